@@ -1,13 +1,54 @@
 package main
 
 import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"net"
 	"os"
+	"os/signal"
+	"runtime/debug"
+	"strings"
+	"syscall"
 
 	"bennypowers.dev/dtls/internal/log"
+	"bennypowers.dev/dtls/internal/metrics"
 	"bennypowers.dev/dtls/lsp"
+	"bennypowers.dev/dtls/mcp"
+	"bennypowers.dev/dtls/pkg/analyzer"
 )
 
 func main() {
+	// `design-tokens-language-server mcp` serves token queries to AI assistants
+	// instead of speaking LSP
+	if len(os.Args) > 1 && os.Args[1] == "mcp" {
+		if err := runMCP(os.Args[2:]); err != nil {
+			log.Error("MCP server error: %v", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// `design-tokens-language-server daemon` serves several editors from one
+	// process, sharing token indexes between them
+	if len(os.Args) > 1 && os.Args[1] == "daemon" {
+		if err := runDaemon(os.Args[2:]); err != nil {
+			log.Error("Daemon error: %v", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// With --connect, relay stdio to a running daemon instead of serving
+	if socket := flagValue(os.Args[1:], "connect"); socket != "" {
+		if err := runConnect(socket); err != nil {
+			log.Error("Failed to connect to daemon: %v", err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	// Create and run the LSP server
 	server, err := lsp.NewServer()
 	if err != nil {
@@ -15,9 +56,147 @@ func main() {
 		os.Exit(1)
 	}
 
+	if addr := metricsAddr(os.Args[1:]); addr != "" {
+		serveMetrics(server, addr)
+	}
+
+	// Write a crash report before dying on an unrecovered panic
+	defer func() {
+		if r := recover(); r != nil {
+			server.ReportCrash(fmt.Sprintf("panic: %v", r), debug.Stack())
+			panic(r)
+		}
+	}()
+
 	// Run with stdio transport (for VSCode and other editors)
 	if err := server.RunStdio(); err != nil {
 		log.Error("Server error: %v", err)
+		server.ReportCrash(fmt.Sprintf("fatal: %v", err), debug.Stack())
 		os.Exit(1)
 	}
+	writeExitProfile()
+}
+
+// writeExitProfile writes a heap profile as the server exits, when
+// metrics.ProfileDirEnv asks for profiles
+func writeExitProfile() {
+	if os.Getenv(metrics.ProfileDirEnv) == "" {
+		return
+	}
+	f, err := metrics.CreateProfileFile("heap")
+	if err != nil {
+		log.Error("Failed to write exit heap profile: %v", err)
+		return
+	}
+	defer func() { _ = f.Close() }()
+	if err := metrics.WriteProfile(f, "heap", 0); err != nil {
+		log.Error("Failed to write exit heap profile: %v", err)
+		return
+	}
+	log.Info("Wrote exit heap profile to %s", f.Name())
+}
+
+// metricsAddr returns the value of the --metrics-addr flag, or "" if it is absent.
+func metricsAddr(args []string) string {
+	return flagValue(args, "metrics-addr")
+}
+
+// flagValue returns the value of the flag with name, or "" if it is absent.
+// Other arguments, such as the --stdio flag some clients pass, are ignored.
+func flagValue(args []string, flagName string) string {
+	for i, arg := range args {
+		name, value, hasValue := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		if name != flagName || !strings.HasPrefix(arg, "-") {
+			continue
+		}
+		if hasValue {
+			return value
+		}
+		if i+1 < len(args) {
+			return args[i+1]
+		}
+	}
+	return ""
+}
+
+// serveMetrics serves Prometheus metrics and pprof profiles on addr.
+// Failing to listen is logged rather than fatal: metrics are a diagnostic aid.
+func serveMetrics(server *lsp.Server, addr string) {
+	metrics.SetGauge("tokens_loaded", "Design tokens currently loaded.", func() float64 {
+		return float64(server.TokenCount())
+	})
+	metrics.SetGauge("documents_open", "Documents currently open in the client.", func() float64 {
+		return float64(len(server.AllDocuments()))
+	})
+
+	bound, err := metrics.Serve(addr)
+	if err != nil {
+		log.Error("Failed to serve metrics: %v", err)
+		return
+	}
+	log.Info("Serving metrics on http://%s/metrics", bound)
+}
+
+// runMCP serves MCP over stdio, loading tokens from the workspace's package.json
+func runMCP(args []string) error {
+	flags := flag.NewFlagSet("mcp", flag.ContinueOnError)
+	root := flags.String("root", ".", "workspace root containing package.json")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	a, err := analyzer.New(analyzer.Options{Root: *root})
+	if a == nil {
+		return err
+	}
+	defer func() { _ = a.Close() }()
+	if err != nil {
+		// Serve the tokens which did load
+		log.Warn("Failed to load some tokens: %v", err)
+	}
+
+	return mcp.NewServer(a).RunStdio()
+}
+
+// runDaemon serves LSP clients on a Unix socket until interrupted
+func runDaemon(args []string) error {
+	flags := flag.NewFlagSet("daemon", flag.ContinueOnError)
+	socket := flags.String("socket", lsp.DefaultSocket(), "Unix socket to listen on")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	listener, err := lsp.ListenSocket(*socket)
+	if err != nil {
+		return err
+	}
+	// Closing the listener removes the socket and stops accepting clients
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		_ = listener.Close()
+	}()
+
+	return lsp.NewDaemon().Serve(listener)
+}
+
+// runConnect relays LSP messages between stdio and the daemon on socket, so
+// editors which spawn a server process can use the daemon
+func runConnect(socket string) error {
+	conn, err := net.Dial("unix", socket)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = conn.Close() }()
+
+	go func() {
+		_, _ = io.Copy(conn, os.Stdin)
+		// The editor closed stdin: let the daemon see the client leave
+		if unixConn, ok := conn.(*net.UnixConn); ok {
+			_ = unixConn.CloseWrite()
+		}
+	}()
+	_, err = io.Copy(os.Stdout, conn)
+	return err
 }
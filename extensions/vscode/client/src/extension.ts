@@ -1,6 +1,6 @@
 import * as path from "node:path";
 import * as os from "node:os";
-import { ExtensionContext } from "vscode";
+import { ExtensionContext, workspace } from "vscode";
 
 import {
   LanguageClient,
@@ -47,17 +47,34 @@ export async function activate(context: ExtensionContext) {
     path.join("dist", "bin", binaryName),
   );
 
+  const settings = workspace.getConfiguration("designTokensLanguageServer");
+  const languages = [
+    "css",
+    "scss",
+    "html",
+    "javascript",
+    "javascriptreact",
+    "typescript",
+    "typescriptreact",
+    "json",
+    "yaml",
+    // Nonstandard language IDs routed to a supported language's pipeline
+    ...Object.keys(settings.get<Record<string, string>>("languageOverrides") ?? {}),
+  ];
+  const schemes = settings.get<boolean>("nonFileDocuments")
+    ? ["file", "untitled"]
+    : ["file"];
+
   const clientOptions: LanguageClientOptions = {
-    documentSelector: [
-      { scheme: "file", language: "css" },
-      { scheme: "file", language: "html" },
-      { scheme: "file", language: "javascript" },
-      { scheme: "file", language: "javascriptreact" },
-      { scheme: "file", language: "typescript" },
-      { scheme: "file", language: "typescriptreact" },
-      { scheme: "file", language: "json" },
-      { scheme: "file", language: "yaml" },
-    ],
+    documentSelector: schemes.flatMap((scheme) =>
+      languages.map((language) => ({ scheme, language })),
+    ),
+    // Sent with initialize so that disabled features are not advertised
+    initializationOptions: {
+      designTokensLanguageServer: workspace.getConfiguration("designTokensLanguageServer"),
+      // Untrusted workspaces run the server read-only
+      workspaceTrusted: workspace.isTrusted,
+    },
   };
 
   client = new LanguageClient(
@@ -70,6 +87,17 @@ export async function activate(context: ExtensionContext) {
     clientOptions,
   );
 
+  // Trust is only sent with initialize, so restart to lift the restrictions
+  context.subscriptions.push(
+    workspace.onDidGrantWorkspaceTrust(async () => {
+      try {
+        await client.restart();
+      } catch (error) {
+        console.error(error);
+      }
+    }),
+  );
+
   try {
     await client.start();
   } catch (error) {
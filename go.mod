@@ -6,6 +6,7 @@ require (
 	bennypowers.dev/asimonim v0.1.4
 	github.com/bmatcuk/doublestar/v4 v4.9.2
 	github.com/mazznoer/csscolorparser v0.1.8
+	github.com/sourcegraph/jsonrpc2 v0.2.0
 	github.com/stretchr/testify v1.11.1
 	github.com/tidwall/jsonc v0.3.2
 	github.com/tliron/glsp v0.2.2
@@ -32,7 +33,6 @@ require (
 	github.com/rivo/uniseg v0.4.7 // indirect
 	github.com/sasha-s/go-deadlock v0.3.5 // indirect
 	github.com/segmentio/ksuid v1.0.4 // indirect
-	github.com/sourcegraph/jsonrpc2 v0.2.0 // indirect
 	github.com/tliron/commonlog v0.2.19 // indirect
 	github.com/tliron/kutil v0.3.27 // indirect
 	golang.org/x/crypto v0.43.0 // indirect
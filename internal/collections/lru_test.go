@@ -0,0 +1,39 @@
+package collections_test
+
+import (
+	"testing"
+
+	"bennypowers.dev/dtls/internal/collections"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLRU(t *testing.T) {
+	c := collections.NewLRU[string, int](2)
+	c.Put("a", 1)
+	c.Put("b", 2)
+
+	v, ok := c.Get("a")
+	assert.True(t, ok)
+	assert.Equal(t, 1, v)
+
+	// "b" is now least recently used, so it is evicted
+	c.Put("c", 3)
+	assert.Equal(t, 2, c.Len())
+	_, ok = c.Get("b")
+	assert.False(t, ok)
+	_, ok = c.Get("a")
+	assert.True(t, ok)
+
+	// Putting an existing key replaces its value without evicting
+	c.Put("c", 4)
+	v, _ = c.Get("c")
+	assert.Equal(t, 4, v)
+	assert.Equal(t, 2, c.Len())
+}
+
+func TestLRU_MinimumCapacity(t *testing.T) {
+	c := collections.NewLRU[string, int](0)
+	c.Put("a", 1)
+	c.Put("b", 2)
+	assert.Equal(t, 1, c.Len())
+}
@@ -0,0 +1,104 @@
+// Package crashreport writes diagnostic bundles when the server exits abnormally.
+//
+// A bundle is a plain-text file containing the server version, the reason for
+// the crash, a snapshot of the configuration, the loaded token files, the most
+// recent log lines, and the Go stack. Users can attach it to bug reports.
+package crashreport
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+	"time"
+
+	"bennypowers.dev/dtls/internal/version"
+)
+
+// filePattern is the os.CreateTemp pattern for bundle files
+const filePattern = "dtls-crash-*.txt"
+
+// Report is the content of a crash bundle
+type Report struct {
+	// Reason describes what went wrong (panic value or fatal error)
+	Reason string
+
+	// Config is a snapshot of the server configuration, serialized as JSON
+	Config any
+
+	// TokenFiles lists the token files loaded at the time of the crash
+	TokenFiles []string
+
+	// LogLines are the most recent log lines, oldest first
+	LogLines []string
+
+	// Stack is the Go stack trace captured at the crash site
+	Stack []byte
+}
+
+// Write writes the report to a new file in dir and returns its path.
+// If dir is empty, os.TempDir() is used.
+func Write(dir string, report Report) (string, error) {
+	if dir == "" {
+		dir = os.TempDir()
+	}
+
+	f, err := os.CreateTemp(dir, filePattern)
+	if err != nil {
+		return "", fmt.Errorf("failed to create crash report: %w", err)
+	}
+
+	if _, err := f.WriteString(report.String()); err != nil {
+		_ = f.Close()
+		return "", fmt.Errorf("failed to write crash report: %w", err)
+	}
+
+	if err := f.Close(); err != nil {
+		return "", fmt.Errorf("failed to close crash report: %w", err)
+	}
+
+	return f.Name(), nil
+}
+
+// String renders the report as the bundle's text content
+func (r Report) String() string {
+	var b strings.Builder
+
+	b.WriteString("# Design Tokens Language Server crash report\n\n")
+	fmt.Fprintf(&b, "Time:    %s\n", time.Now().UTC().Format(time.RFC3339))
+	fmt.Fprintf(&b, "Version: %s\n", version.GetVersion())
+	fmt.Fprintf(&b, "Go:      %s %s/%s\n", runtime.Version(), runtime.GOOS, runtime.GOARCH)
+	fmt.Fprintf(&b, "Reason:  %s\n", r.Reason)
+
+	b.WriteString("\n## Configuration\n\n")
+	config, err := json.MarshalIndent(r.Config, "", "  ")
+	if err != nil {
+		fmt.Fprintf(&b, "(failed to serialize configuration: %v)\n", err)
+	} else {
+		b.Write(config)
+		b.WriteString("\n")
+	}
+
+	b.WriteString("\n## Loaded token files\n\n")
+	if len(r.TokenFiles) == 0 {
+		b.WriteString("(none)\n")
+	}
+	for _, file := range r.TokenFiles {
+		fmt.Fprintf(&b, "- %s\n", file)
+	}
+
+	fmt.Fprintf(&b, "\n## Recent log lines (%d)\n\n", len(r.LogLines))
+	for _, line := range r.LogLines {
+		b.WriteString(line)
+		b.WriteString("\n")
+	}
+
+	b.WriteString("\n## Stack trace\n\n")
+	b.Write(r.Stack)
+	if len(r.Stack) > 0 && r.Stack[len(r.Stack)-1] != '\n' {
+		b.WriteString("\n")
+	}
+
+	return b.String()
+}
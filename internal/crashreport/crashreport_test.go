@@ -0,0 +1,53 @@
+package crashreport_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"bennypowers.dev/dtls/internal/crashreport"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWrite(t *testing.T) {
+	dir := t.TempDir()
+
+	path, err := crashreport.Write(dir, crashreport.Report{
+		Reason:     "panic: boom",
+		Config:     map[string]any{"prefix": "ds"},
+		TokenFiles: []string{"/workspace/tokens.json", "/workspace/colors.json"},
+		LogLines:   []string{"[DTLS] INFO: loaded tokens", "[DTLS] ERROR: something broke"},
+		Stack:      []byte("goroutine 1 [running]:\nmain.main()"),
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, dir, filepath.Dir(path))
+	assert.True(t, strings.HasPrefix(filepath.Base(path), "dtls-crash-"))
+
+	content, err := os.ReadFile(path) //nolint:gosec // G304: Path returned by Write - test code only
+	require.NoError(t, err)
+	report := string(content)
+
+	assert.Contains(t, report, "Reason:  panic: boom")
+	assert.Contains(t, report, `"prefix": "ds"`)
+	assert.Contains(t, report, "- /workspace/tokens.json\n- /workspace/colors.json")
+	assert.Contains(t, report, "## Recent log lines (2)")
+	assert.Contains(t, report, "[DTLS] ERROR: something broke")
+	assert.True(t, strings.HasSuffix(report, "main.main()\n"), "stack should end the report with a newline")
+}
+
+func TestWrite_NoTokenFiles(t *testing.T) {
+	path, err := crashreport.Write(t.TempDir(), crashreport.Report{Reason: "fatal: closed"})
+	require.NoError(t, err)
+
+	content, err := os.ReadFile(path) //nolint:gosec // G304: Path returned by Write - test code only
+	require.NoError(t, err)
+	assert.Contains(t, string(content), "## Loaded token files\n\n(none)\n")
+}
+
+func TestWrite_MissingDir(t *testing.T) {
+	_, err := crashreport.Write(filepath.Join(t.TempDir(), "missing"), crashreport.Report{})
+	assert.Error(t, err)
+}
@@ -0,0 +1,248 @@
+// Package figma imports Figma variables as design tokens.
+//
+// It fetches a file's local variables from the Figma REST API and converts
+// them to a DTCG token document, so they load like any other token file.
+// Each variable collection becomes a top-level group, and one of the
+// collection's modes supplies the values.
+package figma
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strings"
+)
+
+// DefaultEndpoint is the base URL of the Figma REST API
+const DefaultEndpoint = "https://api.figma.com"
+
+// maxResponseSize bounds the variables response read from the API
+const maxResponseSize = 32 << 20
+
+// Variables are the local variables of a Figma file and their collections,
+// keyed by ID
+type Variables struct {
+	Variables   map[string]Variable   `json:"variables"`
+	Collections map[string]Collection `json:"variableCollections"`
+}
+
+// Variable is a Figma variable. Its name is slash-separated, e.g. "color/primary".
+type Variable struct {
+	ID           string                     `json:"id"`
+	Name         string                     `json:"name"`
+	Description  string                     `json:"description"`
+	CollectionID string                     `json:"variableCollectionId"`
+	ResolvedType string                     `json:"resolvedType"`
+	ValuesByMode map[string]json.RawMessage `json:"valuesByMode"`
+}
+
+// Collection is a Figma variable collection, whose modes hold alternative
+// values for its variables, e.g. "Light" and "Dark"
+type Collection struct {
+	ID            string `json:"id"`
+	Name          string `json:"name"`
+	Modes         []Mode `json:"modes"`
+	DefaultModeID string `json:"defaultModeId"`
+}
+
+// Mode is a collection's mode
+type Mode struct {
+	ModeID string `json:"modeId"`
+	Name   string `json:"name"`
+}
+
+// FileURL returns the URL of a Figma file, for linking tokens to their source
+func FileURL(fileKey string) string {
+	return "https://www.figma.com/design/" + fileKey
+}
+
+// Fetch gets the local variables of the Figma file with fileKey, authorized by
+// a personal access token
+func Fetch(ctx context.Context, client *http.Client, endpoint, fileKey, token string) (*Variables, error) {
+	if endpoint == "" {
+		endpoint = DefaultEndpoint
+	}
+	url := strings.TrimSuffix(endpoint, "/") + "/v1/files/" + fileKey + "/variables/local"
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Figma request: %w", err)
+	}
+	req.Header.Set("X-Figma-Token", token)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch Figma variables: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read Figma variables: %w", err)
+	}
+
+	var result struct {
+		Error   bool      `json:"error"`
+		Message string    `json:"message"`
+		Meta    Variables `json:"meta"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("failed to parse Figma variables (HTTP %d): %w", resp.StatusCode, err)
+	}
+	if resp.StatusCode != http.StatusOK || result.Error {
+		if result.Message == "" {
+			result.Message = http.StatusText(resp.StatusCode)
+		}
+		return nil, fmt.Errorf("figma API error (HTTP %d): %s", resp.StatusCode, result.Message)
+	}
+	return &result.Meta, nil
+}
+
+// Tokens converts variables to a DTCG token document. Values come from the
+// mode that modes names for each collection, or else the collection's
+// default mode. Boolean variables are skipped, having no CSS value, and so
+// are aliases of variables from other files; each skipped variable is
+// reported in the returned error, alongside the document.
+func Tokens(vars *Variables, modes map[string]string) ([]byte, error) {
+	doc := map[string]any{}
+	var errs []error
+	for _, v := range vars.Variables {
+		collection, ok := vars.Collections[v.CollectionID]
+		if !ok {
+			errs = append(errs, fmt.Errorf("%s: unknown collection %s", v.Name, v.CollectionID))
+			continue
+		}
+		if v.ResolvedType == "BOOLEAN" {
+			continue
+		}
+
+		mode := modeID(collection, modes[collection.Name])
+		raw, ok := v.ValuesByMode[mode]
+		if !ok {
+			errs = append(errs, fmt.Errorf("%s: no value for mode %s", v.Name, mode))
+			continue
+		}
+		token, err := tokenObject(vars, v, raw)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", v.Name, err))
+			continue
+		}
+
+		group := doc
+		path := tokenPath(collection, v)
+		for _, segment := range path[:len(path)-1] {
+			child, ok := group[segment].(map[string]any)
+			if !ok {
+				child = map[string]any{}
+				group[segment] = child
+			}
+			group = child
+		}
+		group[path[len(path)-1]] = token
+	}
+
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+	return data, errors.Join(errs...)
+}
+
+// modeID returns the ID of the collection's mode with name, or of its
+// default mode when no mode has that name
+func modeID(collection Collection, name string) string {
+	for _, mode := range collection.Modes {
+		if mode.Name == name {
+			return mode.ModeID
+		}
+	}
+	return collection.DefaultModeID
+}
+
+// tokenPath returns the token path of a variable: its collection's name,
+// then each part of its name
+func tokenPath(collection Collection, v Variable) []string {
+	path := []string{segment(collection.Name)}
+	for part := range strings.SplitSeq(v.Name, "/") {
+		path = append(path, segment(part))
+	}
+	return path
+}
+
+// segment makes a Figma name usable as a token path segment, which can't
+// contain dots or braces, and becomes part of a CSS variable name
+func segment(name string) string {
+	return strings.Join(strings.FieldsFunc(name, func(r rune) bool {
+		return r == ' ' || r == '.' || r == '{' || r == '}'
+	}), "-")
+}
+
+// tokenObject converts a variable's value to a DTCG token
+func tokenObject(vars *Variables, v Variable, raw json.RawMessage) (map[string]any, error) {
+	token := map[string]any{}
+	if v.Description != "" {
+		token["$description"] = v.Description
+	}
+	switch v.ResolvedType {
+	case "COLOR":
+		token["$type"] = "color"
+	case "FLOAT":
+		token["$type"] = "number"
+	}
+
+	var alias struct {
+		Type string `json:"type"`
+		ID   string `json:"id"`
+	}
+	if json.Unmarshal(raw, &alias) == nil && alias.Type == "VARIABLE_ALIAS" {
+		target, ok := vars.Variables[alias.ID]
+		if !ok {
+			return nil, fmt.Errorf("aliases %s, which is not in the file", alias.ID)
+		}
+		collection, ok := vars.Collections[target.CollectionID]
+		if !ok {
+			return nil, fmt.Errorf("aliases %s, whose collection is unknown", target.Name)
+		}
+		token["$value"] = "{" + strings.Join(tokenPath(collection, target), ".") + "}"
+		return token, nil
+	}
+
+	switch v.ResolvedType {
+	case "COLOR":
+		var color struct{ R, G, B, A float64 }
+		if err := json.Unmarshal(raw, &color); err != nil {
+			return nil, fmt.Errorf("invalid color: %w", err)
+		}
+		token["$value"] = hexColor(color.R, color.G, color.B, color.A)
+	case "FLOAT":
+		var number float64
+		if err := json.Unmarshal(raw, &number); err != nil {
+			return nil, fmt.Errorf("invalid number: %w", err)
+		}
+		token["$value"] = number
+	case "STRING":
+		var str string
+		if err := json.Unmarshal(raw, &str); err != nil {
+			return nil, fmt.Errorf("invalid string: %w", err)
+		}
+		token["$value"] = str
+	default:
+		return nil, fmt.Errorf("unsupported variable type %q", v.ResolvedType)
+	}
+	return token, nil
+}
+
+// hexColor writes Figma's 0-1 color channels as a hex color, with an alpha
+// channel only when the color is translucent
+func hexColor(r, g, b, a float64) string {
+	channel := func(c float64) uint8 {
+		return uint8(math.Round(math.Max(0, math.Min(1, c)) * 255))
+	}
+	if a >= 1 {
+		return fmt.Sprintf("#%02x%02x%02x", channel(r), channel(g), channel(b))
+	}
+	return fmt.Sprintf("#%02x%02x%02x%02x", channel(r), channel(g), channel(b), channel(a))
+}
@@ -0,0 +1,79 @@
+package figma_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"bennypowers.dev/dtls/internal/figma"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// serveVariables serves the variables fixture to requests with the right token
+func serveVariables(t *testing.T) *httptest.Server {
+	t.Helper()
+	fixture, err := os.ReadFile("testdata/variables.json")
+	require.NoError(t, err)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Figma-Token") != "secret" {
+			w.WriteHeader(http.StatusForbidden)
+			_, _ = w.Write([]byte(`{"status": 403, "error": true, "message": "Invalid token"}`))
+			return
+		}
+		if r.URL.Path != "/v1/files/abc123/variables/local" {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"status": 404, "error": true, "message": "Not found"}`))
+			return
+		}
+		_, _ = w.Write(fixture)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestFetch(t *testing.T) {
+	server := serveVariables(t)
+
+	t.Run("variables", func(t *testing.T) {
+		vars, err := figma.Fetch(context.Background(), server.Client(), server.URL, "abc123", "secret")
+		require.NoError(t, err)
+		assert.Len(t, vars.Variables, 7)
+		assert.Equal(t, "Theme", vars.Collections["VariableCollectionId:2:0"].Name)
+	})
+
+	t.Run("API error", func(t *testing.T) {
+		_, err := figma.Fetch(context.Background(), server.Client(), server.URL, "abc123", "wrong")
+		assert.ErrorContains(t, err, "HTTP 403): Invalid token")
+	})
+}
+
+func TestTokens(t *testing.T) {
+	server := serveVariables(t)
+	vars, err := figma.Fetch(context.Background(), server.Client(), server.URL, "abc123", "secret")
+	require.NoError(t, err)
+
+	t.Run("default modes", func(t *testing.T) {
+		doc, err := figma.Tokens(vars, nil)
+		assert.ErrorContains(t, err, "text/link: aliases VariableID:remote, which is not in the file")
+		assert.JSONEq(t, `{
+			"Primitives": {
+				"blue": {"500": {"$type": "color", "$value": "#0066ff", "$description": "Brand blue"}},
+				"gray": {"900": {"$type": "color", "$value": "#1a1a1a80"}},
+				"space": {"1-5": {"$type": "number", "$value": 6}},
+				"font": {"body": {"$value": "Inter"}}
+			},
+			"Theme": {
+				"text": {"primary": {"$type": "color", "$value": "{Primitives.gray.900}"}}
+			}
+		}`, string(doc))
+	})
+
+	t.Run("named mode", func(t *testing.T) {
+		doc, _ := figma.Tokens(vars, map[string]string{"Theme": "Dark"})
+		assert.Contains(t, string(doc), `"primary":{"$type":"color","$value":"{Primitives.blue.500}"}`)
+	})
+}
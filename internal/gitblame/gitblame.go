@@ -0,0 +1,147 @@
+// Package gitblame annotates token definitions with the commit that last changed them.
+//
+// It shells out to `git blame --porcelain` for a single line and caches the
+// result, so repeated hovers over the same token don't spawn git again.
+package gitblame
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"bennypowers.dev/dtls/internal/metrics"
+)
+
+// timeout bounds each git invocation so a slow repository can't stall a hover
+const timeout = 2 * time.Second
+
+// Annotation describes the last commit to touch a line
+type Annotation struct {
+	// Commit is the full commit hash
+	Commit string
+
+	// Date is when the commit was made (committer time)
+	Date time.Time
+
+	// Subject is the first line of the commit message
+	Subject string
+}
+
+// DateString formats the commit date as YYYY-MM-DD
+func (a *Annotation) DateString() string {
+	return a.Date.Format(time.DateOnly)
+}
+
+// RunFunc runs git with args in dir and returns its standard output
+type RunFunc func(dir string, args ...string) ([]byte, error)
+
+type key struct {
+	path string
+	line uint32
+}
+
+// Cache looks up and caches annotations for file lines.
+// Lookups that fail (untracked files, uncommitted lines, no git) are cached
+// as nil too. It is safe for concurrent use.
+type Cache struct {
+	mu      sync.Mutex
+	entries map[key]*Annotation
+	run     RunFunc
+}
+
+// NewCache creates a cache that runs the git executable
+func NewCache() *Cache {
+	return NewCacheWithRunner(runGit)
+}
+
+// NewCacheWithRunner creates a cache that runs git through run, for testing
+func NewCacheWithRunner(run RunFunc) *Cache {
+	return &Cache{
+		entries: make(map[key]*Annotation),
+		run:     run,
+	}
+}
+
+// Annotate returns the last commit to change the 0-based line of path,
+// or nil if it can't be determined
+func (c *Cache) Annotate(path string, line uint32) *Annotation {
+	k := key{path: filepath.Clean(path), line: line}
+
+	c.mu.Lock()
+	if a, ok := c.entries[k]; ok {
+		c.mu.Unlock()
+		metrics.CacheLookup("gitBlame", true)
+		return a
+	}
+	c.mu.Unlock()
+	metrics.CacheLookup("gitBlame", false)
+
+	lineArg := fmt.Sprintf("%d,%d", line+1, line+1)
+	out, err := c.run(filepath.Dir(k.path), "blame", "--porcelain", "-L", lineArg, "--", filepath.Base(k.path))
+	var a *Annotation
+	if err == nil {
+		a = parsePorcelain(out)
+	}
+
+	c.mu.Lock()
+	c.entries[k] = a
+	c.mu.Unlock()
+	return a
+}
+
+// Clear drops all cached annotations, e.g. after token files change
+func (c *Cache) Clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	clear(c.entries)
+}
+
+// parsePorcelain reads the commit of a single-line `git blame --porcelain` result.
+// Lines that are not yet committed have an all-zero hash and yield nil.
+func parsePorcelain(out []byte) *Annotation {
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	if !scanner.Scan() {
+		return nil
+	}
+	header := strings.Fields(scanner.Text())
+	if len(header) == 0 || strings.Trim(header[0], "0") == "" {
+		return nil
+	}
+
+	a := &Annotation{Commit: header[0]}
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "\t") {
+			// The line content ends the commit headers
+			break
+		}
+		field, value, _ := strings.Cut(line, " ")
+		switch field {
+		case "committer-time":
+			if sec, err := strconv.ParseInt(value, 10, 64); err == nil {
+				a.Date = time.Unix(sec, 0).UTC()
+			}
+		case "summary":
+			a.Subject = value
+		}
+	}
+	if a.Date.IsZero() {
+		return nil
+	}
+	return a
+}
+
+func runGit(dir string, args ...string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, "git", args...) //nolint:gosec // G204: fixed executable, arguments built by Annotate
+	cmd.Dir = dir
+	return cmd.Output()
+}
@@ -0,0 +1,108 @@
+package gitblame
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePorcelain(t *testing.T) {
+	t.Run("committed line", func(t *testing.T) {
+		out, err := os.ReadFile("testdata/committed.porcelain")
+		require.NoError(t, err)
+
+		a := parsePorcelain(out)
+		require.NotNil(t, a)
+		assert.Equal(t, "8c1b2f0d6e4a3b2c1d0e9f8a7b6c5d4e3f2a1b0c", a.Commit)
+		assert.Equal(t, "Darken primary for contrast", a.Subject)
+		assert.Equal(t, "2026-03-14", a.DateString())
+	})
+
+	t.Run("uncommitted line", func(t *testing.T) {
+		out, err := os.ReadFile("testdata/uncommitted.porcelain")
+		require.NoError(t, err)
+		assert.Nil(t, parsePorcelain(out))
+	})
+
+	t.Run("empty output", func(t *testing.T) {
+		assert.Nil(t, parsePorcelain(nil))
+	})
+}
+
+func TestCache_Annotate(t *testing.T) {
+	out, err := os.ReadFile("testdata/committed.porcelain")
+	require.NoError(t, err)
+
+	var calls [][]string
+	var dirs []string
+	cache := NewCacheWithRunner(func(dir string, args ...string) ([]byte, error) {
+		dirs = append(dirs, dir)
+		calls = append(calls, args)
+		if filepath.Base(args[len(args)-1]) == "untracked.json" {
+			return nil, errors.New("fatal: no such path in HEAD")
+		}
+		return out, nil
+	})
+
+	path := filepath.Join("project", "tokens.json")
+	a := cache.Annotate(path, 2)
+	require.NotNil(t, a)
+	assert.Equal(t, "Darken primary for contrast", a.Subject)
+	assert.Equal(t, []string{"blame", "--porcelain", "-L", "3,3", "--", "tokens.json"}, calls[0])
+	assert.Equal(t, "project", dirs[0])
+
+	// Repeated lookups are served from the cache
+	assert.Same(t, a, cache.Annotate(path, 2))
+	assert.Len(t, calls, 1)
+
+	// Failures are cached too
+	assert.Nil(t, cache.Annotate(filepath.Join("project", "untracked.json"), 0))
+	assert.Nil(t, cache.Annotate(filepath.Join("project", "untracked.json"), 0))
+	assert.Len(t, calls, 2)
+
+	cache.Clear()
+	cache.Annotate(path, 2)
+	assert.Len(t, calls, 3)
+}
+
+func TestCache_AnnotateGitRepository(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+
+	dir := t.TempDir()
+	git := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		cmd.Env = append(os.Environ(),
+			"GIT_AUTHOR_NAME=Test", "GIT_AUTHOR_EMAIL=test@example.com",
+			"GIT_COMMITTER_NAME=Test", "GIT_COMMITTER_EMAIL=test@example.com",
+			"GIT_COMMITTER_DATE=2026-03-14T09:30:00Z",
+		)
+		out, err := cmd.CombinedOutput()
+		require.NoError(t, err, string(out))
+	}
+
+	path := filepath.Join(dir, "tokens.json")
+	require.NoError(t, os.WriteFile(path, []byte("{\n  \"color\": {}\n}\n"), 0o600))
+	git("init", "-q")
+	git("add", "tokens.json")
+	git("commit", "-q", "-m", "Add color tokens")
+	require.NoError(t, os.WriteFile(path, []byte("{\n  \"color\": {}\n}\n\n"), 0o600))
+
+	cache := NewCache()
+	a := cache.Annotate(path, 1)
+	require.NotNil(t, a)
+	assert.Equal(t, "Add color tokens", a.Subject)
+	assert.Equal(t, time.Date(2026, 3, 14, 9, 30, 0, 0, time.UTC), a.Date)
+
+	// The trailing blank line isn't committed yet
+	assert.Nil(t, cache.Annotate(path, 3))
+}
@@ -0,0 +1,68 @@
+// Package i18n translates user-facing strings, such as code action titles,
+// diagnostic messages and hover labels, into the client's UI language.
+//
+// Messages are keyed by their English text, as fmt format strings, so call
+// sites read as plain English and messages without a translation fall back
+// to it. Translations live in locales/<language>.json.
+package i18n
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+
+	"bennypowers.dev/dtls/internal/log"
+)
+
+//go:embed locales/*.json
+var localesFS embed.FS
+
+// catalogs maps a lowercase language tag (e.g. "de" or "pt-br") to its
+// translations, keyed by English message
+var catalogs = loadCatalogs()
+
+func loadCatalogs() map[string]map[string]string {
+	result := make(map[string]map[string]string)
+	entries, err := localesFS.ReadDir("locales")
+	if err != nil {
+		return result
+	}
+	for _, entry := range entries {
+		data, err := localesFS.ReadFile(path.Join("locales", entry.Name()))
+		if err != nil {
+			continue
+		}
+		var catalog map[string]string
+		if err := json.Unmarshal(data, &catalog); err != nil {
+			log.Error("Invalid message catalog %s: %v", entry.Name(), err)
+			continue
+		}
+		result[strings.TrimSuffix(entry.Name(), ".json")] = catalog
+	}
+	return result
+}
+
+// Translate returns message in the language of locale, e.g. "de" or
+// "de-CH". Regional locales fall back to their language, and untranslated
+// messages to English.
+func Translate(locale, message string) string {
+	tag := strings.ToLower(strings.ReplaceAll(locale, "_", "-"))
+	for tag != "" {
+		if translated, ok := catalogs[tag][message]; ok {
+			return translated
+		}
+		i := strings.LastIndex(tag, "-")
+		if i < 0 {
+			break
+		}
+		tag = tag[:i]
+	}
+	return message
+}
+
+// Sprintf translates format and formats it with args
+func Sprintf(locale, format string, args ...any) string {
+	return fmt.Sprintf(Translate(locale, format), args...)
+}
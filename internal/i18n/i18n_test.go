@@ -0,0 +1,48 @@
+package i18n
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTranslate(t *testing.T) {
+	tests := []struct {
+		name    string
+		locale  string
+		message string
+		want    string
+	}{
+		{"english", "en", "Unknown token", "Unknown token"},
+		{"no locale", "", "Unknown token", "Unknown token"},
+		{"language", "de", "Unknown token", "Unbekanntes Token"},
+		{"region falls back to language", "de-CH", "Unknown token", "Unbekanntes Token"},
+		{"underscore and case", "DE_at", "Unknown token", "Unbekanntes Token"},
+		{"untranslated message", "de", "Not in any catalog", "Not in any catalog"},
+		{"unknown language", "xx", "Unknown token", "Unknown token"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, Translate(tt.locale, tt.message))
+		})
+	}
+}
+
+func TestSprintf(t *testing.T) {
+	assert.Equal(t, "Gruppe 'color' alphabetisch sortieren", Sprintf("de", "Sort group '%s' alphabetically", "color"))
+	assert.Equal(t, "Sort group 'color' alphabetically", Sprintf("fr", "Sort group '%s' alphabetically", "color"))
+}
+
+// verbPattern matches fmt verbs, e.g. %s, %d or %q
+var verbPattern = regexp.MustCompile(`%[-+# 0-9.]*[a-zA-Z%]`)
+
+func TestCatalogs_KeepVerbs(t *testing.T) {
+	assert.NotEmpty(t, catalogs)
+	for language, catalog := range catalogs {
+		for message, translated := range catalog {
+			assert.Equal(t, verbPattern.FindAllString(message, -1), verbPattern.FindAllString(translated, -1),
+				"%s translation of %q must use the same verbs in the same order", language, message)
+		}
+	}
+}
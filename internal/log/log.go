@@ -40,10 +40,18 @@ func (l Level) String() string {
 
 const prefix = "[DTLS]"
 
+// historySize is the number of recent log lines retained for crash reports
+const historySize = 100
+
 var (
 	mu       sync.Mutex
 	output   io.Writer = os.Stderr
 	minLevel atomic.Int32
+
+	// history is a ring buffer of the most recent log lines, guarded by mu
+	history     [historySize]string
+	historyNext int
+	historyLen  int
 )
 
 func init() {
@@ -67,6 +75,20 @@ func GetLevel() Level {
 	return Level(minLevel.Load())
 }
 
+// Recent returns up to the last 100 logged lines, oldest first.
+// Lines below the minimum level are not recorded.
+func Recent() []string {
+	mu.Lock()
+	defer mu.Unlock()
+
+	lines := make([]string, 0, historyLen)
+	start := (historyNext - historyLen + historySize) % historySize
+	for i := range historyLen {
+		lines = append(lines, history[(start+i)%historySize])
+	}
+	return lines
+}
+
 // Debug logs a debug message (verbose debugging information)
 func Debug(format string, args ...any) {
 	log(LevelDebug, format, args...)
@@ -101,11 +123,6 @@ func log(level Level, format string, args ...any) {
 		return
 	}
 
-	// Skip logging if output is nil (e.g., during test cleanup)
-	if output == nil {
-		return
-	}
-
 	// Map level to label for clarity
 	levelLabel := ""
 	switch level {
@@ -120,6 +137,18 @@ func log(level Level, format string, args ...any) {
 	}
 
 	// Format: [DTLS] LEVEL: message
-	message := fmt.Sprintf(format, args...)
-	_, _ = fmt.Fprintf(output, "%s %s: %s\n", prefix, levelLabel, message)
+	line := fmt.Sprintf("%s %s: %s", prefix, levelLabel, fmt.Sprintf(format, args...))
+
+	history[historyNext] = line
+	historyNext = (historyNext + 1) % historySize
+	if historyLen < historySize {
+		historyLen++
+	}
+
+	// Skip writing if output is nil (e.g., during test cleanup)
+	if output == nil {
+		return
+	}
+
+	_, _ = fmt.Fprintln(output, line)
 }
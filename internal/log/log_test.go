@@ -245,3 +245,31 @@ func TestConcurrentLevelChanges(t *testing.T) {
 	log.SetLevel(log.LevelInfo)
 	assert.Equal(t, log.LevelInfo, log.GetLevel())
 }
+
+func TestRecent(t *testing.T) {
+	log.SetOutput(nil)
+	originalLevel := log.GetLevel()
+	defer log.SetLevel(originalLevel)
+
+	t.Run("keeps the last 100 lines in order", func(t *testing.T) {
+		log.SetLevel(log.LevelInfo)
+		for i := range 150 {
+			log.Info("history line %d", i)
+		}
+
+		lines := log.Recent()
+		assert.Len(t, lines, 100)
+		assert.Equal(t, "[DTLS] INFO: history line 50", lines[0])
+		assert.Equal(t, "[DTLS] INFO: history line 149", lines[99])
+	})
+
+	t.Run("skips filtered levels", func(t *testing.T) {
+		log.SetLevel(log.LevelWarn)
+		log.Info("filtered history line")
+		log.Warn("kept history line")
+
+		lines := log.Recent()
+		assert.Equal(t, "[DTLS] WARN: kept history line", lines[len(lines)-1])
+		assert.NotContains(t, strings.Join(lines, "\n"), "filtered history line")
+	})
+}
@@ -0,0 +1,230 @@
+// Package metrics collects counters about the running server and serves them
+// in the Prometheus text exposition format.
+//
+// Collection is always on and cheap; nothing is served unless the server is
+// started with --metrics-addr. Use the package-level functions, which record
+// to the Default registry.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// latencyBuckets are the upper bounds, in seconds, of the request latency histogram
+var latencyBuckets = []float64{0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// methodStats are the counters for one LSP method
+type methodStats struct {
+	count   uint64
+	errors  uint64
+	sum     float64
+	buckets []uint64 // per latencyBuckets entry, not cumulative
+}
+
+// cacheStats are the lookup counters for one cache
+type cacheStats struct {
+	hits   uint64
+	misses uint64
+}
+
+// gauge is a value read when metrics are scraped
+type gauge struct {
+	help  string
+	value func() float64
+}
+
+// Registry holds the server's metrics
+type Registry struct {
+	mu      sync.Mutex
+	methods map[string]*methodStats
+	caches  map[string]*cacheStats
+	gauges  map[string]gauge
+}
+
+// NewRegistry creates an empty registry
+func NewRegistry() *Registry {
+	return &Registry{
+		methods: make(map[string]*methodStats),
+		caches:  make(map[string]*cacheStats),
+		gauges:  make(map[string]gauge),
+	}
+}
+
+// Default is the registry the package-level functions record to
+var Default = NewRegistry()
+
+// ObserveRequest records a handled request or notification on Default
+func ObserveRequest(method string, duration time.Duration, err error) {
+	Default.ObserveRequest(method, duration, err)
+}
+
+// CacheLookup records a cache hit or miss on Default
+func CacheLookup(cache string, hit bool) {
+	Default.CacheLookup(cache, hit)
+}
+
+// SetGauge registers a gauge on Default
+func SetGauge(name, help string, value func() float64) {
+	Default.SetGauge(name, help, value)
+}
+
+// ObserveRequest records a handled request or notification
+func (r *Registry) ObserveRequest(method string, duration time.Duration, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stats, ok := r.methods[method]
+	if !ok {
+		stats = &methodStats{buckets: make([]uint64, len(latencyBuckets))}
+		r.methods[method] = stats
+	}
+	stats.count++
+	if err != nil {
+		stats.errors++
+	}
+	seconds := duration.Seconds()
+	stats.sum += seconds
+	if i, _ := slices.BinarySearch(latencyBuckets, seconds); i < len(latencyBuckets) {
+		stats.buckets[i]++
+	}
+}
+
+// CacheLookup records a cache hit or miss
+func (r *Registry) CacheLookup(cache string, hit bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stats, ok := r.caches[cache]
+	if !ok {
+		stats = &cacheStats{}
+		r.caches[cache] = stats
+	}
+	if hit {
+		stats.hits++
+	} else {
+		stats.misses++
+	}
+}
+
+// SetGauge registers a gauge whose value is read on each scrape,
+// replacing any gauge with the same name. Names are prefixed with "dtls_".
+func (r *Registry) SetGauge(name, help string, value func() float64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.gauges[name] = gauge{help: help, value: value}
+}
+
+// WriteText writes the metrics in the Prometheus text exposition format
+func (r *Registry) WriteText(w io.Writer) error {
+	r.mu.Lock()
+	methods := make(map[string]methodStats, len(r.methods))
+	for name, stats := range r.methods {
+		methods[name] = methodStats{stats.count, stats.errors, stats.sum, slices.Clone(stats.buckets)}
+	}
+	caches := make(map[string]cacheStats, len(r.caches))
+	for name, stats := range r.caches {
+		caches[name] = *stats
+	}
+	gauges := make(map[string]gauge, len(r.gauges))
+	for name, g := range r.gauges {
+		gauges[name] = g
+	}
+	r.mu.Unlock()
+
+	var b strings.Builder
+	methodNames := sortedKeys(methods)
+
+	header(&b, "dtls_requests_total", "counter", "LSP requests and notifications handled, by method.")
+	for _, name := range methodNames {
+		fmt.Fprintf(&b, "dtls_requests_total{method=%q} %d\n", name, methods[name].count)
+	}
+
+	header(&b, "dtls_request_errors_total", "counter", "LSP requests and notifications which returned an error, by method.")
+	for _, name := range methodNames {
+		fmt.Fprintf(&b, "dtls_request_errors_total{method=%q} %d\n", name, methods[name].errors)
+	}
+
+	header(&b, "dtls_request_duration_seconds", "histogram", "Time spent handling LSP requests and notifications, by method.")
+	for _, name := range methodNames {
+		stats := methods[name]
+		var cumulative uint64
+		for i, le := range latencyBuckets {
+			cumulative += stats.buckets[i]
+			fmt.Fprintf(&b, "dtls_request_duration_seconds_bucket{method=%q,le=\"%g\"} %d\n", name, le, cumulative)
+		}
+		fmt.Fprintf(&b, "dtls_request_duration_seconds_bucket{method=%q,le=\"+Inf\"} %d\n", name, stats.count)
+		fmt.Fprintf(&b, "dtls_request_duration_seconds_sum{method=%q} %g\n", name, stats.sum)
+		fmt.Fprintf(&b, "dtls_request_duration_seconds_count{method=%q} %d\n", name, stats.count)
+	}
+
+	header(&b, "dtls_cache_lookups_total", "counter", "Cache lookups, by cache and result (hit or miss).")
+	for _, name := range sortedKeys(caches) {
+		fmt.Fprintf(&b, "dtls_cache_lookups_total{cache=%q,result=\"hit\"} %d\n", name, caches[name].hits)
+		fmt.Fprintf(&b, "dtls_cache_lookups_total{cache=%q,result=\"miss\"} %d\n", name, caches[name].misses)
+	}
+
+	for _, name := range sortedKeys(gauges) {
+		header(&b, "dtls_"+name, "gauge", gauges[name].help)
+		fmt.Fprintf(&b, "dtls_%s %g\n", name, gauges[name].value())
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// header writes the HELP and TYPE lines of a metric family
+func header(b *strings.Builder, name, kind, help string) {
+	fmt.Fprintf(b, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// Handler serves the registry's metrics at /metrics and the Go runtime
+// profiles at /debug/pprof/
+func (r *Registry) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		if err := r.WriteText(w); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	})
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	return mux
+}
+
+// Serve serves Default's metrics on addr in the background.
+// It returns the address listened on, which differs from addr when addr
+// asks for any free port (e.g. "localhost:0").
+func Serve(addr string) (string, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return "", fmt.Errorf("failed to listen on %s: %w", addr, err)
+	}
+	server := &http.Server{
+		Handler:           Default.Handler(),
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+	go func() { _ = server.Serve(listener) }()
+	return listener.Addr().String(), nil
+}
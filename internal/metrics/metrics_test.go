@@ -0,0 +1,103 @@
+package metrics_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"bennypowers.dev/dtls/internal/metrics"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegistry_WriteText(t *testing.T) {
+	r := metrics.NewRegistry()
+	r.ObserveRequest("textDocument/hover", 3*time.Millisecond, nil)
+	r.ObserveRequest("textDocument/hover", 200*time.Millisecond, errors.New("boom"))
+	r.CacheLookup("semanticTokens", true)
+	r.CacheLookup("semanticTokens", true)
+	r.CacheLookup("semanticTokens", false)
+	r.SetGauge("tokens_loaded", "Design tokens currently loaded.", func() float64 { return 42 })
+
+	var b bytes.Buffer
+	require.NoError(t, r.WriteText(&b))
+	text := b.String()
+
+	assert.Contains(t, text, "# TYPE dtls_requests_total counter\n")
+	assert.Contains(t, text, `dtls_requests_total{method="textDocument/hover"} 2`)
+	assert.Contains(t, text, `dtls_request_errors_total{method="textDocument/hover"} 1`)
+
+	// Buckets are cumulative
+	assert.Contains(t, text, "# TYPE dtls_request_duration_seconds histogram\n")
+	assert.Contains(t, text, `dtls_request_duration_seconds_bucket{method="textDocument/hover",le="0.001"} 0`)
+	assert.Contains(t, text, `dtls_request_duration_seconds_bucket{method="textDocument/hover",le="0.005"} 1`)
+	assert.Contains(t, text, `dtls_request_duration_seconds_bucket{method="textDocument/hover",le="0.25"} 2`)
+	assert.Contains(t, text, `dtls_request_duration_seconds_bucket{method="textDocument/hover",le="+Inf"} 2`)
+	assert.Contains(t, text, `dtls_request_duration_seconds_count{method="textDocument/hover"} 2`)
+
+	assert.Contains(t, text, `dtls_cache_lookups_total{cache="semanticTokens",result="hit"} 2`)
+	assert.Contains(t, text, `dtls_cache_lookups_total{cache="semanticTokens",result="miss"} 1`)
+
+	assert.Contains(t, text, "# TYPE dtls_tokens_loaded gauge\ndtls_tokens_loaded 42\n")
+}
+
+func TestRegistry_SlowRequestsCountOnlyInInf(t *testing.T) {
+	r := metrics.NewRegistry()
+	r.ObserveRequest("workspace/executeCommand", time.Minute, nil)
+
+	var b bytes.Buffer
+	require.NoError(t, r.WriteText(&b))
+	assert.Contains(t, b.String(), `dtls_request_duration_seconds_bucket{method="workspace/executeCommand",le="10"} 0`)
+	assert.Contains(t, b.String(), `dtls_request_duration_seconds_bucket{method="workspace/executeCommand",le="+Inf"} 1`)
+}
+
+func TestServe(t *testing.T) {
+	metrics.ObserveRequest("initialize", time.Millisecond, nil)
+
+	addr, err := metrics.Serve("127.0.0.1:0")
+	require.NoError(t, err)
+
+	resp, err := http.Get("http://" + addr + "/metrics")
+	require.NoError(t, err)
+	defer func() { _ = resp.Body.Close() }()
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Contains(t, resp.Header.Get("Content-Type"), "text/plain")
+	assert.Contains(t, string(body), `dtls_requests_total{method="initialize"} 1`)
+}
+
+func TestWriteProfile(t *testing.T) {
+	assert.True(t, metrics.HasProfile("cpu"))
+	assert.True(t, metrics.HasProfile("heap"))
+	assert.False(t, metrics.HasProfile("nope"))
+
+	var b bytes.Buffer
+	require.NoError(t, metrics.WriteProfile(&b, "heap", 0))
+	assert.NotZero(t, b.Len())
+
+	b.Reset()
+	require.NoError(t, metrics.WriteProfile(&b, metrics.ProfileCPU, 10*time.Millisecond))
+	assert.NotZero(t, b.Len())
+
+	assert.Error(t, metrics.WriteProfile(&b, "nope", 0))
+}
+
+func TestCreateProfileFile(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "profiles")
+	t.Setenv(metrics.ProfileDirEnv, dir)
+
+	f, err := metrics.CreateProfileFile("heap")
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	assert.Equal(t, dir, filepath.Dir(f.Name()))
+	assert.True(t, strings.HasPrefix(filepath.Base(f.Name()), "dtls-heap-"))
+	assert.True(t, strings.HasSuffix(f.Name(), ".pprof"))
+}
@@ -0,0 +1,59 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"runtime/pprof"
+	"time"
+)
+
+// ProfileCPU samples CPU usage for a duration
+const ProfileCPU = "cpu"
+
+// ProfileDirEnv names the environment variable that selects where profile
+// files are written, in the manner of GOCOVERDIR. When it is set, the server
+// also writes a heap profile there as it exits, so benchmark harnesses can
+// collect profiles alongside their results.
+const ProfileDirEnv = "DTLS_PROFILE_DIR"
+
+// CreateProfileFile creates a new file for a profile of kind, in the
+// ProfileDirEnv directory if set, else the temporary directory
+func CreateProfileFile(kind string) (*os.File, error) {
+	dir := os.Getenv(ProfileDirEnv)
+	if dir != "" {
+		if err := os.MkdirAll(dir, 0o750); err != nil {
+			return nil, fmt.Errorf("failed to create profile directory: %w", err)
+		}
+	}
+	f, err := os.CreateTemp(dir, "dtls-"+kind+"-*.pprof")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create profile file: %w", err)
+	}
+	return f, nil
+}
+
+// HasProfile reports whether kind names a profile WriteProfile can write
+func HasProfile(kind string) bool {
+	return kind == ProfileCPU || pprof.Lookup(kind) != nil
+}
+
+// WriteProfile writes a pprof profile to w. CPU profiles sample for
+// duration, so WriteProfile blocks until it has elapsed; other kinds are
+// snapshots of a runtime profile such as "heap", "goroutine" or "mutex".
+func WriteProfile(w io.Writer, kind string, duration time.Duration) error {
+	if kind == ProfileCPU {
+		if err := pprof.StartCPUProfile(w); err != nil {
+			return fmt.Errorf("failed to start CPU profile: %w", err)
+		}
+		time.Sleep(duration)
+		pprof.StopCPUProfile()
+		return nil
+	}
+
+	profile := pprof.Lookup(kind)
+	if profile == nil {
+		return fmt.Errorf("unknown profile %q", kind)
+	}
+	return profile.WriteTo(w, 0)
+}
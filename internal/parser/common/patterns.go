@@ -27,5 +27,5 @@ var RootKeywordRegexp = regexp.MustCompile(`"?\$root"?\s*:`)
 // Anchored to line start to match only top-level $schema declarations
 // Captures the schema URL to avoid false positives from schema versions appearing elsewhere
 // JSON: "$schema": "https://..."
-// YAML: $schema: "https://..." or $schema: 'https://...'
-var SchemaFieldRegexp = regexp.MustCompile(`(?m)^\s*"?\$schema"?\s*:\s*["']([^"']+)["']`)
+// YAML: $schema: https://..., $schema: "https://..." or $schema: 'https://...'
+var SchemaFieldRegexp = regexp.MustCompile(`(?m)^\s*"?\$schema"?\s*:\s*["']?([^"'\s]+)["']?`)
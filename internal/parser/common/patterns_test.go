@@ -120,6 +120,12 @@ func TestSchemaFieldRegexp(t *testing.T) {
 		assert.Equal(t, "https://designtokens.org/schemas/draft.json", matches[1])
 	})
 
+	t.Run("matches unquoted YAML $schema", func(t *testing.T) {
+		matches := common.SchemaFieldRegexp.FindStringSubmatch("$schema: https://designtokens.org/schemas/2025.10.json\ncolor:")
+		assert.Len(t, matches, 2)
+		assert.Equal(t, "https://designtokens.org/schemas/2025.10.json", matches[1])
+	})
+
 	t.Run("matches with leading whitespace", func(t *testing.T) {
 		matches := common.SchemaFieldRegexp.FindStringSubmatch(`  "$schema": "https://example.com/schema.json"`)
 		assert.Len(t, matches, 2)
@@ -0,0 +1,45 @@
+package css
+
+import (
+	"regexp"
+
+	sitter "github.com/tree-sitter/go-tree-sitter"
+)
+
+// annotationPattern matches a token annotation comment, capturing the token name
+var annotationPattern = regexp.MustCompile(`^/\*\s*token:\s*([^\s*]+)\s*\*/$`)
+
+// handleAnnotation records a token annotation comment and the declaration
+// after it
+func (p *Parser) handleAnnotation(node *sitter.Node, sourceBytes []byte, source string, result *ParseResult) error {
+	start := node.StartByte()
+	match := annotationPattern.FindStringSubmatchIndex(source[start:node.EndByte()])
+	if match == nil {
+		return nil
+	}
+
+	nameRange, err := byteSpanRange(source, start+uint(match[2]), start+uint(match[3])) //nolint:gosec // G115: offsets are within the comment
+	if err != nil {
+		return err
+	}
+	annotation := &Annotation{
+		TokenName: source[start+uint(match[2]) : start+uint(match[3])], //nolint:gosec // G115: offsets are within the comment
+		Range:     nameRange,
+	}
+
+	// Comments between the annotation and its declaration are skipped
+	next := node.NextSibling()
+	for next != nil && next.Kind() == "comment" {
+		next = next.NextSibling()
+	}
+	if next != nil && next.Kind() == "declaration" {
+		declaration, err := createPositionRange(source, next)
+		if err != nil {
+			return err
+		}
+		annotation.Declaration = &declaration
+	}
+
+	result.Annotations = append(result.Annotations, annotation)
+	return nil
+}
@@ -0,0 +1,151 @@
+package css
+
+import (
+	"strings"
+
+	"bennypowers.dev/dtls/lsp/helpers"
+	sitter "github.com/tree-sitter/go-tree-sitter"
+)
+
+// handleContainerRule records the custom properties compared in a @container
+// rule's style() queries, e.g. --theme in style(--theme: dark), as
+// PropertyNameReference calls
+func (p *Parser) handleContainerRule(node *sitter.Node, sourceBytes []byte, source string, result *ParseResult) error {
+	var block *sitter.Node
+	for i := uint(0); i < node.ChildCount(); i++ {
+		if child := node.Child(i); child.Kind() == "block" {
+			block = child
+			break
+		}
+	}
+	if block == nil {
+		return nil
+	}
+
+	start := node.StartByte()
+	prelude := source[start:block.StartByte()]
+	selector := enclosingSelector(node, sourceBytes)
+	for _, span := range styleQueryNames(prelude) {
+		nameRange, err := byteSpanRange(source, start+uint(span[0]), start+uint(span[1])) //nolint:gosec // G115: offsets are within the prelude
+		if err != nil {
+			return err
+		}
+		result.VarCalls = append(result.VarCalls, &VarCall{
+			TokenName: prelude[span[0]:span[1]],
+			Type:      PropertyNameReference,
+			Range:     nameRange,
+			Selector:  selector,
+		})
+	}
+	return nil
+}
+
+// inContainerPrelude reports whether node is in a @container rule's prelude,
+// where the grammar fails on style() queries
+func inContainerPrelude(node *sitter.Node, source string) bool {
+	for parent := node.Parent(); parent != nil; parent = parent.Parent() {
+		if parent.Kind() == "block" {
+			return false
+		}
+		if parent.Kind() != "at_rule" {
+			continue
+		}
+		keyword := parent.Child(0)
+		return keyword != nil && keyword.Kind() == "at_keyword" &&
+			source[keyword.StartByte():keyword.EndByte()] == "@container"
+	}
+	return false
+}
+
+// styleQueryNames returns the byte spans of the custom property names in the
+// style() queries of a @container prelude. Names follow an opening
+// parenthesis, as in style(--a: 1) or style((--a: 1) and (--b)), but not a
+// function's, as in style(--a: var(--b)).
+func styleQueryNames(prelude string) [][2]int {
+	var spans [][2]int
+	for i := 0; i < len(prelude); {
+		found := strings.Index(prelude[i:], "style(")
+		if found < 0 {
+			break
+		}
+		start := i + found
+		open := start + len("style")
+		i = open + 1
+		if start > 0 && isIdentByte(prelude[start-1]) {
+			continue
+		}
+
+		depth := 0
+		for j := open; j < len(prelude); j++ {
+			switch prelude[j] {
+			case '(':
+				depth++
+				if j == open || !isIdentByte(prelude[j-1]) {
+					if span, ok := customPropertyAt(prelude, j+1); ok {
+						spans = append(spans, span)
+					}
+				}
+			case ')':
+				depth--
+			}
+			if depth == 0 {
+				i = j + 1
+				break
+			}
+		}
+	}
+	return spans
+}
+
+// customPropertyAt returns the span of the custom property name at offset,
+// after any whitespace
+func customPropertyAt(s string, offset int) ([2]int, bool) {
+	start := offset
+	for start < len(s) && strings.ContainsRune(" \t\r\n", rune(s[start])) {
+		start++
+	}
+	if !strings.HasPrefix(s[start:], "--") {
+		return [2]int{}, false
+	}
+	end := start + len("--")
+	for end < len(s) && isIdentByte(s[end]) {
+		end++
+	}
+	if end == start+len("--") {
+		return [2]int{}, false
+	}
+	return [2]int{start, end}, true
+}
+
+// isIdentByte reports whether b may appear in a CSS identifier. Bytes of
+// multi-byte runes are accepted, since non-ASCII runes are identifier runes.
+func isIdentByte(b byte) bool {
+	return b >= 'a' && b <= 'z' || b >= 'A' && b <= 'Z' || b >= '0' && b <= '9' ||
+		b == '-' || b == '_' || b >= 0x80
+}
+
+// byteSpanRange converts the byte offsets start and end in source to a Range
+func byteSpanRange(source string, start, end uint) (Range, error) {
+	startProto, err := helpers.OffsetToUTF16(source, bytePoint(source, start), start)
+	if err != nil {
+		return Range{}, err
+	}
+	endProto, err := helpers.OffsetToUTF16(source, bytePoint(source, end), end)
+	if err != nil {
+		return Range{}, err
+	}
+	return Range{
+		Start: Position{Line: startProto.Line, Character: startProto.Character},
+		End:   Position{Line: endProto.Line, Character: endProto.Character},
+	}, nil
+}
+
+// bytePoint returns the row and byte column of a byte offset in source
+func bytePoint(source string, offset uint) sitter.Point {
+	before := source[:offset]
+	lineStart := strings.LastIndexByte(before, '\n') + 1
+	return sitter.Point{
+		Row:    uint(strings.Count(before, "\n")),
+		Column: offset - uint(lineStart), //nolint:gosec // G115: lineStart is at most offset
+	}
+}
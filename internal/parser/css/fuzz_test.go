@@ -0,0 +1,57 @@
+package css_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"bennypowers.dev/dtls/internal/parser/css"
+)
+
+// FuzzParse checks that arbitrary (often half-typed) CSS never panics the parser
+// and that every reported range is well-formed.
+//
+// Seeds come from testdata/fuzz-seeds. Crashers found by `go test -fuzz` are
+// written to testdata/fuzz/FuzzParse and replayed by plain `go test` runs.
+func FuzzParse(f *testing.F) {
+	seeds, err := filepath.Glob(filepath.Join("testdata", "fuzz-seeds", "*.css"))
+	if err != nil {
+		f.Fatal(err)
+	}
+	for _, path := range seeds {
+		data, err := os.ReadFile(path) //nolint:gosec // G304: Test fixture path - test code only
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(string(data))
+	}
+	f.Add("")
+	f.Add("var(")
+	f.Add("a{b:var(--")
+	f.Add("--x:var(--y,var(--z,")
+
+	f.Fuzz(func(t *testing.T, source string) {
+		parser := css.AcquireParser()
+		defer css.ReleaseParser(parser)
+
+		result, err := parser.Parse(source)
+		if err != nil || result == nil {
+			return
+		}
+
+		for _, v := range result.Variables {
+			assertOrderedRange(t, v.Name, v.Range)
+		}
+		for _, c := range result.VarCalls {
+			assertOrderedRange(t, c.TokenName, c.Range)
+		}
+	})
+}
+
+func assertOrderedRange(t *testing.T, name string, r css.Range) {
+	t.Helper()
+	if r.Start.Line > r.End.Line ||
+		(r.Start.Line == r.End.Line && r.Start.Character > r.End.Character) {
+		t.Errorf("%s: range start %+v is after end %+v", name, r.Start, r.End)
+	}
+}
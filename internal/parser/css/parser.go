@@ -5,6 +5,7 @@ import (
 	"strings"
 	"sync"
 
+	"bennypowers.dev/dtls/internal/parser/queries"
 	"bennypowers.dev/dtls/lsp/helpers"
 	sitter "github.com/tree-sitter/go-tree-sitter"
 	tree_sitter_css "github.com/tree-sitter/tree-sitter-css/bindings/go"
@@ -12,31 +13,65 @@ import (
 
 // Parser handles parsing CSS with tree-sitter
 type Parser struct {
-	parser *sitter.Parser
+	// generation is the queries.Generation the parser compiled its queries in
+	generation       uint64
+	parser           *sitter.Parser
+	declarationQuery *sitter.Query
+	varCallQuery     *sitter.Query
+	containerQuery   *sitter.Query
+	annotationQuery  *sitter.Query
 }
 
-// parserPool is a pool of reusable CSS parsers for performance
-var parserPool = sync.Pool{
-	New: func() any {
-		parser := sitter.NewParser()
-		lang := sitter.NewLanguage(tree_sitter_css.Language())
-		_ = parser.SetLanguage(lang) // Error ignored - parser initialization is critical and will panic if it fails
-		return &Parser{parser: parser}
-	},
+var cssLang = sitter.NewLanguage(tree_sitter_css.Language())
+
+// parserPool is a pool of reusable CSS parsers for performance. It has no New
+// function, so that draining it (see ClosePool) never races with AcquireParser.
+var parserPool sync.Pool
+
+// newParser creates a parser with the current queries
+func newParser() *Parser {
+	// Read the generation first, so a parser compiled while the queries
+	// change is discarded rather than kept
+	generation := queries.Generation()
+	parser := sitter.NewParser()
+	_ = parser.SetLanguage(cssLang) // Error ignored - parser initialization is critical and will panic if it fails
+	return &Parser{
+		generation:       generation,
+		parser:           parser,
+		declarationQuery: queries.Compile(cssLang, "css", "declaration"),
+		varCallQuery:     queries.Compile(cssLang, "css", "var-call"),
+		containerQuery:   queries.Compile(cssLang, "css", "container"),
+		annotationQuery:  queries.Compile(cssLang, "css", "annotation"),
+	}
 }
 
-// AcquireParser gets a parser from the pool
+// AcquireParser gets a parser from the pool. Parsers compiled before the
+// queries were overridden (see queries.Generation) are closed, not reused.
 func AcquireParser() *Parser {
-	p := parserPool.Get().(*Parser)
-	p.parser.Reset() // Reset state for reuse
-	return p
+	for {
+		p, ok := parserPool.Get().(*Parser)
+		if !ok {
+			return newParser()
+		}
+		if p.generation == queries.Generation() {
+			p.parser.Reset() // Reset state for reuse
+			return p
+		}
+		p.Close()
+	}
 }
 
-// ReleaseParser returns a parser to the pool
+// ReleaseParser returns a parser to the pool, or closes it if the queries
+// were overridden since it was created
 func ReleaseParser(p *Parser) {
-	if p != nil {
-		parserPool.Put(p)
+	if p == nil {
+		return
+	}
+	if p.generation != queries.Generation() {
+		p.Close()
+		return
 	}
+	parserPool.Put(p)
 }
 
 // Close closes the parser and releases its resources
@@ -45,24 +80,28 @@ func (p *Parser) Close() {
 	if p.parser != nil {
 		p.parser.Close()
 	}
+	if p.declarationQuery != nil {
+		p.declarationQuery.Close()
+	}
+	if p.varCallQuery != nil {
+		p.varCallQuery.Close()
+	}
+	if p.containerQuery != nil {
+		p.containerQuery.Close()
+	}
+	if p.annotationQuery != nil {
+		p.annotationQuery.Close()
+	}
 }
 
-// ClosePool drains the parser pool and closes all cached parsers.
-// It temporarily nils the pool's New function to avoid allocating
-// fresh parsers while draining. Call on server shutdown.
+// ClosePool drains the parser pool and closes all cached parsers. Call on server shutdown.
 func ClosePool() {
-	oldNew := parserPool.New
-	parserPool.New = nil
-	defer func() { parserPool.New = oldNew }()
-
 	for {
-		v := parserPool.Get()
-		if v == nil {
-			break
-		}
-		if p, ok := v.(*Parser); ok {
-			p.Close()
+		p, ok := parserPool.Get().(*Parser)
+		if !ok {
+			return
 		}
+		p.Close()
 	}
 }
 
@@ -82,42 +121,100 @@ func (p *Parser) Parse(source string) (*ParseResult, error) {
 		VarCalls:  []*VarCall{},
 	}
 
-	// Walk the tree to find declarations and var() calls
+	// Query the tree for declarations and var() calls
 	// Note: tree-sitter positions from Parse() are byte-based, we'll convert them
-	if err := p.walkTree(root, sourceBytes, source, result); err != nil {
-		return nil, fmt.Errorf("failed to walk parse tree: %w", err)
+	if err := p.runQuery(p.declarationQuery, "declaration", root, sourceBytes, source, result, p.handleDeclaration); err != nil {
+		return nil, fmt.Errorf("failed to query declarations: %w", err)
+	}
+	if err := p.runQuery(p.varCallQuery, "call", root, sourceBytes, source, result, p.handleCallExpression); err != nil {
+		return nil, fmt.Errorf("failed to query var() calls: %w", err)
+	}
+	if err := p.runQuery(p.containerQuery, "rule", root, sourceBytes, source, result, p.handleContainerRule); err != nil {
+		return nil, fmt.Errorf("failed to query container rules: %w", err)
+	}
+	if err := p.runQuery(p.annotationQuery, "comment", root, sourceBytes, source, result, p.handleAnnotation); err != nil {
+		return nil, fmt.Errorf("failed to query token annotations: %w", err)
+	}
+	linkFallbackChains(result)
+	if root.HasError() {
+		collectUnsupported(root, source, result)
 	}
 
 	return result, nil
 }
 
-// walkTree recursively walks the tree to find CSS variables and var() calls
-func (p *Parser) walkTree(node *sitter.Node, sourceBytes []byte, source string, result *ParseResult) error {
-	if node == nil {
-		return nil
+// maxUnsupportedText caps the length of Unsupported.Text, in runes
+const maxUnsupportedText = 80
+
+// collectUnsupported records the error and missing nodes under node. Errors
+// starting on the line of the previous one extend it, since one unsupported
+// construct often produces several errors.
+func collectUnsupported(node *sitter.Node, source string, result *ParseResult) {
+	if !node.IsError() && !node.IsMissing() {
+		for i := uint(0); i < node.ChildCount(); i++ {
+			if child := node.Child(i); child.HasError() || child.IsMissing() {
+				collectUnsupported(child, source, result)
+			}
+		}
+		return
+	}
+	if inContainerPrelude(node, source) {
+		return // style() queries, which handleContainerRule reads
 	}
 
-	nodeKind := node.Kind()
+	posRange, err := createPositionRange(source, node)
+	if err != nil {
+		return
+	}
+	if n := len(result.Unsupported); n > 0 && result.Unsupported[n-1].Range.Start.Line == posRange.Start.Line {
+		result.Unsupported[n-1].Range.End = posRange.End
+		return
+	}
 
-	// Check for CSS custom property declaration
-	if nodeKind == "declaration" {
-		if err := p.handleDeclaration(node, sourceBytes, source, result); err != nil {
-			return err
-		}
+	start := strings.LastIndexByte(source[:node.StartByte()], '\n') + 1
+	line, _, _ := strings.Cut(source[start:], "\n")
+	text := []rune(strings.TrimSpace(line))
+	if len(text) > maxUnsupportedText {
+		text = append(text[:maxUnsupportedText-1], '…')
 	}
+	result.Unsupported = append(result.Unsupported, &Unsupported{Range: posRange, Text: string(text)})
+}
 
-	// Check for var() function call
-	if nodeKind == "call_expression" {
-		if err := p.handleCallExpression(node, sourceBytes, source, result); err != nil {
-			return err
+// linkFallbackChains links each var() call whose whole fallback is another
+// var() call to that nested call, then drops the bookkeeping maps
+func linkFallbackChains(result *ParseResult) {
+	for start, owner := range result.fallbackOwners {
+		if nested, ok := result.callsByStart[start]; ok {
+			owner.FallbackVar = nested
+			nested.Nested = true
 		}
 	}
+	result.fallbackOwners = nil
+	result.callsByStart = nil
+}
 
-	// Recursively walk children
-	for i := uint(0); i < node.ChildCount(); i++ {
-		child := node.Child(i)
-		if err := p.walkTree(child, sourceBytes, source, result); err != nil {
-			return err
+// runQuery passes each node captured as captureName by query to handle.
+func (p *Parser) runQuery(
+	query *sitter.Query,
+	captureName string,
+	root *sitter.Node,
+	sourceBytes []byte,
+	source string,
+	result *ParseResult,
+	handle func(*sitter.Node, []byte, string, *ParseResult) error,
+) error {
+	cursor := sitter.NewQueryCursor()
+	defer cursor.Close()
+
+	matches := cursor.Matches(query, root, sourceBytes)
+	for match := matches.Next(); match != nil; match = matches.Next() {
+		for _, capture := range match.Captures {
+			if query.CaptureNames()[capture.Index] != captureName {
+				continue
+			}
+			if err := handle(&capture.Node, sourceBytes, source, result); err != nil {
+				return err
+			}
 		}
 	}
 
@@ -129,6 +226,9 @@ func (p *Parser) handleDeclaration(node *sitter.Node, sourceBytes []byte, source
 	// Find property name node
 	var propertyNode *sitter.Node
 	var valueNodes []*sitter.Node
+	// firstValue and lastValue bound everything between ":" and ";"
+	var firstValue, lastValue *sitter.Node
+	afterColon := false
 
 	for i := uint(0); i < node.ChildCount(); i++ {
 		child := node.Child(i)
@@ -136,9 +236,20 @@ func (p *Parser) handleDeclaration(node *sitter.Node, sourceBytes []byte, source
 		switch kind {
 		case "property_name":
 			propertyNode = child
+		case ":":
+			afterColon = true
+			continue
+		case ";":
+			continue
 		case "plain_value", "integer_value", "float_value", "color_value":
 			valueNodes = append(valueNodes, child)
 		}
+		if afterColon && child.StartByte() < child.EndByte() {
+			if firstValue == nil {
+				firstValue = child
+			}
+			lastValue = child
+		}
 	}
 
 	if propertyNode == nil {
@@ -179,13 +290,19 @@ func (p *Parser) handleDeclaration(node *sitter.Node, sourceBytes []byte, source
 		Type:  VariableDeclaration,
 		Range: posRange,
 	}
+	if firstValue != nil {
+		if variable.ValueRange, err = createSpanRange(source, firstValue, lastValue); err != nil {
+			return err
+		}
+	}
 
 	result.Variables = append(result.Variables, variable)
 	return nil
 }
 
-// extractVarArguments extracts token name and optional fallback from var() arguments
-func extractVarArguments(argumentsNode *sitter.Node, sourceBytes []byte) (tokenName string, fallback *string) {
+// extractVarArguments extracts token name and optional fallback from var() arguments.
+// fallbackVar is the fallback node when the whole fallback is a single var() call.
+func extractVarArguments(argumentsNode *sitter.Node, sourceBytes []byte) (tokenName string, fallback *string, fallbackVar *sitter.Node) {
 	var firstValueNode *sitter.Node
 	var firstFallbackNode *sitter.Node
 	var lastFallbackNode *sitter.Node
@@ -235,18 +352,139 @@ func extractVarArguments(argumentsNode *sitter.Node, sourceBytes []byte) (tokenN
 		text := string(sourceBytes[firstFallbackNode.StartByte():lastFallbackNode.EndByte()])
 		fb := strings.TrimSpace(text)
 		fallback = &fb
+
+		if firstFallbackNode == lastFallbackNode && isVarCall(firstFallbackNode, sourceBytes) {
+			fallbackVar = firstFallbackNode
+		}
 	}
 
-	return tokenName, fallback
+	return tokenName, fallback, fallbackVar
+}
+
+// enclosingSelector returns the selector list of the nearest rule containing
+// node, resolved against the rules it is nested in
+func enclosingSelector(node *sitter.Node, sourceBytes []byte) string {
+	for parent := node.Parent(); parent != nil; parent = parent.Parent() {
+		if parent.Kind() == "rule_set" {
+			return ruleSelector(parent, sourceBytes)
+		}
+	}
+	return ""
+}
+
+// ruleSelector returns a rule's selector list. A nested rule's selector is
+// resolved against its parent's as CSS Nesting does, so &:hover nested in
+// .card gives ".card:hover" and > a gives ".card > a".
+func ruleSelector(rule *sitter.Node, sourceBytes []byte) string {
+	// The selector is everything before the block, which keeps the parts of
+	// a nested selector the grammar doesn't support, e.g. ".parent &"
+	var selector string
+	for i := uint(0); i < rule.ChildCount(); i++ {
+		if child := rule.Child(i); child.Kind() == "block" {
+			selector = strings.TrimSpace(string(sourceBytes[rule.StartByte():child.StartByte()]))
+			break
+		}
+	}
+
+	for ancestor := rule.Parent(); ancestor != nil; ancestor = ancestor.Parent() {
+		if ancestor.Kind() == "rule_set" {
+			return nestSelector(ruleSelector(ancestor, sourceBytes), selector)
+		}
+	}
+	return selector
+}
+
+// nestSelector resolves a nested selector list against its parent's
+func nestSelector(parent, nested string) string {
+	if parent == "" {
+		return nested
+	}
+	if len(splitSelectorList(parent)) > 1 {
+		parent = ":is(" + parent + ")"
+	}
+
+	parts := splitSelectorList(nested)
+	for i, part := range parts {
+		if strings.Contains(part, "&") {
+			parts[i] = strings.ReplaceAll(part, "&", parent)
+		} else {
+			parts[i] = parent + " " + part
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// splitSelectorList splits a selector list on its top-level commas, leaving
+// the commas in e.g. :is(a, b) alone
+func splitSelectorList(list string) []string {
+	var parts []string
+	depth, start := 0, 0
+	for i, r := range list {
+		switch r {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				parts = append(parts, strings.TrimSpace(list[start:i]))
+				start = i + 1
+			}
+		}
+	}
+	return append(parts, strings.TrimSpace(list[start:]))
+}
+
+// enclosingProperty returns the property name of the declaration containing
+// node, or of the @supports feature query, as in @supports (color: var(--a))
+func enclosingProperty(node *sitter.Node, sourceBytes []byte) string {
+	for parent := node.Parent(); parent != nil; parent = parent.Parent() {
+		var nameKind string
+		switch parent.Kind() {
+		case "declaration":
+			nameKind = "property_name"
+		case "feature_query":
+			nameKind = "feature_name"
+		default:
+			continue
+		}
+		for i := uint(0); i < parent.ChildCount(); i++ {
+			child := parent.Child(i)
+			if child.Kind() == nameKind {
+				return string(sourceBytes[child.StartByte():child.EndByte()])
+			}
+		}
+		return ""
+	}
+	return ""
+}
+
+// isVarCall reports whether node is a var() call expression
+func isVarCall(node *sitter.Node, sourceBytes []byte) bool {
+	if node.Kind() != "call_expression" {
+		return false
+	}
+	for i := uint(0); i < node.ChildCount(); i++ {
+		child := node.Child(i)
+		if child.Kind() == "function_name" {
+			return string(sourceBytes[child.StartByte():child.EndByte()]) == "var"
+		}
+	}
+	return false
 }
 
 // createPositionRange converts tree-sitter node positions to LSP Range with overflow checking
 func createPositionRange(source string, node *sitter.Node) (Range, error) {
-	startProto, err := helpers.PositionToUTF16(source, node.StartPosition())
+	return createSpanRange(source, node, node)
+}
+
+// createSpanRange creates a Range from the start of one node to the end of another
+func createSpanRange(source string, start, end *sitter.Node) (Range, error) {
+	startProto, err := helpers.OffsetToUTF16(source, start.StartPosition(), start.StartByte())
 	if err != nil {
 		return Range{}, fmt.Errorf("failed to convert start position: %w", err)
 	}
-	endProto, err := helpers.PositionToUTF16(source, node.EndPosition())
+	endProto, err := helpers.OffsetToUTF16(source, end.EndPosition(), end.EndByte())
 	if err != nil {
 		return Range{}, fmt.Errorf("failed to convert end position: %w", err)
 	}
@@ -274,17 +512,13 @@ func (p *Parser) handleCallExpression(node *sitter.Node, sourceBytes []byte, sou
 		}
 	}
 
-	// Validate function name is "var"
-	if functionNameNode == nil {
-		return nil
-	}
-	functionName := string(sourceBytes[functionNameNode.StartByte():functionNameNode.EndByte()])
-	if functionName != "var" || argumentsNode == nil {
+	// The var-call query decides which functions count as var() calls
+	if functionNameNode == nil || argumentsNode == nil {
 		return nil
 	}
 
 	// Extract arguments
-	tokenName, fallback := extractVarArguments(argumentsNode, sourceBytes)
+	tokenName, fallback, fallbackVar := extractVarArguments(argumentsNode, sourceBytes)
 	if tokenName == "" {
 		return nil
 	}
@@ -301,6 +535,18 @@ func (p *Parser) handleCallExpression(node *sitter.Node, sourceBytes []byte, sou
 		Fallback:  fallback,
 		Type:      VarReference,
 		Range:     posRange,
+		Selector:  enclosingSelector(node, sourceBytes),
+		Property:  enclosingProperty(node, sourceBytes),
+	}
+
+	// Record calls for linking fallback chains (see linkFallbackChains)
+	if result.callsByStart == nil {
+		result.callsByStart = make(map[uint]*VarCall)
+		result.fallbackOwners = make(map[uint]*VarCall)
+	}
+	result.callsByStart[node.StartByte()] = varCall
+	if fallbackVar != nil {
+		result.fallbackOwners[fallbackVar.StartByte()] = varCall
 	}
 
 	result.VarCalls = append(result.VarCalls, varCall)
@@ -1,9 +1,11 @@
 package css_test
 
 import (
+	"sync"
 	"testing"
 
 	"bennypowers.dev/dtls/internal/parser/css"
+	"bennypowers.dev/dtls/internal/parser/queries"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -140,6 +142,204 @@ func TestParseNestedVarCalls(t *testing.T) {
 	assert.True(t, found, "Should find var(--color-base)")
 }
 
+// TestParseFallbackChain tests that nested var() fallbacks are linked into a chain
+func TestParseFallbackChain(t *testing.T) {
+	cssCode := `.button {
+  padding: var(--a, var(--b, var(--c, 4px)));
+  margin: var(--d, var(--e) 2px);
+}`
+
+	parser := css.AcquireParser()
+	defer css.ReleaseParser(parser)
+	result, err := parser.Parse(cssCode)
+	require.NoError(t, err)
+	require.Len(t, result.VarCalls, 5)
+
+	head := result.VarCalls[0]
+	require.Equal(t, "--a", head.TokenName)
+	assert.False(t, head.Nested)
+
+	chain := head.Chain()
+	require.Len(t, chain, 3)
+	assert.Equal(t, "--b", chain[1].TokenName)
+	assert.Equal(t, "--c", chain[2].TokenName)
+	assert.True(t, chain[1].Nested)
+	assert.True(t, chain[2].Nested)
+	require.NotNil(t, chain[2].Fallback)
+	assert.Equal(t, "4px", *chain[2].Fallback)
+
+	// A fallback that merely contains var() alongside other values is not a chain
+	mixed := result.VarCalls[3]
+	require.Equal(t, "--d", mixed.TokenName)
+	assert.Nil(t, mixed.FallbackVar)
+	assert.False(t, result.VarCalls[4].Nested)
+}
+
+// TestParseVarCallSelector tests that var() calls record their enclosing rule's selector
+func TestParseVarCallSelector(t *testing.T) {
+	cssCode := `:host, .card { color: var(--a); }
+@media (min-width: 1px) { rh-button { padding: var(--b); } }`
+
+	parser := css.AcquireParser()
+	defer css.ReleaseParser(parser)
+	result, err := parser.Parse(cssCode)
+	require.NoError(t, err)
+	require.Len(t, result.VarCalls, 2)
+
+	assert.Equal(t, ":host, .card", result.VarCalls[0].Selector)
+	assert.Equal(t, "rh-button", result.VarCalls[1].Selector)
+}
+
+// TestParseNestedSelector tests that nested rules resolve against their parents
+func TestParseNestedSelector(t *testing.T) {
+	cssCode := `.card {
+  color: var(--a);
+  &:hover { color: var(--b); }
+  > a, .icon { color: var(--c); }
+  .dark & { color: var(--d); }
+  @media (min-width: 1px) { color: var(--e); }
+}
+:host, .card { & + & { color: var(--f); } }
+.a:has(> img) { color: var(--g); }
+@layer base { .b { color: var(--h); } }`
+
+	parser := css.AcquireParser()
+	defer css.ReleaseParser(parser)
+	result, err := parser.Parse(cssCode)
+	require.NoError(t, err)
+
+	selectors := map[string]string{}
+	for _, call := range result.VarCalls {
+		selectors[call.TokenName] = call.Selector
+	}
+	assert.Equal(t, map[string]string{
+		"--a": ".card",
+		"--b": ".card:hover",
+		"--c": ".card > a, .card .icon",
+		"--d": ".dark .card",
+		"--e": ".card",
+		"--f": ":is(:host, .card) + :is(:host, .card)",
+		"--g": ".a:has(> img)",
+		"--h": ".b",
+	}, selectors)
+}
+
+// TestParseConditionalRules tests var() calls in conditional group rule
+// preludes and custom properties compared in container style queries
+func TestParseConditionalRules(t *testing.T) {
+	cssCode := `@supports (color: var(--a)) {
+  .a { color: var(--b); }
+}
+@container card style(--c: dark) and (min-width: 1px) {
+  .d { color: red; }
+}
+.e {
+  @container style((--f: 1) and (--g)) { color: red; }
+}`
+
+	parser := css.AcquireParser()
+	defer css.ReleaseParser(parser)
+	result, err := parser.Parse(cssCode)
+	require.NoError(t, err)
+
+	type call struct {
+		Type     css.VariableType
+		Property string
+		Selector string
+		Range    css.Range
+	}
+	calls := map[string]call{}
+	for _, vc := range result.VarCalls {
+		calls[vc.TokenName] = call{vc.Type, vc.Property, vc.Selector, vc.Range}
+	}
+	span := func(line, start, end uint32) css.Range {
+		return css.Range{Start: css.Position{Line: line, Character: start}, End: css.Position{Line: line, Character: end}}
+	}
+	assert.Equal(t, map[string]call{
+		"--a": {css.VarReference, "color", "", span(0, 18, 26)},
+		"--b": {css.VarReference, "color", ".a", span(1, 14, 22)},
+		"--c": {css.PropertyNameReference, "", "", span(3, 22, 25)},
+		"--f": {css.PropertyNameReference, "", ".e", span(7, 20, 23)},
+		"--g": {css.PropertyNameReference, "", ".e", span(7, 33, 36)},
+	}, calls)
+}
+
+// TestParseUnsupported tests that syntax the grammar doesn't know is reported
+func TestParseUnsupported(t *testing.T) {
+	t.Run("supported syntax", func(t *testing.T) {
+		parser := css.AcquireParser()
+		defer css.ReleaseParser(parser)
+		result, err := parser.Parse(`.a { &:hover { color: var(--a); } } @layer base { .b { color: var(--b); } }
+@container card style(--theme: dark) { .c { color: var(--c); } }`)
+		require.NoError(t, err)
+		assert.Empty(t, result.Unsupported)
+	})
+
+	t.Run("unsupported syntax", func(t *testing.T) {
+		cssCode := `.a { color: var(--a); }
+@scope (.card) to (.content) { img { color: var(--b); } }
+@media (width > 40em) { .c { color: var(--c); } }`
+
+		parser := css.AcquireParser()
+		defer css.ReleaseParser(parser)
+		result, err := parser.Parse(cssCode)
+		require.NoError(t, err)
+		assert.Len(t, result.VarCalls, 3, "var() calls are still found")
+
+		require.Len(t, result.Unsupported, 2, "one entry per line")
+		assert.Equal(t, uint32(1), result.Unsupported[0].Range.Start.Line)
+		assert.Equal(t, "@scope (.card) to (.content) { img { color: var(--b); } }", result.Unsupported[0].Text)
+		assert.Equal(t, uint32(2), result.Unsupported[1].Range.Start.Line)
+		assert.Equal(t, "@media (width > 40em) { .c { color: var(--c); } }", result.Unsupported[1].Text)
+	})
+}
+
+func TestParseVariableValueRange(t *testing.T) {
+	cssCode := `:root { --border: 1px solid rgb(0 0 0) !important; --empty:; }`
+
+	parser := css.AcquireParser()
+	defer css.ReleaseParser(parser)
+	result, err := parser.Parse(cssCode)
+	require.NoError(t, err)
+	require.Len(t, result.Variables, 2)
+
+	assert.Equal(t, css.Range{
+		Start: css.Position{Line: 0, Character: 18},
+		End:   css.Position{Line: 0, Character: 49},
+	}, result.Variables[0].ValueRange, "value range spans every value node")
+	assert.Equal(t, css.Range{}, result.Variables[1].ValueRange)
+}
+
+func TestParseAnnotations(t *testing.T) {
+	cssCode := `.a {
+  /* token: color.primary */
+  /* generated */
+  color: var(--color-primary);
+  /* a plain comment */
+  /* token: space.small */
+}`
+
+	parser := css.AcquireParser()
+	defer css.ReleaseParser(parser)
+	result, err := parser.Parse(cssCode)
+	require.NoError(t, err)
+	require.Len(t, result.Annotations, 2, "plain comments are not annotations")
+
+	annotation := result.Annotations[0]
+	assert.Equal(t, "color.primary", annotation.TokenName)
+	assert.Equal(t, css.Range{
+		Start: css.Position{Line: 1, Character: 12},
+		End:   css.Position{Line: 1, Character: 25},
+	}, annotation.Range)
+	assert.Equal(t, &css.Range{
+		Start: css.Position{Line: 3, Character: 2},
+		End:   css.Position{Line: 3, Character: 30},
+	}, annotation.Declaration, "comments before the declaration are skipped")
+
+	assert.Equal(t, "space.small", result.Annotations[1].TokenName)
+	assert.Nil(t, result.Annotations[1].Declaration)
+}
+
 // TestParseMixedContent tests parsing CSS with both declarations and var() calls
 func TestParseMixedContent(t *testing.T) {
 	cssCode := `:root {
@@ -285,3 +485,65 @@ func TestParseVarFunctionWithComplexFontFallback(t *testing.T) {
 		})
 	}
 }
+
+// TestParseWithQueryOverride tests that a user query override changes which
+// functions are treated as var() calls
+func TestParseWithQueryOverride(t *testing.T) {
+	cssCode := `.a { color: token(--color-primary, red); padding: var(--space); }`
+
+	parse := func() []string {
+		parser := css.AcquireParser()
+		defer css.ReleaseParser(parser)
+		result, err := parser.Parse(cssCode)
+		require.NoError(t, err)
+
+		var names []string
+		for _, call := range result.VarCalls {
+			names = append(names, call.TokenName)
+		}
+		return names
+	}
+
+	t.Cleanup(func() { queries.SetOverrideDir("") })
+
+	assert.Equal(t, []string{"--space"}, parse())
+
+	// A parser in use while the queries change keeps working, and pooled
+	// parsers are replaced without draining the pool
+	inUse := css.AcquireParser()
+	queries.SetOverrideDir("testdata/queries")
+	_, err := inUse.Parse(cssCode)
+	require.NoError(t, err)
+	css.ReleaseParser(inUse)
+
+	assert.Equal(t, []string{"--color-primary", "--space"}, parse())
+}
+
+// TestAcquireParser_ConcurrentWithQueryChanges tests that parsing while the
+// queries change and the pool is drained neither panics nor races
+func TestAcquireParser_ConcurrentWithQueryChanges(t *testing.T) {
+	t.Cleanup(func() { queries.SetOverrideDir("") })
+
+	var wg sync.WaitGroup
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 50 {
+				parser := css.AcquireParser()
+				_, err := parser.Parse(`.a { color: var(--color-primary); }`)
+				assert.NoError(t, err)
+				css.ReleaseParser(parser)
+			}
+		}()
+	}
+	for i := range 20 {
+		if i%2 == 0 {
+			queries.SetOverrideDir("testdata/queries")
+		} else {
+			queries.SetOverrideDir("")
+		}
+		css.ClosePool()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,4 @@
+(call_expression
+  (function_name) @function
+  (arguments)
+  (#match? @function "^(var|token)$")) @call
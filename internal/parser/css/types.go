@@ -8,6 +8,11 @@ const (
 	VariableDeclaration VariableType = iota
 	// VarReference represents a var() function call
 	VarReference
+	// PropertyNameReference represents a custom property named outside a var()
+	// call: in a JS/TS string, e.g. getPropertyValue('--var-name'), or in a
+	// container style query, e.g. @container style(--var-name: value).
+	// Its range covers the name.
+	PropertyNameReference
 )
 
 // Position represents a position in a text document
@@ -28,6 +33,10 @@ type Variable struct {
 	Value string
 	Type  VariableType
 	Range Range
+
+	// ValueRange covers the declared value, from after the colon to before
+	// the semicolon. It is empty when the declaration has no value.
+	ValueRange Range
 }
 
 // VarCall represents a var() function call
@@ -36,10 +45,75 @@ type VarCall struct {
 	TokenName string
 	Type      VariableType
 	Range     Range
+
+	// FallbackVar is the nested call when the whole fallback is another var() call,
+	// as in var(--a, var(--b, 4px)). Nested calls also appear in ParseResult.VarCalls.
+	FallbackVar *VarCall
+
+	// Nested reports whether this call is the FallbackVar of an enclosing call
+	Nested bool
+
+	// Selector is the selector list of the rule containing the call
+	// (e.g. ":host, .card"), or empty outside of a rule
+	Selector string
+
+	// Property is the property of the declaration containing the call
+	// (e.g. "color"), or empty outside of a declaration
+	Property string
+
+	// Alias is the SCSS variable (e.g. "$primary") through which the token
+	// is used. Range then covers the variable rather than a var() call.
+	Alias string
+}
+
+// Chain returns the call followed by each nested fallback call,
+// e.g. [--a, --b] for var(--a, var(--b, 4px))
+func (vc *VarCall) Chain() []*VarCall {
+	var chain []*VarCall
+	for link := vc; link != nil; link = link.FallbackVar {
+		chain = append(chain, link)
+	}
+	return chain
+}
+
+// Unsupported is source the parser could not analyze, usually syntax newer
+// than its grammar. var() calls inside it may still be found, but the
+// surrounding rule's selector or property may be wrong.
+type Unsupported struct {
+	Range Range
+
+	// Text is the trimmed source line where the unparsed source starts
+	Text string
+}
+
+// Annotation is a token annotation comment, e.g. /* token: color.primary */,
+// which some build pipelines write before the declaration using the token
+type Annotation struct {
+	// TokenName is the annotated token as written, e.g. "color.primary"
+	TokenName string
+
+	// Range covers the token name in the comment
+	Range Range
+
+	// Declaration covers the declaration after the comment in the same
+	// block, or is nil when none follows
+	Declaration *Range
 }
 
 // ParseResult contains the results of parsing CSS
 type ParseResult struct {
 	Variables []*Variable
 	VarCalls  []*VarCall
+
+	// Unsupported lists the source the parser could not analyze, at most
+	// one entry per line
+	Unsupported []*Unsupported
+
+	Annotations []*Annotation
+
+	// fallbackOwners maps the start byte of a var() call that forms an entire
+	// fallback to the enclosing call; callsByStart indexes calls by start byte.
+	// Both are used to link fallback chains once parsing finishes.
+	fallbackOwners map[uint]*VarCall
+	callsByStart   map[uint]*VarCall
 }
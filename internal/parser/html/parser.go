@@ -6,12 +6,15 @@ import (
 
 	"bennypowers.dev/dtls/internal/log"
 	"bennypowers.dev/dtls/internal/parser/css"
+	"bennypowers.dev/dtls/internal/parser/queries"
 	sitter "github.com/tree-sitter/go-tree-sitter"
 	tree_sitter_html "github.com/tree-sitter/tree-sitter-html/bindings/go"
 )
 
 // Parser handles parsing HTML to extract CSS regions
 type Parser struct {
+	// generation is the queries.Generation the parser compiled its queries in
+	generation uint64
 	parser     *sitter.Parser
 	styleQuery *sitter.Query
 	attrQuery  *sitter.Query
@@ -19,49 +22,55 @@ type Parser struct {
 
 var htmlLang = sitter.NewLanguage(tree_sitter_html.Language())
 
-// parserPool is a pool of reusable HTML parsers
-var parserPool = sync.Pool{
-	New: func() any {
-		parser := sitter.NewParser()
-		if err := parser.SetLanguage(htmlLang); err != nil {
-			panic(fmt.Sprintf("failed to set HTML language: %v", err))
-		}
-
-		styleQuery, qerr := sitter.NewQuery(htmlLang, `(style_element (raw_text) @css)`)
-		if qerr != nil {
-			panic(fmt.Sprintf("failed to compile style query: %v", qerr))
-		}
-
-		attrQuery, qerr := sitter.NewQuery(htmlLang, `
-			(attribute
-				(attribute_name) @attr_name
-				(quoted_attribute_value (attribute_value) @attr_value)
-				(#eq? @attr_name "style"))
-		`)
-		if qerr != nil {
-			panic(fmt.Sprintf("failed to compile attribute query: %v", qerr))
-		}
+// parserPool is a pool of reusable HTML parsers. It has no New
+// function, so that draining it (see ClosePool) never races with AcquireParser.
+var parserPool sync.Pool
+
+// newParser creates a parser with the current queries
+func newParser() *Parser {
+	// Read the generation first, so a parser compiled while the queries
+	// change is discarded rather than kept
+	generation := queries.Generation()
+	parser := sitter.NewParser()
+	if err := parser.SetLanguage(htmlLang); err != nil {
+		panic(fmt.Sprintf("failed to set HTML language: %v", err))
+	}
 
-		return &Parser{
-			parser:     parser,
-			styleQuery: styleQuery,
-			attrQuery:  attrQuery,
-		}
-	},
+	return &Parser{
+		generation: generation,
+		parser:     parser,
+		styleQuery: queries.Compile(htmlLang, "html", "style"),
+		attrQuery:  queries.Compile(htmlLang, "html", "style-attribute"),
+	}
 }
 
-// AcquireParser gets a parser from the pool
+// AcquireParser gets a parser from the pool. Parsers compiled before the
+// queries were overridden (see queries.Generation) are closed, not reused.
 func AcquireParser() *Parser {
-	p := parserPool.Get().(*Parser)
-	p.parser.Reset()
-	return p
+	for {
+		p, ok := parserPool.Get().(*Parser)
+		if !ok {
+			return newParser()
+		}
+		if p.generation == queries.Generation() {
+			p.parser.Reset() // Reset state for reuse
+			return p
+		}
+		p.Close()
+	}
 }
 
-// ReleaseParser returns a parser to the pool
+// ReleaseParser returns a parser to the pool, or closes it if the queries
+// were overridden since it was created
 func ReleaseParser(p *Parser) {
-	if p != nil {
-		parserPool.Put(p)
+	if p == nil {
+		return
 	}
+	if p.generation != queries.Generation() {
+		p.Close()
+		return
+	}
+	parserPool.Put(p)
 }
 
 // Close closes the parser and releases its resources
@@ -78,21 +87,13 @@ func (p *Parser) Close() {
 }
 
 // ClosePool drains the parser pool and closes all cached parsers.
-// It temporarily nils the pool's New function to avoid allocating
-// fresh parsers while draining.
 func ClosePool() {
-	oldNew := parserPool.New
-	parserPool.New = nil
-	defer func() { parserPool.New = oldNew }()
-
 	for {
-		v := parserPool.Get()
-		if v == nil {
-			break
-		}
-		if p, ok := v.(*Parser); ok {
-			p.Close()
+		p, ok := parserPool.Get().(*Parser)
+		if !ok {
+			return
 		}
+		p.Close()
 	}
 }
 
@@ -180,6 +181,8 @@ func (p *Parser) ParseCSS(source string) (*css.ParseResult, error) {
 			offsetStyleTagResults(parsed, region)
 			result.Variables = append(result.Variables, parsed.Variables...)
 			result.VarCalls = append(result.VarCalls, parsed.VarCalls...)
+			result.Unsupported = append(result.Unsupported, parsed.Unsupported...)
+			result.Annotations = append(result.Annotations, parsed.Annotations...)
 
 		case StyleAttribute:
 			parsed, err := parseStyleAttribute(cssParser, region)
@@ -199,10 +202,21 @@ func (p *Parser) ParseCSS(source string) (*css.ParseResult, error) {
 func offsetStyleTagResults(parsed *css.ParseResult, region CSSRegion) {
 	for _, v := range parsed.Variables {
 		v.Range = offsetRange(v.Range, region)
+		v.ValueRange = offsetRange(v.ValueRange, region)
 	}
 	for _, vc := range parsed.VarCalls {
 		vc.Range = offsetRange(vc.Range, region)
 	}
+	for _, u := range parsed.Unsupported {
+		u.Range = offsetRange(u.Range, region)
+	}
+	for _, a := range parsed.Annotations {
+		a.Range = offsetRange(a.Range, region)
+		if a.Declaration != nil {
+			declaration := offsetRange(*a.Declaration, region)
+			a.Declaration = &declaration
+		}
+	}
 }
 
 // offsetRange adjusts a CSS range to account for the region's position in the HTML document
@@ -236,9 +250,11 @@ func parseStyleAttribute(cssParser *css.Parser, region CSSRegion) (*css.ParseRes
 	// Adjust positions: subtract the "x{" prefix (2 chars), add attribute position
 	for _, v := range parsed.Variables {
 		v.Range = adjustAttributeRange(v.Range, region)
+		v.ValueRange = adjustAttributeRange(v.ValueRange, region)
 	}
 	for _, vc := range parsed.VarCalls {
 		vc.Range = adjustAttributeRange(vc.Range, region)
+		vc.Selector = "" // The "x" wrapper rule is not a real selector
 	}
 
 	return parsed, nil
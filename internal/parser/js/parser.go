@@ -2,75 +2,91 @@ package js
 
 import (
 	"fmt"
+	"strings"
 	"sync"
 
 	"bennypowers.dev/dtls/internal/log"
 	"bennypowers.dev/dtls/internal/parser/css"
 	htmlparser "bennypowers.dev/dtls/internal/parser/html"
+	"bennypowers.dev/dtls/internal/parser/queries"
 	sitter "github.com/tree-sitter/go-tree-sitter"
 	tree_sitter_javascript "github.com/tree-sitter/tree-sitter-javascript/bindings/go"
 )
 
 // Parser handles parsing JS/TS to extract CSS from tagged template literals
 type Parser struct {
+	// generation is the queries.Generation the parser compiled its queries in
+	generation    uint64
 	parser        *sitter.Parser
 	templateQuery *sitter.Query
 	genericQuery  *sitter.Query // matches css<Type>`...` (generic form parsed by JS grammar as binary_expression)
+	styleQuery    *sitter.Query // matches style object values and style.setProperty() arguments
 }
 
 var jsLang = sitter.NewLanguage(tree_sitter_javascript.Language())
 
-// parserPool is a pool of reusable JS parsers
-var parserPool = sync.Pool{
-	New: func() any {
-		parser := sitter.NewParser()
-		if err := parser.SetLanguage(jsLang); err != nil {
-			panic(fmt.Sprintf("failed to set JS language: %v", err))
-		}
-
-		templateQuery, qerr := sitter.NewQuery(jsLang, `
-			(call_expression
-				function: (identifier) @tag
-				arguments: (template_string) @template)
-		`)
-		if qerr != nil {
-			panic(fmt.Sprintf("failed to compile template query: %v", qerr))
-		}
+// templateTags maps the tags of template literals holding styles or markup to
+// the TemplateRegion tag of their content. The styled-components and emotion
+// tags hold declarations, nested rules or keyframes, which parse as CSS.
+var templateTags = map[string]string{
+	"css":               "css",
+	"html":              "html",
+	"styled":            "css",
+	"createGlobalStyle": "css",
+	"injectGlobal":      "css",
+	"keyframes":         "css",
+}
 
-		// Generic form: css<Type>`...` is valid TypeScript (since TS 2.9) but both
-		// tree-sitter-javascript and tree-sitter-typescript misparse it as binary
-		// expressions instead of a call_expression with type_arguments.
-		// See: https://github.com/tree-sitter/tree-sitter-typescript/issues/341
-		genericQuery, qerr := sitter.NewQuery(jsLang, `
-			(binary_expression
-				left: (binary_expression
-					left: (identifier) @tag)
-				right: (template_string) @template)
-		`)
-		if qerr != nil {
-			panic(fmt.Sprintf("failed to compile generic query: %v", qerr))
-		}
+// parserPool is a pool of reusable JS parsers. It has no New
+// function, so that draining it (see ClosePool) never races with AcquireParser.
+var parserPool sync.Pool
+
+// newParser creates a parser with the current queries
+func newParser() *Parser {
+	// Read the generation first, so a parser compiled while the queries
+	// change is discarded rather than kept
+	generation := queries.Generation()
+	parser := sitter.NewParser()
+	if err := parser.SetLanguage(jsLang); err != nil {
+		panic(fmt.Sprintf("failed to set JS language: %v", err))
+	}
 
-		return &Parser{
-			parser:        parser,
-			templateQuery: templateQuery,
-			genericQuery:  genericQuery,
-		}
-	},
+	return &Parser{
+		generation:    generation,
+		parser:        parser,
+		templateQuery: queries.Compile(jsLang, "js", "template"),
+		genericQuery:  queries.Compile(jsLang, "js", "generic-template"),
+		styleQuery:    queries.Compile(jsLang, "js", "style-string"),
+	}
 }
 
-// AcquireParser gets a parser from the pool
+// AcquireParser gets a parser from the pool. Parsers compiled before the
+// queries were overridden (see queries.Generation) are closed, not reused.
 func AcquireParser() *Parser {
-	p := parserPool.Get().(*Parser)
-	p.parser.Reset()
-	return p
+	for {
+		p, ok := parserPool.Get().(*Parser)
+		if !ok {
+			return newParser()
+		}
+		if p.generation == queries.Generation() {
+			p.parser.Reset() // Reset state for reuse
+			return p
+		}
+		p.Close()
+	}
 }
 
-// ReleaseParser returns a parser to the pool
+// ReleaseParser returns a parser to the pool, or closes it if the queries
+// were overridden since it was created
 func ReleaseParser(p *Parser) {
-	if p != nil {
-		parserPool.Put(p)
+	if p == nil {
+		return
+	}
+	if p.generation != queries.Generation() {
+		p.Close()
+		return
 	}
+	parserPool.Put(p)
 }
 
 // Close closes the parser and releases its resources
@@ -84,29 +100,25 @@ func (p *Parser) Close() {
 	if p.genericQuery != nil {
 		p.genericQuery.Close()
 	}
+	if p.styleQuery != nil {
+		p.styleQuery.Close()
+	}
 }
 
 // ClosePool drains the parser pool and closes all cached parsers.
-// It temporarily nils the pool's New function to avoid allocating
-// fresh parsers while draining.
 func ClosePool() {
-	oldNew := parserPool.New
-	parserPool.New = nil
-	defer func() { parserPool.New = oldNew }()
-
 	for {
-		v := parserPool.Get()
-		if v == nil {
-			break
-		}
-		if p, ok := v.(*Parser); ok {
-			p.Close()
+		p, ok := parserPool.Get().(*Parser)
+		if !ok {
+			return
 		}
+		p.Close()
 	}
 }
 
 // ParseTemplates finds css/html tagged template literals and splits them at ${...} boundaries.
-// Handles both standard form (css`...`) and generic form (css<Type>`...`).
+// Handles both standard form (css`...`) and generic form (css<Type>`...`), and
+// the styled-components and emotion tags, e.g. styled.div`...` and keyframes`...`.
 func (p *Parser) ParseTemplates(source string) []TemplateRegion {
 	sourceBytes := []byte(source)
 	tree := p.parser.Parse(sourceBytes, nil)
@@ -115,7 +127,11 @@ func (p *Parser) ParseTemplates(source string) []TemplateRegion {
 	}
 	defer tree.Close()
 
-	root := tree.RootNode()
+	return p.templates(tree.RootNode(), sourceBytes)
+}
+
+// templates finds the tagged template regions in a parsed tree
+func (p *Parser) templates(root *sitter.Node, sourceBytes []byte) []TemplateRegion {
 	var regions []TemplateRegion
 
 	// Run both queries: standard tagged templates and generic form
@@ -149,7 +165,8 @@ func (p *Parser) runTemplateQuery(query *sitter.Query, root *sitter.Node, source
 			}
 		}
 
-		if tagName != "css" && tagName != "html" {
+		tag, ok := templateTags[tagName]
+		if !ok {
 			continue
 		}
 
@@ -161,7 +178,7 @@ func (p *Parser) runTemplateQuery(query *sitter.Query, root *sitter.Node, source
 		if len(segments) > 0 {
 			regions = append(regions, TemplateRegion{
 				Segments: segments,
-				Tag:      tagName,
+				Tag:      tag,
 			})
 		}
 	}
@@ -179,7 +196,7 @@ func extractSegments(templateNode *sitter.Node, sourceBytes []byte) []Segment {
 		if child.Kind() == "string_fragment" {
 			content := string(sourceBytes[child.StartByte():child.EndByte()])
 			segments = append(segments, Segment{
-				Content:  content,
+				Content:   content,
 				StartLine: child.StartPosition().Row,
 				StartCol:  child.StartPosition().Column,
 			})
@@ -189,22 +206,74 @@ func extractSegments(templateNode *sitter.Node, sourceBytes []byte) []Segment {
 	return segments
 }
 
-// ParseCSS extracts and parses CSS from tagged template literals in JS/TS source
-func (p *Parser) ParseCSS(source string) (*css.ParseResult, error) {
-	templates := p.ParseTemplates(source)
-	if len(templates) == 0 {
-		return &css.ParseResult{
-			Variables: []*css.Variable{},
-			VarCalls:  []*css.VarCall{},
-		}, nil
+// ParseStyleStrings finds string literals holding CSS used from script:
+// the values of JSX style objects, the arguments of style.setProperty() calls,
+// and the custom property names given to getPropertyValue() and CSS.registerProperty()
+func (p *Parser) ParseStyleStrings(source string) []StyleString {
+	sourceBytes := []byte(source)
+	tree := p.parser.Parse(sourceBytes, nil)
+	if tree == nil {
+		return nil
+	}
+	defer tree.Close()
+
+	return p.styleStrings(tree.RootNode(), sourceBytes)
+}
+
+// styleStrings finds the style strings in a parsed tree
+func (p *Parser) styleStrings(root *sitter.Node, sourceBytes []byte) []StyleString {
+	cursor := sitter.NewQueryCursor()
+	defer cursor.Close()
+
+	var styleStrings []StyleString
+	matches := cursor.Matches(p.styleQuery, root, sourceBytes)
+	for match := matches.Next(); match != nil; match = matches.Next() {
+		for _, capture := range match.Captures {
+			var kind StyleStringKind
+			switch p.styleQuery.CaptureNames()[capture.Index] {
+			case "value":
+				kind = StyleValue
+			case "property":
+				kind = StyleProperty
+			default:
+				continue
+			}
+
+			// Content lies between the quotes
+			node := capture.Node
+			start, end := node.StartByte()+1, node.EndByte()-1
+			if end < start {
+				continue
+			}
+			styleStrings = append(styleStrings, StyleString{
+				Content:   string(sourceBytes[start:end]),
+				StartLine: node.StartPosition().Row,
+				StartCol:  node.StartPosition().Column + 1,
+				Kind:      kind,
+			})
+		}
 	}
 
+	return styleStrings
+}
+
+// ParseCSS extracts and parses CSS from tagged template literals and style
+// strings in JS/TS source
+func (p *Parser) ParseCSS(source string) (*css.ParseResult, error) {
 	result := &css.ParseResult{
 		Variables: []*css.Variable{},
 		VarCalls:  []*css.VarCall{},
 	}
 
-	for _, tmpl := range templates {
+	sourceBytes := []byte(source)
+	tree := p.parser.Parse(sourceBytes, nil)
+	if tree == nil {
+		return result, nil
+	}
+	defer tree.Close()
+	root := tree.RootNode()
+
+	for _, tmpl := range p.templates(root, sourceBytes) {
 		switch tmpl.Tag {
 		case "css":
 			parseCSSSegments(tmpl.Segments, result)
@@ -213,26 +282,109 @@ func (p *Parser) ParseCSS(source string) (*css.ParseResult, error) {
 		}
 	}
 
+	parseStyleStrings(p.styleStrings(root, sourceBytes), result)
+
 	return result, nil
 }
 
-// parseCSSSegments parses each segment of a css tagged template as CSS
-func parseCSSSegments(segments []Segment, result *css.ParseResult) {
+// styleValuePrefix wraps a style value in a rule so it parses as a declaration
+const styleValuePrefix = "x{p:"
+
+// parseStyleStrings parses the CSS values among style strings, and records
+// the custom property names as references
+func parseStyleStrings(styleStrings []StyleString, result *css.ParseResult) {
 	cssParser := css.AcquireParser()
 	defer css.ReleaseParser(cssParser)
 
-	for _, seg := range segments {
-		parsed, err := cssParser.Parse(seg.Content)
+	for _, str := range styleStrings {
+		seg := Segment{StartLine: str.StartLine, StartCol: str.StartCol}
+
+		if str.Kind == StyleProperty {
+			if !strings.HasPrefix(str.Content, "--") || strings.ContainsAny(str.Content, " \t\\") {
+				continue
+			}
+			result.VarCalls = append(result.VarCalls, &css.VarCall{
+				TokenName: str.Content,
+				Type:      css.PropertyNameReference,
+				Range: offsetSegmentRange(css.Range{
+					End: css.Position{Character: uint32(len(str.Content))}, //nolint:gosec // G115: string length is bounded by file size
+				}, seg),
+			})
+			continue
+		}
+
+		parsed, err := cssParser.Parse(styleValuePrefix + str.Content + "}")
 		if err != nil {
-			log.Debug("Failed to parse CSS segment at %d:%d: %v", seg.StartLine, seg.StartCol, err)
+			log.Debug("Failed to parse style string at %d:%d: %v", str.StartLine, str.StartCol, err)
 			continue
 		}
-		offsetSegmentResults(parsed, seg)
-		result.Variables = append(result.Variables, parsed.Variables...)
+		for _, vc := range parsed.VarCalls {
+			// Values are single-line, so only the start of line 0 holds the wrapper
+			vc.Range.Start.Character -= uint32(len(styleValuePrefix))
+			vc.Range.End.Character -= uint32(len(styleValuePrefix))
+			vc.Range = offsetSegmentRange(vc.Range, seg)
+			vc.Selector = "" // The "x" wrapper rule is not a real selector
+			vc.Property = "" // Nor is its "p" declaration a real property
+		}
 		result.VarCalls = append(result.VarCalls, parsed.VarCalls...)
 	}
 }
 
+// parseCSSSegments parses the segments of a css tagged template as CSS.
+// The segments are parsed together, so a declaration following a
+// substitution, as in `color: ${c}; padding: var(--x);`, is not lost to
+// error recovery.
+func parseCSSSegments(segments []Segment, result *css.ParseResult) {
+	if len(segments) == 0 {
+		return
+	}
+
+	cssParser := css.AcquireParser()
+	defer css.ReleaseParser(cssParser)
+
+	seg := joinSegments(segments)
+	parsed, err := cssParser.Parse(seg.Content)
+	if err != nil {
+		log.Debug("Failed to parse CSS segment at %d:%d: %v", seg.StartLine, seg.StartCol, err)
+		return
+	}
+	offsetSegmentResults(parsed, seg)
+	result.Variables = append(result.Variables, parsed.Variables...)
+	result.VarCalls = append(result.VarCalls, parsed.VarCalls...)
+}
+
+// joinSegments joins the segments of a template into one, with the
+// substitutions between them blanked out, so that positions in the joined
+// content map to the source as they do in each segment
+func joinSegments(segments []Segment) Segment {
+	joined := segments[0]
+	var b strings.Builder
+	b.WriteString(joined.Content)
+	line, col := segmentEnd(joined)
+	for _, seg := range segments[1:] {
+		if seg.StartLine > line {
+			b.WriteString(strings.Repeat("\n", int(seg.StartLine-line))) //nolint:gosec // G115: segment positions from tree-sitter are bounded by file size
+			col = 0
+		}
+		if seg.StartCol > col {
+			b.WriteString(strings.Repeat(" ", int(seg.StartCol-col))) //nolint:gosec // G115: segment positions from tree-sitter are bounded by file size
+		}
+		b.WriteString(seg.Content)
+		line, col = segmentEnd(seg)
+	}
+	joined.Content = b.String()
+	return joined
+}
+
+// segmentEnd returns the line and column in the source where a segment ends
+func segmentEnd(seg Segment) (line, col uint) {
+	last := strings.LastIndexByte(seg.Content, '\n')
+	if last < 0 {
+		return seg.StartLine, seg.StartCol + uint(len(seg.Content))
+	}
+	return seg.StartLine + uint(strings.Count(seg.Content, "\n")), uint(len(seg.Content) - last - 1) //nolint:gosec // G115: content length is bounded by file size
+}
+
 // parseHTMLSegments parses each segment of an html tagged template as HTML, then extracts CSS
 func parseHTMLSegments(segments []Segment, result *css.ParseResult) {
 	htmlParser := htmlparser.AcquireParser()
@@ -255,6 +407,7 @@ func parseHTMLSegments(segments []Segment, result *css.ParseResult) {
 func offsetSegmentResults(parsed *css.ParseResult, seg Segment) {
 	for _, v := range parsed.Variables {
 		v.Range = offsetSegmentRange(v.Range, seg)
+		v.ValueRange = offsetSegmentRange(v.ValueRange, seg)
 	}
 	for _, vc := range parsed.VarCalls {
 		vc.Range = offsetSegmentRange(vc.Range, seg)
@@ -63,6 +63,12 @@ func TestParseTemplates(t *testing.T) {
 			wantCSS:  1,
 			wantHTML: 0,
 		},
+		{
+			name:     "styled-components",
+			fixture:  "testdata/styled-components.tsx",
+			wantCSS:  7,
+			wantHTML: 0,
+		},
 	}
 
 	for _, tt := range tests {
@@ -144,6 +150,21 @@ func TestParseCSS(t *testing.T) {
 			fixture: "testdata/tsx-component.tsx",
 			golden:  "testdata/golden/tsx-component.json",
 		},
+		{
+			name:    "style strings",
+			fixture: "testdata/style-strings.jsx",
+			golden:  "testdata/golden/style-strings.json",
+		},
+		{
+			name:    "property names",
+			fixture: "testdata/property-names.ts",
+			golden:  "testdata/golden/property-names.json",
+		},
+		{
+			name:    "styled-components",
+			fixture: "testdata/styled-components.tsx",
+			golden:  "testdata/golden/styled-components.json",
+		},
 	}
 
 	for _, tt := range tests {
@@ -333,3 +354,29 @@ func TestCSSTemplatePositionMapping(t *testing.T) {
 	assert.Equal(t, uint32(8), vc.Range.Start.Line, "var call line")
 	assert.Equal(t, uint32(11), vc.Range.Start.Character, "var call character")
 }
+
+func TestParseStyleStrings(t *testing.T) {
+	source, err := os.ReadFile("testdata/style-strings.jsx")
+	require.NoError(t, err)
+
+	parser := js.AcquireParser()
+	defer js.ReleaseParser(parser)
+
+	strs := parser.ParseStyleStrings(string(source))
+	require.Len(t, strs, 4, "JSX style values and setProperty arguments only")
+
+	assert.Equal(t, js.StyleString{Content: "var(--badge-color)", StartLine: 2, StartCol: 27, Kind: js.StyleValue}, strs[0])
+	assert.Equal(t, js.StyleString{Content: "var(--space-small, 4px)", StartLine: 2, StartCol: 58, Kind: js.StyleValue}, strs[1])
+
+	var properties, values []string
+	for _, s := range strs[2:] {
+		switch s.Kind {
+		case js.StyleProperty:
+			properties = append(properties, s.Content)
+		case js.StyleValue:
+			values = append(values, s.Content)
+		}
+	}
+	assert.Equal(t, []string{"--badge-color"}, properties)
+	assert.Equal(t, []string{"var(--color-accent)"}, values)
+}
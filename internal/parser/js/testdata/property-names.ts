@@ -0,0 +1,11 @@
+const accent = getComputedStyle(host).getPropertyValue('--color-accent');
+
+CSS.registerProperty({
+  name: '--space-gap',
+  syntax: '<length>',
+  inherits: false,
+  initialValue: '0px',
+});
+
+host.style.setProperty('--color-accent', 'red');
+host.style.getPropertyValue('not a property');
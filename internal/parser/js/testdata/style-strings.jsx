@@ -0,0 +1,13 @@
+export function Badge({ tone }) {
+  return (
+    <span style={{ color: 'var(--badge-color)', padding: "var(--space-small, 4px)", ...extra }}>
+      {tone}
+    </span>
+  );
+}
+
+export function highlight(el) {
+  el.style.setProperty('--badge-color', 'var(--color-accent)');
+  el.setAttribute('title', 'var(--not-a-style)');
+  const styles = { color: 'var(--not-jsx)' };
+}
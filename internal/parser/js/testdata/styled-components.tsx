@@ -0,0 +1,36 @@
+// NOTE: Line/column-based tests depend on exact layout. Do not reformat.
+import styled, { createGlobalStyle, css, keyframes } from 'styled-components';
+
+const GlobalStyle = createGlobalStyle`
+  body { margin: var(--space-none); }
+`;
+
+const pulse = keyframes`
+  from { opacity: var(--opacity-full); }
+`;
+
+const truncate = css`
+  overflow: hidden;
+  text-overflow: var(--text-overflow, ellipsis);
+`;
+
+const Button = styled.button<{ primary?: boolean }>`
+  color: ${(p) => p.primary ? 'white' : 'black'};
+  background: var(--color-primary);
+  &:hover { background: var(--color-primary-hover); }
+`;
+
+const Link = styled(Button).attrs({ as: 'a' })`
+  padding: var(--space-sm);
+`;
+
+const Card = styled.div`
+  border-radius: var(--radius-md);
+  ${truncate}
+`;
+
+const Panel = styled('section')`
+  gap: var(--space-md);
+`;
+
+const notStyles = other.thing`var(--not-a-token)`;
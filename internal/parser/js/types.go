@@ -15,6 +15,30 @@ type Segment struct {
 type TemplateRegion struct {
 	// Segments contains the literal text parts of the template, split at ${...} boundaries
 	Segments []Segment
-	// Tag is the template tag function name ("css" or "html")
+	// Tag is the language of the template's content, "css" or "html".
+	// Templates with styled-components and emotion tags are "css".
 	Tag string
 }
+
+// StyleStringKind identifies what a style string holds
+type StyleStringKind int
+
+const (
+	// StyleValue is a CSS value, e.g. 'var(--text-color)'
+	StyleValue StyleStringKind = iota
+	// StyleProperty is a custom property name, e.g. '--text-color'
+	StyleProperty
+)
+
+// StyleString represents a string literal holding CSS used from script,
+// e.g. a JSX style object value or a style.setProperty() argument
+type StyleString struct {
+	// Content is the text between the quotes
+	Content string
+	// StartLine is the 0-indexed line in the JS/TS source where the content begins
+	StartLine uint
+	// StartCol is the 0-indexed column in the JS/TS source where the content begins
+	StartCol uint
+	// Kind identifies whether the string is a value or a property name
+	Kind StyleStringKind
+}
@@ -1,15 +1,22 @@
 package parser
 
 import (
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
 	"bennypowers.dev/dtls/internal/parser/css"
 	"bennypowers.dev/dtls/internal/parser/html"
 	"bennypowers.dev/dtls/internal/parser/js"
+	"bennypowers.dev/dtls/internal/parser/scss"
 )
 
 // cssLanguages maps language IDs to the parser category they use.
-// "css" → direct CSS, "html" → HTML parser, "js" → JS parser.
+// "css" → direct CSS, "scss" → SCSS bridge, "html" → HTML parser, "js" → JS parser.
 var cssLanguages = map[string]string{
 	"css":             "css",
+	"scss":            "scss",
 	"html":            "html",
 	"javascript":      "js",
 	"javascriptreact": "js",
@@ -17,21 +24,94 @@ var cssLanguages = map[string]string{
 	"typescriptreact": "js",
 }
 
+var (
+	aliasMu         sync.RWMutex
+	languageAliases map[string]string
+)
+
+// SetLanguageAliases maps nonstandard language IDs (e.g. "postcss") to supported
+// ones (e.g. "css"), so documents opened with them use that language's pipeline.
+// Replaces any previously set aliases. Returns the language IDs which were
+// ignored because they map to an unsupported language, sorted.
+func SetLanguageAliases(aliases map[string]string) []string {
+	valid := make(map[string]string, len(aliases))
+	var ignored []string
+	for from, to := range aliases {
+		if _, ok := cssLanguages[to]; ok {
+			valid[from] = to
+		} else {
+			ignored = append(ignored, from)
+		}
+	}
+	slices.Sort(ignored)
+
+	aliasMu.Lock()
+	defer aliasMu.Unlock()
+	languageAliases = valid
+	return ignored
+}
+
+// ResolveLanguage returns the supported language ID a document with the given
+// language ID is handled as, following any configured alias.
+// Built-in language IDs cannot be aliased.
+func ResolveLanguage(languageID string) string {
+	if _, ok := cssLanguages[languageID]; ok {
+		return languageID
+	}
+
+	aliasMu.RLock()
+	defer aliasMu.RUnlock()
+	if to, ok := languageAliases[languageID]; ok {
+		return to
+	}
+	return languageID
+}
+
+// category returns the parser category for a language ID, or "" if unsupported
+func category(languageID string) string {
+	return cssLanguages[ResolveLanguage(languageID)]
+}
+
+// LanguageIDForPath returns the language ID of a source file from its
+// extension, or "" when it is not a supported language
+func LanguageIDForPath(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".css":
+		return "css"
+	case ".scss":
+		return "scss"
+	case ".html", ".htm":
+		return "html"
+	case ".js", ".mjs", ".cjs":
+		return "javascript"
+	case ".jsx":
+		return "javascriptreact"
+	case ".ts", ".mts", ".cts":
+		return "typescript"
+	case ".tsx":
+		return "typescriptreact"
+	default:
+		return ""
+	}
+}
+
 // IsCSSSupportedLanguage returns true if the language supports CSS extraction
 func IsCSSSupportedLanguage(languageID string) bool {
-	_, ok := cssLanguages[languageID]
-	return ok
+	return category(languageID) != ""
 }
 
 // ParseCSSFromDocument extracts CSS parse results from any supported document type.
 // Dispatches to the appropriate parser based on language ID.
 func ParseCSSFromDocument(content, languageID string) (*css.ParseResult, error) {
-	switch cssLanguages[languageID] {
+	switch category(languageID) {
 	case "css":
 		p := css.AcquireParser()
 		defer css.ReleaseParser(p)
 		return p.Parse(content)
 
+	case "scss":
+		return scss.ParseCSS(content)
+
 	case "html":
 		p := html.AcquireParser()
 		defer html.ReleaseParser(p)
@@ -49,11 +129,12 @@ func ParseCSSFromDocument(content, languageID string) (*css.ParseResult, error)
 
 // CSSContentSpans returns the CSS text fragments from a document.
 // For CSS files, this is the entire content. For HTML/JS files, these are the
-// extracted CSS regions (style tags, style attributes, css tagged templates).
+// extracted CSS regions (style tags, style attributes, css tagged templates,
+// JSX style objects).
 // Used by completion to scope brace counting to CSS content only.
 func CSSContentSpans(content, languageID string) []string {
-	switch cssLanguages[languageID] {
-	case "css":
+	switch category(languageID) {
+	case "css", "scss":
 		return []string{content}
 
 	case "html":
@@ -81,6 +162,9 @@ func CSSContentSpans(content, languageID string) []string {
 				spans = append(spans, htmlCSSSpans(tmpl.Segments)...)
 			}
 		}
+		for _, str := range p.ParseStyleStrings(content) {
+			spans = append(spans, str.Content)
+		}
 		return spans
 
 	default:
@@ -88,6 +172,17 @@ func CSSContentSpans(content, languageID string) []string {
 	}
 }
 
+// StyleStrings returns the string literals holding CSS set from script in a
+// JS/TS document, e.g. JSX style object values, or nil for other languages
+func StyleStrings(content, languageID string) []js.StyleString {
+	if category(languageID) != "js" {
+		return nil
+	}
+	p := js.AcquireParser()
+	defer js.ReleaseParser(p)
+	return p.ParseStyleStrings(content)
+}
+
 // cssRegionSpan converts a CSS region to its text span.
 // Style tags return raw content; style attributes are wrapped in "x{...}"
 // to form valid CSS for brace counting.
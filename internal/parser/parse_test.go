@@ -4,6 +4,7 @@ import (
 	"testing"
 
 	"bennypowers.dev/dtls/internal/parser"
+	"bennypowers.dev/dtls/internal/parser/js"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -39,6 +40,33 @@ func TestIsCSSSupportedLanguage(t *testing.T) {
 	}
 }
 
+func TestSetLanguageAliases(t *testing.T) {
+	t.Cleanup(func() { parser.SetLanguageAliases(nil) })
+
+	ignored := parser.SetLanguageAliases(map[string]string{
+		"postcss": "css",
+		"sugarss": "css",
+		"vue":     "html",
+		"pug":     "jade",
+	})
+	assert.Equal(t, []string{"pug"}, ignored)
+
+	assert.True(t, parser.IsCSSSupportedLanguage("postcss"))
+	assert.Equal(t, "css", parser.ResolveLanguage("postcss"))
+	assert.Equal(t, "html", parser.ResolveLanguage("vue"))
+	assert.False(t, parser.IsCSSSupportedLanguage("pug"))
+	assert.Equal(t, "scss", parser.ResolveLanguage("scss"), "built-in IDs resolve to themselves")
+
+	result, err := parser.ParseCSSFromDocument(".a { color: var(--color-primary); }", "postcss")
+	require.NoError(t, err)
+	require.NotNil(t, result)
+	require.Len(t, result.VarCalls, 1)
+	assert.Equal(t, "--color-primary", result.VarCalls[0].TokenName)
+
+	parser.SetLanguageAliases(nil)
+	assert.False(t, parser.IsCSSSupportedLanguage("postcss"), "aliases are replaced, not merged")
+}
+
 func TestParseCSSFromDocumentCSS(t *testing.T) {
 	content := `.button { color: var(--color-primary); }`
 
@@ -140,6 +168,22 @@ func TestCSSContentSpansJSHTMLTemplate(t *testing.T) {
 	assert.True(t, found, "should have extracted CSS span '.b { color: blue; }' from html template")
 }
 
+func TestCSSContentSpansJSXStyleObject(t *testing.T) {
+	content := "const a = <a style={{ color: 'var(--x)' }} />;"
+	spans := parser.CSSContentSpans(content, "javascriptreact")
+	assert.Equal(t, []string{"var(--x)"}, spans)
+}
+
+func TestStyleStrings(t *testing.T) {
+	content := "el.style.setProperty('--x', 'var(--y)');"
+	strs := parser.StyleStrings(content, "typescript")
+	require.Len(t, strs, 2)
+	assert.Equal(t, "--x", strs[0].Content)
+	assert.Equal(t, js.StyleProperty, strs[0].Kind)
+
+	assert.Nil(t, parser.StyleStrings(content, "css"), "only JS/TS documents have style strings")
+}
+
 func TestCSSContentSpansUnsupported(t *testing.T) {
 	spans := parser.CSSContentSpans("{}", "json")
 	assert.Nil(t, spans)
@@ -0,0 +1,5 @@
+; Token annotations: /* token: color.primary */
+; @comment must capture a comment node. The token name is read from its text,
+; and the declaration after it is the one it annotates.
+((comment) @comment
+  (#match? @comment "^/\\*\\s*token:"))
@@ -0,0 +1,8 @@
+; Container queries: @container name style(--name: value) { ... }
+; @rule must capture an at_rule node. The grammar doesn't parse style()
+; queries, so the custom property names in them are read from the rule's
+; source before its block.
+(at_rule
+  (at_keyword) @keyword
+  (block)
+  (#eq? @keyword "@container")) @rule
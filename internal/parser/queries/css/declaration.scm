@@ -0,0 +1,5 @@
+; Custom property declarations: --name: value;
+; @declaration must capture a declaration node with a property_name child.
+(declaration
+  (property_name) @name
+  (#match? @name "^--")) @declaration
@@ -0,0 +1,7 @@
+; Token references: var(--name, fallback)
+; @call must capture a call_expression node. Its first argument is the token
+; name and everything after the first comma is the fallback.
+(call_expression
+  (function_name) @function
+  (arguments)
+  (#eq? @function "var")) @call
@@ -0,0 +1,5 @@
+; CSS in style="..." attributes. @attr_value captures the declarations.
+(attribute
+  (attribute_name) @attr_name
+  (quoted_attribute_value (attribute_value) @attr_value)
+  (#eq? @attr_name "style"))
@@ -0,0 +1,2 @@
+; CSS in <style> elements. @css captures the stylesheet text.
+(style_element (raw_text) @css)
@@ -0,0 +1,16 @@
+; Generic tagged template literals: css<Type>`...`
+; This is valid TypeScript (since TS 2.9) but both tree-sitter-javascript and
+; tree-sitter-typescript misparse it as binary expressions instead of a
+; call_expression with type_arguments.
+; See: https://github.com/tree-sitter/tree-sitter-typescript/issues/341
+(binary_expression
+  left: (binary_expression
+    left: (identifier) @tag)
+  right: (template_string) @template)
+
+; Likewise styled.div<Props>`...`
+(binary_expression
+  left: (binary_expression
+    left: (member_expression object: (identifier) @tag))
+  right: (template_string) @template
+  (#eq? @tag "styled"))
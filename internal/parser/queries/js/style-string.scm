@@ -0,0 +1,31 @@
+; String literals holding CSS set from script.
+; @value captures a CSS value, as in a JSX style object,
+;   <div style={{ color: 'var(--text-color)' }}>
+; or the value argument of style.setProperty('--x', 'var(--y)').
+; @property captures a custom property name, as in the first argument of
+; style.setProperty('--x', ...) and getComputedStyle(el).getPropertyValue('--x'),
+; or the name of CSS.registerProperty({ name: '--x', ... }).
+(jsx_attribute
+  (property_identifier) @attr_name
+  (jsx_expression
+    (object
+      (pair value: (string) @value)))
+  (#eq? @attr_name "style"))
+
+(call_expression
+  function: (member_expression property: (property_identifier) @method)
+  arguments: (arguments . (string) @property)
+  (#any-of? @method "setProperty" "getPropertyValue"))
+
+(call_expression
+  function: (member_expression property: (property_identifier) @method)
+  arguments: (arguments
+    . (object
+      (pair key: (property_identifier) @key value: (string) @property)))
+  (#eq? @method "registerProperty")
+  (#eq? @key "name"))
+
+(call_expression
+  function: (member_expression property: (property_identifier) @method)
+  arguments: (arguments . (_) . (string) @value)
+  (#eq? @method "setProperty"))
@@ -0,0 +1,28 @@
+; Tagged template literals: css`...` and html`...`
+; @tag captures the tag identifier and @template the template_string.
+(call_expression
+  function: (identifier) @tag
+  arguments: (template_string) @template)
+
+; styled-components and emotion styled templates. @tag captures the styled
+; identifier in styled.div`...`, styled(Button)`...`, and their
+; .attrs(...)`...` and .withConfig(...)`...` forms.
+(call_expression
+  function: (member_expression object: (identifier) @tag)
+  arguments: (template_string) @template
+  (#eq? @tag "styled"))
+
+(call_expression
+  function: (call_expression function: (identifier) @tag)
+  arguments: (template_string) @template
+  (#eq? @tag "styled"))
+
+(call_expression
+  function: (call_expression
+    function: (member_expression
+      object: [
+        (member_expression object: (identifier) @tag)
+        (call_expression function: (identifier) @tag)
+      ]))
+  arguments: (template_string) @template
+  (#eq? @tag "styled"))
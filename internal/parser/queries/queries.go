@@ -0,0 +1,126 @@
+// Package queries provides the tree-sitter queries the parsers use to find
+// CSS regions, custom property declarations, var() calls, container style
+// queries, and token annotation comments.
+//
+// Queries are embedded .scm files, organized by language:
+//
+//	queries/
+//	├── css/annotation.scm
+//	├── css/container.scm
+//	├── css/declaration.scm
+//	├── css/var-call.scm
+//	├── html/style.scm
+//	├── html/style-attribute.scm
+//	├── js/template.scm
+//	├── js/generic-template.scm
+//	└── js/style-string.scm
+//
+// Users can override any of them without recompiling by placing a file with the
+// same relative path in the directory set with SetOverrideDir. Overrides must keep
+// the capture names documented in each embedded file.
+package queries
+
+import (
+	"embed"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+
+	"bennypowers.dev/dtls/internal/log"
+	sitter "github.com/tree-sitter/go-tree-sitter"
+)
+
+//go:embed */*.scm
+var embedded embed.FS
+
+var (
+	overrideMu  sync.RWMutex
+	overrideDir string
+
+	// generation counts changes of the override directory
+	generation atomic.Uint64
+)
+
+// SetOverrideDir sets the directory searched for query overrides.
+// An empty dir uses only the embedded queries.
+// Reports whether the directory changed. A change starts a new Generation,
+// so pooled parsers compiled with the previous queries are discarded.
+func SetOverrideDir(dir string) bool {
+	overrideMu.Lock()
+	defer overrideMu.Unlock()
+
+	if dir == overrideDir {
+		return false
+	}
+	overrideDir = dir
+	generation.Add(1)
+	return true
+}
+
+// Generation returns a number which changes whenever the override directory
+// does. Parsers record it when they compile their queries, so that they can
+// tell when the queries they compiled are out of date.
+func Generation() uint64 {
+	return generation.Load()
+}
+
+// OverrideDir returns the directory searched for query overrides
+func OverrideDir() string {
+	overrideMu.RLock()
+	defer overrideMu.RUnlock()
+
+	return overrideDir
+}
+
+// Embedded returns the source of an embedded query, e.g. Embedded("css", "var-call")
+func Embedded(lang, name string) (string, error) {
+	data, err := embedded.ReadFile(lang + "/" + name + ".scm")
+	if err != nil {
+		return "", fmt.Errorf("unknown query %s/%s: %w", lang, name, err)
+	}
+	return string(data), nil
+}
+
+// override returns the source of an override query, if one exists
+func override(lang, name string) (source, path string, ok bool) {
+	dir := OverrideDir()
+	if dir == "" {
+		return "", "", false
+	}
+
+	path = filepath.Join(dir, lang, name+".scm")
+	data, err := os.ReadFile(path) //nolint:gosec // G304: User-configured query directory
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Warn("Failed to read query override %s: %v", path, err)
+		}
+		return "", "", false
+	}
+	return string(data), path, true
+}
+
+// Compile compiles a query for a language, preferring an override file.
+// Overrides that fail to compile are logged and the embedded query is used instead.
+// Panics if the embedded query cannot be compiled, since that is a build defect.
+func Compile(language *sitter.Language, lang, name string) *sitter.Query {
+	if source, path, ok := override(lang, name); ok {
+		query, qerr := sitter.NewQuery(language, source)
+		if qerr == nil {
+			log.Info("Using query override %s", path)
+			return query
+		}
+		log.Warn("Ignoring query override %s: %v", path, qerr)
+	}
+
+	source, err := Embedded(lang, name)
+	if err != nil {
+		panic(err.Error())
+	}
+	query, qerr := sitter.NewQuery(language, source)
+	if qerr != nil {
+		panic(fmt.Sprintf("failed to compile %s/%s query: %v", lang, name, qerr))
+	}
+	return query
+}
@@ -0,0 +1,71 @@
+package queries_test
+
+import (
+	"testing"
+
+	"bennypowers.dev/dtls/internal/parser/queries"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	sitter "github.com/tree-sitter/go-tree-sitter"
+	tree_sitter_css "github.com/tree-sitter/tree-sitter-css/bindings/go"
+	tree_sitter_html "github.com/tree-sitter/tree-sitter-html/bindings/go"
+	tree_sitter_javascript "github.com/tree-sitter/tree-sitter-javascript/bindings/go"
+)
+
+var cssLang = sitter.NewLanguage(tree_sitter_css.Language())
+
+// TestEmbeddedQueriesCompile tests that every embedded query compiles for its language
+func TestEmbeddedQueriesCompile(t *testing.T) {
+	languages := map[string]*sitter.Language{
+		"css":  cssLang,
+		"html": sitter.NewLanguage(tree_sitter_html.Language()),
+		"js":   sitter.NewLanguage(tree_sitter_javascript.Language()),
+	}
+	names := map[string][]string{
+		"css":  {"declaration", "var-call", "container", "annotation"},
+		"html": {"style", "style-attribute"},
+		"js":   {"template", "generic-template", "style-string"},
+	}
+
+	for lang, queryNames := range names {
+		for _, name := range queryNames {
+			t.Run(lang+"/"+name, func(t *testing.T) {
+				query := queries.Compile(languages[lang], lang, name)
+				require.NotNil(t, query)
+				query.Close()
+			})
+		}
+	}
+}
+
+func TestEmbedded_Unknown(t *testing.T) {
+	_, err := queries.Embedded("css", "nope")
+	assert.Error(t, err)
+}
+
+func TestCompile_Override(t *testing.T) {
+	t.Cleanup(func() { queries.SetOverrideDir("") })
+
+	t.Run("valid override is used", func(t *testing.T) {
+		assert.True(t, queries.SetOverrideDir("testdata/valid"))
+		assert.False(t, queries.SetOverrideDir("testdata/valid"), "unchanged dir reports no change")
+
+		query := queries.Compile(cssLang, "css", "var-call")
+		require.NotNil(t, query)
+		query.Close()
+	})
+
+	t.Run("invalid override falls back to embedded", func(t *testing.T) {
+		queries.SetOverrideDir("testdata/invalid")
+		query := queries.Compile(cssLang, "css", "var-call")
+		require.NotNil(t, query)
+		query.Close()
+	})
+
+	t.Run("missing override falls back to embedded", func(t *testing.T) {
+		queries.SetOverrideDir("testdata/valid")
+		query := queries.Compile(cssLang, "css", "declaration")
+		require.NotNil(t, query)
+		query.Close()
+	})
+}
@@ -0,0 +1 @@
+(call_expression (not_a_node) @call)
@@ -0,0 +1,188 @@
+// Package scss extracts CSS parse results from SCSS documents.
+//
+// The tree-sitter CSS grammar rejects Sass variables and line comments, so
+// the source is masked into CSS of the same shape before parsing: line
+// comments are blanked and "$" becomes "-", which keeps every position
+// intact. SCSS variables whose value is a design token var() call are then
+// bridged, so that each use of the variable is reported as a use of the token.
+package scss
+
+import (
+	"regexp"
+	"strings"
+
+	"bennypowers.dev/dtls/internal/parser/css"
+	"bennypowers.dev/dtls/internal/position"
+)
+
+// wrapperSelector names the rule that wraps the masked source, so that
+// top-level declarations such as "$primary: var(--color-primary);" parse
+const wrapperSelector = "dtls-scss-root"
+
+var (
+	// definitionPattern matches "$name: value" at the start of a statement
+	definitionPattern = regexp.MustCompile(`^\s*\$([A-Za-z_][\w-]*)\s*:\s*([^;]*)`)
+
+	// tokenValuePattern matches a value that is a var() call, capturing the token name
+	tokenValuePattern = regexp.MustCompile(`^var\(\s*(--[\w-]+)`)
+
+	// aliasValuePattern matches a value that is another SCSS variable
+	aliasValuePattern = regexp.MustCompile(`^\$([A-Za-z_][\w-]*)\s*(?:!default|!global|\s)*$`)
+
+	// usagePattern matches any SCSS variable
+	usagePattern = regexp.MustCompile(`\$([A-Za-z_][\w-]*)`)
+)
+
+// ParseCSS parses SCSS source, returning var() calls and custom property
+// declarations like the CSS parser, plus a VarCall with Alias set for each
+// use of an SCSS variable bound to a design token
+func ParseCSS(source string) (*css.ParseResult, error) {
+	lines := strings.Split(source, "\n")
+	for i, line := range lines {
+		lines[i] = stripLineComment(line)
+	}
+
+	p := css.AcquireParser()
+	defer css.ReleaseParser(p)
+
+	masked := strings.ReplaceAll(strings.Join(lines, "\n"), "$", "-")
+	result, err := p.Parse(wrapperSelector + "{\n" + masked + "\n}")
+	if err != nil {
+		return nil, err
+	}
+
+	// Remove the wrapper's line from every position
+	for _, v := range result.Variables {
+		v.Range = unwrapRange(v.Range)
+		v.ValueRange = unwrapRange(v.ValueRange)
+	}
+	for _, vc := range result.VarCalls {
+		vc.Range = unwrapRange(vc.Range)
+		// Nested rules are resolved against the wrapper too
+		if vc.Selector == wrapperSelector {
+			vc.Selector = ""
+		}
+		vc.Selector = strings.TrimPrefix(vc.Selector, wrapperSelector+" ")
+	}
+	for _, a := range result.Annotations {
+		a.Range = unwrapRange(a.Range)
+		if a.Declaration != nil {
+			declaration := unwrapRange(*a.Declaration)
+			a.Declaration = &declaration
+		}
+	}
+	// SCSS syntax, such as @include or @if, is not CSS the parser failed
+	// to analyze
+	result.Unsupported = nil
+
+	result.VarCalls = append(result.VarCalls, bridgeVariables(lines)...)
+	return result, nil
+}
+
+// bridgeVariables tracks SCSS variables bound to tokens, in document order,
+// and returns a VarCall for each use of one. A variable bound to another
+// bridged variable ($accent: $primary) resolves to the same token.
+func bridgeVariables(lines []string) []*css.VarCall {
+	bindings := map[string]string{}
+	var calls []*css.VarCall
+
+	for i, line := range lines {
+		definedAt := -1
+		var defined, value string
+		if m := definitionPattern.FindStringSubmatchIndex(line); m != nil {
+			definedAt = m[2] - 1
+			defined = line[m[2]:m[3]]
+			value = strings.TrimSpace(line[m[4]:m[5]])
+		}
+
+		for _, m := range usagePattern.FindAllStringSubmatchIndex(line, -1) {
+			if m[0] == definedAt {
+				continue
+			}
+			tokenName, ok := bindings[line[m[2]:m[3]]]
+			if !ok {
+				continue
+			}
+			calls = append(calls, &css.VarCall{
+				TokenName: tokenName,
+				Type:      css.VarReference,
+				Alias:     line[m[0]:m[1]],
+				Range: css.Range{
+					Start: css.Position{Line: uint32(i), Character: position.ByteOffsetToUTF16Uint32(line, m[0])}, //nolint:gosec // G115: line count is bounded by file size
+					End:   css.Position{Line: uint32(i), Character: position.ByteOffsetToUTF16Uint32(line, m[1])}, //nolint:gosec // G115: line count is bounded by file size
+				},
+			})
+		}
+
+		if definedAt < 0 {
+			continue
+		}
+		switch {
+		case tokenValuePattern.MatchString(value):
+			bindings[defined] = tokenValuePattern.FindStringSubmatch(value)[1]
+		case aliasValuePattern.MatchString(value):
+			if tokenName, ok := bindings[aliasValuePattern.FindStringSubmatch(value)[1]]; ok {
+				bindings[defined] = tokenName
+			} else {
+				delete(bindings, defined)
+			}
+		default:
+			delete(bindings, defined)
+		}
+	}
+
+	return calls
+}
+
+// stripLineComment blanks a "//" comment through the end of the line.
+// A "//" inside a quoted string or url(...), as in url(https://...), is not
+// a comment.
+func stripLineComment(line string) string {
+	for i := 0; i+1 < len(line); i++ {
+		switch {
+		case line[i] == '"' || line[i] == '\'':
+			i = stringEnd(line, i)
+		case hasURLPrefix(line[i:]) && (i == 0 || !isIdentByte(line[i-1])):
+			end := strings.IndexByte(line[i:], ')')
+			if end < 0 {
+				return line
+			}
+			i += end
+		case line[i] == '/' && line[i+1] == '/':
+			return line[:i] + strings.Repeat(" ", len(line)-i)
+		}
+	}
+	return line
+}
+
+// stringEnd returns the index of the quote closing the string which opens at
+// start, or the end of the line for unterminated strings
+func stringEnd(line string, start int) int {
+	quote := line[start]
+	for i := start + 1; i < len(line); i++ {
+		switch line[i] {
+		case '\\':
+			i++
+		case quote:
+			return i
+		}
+	}
+	return len(line)
+}
+
+// hasURLPrefix reports whether s starts with an unquoted url( token
+func hasURLPrefix(s string) bool {
+	return len(s) >= 4 && strings.EqualFold(s[:4], "url(")
+}
+
+// isIdentByte reports whether b can be part of a CSS identifier
+func isIdentByte(b byte) bool {
+	return b == '-' || b == '_' || b >= '0' && b <= '9' || b >= 'a' && b <= 'z' || b >= 'A' && b <= 'Z' || b >= 0x80
+}
+
+// unwrapRange moves a range from the wrapped source back to the SCSS document
+func unwrapRange(r css.Range) css.Range {
+	r.Start.Line--
+	r.End.Line--
+	return r
+}
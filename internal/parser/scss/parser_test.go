@@ -0,0 +1,106 @@
+package scss_test
+
+import (
+	"os"
+	"testing"
+
+	"bennypowers.dev/dtls/internal/parser/css"
+	"bennypowers.dev/dtls/internal/parser/scss"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCSS(t *testing.T) {
+	source, err := os.ReadFile("testdata/bridge.scss")
+	require.NoError(t, err)
+
+	result, err := scss.ParseCSS(string(source))
+	require.NoError(t, err)
+
+	var calls, aliases []*css.VarCall
+	for _, vc := range result.VarCalls {
+		if vc.Alias != "" {
+			aliases = append(aliases, vc)
+		} else {
+			calls = append(calls, vc)
+		}
+	}
+
+	t.Run("var() calls", func(t *testing.T) {
+		require.Len(t, calls, 2)
+
+		assert.Equal(t, "--color-primary", calls[0].TokenName)
+		assert.Equal(t, css.Range{
+			Start: css.Position{Line: 3, Character: 10},
+			End:   css.Position{Line: 3, Character: 39},
+		}, calls[0].Range)
+		assert.Empty(t, calls[0].Selector, "top-level calls have no selector")
+
+		assert.Equal(t, "--space-small", calls[1].TokenName)
+		assert.Equal(t, uint32(11), calls[1].Range.Start.Line)
+		assert.Equal(t, ".card", calls[1].Selector)
+	})
+
+	t.Run("bridged variables", func(t *testing.T) {
+		require.Len(t, aliases, 4)
+
+		// $primary in "$accent: $primary"
+		assert.Equal(t, "$primary", aliases[0].Alias)
+		assert.Equal(t, "--color-primary", aliases[0].TokenName)
+		assert.Equal(t, css.Range{
+			Start: css.Position{Line: 4, Character: 9},
+			End:   css.Position{Line: 4, Character: 17},
+		}, aliases[0].Range)
+
+		// color: $primary
+		assert.Equal(t, "$primary", aliases[1].Alias)
+		assert.Equal(t, css.Position{Line: 8, Character: 9}, aliases[1].Range.Start)
+
+		// $accent resolves through $primary
+		assert.Equal(t, "$accent", aliases[2].Alias)
+		assert.Equal(t, "--color-primary", aliases[2].TokenName)
+
+		// after url(https://...), which is not a comment
+		assert.Equal(t, "$primary", aliases[3].Alias)
+		assert.Equal(t, uint32(14), aliases[3].Range.Start.Line)
+	})
+}
+
+func TestParseCSS_Rebinding(t *testing.T) {
+	source := "$c: var(--a);\n.x { color: $c; }\n$c: red;\n.y { color: $c; }\n"
+
+	result, err := scss.ParseCSS(source)
+	require.NoError(t, err)
+
+	var aliases []*css.VarCall
+	for _, vc := range result.VarCalls {
+		if vc.Alias != "" {
+			aliases = append(aliases, vc)
+		}
+	}
+	require.Len(t, aliases, 1, "a variable rebound to a literal is no longer bridged")
+	assert.Equal(t, uint32(1), aliases[0].Range.Start.Line)
+}
+
+func TestParseCSS_LineComments(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   int
+	}{
+		{"comment hides the reference", ".a { color: red; } // var(--x)\n", 0},
+		{"protocol-relative url", ".a { background: url(//cdn.example.com/a.png); color: var(--x); }\n", 1},
+		{"double-quoted string", ".a { content: \"a // b\"; color: var(--x); }\n", 1},
+		{"single-quoted string", ".a { content: 'a // b'; color: var(--x); }\n", 1},
+		{"escaped quote in string", ".a { content: \"\\\" // \"; color: var(--x); }\n", 1},
+		{"comment after url", ".a { background: URL(https://example.com/a.png); } // var(--x)\n", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := scss.ParseCSS(tt.source)
+			require.NoError(t, err)
+			assert.Len(t, result.VarCalls, tt.want)
+		})
+	}
+}
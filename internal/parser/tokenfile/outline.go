@@ -0,0 +1,64 @@
+// Package tokenfile parses token files into a node tree recording the
+// position of every key and value.
+package tokenfile
+
+import (
+	"crypto/sha256"
+
+	"bennypowers.dev/dtls/internal/collections"
+	"bennypowers.dev/dtls/internal/metrics"
+	"github.com/tidwall/jsonc"
+	"gopkg.in/yaml.v3"
+)
+
+// outlineCacheSize is how many parsed token files are kept. Handlers for one
+// request often parse the same file several times, and editors re-request
+// features for a file until it changes.
+const outlineCacheSize = 16
+
+// outlineKey identifies token file content and how it was parsed
+type outlineKey struct {
+	hash [sha256.Size]byte
+	json bool
+}
+
+var outlines = collections.NewLRU[outlineKey, *yaml.Node](outlineCacheSize)
+
+// Outline returns the top-level node of a token file, or nil if it doesn't
+// parse. yaml.v3 reads both JSON and YAML; comments are stripped from JSON
+// first, preserving line numbers. Positions are 1-based, with columns
+// counting runes.
+//
+// Results are cached by content hash, so unchanged files aren't parsed again.
+// The node is shared and must not be modified.
+func Outline(content string, isJSON bool) *yaml.Node {
+	key := outlineKey{hash: sha256.Sum256([]byte(content)), json: isJSON}
+	if root, ok := outlines.Get(key); ok {
+		metrics.CacheLookup("tokenFileOutline", true)
+		return root
+	}
+	metrics.CacheLookup("tokenFileOutline", false)
+
+	data := []byte(content)
+	if isJSON {
+		data = jsonc.ToJSON(data)
+	}
+	var doc yaml.Node
+	var root *yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err == nil && len(doc.Content) > 0 {
+		root = doc.Content[0]
+	}
+	outlines.Put(key, root)
+	return root
+}
+
+// ValueNode returns the $value node of the token at path under node, or nil
+func ValueNode(node *yaml.Node, path []string) *yaml.Node {
+	keys := append(append([]string{}, path...), "$value")
+	for _, key := range keys {
+		if node = MappingValue(node, key); node == nil {
+			return nil
+		}
+	}
+	return node
+}
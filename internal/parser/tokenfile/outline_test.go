@@ -0,0 +1,51 @@
+package tokenfile_test
+
+import (
+	"testing"
+
+	"bennypowers.dev/dtls/internal/parser/tokenfile"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestOutline(t *testing.T) {
+	t.Run("JSON with comments", func(t *testing.T) {
+		root := tokenfile.Outline("{\n  // brand\n  \"color\": {\"$value\": \"#f00\"}\n}", true)
+		require.NotNil(t, root)
+		require.Equal(t, yaml.MappingNode, root.Kind)
+		assert.Equal(t, "color", root.Content[0].Value)
+		assert.Equal(t, 3, root.Content[0].Line, "line numbers survive comment stripping")
+	})
+
+	t.Run("YAML", func(t *testing.T) {
+		root := tokenfile.Outline("color:\n  $value: '#f00'\n", false)
+		require.NotNil(t, root)
+		assert.Equal(t, "color", root.Content[0].Value)
+	})
+
+	t.Run("invalid content", func(t *testing.T) {
+		assert.Nil(t, tokenfile.Outline("{", true))
+		assert.Nil(t, tokenfile.Outline("", false))
+	})
+
+	t.Run("identical content is parsed once", func(t *testing.T) {
+		content := `{"space": {"$value": "4px"}}`
+		assert.Same(t, tokenfile.Outline(content, true), tokenfile.Outline(content, true))
+		assert.NotSame(t, tokenfile.Outline(content, true), tokenfile.Outline(`{"space": {"$value": "8px"}}`, true))
+	})
+}
+
+func TestValueNode(t *testing.T) {
+	root := tokenfile.Outline("{\n  \"color\": {\n    \"primary\": {\n      \"$type\": \"color\",\n      \"$value\": \"#f00\"\n    }\n  }\n}", true)
+	require.NotNil(t, root)
+
+	node := tokenfile.ValueNode(root, []string{"color", "primary"})
+	require.NotNil(t, node)
+	assert.Equal(t, "#f00", node.Value)
+	assert.Equal(t, 5, node.Line)
+
+	assert.Nil(t, tokenfile.ValueNode(root, []string{"color"}), "groups have no $value")
+	assert.Nil(t, tokenfile.ValueNode(root, []string{"color", "secondary"}))
+	assert.Nil(t, tokenfile.ValueNode(nil, []string{"color"}))
+}
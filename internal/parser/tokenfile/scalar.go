@@ -0,0 +1,114 @@
+package tokenfile
+
+import (
+	"encoding/json"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"bennypowers.dev/dtls/internal/position"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+	"gopkg.in/yaml.v3"
+)
+
+// IsLanguage reports whether languageID is a token file language: JSON,
+// JSON with comments, or YAML
+func IsLanguage(languageID string) bool {
+	switch languageID {
+	case "json", "jsonc", "yaml":
+		return true
+	}
+	return false
+}
+
+// MappingValue returns the value of key in a mapping node, or nil
+func MappingValue(node *yaml.Node, key string) *yaml.Node {
+	if node == nil || node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// ScalarTextRange returns the range of a scalar's source text in content,
+// including any quotes. yaml.v3 columns count runes from 1 and point at
+// the opening quote.
+func ScalarTextRange(content string, node *yaml.Node) protocol.Range {
+	lines := strings.Split(content, "\n")
+	line := uint32(max(node.Line-1, 0)) //nolint:gosec // G115: yaml line numbers are bounded by file size
+	if int(line) >= len(lines) {
+		return protocol.Range{}
+	}
+	runes := []rune(lines[line])
+	start := min(max(node.Column-1, 0), len(runes))
+
+	// The source text is the value, plus quotes and escapes when quoted
+	length := len([]rune(node.Value))
+	if node.Style&(yaml.DoubleQuotedStyle|yaml.SingleQuotedStyle) != 0 {
+		length = quotedLength(runes[start:])
+	}
+	end := min(start+length, len(runes))
+
+	character := position.StringLengthUTF16Uint32(string(runes[:start]))
+	return protocol.Range{
+		Start: protocol.Position{Line: line, Character: character},
+		End:   protocol.Position{Line: line, Character: character + position.StringLengthUTF16Uint32(string(runes[start:end]))},
+	}
+}
+
+// quotedLength returns the length in runes of the quoted string at the start of s
+func quotedLength(s []rune) int {
+	if len(s) == 0 {
+		return 0
+	}
+	quote := s[0]
+	for i := 1; i < len(s); i++ {
+		switch {
+		case quote == '"' && s[i] == '\\':
+			i++
+		case quote == '\'' && s[i] == '\'' && i+1 < len(s) && s[i+1] == '\'':
+			i++
+		case s[i] == quote:
+			return i + 1
+		}
+	}
+	return len(s)
+}
+
+// ScalarText writes value as a scalar in the style of node, the scalar it
+// replaces: numbers stay bare where the old value was bare, as do words in
+// YAML, and strings are quoted
+func ScalarText(node *yaml.Node, value string, isJSON bool) string {
+	if node.Style&yaml.SingleQuotedStyle != 0 {
+		return "'" + strings.ReplaceAll(value, "'", "''") + "'"
+	}
+	if node.Style&yaml.DoubleQuotedStyle == 0 {
+		if _, err := strconv.ParseFloat(value, 64); err == nil || (!isJSON && isPlain(value)) {
+			return value
+		}
+	}
+	// JSON strings are valid double-quoted YAML scalars
+	quoted, _ := json.Marshal(value)
+	return string(quoted)
+}
+
+// plainWord matches strings which YAML reads as strings without quotes,
+// e.g. srgb or hsl(0 100% 50%)
+var plainWord = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9 ().%/-]*$`)
+
+// isPlain reports whether YAML reads value, unquoted, as that same string,
+// in flow collections as well as in blocks
+func isPlain(value string) bool {
+	if !plainWord.MatchString(value) || strings.HasSuffix(value, " ") {
+		return false
+	}
+	var decoded any
+	if err := yaml.Unmarshal([]byte(value), &decoded); err != nil {
+		return false
+	}
+	return decoded == value
+}
@@ -0,0 +1,97 @@
+package tokenfile_test
+
+import (
+	"testing"
+
+	"bennypowers.dev/dtls/internal/parser/tokenfile"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+func TestIsLanguage(t *testing.T) {
+	for _, languageID := range []string{"json", "jsonc", "yaml"} {
+		assert.True(t, tokenfile.IsLanguage(languageID), languageID)
+	}
+	for _, languageID := range []string{"css", "html", ""} {
+		assert.False(t, tokenfile.IsLanguage(languageID), languageID)
+	}
+}
+
+func TestMappingValue(t *testing.T) {
+	root := tokenfile.Outline(`{"$type": "color", "primary": {"$value": "#f00"}}`, true)
+	require.NotNil(t, root)
+
+	assert.Equal(t, "color", tokenfile.MappingValue(root, "$type").Value)
+	assert.Nil(t, tokenfile.MappingValue(root, "secondary"))
+	assert.Nil(t, tokenfile.MappingValue(tokenfile.MappingValue(root, "$type"), "$value"), "scalars have no keys")
+	assert.Nil(t, tokenfile.MappingValue(nil, "$type"))
+}
+
+func TestScalarTextRange(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		isJSON  bool
+		want    protocol.Range
+	}{
+		{
+			name:    "double quoted",
+			content: `{"a": {"$value": "#f00"}}`,
+			isJSON:  true,
+			want:    protocol.Range{Start: protocol.Position{Line: 0, Character: 17}, End: protocol.Position{Line: 0, Character: 23}},
+		},
+		{
+			name:    "escaped quote",
+			content: `{"a": {"$value": "a\"b"}}`,
+			isJSON:  true,
+			want:    protocol.Range{Start: protocol.Position{Line: 0, Character: 17}, End: protocol.Position{Line: 0, Character: 23}},
+		},
+		{
+			name:    "single quoted",
+			content: "a:\n  $value: 'it''s'\n",
+			want:    protocol.Range{Start: protocol.Position{Line: 1, Character: 10}, End: protocol.Position{Line: 1, Character: 17}},
+		},
+		{
+			name:    "bare",
+			content: "a:\n  $value: 4px\n",
+			want:    protocol.Range{Start: protocol.Position{Line: 1, Character: 10}, End: protocol.Position{Line: 1, Character: 13}},
+		},
+		{
+			name:    "UTF-16 columns",
+			content: "a:\n  😀: 1\n  $value: red\n",
+			want:    protocol.Range{Start: protocol.Position{Line: 2, Character: 10}, End: protocol.Position{Line: 2, Character: 13}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node := tokenfile.ValueNode(tokenfile.Outline(tt.content, tt.isJSON), []string{"a"})
+			require.NotNil(t, node)
+			assert.Equal(t, tt.want, tokenfile.ScalarTextRange(tt.content, node))
+		})
+	}
+}
+
+func TestScalarText(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		isJSON  bool
+		value   string
+		want    string
+	}{
+		{name: "JSON string", content: `{"a": {"$value": "#f00"}}`, isJSON: true, value: `#0"f`, want: `"#0\"f"`},
+		{name: "JSON number", content: `{"a": {"$value": 4}}`, isJSON: true, value: "8", want: "8"},
+		{name: "JSON number replaced by a word", content: `{"a": {"$value": 4}}`, isJSON: true, value: "red", want: `"red"`},
+		{name: "YAML bare word", content: "a:\n  $value: red\n", value: "srgb", want: "srgb"},
+		{name: "YAML bare, not plain", content: "a:\n  $value: red\n", value: "#f00", want: `"#f00"`},
+		{name: "YAML single quoted", content: "a:\n  $value: 'red'\n", value: "it's", want: "'it''s'"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node := tokenfile.ValueNode(tokenfile.Outline(tt.content, tt.isJSON), []string{"a"})
+			require.NotNil(t, node)
+			assert.Equal(t, tt.want, tokenfile.ScalarText(node, tt.value, tt.isJSON))
+		})
+	}
+}
@@ -0,0 +1,86 @@
+// Package yaml finds the tokens in DTCG token files written as YAML, and
+// where each is defined.
+package yaml
+
+import (
+	"errors"
+	"slices"
+	"strings"
+
+	"bennypowers.dev/dtls/internal/parser/tokenfile"
+	goyaml "gopkg.in/yaml.v3"
+)
+
+// Token is a token defined in a YAML token file
+type Token struct {
+	// Path is the token's path, e.g. ["color", "primary"]
+	Path []string
+	// Line is the 0-indexed line of the token's key
+	Line uint32
+	// Character is the byte offset of the token's key in its line
+	Character uint32
+}
+
+// ErrNotMapping is returned for content which isn't a YAML mapping
+var ErrNotMapping = errors.New("token file is not a YAML mapping")
+
+// Parse finds the tokens in YAML token file content: the mappings with a
+// $value key, in document order. A token under a $root key, or a key named
+// in groupMarkers, is its parent group's token, e.g. color.$root is color.
+func Parse(content string, groupMarkers []string) ([]Token, error) {
+	root := tokenfile.Outline(content, false)
+	if root == nil || root.Kind != goyaml.MappingNode {
+		return nil, ErrNotMapping
+	}
+
+	lines := strings.Split(content, "\n")
+	var found []Token
+	var walk func(group *goyaml.Node, path []string)
+	walk = func(group *goyaml.Node, path []string) {
+		for i := 0; i+1 < len(group.Content); i += 2 {
+			key, value := group.Content[i], group.Content[i+1]
+			if value.Kind != goyaml.MappingNode {
+				continue
+			}
+			isRoot := key.Value == "$root" || (len(path) > 0 && slices.Contains(groupMarkers, key.Value))
+			if strings.HasPrefix(key.Value, "$") && !isRoot {
+				continue
+			}
+
+			childPath := append(slices.Clip(path), key.Value)
+			if mappingValue(value, "$value") != nil {
+				tokenPath := childPath
+				if isRoot {
+					tokenPath = slices.Clone(path)
+				}
+				line, character := keyPosition(lines, key)
+				found = append(found, Token{Path: tokenPath, Line: line, Character: character})
+			}
+			walk(value, childPath)
+		}
+	}
+	walk(root, nil)
+	return found, nil
+}
+
+// mappingValue returns the value of a key in a mapping node, or nil
+func mappingValue(mapping *goyaml.Node, key string) *goyaml.Node {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			return mapping.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// keyPosition converts a key node's 1-based line and rune column to a
+// 0-indexed line and byte offset
+func keyPosition(lines []string, key *goyaml.Node) (line, character uint32) {
+	row := max(key.Line-1, 0)
+	col := max(key.Column-1, 0)
+	offset := col
+	if row < len(lines) {
+		offset = len(string([]rune(lines[row])[:min(col, len([]rune(lines[row])))]))
+	}
+	return uint32(row), uint32(offset) //nolint:gosec // G115: positions are bounded by file size
+}
@@ -0,0 +1,40 @@
+package yaml_test
+
+import (
+	"os"
+	"testing"
+
+	"bennypowers.dev/dtls/internal/parser/yaml"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	content, err := os.ReadFile("testdata/tokens.yaml")
+	require.NoError(t, err)
+
+	found, err := yaml.Parse(string(content), []string{"_"})
+	require.NoError(t, err)
+	assert.Equal(t, []yaml.Token{
+		{Path: []string{"color", "brand"}, Line: 6, Character: 4},
+		{Path: []string{"color", "brand", "primary"}, Line: 8, Character: 4},
+		{Path: []string{"color", "brand", "ünïcode"}, Line: 10, Character: 4},
+		{Path: []string{"spacing"}, Line: 13, Character: 2},
+		{Path: []string{"spacing", "small"}, Line: 15, Character: 2},
+	}, found)
+}
+
+func TestParse_MultiByteColumns(t *testing.T) {
+	found, err := yaml.Parse("ä: { b: { $value: 1 }, ü: { $value: 2 } }", nil)
+	require.NoError(t, err)
+	require.Len(t, found, 2)
+	assert.Equal(t, uint32(6), found[0].Character, "byte offset of b")
+	assert.Equal(t, uint32(24), found[1].Character, "byte offset of ü")
+}
+
+func TestParse_NotMapping(t *testing.T) {
+	for _, content := range []string{"", "- a\n- b\n", "a: [unclosed"} {
+		_, err := yaml.Parse(content, nil)
+		assert.ErrorIs(t, err, yaml.ErrNotMapping, "%q", content)
+	}
+}
@@ -0,0 +1,137 @@
+package tokens
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// CircularReferenceError reports an alias chain which leads back to a token
+// already in it, so it never reaches a concrete value
+type CircularReferenceError struct {
+	// Cycle is the paths of the tokens in the cycle, starting and ending
+	// with the same token, e.g. [color.a color.b color.a]
+	Cycle []string
+}
+
+func (e *CircularReferenceError) Error() string {
+	return "circular reference: " + strings.Join(e.Cycle, " → ")
+}
+
+// AliasTarget returns the path of the token a token's value aliases, e.g.
+// color.primary for "{color.primary}" or {"$ref": "#/color/primary"}, or
+// empty when its value is not an alias
+func AliasTarget(t *Token) string {
+	switch value := t.RawValue.(type) {
+	case string:
+		if strings.HasPrefix(value, "{") && strings.HasSuffix(value, "}") && strings.Count(value, "{") == 1 {
+			return TokenPath(value[1 : len(value)-1])
+		}
+	case map[string]any:
+		if pointer, ok := value["$ref"].(string); ok && strings.HasPrefix(pointer, "#/") {
+			pointer = strings.TrimSuffix(strings.TrimPrefix(pointer, "#/"), "/$value")
+			return TokenPath(strings.ReplaceAll(pointer, "/", "."))
+		}
+	}
+	return ""
+}
+
+// TokenPath normalizes a dot-separated token path, e.g. color.brand.$root,
+// to the path aliases name its token by, color.brand
+func TokenPath(path string) string {
+	return strings.TrimSuffix(path, ".$root")
+}
+
+// AliasChain follows a token's aliases to the token holding its concrete
+// value. The chain starts with token and ends with that token, so a token
+// which is not an alias is its own chain. lookup finds a token by path, e.g.
+// color.primary.
+//
+// For a chain which leads back to a token already in it, the error is a
+// *CircularReferenceError, and for one naming an unknown token, the error
+// names it. Either way, the chain is followed as far as it goes.
+func AliasChain(token *Token, lookup func(path string) *Token) ([]*Token, error) {
+	chain := []*Token{token}
+	seen := map[*Token]int{token: 0}
+	for t := token; ; {
+		path := AliasTarget(t)
+		if path == "" {
+			return chain, nil
+		}
+		next := lookup(path)
+		if next == nil {
+			return chain, fmt.Errorf("unknown token {%s}", path)
+		}
+		if i, ok := seen[next]; ok {
+			cycle := make([]string, 0, len(chain)-i+1)
+			for _, link := range chain[i:] {
+				cycle = append(cycle, TokenPath(strings.Join(link.Path, ".")))
+			}
+			return chain, &CircularReferenceError{Cycle: append(cycle, cycle[0])}
+		}
+		seen[next] = len(chain)
+		chain = append(chain, next)
+		t = next
+	}
+}
+
+// ResolveAliasChains resolves every alias among a set of tokens to the
+// concrete value at the end of its chain, however long, which becomes its
+// ResolvedValue and Value. Tokens already
+// resolved are left as they are. Aliases which can't be resolved, because
+// they name unknown tokens or are in or lead to a cycle, are returned as
+// errors, with each cycle reported once.
+func ResolveAliasChains(all []*Token) error {
+	byPath := make(map[string]*Token, len(all))
+	for _, t := range all {
+		byPath[TokenPath(strings.Join(t.Path, "."))] = t
+	}
+	lookup := func(path string) *Token { return byPath[path] }
+
+	var errs []error
+	reported := map[string]bool{}
+	for _, t := range all {
+		if AliasTarget(t) == "" {
+			continue
+		}
+		chain, err := AliasChain(t, lookup)
+		if err != nil {
+			var cycle *CircularReferenceError
+			if errors.As(err, &cycle) {
+				key := cycleKey(cycle.Cycle)
+				if reported[key] {
+					continue
+				}
+				reported[key] = true
+			}
+			errs = append(errs, fmt.Errorf("cannot resolve %s: %w", t.Name, err))
+			continue
+		}
+		if t.IsResolved {
+			continue
+		}
+		last := chain[len(chain)-1]
+		t.ResolvedValue = last.RawValue
+		if last.IsResolved && last.ResolvedValue != nil {
+			t.ResolvedValue = last.ResolvedValue
+		}
+		t.IsResolved = true
+		if last.Value != "" {
+			t.Value = last.Value
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// cycleKey identifies a cycle whichever of its tokens it starts from
+func cycleKey(cycle []string) string {
+	links := cycle[:len(cycle)-1]
+	start := 0
+	for i, path := range links {
+		if path < links[start] {
+			start = i
+		}
+	}
+	return strings.Join(slices.Concat(links[start:], links[:start]), " ")
+}
@@ -0,0 +1,116 @@
+package tokens_test
+
+import (
+	"strings"
+	"testing"
+
+	"bennypowers.dev/dtls/internal/tokens"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAliasTarget(t *testing.T) {
+	tests := []struct {
+		value any
+		want  string
+	}{
+		{"{color.primary}", "color.primary"},
+		{"{color.brand.$root}", "color.brand"},
+		{map[string]any{"$ref": "#/color/primary"}, "color.primary"},
+		{map[string]any{"$ref": "#/color/primary/$value"}, "color.primary"},
+		{map[string]any{"$ref": "#/color/brand/$root"}, "color.brand"},
+		{"#ff0000", ""},
+		{"{spacing.base} * 2", ""},
+		{"{a} {b}", ""},
+		{map[string]any{"colorSpace": "srgb"}, ""},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, tokens.AliasTarget(&tokens.Token{RawValue: tt.value}), "%v", tt.value)
+	}
+}
+
+// byPath returns a lookup of the given tokens by their path
+func byPath(all ...*tokens.Token) func(string) *tokens.Token {
+	return func(path string) *tokens.Token {
+		for _, t := range all {
+			if tokens.TokenPath(strings.Join(t.Path, ".")) == path {
+				return t
+			}
+		}
+		return nil
+	}
+}
+
+func TestAliasChain(t *testing.T) {
+	primary := &tokens.Token{Name: "color-primary", Path: []string{"color", "primary"}, RawValue: "#ff0000"}
+	brand := &tokens.Token{Name: "color-brand", Path: []string{"color", "brand", "$root"}, RawValue: "{color.primary}"}
+	accent := &tokens.Token{Name: "color-accent", Path: []string{"color", "accent"}, RawValue: map[string]any{"$ref": "#/color/brand/$root"}}
+	loopA := &tokens.Token{Name: "loop-a", Path: []string{"loop", "a"}, RawValue: "{loop.b}"}
+	loopB := &tokens.Token{Name: "loop-b", Path: []string{"loop", "b"}, RawValue: "{loop.a}"}
+	intoLoop := &tokens.Token{Name: "into-loop", Path: []string{"into", "loop"}, RawValue: "{loop.a}"}
+	self := &tokens.Token{Name: "self", Path: []string{"self"}, RawValue: "{self}"}
+	broken := &tokens.Token{Name: "broken", Path: []string{"broken"}, RawValue: "{color.missing}"}
+	lookup := byPath(primary, brand, accent, loopA, loopB, intoLoop, self, broken)
+
+	t.Run("follows aliases to a concrete value", func(t *testing.T) {
+		chain, err := tokens.AliasChain(accent, lookup)
+		require.NoError(t, err)
+		assert.Equal(t, []*tokens.Token{accent, brand, primary}, chain)
+	})
+
+	t.Run("a literal is its own chain", func(t *testing.T) {
+		chain, err := tokens.AliasChain(primary, lookup)
+		require.NoError(t, err)
+		assert.Equal(t, []*tokens.Token{primary}, chain)
+	})
+
+	t.Run("detects cycles", func(t *testing.T) {
+		chain, err := tokens.AliasChain(loopA, lookup)
+		var cycle *tokens.CircularReferenceError
+		require.ErrorAs(t, err, &cycle)
+		assert.Equal(t, []string{"loop.a", "loop.b", "loop.a"}, cycle.Cycle)
+		assert.Equal(t, "circular reference: loop.a → loop.b → loop.a", err.Error())
+		assert.Equal(t, []*tokens.Token{loopA, loopB}, chain)
+	})
+
+	t.Run("detects cycles the chain leads into", func(t *testing.T) {
+		_, err := tokens.AliasChain(intoLoop, lookup)
+		var cycle *tokens.CircularReferenceError
+		require.ErrorAs(t, err, &cycle)
+		assert.Equal(t, []string{"loop.a", "loop.b", "loop.a"}, cycle.Cycle)
+	})
+
+	t.Run("detects self references", func(t *testing.T) {
+		_, err := tokens.AliasChain(self, lookup)
+		var cycle *tokens.CircularReferenceError
+		require.ErrorAs(t, err, &cycle)
+		assert.Equal(t, []string{"self", "self"}, cycle.Cycle)
+	})
+
+	t.Run("reports unknown tokens", func(t *testing.T) {
+		chain, err := tokens.AliasChain(broken, lookup)
+		assert.EqualError(t, err, "unknown token {color.missing}")
+		assert.Equal(t, []*tokens.Token{broken}, chain)
+	})
+}
+
+func TestResolveAliasChains(t *testing.T) {
+	primary := &tokens.Token{Name: "color-primary", Path: []string{"color", "primary"}, Value: "#ff0000", RawValue: "#ff0000"}
+	brand := &tokens.Token{Name: "color-brand", Path: []string{"color", "brand"}, Value: "{color.primary}", RawValue: "{color.primary}"}
+	accent := &tokens.Token{Name: "color-accent", Path: []string{"color", "accent"}, Value: "{color.brand}", RawValue: "{color.brand}"}
+	resolved := &tokens.Token{Name: "color-link", Path: []string{"color", "link"}, RawValue: "{color.primary}", ResolvedValue: "blue", IsResolved: true}
+	loopA := &tokens.Token{Name: "loop-a", Path: []string{"loop", "a"}, RawValue: "{loop.b}"}
+	loopB := &tokens.Token{Name: "loop-b", Path: []string{"loop", "b"}, RawValue: "{loop.a}"}
+
+	err := tokens.ResolveAliasChains([]*tokens.Token{accent, brand, primary, resolved, loopA, loopB})
+	require.Error(t, err)
+	assert.Equal(t, 1, strings.Count(err.Error(), "circular reference"), "each cycle is reported once")
+
+	assert.Equal(t, "#ff0000", accent.ResolvedValue)
+	assert.Equal(t, "#ff0000", accent.Value)
+	assert.True(t, accent.IsResolved)
+	assert.Equal(t, "#ff0000", brand.ResolvedValue)
+	assert.Equal(t, "blue", resolved.ResolvedValue, "resolved tokens are left as they are")
+	assert.False(t, loopA.IsResolved)
+	assert.False(t, primary.IsResolved, "literal tokens are untouched")
+}
@@ -0,0 +1,75 @@
+package tokens
+
+import (
+	"cmp"
+	"slices"
+	"strings"
+)
+
+// Collision is a CSS variable name which more than one token formats to,
+// e.g. --color-primary-dark for both color.primary-dark and
+// color.primary.dark. Only one of them is used.
+type Collision struct {
+	// Name is the CSS variable name, e.g. "--color-primary-dark"
+	Name string
+
+	// Tokens are the colliding tokens, in file and path order
+	Tokens []*Token
+}
+
+// Collisions returns the CSS variable names which tokens with different
+// prefixes or paths format to, in name order. The tokens include those a
+// token file replaced with another of the same name, e.g. color.primary-dark
+// with color.primary.dark. Tokens with the same prefix and path in more than
+// one file, such as the themes of a multi-file token set, are not
+// collisions, but are listed along with the tokens they collide with.
+func (m *Manager) Collisions() []Collision {
+	idx := m.load()
+
+	byName := map[string][]*Token{}
+	add := func(token *Token) {
+		name := idx.format.CSSVariableName(token)
+		byName[name] = append(byName[name], token)
+	}
+	for _, token := range idx.tokens {
+		add(token)
+	}
+	for _, tokens := range idx.shadowed {
+		for _, token := range tokens {
+			add(token)
+		}
+	}
+
+	var collisions []Collision
+	for name, tokens := range byName {
+		identities := map[string]bool{}
+		for _, token := range tokens {
+			identities[tokenIdentity(token)] = true
+		}
+		if len(identities) < 2 {
+			continue
+		}
+		slices.SortFunc(tokens, func(a, b *Token) int {
+			return cmp.Or(
+				cmp.Compare(a.FilePath, b.FilePath),
+				cmp.Compare(a.Line, b.Line),
+				cmp.Compare(tokenIdentity(a), tokenIdentity(b)),
+			)
+		})
+		collisions = append(collisions, Collision{Name: name, Tokens: tokens})
+	}
+	slices.SortFunc(collisions, func(a, b Collision) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	return collisions
+}
+
+// tokenIdentity distinguishes tokens by prefix and path, or by name for
+// tokens without a path
+func tokenIdentity(token *Token) string {
+	path := token.Name
+	if len(token.Path) > 0 {
+		path = strings.Join(token.Path, ".")
+	}
+	return token.Prefix + ":" + path
+}
@@ -0,0 +1,52 @@
+package tokens_test
+
+import (
+	"testing"
+
+	"bennypowers.dev/dtls/internal/tokens"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestManager_Collisions(t *testing.T) {
+	manager := tokens.NewManager()
+
+	dashed := &tokens.Token{Name: "color-primary-dark", Path: []string{"color", "primary-dark"}, FilePath: "/a.json", Line: 2}
+	dotted := &tokens.Token{Name: "color-primary-dark", Path: []string{"color", "primary", "dark"}, FilePath: "/a.json", Line: 5}
+	prefixed := &tokens.Token{Name: "color-accent", Path: []string{"color", "accent"}, Prefix: "ds", FilePath: "/a.json", Line: 8}
+	unprefixed := &tokens.Token{Name: "ds-color-accent", Path: []string{"ds", "color", "accent"}, FilePath: "/b.json", Line: 1}
+	light := &tokens.Token{Name: "color-surface", Path: []string{"color", "surface"}, FilePath: "/light.json"}
+	dark := &tokens.Token{Name: "color-surface", Path: []string{"color", "surface"}, FilePath: "/dark.json"}
+	for _, token := range []*tokens.Token{dashed, dotted, prefixed, unprefixed, light, dark} {
+		require.NoError(t, manager.Add(token))
+	}
+
+	assert.Same(t, dotted, manager.Get("color-primary-dark"), "the later token replaces the earlier one")
+	assert.Equal(t, []tokens.Collision{
+		{Name: "--color-primary-dark", Tokens: []*tokens.Token{dashed, dotted}},
+		{Name: "--ds-color-accent", Tokens: []*tokens.Token{prefixed, unprefixed}},
+	}, manager.Collisions(), "the same path in two files is not a collision")
+
+	t.Run("reloading a file forgets its replaced tokens", func(t *testing.T) {
+		manager.RemoveBySourceFile("/a.json")
+		require.NoError(t, manager.Add(dotted))
+		assert.Empty(t, manager.Collisions())
+	})
+
+	t.Run("updating a token is not a collision", func(t *testing.T) {
+		updated := *dotted
+		updated.Value = "#000"
+		require.NoError(t, manager.Add(&updated))
+		assert.Empty(t, manager.Collisions())
+	})
+
+	t.Run("renamed files keep their replaced tokens", func(t *testing.T) {
+		require.NoError(t, manager.Add(dashed))
+		manager.RenameSourceFile("/a.json", "/c.json", "file:///c.json")
+		collisions := manager.Collisions()
+		require.Len(t, collisions, 1)
+		for _, token := range collisions[0].Tokens {
+			assert.Equal(t, "/c.json", token.FilePath)
+		}
+	})
+}
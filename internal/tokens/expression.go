@@ -0,0 +1,425 @@
+package tokens
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Expression is a token value computed from other tokens, e.g.
+// "{spacing.base} * 2", a common pattern in token files that predate DTCG.
+// Expressions support +, -, *, / and parentheses over references, numbers
+// and dimensions.
+type Expression struct {
+	root exprNode
+}
+
+// exprNode is a node of a parsed expression
+type exprNode interface {
+	// eval computes the node, looking up references with lookup
+	eval(lookup func(ref string) (string, error)) (dimension, error)
+
+	// css writes the node as a calc() operand, with references replaced
+	// by their values
+	css(lookup func(ref string) (string, error)) (string, error)
+}
+
+type numberNode struct{ value dimension }
+
+type referenceNode struct{ path string }
+
+type groupNode struct{ inner exprNode }
+
+type binaryNode struct {
+	op          byte
+	left, right exprNode
+}
+
+// dimension is a number with an optional unit, e.g. 4px or 1.5
+type dimension struct {
+	n    float64
+	unit string
+}
+
+func (d dimension) String() string {
+	// Round away floating point noise such as 0.30000000000000004
+	n := math.Round(d.n*1e4) / 1e4
+	return strconv.FormatFloat(n, 'f', -1, 64) + d.unit
+}
+
+// dimensionPattern matches a number with an optional unit
+var dimensionPattern = regexp.MustCompile(`^(-?(?:\d+\.?\d*|\.\d+)(?:[eE][-+]?\d+)?)([a-zA-Z%]*)$`)
+
+// parseDimension parses a number with an optional unit
+func parseDimension(s string) (dimension, bool) {
+	match := dimensionPattern.FindStringSubmatch(strings.TrimSpace(s))
+	if match == nil {
+		return dimension{}, false
+	}
+	n, err := strconv.ParseFloat(match[1], 64)
+	if err != nil {
+		return dimension{}, false
+	}
+	return dimension{n: n, unit: match[2]}, true
+}
+
+// IsExpression reports whether value is a string which computes a value
+// from references, as opposed to a literal or a single alias like "{a.b}"
+func IsExpression(value any) bool {
+	s, ok := value.(string)
+	if !ok || !strings.Contains(s, "{") {
+		return false
+	}
+	e, err := ParseExpression(s)
+	if err != nil {
+		return false
+	}
+	_, alias := e.root.(*referenceNode)
+	return !alias
+}
+
+// ParseExpression parses an expression such as "({spacing.base} + 2px) * 2"
+func ParseExpression(value string) (*Expression, error) {
+	p := &exprParser{src: value}
+	root, err := p.expr()
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpace()
+	if p.pos < len(p.src) {
+		return nil, fmt.Errorf("unexpected %q at %d", p.src[p.pos:], p.pos)
+	}
+	return &Expression{root: root}, nil
+}
+
+// References returns the token paths the expression refers to, e.g. "spacing.base"
+func (e *Expression) References() []string {
+	var refs []string
+	var walk func(node exprNode)
+	walk = func(node exprNode) {
+		switch n := node.(type) {
+		case *referenceNode:
+			refs = append(refs, n.path)
+		case *groupNode:
+			walk(n.inner)
+		case *binaryNode:
+			walk(n.left)
+			walk(n.right)
+		}
+	}
+	walk(e.root)
+	return refs
+}
+
+// Evaluate computes the expression, e.g. "16px", looking up the value of
+// each reference with lookup
+func (e *Expression) Evaluate(lookup func(ref string) (string, error)) (string, error) {
+	result, err := e.root.eval(lookup)
+	if err != nil {
+		return "", err
+	}
+	return result.String(), nil
+}
+
+// CSS writes the expression as a calc() value, e.g. "calc(8px * 2)",
+// looking up the value of each reference with lookup
+func (e *Expression) CSS(lookup func(ref string) (string, error)) (string, error) {
+	// Check the units first, since calc() would only fail in the browser
+	if _, err := e.root.eval(lookup); err != nil {
+		return "", err
+	}
+	inner, err := e.root.css(lookup)
+	if err != nil {
+		return "", err
+	}
+	return "calc(" + inner + ")", nil
+}
+
+func (n *numberNode) eval(func(string) (string, error)) (dimension, error) {
+	return n.value, nil
+}
+
+func (n *numberNode) css(func(string) (string, error)) (string, error) {
+	return n.value.String(), nil
+}
+
+func (n *referenceNode) eval(lookup func(string) (string, error)) (dimension, error) {
+	value, err := lookup(n.path)
+	if err != nil {
+		return dimension{}, err
+	}
+	d, ok := parseDimension(value)
+	if !ok {
+		return dimension{}, fmt.Errorf("{%s} is %q, not a number or dimension", n.path, value)
+	}
+	return d, nil
+}
+
+func (n *referenceNode) css(lookup func(string) (string, error)) (string, error) {
+	d, err := n.eval(lookup)
+	if err != nil {
+		return "", err
+	}
+	return d.String(), nil
+}
+
+func (n *groupNode) eval(lookup func(string) (string, error)) (dimension, error) {
+	return n.inner.eval(lookup)
+}
+
+func (n *groupNode) css(lookup func(string) (string, error)) (string, error) {
+	inner, err := n.inner.css(lookup)
+	if err != nil {
+		return "", err
+	}
+	return "(" + inner + ")", nil
+}
+
+func (n *binaryNode) eval(lookup func(string) (string, error)) (dimension, error) {
+	left, err := n.left.eval(lookup)
+	if err != nil {
+		return dimension{}, err
+	}
+	right, err := n.right.eval(lookup)
+	if err != nil {
+		return dimension{}, err
+	}
+
+	switch n.op {
+	case '+', '-':
+		if left.unit != right.unit {
+			return dimension{}, fmt.Errorf("cannot %s %s and %s: the units differ", opVerb(n.op), left, right)
+		}
+		if n.op == '-' {
+			right.n = -right.n
+		}
+		return dimension{n: left.n + right.n, unit: left.unit}, nil
+	case '*':
+		if left.unit != "" && right.unit != "" {
+			return dimension{}, fmt.Errorf("cannot multiply %s by %s: one of them must be a number", left, right)
+		}
+		return dimension{n: left.n * right.n, unit: left.unit + right.unit}, nil
+	default:
+		if right.n == 0 {
+			return dimension{}, errors.New("division by zero")
+		}
+		switch right.unit {
+		case "":
+			return dimension{n: left.n / right.n, unit: left.unit}, nil
+		case left.unit:
+			return dimension{n: left.n / right.n}, nil
+		}
+		return dimension{}, fmt.Errorf("cannot divide %s by %s", left, right)
+	}
+}
+
+func (n *binaryNode) css(lookup func(string) (string, error)) (string, error) {
+	left, err := n.left.css(lookup)
+	if err != nil {
+		return "", err
+	}
+	right, err := n.right.css(lookup)
+	if err != nil {
+		return "", err
+	}
+	return left + " " + string(n.op) + " " + right, nil
+}
+
+func opVerb(op byte) string {
+	if op == '+' {
+		return "add"
+	}
+	return "subtract"
+}
+
+// exprParser is a recursive descent parser for expressions:
+//
+//	expr   = term { ("+" | "-") term }
+//	term   = factor { ("*" | "/") factor }
+//	factor = "-" factor | "(" expr ")" | reference | number
+type exprParser struct {
+	src string
+	pos int
+}
+
+func (p *exprParser) skipSpace() {
+	for p.pos < len(p.src) && (p.src[p.pos] == ' ' || p.src[p.pos] == '\t') {
+		p.pos++
+	}
+}
+
+// peek returns the next non-space byte, or 0 at the end
+func (p *exprParser) peek() byte {
+	p.skipSpace()
+	if p.pos >= len(p.src) {
+		return 0
+	}
+	return p.src[p.pos]
+}
+
+func (p *exprParser) expr() (exprNode, error) {
+	left, err := p.term()
+	if err != nil {
+		return nil, err
+	}
+	for op := p.peek(); op == '+' || op == '-'; op = p.peek() {
+		p.pos++
+		right, err := p.term()
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryNode{op: op, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *exprParser) term() (exprNode, error) {
+	left, err := p.factor()
+	if err != nil {
+		return nil, err
+	}
+	for op := p.peek(); op == '*' || op == '/'; op = p.peek() {
+		p.pos++
+		right, err := p.factor()
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryNode{op: op, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *exprParser) factor() (exprNode, error) {
+	switch c := p.peek(); {
+	case c == 0:
+		return nil, errors.New("unexpected end of expression")
+	case c == '-':
+		p.pos++
+		operand, err := p.factor()
+		if err != nil {
+			return nil, err
+		}
+		if number, ok := operand.(*numberNode); ok {
+			number.value.n = -number.value.n
+			return number, nil
+		}
+		return &binaryNode{op: '*', left: &numberNode{value: dimension{n: -1}}, right: operand}, nil
+	case c == '(':
+		p.pos++
+		inner, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek() != ')' {
+			return nil, fmt.Errorf("missing ) at %d", p.pos)
+		}
+		p.pos++
+		return &groupNode{inner: inner}, nil
+	case c == '{':
+		end := strings.IndexByte(p.src[p.pos:], '}')
+		if end < 0 {
+			return nil, fmt.Errorf("unclosed reference at %d", p.pos)
+		}
+		path := strings.TrimSpace(p.src[p.pos+1 : p.pos+end])
+		if path == "" {
+			return nil, fmt.Errorf("empty reference at %d", p.pos)
+		}
+		p.pos += end + 1
+		return &referenceNode{path: path}, nil
+	default:
+		start := p.pos
+		for p.pos < len(p.src) && strings.IndexByte(" \t+-*/(){}", p.src[p.pos]) < 0 {
+			// Exponents such as 1e-3 contain a sign
+			if (p.src[p.pos] == 'e' || p.src[p.pos] == 'E') && p.pos+1 < len(p.src) && (p.src[p.pos+1] == '-' || p.src[p.pos+1] == '+') {
+				p.pos++
+			}
+			p.pos++
+		}
+		d, ok := parseDimension(p.src[start:p.pos])
+		if !ok {
+			return nil, fmt.Errorf("unexpected %q at %d", p.src[start:max(p.pos, start+1)], start)
+		}
+		return &numberNode{value: d}, nil
+	}
+}
+
+// ResolveExpressions computes the tokens whose value is an expression, after
+// aliases are resolved. A computed token's ResolvedValue is the result, e.g.
+// "16px", and its Value is the equivalent calc() form, e.g. "calc(8px * 2)",
+// for use as a CSS fallback. Aliases of computed tokens are computed too.
+func ResolveExpressions(all []*Token) error {
+	byPath := make(map[string]*Token, len(all))
+	for _, t := range all {
+		byPath[strings.Join(t.Path, ".")] = t
+	}
+
+	// computed memoizes results; nil marks a token being computed, to catch cycles
+	computed := map[*Token]*string{}
+	var compute func(t *Token) (string, error)
+	lookup := func(ref string) (string, error) {
+		target, ok := byPath[ref]
+		if !ok {
+			return "", fmt.Errorf("unknown token {%s}", ref)
+		}
+		return compute(target)
+	}
+	compute = func(t *Token) (string, error) {
+		if result, seen := computed[t]; seen {
+			if result == nil {
+				return "", fmt.Errorf("circular reference at %s", t.Name)
+			}
+			return *result, nil
+		}
+		e := expressionOf(t)
+		if e == nil {
+			return t.DisplayValue(), nil
+		}
+		computed[t] = nil
+		result, err := e.Evaluate(lookup)
+		if err != nil {
+			delete(computed, t)
+			return "", fmt.Errorf("cannot compute %s: %w", t.Name, err)
+		}
+		computed[t] = &result
+		return result, nil
+	}
+
+	var errs []error
+	for _, t := range all {
+		e := expressionOf(t)
+		if e == nil {
+			continue
+		}
+		result, err := compute(t)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		calc, err := e.CSS(lookup)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		t.ResolvedValue = result
+		t.IsResolved = true
+		t.Value = calc
+	}
+	return errors.Join(errs...)
+}
+
+// expressionOf returns the expression a token's value computes, or nil.
+// For an alias of a computed token, it is the aliased expression.
+func expressionOf(t *Token) *Expression {
+	value := t.RawValue
+	if s, ok := t.ResolvedValue.(string); ok && t.IsResolved && IsExpression(s) {
+		value = s
+	}
+	if !IsExpression(value) {
+		return nil
+	}
+	e, _ := ParseExpression(value.(string))
+	return e
+}
@@ -0,0 +1,129 @@
+package tokens_test
+
+import (
+	"fmt"
+	"testing"
+
+	"bennypowers.dev/dtls/internal/tokens"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsExpression(t *testing.T) {
+	tests := []struct {
+		value any
+		want  bool
+	}{
+		{"{spacing.base} * 2", true},
+		{"({spacing.base} + 2px) / 2", true},
+		{"-{spacing.base}", true},
+		{"{spacing.base}", false},
+		{"8px", false},
+		{"1px solid {color.border}", false},
+		{42.0, false},
+		{nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprint(tt.value), func(t *testing.T) {
+			assert.Equal(t, tt.want, tokens.IsExpression(tt.value))
+		})
+	}
+}
+
+func TestExpression(t *testing.T) {
+	values := map[string]string{
+		"spacing.base": "8px",
+		"ratio":        "1.5",
+		"size.rem":     "1rem",
+		"color.text":   "#000",
+	}
+	lookup := func(ref string) (string, error) {
+		value, ok := values[ref]
+		if !ok {
+			return "", fmt.Errorf("unknown token {%s}", ref)
+		}
+		return value, nil
+	}
+
+	tests := []struct {
+		expression string
+		want       string
+		css        string
+		err        string
+	}{
+		{expression: "{spacing.base} * 2", want: "16px", css: "calc(8px * 2)"},
+		{expression: "2 * {spacing.base}", want: "16px", css: "calc(2 * 8px)"},
+		{expression: "{spacing.base} + 4px * 2", want: "16px", css: "calc(8px + 4px * 2)"},
+		{expression: "({spacing.base} + 4px) * 2", want: "24px", css: "calc((8px + 4px) * 2)"},
+		{expression: "{spacing.base} / 3", want: "2.6667px", css: "calc(8px / 3)"},
+		{expression: "{spacing.base} * {ratio}", want: "12px", css: "calc(8px * 1.5)"},
+		{expression: "-{spacing.base}", want: "-8px", css: "calc(-1 * 8px)"},
+		{expression: "{spacing.base} - -2px", want: "10px", css: "calc(8px - -2px)"},
+		{expression: "{spacing.base} / 4px", want: "2", css: "calc(8px / 4px)"},
+		{expression: "{spacing.base} + {size.rem}", err: "cannot add 8px and 1rem"},
+		{expression: "{spacing.base} * {spacing.base}", err: "cannot multiply 8px by 8px"},
+		{expression: "{spacing.base} / 0", err: "division by zero"},
+		{expression: "{color.text} * 2", err: `{color.text} is "#000"`},
+		{expression: "{spacing.missing} * 2", err: "unknown token {spacing.missing}"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expression, func(t *testing.T) {
+			e, err := tokens.ParseExpression(tt.expression)
+			require.NoError(t, err)
+
+			got, err := e.Evaluate(lookup)
+			css, cssErr := e.CSS(lookup)
+			if tt.err != "" {
+				assert.ErrorContains(t, err, tt.err)
+				assert.ErrorContains(t, cssErr, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			require.NoError(t, cssErr)
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, tt.css, css)
+		})
+	}
+}
+
+func TestParseExpression_Invalid(t *testing.T) {
+	for _, expression := range []string{"{a} *", "({a} * 2", "{a} * 2)", "{a", "{} * 2", "{a} % 2", "{a} * two"} {
+		t.Run(expression, func(t *testing.T) {
+			_, err := tokens.ParseExpression(expression)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestParseExpression_References(t *testing.T) {
+	e, err := tokens.ParseExpression("({spacing.base} + {spacing.gap}) * 2")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"spacing.base", "spacing.gap"}, e.References())
+}
+
+func TestResolveExpressions(t *testing.T) {
+	base := &tokens.Token{Name: "spacing-base", Path: []string{"spacing", "base"}, Value: "8px", RawValue: "8px", Type: "dimension"}
+	double := &tokens.Token{Name: "spacing-double", Path: []string{"spacing", "double"}, Value: "{spacing.base} * 2", RawValue: "{spacing.base} * 2", Type: "dimension"}
+	quad := &tokens.Token{Name: "spacing-quad", Path: []string{"spacing", "quad"}, Value: "{spacing.double} * 2", RawValue: "{spacing.double} * 2", Type: "dimension"}
+	// An alias of a computed token, after alias resolution
+	gap := &tokens.Token{Name: "spacing-gap", Path: []string{"spacing", "gap"}, Value: "{spacing.double}", RawValue: "{spacing.double}", ResolvedValue: "{spacing.base} * 2", IsResolved: true, Type: "dimension"}
+	loopA := &tokens.Token{Name: "loop-a", Path: []string{"loop", "a"}, RawValue: "{loop.b} + 1px"}
+	loopB := &tokens.Token{Name: "loop-b", Path: []string{"loop", "b"}, RawValue: "{loop.a} + 1px"}
+
+	err := tokens.ResolveExpressions([]*tokens.Token{quad, double, base, gap, loopA, loopB})
+	assert.ErrorContains(t, err, "circular reference")
+
+	assert.Equal(t, "16px", double.ResolvedValue)
+	assert.True(t, double.IsResolved)
+	assert.Equal(t, "calc(8px * 2)", double.Value)
+	assert.Equal(t, "{spacing.base} * 2", double.RawValue, "the expression is kept")
+
+	assert.Equal(t, "32px", quad.ResolvedValue)
+	assert.Equal(t, "calc(16px * 2)", quad.Value)
+
+	assert.Equal(t, "16px", gap.ResolvedValue)
+	assert.Equal(t, "calc(8px * 2)", gap.Value)
+
+	assert.Equal(t, "8px", base.Value, "literal tokens are untouched")
+	assert.Nil(t, loopA.ResolvedValue)
+}
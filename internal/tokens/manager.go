@@ -2,8 +2,11 @@ package tokens
 
 import (
 	"fmt"
+	"maps"
+	"slices"
 	"strings"
 	"sync"
+	"sync/atomic"
 
 	"bennypowers.dev/dtls/internal/schema"
 )
@@ -29,19 +32,300 @@ import (
 //	// Two files can both define "color-primary"
 //	legacy/tokens.json:color-primary  (draft schema)
 //	design/tokens.json:color-primary  (2025.10 schema)
+//
+// Concurrency:
+// Reads never wait on writers. The tokens live in an immutable index which
+// readers load atomically; writers copy it, make their change, and publish
+// the copy (read-copy-update). Use AddBatch or ReplaceFile to load a file's
+// tokens with one copy, Batch for other sets of changes, and Replace to swap
+// in a whole token set built off to the side, so that readers see the
+// previous tokens until a reload completes.
 type Manager struct {
+	// index is the published token index. It is never modified once
+	// published, except by an owned manager (see Batch).
+	index atomic.Pointer[tokenIndex]
+
+	// mu serializes writers
+	mu sync.Mutex
+
+	// owned is set on the manager passed to a Batch callback, which no other
+	// goroutine can see, so it modifies its index in place
+	owned bool
+}
+
+// tokenIndex is a snapshot of the manager's state
+type tokenIndex struct {
 	// tokens stores design tokens using composite keys.
 	// Key format: "filePath:tokenName" for multi-file support,
 	// or just "tokenName" for legacy single-file scenarios.
 	tokens map[string]*Token
-	mu     sync.RWMutex
+
+	// files indexes tokens by source file path, then composite key, so that
+	// per-file queries don't scan every token
+	files map[string]map[string]*Token
+
+	// schemas records the schema version each source file was loaded as
+	schemas map[string]schema.SchemaVersion
+
+	// shadowed records, by source file path, the tokens replaced by a token
+	// with the same name but a different path, e.g. color.primary-dark by
+	// color.primary.dark. See Collisions.
+	shadowed map[string][]*Token
+
+	// format controls how tokens are named as CSS variables
+	format NameFormat
+
+	// vars indexes tokens by CSS variable name in format, so that Colliding
+	// doesn't format every token. Copies of the index share its slices, so
+	// they are replaced rather than modified.
+	vars map[string][]*Token
+
+	// lookup indexes the tokens for Get. It is built on first use, since
+	// writers copy the index for every change, and dropped when the tokens
+	// change.
+	lookup atomic.Pointer[tokenLookup]
+
+	// revision identifies the published index (see Revision)
+	revision uint64
+}
+
+// revisions numbers published indexes, across managers, so that a staged
+// manager's revisions differ from those of the manager it replaces
+var revisions atomic.Uint64
+
+// tokenLookup indexes tokens by name and by CSS variable name, so that Get
+// doesn't scan, or format, every token. Of several tokens sharing a name,
+// it holds one.
+type tokenLookup struct {
+	names map[string]*Token
+	vars  map[string]*Token
+}
+
+// lookupIndex returns the index's lookup, building it if needed
+func (idx *tokenIndex) lookupIndex() *tokenLookup {
+	if lookup := idx.lookup.Load(); lookup != nil {
+		return lookup
+	}
+	lookup := &tokenLookup{
+		names: make(map[string]*Token, len(idx.tokens)),
+		vars:  make(map[string]*Token, len(idx.tokens)),
+	}
+	for _, token := range idx.tokens {
+		lookup.names[token.Name] = token
+		lookup.vars[idx.format.CSSVariableName(token)] = token
+	}
+	idx.lookup.Store(lookup)
+	return lookup
+}
+
+// newTokenIndex returns an empty index
+func newTokenIndex(format NameFormat) *tokenIndex {
+	return &tokenIndex{
+		tokens:   make(map[string]*Token),
+		files:    make(map[string]map[string]*Token),
+		schemas:  make(map[string]schema.SchemaVersion),
+		shadowed: make(map[string][]*Token),
+		format:   format,
+		vars:     make(map[string][]*Token),
+	}
+}
+
+// clone returns a copy of the index which may be modified
+func (idx *tokenIndex) clone() *tokenIndex {
+	files := make(map[string]map[string]*Token, len(idx.files))
+	for path, tokens := range idx.files {
+		files[path] = maps.Clone(tokens)
+	}
+	return &tokenIndex{tokens: maps.Clone(idx.tokens), files: files, schemas: maps.Clone(idx.schemas), shadowed: maps.Clone(idx.shadowed), format: idx.format, vars: maps.Clone(idx.vars)}
+}
+
+// put stores a token under key, keeping the file index in step
+func (idx *tokenIndex) put(key string, token *Token) {
+	if existing, ok := idx.tokens[key]; ok && existing.FilePath == token.FilePath && !slices.Equal(existing.Path, token.Path) {
+		// Clip, so the append doesn't write to a slice shared with readers
+		idx.shadowed[token.FilePath] = append(slices.Clip(idx.shadowed[token.FilePath]), existing)
+	}
+	idx.delete(key)
+	idx.tokens[key] = token
+	file := idx.files[token.FilePath]
+	if file == nil {
+		file = make(map[string]*Token)
+		idx.files[token.FilePath] = file
+	}
+	file[key] = token
+	name := idx.format.CSSVariableName(token)
+	idx.vars[name] = append(slices.Clip(idx.vars[name]), token)
+	idx.lookup.Store(nil)
+}
+
+// delete removes the token stored under key, if any
+func (idx *tokenIndex) delete(key string) {
+	token, ok := idx.tokens[key]
+	if !ok {
+		return
+	}
+	delete(idx.tokens, key)
+	idx.deleteVar(token)
+	idx.lookup.Store(nil)
+	if file := idx.files[token.FilePath]; file != nil {
+		delete(file, key)
+		if len(file) == 0 {
+			delete(idx.files, token.FilePath)
+		}
+	}
+}
+
+// deleteFile removes every token from a source file.
+// Returns the number of tokens removed.
+func (idx *tokenIndex) deleteFile(filePath string) int {
+	file := idx.files[filePath]
+	for key, token := range file {
+		delete(idx.tokens, key)
+		idx.deleteVar(token)
+	}
+	idx.lookup.Store(nil)
+	delete(idx.files, filePath)
+	delete(idx.schemas, filePath)
+	delete(idx.shadowed, filePath)
+	return len(file)
+}
+
+// deleteVar removes a token from the CSS variable name index
+func (idx *tokenIndex) deleteVar(token *Token) {
+	name := idx.format.CSSVariableName(token)
+	// Clone, so the delete doesn't modify a slice shared with readers
+	tokens := slices.DeleteFunc(slices.Clone(idx.vars[name]), func(t *Token) bool { return t == token })
+	if len(tokens) == 0 {
+		delete(idx.vars, name)
+		return
+	}
+	idx.vars[name] = tokens
+}
+
+// setFormat changes the index's name format, reindexing the tokens by their
+// CSS variable names in it
+func (idx *tokenIndex) setFormat(format NameFormat) {
+	idx.format = format
+	idx.vars = make(map[string][]*Token, len(idx.tokens))
+	for _, token := range idx.tokens {
+		name := format.CSSVariableName(token)
+		idx.vars[name] = append(idx.vars[name], token)
+	}
+	idx.lookup.Store(nil)
 }
 
 // NewManager creates a new token manager with an empty token registry.
 func NewManager() *Manager {
-	return &Manager{
-		tokens: make(map[string]*Token),
+	m := &Manager{}
+	m.publish(newTokenIndex(NameFormat{}))
+	return m
+}
+
+// publish makes idx the manager's index, with a new revision
+func (m *Manager) publish(idx *tokenIndex) {
+	idx.revision = revisions.Add(1)
+	m.index.Store(idx)
+}
+
+// Revision returns a number which changes whenever the tokens or their name
+// format do, for caching what is derived from them
+func (m *Manager) Revision() uint64 {
+	return m.load().revision
+}
+
+// load returns the current index for reading
+func (m *Manager) load() *tokenIndex {
+	return m.index.Load()
+}
+
+// update applies change to a copy of the index and publishes the copy
+func (m *Manager) update(change func(idx *tokenIndex)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	idx := m.index.Load()
+	if !m.owned {
+		idx = idx.clone()
+	}
+	change(idx)
+	m.publish(idx)
+}
+
+// Batch makes several changes with a single copy of the index. fn receives a
+// manager holding a private copy of the tokens; when fn returns, the copy
+// replaces the manager's tokens. Readers see either none or all of the
+// batch. The batch manager must not be used after fn returns, and other
+// writes to m wait until it does.
+func (m *Manager) Batch(fn func(batch *Manager)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	batch := &Manager{owned: true}
+	batch.index.Store(m.index.Load().clone())
+	fn(batch)
+	m.publish(batch.index.Load())
+}
+
+// AddBatch adds or updates several tokens in one step, so that loading a
+// large file copies the index once. Readers see all of the tokens or none.
+// No tokens are added if any is nil.
+func (m *Manager) AddBatch(tokens []*Token) error {
+	for i, token := range tokens {
+		if token == nil {
+			return fmt.Errorf("token %d cannot be nil", i)
+		}
 	}
+
+	m.Batch(func(batch *Manager) {
+		for _, token := range tokens {
+			_ = batch.Add(token)
+		}
+	})
+	return nil
+}
+
+// ReplaceFile replaces all tokens from a source file with tokens in one
+// step, so readers never see the file half loaded, and tokens removed from
+// the file since it was last loaded don't linger. Every token must belong
+// to filePath; otherwise nothing changes.
+func (m *Manager) ReplaceFile(filePath string, tokens []*Token) error {
+	for i, token := range tokens {
+		if token == nil {
+			return fmt.Errorf("token %d cannot be nil", i)
+		}
+		if token.FilePath != filePath {
+			return fmt.Errorf("token %s belongs to %q, not %q", token.Name, token.FilePath, filePath)
+		}
+	}
+
+	m.Batch(func(batch *Manager) {
+		batch.RemoveBySourceFile(filePath)
+		for _, token := range tokens {
+			_ = batch.Add(token)
+		}
+	})
+	return nil
+}
+
+// Staged returns an empty manager with the same name format, for building a
+// replacement token set while readers keep using this one. Publish it with Replace.
+func (m *Manager) Staged() *Manager {
+	staged := NewManager()
+	staged.SetNameFormat(m.NameFormat())
+	return staged
+}
+
+// Replace swaps in the tokens of staged in one step
+func (m *Manager) Replace(staged *Manager) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	next := staged.load()
+	idx := &tokenIndex{tokens: next.tokens, files: next.files, schemas: next.schemas, shadowed: next.shadowed, format: next.format, vars: next.vars}
+	if format := m.index.Load().format; format != next.format {
+		idx.setFormat(format)
+	}
+	m.publish(idx)
 }
 
 // makeKey creates a composite key for token storage.
@@ -74,11 +358,10 @@ func (m *Manager) Add(token *Token) error {
 		return fmt.Errorf("token cannot be nil")
 	}
 
-	m.mu.Lock()
-	defer m.mu.Unlock()
-
 	key := makeKey(token.FilePath, token.Name)
-	m.tokens[key] = token
+	m.update(func(idx *tokenIndex) {
+		idx.put(key, token)
+	})
 	return nil
 }
 
@@ -90,11 +373,10 @@ func (m *Manager) Add(token *Token) error {
 // - "--color-primary" (CSS variable without prefix)
 // - "--prefix-color-primary" (CSS variable with prefix)
 func (m *Manager) Get(nameOrVar string) *Token {
-	m.mu.RLock()
-	defer m.mu.RUnlock()
+	idx := m.load()
 
 	// Try direct lookup first (legacy single-token case)
-	if token, exists := m.tokens[nameOrVar]; exists {
+	if token, exists := idx.tokens[nameOrVar]; exists {
 		return token
 	}
 
@@ -107,29 +389,70 @@ func (m *Manager) Get(nameOrVar string) *Token {
 	// Strip -- prefix if present
 	searchName = strings.TrimPrefix(searchName, "--")
 
-	// Search across all files for token with matching name
-	for key, token := range m.tokens {
-		// Check if key ends with :tokenName
-		if strings.HasSuffix(key, ":"+searchName) || key == searchName {
-			return token
-		}
+	// Find a token with matching name in any file, or with matching CSS
+	// variable name
+	lookup := idx.lookupIndex()
+	if token, ok := lookup.names[searchName]; ok {
+		return token
+	}
+	return lookup.vars[nameOrVar]
+}
 
-		// Also check CSS variable name match
-		if token.CSSVariableName() == nameOrVar {
-			return token
-		}
+// SetNameFormat sets how tokens are named as CSS variables
+func (m *Manager) SetNameFormat(format NameFormat) {
+	m.update(func(idx *tokenIndex) {
+		idx.setFormat(format)
+	})
+}
+
+// NameFormat returns the current CSS variable name format
+func (m *Manager) NameFormat() NameFormat {
+	idx := m.load()
+
+	return idx.format
+}
+
+// CSSVariableName returns the CSS variable name for a token
+// according to the manager's name format
+func (m *Manager) CSSVariableName(token *Token) string {
+	return m.NameFormat().CSSVariableName(token)
+}
+
+// DisplayName returns the name shown for a token in UI strings such as hover
+// titles and completion labels: the CSS variable name, optionally without its prefix
+func (m *Manager) DisplayName(token *Token, showPrefix bool) string {
+	if showPrefix {
+		return m.CSSVariableName(token)
+	}
+	return m.NameFormat().UnprefixedName(token)
+}
+
+// Colliding returns a loaded token with a different name that formats to
+// the same CSS variable name as token, or nil if there is none.
+// Tokens sharing a name across files are not collisions.
+func (m *Manager) Colliding(token *Token) *Token {
+	idx := m.load()
+
+	// Only custom formats are checked: they can fold previously distinct
+	// names together (e.g. camelCase merges "fooBar" and "foo-bar")
+	if token == nil || idx.format.isStandard() {
+		return nil
 	}
 
+	for _, existing := range idx.vars[idx.format.CSSVariableName(token)] {
+		if existing.Name != token.Name {
+			return existing
+		}
+	}
 	return nil
 }
 
 // GetAll returns all tokens
 func (m *Manager) GetAll() []*Token {
-	m.mu.RLock()
-	defer m.mu.RUnlock()
+	idx := m.load()
 
-	tokens := make([]*Token, 0, len(m.tokens))
-	for _, token := range m.tokens {
+	tokens := make([]*Token, 0, len(idx.tokens))
+	for _, token := range idx.tokens {
 		tokens = append(tokens, token)
 	}
 	return tokens
@@ -138,51 +461,57 @@ func (m *Manager) GetAll() []*Token {
 // Remove removes a token by name
 // For multi-file scenarios, use RemoveBySourceFile or provide the full composite key
 func (m *Manager) Remove(name string) error {
-	m.mu.Lock()
-	defer m.mu.Unlock()
+	err := fmt.Errorf("token not found: %s", name)
+	m.update(func(idx *tokenIndex) {
+		// Try direct lookup first (legacy or composite key)
+		if _, exists := idx.tokens[name]; exists {
+			idx.delete(name)
+			err = nil
+			return
+		}
 
-	// Try direct lookup first (legacy or composite key)
-	if _, exists := m.tokens[name]; !exists {
 		// Search across all files for matching token
 		// Use exact segment matching to avoid partial matches
-		for key, token := range m.tokens {
+		for key, token := range idx.tokens {
 			// Check if the token name (after the last ':') exactly matches
 			lastColon := strings.LastIndex(key, ":")
 			if lastColon != -1 {
 				// Composite key: extract token name after ':'
 				tokenNameInKey := key[lastColon+1:]
 				if tokenNameInKey == name {
-					delete(m.tokens, key)
-					return nil
+					idx.delete(key)
+					err = nil
+					return
 				}
 			} else if token.Name == name {
 				// Legacy key without file path
-				delete(m.tokens, key)
-				return nil
+				idx.delete(key)
+				err = nil
+				return
 			}
 		}
-		return fmt.Errorf("token not found: %s", name)
-	}
-
-	delete(m.tokens, name)
-	return nil
+	})
+	return err
 }
 
 // Clear removes all tokens
 func (m *Manager) Clear() {
-	m.mu.Lock()
-	defer m.mu.Unlock()
-
-	m.tokens = make(map[string]*Token)
+	m.update(func(idx *tokenIndex) {
+		idx.tokens = make(map[string]*Token)
+		idx.files = make(map[string]map[string]*Token)
+		idx.schemas = make(map[string]schema.SchemaVersion)
+		idx.shadowed = make(map[string][]*Token)
+		idx.vars = make(map[string][]*Token)
+		idx.lookup.Store(nil)
+	})
 }
 
 // FindByPrefix returns all tokens whose names start with the given prefix
 func (m *Manager) FindByPrefix(prefix string) []*Token {
-	m.mu.RLock()
-	defer m.mu.RUnlock()
+	idx := m.load()
 
 	matches := []*Token{}
-	for _, token := range m.tokens {
+	for _, token := range idx.tokens {
 		if strings.HasPrefix(token.Name, prefix) {
 			matches = append(matches, token)
 		}
@@ -192,19 +521,17 @@ func (m *Manager) FindByPrefix(prefix string) []*Token {
 
 // Count returns the number of tokens
 func (m *Manager) Count() int {
-	m.mu.RLock()
-	defer m.mu.RUnlock()
+	idx := m.load()
 
-	return len(m.tokens)
+	return len(idx.tokens)
 }
 
 // GetBySchemaVersion returns all tokens for a specific schema version
 func (m *Manager) GetBySchemaVersion(version schema.SchemaVersion) []*Token {
-	m.mu.RLock()
-	defer m.mu.RUnlock()
+	idx := m.load()
 
 	matches := []*Token{}
-	for _, token := range m.tokens {
+	for _, token := range idx.tokens {
 		if token.SchemaVersion == version {
 			matches = append(matches, token)
 		}
@@ -214,14 +541,11 @@ func (m *Manager) GetBySchemaVersion(version schema.SchemaVersion) []*Token {
 
 // GetBySourceFile returns all tokens from a specific source file
 func (m *Manager) GetBySourceFile(filePath string) []*Token {
-	m.mu.RLock()
-	defer m.mu.RUnlock()
+	idx := m.load()
 
-	matches := []*Token{}
-	for _, token := range m.tokens {
-		if token.FilePath == filePath {
-			matches = append(matches, token)
-		}
+	matches := make([]*Token, 0, len(idx.files[filePath]))
+	for _, token := range idx.files[filePath] {
+		matches = append(matches, token)
 	}
 	return matches
 }
@@ -229,70 +553,101 @@ func (m *Manager) GetBySourceFile(filePath string) []*Token {
 // GetQualified retrieves a token by name and file path
 // This allows resolving ambiguous token names when multiple files define the same token
 func (m *Manager) GetQualified(tokenName, filePath string) *Token {
-	m.mu.RLock()
-	defer m.mu.RUnlock()
+	idx := m.load()
 
 	key := makeKey(filePath, tokenName)
-	return m.tokens[key]
+	return idx.tokens[key]
 }
 
 // RemoveBySourceFile removes all tokens from a specific source file
 // Returns the number of tokens removed
 func (m *Manager) RemoveBySourceFile(filePath string) int {
-	m.mu.Lock()
-	defer m.mu.Unlock()
-
 	removed := 0
-	for key, token := range m.tokens {
-		if token.FilePath == filePath {
-			delete(m.tokens, key)
-			removed++
-		}
-	}
+	m.update(func(idx *tokenIndex) {
+		removed = idx.deleteFile(filePath)
+	})
 	return removed
 }
 
+// RenameSourceFile moves all tokens from one source file to another, updating
+// their FilePath, DefinitionURI, and composite keys.
+// Returns the number of tokens moved
+func (m *Manager) RenameSourceFile(oldPath, newPath, newURI string) int {
+	moved := 0
+	m.update(func(idx *tokenIndex) {
+		var tokens []*Token
+		for _, token := range idx.files[oldPath] {
+			tokens = append(tokens, token)
+		}
+		version, recorded := idx.schemas[oldPath]
+		shadowed := idx.shadowed[oldPath]
+		idx.deleteFile(oldPath)
+		if recorded {
+			idx.schemas[newPath] = version
+		}
+		for _, token := range shadowed {
+			renamed := *token
+			renamed.FilePath = newPath
+			renamed.DefinitionURI = newURI
+			idx.shadowed[newPath] = append(slices.Clip(idx.shadowed[newPath]), &renamed)
+		}
+		for _, token := range tokens {
+			// Published tokens are shared with readers, so move a copy
+			renamed := *token
+			renamed.FilePath = newPath
+			renamed.DefinitionURI = newURI
+			idx.put(makeKey(newPath, renamed.Name), &renamed)
+		}
+		moved = len(tokens)
+	})
+	return moved
+}
+
 // GetSourceFiles returns a list of all unique source files that have tokens loaded
 func (m *Manager) GetSourceFiles() []string {
-	m.mu.RLock()
-	defer m.mu.RUnlock()
+	idx := m.load()
 
-	files := make(map[string]bool)
-	for _, token := range m.tokens {
-		if token.FilePath != "" {
-			files[token.FilePath] = true
+	result := make([]string, 0, len(idx.files))
+	for file := range idx.files {
+		if file != "" {
+			result = append(result, file)
 		}
 	}
-
-	result := make([]string, 0, len(files))
-	for file := range files {
-		result = append(result, file)
-	}
 	return result
 }
 
-// GetSchemaVersionForFile returns the schema version used by a specific file
+// SetSchemaVersionForFile records the schema version a source file was
+// loaded as. The record is dropped with the file's tokens, so set it after
+// replacing them.
+func (m *Manager) SetSchemaVersionForFile(filePath string, version schema.SchemaVersion) {
+	m.update(func(idx *tokenIndex) {
+		idx.schemas[filePath] = version
+	})
+}
+
+// GetSchemaVersionForFile returns the schema version used by a specific file:
+// the one recorded by SetSchemaVersionForFile, or else the one its tokens share.
 // Returns Unknown if the file has no tokens, doesn't exist, or has inconsistent schema versions.
 // All tokens from the same file should have the same schema version; if they don't,
 // this indicates a parsing bug and Unknown is returned.
 func (m *Manager) GetSchemaVersionForFile(filePath string) schema.SchemaVersion {
-	m.mu.RLock()
-	defer m.mu.RUnlock()
+	idx := m.load()
+	if version, ok := idx.schemas[filePath]; ok {
+		return version
+	}
 
 	var version schema.SchemaVersion
 	found := false
 
-	for _, token := range m.tokens {
-		if token.FilePath == filePath {
-			if !found {
-				// First token from this file
-				version = token.SchemaVersion
-				found = true
-			} else if token.SchemaVersion != version {
-				// Inconsistency detected - this should not happen
-				// Return Unknown to signal an error condition
-				return schema.Unknown
-			}
+	for _, token := range idx.files[filePath] {
+		if !found {
+			// First token from this file
+			version = token.SchemaVersion
+			found = true
+		} else if token.SchemaVersion != version {
+			// Inconsistency detected - this should not happen
+			// Return Unknown to signal an error condition
+			return schema.Unknown
 		}
 	}
 
@@ -253,3 +253,28 @@ func TestManager_MultiSchema_GetSchemaVersionForFile(t *testing.T) {
 	version = manager.GetSchemaVersionForFile("nonexistent.json")
 	assert.Equal(t, schema.Unknown, version)
 }
+
+func TestManager_MultiSchema_RecordedFileVersion(t *testing.T) {
+	manager := tokens.NewManager()
+	require.NoError(t, manager.Add(&tokens.Token{Name: "size", Value: "4", FilePath: "/a.json", SchemaVersion: schema.Draft}))
+
+	// The recorded version wins over the tokens'
+	manager.SetSchemaVersionForFile("/a.json", schema.V2025_10)
+	assert.Equal(t, schema.V2025_10, manager.GetSchemaVersionForFile("/a.json"))
+
+	// Files without tokens keep their record, e.g. empty files
+	manager.SetSchemaVersionForFile("/empty.json", schema.V2025_10)
+	assert.Equal(t, schema.V2025_10, manager.GetSchemaVersionForFile("/empty.json"))
+
+	// Renaming moves the record
+	manager.RenameSourceFile("/a.json", "/b.json", "file:///b.json")
+	assert.Equal(t, schema.V2025_10, manager.GetSchemaVersionForFile("/b.json"))
+	assert.Equal(t, schema.Unknown, manager.GetSchemaVersionForFile("/a.json"))
+
+	// Removing the file's tokens drops it
+	manager.RemoveBySourceFile("/b.json")
+	assert.Equal(t, schema.Unknown, manager.GetSchemaVersionForFile("/b.json"))
+
+	manager.Clear()
+	assert.Equal(t, schema.Unknown, manager.GetSchemaVersionForFile("/empty.json"))
+}
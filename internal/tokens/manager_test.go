@@ -2,6 +2,7 @@ package tokens_test
 
 import (
 	"fmt"
+	"sync"
 	"testing"
 
 	"bennypowers.dev/dtls/internal/schema"
@@ -292,3 +293,181 @@ func TestManager_MultiFile_CorrectCount(t *testing.T) {
 	actualCount := m.Count()
 	assert.Equal(t, expectedCount, actualCount, "Expected %d tokens, got %d", expectedCount, actualCount)
 }
+
+// TestManager_RenameSourceFile verifies tokens are rekeyed under the new file path
+func TestManager_RenameSourceFile(t *testing.T) {
+	m := tokens.NewManager()
+	require.NoError(t, m.Add(&tokens.Token{Name: "color-primary", Value: "#f00", FilePath: "/ws/old.json", DefinitionURI: "file:///ws/old.json"}))
+	require.NoError(t, m.Add(&tokens.Token{Name: "color-secondary", Value: "#0f0", FilePath: "/ws/old.json", DefinitionURI: "file:///ws/old.json"}))
+	require.NoError(t, m.Add(&tokens.Token{Name: "color-primary", Value: "#00f", FilePath: "/ws/other.json"}))
+
+	moved := m.RenameSourceFile("/ws/old.json", "/ws/new.json", "file:///ws/new.json")
+	assert.Equal(t, 2, moved)
+	assert.Equal(t, 3, m.Count())
+
+	assert.Nil(t, m.GetQualified("color-primary", "/ws/old.json"))
+	token := m.GetQualified("color-primary", "/ws/new.json")
+	require.NotNil(t, token)
+	assert.Equal(t, "#f00", token.Value)
+	assert.Equal(t, "/ws/new.json", token.FilePath)
+	assert.Equal(t, "file:///ws/new.json", token.DefinitionURI)
+	assert.Len(t, m.GetBySourceFile("/ws/new.json"), 2)
+	assert.Len(t, m.GetBySourceFile("/ws/other.json"), 1)
+
+	assert.Equal(t, 0, m.RenameSourceFile("/ws/missing.json", "/ws/x.json", "file:///ws/x.json"))
+}
+
+// TestManager_Batch verifies batched changes are published together
+func TestManager_Batch(t *testing.T) {
+	m := tokens.NewManager()
+	require.NoError(t, m.Add(&tokens.Token{Name: "color-primary", Value: "#f00"}))
+
+	m.Batch(func(batch *tokens.Manager) {
+		require.NoError(t, batch.Add(&tokens.Token{Name: "color-secondary", Value: "#0f0"}))
+		require.NoError(t, batch.Add(&tokens.Token{Name: "color-tertiary", Value: "#00f"}))
+		require.NoError(t, batch.Remove("color-primary"))
+
+		// The batch sees its own changes, readers of m don't yet
+		assert.Equal(t, 2, batch.Count())
+		assert.Equal(t, 1, m.Count())
+		assert.NotNil(t, m.Get("color-primary"))
+	})
+
+	assert.Equal(t, 2, m.Count())
+	assert.Nil(t, m.Get("color-primary"))
+	assert.NotNil(t, m.Get("color-tertiary"))
+}
+
+// TestManager_AddBatch verifies a batch of tokens is added in one step
+func TestManager_AddBatch(t *testing.T) {
+	m := tokens.NewManager()
+	require.NoError(t, m.AddBatch([]*tokens.Token{
+		{Name: "color-primary", Value: "#f00", FilePath: "/a.json"},
+		{Name: "color-secondary", Value: "#0f0", FilePath: "/a.json"},
+		{Name: "space-small", Value: "4px", FilePath: "/b.json"},
+	}))
+	assert.Equal(t, 3, m.Count())
+	assert.Len(t, m.GetBySourceFile("/a.json"), 2)
+	assert.ElementsMatch(t, []string{"/a.json", "/b.json"}, m.GetSourceFiles())
+
+	err := m.AddBatch([]*tokens.Token{{Name: "space-large", FilePath: "/b.json"}, nil})
+	require.Error(t, err)
+	assert.Equal(t, 3, m.Count(), "nothing is added when a token is invalid")
+}
+
+// TestManager_ReplaceFile verifies a file's tokens are swapped without
+// touching other files
+func TestManager_ReplaceFile(t *testing.T) {
+	m := tokens.NewManager()
+	require.NoError(t, m.AddBatch([]*tokens.Token{
+		{Name: "color-primary", Value: "#f00", FilePath: "/a.json"},
+		{Name: "color-removed", Value: "#0f0", FilePath: "/a.json"},
+		{Name: "space-small", Value: "4px", FilePath: "/b.json"},
+	}))
+
+	require.NoError(t, m.ReplaceFile("/a.json", []*tokens.Token{
+		{Name: "color-primary", Value: "#00f", FilePath: "/a.json"},
+		{Name: "color-added", Value: "#fff", FilePath: "/a.json"},
+	}))
+	assert.Equal(t, 3, m.Count())
+	assert.Equal(t, "#00f", m.GetQualified("color-primary", "/a.json").Value)
+	assert.Nil(t, m.GetQualified("color-removed", "/a.json"))
+	assert.NotNil(t, m.GetQualified("color-added", "/a.json"))
+	assert.NotNil(t, m.GetQualified("space-small", "/b.json"))
+
+	err := m.ReplaceFile("/a.json", []*tokens.Token{{Name: "space-large", FilePath: "/b.json"}})
+	require.Error(t, err)
+	assert.Len(t, m.GetBySourceFile("/a.json"), 2, "nothing changes when a token belongs to another file")
+
+	require.NoError(t, m.ReplaceFile("/a.json", nil))
+	assert.Empty(t, m.GetBySourceFile("/a.json"))
+	assert.Equal(t, []string{"/b.json"}, m.GetSourceFiles())
+}
+
+// TestManager_StagedReplace verifies readers see the previous tokens until a
+// staged token set replaces them
+func TestManager_StagedReplace(t *testing.T) {
+	m := tokens.NewManager()
+	m.SetNameFormat(tokens.NameFormat{Case: tokens.NameCaseCamel})
+	require.NoError(t, m.Add(&tokens.Token{Name: "color-old", Value: "#f00"}))
+
+	staged := m.Staged()
+	assert.Equal(t, m.NameFormat(), staged.NameFormat())
+	assert.Equal(t, 0, staged.Count())
+
+	require.NoError(t, staged.Add(&tokens.Token{Name: "color-new", Value: "#0f0"}))
+	assert.NotNil(t, m.Get("color-old"))
+	assert.Nil(t, m.Get("color-new"))
+
+	m.Replace(staged)
+	assert.Nil(t, m.Get("color-old"))
+	assert.NotNil(t, m.Get("color-new"))
+	assert.Equal(t, tokens.NameCaseCamel, m.NameFormat().Case)
+
+	// Later writes to either manager don't leak into the other
+	require.NoError(t, staged.Add(&tokens.Token{Name: "color-later", Value: "#00f"}))
+	assert.Nil(t, m.Get("color-later"))
+}
+
+func TestManager_Revision(t *testing.T) {
+	m := tokens.NewManager()
+	seen := map[uint64]bool{m.Revision(): true}
+	changed := func(msg string) {
+		t.Helper()
+		assert.False(t, seen[m.Revision()], msg)
+		seen[m.Revision()] = true
+	}
+
+	require.NoError(t, m.Add(&tokens.Token{Name: "color-primary", Value: "#f00"}))
+	changed("adding a token")
+	m.SetNameFormat(tokens.NameFormat{Case: tokens.NameCaseCamel})
+	changed("changing the name format")
+	require.NoError(t, m.ReplaceFile("/tokens.json", []*tokens.Token{{Name: "color-secondary", Value: "#0f0", FilePath: "/tokens.json"}}))
+	changed("loading a file")
+
+	staged := m.Staged()
+	assert.False(t, seen[staged.Revision()], "managers don't share revisions")
+	m.Replace(staged)
+	changed("replacing the tokens")
+
+	revision := m.Revision()
+	m.Get("color-primary")
+	assert.Equal(t, revision, m.Revision(), "reading doesn't change the revision")
+}
+
+// TestManager_ReadersDuringWrites verifies readers never see a token set
+// torn by a concurrent batch. Run with -race.
+func TestManager_ReadersDuringWrites(t *testing.T) {
+	m := tokens.NewManager()
+	const perBatch = 10
+
+	var wg sync.WaitGroup
+	done := make(chan struct{})
+	for range 4 {
+		wg.Go(func() {
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				assert.Zero(t, m.Count()%perBatch, "saw a partial batch")
+				for _, token := range m.GetAll() {
+					_ = m.CSSVariableName(token)
+				}
+			}
+		})
+	}
+
+	for i := range 50 {
+		m.Batch(func(batch *tokens.Manager) {
+			for j := range perBatch {
+				require.NoError(t, batch.Add(&tokens.Token{Name: fmt.Sprintf("token-%d-%d", i, j)}))
+			}
+		})
+	}
+	close(done)
+	wg.Wait()
+
+	assert.Equal(t, 50*perBatch, m.Count())
+}
@@ -0,0 +1,174 @@
+package tokens
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// NameCase controls how token path segments are cased in CSS variable names
+type NameCase string
+
+const (
+	// NameCasePreserve keeps path segments as written in the token file (default)
+	NameCasePreserve NameCase = ""
+	// NameCaseKebab lowercases each segment and joins its words with "-"
+	NameCaseKebab NameCase = "kebab"
+	// NameCaseCamel joins all words into a single camelCase identifier
+	// (the separator is ignored)
+	NameCaseCamel NameCase = "camel"
+)
+
+// CollisionPolicy controls what happens when two different tokens
+// format to the same CSS variable name
+type CollisionPolicy string
+
+const (
+	// CollisionWarn keeps both tokens and logs a warning (default)
+	CollisionWarn CollisionPolicy = "warn"
+	// CollisionFirst keeps the first token loaded and skips later ones
+	CollisionFirst CollisionPolicy = "first"
+	// CollisionError skips later tokens and reports an error
+	CollisionError CollisionPolicy = "error"
+)
+
+// DefaultSeparator joins the prefix and path segments of a CSS variable name
+const DefaultSeparator = "-"
+
+// NameFormat describes how token paths are turned into CSS variable names,
+// so that names match what a team's build pipeline emits.
+//
+// The zero value reproduces the standard naming: "--prefix-path-to-token".
+type NameFormat struct {
+	// Separator joins the prefix and path segments (default "-")
+	Separator string
+
+	// Case controls segment casing
+	Case NameCase
+
+	// Collisions controls what happens when two tokens format to the same name
+	Collisions CollisionPolicy
+}
+
+// Validate reports an error for unknown cases or collision policies
+func (f NameFormat) Validate() error {
+	switch f.Case {
+	case NameCasePreserve, NameCaseKebab, NameCaseCamel:
+	default:
+		return fmt.Errorf("unknown name case %q (valid: kebab, camel)", f.Case)
+	}
+	switch f.Collisions {
+	case "", CollisionWarn, CollisionFirst, CollisionError:
+	default:
+		return fmt.Errorf("unknown collision policy %q (valid: warn, first, error)", f.Collisions)
+	}
+	return nil
+}
+
+// isStandard reports whether the format produces the standard token naming
+func (f NameFormat) isStandard() bool {
+	return f.Case == NameCasePreserve && (f.Separator == "" || f.Separator == DefaultSeparator)
+}
+
+// CSSVariableName returns the CSS custom property name for a token
+// (e.g. "--ds-color-primary") according to the format.
+func (f NameFormat) CSSVariableName(token *Token) string {
+	return f.format(token, true)
+}
+
+// UnprefixedName returns the CSS variable name for a token without its prefix
+// (e.g. "--color-primary" for "--ds-color-primary"), for display in UI strings.
+func (f NameFormat) UnprefixedName(token *Token) string {
+	return f.format(token, false)
+}
+
+// PathName returns the CSS variable name the format gives the token path
+// with the given prefix. Groups have no Token, so this names them as if
+// they were tokens, e.g. "--ds-color" for the color group.
+func (f NameFormat) PathName(prefix string, path []string) string {
+	return f.CSSVariableName(&Token{Name: strings.Join(path, "-"), Path: path, Prefix: prefix})
+}
+
+func (f NameFormat) format(token *Token, withPrefix bool) string {
+	if token == nil || token.Name == "" {
+		return ""
+	}
+	if f.isStandard() && (withPrefix || token.Prefix == "") {
+		return token.CSSVariableName()
+	}
+
+	var segments []string
+	if withPrefix && token.Prefix != "" {
+		segments = append(segments, strings.Split(token.Prefix, ".")...)
+	}
+	if len(token.Path) > 0 {
+		segments = append(segments, token.Path...)
+	} else {
+		segments = append(segments, strings.Split(token.Name, "-")...)
+	}
+
+	separator := f.Separator
+	if separator == "" {
+		separator = DefaultSeparator
+	}
+
+	switch f.Case {
+	case NameCaseCamel:
+		var b strings.Builder
+		for _, segment := range segments {
+			for _, word := range splitWords(segment) {
+				if b.Len() == 0 {
+					b.WriteString(strings.ToLower(word))
+				} else {
+					b.WriteString(capitalize(word))
+				}
+			}
+		}
+		return "--" + b.String()
+	case NameCaseKebab:
+		formatted := make([]string, 0, len(segments))
+		for _, segment := range segments {
+			formatted = append(formatted, strings.ToLower(strings.Join(splitWords(segment), "-")))
+		}
+		return "--" + strings.Join(formatted, separator)
+	default:
+		return "--" + strings.Join(segments, separator)
+	}
+}
+
+// splitWords splits a path segment into words at "-", "_", spaces,
+// and lower-to-upper case boundaries ("brandPrimary" -> "brand", "Primary")
+func splitWords(segment string) []string {
+	var words []string
+	var current []rune
+	flush := func() {
+		if len(current) > 0 {
+			words = append(words, string(current))
+			current = current[:0]
+		}
+	}
+
+	runes := []rune(segment)
+	for i, r := range runes {
+		switch {
+		case r == '-' || r == '_' || unicode.IsSpace(r):
+			flush()
+			continue
+		case unicode.IsUpper(r) && i > 0 && unicode.IsLower(runes[i-1]):
+			flush()
+		}
+		current = append(current, r)
+	}
+	flush()
+
+	return words
+}
+
+// capitalize uppercases the first letter of a word and lowercases the rest
+func capitalize(word string) string {
+	runes := []rune(strings.ToLower(word))
+	if len(runes) > 0 {
+		runes[0] = unicode.ToUpper(runes[0])
+	}
+	return string(runes)
+}
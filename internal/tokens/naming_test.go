@@ -0,0 +1,126 @@
+package tokens_test
+
+import (
+	"testing"
+
+	"bennypowers.dev/dtls/internal/tokens"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNameFormat_CSSVariableName(t *testing.T) {
+	token := &tokens.Token{
+		Name:   "color-brandPrimary-base",
+		Path:   []string{"color", "brandPrimary", "base"},
+		Prefix: "ds",
+	}
+
+	tests := []struct {
+		name   string
+		format tokens.NameFormat
+		want   string
+	}{
+		{"default", tokens.NameFormat{}, token.CSSVariableName()},
+		{"explicit hyphen separator", tokens.NameFormat{Separator: "-"}, token.CSSVariableName()},
+		{"underscore separator", tokens.NameFormat{Separator: "_"}, "--ds_color_brandPrimary_base"},
+		{"kebab", tokens.NameFormat{Case: tokens.NameCaseKebab}, "--ds-color-brand-primary-base"},
+		{"kebab with underscore", tokens.NameFormat{Case: tokens.NameCaseKebab, Separator: "_"}, "--ds_color_brand-primary_base"},
+		{"camel", tokens.NameFormat{Case: tokens.NameCaseCamel}, "--dsColorBrandPrimaryBase"},
+		{"camel ignores separator", tokens.NameFormat{Case: tokens.NameCaseCamel, Separator: "_"}, "--dsColorBrandPrimaryBase"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.format.CSSVariableName(token))
+		})
+	}
+}
+
+func TestNameFormat_CSSVariableName_WithoutPath(t *testing.T) {
+	token := &tokens.Token{Name: "spacing-large"}
+	format := tokens.NameFormat{Separator: "_"}
+	assert.Equal(t, "--spacing_large", format.CSSVariableName(token))
+	assert.Empty(t, format.CSSVariableName(nil))
+}
+
+func TestNameFormat_UnprefixedName(t *testing.T) {
+	token := &tokens.Token{Name: "color-primary", Path: []string{"color", "primary"}, Prefix: "ds"}
+
+	assert.Equal(t, "--color-primary", tokens.NameFormat{}.UnprefixedName(token))
+	assert.Equal(t, "--colorPrimary", tokens.NameFormat{Case: tokens.NameCaseCamel}.UnprefixedName(token))
+
+	unprefixed := &tokens.Token{Name: "spacing-large", Path: []string{"spacing", "large"}}
+	assert.Equal(t, unprefixed.CSSVariableName(), tokens.NameFormat{}.UnprefixedName(unprefixed))
+}
+
+func TestNameFormat_PathName(t *testing.T) {
+	assert.Equal(t, "--ds-color-brand", tokens.NameFormat{}.PathName("ds", []string{"color", "brand"}))
+	assert.Equal(t, "--color", tokens.NameFormat{}.PathName("", []string{"color"}))
+	assert.Equal(t, "--dsColorBrand", tokens.NameFormat{Case: tokens.NameCaseCamel}.PathName("ds", []string{"color", "brand"}))
+}
+
+func TestManager_DisplayName(t *testing.T) {
+	manager := tokens.NewManager()
+	token := &tokens.Token{Name: "color-primary", Path: []string{"color", "primary"}, Prefix: "ds"}
+
+	assert.Equal(t, token.CSSVariableName(), manager.DisplayName(token, true))
+	assert.Equal(t, "--color-primary", manager.DisplayName(token, false))
+}
+
+func TestNameFormat_Validate(t *testing.T) {
+	assert.NoError(t, tokens.NameFormat{}.Validate())
+	assert.NoError(t, tokens.NameFormat{Case: tokens.NameCaseCamel, Collisions: tokens.CollisionError}.Validate())
+	assert.NoError(t, tokens.NameFormat{Collisions: tokens.CollisionWarn}.Validate())
+	assert.Error(t, tokens.NameFormat{Case: "snake"}.Validate())
+	assert.Error(t, tokens.NameFormat{Collisions: "ignore"}.Validate())
+}
+
+func TestManager_NameFormat(t *testing.T) {
+	manager := tokens.NewManager()
+	manager.SetNameFormat(tokens.NameFormat{Case: tokens.NameCaseCamel})
+
+	token := &tokens.Token{Name: "color-primary", Path: []string{"color", "primary"}}
+	assert.NoError(t, manager.Add(token))
+
+	assert.Equal(t, "--colorPrimary", manager.CSSVariableName(token))
+	assert.Same(t, token, manager.Get("--colorPrimary"), "formatted name should resolve")
+	assert.Same(t, token, manager.Get("color-primary"), "canonical name should still resolve")
+}
+
+func TestManager_Colliding(t *testing.T) {
+	manager := tokens.NewManager()
+	first := &tokens.Token{Name: "color-brandPrimary", Path: []string{"color", "brandPrimary"}}
+	second := &tokens.Token{Name: "color-brand-primary", Path: []string{"color", "brand", "primary"}}
+	assert.NoError(t, manager.Add(first))
+
+	assert.Nil(t, manager.Colliding(second), "standard naming keeps names distinct")
+
+	manager.SetNameFormat(tokens.NameFormat{Case: tokens.NameCaseCamel})
+	assert.Same(t, first, manager.Colliding(second))
+
+	sameName := &tokens.Token{Name: "color-brandPrimary", Path: []string{"color", "brandPrimary"}, FilePath: "/other.json"}
+	assert.Nil(t, manager.Colliding(sameName), "same token name from another file is not a collision")
+}
+
+func TestManager_Colliding_FollowsChanges(t *testing.T) {
+	manager := tokens.NewManager()
+	manager.SetNameFormat(tokens.NameFormat{Case: tokens.NameCaseCamel})
+	first := &tokens.Token{Name: "color-brandPrimary", Path: []string{"color", "brandPrimary"}, FilePath: "/a.json"}
+	second := &tokens.Token{Name: "color-brand-primary", Path: []string{"color", "brand", "primary"}, FilePath: "/b.json"}
+
+	manager.Batch(func(batch *tokens.Manager) {
+		assert.NoError(t, batch.Add(first))
+		assert.Same(t, first, batch.Colliding(second), "tokens added in a batch are seen by the batch")
+	})
+	assert.Same(t, first, manager.Colliding(second))
+
+	manager.RemoveBySourceFile("/a.json")
+	assert.Nil(t, manager.Colliding(second), "removed tokens don't collide")
+
+	assert.NoError(t, manager.Add(first))
+	manager.SetNameFormat(tokens.NameFormat{})
+	assert.Nil(t, manager.Colliding(second), "tokens are reindexed when the format changes")
+
+	manager.Clear()
+	manager.SetNameFormat(tokens.NameFormat{Case: tokens.NameCaseCamel})
+	assert.Nil(t, manager.Colliding(second), "cleared tokens don't collide")
+}
@@ -0,0 +1,119 @@
+package tokens
+
+import (
+	"encoding/json"
+
+	"bennypowers.dev/dtls/internal/schema"
+	"gopkg.in/yaml.v3"
+)
+
+// SchemaSource is where a token file's schema version came from
+type SchemaSource int
+
+const (
+	// SchemaSourceDefault means nothing in the file or its configuration
+	// indicated a version, so the draft schema is assumed
+	SchemaSourceDefault SchemaSource = iota
+
+	// SchemaSourceConfig means the file's configuration set the version
+	SchemaSourceConfig
+
+	// SchemaSourceField means the file's $schema field names the version
+	SchemaSourceField
+
+	// SchemaSourceValues means the version was inferred from the shapes of
+	// the file's values, e.g. structured colors or $ref pointers
+	SchemaSourceValues
+)
+
+// String returns a description of the source, for logs
+func (s SchemaSource) String() string {
+	switch s {
+	case SchemaSourceConfig:
+		return "configuration"
+	case SchemaSourceField:
+		return "$schema"
+	case SchemaSourceValues:
+		return "value shapes"
+	default:
+		return "default"
+	}
+}
+
+// SchemaDetection is a token file's schema version and where it came from
+type SchemaDetection struct {
+	Version schema.SchemaVersion
+	Source  SchemaSource
+}
+
+// DetectSchemaVersion determines the schema version of token file data (JSON
+// or YAML), so that files of different versions can be loaded side by side.
+//
+// A configured version other than Unknown wins. Otherwise a $schema field
+// naming a known version decides, then the shapes of the file's values: the
+// version most of its version-specific constructs belong to, preferring
+// draft on a tie. Files with none of those are draft.
+func DetectSchemaVersion(data []byte, configured schema.SchemaVersion) SchemaDetection {
+	if configured != schema.Unknown {
+		return SchemaDetection{Version: configured, Source: SchemaSourceConfig}
+	}
+
+	var root map[string]any
+	if err := json.Unmarshal(data, &root); err != nil {
+		root = nil
+		if err := yaml.Unmarshal(data, &root); err != nil {
+			return SchemaDetection{Version: schema.Draft, Source: SchemaSourceDefault}
+		}
+	}
+
+	if url, ok := root["$schema"].(string); ok {
+		if version, err := schema.FromURL(url); err == nil && version != schema.Unknown {
+			return SchemaDetection{Version: version, Source: SchemaSourceField}
+		}
+	}
+
+	counts := map[schema.SchemaVersion]int{}
+	countValueVersions(root, "", counts)
+	switch {
+	case counts[schema.V2025_10] > counts[schema.Draft]:
+		return SchemaDetection{Version: schema.V2025_10, Source: SchemaSourceValues}
+	case counts[schema.Draft] > 0:
+		return SchemaDetection{Version: schema.Draft, Source: SchemaSourceValues}
+	}
+	return SchemaDetection{Version: schema.Draft, Source: SchemaSourceDefault}
+}
+
+// countValueVersions counts the version-specific constructs in a group or
+// token and its children by the version they belong to. inheritedType is the
+// $type of the enclosing groups.
+func countValueVersions(node map[string]any, inheritedType string, counts map[schema.SchemaVersion]int) {
+	tokenType := inheritedType
+	if t, ok := node["$type"].(string); ok {
+		tokenType = t
+	}
+
+	if _, ok := node["$ref"]; ok {
+		counts[schema.V2025_10]++
+	}
+	if _, ok := node["$root"]; ok {
+		counts[schema.V2025_10]++
+	}
+	if value, ok := node["$value"]; ok {
+		if object, ok := value.(map[string]any); ok {
+			if _, ok := object["$ref"]; ok {
+				counts[schema.V2025_10]++
+				return
+			}
+		}
+		if version, _ := valueVersion(value, tokenType); version != schema.Unknown {
+			counts[version]++
+		}
+		return
+	}
+
+	for key, child := range node {
+		if group, ok := child.(map[string]any); ok && (len(key) == 0 || key[0] != '$' || key == "$root") {
+			countValueVersions(group, tokenType, counts)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package tokens_test
+
+import (
+	"testing"
+
+	"bennypowers.dev/dtls/internal/schema"
+	"bennypowers.dev/dtls/internal/tokens"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDetectSchemaVersion(t *testing.T) {
+	tests := []struct {
+		name       string
+		data       string
+		configured schema.SchemaVersion
+		expected   tokens.SchemaDetection
+	}{
+		{
+			name:       "configured version wins",
+			data:       `{"$schema": "https://www.designtokens.org/schemas/2025.10.json"}`,
+			configured: schema.Draft,
+			expected:   tokens.SchemaDetection{Version: schema.Draft, Source: tokens.SchemaSourceConfig},
+		},
+		{
+			name:     "$schema field",
+			data:     `{"$schema": "https://www.designtokens.org/schemas/2025.10.json", "color": {"$type": "color", "red": {"$value": "#f00"}}}`,
+			expected: tokens.SchemaDetection{Version: schema.V2025_10, Source: tokens.SchemaSourceField},
+		},
+		{
+			name:     "unknown $schema falls back to values",
+			data:     `{"$schema": "https://example.com/tokens.json", "color": {"red": {"$type": "color", "$value": "#f00"}}}`,
+			expected: tokens.SchemaDetection{Version: schema.Draft, Source: tokens.SchemaSourceValues},
+		},
+		{
+			name:     "structured colors",
+			data:     `{"color": {"$type": "color", "red": {"$value": {"colorSpace": "srgb", "components": [1, 0, 0]}}}}`,
+			expected: tokens.SchemaDetection{Version: schema.V2025_10, Source: tokens.SchemaSourceValues},
+		},
+		{
+			name:     "structured dimensions",
+			data:     `{"space": {"small": {"$type": "dimension", "$value": {"value": 4, "unit": "px"}}}}`,
+			expected: tokens.SchemaDetection{Version: schema.V2025_10, Source: tokens.SchemaSourceValues},
+		},
+		{
+			name:     "JSON pointer references",
+			data:     `{"color": {"red": {"$type": "color", "$value": "#f00"}, "danger": {"$ref": "#/color/red"}, "error": {"$ref": "#/color/red"}}}`,
+			expected: tokens.SchemaDetection{Version: schema.V2025_10, Source: tokens.SchemaSourceValues},
+		},
+		{
+			name:     "$root tokens",
+			data:     `{"space": {"$type": "number", "$root": {"$value": 4}}}`,
+			expected: tokens.SchemaDetection{Version: schema.V2025_10, Source: tokens.SchemaSourceValues},
+		},
+		{
+			name:     "string colors",
+			data:     `{"color": {"$type": "color", "red": {"$value": "#f00"}, "alias": {"$value": "{color.red}"}}}`,
+			expected: tokens.SchemaDetection{Version: schema.Draft, Source: tokens.SchemaSourceValues},
+		},
+		{
+			name:     "mostly draft values",
+			data:     `{"color": {"$type": "color", "red": {"$value": "#f00"}, "blue": {"$value": "#00f"}, "green": {"$value": {"colorSpace": "srgb", "components": [0, 1, 0]}}}}`,
+			expected: tokens.SchemaDetection{Version: schema.Draft, Source: tokens.SchemaSourceValues},
+		},
+		{
+			name:     "YAML",
+			data:     "color:\n  red:\n    $type: color\n    $value:\n      colorSpace: srgb\n      components: [1, 0, 0]\n",
+			expected: tokens.SchemaDetection{Version: schema.V2025_10, Source: tokens.SchemaSourceValues},
+		},
+		{
+			name:     "no version-specific values",
+			data:     `{"size": {"$type": "number", "$value": 4}}`,
+			expected: tokens.SchemaDetection{Version: schema.Draft, Source: tokens.SchemaSourceDefault},
+		},
+		{
+			name:     "unparseable",
+			data:     `{"color": `,
+			expected: tokens.SchemaDetection{Version: schema.Draft, Source: tokens.SchemaSourceDefault},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, tokens.DetectSchemaVersion([]byte(tt.data), tt.configured))
+		})
+	}
+}
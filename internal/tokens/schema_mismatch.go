@@ -0,0 +1,80 @@
+package tokens
+
+import (
+	"cmp"
+	"slices"
+	"strings"
+
+	"bennypowers.dev/dtls/internal/schema"
+)
+
+// SchemaMismatch is a token whose value uses a construct from a different
+// schema version than its file was parsed as, e.g. a 2025.10 structured
+// color in a draft file
+type SchemaMismatch struct {
+	Token *Token
+
+	// Version is the schema version the construct belongs to
+	Version schema.SchemaVersion
+
+	// Construct describes the construct, e.g. "structured color value"
+	Construct string
+}
+
+// constructVersion returns the schema version that a token's value construct
+// belongs to, and a description of it. Constructs valid in every version
+// return Unknown.
+func constructVersion(token *Token) (schema.SchemaVersion, string) {
+	if strings.HasPrefix(token.Reference, "#/") {
+		return schema.V2025_10, "JSON pointer reference"
+	}
+	return valueVersion(token.RawValue, token.Type)
+}
+
+// valueVersion returns the schema version that a $value of the given $type
+// belongs to, and a description of its construct, as constructVersion does
+func valueVersion(raw any, tokenType string) (schema.SchemaVersion, string) {
+	switch value := raw.(type) {
+	case map[string]any:
+		if _, ok := value["colorSpace"]; ok {
+			return schema.V2025_10, "structured color value"
+		}
+		if _, ok := value["unit"]; ok {
+			return schema.V2025_10, "structured " + cmp.Or(tokenType, "dimension") + " value"
+		}
+	case string:
+		if strings.HasPrefix(value, "{") {
+			// Curly brace aliases are valid in every version
+			return schema.Unknown, ""
+		}
+		switch tokenType {
+		case "color":
+			return schema.Draft, "string color value"
+		case "dimension", "duration":
+			return schema.Draft, "string " + tokenType + " value"
+		}
+	}
+	return schema.Unknown, ""
+}
+
+// SchemaMismatches returns the tokens of a file that use constructs from a
+// different schema version than the file was parsed as, sorted by name.
+// Files parsed with an unknown or inconsistent version return nil.
+func (m *Manager) SchemaMismatches(filePath string) []SchemaMismatch {
+	fileVersion := m.GetSchemaVersionForFile(filePath)
+	if fileVersion == schema.Unknown {
+		return nil
+	}
+
+	var mismatches []SchemaMismatch
+	for _, token := range m.GetBySourceFile(filePath) {
+		version, construct := constructVersion(token)
+		if version != schema.Unknown && version != fileVersion {
+			mismatches = append(mismatches, SchemaMismatch{Token: token, Version: version, Construct: construct})
+		}
+	}
+	slices.SortFunc(mismatches, func(a, b SchemaMismatch) int {
+		return cmp.Compare(a.Token.Name, b.Token.Name)
+	})
+	return mismatches
+}
@@ -0,0 +1,51 @@
+package tokens_test
+
+import (
+	"testing"
+
+	"bennypowers.dev/dtls/internal/schema"
+	"bennypowers.dev/dtls/internal/tokens"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestManager_SchemaMismatches(t *testing.T) {
+	structuredColor := map[string]any{"colorSpace": "srgb", "components": []any{1.0, 0.0, 0.0}}
+	structuredDimension := map[string]any{"value": 4.0, "unit": "px"}
+
+	m := tokens.NewManager()
+	for _, token := range []*tokens.Token{
+		// A draft file using 2025.10 constructs
+		{Name: "color-a", Type: "color", RawValue: "#f00", FilePath: "/draft.json", SchemaVersion: schema.Draft},
+		{Name: "color-b", Type: "color", RawValue: structuredColor, FilePath: "/draft.json", SchemaVersion: schema.Draft},
+		{Name: "space-md", Type: "dimension", RawValue: structuredDimension, FilePath: "/draft.json", SchemaVersion: schema.Draft},
+		{Name: "color-alias", Type: "color", RawValue: "{color.a}", FilePath: "/draft.json", SchemaVersion: schema.Draft},
+
+		// A 2025.10 file using draft constructs
+		{Name: "color-c", Type: "color", RawValue: structuredColor, FilePath: "/modern.json", SchemaVersion: schema.V2025_10},
+		{Name: "space-sm", Type: "dimension", RawValue: "2px", FilePath: "/modern.json", SchemaVersion: schema.V2025_10},
+		{Name: "color-ref", Type: "color", Reference: "#/color/c", FilePath: "/modern.json", SchemaVersion: schema.V2025_10},
+
+		// A consistent file
+		{Name: "color-d", Type: "color", RawValue: "#00f", FilePath: "/clean.json", SchemaVersion: schema.Draft},
+	} {
+		require.NoError(t, m.Add(token))
+	}
+
+	draft := m.SchemaMismatches("/draft.json")
+	require.Len(t, draft, 2)
+	assert.Equal(t, "color-b", draft[0].Token.Name)
+	assert.Equal(t, schema.V2025_10, draft[0].Version)
+	assert.Equal(t, "structured color value", draft[0].Construct)
+	assert.Equal(t, "space-md", draft[1].Token.Name)
+	assert.Equal(t, "structured dimension value", draft[1].Construct)
+
+	modern := m.SchemaMismatches("/modern.json")
+	require.Len(t, modern, 1)
+	assert.Equal(t, "space-sm", modern[0].Token.Name)
+	assert.Equal(t, schema.Draft, modern[0].Version)
+	assert.Equal(t, "string dimension value", modern[0].Construct)
+
+	assert.Empty(t, m.SchemaMismatches("/clean.json"))
+	assert.Empty(t, m.SchemaMismatches("/missing.json"))
+}
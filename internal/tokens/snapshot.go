@@ -0,0 +1,95 @@
+package tokens
+
+import (
+	"cmp"
+	"slices"
+	"time"
+)
+
+// SnapshotEntry is a token's resolved state at the time of a snapshot
+type SnapshotEntry struct {
+	// Name is the token's CSS variable name
+	Name       string `json:"name"`
+	Value      string `json:"value"`
+	Type       string `json:"type,omitempty"`
+	Deprecated bool   `json:"deprecated,omitempty"`
+}
+
+// Snapshot is a point-in-time copy of the resolved token set, which can
+// later be compared against the live tokens, e.g. across a version bump
+type Snapshot struct {
+	Taken time.Time
+
+	// entries maps CSS variable names to token state
+	entries map[string]SnapshotEntry
+}
+
+// SnapshotChange is a token present in both sets whose state differs
+type SnapshotChange struct {
+	Name string        `json:"name"`
+	Old  SnapshotEntry `json:"old"`
+	New  SnapshotEntry `json:"new"`
+}
+
+// SnapshotDiff lists the differences between a snapshot and a later token
+// set. Each list is sorted by name.
+type SnapshotDiff struct {
+	Added   []SnapshotEntry  `json:"added"`
+	Removed []SnapshotEntry  `json:"removed"`
+	Changed []SnapshotChange `json:"changed"`
+}
+
+// Empty reports whether the token sets were identical
+func (d SnapshotDiff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+// Snapshot copies the current resolved state of every token.
+// Tokens sharing a CSS variable name across files keep one entry.
+func (m *Manager) Snapshot() *Snapshot {
+	snapshot := &Snapshot{Taken: time.Now(), entries: map[string]SnapshotEntry{}}
+	for _, token := range m.GetAll() {
+		name := m.CSSVariableName(token)
+		snapshot.entries[name] = SnapshotEntry{
+			Name:       name,
+			Value:      token.DisplayValue(),
+			Type:       token.Type,
+			Deprecated: token.Deprecated,
+		}
+	}
+	return snapshot
+}
+
+// Len returns the number of tokens in the snapshot
+func (s *Snapshot) Len() int {
+	return len(s.entries)
+}
+
+// Diff compares the snapshot (old) against a later snapshot (new)
+func (s *Snapshot) Diff(later *Snapshot) SnapshotDiff {
+	diff := SnapshotDiff{
+		Added:   []SnapshotEntry{},
+		Removed: []SnapshotEntry{},
+		Changed: []SnapshotChange{},
+	}
+	for name, old := range s.entries {
+		current, ok := later.entries[name]
+		switch {
+		case !ok:
+			diff.Removed = append(diff.Removed, old)
+		case current != old:
+			diff.Changed = append(diff.Changed, SnapshotChange{Name: name, Old: old, New: current})
+		}
+	}
+	for name, current := range later.entries {
+		if _, ok := s.entries[name]; !ok {
+			diff.Added = append(diff.Added, current)
+		}
+	}
+
+	byName := func(a, b SnapshotEntry) int { return cmp.Compare(a.Name, b.Name) }
+	slices.SortFunc(diff.Added, byName)
+	slices.SortFunc(diff.Removed, byName)
+	slices.SortFunc(diff.Changed, func(a, b SnapshotChange) int { return cmp.Compare(a.Name, b.Name) })
+	return diff
+}
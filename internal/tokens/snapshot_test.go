@@ -0,0 +1,36 @@
+package tokens_test
+
+import (
+	"testing"
+
+	"bennypowers.dev/dtls/internal/tokens"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSnapshotDiff(t *testing.T) {
+	manager := tokens.NewManager()
+	require.NoError(t, manager.Add(&tokens.Token{Name: "color.primary", Value: "#00f", Type: "color"}))
+	require.NoError(t, manager.Add(&tokens.Token{Name: "color.legacy", Value: "#000", Type: "color"}))
+	require.NoError(t, manager.Add(&tokens.Token{Name: "space.small", Value: "4px", Type: "dimension"}))
+	require.NoError(t, manager.Add(&tokens.Token{Name: "space.large", Value: "16px", Type: "dimension"}))
+
+	before := manager.Snapshot()
+	assert.Equal(t, 4, before.Len())
+	assert.True(t, before.Diff(manager.Snapshot()).Empty())
+
+	require.NoError(t, manager.Remove("color.legacy"))
+	require.NoError(t, manager.Add(&tokens.Token{Name: "color.primary", Value: "#00e", Type: "color"}))
+	require.NoError(t, manager.Add(&tokens.Token{Name: "space.large", Value: "16px", Type: "dimension", Deprecated: true}))
+	require.NoError(t, manager.Add(&tokens.Token{Name: "space.medium", Value: "8px", Type: "dimension"}))
+
+	diff := before.Diff(manager.Snapshot())
+	assert.Equal(t, []tokens.SnapshotEntry{{Name: "--space-medium", Value: "8px", Type: "dimension"}}, diff.Added)
+	assert.Equal(t, []tokens.SnapshotEntry{{Name: "--color-legacy", Value: "#000", Type: "color"}}, diff.Removed)
+	require.Len(t, diff.Changed, 2)
+	assert.Equal(t, "--color-primary", diff.Changed[0].Name)
+	assert.Equal(t, "#00f", diff.Changed[0].Old.Value)
+	assert.Equal(t, "#00e", diff.Changed[0].New.Value)
+	assert.Equal(t, "--space-large", diff.Changed[1].Name)
+	assert.True(t, diff.Changed[1].New.Deprecated)
+}
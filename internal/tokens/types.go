@@ -10,6 +10,12 @@ import (
 // This eliminates conversion overhead and maintains backward compatibility.
 type Token = asimonimToken.Token
 
+// DTCGTypes are the token types the DTCG format defines, in name order
+var DTCGTypes = []string{
+	"border", "color", "cubicBezier", "dimension", "duration", "fontFamily", "fontWeight",
+	"gradient", "number", "shadow", "strokeStyle", "transition", "typography",
+}
+
 // TokenGroup represents a group of tokens (can be nested)
 type TokenGroup struct {
 	Name        string                 `json:"-"`
@@ -158,6 +158,14 @@ func PathToURI(path string) string {
 	return "file://" + encodedPath
 }
 
+// IsFileURI reports whether uri uses the file scheme.
+// Documents with other schemes (untitled:, vscode-notebook-cell:, ...) have no
+// file system path.
+func IsFileURI(uri string) bool {
+	scheme, _, ok := strings.Cut(uri, ":")
+	return ok && strings.EqualFold(scheme, "file")
+}
+
 // URIToPath converts a file:// URI to a file system path.
 // Handles both Windows and POSIX URIs correctly:
 //   - file:///C:/proj -> C:\proj (on Windows) or C:/proj (on POSIX)
@@ -688,3 +688,12 @@ func TestNormalizeAndEncodeSegments(t *testing.T) {
 		})
 	}
 }
+
+func TestIsFileURI(t *testing.T) {
+	assert.True(t, IsFileURI("file:///home/user/tokens.json"))
+	assert.True(t, IsFileURI("FILE:///C:/proj/tokens.json"))
+	assert.False(t, IsFileURI("untitled:Untitled-1"))
+	assert.False(t, IsFileURI("vscode-notebook-cell:/nb.ipynb#cell1"))
+	assert.False(t, IsFileURI("/home/user/tokens.json"))
+	assert.False(t, IsFileURI(""))
+}
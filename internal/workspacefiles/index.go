@@ -0,0 +1,192 @@
+package workspacefiles
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	"bennypowers.dev/dtls/internal/log"
+	"bennypowers.dev/dtls/internal/parser"
+	"bennypowers.dev/dtls/internal/position"
+	"bennypowers.dev/dtls/internal/uriutil"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+// Index holds the CSS variable references of the CSS-supported files under a
+// workspace root, so that token uses can be found in files which were never
+// opened. It is empty until the first Scan completes.
+type Index struct {
+	mu    sync.RWMutex
+	root  string
+	files map[string]*indexedFile // by path
+}
+
+// indexedFile is the CSS variable references of one file
+type indexedFile struct {
+	uri  string
+	refs map[string][]protocol.Range // by variable name, e.g. --color-primary
+}
+
+// NewIndex creates an empty index
+func NewIndex() *Index {
+	return &Index{files: map[string]*indexedFile{}}
+}
+
+// Scan indexes the files under root, replacing what was indexed before. It
+// reads every file, so servers run it in the background.
+func (x *Index) Scan(root string) error {
+	files := map[string]*indexedFile{}
+	err := Walk(root, func(path, _ string) error {
+		file, err := indexFile(path)
+		if err != nil {
+			log.Warn("Cannot index %s: %v", path, err)
+			return nil
+		}
+		files[filepath.Clean(path)] = file
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	x.mu.Lock()
+	x.root = filepath.Clean(root)
+	x.files = files
+	x.mu.Unlock()
+	return nil
+}
+
+// Update re-indexes the file at path after it changed on disk, or drops it
+// when it was deleted or is no longer one Scan would index. Files outside the
+// scanned root are ignored.
+func (x *Index) Update(path string) {
+	x.mu.RLock()
+	root := x.root
+	x.mu.RUnlock()
+	if root == "" {
+		return
+	}
+
+	path = filepath.Clean(path)
+	var file *indexedFile
+	if parser.LanguageIDForPath(path) != "" && !newIgnorer(root).excluded(path) {
+		// Deleted and unreadable files are dropped
+		file, _ = indexFile(path)
+	}
+
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	if file == nil {
+		delete(x.files, path)
+		return
+	}
+	x.files[path] = file
+}
+
+// Len returns the number of indexed files
+func (x *Index) Len() int {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+	return len(x.files)
+}
+
+// Paths returns the paths of the indexed files, in order
+func (x *Index) Paths() []string {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+
+	paths := make([]string, 0, len(x.files))
+	for path := range x.files {
+		paths = append(paths, path)
+	}
+	slices.Sort(paths)
+	return paths
+}
+
+// References returns the locations in indexed files which reference a CSS
+// variable, e.g. var(--color-primary), ordered by file and position
+func (x *Index) References(name string) []protocol.Location {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+
+	paths := make([]string, 0, len(x.files))
+	for path, file := range x.files {
+		if len(file.refs[name]) > 0 {
+			paths = append(paths, path)
+		}
+	}
+	slices.Sort(paths)
+
+	var locations []protocol.Location
+	for _, path := range paths {
+		file := x.files[path]
+		for _, r := range file.refs[name] {
+			locations = append(locations, protocol.Location{URI: file.uri, Range: r})
+		}
+	}
+	return locations
+}
+
+// indexFile reads the CSS variable references of a file
+func indexFile(path string) (*indexedFile, error) {
+	data, err := os.ReadFile(path) //nolint:gosec // G304: files under the workspace root
+	if err != nil {
+		return nil, err
+	}
+	return &indexedFile{uri: uriutil.PathToURI(path), refs: varReferences(string(data))}, nil
+}
+
+// varReferences finds the CSS variables which content references: names
+// starting with two hyphens and followed, after any whitespace, by a comma or
+// closing parenthesis, as in var(--color-primary) or var( --color-primary , blue). Declarations,
+// e.g. --color-primary: blue, are not references.
+func varReferences(content string) map[string][]protocol.Range {
+	refs := map[string][]protocol.Range{}
+	for lineNum, line := range strings.Split(content, "\n") {
+		offset := 0
+		for {
+			idx := strings.Index(line[offset:], "--")
+			if idx == -1 {
+				break
+			}
+			start := offset + idx
+			end := start + 2
+			for end < len(line) && isNameByte(line[end]) {
+				end++
+			}
+			offset = end
+
+			// Skip hyphens inside other names, e.g. BEM classes like .button--primary
+			if start > 0 && isNameByte(line[start-1]) {
+				continue
+			}
+			if end == start+2 {
+				continue
+			}
+			next := end
+			for next < len(line) && (line[next] == ' ' || line[next] == '\t') {
+				next++
+			}
+			if next == len(line) || (line[next] != ',' && line[next] != ')') {
+				continue
+			}
+
+			lineU32 := uint32(lineNum) //nolint:gosec // G115: line counts fit in uint32
+			name := line[start:end]
+			refs[name] = append(refs[name], protocol.Range{
+				Start: protocol.Position{Line: lineU32, Character: position.ByteOffsetToUTF16Uint32(line, start)},
+				End:   protocol.Position{Line: lineU32, Character: position.ByteOffsetToUTF16Uint32(line, end)},
+			})
+		}
+	}
+	return refs
+}
+
+// isNameByte reports whether b can be part of a CSS variable name. Bytes of
+// non-ASCII characters are.
+func isNameByte(b byte) bool {
+	return b == '-' || b == '_' || b >= 0x80 ||
+		('a' <= b && b <= 'z') || ('A' <= b && b <= 'Z') || ('0' <= b && b <= '9')
+}
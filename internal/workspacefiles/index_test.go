@@ -0,0 +1,126 @@
+package workspacefiles
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"bennypowers.dev/dtls/internal/uriutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+// lineRange returns a range on one line
+func lineRange(line, start, end uint32) protocol.Range {
+	return protocol.Range{
+		Start: protocol.Position{Line: line, Character: start},
+		End:   protocol.Position{Line: line, Character: end},
+	}
+}
+
+func TestVarReferences(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		expected map[string][]protocol.Range
+	}{
+		{
+			name:    "var calls",
+			content: ".a { color: var(--color-primary); }\n.b { color: var(--color-primary, blue); }",
+			expected: map[string][]protocol.Range{
+				"--color-primary": {lineRange(0, 16, 31), lineRange(1, 16, 31)},
+			},
+		},
+		{
+			name:    "nested fallbacks",
+			content: "color: var(--a, var(--b));",
+			expected: map[string][]protocol.Range{
+				"--a": {lineRange(0, 11, 14)},
+				"--b": {lineRange(0, 20, 23)},
+			},
+		},
+		{
+			name:    "whitespace around the name",
+			content: "a: var( --a); b: var(--b ); c: var(\t--c\t, red);",
+			expected: map[string][]protocol.Range{
+				"--a": {lineRange(0, 8, 11)},
+				"--b": {lineRange(0, 21, 24)},
+				"--c": {lineRange(0, 36, 39)},
+			},
+		},
+		{
+			name:     "declarations with whitespace",
+			content:  ":root { --color-primary : blue; }",
+			expected: map[string][]protocol.Range{},
+		},
+		{
+			name:     "declarations and BEM classes",
+			content:  ":root { --color-primary: blue; }\n.button--primary) {}",
+			expected: map[string][]protocol.Range{},
+		},
+		{
+			name:    "characters before the name count in UTF-16",
+			content: `content: "✨"; color: var(--color-primary);`,
+			expected: map[string][]protocol.Range{
+				"--color-primary": {lineRange(0, 25, 40)},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, varReferences(tt.content))
+		})
+	}
+}
+
+func TestIndex(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		".gitignore":        "dist/\n",
+		"styles/button.css": ".button { color: var(--color-primary); }",
+		"styles/card.scss":  ".card {\n  border-color: var(--color-primary, blue);\n}",
+		"styles/link.css":   ".link { color: var(--color-secondary); }",
+		"dist/bundle.css":   ".button { color: var(--color-primary); }",
+	})
+	button := filepath.Join(root, "styles", "button.css")
+	card := filepath.Join(root, "styles", "card.scss")
+
+	index := NewIndex()
+	assert.Empty(t, index.References("--color-primary"), "empty before the first scan")
+	require.NoError(t, index.Scan(root))
+	assert.Equal(t, 3, index.Len())
+	assert.Equal(t, []string{button, card, filepath.Join(root, "styles", "link.css")}, index.Paths())
+
+	assert.Equal(t, []protocol.Location{
+		{URI: uriutil.PathToURI(button), Range: lineRange(0, 21, 36)},
+		{URI: uriutil.PathToURI(card), Range: lineRange(1, 20, 35)},
+	}, index.References("--color-primary"))
+	assert.Empty(t, index.References("--color-tertiary"))
+
+	t.Run("update changed file", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(button, []byte(".button { color: var(--color-secondary); }"), 0o600))
+		index.Update(button)
+		assert.Len(t, index.References("--color-primary"), 1)
+		assert.Len(t, index.References("--color-secondary"), 2)
+	})
+
+	t.Run("update new file", func(t *testing.T) {
+		path := filepath.Join(root, "styles", "nav.css")
+		writeFiles(t, root, map[string]string{"styles/nav.css": "nav { color: var(--color-primary); }"})
+		index.Update(path)
+		assert.Len(t, index.References("--color-primary"), 2)
+	})
+
+	t.Run("update deleted file", func(t *testing.T) {
+		require.NoError(t, os.Remove(card))
+		index.Update(card)
+		assert.Len(t, index.References("--color-primary"), 1)
+	})
+
+	t.Run("update ignored file", func(t *testing.T) {
+		index.Update(filepath.Join(root, "dist", "bundle.css"))
+		assert.Len(t, index.References("--color-primary"), 1)
+	})
+}
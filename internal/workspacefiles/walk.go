@@ -0,0 +1,217 @@
+// Package workspacefiles finds the stylesheets and components under a
+// workspace root, and indexes the CSS variables they reference.
+//
+// Walks follow git's view of the workspace: what the .gitignore files in the
+// root and the directories under it ignore is skipped, along with
+// node_modules and hidden directories.
+package workspacefiles
+
+import (
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"bennypowers.dev/dtls/internal/parser"
+	"github.com/bmatcuk/doublestar/v4"
+)
+
+// Walk calls fn with each CSS-supported file under root and its language ID.
+// Directories which can't be read are skipped rather than ending the walk;
+// an error from fn ends it.
+func Walk(root string, fn func(path, languageID string) error) error {
+	return walkFiles(root, func(p string) error {
+		languageID := parser.LanguageIDForPath(p)
+		if languageID == "" {
+			return nil
+		}
+		return fn(p, languageID)
+	})
+}
+
+// tokenFileNames are the names token files are conventionally given
+var tokenFileNames = []string{
+	"tokens.json", "*.tokens.json", "design-tokens.json",
+	"tokens.yaml", "*.tokens.yaml", "*.tokens.yml",
+}
+
+// FindTokenFiles returns the files under root named as token files
+// conventionally are, e.g. tokens.json or colors.tokens.json, in walk order
+func FindTokenFiles(root string) ([]string, error) {
+	var found []string
+	err := walkFiles(root, func(p string) error {
+		for _, pattern := range tokenFileNames {
+			if ok, _ := path.Match(pattern, filepath.Base(p)); ok {
+				found = append(found, p)
+				break
+			}
+		}
+		return nil
+	})
+	return found, err
+}
+
+// walkFiles calls fn with each file under root which git doesn't ignore
+func walkFiles(root string, fn func(path string) error) error {
+	ignore := newIgnorer(root)
+	return filepath.WalkDir(root, func(p string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if p == root {
+				return err
+			}
+			return nil
+		}
+		if entry.IsDir() {
+			if p != root && (skippedDir(entry.Name()) || ignore.ignored(p, true)) {
+				return filepath.SkipDir
+			}
+			ignore.load(p)
+			return nil
+		}
+		if ignore.ignored(p, false) {
+			return nil
+		}
+		return fn(p)
+	})
+}
+
+// skippedDir reports whether a directory is skipped whether or not git
+// ignores it
+func skippedDir(name string) bool {
+	return name == "node_modules" || strings.HasPrefix(name, ".")
+}
+
+// ignoreRule is a pattern from a .gitignore file
+type ignoreRule struct {
+	// dir is the directory of the .gitignore file
+	dir string
+
+	// pattern is a slash-separated glob
+	pattern string
+
+	// anchored patterns match paths relative to dir, others match names
+	anchored bool
+
+	// negate re-includes what earlier rules ignored
+	negate bool
+
+	// dirOnly patterns only match directories
+	dirOnly bool
+}
+
+// matches reports whether the rule matches p, which is under its directory
+func (r ignoreRule) matches(p string, isDir bool) bool {
+	if r.dirOnly && !isDir {
+		return false
+	}
+	rel, err := filepath.Rel(r.dir, p)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return false
+	}
+	rel = filepath.ToSlash(rel)
+	if !r.anchored {
+		rel = path.Base(rel)
+	}
+	ok, _ := doublestar.Match(r.pattern, rel)
+	return ok
+}
+
+// parseGitignore returns the rules of a .gitignore file in dir
+func parseGitignore(dir, content string) []ignoreRule {
+	var rules []ignoreRule
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSuffix(line, "\r")
+		if !strings.HasSuffix(line, `\ `) {
+			line = strings.TrimRight(line, " \t")
+		}
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		rule := ignoreRule{dir: dir}
+		if strings.HasPrefix(line, "!") {
+			rule.negate = true
+			line = line[1:]
+		}
+		if strings.HasSuffix(line, "/") {
+			rule.dirOnly = true
+			line = strings.TrimSuffix(line, "/")
+		}
+		if strings.Contains(line, "/") {
+			rule.anchored = true
+			line = strings.TrimPrefix(line, "/")
+		}
+		if line == "" {
+			continue
+		}
+		rule.pattern = line
+		rules = append(rules, rule)
+	}
+	return rules
+}
+
+// ignorer holds the .gitignore rules of the directories under a root
+type ignorer struct {
+	root  string
+	rules map[string][]ignoreRule
+}
+
+func newIgnorer(root string) *ignorer {
+	return &ignorer{root: filepath.Clean(root), rules: map[string][]ignoreRule{}}
+}
+
+// load reads the .gitignore file in dir, if it has one
+func (i *ignorer) load(dir string) {
+	dir = filepath.Clean(dir)
+	if _, ok := i.rules[dir]; ok {
+		return
+	}
+	data, err := os.ReadFile(filepath.Join(dir, ".gitignore")) //nolint:gosec // G304: .gitignore files under the workspace root
+	if err != nil {
+		i.rules[dir] = nil
+		return
+	}
+	i.rules[dir] = parseGitignore(dir, string(data))
+}
+
+// ignored reports whether git ignores p: the last rule matching it, in the
+// .gitignore files from the root down to p's directory, doesn't negate.
+// Rules of directories not yet loaded are loaded first.
+func (i *ignorer) ignored(p string, isDir bool) bool {
+	var dirs []string
+	for dir := filepath.Dir(filepath.Clean(p)); ; dir = filepath.Dir(dir) {
+		dirs = append(dirs, dir)
+		if dir == i.root || dir == filepath.Dir(dir) {
+			break
+		}
+	}
+
+	ignored := false
+	for j := len(dirs) - 1; j >= 0; j-- {
+		i.load(dirs[j])
+		for _, rule := range i.rules[dirs[j]] {
+			if rule.matches(p, isDir) {
+				ignored = !rule.negate
+			}
+		}
+	}
+	return ignored
+}
+
+// excluded reports whether a path under root is left out of walks, because
+// it or a directory above it is skipped or ignored
+func (i *ignorer) excluded(p string) bool {
+	rel, err := filepath.Rel(i.root, p)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return true
+	}
+	dir := i.root
+	parts := strings.Split(rel, string(filepath.Separator))
+	for _, part := range parts[:len(parts)-1] {
+		dir = filepath.Join(dir, part)
+		if skippedDir(part) || i.ignored(dir, true) {
+			return true
+		}
+	}
+	return i.ignored(p, false)
+}
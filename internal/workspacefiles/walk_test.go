@@ -0,0 +1,123 @@
+package workspacefiles
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeFiles writes files, by path relative to root, creating directories
+func writeFiles(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	}
+}
+
+func TestWalk(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		".gitignore":                  "# build output\ndist/\n*.min.css\n/generated.css\n",
+		"styles/button.css":           "",
+		"styles/button.min.css":       "",
+		"styles/theme.scss":           "",
+		"styles/notes.txt":            "",
+		"generated.css":               "",
+		"styles/generated.css":        "",
+		"dist/bundle.css":             "",
+		"node_modules/pkg/index.css":  "",
+		".cache/styles.css":           "",
+		"components/.gitignore":       "legacy-*.js\n!legacy-keep.js\n",
+		"components/card.js":          "",
+		"components/legacy-card.js":   "",
+		"components/legacy-keep.js":   "",
+		"components/docs/example.css": "",
+	})
+
+	var found []string
+	languages := map[string]string{}
+	require.NoError(t, Walk(root, func(path, languageID string) error {
+		rel, err := filepath.Rel(root, path)
+		require.NoError(t, err)
+		found = append(found, filepath.ToSlash(rel))
+		languages[filepath.ToSlash(rel)] = languageID
+		return nil
+	}))
+
+	assert.ElementsMatch(t, []string{
+		"styles/button.css",
+		"styles/theme.scss",
+		"styles/generated.css",
+		"components/card.js",
+		"components/legacy-keep.js",
+		"components/docs/example.css",
+	}, found)
+	assert.Equal(t, "scss", languages["styles/theme.scss"])
+	assert.Equal(t, "javascript", languages["components/card.js"])
+}
+
+func TestWalk_MissingRoot(t *testing.T) {
+	err := Walk(filepath.Join(t.TempDir(), "missing"), func(string, string) error { return nil })
+	assert.Error(t, err)
+}
+
+func TestFindTokenFiles(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		".gitignore":                   "dist/\n",
+		"tokens.json":                  "{}",
+		"tokens/color.tokens.json":     "{}",
+		"tokens/space.tokens.yaml":     "",
+		"design/design-tokens.json":    "{}",
+		"package.json":                 "{}",
+		"dist/tokens.json":             "{}",
+		"node_modules/pkg/tokens.json": "{}",
+		"styles/tokens.css":            "",
+	})
+
+	found, err := FindTokenFiles(root)
+	require.NoError(t, err)
+	var rel []string
+	for _, path := range found {
+		r, err := filepath.Rel(root, path)
+		require.NoError(t, err)
+		rel = append(rel, filepath.ToSlash(r))
+	}
+	assert.ElementsMatch(t, []string{
+		"tokens.json",
+		"tokens/color.tokens.json",
+		"tokens/space.tokens.yaml",
+		"design/design-tokens.json",
+	}, rel)
+}
+
+func TestParseGitignore(t *testing.T) {
+	rules := parseGitignore("/repo", "# comment\n\n*.log\n!keep.log\nbuild/\n/root-only.css\ndocs/**/*.css\ntrailing   \r\n")
+	require.Len(t, rules, 6)
+
+	assert.Equal(t, ignoreRule{dir: "/repo", pattern: "*.log"}, rules[0])
+	assert.Equal(t, ignoreRule{dir: "/repo", pattern: "keep.log", negate: true}, rules[1])
+	assert.Equal(t, ignoreRule{dir: "/repo", pattern: "build", dirOnly: true}, rules[2])
+	assert.Equal(t, ignoreRule{dir: "/repo", pattern: "root-only.css", anchored: true}, rules[3])
+	assert.Equal(t, ignoreRule{dir: "/repo", pattern: "docs/**/*.css", anchored: true}, rules[4])
+	assert.Equal(t, ignoreRule{dir: "/repo", pattern: "trailing"}, rules[5])
+}
+
+func TestIgnorer_Excluded(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		".gitignore": "dist/\n",
+	})
+	ignore := newIgnorer(root)
+
+	assert.False(t, ignore.excluded(filepath.Join(root, "styles", "button.css")))
+	assert.True(t, ignore.excluded(filepath.Join(root, "dist", "bundle.css")))
+	assert.True(t, ignore.excluded(filepath.Join(root, "node_modules", "pkg", "index.css")))
+	assert.True(t, ignore.excluded(filepath.Join(root, ".cache", "styles.css")))
+	assert.True(t, ignore.excluded(filepath.Join(filepath.Dir(root), "elsewhere.css")), "paths outside the root")
+}
@@ -44,3 +44,31 @@ func DetectPullDiagnosticsSupport(rawParams json.RawMessage) bool {
 	// No diagnostic capability found: default to push diagnostics
 	return false
 }
+
+// DetectDiagnosticRefreshSupport detects whether the client supports the
+// workspace/diagnostic/refresh request by parsing the raw initialize params for
+// capabilities.workspace.diagnostics.refreshSupport (LSP 3.17).
+//
+// Returns false if the field is absent, false, or the params can't be parsed.
+func DetectDiagnosticRefreshSupport(rawParams json.RawMessage) bool {
+	var initParams struct {
+		Capabilities struct {
+			Workspace *struct {
+				Diagnostics *struct {
+					RefreshSupport bool `json:"refreshSupport"`
+				} `json:"diagnostics"` // LSP 3.17 field
+			} `json:"workspace"`
+		} `json:"capabilities"`
+	}
+
+	if err := json.Unmarshal(rawParams, &initParams); err != nil {
+		return false
+	}
+
+	workspace := initParams.Capabilities.Workspace
+	if workspace == nil || workspace.Diagnostics == nil {
+		return false
+	}
+
+	return workspace.Diagnostics.RefreshSupport
+}
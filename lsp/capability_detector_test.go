@@ -185,3 +185,46 @@ func TestDetectPullDiagnosticsSupport(t *testing.T) {
 		})
 	}
 }
+
+func TestDetectDiagnosticRefreshSupport(t *testing.T) {
+	tests := []struct {
+		name     string
+		rawJSON  string
+		expected bool
+	}{
+		{
+			name:     "refreshSupport true",
+			rawJSON:  `{"capabilities": {"workspace": {"diagnostics": {"refreshSupport": true}}}}`,
+			expected: true,
+		},
+		{
+			name:     "refreshSupport false",
+			rawJSON:  `{"capabilities": {"workspace": {"diagnostics": {"refreshSupport": false}}}}`,
+			expected: false,
+		},
+		{
+			name:     "no diagnostics workspace capability",
+			rawJSON:  `{"capabilities": {"workspace": {"applyEdit": true}}}`,
+			expected: false,
+		},
+		{
+			name:     "no workspace capabilities",
+			rawJSON:  `{"capabilities": {}}`,
+			expected: false,
+		},
+		{
+			name:     "invalid JSON",
+			rawJSON:  `{invalid`,
+			expected: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := DetectDiagnosticRefreshSupport(json.RawMessage(tt.rawJSON))
+			if result != tt.expected {
+				t.Errorf("DetectDiagnosticRefreshSupport() = %v, want %v", result, tt.expected)
+			}
+		})
+	}
+}
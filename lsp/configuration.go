@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"maps"
+	"path/filepath"
 	"time"
 
 	"bennypowers.dev/asimonim/load"
@@ -12,13 +13,21 @@ import (
 	"bennypowers.dev/asimonim/schema"
 	"bennypowers.dev/asimonim/specifier"
 	"bennypowers.dev/dtls/internal/log"
+	"bennypowers.dev/dtls/internal/parser"
+	"bennypowers.dev/dtls/internal/parser/queries"
+	"bennypowers.dev/dtls/internal/tokens"
+	csshelpers "bennypowers.dev/dtls/lsp/helpers/css"
 	"bennypowers.dev/dtls/lsp/types"
 )
 
-// GetConfig returns the current server configuration (user settings only)
+// GetConfig returns the current server configuration (user settings only),
+// restricted when the workspace is untrusted or the readOnly setting is on
 func (s *Server) GetConfig() types.ServerConfig {
 	s.configMu.RLock()
 	defer s.configMu.RUnlock()
+	if s.workspaceUntrusted || s.config.ReadOnly {
+		return s.config.Restricted()
+	}
 	return s.config
 }
 
@@ -85,6 +94,131 @@ func mergePackageJsonConfig(current, pkg *types.ServerConfig) {
 		current.Resolvers = pkg.Resolvers
 		log.Info("Loaded %d resolvers from config", len(pkg.Resolvers))
 	}
+
+	mergeFeatureToggles(&current.Features, pkg.Features)
+	mergeNamingConfig(&current.Naming, pkg.Naming)
+
+	if current.ShowPrefix == nil && pkg.ShowPrefix != nil {
+		current.ShowPrefix = pkg.ShowPrefix
+		log.Info("Loaded showPrefix from package.json: %v", *pkg.ShowPrefix)
+	}
+
+	if current.CustomPropertiesFiles == nil && pkg.CustomPropertiesFiles != nil {
+		current.CustomPropertiesFiles = pkg.CustomPropertiesFiles
+		log.Info("Loaded %d customPropertiesFiles from config", len(pkg.CustomPropertiesFiles))
+	}
+
+	if current.ThemeFile == "" && pkg.ThemeFile != "" {
+		current.ThemeFile = pkg.ThemeFile
+		log.Info("Loaded themeFile from package.json: %s", pkg.ThemeFile)
+	}
+
+	if current.QueriesDir == "" && pkg.QueriesDir != "" {
+		current.QueriesDir = pkg.QueriesDir
+		log.Info("Loaded queriesDir from package.json: %s", pkg.QueriesDir)
+	}
+
+	if !current.ValueHistory && pkg.ValueHistory {
+		current.ValueHistory = true
+		log.Info("Loaded valueHistory from package.json: %v", pkg.ValueHistory)
+	}
+
+	if !current.CompositeFallbacks && pkg.CompositeFallbacks {
+		current.CompositeFallbacks = true
+		log.Info("Loaded compositeFallbacks from package.json: %v", pkg.CompositeFallbacks)
+	}
+
+	if current.CompositeSerializers == nil && pkg.CompositeSerializers != nil {
+		current.CompositeSerializers = pkg.CompositeSerializers
+		log.Info("Loaded compositeSerializers from package.json: %v", pkg.CompositeSerializers)
+	}
+
+	if !current.HoverPreviews && pkg.HoverPreviews {
+		current.HoverPreviews = true
+		log.Info("Loaded hoverPreviews from package.json: %v", pkg.HoverPreviews)
+	}
+
+	if !current.NonFileDocuments && pkg.NonFileDocuments {
+		current.NonFileDocuments = true
+		log.Info("Loaded nonFileDocuments from package.json: %v", pkg.NonFileDocuments)
+	}
+
+	if current.DimensionDisplay == "" && pkg.DimensionDisplay != "" {
+		current.DimensionDisplay = pkg.DimensionDisplay
+		log.Info("Loaded dimensionDisplay from package.json: %s", pkg.DimensionDisplay)
+	}
+
+	if current.RootFontSize == 0 && pkg.RootFontSize != 0 {
+		current.RootFontSize = pkg.RootFontSize
+		log.Info("Loaded rootFontSize from package.json: %g", pkg.RootFontSize)
+	}
+
+	if current.MaxCompletionItems == 0 && pkg.MaxCompletionItems != 0 {
+		current.MaxCompletionItems = pkg.MaxCompletionItems
+		log.Info("Loaded maxCompletionItems from package.json: %d", pkg.MaxCompletionItems)
+	}
+
+	if current.MaxDocumentColors == 0 && pkg.MaxDocumentColors != 0 {
+		current.MaxDocumentColors = pkg.MaxDocumentColors
+		log.Info("Loaded maxDocumentColors from package.json: %d", pkg.MaxDocumentColors)
+	}
+
+	if current.LanguageOverrides == nil && pkg.LanguageOverrides != nil {
+		current.LanguageOverrides = pkg.LanguageOverrides
+		log.Info("Loaded languageOverrides from package.json: %v", pkg.LanguageOverrides)
+	}
+
+	if current.Scales == nil && pkg.Scales != nil {
+		current.Scales = pkg.Scales
+		log.Info("Loaded %d scales from package.json", len(pkg.Scales))
+	}
+
+	if !current.Strict && pkg.Strict {
+		current.Strict = true
+		log.Info("Loaded strict from package.json: %v", pkg.Strict)
+	}
+
+	// The editor's settings may only say where the access token comes from,
+	// leaving the file to import to package.json
+	if pkg.Figma != nil && (current.Figma == nil || current.Figma.FileKey == "") {
+		figma := *pkg.Figma
+		if current.Figma != nil {
+			figma.TokenEnv, figma.Endpoint = current.Figma.TokenEnv, current.Figma.Endpoint
+		}
+		current.Figma = &figma
+		log.Info("Loaded figma from package.json: file %s", pkg.Figma.FileKey)
+	}
+}
+
+// mergeFeatureToggles fills unset feature toggles from package.json config
+func mergeFeatureToggles(current *types.FeatureToggles, pkg types.FeatureToggles) {
+	merge := func(name string, current **bool, pkg *bool) {
+		if *current == nil && pkg != nil {
+			*current = pkg
+			log.Info("Loaded features.%s from package.json: %v", name, *pkg)
+		}
+	}
+
+	merge("hover", &current.Hover, pkg.Hover)
+	merge("completion", &current.Completion, pkg.Completion)
+	merge("codeActions", &current.CodeActions, pkg.CodeActions)
+	merge("documentColor", &current.DocumentColor, pkg.DocumentColor)
+	merge("semanticTokens", &current.SemanticTokens, pkg.SemanticTokens)
+	merge("diagnostics", &current.Diagnostics, pkg.Diagnostics)
+}
+
+// mergeNamingConfig fills unset naming options from package.json config
+func mergeNamingConfig(current *types.NamingConfig, pkg types.NamingConfig) {
+	merge := func(name string, current *string, pkg string) {
+		if *current == "" && pkg != "" {
+			*current = pkg
+			log.Info("Loaded naming.%s from package.json: %q", name, pkg)
+		}
+	}
+
+	merge("separator", &current.Separator, pkg.Separator)
+	merge("case", &current.Case, pkg.Case)
+	merge("collisions", &current.Collisions, pkg.Collisions)
 }
 
 // GetState returns a snapshot of runtime state (NOT configuration)
@@ -105,38 +239,71 @@ func (s *Server) SetConfig(config types.ServerConfig) {
 	s.config = config
 }
 
+// SetTokensFiles replaces the configured token files, keeping the rest of
+// the configuration as the user set it, unlike SetConfig(GetConfig()), which
+// would store the restricted copy of an untrusted workspace's configuration
+func (s *Server) SetTokensFiles(files []any) {
+	s.configMu.Lock()
+	defer s.configMu.Unlock()
+	s.config.TokensFiles = files
+}
+
 // loadTokensFromConfig loads tokens based on current configuration
 // Matches TypeScript behavior: explicit configuration only, no auto-discovery
 func (s *Server) LoadTokensFromConfig() error {
 	cfg := s.GetConfig()
+	s.shareTokens(cfg)
+	s.applyNameFormat(cfg.Naming)
+	s.applyQueriesDir(cfg.QueriesDir)
+	s.applyLanguageOverrides(cfg.LanguageOverrides)
+	s.applyFigmaImport(cfg.Figma)
+	s.blame.Clear()
 
 	hasTokensFiles := cfg.TokensFiles != nil
 	hasResolvers := cfg.Resolvers != nil
+	hasCustomProperties := cfg.CustomPropertiesFiles != nil
+	hasFigma := cfg.Figma != nil
+
+	if hasTokensFiles || hasResolvers || hasCustomProperties || hasFigma {
+		// Replace existing tokens with the configured files
+		return s.reload(func() error {
+			var errs []error
+
+			if hasTokensFiles {
+				log.Info("Loading %d token files from config", len(cfg.TokensFiles))
+				if err := s.loadExplicitTokenFiles(); err != nil {
+					errs = append(errs, err)
+				}
+			}
 
-	if hasTokensFiles || hasResolvers {
-		// Clear existing tokens before loading configured files
-		s.tokens.Clear()
-
-		var errs []error
+			if hasResolvers {
+				log.Info("Loading %d resolver documents from config", len(cfg.Resolvers))
+				if err := s.loadResolverDocuments(); err != nil {
+					errs = append(errs, err)
+				}
+			}
 
-		if hasTokensFiles {
-			log.Info("Loading %d token files from config", len(cfg.TokensFiles))
-			if err := s.loadExplicitTokenFiles(); err != nil {
-				errs = append(errs, err)
+			// Figma variables arrive asynchronously, so this adds the last import
+			if hasFigma {
+				if err := s.loadFigmaTokens(cfg); err != nil {
+					errs = append(errs, err)
+				}
 			}
-		}
 
-		if hasResolvers {
-			log.Info("Loading %d resolver documents from config", len(cfg.Resolvers))
-			if err := s.loadResolverDocuments(); err != nil {
-				errs = append(errs, err)
+			// Resolve all aliases after loading all tokens
+			s.ResolveAllTokens()
+
+			// Generated properties fill in only what the token files don't define
+			if hasCustomProperties {
+				log.Info("Loading %d custom properties files from config", len(cfg.CustomPropertiesFiles))
+				if err := s.loadCustomPropertiesFiles(); err != nil {
+					errs = append(errs, err)
+				}
 			}
-		}
 
-		// Resolve all aliases after loading all tokens
-		s.ResolveAllTokens()
-		log.Info("Loaded %d tokens total", s.tokens.Count())
-		return errors.Join(errs...)
+			log.Info("Loaded %d tokens total", s.loadTarget().Count())
+			return errors.Join(errs...)
+		})
 	}
 
 	// If tokensFiles is empty or nil, check if we have programmatically loaded files to reload
@@ -154,11 +321,86 @@ func (s *Server) LoadTokensFromConfig() error {
 	return nil
 }
 
-// ResolveAllTokens resolves all alias references in the loaded tokens.
+// applyNameFormat configures CSS variable name formatting on the token manager.
+// Invalid options are logged and the standard naming is used instead.
+func (s *Server) applyNameFormat(naming types.NamingConfig) {
+	format := tokens.NameFormat{
+		Separator:  naming.Separator,
+		Case:       tokens.NameCase(naming.Case),
+		Collisions: tokens.CollisionPolicy(naming.Collisions),
+	}
+	if err := format.Validate(); err != nil {
+		log.Warn("Invalid naming configuration, using default naming: %v", err)
+		format = tokens.NameFormat{}
+	}
+	s.liveTokens().SetNameFormat(format)
+}
+
+// applyQueriesDir points the parsers at the configured tree-sitter query overrides.
+// Relative paths are resolved against the workspace root.
+//
+// The parsers are process-wide, so a daemon's server ignores the setting,
+// which would change how every other client's documents are parsed.
+func (s *Server) applyQueriesDir(dir string) {
+	if s.daemon != nil {
+		if dir != "" {
+			log.Warn("Ignoring queriesDir: the daemon's clients share their parsers")
+		}
+		return
+	}
+	if dir != "" && !filepath.IsAbs(dir) {
+		if root := s.GetState().RootPath; root != "" {
+			dir = filepath.Join(root, dir)
+		}
+	}
+
+	// Pooled parsers compiled with the previous queries are discarded as they
+	// are acquired, so parsers in use elsewhere are never closed under them
+	if queries.SetOverrideDir(dir) {
+		log.Info("Using tree-sitter query overrides from %q", dir)
+	}
+}
+
+// applyLanguageOverrides routes documents with nonstandard language IDs
+// through the configured language's pipeline. Like queriesDir, the setting
+// is process-wide, and a daemon's server ignores it.
+func (s *Server) applyLanguageOverrides(overrides map[string]string) {
+	if s.daemon != nil {
+		if len(overrides) > 0 {
+			log.Warn("Ignoring languageOverrides: the daemon's clients share their parsers")
+		}
+		return
+	}
+	for _, languageID := range parser.SetLanguageAliases(overrides) {
+		log.Warn("Ignoring languageOverrides entry %q: %q is not a supported language", languageID, overrides[languageID])
+	}
+}
+
+// ResolveAllTokens resolves all alias references in the loaded tokens, then
+// computes expression values such as "{spacing.base} * 2" and writes array
+// values such as font family stacks as CSS.
 // This should be called after all token files are loaded.
+//
+// Tokens already published may be in use by readers, so copies of them are
+// resolved in a batch, which replaces the tokens with the copies.
 func (s *Server) ResolveAllTokens() {
-	tokens := s.tokens.GetAll()
-	if len(tokens) == 0 {
+	s.loadTarget().Batch(func(batch *tokens.Manager) {
+		all := batch.GetAll()
+		resolved := make([]*tokens.Token, len(all))
+		for i, token := range all {
+			copied := *token
+			resolved[i] = &copied
+		}
+		resolveTokens(resolved)
+		for _, token := range resolved {
+			_ = batch.Add(token)
+		}
+	})
+}
+
+// resolveTokens resolves the aliases and expressions of a set of tokens
+func resolveTokens(all []*tokens.Token) {
+	if len(all) == 0 {
 		return
 	}
 
@@ -166,16 +408,42 @@ func (s *Server) ResolveAllTokens() {
 	// Use the first token's schema version as a heuristic
 	// (in practice, all tokens in a file should have the same version)
 	version := schema.Draft
-	for _, t := range tokens {
+	for _, t := range all {
 		if t.SchemaVersion != schema.Unknown {
 			version = t.SchemaVersion
 			break
 		}
 	}
 
-	if err := resolver.ResolveAliases(tokens, version); err != nil {
+	if err := resolver.ResolveAliases(all, version); err != nil {
+		log.Warn("Failed to resolve token aliases: %v", err)
+	}
+	// Resolve the aliases left, such as the rest of the file when another
+	// alias is circular
+	if err := tokens.ResolveAliasChains(all); err != nil {
 		log.Warn("Failed to resolve token aliases: %v", err)
 	}
+	if err := tokens.ResolveExpressions(all); err != nil {
+		log.Warn("Failed to compute token expressions: %v", err)
+	}
+	serializeArrayValues(all)
+}
+
+// serializeArrayValues replaces the Value of tokens with array values, which
+// is their JSON, with the CSS serialization, e.g. `"Helvetica Neue", Arial`
+// for a font family stack. RawValue keeps the array.
+func serializeArrayValues(all []*tokens.Token) {
+	for _, t := range all {
+		if !csshelpers.IsArrayValue(t) {
+			continue
+		}
+		value, err := csshelpers.ArrayValue(t)
+		if err != nil {
+			log.Warn("Failed to serialize %s: %v", t.Name, err)
+			continue
+		}
+		t.Value = value
+	}
 }
 
 // validateTokenFilePath validates that a token file path is not empty.
@@ -213,42 +481,63 @@ func parseGroupMarkersFromItem(itemMap map[string]any, defaultGroupMarkers []str
 	return groupMarkers
 }
 
+// parseSchemaVersionFromItem extracts the schemaVersion of a map[string]any
+// item, e.g. "draft" or "2025.10". Returns Unknown, meaning detect the version
+// from the file, if it is not present.
+func parseSchemaVersionFromItem(itemMap map[string]any) (schema.Version, error) {
+	versionVal, ok := itemMap["schemaVersion"]
+	if !ok {
+		return schema.Unknown, nil
+	}
+	name, _ := versionVal.(string)
+	version, err := schema.FromString(name)
+	if err != nil || version == schema.Unknown {
+		return schema.Unknown, fmt.Errorf("token file entry has unknown 'schemaVersion' %q: %v", name, itemMap)
+	}
+	return version, nil
+}
+
 // parseTokenFileItem parses a token file item (string or map[string]any) into path and options.
-// Returns path, prefix, groupMarkers, and error.
-func parseTokenFileItem(item any, defaultPrefix string, defaultGroupMarkers []string) (path, prefix string, groupMarkers []string, err error) {
+// Items without a path return an empty path and nil options.
+func parseTokenFileItem(item any, defaultPrefix string, defaultGroupMarkers []string) (path string, opts *TokenFileOptions, err error) {
 	switch v := item.(type) {
 	case string:
 		if err := validateTokenFilePath(v, "token file path"); err != nil {
-			return "", "", nil, err
+			return "", nil, err
 		}
-		return v, defaultPrefix, defaultGroupMarkers, nil
+		return v, &TokenFileOptions{Prefix: defaultPrefix, GroupMarkers: defaultGroupMarkers}, nil
 
 	case map[string]any:
 		// Extract path
 		pathVal, ok := v["path"]
 		if !ok {
-			return "", "", nil, fmt.Errorf("token file entry missing required 'path' field: %v", v)
+			return "", nil, fmt.Errorf("token file entry missing required 'path' field: %v", v)
 		}
 		path, _ = pathVal.(string)
 		if err := validateTokenFilePath(path, "token file entry 'path'"); err != nil {
-			return "", "", nil, fmt.Errorf("%w: %v", err, v)
+			return "", nil, fmt.Errorf("%w: %v", err, v)
 		}
 
+		opts = &TokenFileOptions{Prefix: defaultPrefix}
+
 		// Extract prefix (optional)
 		if prefixVal, ok := v["prefix"]; ok {
-			prefix, _ = prefixVal.(string)
-		} else {
-			prefix = defaultPrefix
+			opts.Prefix, _ = prefixVal.(string)
 		}
 
 		// Extract groupMarkers (optional)
-		groupMarkers = parseGroupMarkersFromItem(v, defaultGroupMarkers)
+		opts.GroupMarkers = parseGroupMarkersFromItem(v, defaultGroupMarkers)
+
+		// Extract schemaVersion (optional)
+		if opts.SchemaVersion, err = parseSchemaVersionFromItem(v); err != nil {
+			return "", nil, err
+		}
 
-		return path, prefix, groupMarkers, nil
+		return path, opts, nil
 
 	default:
 		// Silently skip unsupported types (matches current behavior)
-		return "", "", nil, nil
+		return "", nil, nil
 	}
 }
 
@@ -314,7 +603,7 @@ func (s *Server) loadExplicitTokenFiles() error {
 
 	for _, item := range cfg.TokensFiles {
 		// Parse the item - can be string or object
-		path, prefix, groupMarkers, err := parseTokenFileItem(item, cfg.Prefix, cfg.GroupMarkers)
+		path, opts, err := parseTokenFileItem(item, cfg.Prefix, cfg.GroupMarkers)
 		if err != nil {
 			errs = append(errs, err)
 			continue
@@ -324,11 +613,6 @@ func (s *Server) loadExplicitTokenFiles() error {
 			continue
 		}
 
-		opts := &TokenFileOptions{
-			Prefix:       prefix,
-			GroupMarkers: groupMarkers,
-		}
-
 		// Normalize path (handles relative, ~/, npm:, and absolute paths)
 		normalizedPath, err := normalizePath(path, state.RootPath)
 		if err != nil {
@@ -381,35 +665,34 @@ func (s *Server) loadFromCDN(fetcher load.Fetcher, specPath string, opts *TokenF
 // reloadPreviouslyLoadedFiles reloads all files that were previously loaded
 // This is used for programmatic loading (e.g., tests using LoadTokenFile)
 func (s *Server) reloadPreviouslyLoadedFiles() error {
-	// Clear existing tokens
-	s.tokens.Clear()
-
 	// Copy loadedFiles to avoid holding the lock during file I/O
 	s.loadedFilesMu.RLock()
 	filesToReload := make(map[string]*TokenFileOptions, len(s.loadedFiles))
 	maps.Copy(filesToReload, s.loadedFiles)
 	s.loadedFilesMu.RUnlock()
 
-	// Reload each previously loaded file with its original options (prefix, groupMarkers)
-	var errs []error
-	for path, opts := range filesToReload {
-		if err := s.loadTokenFileInternal(path, opts); err != nil {
-			errs = append(errs, fmt.Errorf("failed to reload %s: %w", path, err))
-			continue
-		}
-		if len(opts.GroupMarkers) > 0 {
-			log.Info("Reloaded %s (prefix: %s, groupMarkers: %v)\n", path, opts.Prefix, opts.GroupMarkers)
-		} else {
-			log.Info("Reloaded %s (prefix: %s)\n", path, opts.Prefix)
+	return s.reload(func() error {
+		// Reload each previously loaded file with its original options (prefix, groupMarkers)
+		var errs []error
+		for path, opts := range filesToReload {
+			if err := s.loadTokenFileInternal(path, opts); err != nil {
+				errs = append(errs, fmt.Errorf("failed to reload %s: %w", path, err))
+				continue
+			}
+			if len(opts.GroupMarkers) > 0 {
+				log.Info("Reloaded %s (prefix: %s, groupMarkers: %v)\n", path, opts.Prefix, opts.GroupMarkers)
+			} else {
+				log.Info("Reloaded %s (prefix: %s)\n", path, opts.Prefix)
+			}
 		}
-	}
 
-	if len(errs) > 0 {
-		return errors.Join(errs...)
-	}
+		if len(errs) > 0 {
+			return errors.Join(errs...)
+		}
 
-	// Resolve all aliases after reloading all tokens
-	s.ResolveAllTokens()
+		// Resolve all aliases after reloading all tokens
+		s.ResolveAllTokens()
 
-	return nil
+		return nil
+	})
 }
@@ -9,6 +9,10 @@ import (
 	"time"
 
 	"bennypowers.dev/asimonim/load"
+	"bennypowers.dev/asimonim/schema"
+	"bennypowers.dev/dtls/internal/parser"
+	"bennypowers.dev/dtls/internal/parser/queries"
+	"bennypowers.dev/dtls/internal/tokens"
 	"bennypowers.dev/dtls/lsp/types"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -343,6 +347,58 @@ color:
 	})
 }
 
+func TestLoadTokensFromConfig_SchemaVersions(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	// A draft library and a 2025.10 library, neither declaring $schema
+	draft := filepath.Join(tmpDir, "draft.json")
+	require.NoError(t, os.WriteFile(draft, []byte(`{
+		"color": {"$type": "color", "red": {"$value": "#ff0000"}}
+	}`), 0o644))
+	modern := filepath.Join(tmpDir, "modern.json")
+	require.NoError(t, os.WriteFile(modern, []byte(`{
+		"color": {"$type": "color", "blue": {"$value": {"colorSpace": "srgb", "components": [0, 0, 1]}}}
+	}`), 0o644))
+	// A library whose values are ambiguous, configured as 2025.10
+	configured := filepath.Join(tmpDir, "configured.json")
+	require.NoError(t, os.WriteFile(configured, []byte(`{
+		"size": {"$type": "number", "small": {"$value": 4}}
+	}`), 0o644))
+
+	server, err := NewServer()
+	require.NoError(t, err)
+	defer func() { _ = server.Close() }()
+
+	server.SetRootPath(tmpDir)
+	server.SetConfig(types.ServerConfig{
+		TokensFiles: []any{
+			draft,
+			modern,
+			map[string]any{"path": configured, "schemaVersion": "2025.10"},
+		},
+	})
+	require.NoError(t, server.LoadTokensFromConfig())
+
+	manager := server.TokenManager()
+	assert.Equal(t, schema.Draft, manager.GetSchemaVersionForFile(draft))
+	assert.Equal(t, schema.V2025_10, manager.GetSchemaVersionForFile(modern))
+	assert.Equal(t, schema.V2025_10, manager.GetSchemaVersionForFile(configured))
+	for _, path := range []string{draft, modern, configured} {
+		for _, token := range manager.GetBySourceFile(path) {
+			assert.Equal(t, manager.GetSchemaVersionForFile(path), token.SchemaVersion, token.Name)
+		}
+	}
+	assert.Empty(t, manager.SchemaMismatches(draft))
+	assert.Empty(t, manager.SchemaMismatches(modern))
+
+	t.Run("unknown schemaVersion", func(t *testing.T) {
+		server.SetConfig(types.ServerConfig{
+			TokensFiles: []any{map[string]any{"path": draft, "schemaVersion": "1999"}},
+		})
+		assert.ErrorContains(t, server.LoadTokensFromConfig(), "schemaVersion")
+	})
+}
+
 func TestMergePackageJsonConfig(t *testing.T) {
 	t.Run("merges resolvers when current is nil", func(t *testing.T) {
 		current := &types.ServerConfig{
@@ -390,6 +446,44 @@ func TestMergePackageJsonConfig(t *testing.T) {
 		assert.Nil(t, current.Resolvers)
 	})
 
+	t.Run("fills unset feature toggles only", func(t *testing.T) {
+		on, off := true, false
+		current := &types.ServerConfig{
+			Features: types.FeatureToggles{Hover: &on},
+		}
+		pkg := &types.ServerConfig{
+			Features: types.FeatureToggles{Hover: &off, Completion: &off},
+		}
+		mergePackageJsonConfig(current, pkg)
+		assert.True(t, current.Features.HoverEnabled(), "client toggle should take precedence")
+		assert.False(t, current.Features.CompletionEnabled())
+		assert.True(t, current.Features.DiagnosticsEnabled())
+	})
+
+	t.Run("figma access settings come from the editor", func(t *testing.T) {
+		current := &types.ServerConfig{Figma: &types.FigmaConfig{TokenEnv: "MY_FIGMA_TOKEN", Endpoint: "https://figma.internal"}}
+		pkg := &types.ServerConfig{Figma: &types.FigmaConfig{FileKey: "abc123", Prefix: "ds"}}
+		mergePackageJsonConfig(current, pkg)
+		assert.Equal(t, &types.FigmaConfig{
+			FileKey:  "abc123",
+			Prefix:   "ds",
+			TokenEnv: "MY_FIGMA_TOKEN",
+			Endpoint: "https://figma.internal",
+		}, current.Figma)
+		assert.Empty(t, pkg.Figma.TokenEnv, "package.json config is not modified")
+
+		current = &types.ServerConfig{Figma: &types.FigmaConfig{FileKey: "mine"}}
+		mergePackageJsonConfig(current, pkg)
+		assert.Equal(t, "mine", current.Figma.FileKey, "an import configured in the editor takes precedence")
+	})
+
+	t.Run("merges unset naming options", func(t *testing.T) {
+		current := &types.ServerConfig{Naming: types.NamingConfig{Case: "camel"}}
+		pkg := &types.ServerConfig{Naming: types.NamingConfig{Case: "kebab", Separator: "_"}}
+		mergePackageJsonConfig(current, pkg)
+		assert.Equal(t, types.NamingConfig{Case: "camel", Separator: "_"}, current.Naming)
+	})
+
 	t.Run("preserves explicit empty tokensFiles", func(t *testing.T) {
 		current := &types.ServerConfig{
 			GroupMarkers: types.DefaultConfig().GroupMarkers,
@@ -494,6 +588,24 @@ func TestSetRootPath(t *testing.T) {
 	assert.Equal(t, "/test/path", server.RootPath())
 }
 
+func TestSetTokensFiles(t *testing.T) {
+	server, err := NewServer()
+	require.NoError(t, err)
+	defer func() { _ = server.Close() }()
+
+	server.SetConfig(types.ServerConfig{QueriesDir: "queries", NetworkFallback: true})
+	server.SetWorkspaceTrusted(false)
+	server.SetTokensFiles([]any{"tokens.json"})
+
+	// The restriction applies to reading the config, and isn't stored
+	server.SetWorkspaceTrusted(true)
+	config := server.GetConfig()
+	assert.Equal(t, []any{"tokens.json"}, config.TokensFiles)
+	assert.Equal(t, "queries", config.QueriesDir)
+	assert.True(t, config.NetworkFallback)
+	assert.False(t, config.ReadOnly)
+}
+
 // mockFetcher implements load.Fetcher for testing
 type mockFetcher struct {
 	data map[string][]byte
@@ -842,6 +954,26 @@ func TestLoadTokensFromDocumentContent(t *testing.T) {
 		err = server.LoadTokensFromDocumentContent("file:///tmp/tokens.json", "json", `{invalid`)
 		require.Error(t, err)
 	})
+
+	t.Run("resolves copies of published tokens", func(t *testing.T) {
+		server, err := NewServer()
+		require.NoError(t, err)
+		defer func() { _ = server.Close() }()
+
+		err = server.LoadTokensFromDocumentContent("file:///tmp/semantic.json", "json", `{"color": {"text": {"$value": "{color.base}", "$type": "color"}}}`)
+		require.NoError(t, err)
+		published := server.Token("color-text")
+		require.NotNil(t, published)
+		assert.False(t, published.IsResolved)
+
+		err = server.LoadTokensFromDocumentContent("file:///tmp/base.json", "json", `{"color": {"base": {"$value": "#ff0000", "$type": "color"}}}`)
+		require.NoError(t, err)
+
+		assert.False(t, published.IsResolved, "a token readers may hold is not modified")
+		resolved := server.Token("color-text")
+		require.NotNil(t, resolved)
+		assert.True(t, resolved.IsResolved)
+	})
 }
 
 func TestParseAndAddTokens(t *testing.T) {
@@ -965,3 +1097,132 @@ func TestParseAndAddTokens(t *testing.T) {
 		assert.Equal(t, 1, count)
 	})
 }
+
+func TestParseAndAddTokens_NameCollisions(t *testing.T) {
+	collisionJSON, err := os.ReadFile("testdata/tokens/naming_collision.json")
+	require.NoError(t, err)
+
+	camel := func(policy tokens.CollisionPolicy) tokens.NameFormat {
+		return tokens.NameFormat{Case: tokens.NameCaseCamel, Collisions: policy}
+	}
+
+	t.Run("warn keeps both tokens", func(t *testing.T) {
+		server, err := NewServer()
+		require.NoError(t, err)
+		defer func() { _ = server.Close() }()
+		server.TokenManager().SetNameFormat(camel(tokens.CollisionWarn))
+
+		count, err := server.parseAndAddTokens(collisionJSON, "/tmp/tokens.json", "", &TokenFileOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, 2, count)
+	})
+
+	t.Run("first skips later tokens", func(t *testing.T) {
+		server, err := NewServer()
+		require.NoError(t, err)
+		defer func() { _ = server.Close() }()
+		server.TokenManager().SetNameFormat(camel(tokens.CollisionFirst))
+
+		count, err := server.parseAndAddTokens(collisionJSON, "/tmp/tokens.json", "", &TokenFileOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, 1, count)
+		assert.Equal(t, 1, server.TokenManager().Count())
+	})
+
+	t.Run("error reports the collision", func(t *testing.T) {
+		server, err := NewServer()
+		require.NoError(t, err)
+		defer func() { _ = server.Close() }()
+		server.TokenManager().SetNameFormat(camel(tokens.CollisionError))
+
+		count, err := server.parseAndAddTokens(collisionJSON, "/tmp/tokens.json", "", &TokenFileOptions{})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "--colorBrandPrimary")
+		assert.Equal(t, 1, count)
+	})
+}
+
+func TestLoadTokensFromConfig_Naming(t *testing.T) {
+	server, err := NewServer()
+	require.NoError(t, err)
+	defer func() { _ = server.Close() }()
+
+	t.Run("applies naming options", func(t *testing.T) {
+		server.SetConfig(types.ServerConfig{Naming: types.NamingConfig{Separator: "_", Collisions: "first"}})
+		require.NoError(t, server.LoadTokensFromConfig())
+		assert.Equal(t, tokens.NameFormat{Separator: "_", Collisions: tokens.CollisionFirst}, server.TokenManager().NameFormat())
+	})
+
+	t.Run("falls back to default naming when invalid", func(t *testing.T) {
+		server.SetConfig(types.ServerConfig{Naming: types.NamingConfig{Case: "SCREAMING"}})
+		require.NoError(t, server.LoadTokensFromConfig())
+		assert.Equal(t, tokens.NameFormat{}, server.TokenManager().NameFormat())
+	})
+}
+
+func TestLoadTokensFromConfig_QueriesDir(t *testing.T) {
+	server, err := NewServer()
+	require.NoError(t, err)
+	defer func() { _ = server.Close() }()
+	t.Cleanup(func() { queries.SetOverrideDir("") })
+
+	root := t.TempDir()
+	server.SetRootPath(root)
+
+	server.SetConfig(types.ServerConfig{QueriesDir: ".dtls/queries"})
+	require.NoError(t, server.LoadTokensFromConfig())
+	assert.Equal(t, filepath.Join(root, ".dtls/queries"), queries.OverrideDir())
+
+	server.SetConfig(types.ServerConfig{})
+	require.NoError(t, server.LoadTokensFromConfig())
+	assert.Empty(t, queries.OverrideDir())
+}
+
+func TestLoadTokensFromConfig_LanguageOverrides(t *testing.T) {
+	server, err := NewServer()
+	require.NoError(t, err)
+	defer func() { _ = server.Close() }()
+	t.Cleanup(func() { parser.SetLanguageAliases(nil) })
+
+	server.SetConfig(types.ServerConfig{LanguageOverrides: map[string]string{"postcss": "css", "pug": "jade"}})
+	require.NoError(t, server.LoadTokensFromConfig())
+	assert.True(t, parser.IsCSSSupportedLanguage("postcss"))
+	assert.False(t, parser.IsCSSSupportedLanguage("pug"), "unsupported targets are ignored")
+
+	server.SetConfig(types.ServerConfig{})
+	require.NoError(t, server.LoadTokensFromConfig())
+	assert.False(t, parser.IsCSSSupportedLanguage("postcss"))
+}
+
+func TestLoadTokensFromConfig_CustomPropertiesFiles(t *testing.T) {
+	server, err := NewServer()
+	require.NoError(t, err)
+	defer func() { _ = server.Close() }()
+
+	root, err := filepath.Abs("testdata/custom-properties")
+	require.NoError(t, err)
+	server.SetRootPath(root)
+
+	server.SetConfig(types.ServerConfig{
+		TokensFiles:           []any{"tokens.json"},
+		CustomPropertiesFiles: []string{"generated.css"},
+	})
+	require.NoError(t, server.LoadTokensFromConfig())
+
+	generated := filepath.Join(root, "generated.css")
+	assert.True(t, server.IsCustomPropertiesFile(generated))
+	assert.False(t, server.IsCustomPropertiesFile(filepath.Join(root, "tokens.json")))
+
+	// The token file wins over the generated file
+	primary := server.Token("--color-primary")
+	require.NotNil(t, primary)
+	assert.Equal(t, "#0000ff", primary.Value)
+	assert.Equal(t, filepath.Join(root, "tokens.json"), primary.FilePath)
+
+	// Properties only the generated file declares become tokens
+	gap := server.Token("--legacy-gap")
+	require.NotNil(t, gap)
+	assert.Equal(t, "4px", gap.Value)
+	assert.Equal(t, generated, gap.FilePath)
+	assert.Equal(t, uint32(2), gap.Line)
+}
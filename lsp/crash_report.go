@@ -0,0 +1,53 @@
+package lsp
+
+import (
+	"fmt"
+	"runtime/debug"
+	"slices"
+
+	"bennypowers.dev/dtls/internal/crashreport"
+	"bennypowers.dev/dtls/internal/log"
+)
+
+// WriteCrashReport writes a diagnostic bundle describing the server state
+// to a temp file and returns its path. The bundle includes the configuration,
+// the loaded token files, recent log lines, and the given stack trace.
+func (s *Server) WriteCrashReport(reason string, stack []byte) (string, error) {
+	s.loadedFilesMu.RLock()
+	files := make([]string, 0, len(s.loadedFiles))
+	for path := range s.loadedFiles {
+		files = append(files, path)
+	}
+	s.loadedFilesMu.RUnlock()
+	slices.Sort(files)
+
+	return crashreport.Write("", crashreport.Report{
+		Reason:     reason,
+		Config:     s.GetConfig(),
+		TokenFiles: files,
+		LogLines:   log.Recent(),
+		Stack:      stack,
+	})
+}
+
+// ReportCrash writes a crash report and logs its path to stderr.
+// Failures to write the report are logged, never returned, since
+// the server is already on its way out.
+func (s *Server) ReportCrash(reason string, stack []byte) {
+	path, err := s.WriteCrashReport(reason, stack)
+	if err != nil {
+		log.Error("Failed to write crash report: %v", err)
+		return
+	}
+	log.Error("Crash report written to %s - please attach it when filing a bug", path)
+}
+
+// reportPanic is deferred on the transport goroutine. Handler panics are
+// recovered by the middleware, so anything reaching here will take the
+// process down: write a crash report first, then re-panic.
+func (s *Server) reportPanic() {
+	if r := recover(); r != nil {
+		s.ReportCrash(fmt.Sprintf("panic: %v", r), debug.Stack())
+		panic(r)
+	}
+}
@@ -0,0 +1,46 @@
+package lsp
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteCrashReport(t *testing.T) {
+	server, err := NewServer()
+	require.NoError(t, err)
+
+	tokensFile, err := filepath.Abs(filepath.Join("testdata", "tokens", "color_primary.json"))
+	require.NoError(t, err)
+	require.NoError(t, server.LoadTokenFile(tokensFile, "ds"))
+
+	t.Setenv("TMPDIR", t.TempDir())
+	path, err := server.WriteCrashReport("panic: boom", []byte("goroutine 1 [running]:"))
+	require.NoError(t, err)
+
+	content, err := os.ReadFile(path) //nolint:gosec // G304: Path returned by WriteCrashReport - test code only
+	require.NoError(t, err)
+	assert.Contains(t, string(content), "Reason:  panic: boom")
+	assert.Contains(t, string(content), "- "+tokensFile)
+	assert.Contains(t, string(content), "goroutine 1 [running]:")
+}
+
+func TestReportPanic_WritesReportAndRepanics(t *testing.T) {
+	server, err := NewServer()
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	t.Setenv("TMPDIR", dir)
+
+	assert.PanicsWithValue(t, "boom", func() {
+		defer server.reportPanic()
+		panic("boom")
+	})
+
+	reports, err := filepath.Glob(filepath.Join(dir, "dtls-crash-*.txt"))
+	require.NoError(t, err)
+	assert.Len(t, reports, 1)
+}
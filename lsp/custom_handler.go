@@ -3,9 +3,10 @@ package lsp
 import (
 	"encoding/json"
 
+	designtokens "bennypowers.dev/dtls/lsp/methods/designTokens"
 	"bennypowers.dev/dtls/lsp/methods/textDocument/diagnostic"
 	semantictokens "bennypowers.dev/dtls/lsp/methods/textDocument/semanticTokens"
-	"bennypowers.dev/dtls/lsp/types"
+	"bennypowers.dev/dtls/lsp/methods/workspace"
 	"github.com/tliron/glsp"
 	protocol "github.com/tliron/glsp/protocol_3_16"
 )
@@ -35,6 +36,7 @@ func (h *CustomHandler) Handle(context *glsp.Context) (r any, validMethod, valid
 
 		// Store the detected capability in the server for use during initialization
 		h.server.SetClientDiagnosticCapability(supportsPullDiagnostics)
+		h.server.SetDiagnosticRefreshSupport(DetectDiagnosticRefreshSupport(context.Params))
 
 		// Fall through to let the normal initialize handler process the request
 		// (don't return here - we want the standard initialization to proceed)
@@ -49,9 +51,23 @@ func (h *CustomHandler) Handle(context *glsp.Context) (r any, validMethod, valid
 			return nil, true, false, err
 		}
 
-		// Create request context and call our handler
-		req := types.NewRequestContext(h.server, context)
-		result, err := diagnostic.DocumentDiagnostic(req, &params)
+		// Call our handler through the middleware for logging and panic recovery
+		result, err := method(h.server, "textDocument/diagnostic", diagnostic.DocumentDiagnostic)(context, &params)
+		if err != nil {
+			return nil, true, true, err
+		}
+
+		return result, true, true, nil
+	}
+
+	// WORKAROUND: Intercept workspace/diagnostic for LSP 3.17 pull diagnostics
+	if context.Method == "workspace/diagnostic" {
+		var params diagnostic.WorkspaceDiagnosticParams
+		if err := json.Unmarshal(context.Params, &params); err != nil {
+			return nil, true, false, err
+		}
+
+		result, err := method(h.server, "workspace/diagnostic", diagnostic.WorkspaceDiagnostic)(context, &params)
 		if err != nil {
 			return nil, true, true, err
 		}
@@ -66,8 +82,52 @@ func (h *CustomHandler) Handle(context *glsp.Context) (r any, validMethod, valid
 			return nil, true, false, err
 		}
 
-		req := types.NewRequestContext(h.server, context)
-		result, err := semantictokens.SemanticTokensFullDelta(req, &params)
+		result, err := method(h.server, "textDocument/semanticTokens/full/delta", semantictokens.SemanticTokensFullDelta)(context, &params)
+		if err != nil {
+			return nil, true, true, err
+		}
+
+		return result, true, true, nil
+	}
+
+	// Handle designTokens/impactAnalysis, a custom request outside the LSP spec
+	if context.Method == designtokens.ImpactAnalysisMethod {
+		var params designtokens.ImpactAnalysisParams
+		if err := json.Unmarshal(context.Params, &params); err != nil {
+			return nil, true, false, err
+		}
+
+		result, err := method(h.server, designtokens.ImpactAnalysisMethod, designtokens.ImpactAnalysis)(context, &params)
+		if err != nil {
+			return nil, true, true, err
+		}
+
+		return result, true, true, nil
+	}
+
+	// Handle designTokens/query, a custom request for token explorers
+	if context.Method == designtokens.QueryMethod {
+		var params designtokens.QueryParams
+		if err := json.Unmarshal(context.Params, &params); err != nil {
+			return nil, true, false, err
+		}
+
+		result, err := method(h.server, designtokens.QueryMethod, designtokens.Query)(context, &params)
+		if err != nil {
+			return nil, true, true, err
+		}
+
+		return result, true, true, nil
+	}
+
+	// Handle workspace/textDocumentContent (LSP 3.18) for virtual documents
+	if context.Method == workspace.TextDocumentContentMethod {
+		var params workspace.TextDocumentContentParams
+		if err := json.Unmarshal(context.Params, &params); err != nil {
+			return nil, true, false, err
+		}
+
+		result, err := method(h.server, workspace.TextDocumentContentMethod, workspace.TextDocumentContent)(context, &params)
 		if err != nil {
 			return nil, true, true, err
 		}
@@ -7,7 +7,10 @@ import (
 
 	"bennypowers.dev/dtls/internal/documents"
 	"bennypowers.dev/dtls/internal/tokens"
+	"bennypowers.dev/dtls/internal/workspacefiles"
+	designtokens "bennypowers.dev/dtls/lsp/methods/designTokens"
 	"bennypowers.dev/dtls/lsp/methods/textDocument/diagnostic"
+	"bennypowers.dev/dtls/lsp/methods/workspace"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/tliron/glsp"
@@ -95,3 +98,121 @@ func TestCustomHandler_DiagnosticMethod(t *testing.T) {
 	// Delta support was disabled because the implementation lacks proper result caching
 	// and would corrupt client state. See custom_handler.go for details.
 }
+
+func TestCustomHandler_WorkspaceDiagnosticMethod(t *testing.T) {
+	server := &Server{
+		documents:      documents.NewManager(),
+		tokens:         tokens.NewManager(),
+		config:         types.ServerConfig{},
+		loadedFiles:    make(map[string]*TokenFileOptions),
+		workspaceIndex: workspacefiles.NewIndex(),
+	}
+	require.NoError(t, server.tokens.Add(&tokens.Token{Name: "color-old", Value: "#0000ff", Type: "color", Deprecated: true}))
+	require.NoError(t, server.documents.DidOpen("file:///a.css", "css", 1, `.a { color: var(--color-old); }`))
+
+	handler := &CustomHandler{
+		Handler: &protocol.Handler{},
+		server:  server,
+	}
+
+	result, validMethod, validParams, err := handler.Handle(&glsp.Context{
+		Method: "workspace/diagnostic",
+		Params: []byte(`{"previousResultIds": []}`),
+	})
+	require.NoError(t, err)
+	assert.True(t, validMethod)
+	assert.True(t, validParams)
+
+	report, ok := result.(*diagnostic.WorkspaceDiagnosticReport)
+	require.True(t, ok)
+	require.Len(t, report.Items, 1)
+	full, ok := report.Items[0].(diagnostic.WorkspaceFullDocumentDiagnosticReport)
+	require.True(t, ok)
+	assert.Equal(t, "file:///a.css", full.URI)
+	assert.Len(t, full.Items, 1)
+}
+
+func TestCustomHandler_ImpactAnalysisMethod(t *testing.T) {
+	server := &Server{
+		documents:      documents.NewManager(),
+		tokens:         tokens.NewManager(),
+		config:         types.ServerConfig{},
+		loadedFiles:    make(map[string]*TokenFileOptions),
+		workspaceIndex: workspacefiles.NewIndex(),
+	}
+	require.NoError(t, server.tokens.Add(&tokens.Token{Name: "color-primary", Value: "#0000ff", Type: "color"}))
+	require.NoError(t, server.documents.DidOpen("file:///a.css", "css", 1, `.a { color: var(--color-primary); }`))
+
+	handler := &CustomHandler{
+		Handler: &protocol.Handler{},
+		server:  server,
+	}
+
+	result, validMethod, validParams, err := handler.Handle(&glsp.Context{
+		Method: "designTokens/impactAnalysis",
+		Params: []byte(`{"tokenName": "--color-primary", "newValue": "#ff0000"}`),
+	})
+	require.NoError(t, err)
+	assert.True(t, validMethod)
+	assert.True(t, validParams)
+
+	impact, ok := result.(*designtokens.ImpactAnalysisResult)
+	require.True(t, ok)
+	assert.Equal(t, "1 usage in 1 file", impact.Summary)
+}
+
+func TestCustomHandler_QueryMethod(t *testing.T) {
+	server := &Server{
+		documents:   documents.NewManager(),
+		tokens:      tokens.NewManager(),
+		config:      types.ServerConfig{},
+		loadedFiles: make(map[string]*TokenFileOptions),
+	}
+	require.NoError(t, server.tokens.Add(&tokens.Token{Name: "color-primary", Value: "#0000ff", Type: "color"}))
+	require.NoError(t, server.tokens.Add(&tokens.Token{Name: "space-small", Value: "4px", Type: "dimension"}))
+
+	handler := &CustomHandler{
+		Handler: &protocol.Handler{},
+		server:  server,
+	}
+
+	result, validMethod, validParams, err := handler.Handle(&glsp.Context{
+		Method: "designTokens/query",
+		Params: []byte(`{"type": "color"}`),
+	})
+	require.NoError(t, err)
+	assert.True(t, validMethod)
+	assert.True(t, validParams)
+
+	query, ok := result.(*designtokens.QueryResult)
+	require.True(t, ok)
+	require.Len(t, query.Tokens, 1)
+	assert.Equal(t, "--color-primary", query.Tokens[0].CSSVariable)
+}
+
+func TestCustomHandler_TextDocumentContentMethod(t *testing.T) {
+	server := &Server{
+		documents:      documents.NewManager(),
+		tokens:         tokens.NewManager(),
+		config:         types.ServerConfig{},
+		loadedFiles:    make(map[string]*TokenFileOptions),
+		workspaceIndex: workspacefiles.NewIndex(),
+	}
+
+	handler := &CustomHandler{
+		Handler: &protocol.Handler{},
+		server:  server,
+	}
+
+	result, validMethod, validParams, err := handler.Handle(&glsp.Context{
+		Method: "workspace/textDocumentContent",
+		Params: []byte(`{"uri": "dtls://stats"}`),
+	})
+	require.NoError(t, err)
+	assert.True(t, validMethod)
+	assert.True(t, validParams)
+
+	content, ok := result.(*workspace.TextDocumentContentResult)
+	require.True(t, ok)
+	assert.Contains(t, content.Text, "# Design Token Statistics")
+}
@@ -0,0 +1,203 @@
+package lsp
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"bennypowers.dev/dtls/internal/log"
+	"bennypowers.dev/dtls/internal/parser/css"
+	"bennypowers.dev/dtls/internal/tokens"
+	"bennypowers.dev/dtls/internal/uriutil"
+)
+
+// IsCustomPropertiesFile reports whether path is one of the configured
+// generated postcss-custom-properties files
+func (s *Server) IsCustomPropertiesFile(path string) bool {
+	cleanPath := filepath.Clean(path)
+	for _, p := range s.CustomPropertiesFiles() {
+		if p == cleanPath {
+			return true
+		}
+	}
+	return false
+}
+
+// CustomPropertiesFiles returns the configured custom properties files,
+// resolved against the workspace root
+func (s *Server) CustomPropertiesFiles() []string {
+	cfg := s.GetConfig()
+	root := s.GetState().RootPath
+
+	paths := make([]string, 0, len(cfg.CustomPropertiesFiles))
+	for _, p := range cfg.CustomPropertiesFiles {
+		if p == "" {
+			continue
+		}
+		if root != "" && !filepath.IsAbs(p) {
+			p = filepath.Join(root, p)
+		}
+		paths = append(paths, filepath.Clean(p))
+	}
+	return paths
+}
+
+// loadCustomPropertiesFiles adds the properties declared in generated custom
+// properties files as tokens. Token files are the source of truth, so a
+// property that a token file already defines is skipped.
+func (s *Server) loadCustomPropertiesFiles() error {
+	var errs []error
+	for _, path := range s.CustomPropertiesFiles() {
+		count, err := s.loadCustomPropertiesFile(path)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		log.Info("Loaded %d custom properties from %s", count, path)
+	}
+	return errors.Join(errs...)
+}
+
+// loadCustomPropertiesFile adds the custom properties in one generated file as tokens.
+// Returns the number of properties added.
+func (s *Server) loadCustomPropertiesFile(path string) (int, error) {
+	data, err := os.ReadFile(path) //nolint:gosec // G304: Custom properties file paths come from user configuration
+	if err != nil {
+		return 0, fmt.Errorf("failed to read custom properties file %s: %w", path, err)
+	}
+	return s.loadCustomProperties(path, string(data))
+}
+
+// loadCustomProperties replaces the tokens of a generated custom properties
+// file with the properties declared in content.
+// Returns the number of properties added.
+func (s *Server) loadCustomProperties(path, content string) (int, error) {
+	p := css.AcquireParser()
+	defer css.ReleaseParser(p)
+	result, err := p.Parse(content)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse custom properties file %s: %w", path, err)
+	}
+
+	uri := uriutil.PathToURI(path)
+	count := 0
+	replaceFileTokens(s.loadTarget(), path, func(batch *tokens.Manager) {
+		for _, v := range result.Variables {
+			if batch.Get(v.Name) != nil {
+				continue
+			}
+			name := strings.TrimPrefix(v.Name, "--")
+			if err := batch.Add(&tokens.Token{
+				Name:          name,
+				Path:          []string{name},
+				Value:         v.Value,
+				FilePath:      path,
+				DefinitionURI: uri,
+				Line:          v.Range.Start.Line,
+				Character:     v.Range.Start.Character,
+			}); err != nil {
+				log.Warn("Failed to add custom property %s from %s: %v", v.Name, path, err)
+				continue
+			}
+			count++
+		}
+	})
+	return count, nil
+}
+
+// ReloadCustomPropertiesFile replaces the tokens of one generated custom
+// properties file with the properties declared in content, without
+// reloading the other token sources. Only the open documents which
+// reference an added, removed or changed property, and the custom properties
+// files themselves, are re-diagnosed. Pull clients are asked to refresh, and
+// re-pull unchanged results for the other documents.
+func (s *Server) ReloadCustomPropertiesFile(path, content string) error {
+	path = filepath.Clean(path)
+	target := s.loadTarget()
+	before := customPropertyValues(target, path)
+	count, err := s.loadCustomProperties(path, content)
+	if err != nil {
+		return err
+	}
+	after := customPropertyValues(target, path)
+
+	var changed []string
+	for name, value := range before {
+		if v, ok := after[name]; !ok || v != value {
+			changed = append(changed, name)
+		}
+	}
+	for name := range after {
+		if _, ok := before[name]; !ok {
+			changed = append(changed, name)
+		}
+	}
+	log.Info("Reloaded %d custom properties from %s, %d changed", count, path, len(changed))
+	if len(changed) == 0 {
+		return nil
+	}
+
+	glspCtx := s.GLSPContext()
+	if glspCtx == nil {
+		return nil
+	}
+	if s.UsePullDiagnostics() {
+		return s.RefreshDiagnostics(glspCtx)
+	}
+
+	uris := s.documentsReferencing(changed)
+	log.Info("Re-diagnosing %d documents referencing changed custom properties", len(uris))
+	var errs []error
+	for _, uri := range uris {
+		if err := s.PublishDiagnostics(glspCtx, uri); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", uri, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// customPropertyValues returns the values of the tokens a custom properties
+// file declares, by CSS variable name
+func customPropertyValues(manager *tokens.Manager, path string) map[string]string {
+	values := map[string]string{}
+	for _, token := range manager.GetBySourceFile(path) {
+		values[manager.CSSVariableName(token)] = token.Value
+	}
+	return values
+}
+
+// documentsReferencing returns the URIs of the open documents which may
+// reference one of the CSS variables: those the workspace index records a
+// reference in, those whose unsaved content mentions one, and the custom
+// properties files, whose diagnostics compare them to the tokens
+func (s *Server) documentsReferencing(names []string) []string {
+	indexed := map[string]bool{}
+	for _, name := range names {
+		for _, location := range s.WorkspaceIndex().References(name) {
+			indexed[location.URI] = true
+		}
+	}
+
+	var uris []string
+	for _, doc := range s.AllDocuments() {
+		uri := doc.URI()
+		affected := indexed[uri]
+		if !affected && uriutil.IsFileURI(uri) {
+			affected = s.IsCustomPropertiesFile(uriutil.URIToPath(uri))
+		}
+		if !affected {
+			content := doc.Content()
+			affected = slices.ContainsFunc(names, func(name string) bool {
+				return strings.Contains(content, name)
+			})
+		}
+		if affected {
+			uris = append(uris, uri)
+		}
+	}
+	slices.Sort(uris)
+	return uris
+}
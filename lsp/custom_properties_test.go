@@ -0,0 +1,67 @@
+package lsp
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"bennypowers.dev/dtls/internal/uriutil"
+	"bennypowers.dev/dtls/lsp/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tliron/glsp"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+func TestReloadCustomPropertiesFile(t *testing.T) {
+	server, err := NewServer()
+	require.NoError(t, err)
+	defer func() { _ = server.Close() }()
+	server.diagnosticsThrottle = nil
+
+	root := t.TempDir()
+	generated := filepath.Join(root, "generated.css")
+	require.NoError(t, os.WriteFile(generated, []byte(":root {\n  --gap: 4px;\n  --radius: 2px;\n}\n"), 0o600))
+	server.SetRootPath(root)
+	server.SetConfig(types.ServerConfig{CustomPropertiesFiles: []string{"generated.css"}})
+	require.NoError(t, server.LoadTokensFromConfig())
+
+	var published []string
+	server.SetGLSPContext(&glsp.Context{Notify: func(method string, params any) {
+		if method == protocol.ServerTextDocumentPublishDiagnostics {
+			published = append(published, params.(protocol.PublishDiagnosticsParams).URI)
+		}
+	}})
+
+	generatedURI := uriutil.PathToURI(generated)
+	gapURI := "file:///gap.css"
+	radiusURI := "file:///radius.css"
+	unrelatedURI := "file:///unrelated.css"
+	dm := server.DocumentManager()
+	require.NoError(t, dm.DidOpen(generatedURI, "css", 1, ":root {\n  --gap: 4px;\n  --radius: 2px;\n}\n"))
+	require.NoError(t, dm.DidOpen(gapURI, "css", 1, ".a { gap: var(--gap); }"))
+	require.NoError(t, dm.DidOpen(radiusURI, "css", 1, ".a { border-radius: var(--radius); }"))
+	require.NoError(t, dm.DidOpen(unrelatedURI, "css", 1, ".a { color: red; }"))
+
+	t.Run("changed values re-diagnose their references", func(t *testing.T) {
+		published = nil
+		require.NoError(t, server.ReloadCustomPropertiesFile(generated, ":root {\n  --gap: 8px;\n  --radius: 2px;\n}\n"))
+		assert.Equal(t, "8px", server.Token("--gap").Value)
+		assert.Equal(t, "2px", server.Token("--radius").Value)
+		assert.ElementsMatch(t, []string{generatedURI, gapURI}, published)
+	})
+
+	t.Run("removed properties re-diagnose their references", func(t *testing.T) {
+		published = nil
+		require.NoError(t, server.ReloadCustomPropertiesFile(generated, ":root {\n  --gap: 8px;\n}\n"))
+		assert.Nil(t, server.Token("--radius"))
+		assert.ElementsMatch(t, []string{generatedURI, radiusURI}, published)
+	})
+
+	t.Run("unchanged properties re-diagnose nothing", func(t *testing.T) {
+		published = nil
+		require.NoError(t, server.ReloadCustomPropertiesFile(generated, ":root {\n\n  --gap: 8px;\n}\n"))
+		assert.Empty(t, published)
+		assert.Equal(t, uint32(2), server.Token("--gap").Line, "positions are still updated")
+	})
+}
@@ -0,0 +1,202 @@
+package lsp
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"bennypowers.dev/dtls/internal/log"
+	"bennypowers.dev/dtls/internal/tokens"
+	"bennypowers.dev/dtls/lsp/types"
+)
+
+// Daemon serves several editor clients from one process, each over its own
+// connection with its own Server and documents. Clients with the same
+// workspace root and configuration share one token index, so a monorepo's
+// tokens are held in memory once however many editors have it open.
+//
+// Parser query overrides and language overrides are process-wide, so a
+// daemon's clients can't set them: their queriesDir and languageOverrides
+// settings are ignored.
+type Daemon struct {
+	mu      sync.Mutex
+	indexes map[string]*sharedIndex
+	clients sync.WaitGroup
+}
+
+// sharedIndex is a token index and the number of servers using it
+type sharedIndex struct {
+	tokens  *tokens.Manager
+	servers int
+}
+
+// NewDaemon creates a daemon with no clients
+func NewDaemon() *Daemon {
+	return &Daemon{indexes: make(map[string]*sharedIndex)}
+}
+
+// socketName is the file name of the daemon's default socket
+const socketName = "design-tokens-language-server.sock"
+
+// DefaultSocket returns the socket the daemon listens on when none is given:
+// in $XDG_RUNTIME_DIR, or else in a directory of the user's own in the
+// temporary directory, so other users can't connect to the daemon or take
+// its place. The directory is created by ListenSocket.
+func DefaultSocket() string {
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		return filepath.Join(dir, socketName)
+	}
+	return filepath.Join(userSocketDir(), socketName)
+}
+
+// userSocketDir is the user's directory for the default socket when there
+// is no $XDG_RUNTIME_DIR
+func userSocketDir() string {
+	return filepath.Join(os.TempDir(), fmt.Sprintf("design-tokens-language-server-%d", os.Getuid()))
+}
+
+// privateSocketDir creates the user's socket directory, which only the user
+// may enter. A directory which others can enter is refused: another user
+// may have made it.
+func privateSocketDir(dir string) error {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+	info, err := os.Lstat(dir)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() || info.Mode().Perm()&0o077 != 0 {
+		return fmt.Errorf("refusing to listen in %s: other users can access it", dir)
+	}
+	return nil
+}
+
+// ListenSocket listens on the Unix socket at path, replacing a socket left
+// behind by a daemon which is no longer running. The default socket's
+// directory is created if needed (see DefaultSocket).
+func ListenSocket(path string) (net.Listener, error) {
+	if dir := filepath.Dir(path); dir == userSocketDir() {
+		if err := privateSocketDir(dir); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := os.Stat(path); err == nil {
+		if conn, err := net.Dial("unix", path); err == nil {
+			_ = conn.Close()
+			return nil, fmt.Errorf("a daemon is already listening on %s", path)
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, fmt.Errorf("failed to remove stale socket: %w", err)
+		}
+	}
+	return net.Listen("unix", path)
+}
+
+// Serve accepts clients on listener until it is closed, then waits for the
+// connected clients to disconnect
+func (d *Daemon) Serve(listener net.Listener) error {
+	log.Info("Daemon listening on %s", listener.Addr())
+	defer closeParserPools()
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			d.clients.Wait()
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		d.clients.Add(1)
+		go func() {
+			defer d.clients.Done()
+			d.serveClient(conn)
+		}()
+	}
+}
+
+// serveClient serves one client with a server of its own
+func (d *Daemon) serveClient(conn net.Conn) {
+	server, err := NewServer()
+	if err != nil {
+		log.Error("Failed to create LSP server: %v", err)
+		_ = conn.Close()
+		return
+	}
+	server.daemon = d
+	defer func() { _ = server.Close() }()
+
+	log.Info("Client connected")
+	server.serve(conn)
+	log.Info("Client disconnected")
+}
+
+// acquire returns the token index for key, creating it for the first server
+func (d *Daemon) acquire(key string) *tokens.Manager {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	index, ok := d.indexes[key]
+	if !ok {
+		index = &sharedIndex{tokens: tokens.NewManager()}
+		d.indexes[key] = index
+	}
+	index.servers++
+	return index.tokens
+}
+
+// release drops a server's use of the token index for key, freeing it
+// when no server uses it
+func (d *Daemon) release(key string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	index, ok := d.indexes[key]
+	if !ok {
+		return
+	}
+	index.servers--
+	if index.servers <= 0 {
+		delete(d.indexes, key)
+	}
+}
+
+// shareTokens switches a daemon's server to the token index shared by the
+// clients of its workspace root and configuration. Outside a daemon it does
+// nothing.
+func (s *Server) shareTokens(cfg types.ServerConfig) {
+	if s.daemon == nil {
+		return
+	}
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		log.Warn("Not sharing tokens: %v", err)
+		return
+	}
+	key := s.RootPath() + "\x00" + string(data)
+
+	s.reloadMu.Lock()
+	defer s.reloadMu.Unlock()
+	if key == s.sharedKey {
+		return
+	}
+	shared := s.daemon.acquire(key)
+	if s.sharedKey != "" {
+		s.daemon.release(s.sharedKey)
+	}
+	s.sharedKey = key
+	s.sharedTokens.Store(shared)
+}
+
+// unshareTokens releases the server's shared token index, if any
+func (s *Server) unshareTokens() {
+	s.reloadMu.Lock()
+	defer s.reloadMu.Unlock()
+	if s.sharedKey == "" {
+		return
+	}
+	s.daemon.release(s.sharedKey)
+	s.sharedKey = ""
+}
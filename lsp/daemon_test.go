@@ -0,0 +1,132 @@
+package lsp
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"bennypowers.dev/dtls/internal/parser"
+	"bennypowers.dev/dtls/internal/parser/queries"
+	"bennypowers.dev/dtls/lsp/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// daemonServer creates a server of daemon d for the workspace at root
+func daemonServer(t *testing.T, d *Daemon, root string, cfg types.ServerConfig) *Server {
+	t.Helper()
+	server, err := NewServer()
+	require.NoError(t, err)
+	server.daemon = d
+	t.Cleanup(func() { _ = server.Close() })
+	server.SetRootPath(root)
+	server.SetConfig(cfg)
+	require.NoError(t, server.LoadTokensFromConfig())
+	return server
+}
+
+func TestDaemon_SharesTokens(t *testing.T) {
+	root := t.TempDir()
+	tokensFile := filepath.Join(root, "tokens.json")
+	require.NoError(t, os.WriteFile(tokensFile, []byte(`{"color": {"primary": {"$type": "color", "$value": "#ff0000"}}}`), 0o600))
+
+	cfg := types.DefaultConfig()
+	cfg.TokensFiles = []any{tokensFile}
+	d := NewDaemon()
+
+	a := daemonServer(t, d, root, cfg)
+	b := daemonServer(t, d, root, cfg)
+	assert.Same(t, a.TokenManager(), b.TokenManager(), "same workspace and configuration")
+	assert.NotNil(t, b.Token("--color-primary"))
+	assert.NotSame(t, a.DocumentManager(), b.DocumentManager(), "documents are per client")
+
+	prefixed := cfg
+	prefixed.Prefix = "ds"
+	c := daemonServer(t, d, root, prefixed)
+	assert.NotSame(t, a.TokenManager(), c.TokenManager(), "different configuration")
+	assert.NotNil(t, c.Token("--ds-color-primary"))
+
+	require.NoError(t, a.Close())
+	assert.Len(t, d.indexes, 2, "b still uses the shared index")
+	require.NoError(t, b.Close())
+	require.NoError(t, c.Close())
+	assert.Empty(t, d.indexes)
+}
+
+func TestDaemon_Serve(t *testing.T) {
+	// Unix socket paths are short, so t.TempDir() can be too long
+	dir, err := os.MkdirTemp("", "dtls")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = os.RemoveAll(dir) })
+	socket := filepath.Join(dir, "dtls.sock")
+
+	listener, err := ListenSocket(socket)
+	require.NoError(t, err)
+	served := make(chan error, 1)
+	go func() { served <- NewDaemon().Serve(listener) }()
+
+	_, err = ListenSocket(socket)
+	assert.ErrorContains(t, err, "already listening")
+
+	// Each client gets its own server, so both can initialize
+	for range 2 {
+		conn, err := net.Dial("unix", socket)
+		require.NoError(t, err)
+		client := newMessageStream(conn, DefaultMaxMessageSize)
+		require.NoError(t, client.WriteObject(map[string]any{
+			"jsonrpc": "2.0", "id": 1, "method": "initialize", "params": map[string]any{},
+		}))
+
+		var response struct {
+			ID     int `json:"id"`
+			Result struct {
+				Capabilities json.RawMessage `json:"capabilities"`
+			} `json:"result"`
+		}
+		require.NoError(t, client.ReadObject(&response))
+		assert.Equal(t, 1, response.ID)
+		assert.NotEmpty(t, response.Result.Capabilities)
+		require.NoError(t, conn.Close())
+	}
+
+	require.NoError(t, listener.Close())
+	assert.NoError(t, <-served)
+}
+
+func TestDaemon_IgnoresParserOverrides(t *testing.T) {
+	cfg := types.DefaultConfig()
+	cfg.QueriesDir = t.TempDir()
+	cfg.LanguageOverrides = map[string]string{"daemon-css": "css"}
+
+	daemonServer(t, NewDaemon(), t.TempDir(), cfg)
+	assert.NotEqual(t, cfg.QueriesDir, queries.OverrideDir(), "one client's queries would apply to all")
+	assert.False(t, parser.IsCSSSupportedLanguage("daemon-css"), "one client's languages would apply to all")
+}
+
+func TestDefaultSocket(t *testing.T) {
+	runtime := t.TempDir()
+	t.Setenv("XDG_RUNTIME_DIR", runtime)
+	assert.Equal(t, filepath.Join(runtime, socketName), DefaultSocket())
+
+	t.Setenv("XDG_RUNTIME_DIR", "")
+	socket := DefaultSocket()
+	assert.Equal(t, userSocketDir(), filepath.Dir(socket))
+	assert.Contains(t, socket, fmt.Sprint(os.Getuid()), "each user has their own socket")
+}
+
+func TestPrivateSocketDir(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "sockets")
+	require.NoError(t, privateSocketDir(dir))
+	info, err := os.Stat(dir)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o700), info.Mode().Perm())
+	require.NoError(t, privateSocketDir(dir), "the directory is reused")
+
+	shared := filepath.Join(t.TempDir(), "shared")
+	require.NoError(t, os.Mkdir(shared, 0o700))
+	require.NoError(t, os.Chmod(shared, 0o755))
+	assert.ErrorContains(t, privateSocketDir(shared), "other users can access it")
+}
@@ -0,0 +1,150 @@
+package lsp
+
+import (
+	"errors"
+	"fmt"
+
+	"bennypowers.dev/dtls/internal/log"
+	"bennypowers.dev/dtls/lsp/methods/textDocument/diagnostic"
+	"github.com/tliron/glsp"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+// This file is the diagnostics broker: handlers ask for diagnostics to be
+// published or refreshed, and the broker picks the right mechanism for the
+// client (pull via workspace/diagnostic/refresh, or push via publishDiagnostics).
+
+// methodDiagnosticRefresh is the LSP 3.17 server-to-client request asking
+// pull-diagnostics clients to re-request diagnostics for all documents
+const methodDiagnosticRefresh = "workspace/diagnostic/refresh"
+
+// DiagnosticRefreshSupport returns whether the client supports workspace/diagnostic/refresh
+func (s *Server) DiagnosticRefreshSupport() bool {
+	s.configMu.RLock()
+	defer s.configMu.RUnlock()
+	return s.diagnosticRefreshSupport
+}
+
+// SetDiagnosticRefreshSupport records whether the client supports workspace/diagnostic/refresh.
+// Detected from raw initialize params, since glsp v0.2.2 doesn't know about LSP 3.17 capabilities.
+func (s *Server) SetDiagnosticRefreshSupport(supported bool) {
+	s.configMu.Lock()
+	defer s.configMu.Unlock()
+	s.diagnosticRefreshSupport = supported
+}
+
+// RefreshDiagnostics brings diagnostics for all open documents up to date,
+// typically after tokens were reloaded.
+//
+// Pull-diagnostics clients are sent workspace/diagnostic/refresh (if they support it)
+// so they re-request diagnostics; push clients get a publishDiagnostics notification
+// for each open document.
+func (s *Server) RefreshDiagnostics(context *glsp.Context) error {
+	if context == nil {
+		context = s.GLSPContext()
+	}
+	if context == nil {
+		return fmt.Errorf("cannot refresh diagnostics: no client context available")
+	}
+
+	if s.UsePullDiagnostics() {
+		if !s.DiagnosticRefreshSupport() {
+			log.Debug("Client does not support %s, skipping refresh", methodDiagnosticRefresh)
+			return nil
+		}
+		if context.Call == nil {
+			return nil
+		}
+
+		// workspace/diagnostic/refresh is a request: send it from a goroutine so the
+		// message handler loop can read the client's response (see RegisterFileWatchers)
+		go func(ctx *glsp.Context) {
+			var result any
+			ctx.Call(methodDiagnosticRefresh, nil, &result)
+			log.Info("Diagnostic refresh request completed")
+		}(context)
+		return nil
+	}
+
+	var errs []error
+	for _, doc := range s.AllDocuments() {
+		if err := s.PublishDiagnostics(context, doc.URI()); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", doc.URI(), err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// PublishDiagnostics publishes diagnostics for a document.
+// Publishes are coalesced per URI: rapid successive calls for the same document
+// result in a single notification computed against its latest version.
+func (s *Server) PublishDiagnostics(context *glsp.Context, uri string) error {
+	// Select a working context: use passed-in context if non-nil, otherwise fall back to server's context
+	workingContext := context
+	if workingContext == nil {
+		workingContext = s.GLSPContext()
+	}
+
+	// If we still don't have a context, fail fast
+	if workingContext == nil {
+		return fmt.Errorf("cannot publish diagnostics: no client context available")
+	}
+
+	// If server is configured to use pull diagnostics, don't publish (client will request)
+	if s.UsePullDiagnostics() {
+		return nil
+	}
+
+	if s.diagnosticsThrottle == nil {
+		return s.publishDiagnosticsNow(workingContext, uri)
+	}
+
+	s.diagnosticsThrottle.Schedule(workingContext, uri)
+	return nil
+}
+
+// publishThrottledDiagnostics is the diagnosticsThrottle callback.
+// It runs on a timer goroutine, so errors and panics are logged here
+// rather than returned to a handler.
+func (s *Server) publishThrottledDiagnostics(context *glsp.Context, uri string) {
+	defer func() {
+		if r := recover(); r != nil {
+			_ = handlePanic(context, protocol.ServerTextDocumentPublishDiagnostics, r)
+		}
+	}()
+
+	// The client may have switched to pull diagnostics while the publish was pending
+	if s.UsePullDiagnostics() {
+		return
+	}
+
+	if err := s.publishDiagnosticsNow(context, uri); err != nil {
+		log.Warn("Failed to publish diagnostics for %s: %v", uri, err)
+	}
+}
+
+// publishDiagnosticsNow computes and sends diagnostics for a document immediately
+func (s *Server) publishDiagnosticsNow(context *glsp.Context, uri string) error {
+	log.Info("Publishing diagnostics for: %s", uri)
+
+	diagnostics, err := diagnostic.GetDiagnostics(s, uri)
+	if err != nil {
+		return err
+	}
+
+	params := protocol.PublishDiagnosticsParams{
+		URI:         uri,
+		Diagnostics: diagnostics,
+	}
+
+	// Tag with the document version the diagnostics were computed against
+	if doc := s.Document(uri); doc != nil {
+		version := protocol.UInteger(doc.Version())
+		params.Version = &version
+	}
+
+	// Publish diagnostics to the client using the selected context
+	context.Notify(protocol.ServerTextDocumentPublishDiagnostics, params)
+
+	return nil
+}
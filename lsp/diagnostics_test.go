@@ -0,0 +1,122 @@
+package lsp
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tliron/glsp"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+func TestRefreshDiagnostics(t *testing.T) {
+	// newClient returns a glsp.Context recording notifications and requests
+	newClient := func() (*glsp.Context, func() ([]string, []string)) {
+		var mu sync.Mutex
+		var notified, called []string
+		ctx := &glsp.Context{
+			Notify: func(method string, params any) {
+				mu.Lock()
+				defer mu.Unlock()
+				if p, ok := params.(protocol.PublishDiagnosticsParams); ok {
+					notified = append(notified, p.URI)
+				}
+			},
+			Call: func(method string, params any, result any) {
+				mu.Lock()
+				defer mu.Unlock()
+				called = append(called, method)
+			},
+		}
+		return ctx, func() ([]string, []string) {
+			mu.Lock()
+			defer mu.Unlock()
+			return append([]string(nil), notified...), append([]string(nil), called...)
+		}
+	}
+
+	newServer := func(t *testing.T) *Server {
+		t.Helper()
+		server, err := NewServer()
+		require.NoError(t, err)
+		server.diagnosticsThrottle = nil // publish synchronously
+		require.NoError(t, server.DocumentManager().DidOpen("file:///a.css", "css", 1, `.a { color: red; }`))
+		require.NoError(t, server.DocumentManager().DidOpen("file:///b.css", "css", 1, `.b { color: blue; }`))
+		return server
+	}
+
+	t.Run("push clients get publishDiagnostics for each open document", func(t *testing.T) {
+		server := newServer(t)
+		ctx, recorded := newClient()
+
+		require.NoError(t, server.RefreshDiagnostics(ctx))
+
+		notified, called := recorded()
+		assert.ElementsMatch(t, []string{"file:///a.css", "file:///b.css"}, notified)
+		assert.Empty(t, called)
+	})
+
+	t.Run("pull clients with refresh support get workspace/diagnostic/refresh", func(t *testing.T) {
+		server := newServer(t)
+		server.SetUsePullDiagnostics(true)
+		server.SetDiagnosticRefreshSupport(true)
+		ctx, recorded := newClient()
+
+		require.NoError(t, server.RefreshDiagnostics(ctx))
+
+		assert.Eventually(t, func() bool {
+			_, called := recorded()
+			return len(called) == 1
+		}, time.Second, 5*time.Millisecond)
+		notified, called := recorded()
+		assert.Equal(t, []string{"workspace/diagnostic/refresh"}, called)
+		assert.Empty(t, notified)
+	})
+
+	t.Run("pull clients without refresh support get nothing", func(t *testing.T) {
+		server := newServer(t)
+		server.SetUsePullDiagnostics(true)
+		ctx, recorded := newClient()
+
+		require.NoError(t, server.RefreshDiagnostics(ctx))
+
+		time.Sleep(20 * time.Millisecond)
+		notified, called := recorded()
+		assert.Empty(t, notified)
+		assert.Empty(t, called)
+	})
+
+	t.Run("errors without a client context", func(t *testing.T) {
+		server := newServer(t)
+		assert.Error(t, server.RefreshDiagnostics(nil))
+	})
+}
+
+func TestPublishDiagnostics_TokenFile(t *testing.T) {
+	server, err := NewServer()
+	require.NoError(t, err)
+	server.diagnosticsThrottle = nil // publish synchronously
+
+	uri := "file:///tokens.json"
+	content := `{
+  "$schema": "https://www.designtokens.org/schemas/2025.10/format.json",
+  "color": { "brand": { "$type": "color", "$value": "{color.missing}" } }
+}`
+	require.NoError(t, server.DocumentManager().DidOpen(uri, "json", 1, content))
+
+	var published []protocol.PublishDiagnosticsParams
+	ctx := &glsp.Context{
+		Notify: func(method string, params any) {
+			if p, ok := params.(protocol.PublishDiagnosticsParams); ok {
+				published = append(published, p)
+			}
+		},
+	}
+	require.NoError(t, server.PublishDiagnostics(ctx, uri))
+
+	require.Len(t, published, 1)
+	require.Len(t, published[0].Diagnostics, 1)
+	assert.Equal(t, "Unknown design token color.missing", published[0].Diagnostics[0].Message)
+}
@@ -0,0 +1,64 @@
+package lsp
+
+import (
+	"sync"
+	"time"
+
+	"github.com/tliron/glsp"
+)
+
+// defaultDiagnosticsDelay is how long publishDiagnostics notifications for a
+// URI are held back so that rapid successive changes (e.g. reformat-on-save
+// rewriting the buffer several times) coalesce into a single publish.
+const defaultDiagnosticsDelay = 100 * time.Millisecond
+
+// diagnosticsThrottle coalesces outbound publishDiagnostics notifications per URI.
+//
+// The first request for a URI starts a timer; further requests for the same URI
+// before the timer fires are folded into it. When the timer fires, diagnostics are
+// computed once against the latest document version. Different URIs never delay
+// each other.
+type diagnosticsThrottle struct {
+	delay   time.Duration
+	publish func(context *glsp.Context, uri string)
+
+	mu      sync.Mutex
+	pending map[string]*glsp.Context // uri -> most recent context to publish with
+}
+
+// newDiagnosticsThrottle creates a throttle that calls publish at most once per delay for each URI
+func newDiagnosticsThrottle(delay time.Duration, publish func(context *glsp.Context, uri string)) *diagnosticsThrottle {
+	return &diagnosticsThrottle{
+		delay:   delay,
+		publish: publish,
+		pending: make(map[string]*glsp.Context),
+	}
+}
+
+// Schedule queues a publish for uri, coalescing with any publish already pending
+func (t *diagnosticsThrottle) Schedule(context *glsp.Context, uri string) {
+	t.mu.Lock()
+	_, alreadyPending := t.pending[uri]
+	t.pending[uri] = context
+	t.mu.Unlock()
+
+	if alreadyPending {
+		return
+	}
+
+	time.AfterFunc(t.delay, func() {
+		t.flush(uri)
+	})
+}
+
+// flush publishes the pending request for uri, if any
+func (t *diagnosticsThrottle) flush(uri string) {
+	t.mu.Lock()
+	context, ok := t.pending[uri]
+	delete(t.pending, uri)
+	t.mu.Unlock()
+
+	if ok {
+		t.publish(context, uri)
+	}
+}
@@ -0,0 +1,107 @@
+package lsp
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tliron/glsp"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+// publishRecorder collects throttled publish calls
+type publishRecorder struct {
+	mu    sync.Mutex
+	calls []string
+}
+
+func (r *publishRecorder) publish(_ *glsp.Context, uri string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.calls = append(r.calls, uri)
+}
+
+func (r *publishRecorder) snapshot() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.calls...)
+}
+
+func TestDiagnosticsThrottle(t *testing.T) {
+	t.Run("coalesces a burst for the same URI", func(t *testing.T) {
+		recorder := &publishRecorder{}
+		throttle := newDiagnosticsThrottle(20*time.Millisecond, recorder.publish)
+
+		for range 5 {
+			throttle.Schedule(nil, "file:///a.css")
+		}
+
+		assert.Eventually(t, func() bool { return len(recorder.snapshot()) == 1 }, time.Second, 5*time.Millisecond)
+		time.Sleep(50 * time.Millisecond)
+		assert.Equal(t, []string{"file:///a.css"}, recorder.snapshot())
+	})
+
+	t.Run("does not coalesce different URIs", func(t *testing.T) {
+		recorder := &publishRecorder{}
+		throttle := newDiagnosticsThrottle(20*time.Millisecond, recorder.publish)
+
+		throttle.Schedule(nil, "file:///a.css")
+		throttle.Schedule(nil, "file:///b.css")
+
+		assert.Eventually(t, func() bool { return len(recorder.snapshot()) == 2 }, time.Second, 5*time.Millisecond)
+		assert.ElementsMatch(t, []string{"file:///a.css", "file:///b.css"}, recorder.snapshot())
+	})
+
+	t.Run("publishes again after the window closes", func(t *testing.T) {
+		recorder := &publishRecorder{}
+		throttle := newDiagnosticsThrottle(10*time.Millisecond, recorder.publish)
+
+		throttle.Schedule(nil, "file:///a.css")
+		assert.Eventually(t, func() bool { return len(recorder.snapshot()) == 1 }, time.Second, 5*time.Millisecond)
+
+		throttle.Schedule(nil, "file:///a.css")
+		assert.Eventually(t, func() bool { return len(recorder.snapshot()) == 2 }, time.Second, 5*time.Millisecond)
+	})
+}
+
+func TestPublishDiagnostics_CoalescesToLatestVersion(t *testing.T) {
+	server, err := NewServer()
+	require.NoError(t, err)
+	server.diagnosticsThrottle = newDiagnosticsThrottle(20*time.Millisecond, server.publishThrottledDiagnostics)
+
+	published := make(chan protocol.PublishDiagnosticsParams, 10)
+	ctx := &glsp.Context{
+		Notify: func(method string, params any) {
+			if method == protocol.ServerTextDocumentPublishDiagnostics {
+				published <- params.(protocol.PublishDiagnosticsParams)
+			}
+		},
+	}
+
+	uri := "file:///test.css"
+	require.NoError(t, server.DocumentManager().DidOpen(uri, "css", 1, `.a { color: red; }`))
+	require.NoError(t, server.PublishDiagnostics(ctx, uri))
+
+	// Simulate a reformat-on-save burst
+	for version := 2; version <= 4; version++ {
+		require.NoError(t, server.DocumentManager().DidChange(uri, version, []protocol.TextDocumentContentChangeEvent{}))
+		require.NoError(t, server.PublishDiagnostics(ctx, uri))
+	}
+
+	select {
+	case params := <-published:
+		assert.Equal(t, uri, params.URI)
+		require.NotNil(t, params.Version)
+		assert.Equal(t, protocol.UInteger(4), *params.Version)
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for publishDiagnostics")
+	}
+
+	select {
+	case params := <-published:
+		t.Fatalf("expected a single coalesced publish, got another for version %v", params.Version)
+	case <-time.After(60 * time.Millisecond):
+	}
+}
@@ -0,0 +1,151 @@
+package lsp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"time"
+
+	"bennypowers.dev/dtls/internal/figma"
+	"bennypowers.dev/dtls/internal/log"
+	"bennypowers.dev/dtls/lsp/types"
+)
+
+// defaultFigmaTokenEnv is the environment variable holding the Figma access
+// token when figma.tokenEnv is not configured
+const defaultFigmaTokenEnv = "FIGMA_TOKEN"
+
+// defaultFigmaRefresh is how often Figma variables are fetched when
+// figma.refreshInterval is not configured
+const defaultFigmaRefresh = 5 * time.Minute
+
+// figmaImport is a running poll of a Figma file's variables
+type figmaImport struct {
+	// settings is the JSON of the configuration the poll was started with
+	settings string
+	cancel   context.CancelFunc
+}
+
+// applyFigmaImport starts polling the configured Figma file, replacing any
+// poll of different settings. Unchanged settings leave the running poll be,
+// since each fetch that finds new variables reloads the configuration.
+func (s *Server) applyFigmaImport(cfg *types.FigmaConfig) {
+	settings := ""
+	if cfg != nil {
+		data, _ := json.Marshal(cfg)
+		settings = string(data)
+	}
+
+	s.figmaMu.Lock()
+	defer s.figmaMu.Unlock()
+	if s.figma != nil {
+		if s.figma.settings == settings {
+			return
+		}
+		s.figma.cancel()
+		s.figma = nil
+		s.figmaTokens.Store(nil)
+	}
+	if cfg == nil {
+		return
+	}
+	if cfg.FileKey == "" {
+		log.Warn("Ignoring figma configuration without a fileKey")
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s.figma = &figmaImport{settings: settings, cancel: cancel}
+	go s.pollFigma(ctx, *cfg)
+}
+
+// stopFigmaImport stops polling Figma, if a poll is running
+func (s *Server) stopFigmaImport() {
+	s.figmaMu.Lock()
+	defer s.figmaMu.Unlock()
+	if s.figma != nil {
+		s.figma.cancel()
+		s.figma = nil
+	}
+}
+
+// pollFigma fetches the variables now, then every refresh interval until ctx is done
+func (s *Server) pollFigma(ctx context.Context, cfg types.FigmaConfig) {
+	interval := defaultFigmaRefresh
+	if cfg.RefreshInterval > 0 {
+		interval = time.Duration(cfg.RefreshInterval) * time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		s.refreshFigma(ctx, cfg)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// refreshFigma fetches the variables, and when they changed since the last
+// fetch, reloads the tokens and brings diagnostics up to date
+func (s *Server) refreshFigma(ctx context.Context, cfg types.FigmaConfig) {
+	tokenEnv := cfg.TokenEnv
+	if tokenEnv == "" {
+		tokenEnv = defaultFigmaTokenEnv
+	}
+	accessToken := os.Getenv(tokenEnv)
+	if accessToken == "" {
+		log.Warn("Not importing Figma variables: set %s to a Figma access token", tokenEnv)
+		return
+	}
+
+	fetchCtx, cancel := context.WithTimeout(ctx, networkTimeout(s.GetConfig()))
+	defer cancel()
+	vars, err := figma.Fetch(fetchCtx, http.DefaultClient, cfg.Endpoint, cfg.FileKey, accessToken)
+	if err != nil {
+		log.Warn("Failed to import Figma variables: %v", err)
+		return
+	}
+	doc, err := figma.Tokens(vars, cfg.Modes)
+	if err != nil {
+		log.Warn("Skipped some Figma variables: %v", err)
+	}
+
+	if current := s.figmaTokens.Load(); current != nil && bytes.Equal(*current, doc) {
+		return
+	}
+	// The poll may have been replaced during the fetch
+	if ctx.Err() != nil {
+		return
+	}
+	s.figmaTokens.Store(&doc)
+	log.Info("Imported %d Figma variables from %s", len(vars.Variables), cfg.FileKey)
+
+	if err := s.LoadTokensFromConfig(); err != nil {
+		log.Warn("Failed to reload tokens after Figma import: %v", err)
+	}
+	if s.GLSPContext() != nil {
+		if err := s.RefreshDiagnostics(nil); err != nil {
+			log.Warn("Failed to refresh diagnostics after Figma import: %v", err)
+		}
+	}
+}
+
+// loadFigmaTokens adds the tokens from the last Figma import, if any. They
+// have no file path, so they are never edited, and they link to the Figma file.
+func (s *Server) loadFigmaTokens(cfg types.ServerConfig) error {
+	doc := s.figmaTokens.Load()
+	if doc == nil {
+		return nil
+	}
+	prefix := cfg.Figma.Prefix
+	if prefix == "" {
+		prefix = cfg.Prefix
+	}
+	_, err := s.parseAndAddTokens(*doc, "", figma.FileURL(cfg.Figma.FileKey), &TokenFileOptions{Prefix: prefix})
+	return err
+}
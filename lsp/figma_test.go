@@ -0,0 +1,85 @@
+package lsp
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"bennypowers.dev/dtls/lsp/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const figmaVariables = `{"status": 200, "error": false, "meta": {
+	"variableCollections": {
+		"c1": {"id": "c1", "name": "color", "modes": [{"modeId": "m1", "name": "Light"}, {"modeId": "m2", "name": "Dark"}], "defaultModeId": "m1"}
+	},
+	"variables": {
+		"v1": {"id": "v1", "name": "blue/500", "variableCollectionId": "c1", "resolvedType": "COLOR",
+			"valuesByMode": {"m1": {"r": 0, "g": 0.4, "b": 1, "a": 1}, "m2": {"r": 0, "g": 0, "b": 1, "a": 1}}},
+		"v2": {"id": "v2", "name": "link", "variableCollectionId": "c1", "resolvedType": "COLOR",
+			"valuesByMode": {"m1": {"type": "VARIABLE_ALIAS", "id": "v1"}, "m2": {"type": "VARIABLE_ALIAS", "id": "v1"}}}
+	}
+}}`
+
+func TestFigmaImport(t *testing.T) {
+	var requests atomic.Int32
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		assert.Equal(t, "secret", r.Header.Get("X-Figma-Token"))
+		_, _ = w.Write([]byte(figmaVariables))
+	}))
+	t.Cleanup(api.Close)
+	t.Setenv("DTLS_TEST_FIGMA_TOKEN", "secret")
+
+	server, err := NewServer()
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = server.Close() })
+
+	cfg := types.DefaultConfig()
+	cfg.Figma = &types.FigmaConfig{
+		FileKey:         "abc123",
+		TokenEnv:        "DTLS_TEST_FIGMA_TOKEN",
+		Endpoint:        api.URL,
+		Prefix:          "ds",
+		RefreshInterval: 3600,
+	}
+	server.SetConfig(cfg)
+	require.NoError(t, server.LoadTokensFromConfig())
+
+	require.Eventually(t, func() bool {
+		return server.Token("--ds-color-link") != nil
+	}, 5*time.Second, 10*time.Millisecond, "the first fetch loads the variables")
+
+	token := server.Token("--ds-color-blue-500")
+	require.NotNil(t, token)
+	assert.Equal(t, "#0066ff", token.Value)
+	assert.Empty(t, token.FilePath, "imported tokens are read-only")
+	assert.Equal(t, "https://www.figma.com/design/abc123", token.DefinitionURI)
+
+	t.Run("reloading keeps the poll and its tokens", func(t *testing.T) {
+		require.NoError(t, server.LoadTokensFromConfig())
+		assert.NotNil(t, server.Token("--ds-color-blue-500"))
+		assert.Equal(t, int32(1), requests.Load())
+	})
+
+	t.Run("changing the mode fetches again", func(t *testing.T) {
+		cfg.Figma = &types.FigmaConfig{
+			FileKey:         "abc123",
+			TokenEnv:        "DTLS_TEST_FIGMA_TOKEN",
+			Endpoint:        api.URL,
+			Prefix:          "ds",
+			Modes:           map[string]string{"color": "Dark"},
+			RefreshInterval: 3600,
+		}
+		server.SetConfig(cfg)
+		require.NoError(t, server.LoadTokensFromConfig())
+
+		assert.Eventually(t, func() bool {
+			token := server.Token("--ds-color-blue-500")
+			return token != nil && token.Value == "#0000ff"
+		}, 5*time.Second, 10*time.Millisecond)
+	})
+}
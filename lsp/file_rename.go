@@ -0,0 +1,74 @@
+package lsp
+
+import (
+	"maps"
+	"path/filepath"
+
+	"bennypowers.dev/dtls/internal/log"
+	"bennypowers.dev/dtls/internal/uriutil"
+	"bennypowers.dev/dtls/lsp/helpers"
+)
+
+// RenameTokenFile retargets loaded token files and configured tokensFiles
+// entries when oldPath (a file or directory) is renamed to newPath.
+// Tokens keep their values but move to the new file path, so features keep
+// working without a reload.
+// Returns true if any loaded file or configuration entry was affected.
+func (s *Server) RenameTokenFile(oldPath, newPath string) bool {
+	type move struct{ from, to string }
+	var moves []move
+
+	s.loadedFilesMu.Lock()
+	for path := range s.loadedFiles {
+		if target, ok := helpers.RenamedPath(path, oldPath, newPath); ok {
+			moves = append(moves, move{path, target})
+		}
+	}
+	for _, m := range moves {
+		s.loadedFiles[m.to] = s.loadedFiles[m.from]
+		delete(s.loadedFiles, m.from)
+	}
+	s.loadedFilesMu.Unlock()
+
+	for _, m := range moves {
+		count := s.liveTokens().RenameSourceFile(m.from, m.to, uriutil.PathToURI(m.to))
+		log.Info("Token file renamed: %s -> %s (%d tokens)", m.from, m.to, count)
+	}
+
+	configChanged := s.renameConfiguredTokenFiles(oldPath, newPath)
+	return len(moves) > 0 || configChanged
+}
+
+// renameConfiguredTokenFiles rewrites tokensFiles entries affected by a rename.
+// Returns true if the configuration changed.
+func (s *Server) renameConfiguredTokenFiles(oldPath, newPath string) bool {
+	cfg := s.GetConfig()
+	rootPath := s.GetState().RootPath
+
+	changed := false
+	tokensFiles := make([]any, len(cfg.TokensFiles))
+	for i, item := range cfg.TokensFiles {
+		tokensFiles[i] = item
+		switch v := item.(type) {
+		case string:
+			if renamed, ok := helpers.RenameConfigPath(v, rootPath, oldPath, newPath); ok {
+				tokensFiles[i] = renamed
+				changed = true
+			}
+		case map[string]any:
+			path, _ := v["path"].(string)
+			if renamed, ok := helpers.RenameConfigPath(path, rootPath, oldPath, newPath); ok {
+				entry := maps.Clone(v)
+				entry["path"] = renamed
+				tokensFiles[i] = entry
+				changed = true
+			}
+		}
+	}
+
+	if changed {
+		s.SetTokensFiles(tokensFiles)
+		log.Info("Updated tokensFiles configuration for rename %s -> %s", filepath.Clean(oldPath), filepath.Clean(newPath))
+	}
+	return changed
+}
@@ -0,0 +1,55 @@
+package lsp
+
+import (
+	"path/filepath"
+	"testing"
+
+	"bennypowers.dev/dtls/internal/tokens"
+	"bennypowers.dev/dtls/internal/uriutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRenameTokenFile(t *testing.T) {
+	root := filepath.FromSlash("/workspace")
+	oldPath := filepath.Join(root, "tokens", "color.json")
+	newPath := filepath.Join(root, "design", "color.json")
+
+	s, err := NewServer()
+	require.NoError(t, err)
+	s.rootPath = root
+	s.config.TokensFiles = []any{
+		"./tokens/color.json",
+		map[string]any{"path": "tokens/color.json", "prefix": "ds"},
+		"other.json",
+	}
+	opts := &TokenFileOptions{Prefix: "ds"}
+	s.loadedFiles[oldPath] = opts
+	require.NoError(t, s.tokens.Add(&tokens.Token{
+		Name:          "color-primary",
+		Value:         "#0000ff",
+		FilePath:      oldPath,
+		DefinitionURI: uriutil.PathToURI(oldPath),
+	}))
+
+	assert.True(t, s.RenameTokenFile(filepath.Join(root, "tokens"), filepath.Join(root, "design")))
+
+	assert.Same(t, opts, s.loadedFiles[newPath])
+	assert.NotContains(t, s.loadedFiles, oldPath)
+	assert.True(t, s.IsTokenFile(newPath))
+
+	token := s.tokens.GetQualified("color-primary", newPath)
+	require.NotNil(t, token, "token should be rekeyed under the new path")
+	assert.Equal(t, uriutil.PathToURI(newPath), token.DefinitionURI)
+	assert.Nil(t, s.tokens.GetQualified("color-primary", oldPath))
+
+	assert.Equal(t, []any{
+		"./design/color.json",
+		map[string]any{"path": "design/color.json", "prefix": "ds"},
+		"other.json",
+	}, s.GetConfig().TokensFiles)
+
+	t.Run("unrelated rename", func(t *testing.T) {
+		assert.False(t, s.RenameTokenFile(filepath.Join(root, "src"), filepath.Join(root, "lib")))
+	})
+}
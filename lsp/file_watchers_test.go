@@ -0,0 +1,151 @@
+package lsp
+
+import (
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"bennypowers.dev/dtls/lsp/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tliron/glsp"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+// registrationCall is a client/(un)registerCapability request sent by the server
+type registrationCall struct {
+	method string
+	params any
+}
+
+// recordingContext returns a glsp.Context whose Call records requests
+func recordingContext() (*glsp.Context, func() []registrationCall) {
+	var mu sync.Mutex
+	var calls []registrationCall
+	ctx := &glsp.Context{
+		Call: func(method string, params any, result any) {
+			mu.Lock()
+			defer mu.Unlock()
+			calls = append(calls, registrationCall{method, params})
+		},
+	}
+	return ctx, func() []registrationCall {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]registrationCall(nil), calls...)
+	}
+}
+
+func setTokensFiles(s *Server, files ...any) {
+	cfg := types.DefaultConfig()
+	cfg.TokensFiles = files
+	s.SetConfig(cfg)
+}
+
+func TestRegisterFileWatchers(t *testing.T) {
+	server, err := NewServer()
+	require.NoError(t, err)
+	server.SetRootPath("/workspace")
+	ctx, calls := recordingContext()
+
+	// Initial registration
+	setTokensFiles(server, "tokens.json")
+	require.NoError(t, server.RegisterFileWatchers(ctx))
+	require.Eventually(t, func() bool { return len(calls()) == 1 }, time.Second, 5*time.Millisecond)
+
+	first := calls()[0]
+	assert.Equal(t, "client/registerCapability", first.method)
+	firstReg := first.params.(protocol.RegistrationParams).Registrations[0]
+	assert.Equal(t, "workspace/didChangeWatchedFiles", firstReg.Method)
+	watchers := firstReg.RegisterOptions.(protocol.DidChangeWatchedFilesRegistrationOptions).Watchers
+	require.Len(t, watchers, 1)
+	assert.Equal(t, "/workspace/tokens.json", watchers[0].GlobPattern)
+
+	// Same tokensFiles: nothing is sent
+	require.NoError(t, server.RegisterFileWatchers(ctx))
+	time.Sleep(20 * time.Millisecond)
+	assert.Len(t, calls(), 1)
+
+	// Changed tokensFiles: unregister the old ID, then register a new one
+	setTokensFiles(server, "tokens.json", map[string]any{"path": "colors.json"})
+	require.NoError(t, server.RegisterFileWatchers(ctx))
+	require.Eventually(t, func() bool { return len(calls()) == 3 }, time.Second, 5*time.Millisecond)
+
+	unregister := calls()[1]
+	assert.Equal(t, "client/unregisterCapability", unregister.method)
+	assert.Equal(t, firstReg.ID, unregister.params.(protocol.UnregistrationParams).Unregisterations[0].ID)
+
+	register := calls()[2]
+	assert.Equal(t, "client/registerCapability", register.method)
+	secondReg := register.params.(protocol.RegistrationParams).Registrations[0]
+	assert.NotEqual(t, firstReg.ID, secondReg.ID, "replacement registration should use a fresh ID")
+	assert.Len(t, secondReg.RegisterOptions.(protocol.DidChangeWatchedFilesRegistrationOptions).Watchers, 2)
+
+	// tokensFiles removed: only unregister
+	setTokensFiles(server)
+	require.NoError(t, server.RegisterFileWatchers(ctx))
+	require.Eventually(t, func() bool { return len(calls()) == 4 }, time.Second, 5*time.Millisecond)
+
+	last := calls()[3]
+	assert.Equal(t, "client/unregisterCapability", last.method)
+	assert.Equal(t, secondReg.ID, last.params.(protocol.UnregistrationParams).Unregisterations[0].ID)
+}
+
+func TestRegisterFileWatchers_NoContext(t *testing.T) {
+	server, err := NewServer()
+	require.NoError(t, err)
+	setTokensFiles(server, "tokens.json")
+
+	assert.NoError(t, server.RegisterFileWatchers(nil))
+	assert.NoError(t, server.RegisterFileWatchers(&glsp.Context{}))
+}
+
+func TestRegisterFileWatchers_SuccessiveCallsStayInOrder(t *testing.T) {
+	server, err := NewServer()
+	require.NoError(t, err)
+	server.SetRootPath("/workspace")
+
+	// A slow client, so that later calls are made while earlier requests are
+	// still being sent
+	var mu sync.Mutex
+	var sent []string
+	ctx := &glsp.Context{
+		Call: func(method string, params any, result any) {
+			time.Sleep(5 * time.Millisecond)
+			mu.Lock()
+			defer mu.Unlock()
+			switch p := params.(type) {
+			case protocol.RegistrationParams:
+				sent = append(sent, "register "+p.Registrations[0].ID)
+			case protocol.UnregistrationParams:
+				sent = append(sent, "unregister "+p.Unregisterations[0].ID)
+			}
+		},
+	}
+
+	for _, files := range [][]any{{"a.json"}, {"b.json"}, {"c.json"}, nil} {
+		setTokensFiles(server, files...)
+		require.NoError(t, server.RegisterFileWatchers(ctx))
+	}
+
+	sentRequests := func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return slices.Clone(sent)
+	}
+	require.Eventually(t, func() bool { return len(sentRequests()) == 6 }, time.Second, 5*time.Millisecond)
+
+	// Each registration is unregistered after it was registered
+	registered := map[string]bool{}
+	for _, request := range sentRequests() {
+		if id, ok := strings.CutPrefix(request, "unregister "); ok {
+			assert.True(t, registered[id], "%s unregistered before it was registered", id)
+			delete(registered, id)
+			continue
+		}
+		registered[strings.TrimPrefix(request, "register ")] = true
+	}
+	assert.Empty(t, registered, "no watchers should be left registered")
+}
@@ -0,0 +1,46 @@
+package lsp
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// FuzzLoadTokensFromJSON checks that malformed token documents (as users type)
+// are rejected with an error instead of panicking the server.
+//
+// Seeds come from testdata/fuzz-seeds. Crashers found by `go test -fuzz` are
+// written to testdata/fuzz/FuzzLoadTokensFromJSON and replayed by plain
+// `go test` runs.
+func FuzzLoadTokensFromJSON(f *testing.F) {
+	seeds, err := filepath.Glob(filepath.Join("testdata", "fuzz-seeds", "*"))
+	if err != nil {
+		f.Fatal(err)
+	}
+	for _, path := range seeds {
+		data, err := os.ReadFile(path) //nolint:gosec // G304: Test fixture path - test code only
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(data, "")
+	}
+	f.Add([]byte(`{}`), "ds")
+	f.Add([]byte(`{"a":{"$value":"{a}"}}`), "")
+	f.Add([]byte(`{"a":{"$value":{"$ref":"#/a"}}}`), "")
+	f.Add([]byte(`[`), "")
+
+	f.Fuzz(func(t *testing.T, data []byte, prefix string) {
+		server, err := NewServer()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Errors are expected for malformed input; only panics are failures
+		_ = server.LoadTokensFromJSON(data, prefix)
+
+		for _, token := range server.TokenManager().GetAll() {
+			_ = token.CSSVariableName()
+			_ = token.DisplayValue()
+		}
+	})
+}
@@ -0,0 +1,52 @@
+package css
+
+import (
+	"fmt"
+	"strings"
+
+	"bennypowers.dev/dtls/internal/tokens"
+)
+
+// IsArrayValue reports whether a token's value is a DTCG array, such as a
+// font family stack, cubic bezier or list of shadows
+func IsArrayValue(token *tokens.Token) bool {
+	_, ok := arrayValue(token)
+	return ok
+}
+
+// ArrayValue writes a token's array value as CSS: a font family stack as a
+// font-family value, a cubic bezier as a cubic-bezier() function, and a list
+// of shadows as a box-shadow value. Aliases must be resolved first.
+func ArrayValue(token *tokens.Token) (string, error) {
+	values, ok := arrayValue(token)
+	if !ok {
+		return "", fmt.Errorf("%s token value is not an array", token.Type)
+	}
+
+	var value string
+	var err error
+	switch strings.ToLower(token.Type) {
+	case "shadow":
+		value, err = expandShadow(values)
+	case "fontfamily":
+		value, err = FormatFontFamilyValue(values)
+	case "cubicbezier":
+		value, err = compositeList("timingFunction", values)
+	default:
+		value, err = compositeList(token.Type, values)
+	}
+	if err != nil {
+		return "", fmt.Errorf("cannot write %s token as CSS: %w", token.Type, err)
+	}
+	return value, nil
+}
+
+// arrayValue returns a token's resolved array value
+func arrayValue(token *tokens.Token) ([]any, bool) {
+	raw := token.RawValue
+	if token.IsResolved && token.ResolvedValue != nil {
+		raw = token.ResolvedValue
+	}
+	values, ok := raw.([]any)
+	return values, ok
+}
@@ -0,0 +1,185 @@
+package css
+
+import (
+	"math"
+	"strconv"
+	"strings"
+
+	"bennypowers.dev/dtls/internal/tokens"
+	"github.com/mazznoer/csscolorparser"
+)
+
+// colorTolerance is how far apart two colors' channels may be and still
+// match, so that a mix written as rounded hex matches its exact value
+const colorTolerance = 1.0/255 + 1e-9
+
+// TokenLookup finds a token by name, or returns nil
+type TokenLookup func(name string) *tokens.Token
+
+// FallbackMatches reports whether a literal var() fallback is a token's
+// value. Values match when they are written alike, ignoring case and
+// whitespace. Besides, a calc() value, such as a computed token's, may be
+// written as its result; a color-mix() with either value is evaluated; and
+// light-dark() fallbacks are checked against the token's light and dark
+// values (see LightDarkValues), or, for tokens without them, its light one
+// against the token's value.
+func FallbackMatches(fallback string, token *tokens.Token, lookup TokenLookup) bool {
+	value := token.Value
+	if IsCSSValueSemanticallyEquivalent(fallback, value) {
+		return true
+	}
+	if strings.HasPrefix(value, "calc(") && IsCSSValueSemanticallyEquivalent(fallback, token.DisplayValue()) {
+		return true
+	}
+	if args, ok := functionArgs(fallback, "light-dark"); ok {
+		if len(args) != 2 {
+			return false
+		}
+		light, dark, ok := LightDarkValues(token, lookup)
+		if !ok {
+			return ColorsMatch(args[0], value)
+		}
+		return ColorsMatch(args[0], light) && ColorsMatch(args[1], dark)
+	}
+	if isColorMix(fallback) || isColorMix(value) {
+		return ColorsMatch(fallback, value)
+	}
+	return false
+}
+
+// LightDarkValues returns a token's values for light and dark color schemes:
+// the arguments of its light-dark() value, or else the values of its light
+// and dark variant tokens, e.g. color.surface.light and color.surface.dark
+// for color.surface. ok is false when it has neither.
+func LightDarkValues(token *tokens.Token, lookup TokenLookup) (light, dark string, ok bool) {
+	if args, ok := functionArgs(token.Value, "light-dark"); ok && len(args) == 2 {
+		return args[0], args[1], true
+	}
+	if lookup == nil {
+		return "", "", false
+	}
+	lightToken, darkToken := lookup(token.Name+".light"), lookup(token.Name+".dark")
+	if lightToken == nil || darkToken == nil {
+		return "", "", false
+	}
+	return lightToken.Value, darkToken.Value, true
+}
+
+// ColorsMatch reports whether two CSS values are the same color: written
+// alike, or evaluating to colors within rounding of each other. Colors which
+// can't be evaluated, such as ones with var() calls or in unsupported color
+// spaces, only match when written alike.
+func ColorsMatch(a, b string) bool {
+	if IsCSSValueSemanticallyEquivalent(a, b) {
+		return true
+	}
+	colorA, okA := evaluateColor(a)
+	colorB, okB := evaluateColor(b)
+	return okA && okB &&
+		math.Abs(colorA.R-colorB.R) <= colorTolerance &&
+		math.Abs(colorA.G-colorB.G) <= colorTolerance &&
+		math.Abs(colorA.B-colorB.B) <= colorTolerance &&
+		math.Abs(colorA.A-colorB.A) <= colorTolerance
+}
+
+// evaluateColor returns the color a CSS value computes to
+func evaluateColor(value string) (csscolorparser.Color, bool) {
+	if args, ok := functionArgs(value, "color-mix"); ok {
+		return evaluateColorMix(args)
+	}
+	color, err := csscolorparser.Parse(strings.TrimSpace(value))
+	return color, err == nil
+}
+
+// isColorMix reports whether a CSS value is a color-mix() call
+func isColorMix(value string) bool {
+	_, ok := functionArgs(value, "color-mix")
+	return ok
+}
+
+// evaluateColorMix evaluates the arguments of a color-mix() call which mixes
+// two colors in srgb, e.g. color-mix(in srgb, red 30%, blue), as CSS Color 5
+// specifies: omitted percentages complete the other's to 100%, percentages
+// summing to other than 100% are scaled to it, and a sum under 100% makes the
+// result that much transparent.
+func evaluateColorMix(args []string) (csscolorparser.Color, bool) {
+	if len(args) != 3 || !strings.EqualFold(strings.Join(strings.Fields(args[0]), " "), "in srgb") {
+		return csscolorparser.Color{}, false
+	}
+	first, p1, ok1 := mixComponent(args[1])
+	second, p2, ok2 := mixComponent(args[2])
+	if !ok1 || !ok2 {
+		return csscolorparser.Color{}, false
+	}
+	switch {
+	case p1 < 0 && p2 < 0:
+		p1, p2 = 50, 50
+	case p1 < 0:
+		p1 = 100 - p2
+	case p2 < 0:
+		p2 = 100 - p1
+	}
+	sum := p1 + p2
+	if sum <= 0 || p1 < 0 || p2 < 0 {
+		return csscolorparser.Color{}, false
+	}
+	w1, w2 := p1/sum, p2/sum
+
+	// Channels are mixed premultiplied by alpha
+	alpha := first.A*w1 + second.A*w2
+	mixed := csscolorparser.Color{A: alpha * math.Min(sum, 100) / 100}
+	if alpha > 0 {
+		mixed.R = (first.R*first.A*w1 + second.R*second.A*w2) / alpha
+		mixed.G = (first.G*first.A*w1 + second.G*second.A*w2) / alpha
+		mixed.B = (first.B*first.A*w1 + second.B*second.A*w2) / alpha
+	}
+	return mixed, true
+}
+
+// mixComponent parses a color-mix() color and its optional percentage, which
+// is -1 when omitted
+func mixComponent(arg string) (color csscolorparser.Color, percentage float64, ok bool) {
+	arg = strings.TrimSpace(arg)
+	percentage = -1
+	if i := strings.LastIndexAny(arg, " \t\n"); i >= 0 && strings.HasSuffix(arg, "%") {
+		p, err := strconv.ParseFloat(arg[i+1:len(arg)-1], 64)
+		if err != nil {
+			return color, 0, false
+		}
+		percentage, arg = p, strings.TrimSpace(arg[:i])
+	}
+	color, ok = evaluateColor(arg)
+	return color, percentage, ok
+}
+
+// functionArgs returns the top-level, comma-separated arguments of a CSS
+// function call such as light-dark(white, black), trimmed. ok is false when
+// value is not a single call of the named function.
+func functionArgs(value, name string) (args []string, ok bool) {
+	value = strings.TrimSpace(value)
+	if len(value) < len(name)+2 || !strings.EqualFold(value[:len(name)], name) || value[len(name)] != '(' || !strings.HasSuffix(value, ")") {
+		return nil, false
+	}
+	inner := value[len(name)+1 : len(value)-1]
+	depth, start := 0, 0
+	for i, r := range inner {
+		switch r {
+		case '(':
+			depth++
+		case ')':
+			if depth--; depth < 0 {
+				// The call closes before the end of value
+				return nil, false
+			}
+		case ',':
+			if depth == 0 {
+				args = append(args, strings.TrimSpace(inner[start:i]))
+				start = i + 1
+			}
+		}
+	}
+	if depth != 0 {
+		return nil, false
+	}
+	return append(args, strings.TrimSpace(inner[start:])), true
+}
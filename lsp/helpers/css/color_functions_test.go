@@ -0,0 +1,74 @@
+package css_test
+
+import (
+	"testing"
+
+	"bennypowers.dev/dtls/internal/tokens"
+	"bennypowers.dev/dtls/lsp/helpers/css"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestColorsMatch(t *testing.T) {
+	tests := []struct {
+		a, b     string
+		expected bool
+	}{
+		{a: "#FFF", b: "white", expected: true},
+		{a: "#ff0000", b: "#fe0000", expected: true},
+		{a: "#ff0000", b: "#fc0000", expected: false},
+		{a: "color-mix(in srgb, white, black)", b: "#808080", expected: true},
+		{a: "color-mix(in srgb, red 25%, blue)", b: "rgb(64, 0, 191)", expected: true},
+		{a: "color-mix(in srgb, red 20%, blue 20%)", b: "rgb(128 0 128 / 40%)", expected: true},
+		{a: "color-mix(in srgb, red, transparent)", b: "rgb(255 0 0 / 50%)", expected: true},
+		{a: "color-mix(in srgb, red 120%, blue)", b: "red", expected: false},
+		{a: "color-mix(in oklch, red, blue)", b: "#800080", expected: false},
+		{a: "color-mix(in srgb, var(--a), blue)", b: "blue", expected: false},
+		{a: "color-mix(in srgb, red, blue)", b: "COLOR-MIX(in srgb, red, blue)", expected: true},
+		{a: "1px", b: "#000", expected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.a+" "+tt.b, func(t *testing.T) {
+			assert.Equal(t, tt.expected, css.ColorsMatch(tt.a, tt.b))
+		})
+	}
+}
+
+func TestFallbackMatches(t *testing.T) {
+	variants := map[string]*tokens.Token{
+		"color.surface.light": {Name: "color.surface.light", Value: "#ffffff"},
+		"color.surface.dark":  {Name: "color.surface.dark", Value: "#000000"},
+	}
+	lookup := func(name string) *tokens.Token { return variants[name] }
+
+	surface := &tokens.Token{Name: "color.surface", Value: "#ffffff", Type: "color"}
+	text := &tokens.Token{Name: "color.text", Value: "light-dark(#111, #eee)", Type: "color"}
+	accent := &tokens.Token{Name: "color.accent", Value: "#ff0000", Type: "color"}
+	mixed := &tokens.Token{Name: "color.muted", Value: "color-mix(in srgb, white, black)", Type: "color"}
+
+	tests := []struct {
+		name     string
+		fallback string
+		token    *tokens.Token
+		expected bool
+	}{
+		{name: "same value", fallback: "#FFFFFF", token: surface, expected: true},
+		{name: "other value", fallback: "#000", token: surface, expected: false},
+		{name: "light-dark of variants", fallback: "light-dark(white, black)", token: surface, expected: true},
+		{name: "light-dark swapping variants", fallback: "light-dark(black, white)", token: surface, expected: false},
+		{name: "light-dark of light-dark value", fallback: "light-dark(#111111, #EEEEEE)", token: text, expected: true},
+		{name: "light-dark of other dark value", fallback: "light-dark(#111, #fff)", token: text, expected: false},
+		{name: "light-dark without variants", fallback: "light-dark(red, darkred)", token: accent, expected: true},
+		{name: "light-dark of other light value", fallback: "light-dark(blue, darkred)", token: accent, expected: false},
+		{name: "light-dark with one argument", fallback: "light-dark(red)", token: accent, expected: false},
+		{name: "color-mix fallback", fallback: "color-mix(in srgb, red 100%, blue 0%)", token: accent, expected: true},
+		{name: "color-mix value", fallback: "#808080", token: mixed, expected: true},
+		{name: "plain colors are compared as written", fallback: "red", token: accent, expected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, css.FallbackMatches(tt.fallback, tt.token, lookup))
+		})
+	}
+}
@@ -0,0 +1,223 @@
+package css
+
+import (
+	"fmt"
+	"strings"
+
+	"bennypowers.dev/dtls/internal/collections"
+	"bennypowers.dev/dtls/internal/tokens"
+)
+
+// compositeTypes are the composite token types that ExpandCompositeValue
+// can write as a single CSS value
+var compositeTypes = collections.NewSet(
+	"shadow", "border", "transition", "typography",
+)
+
+// IsCompositeType reports whether a token type is a composite type which
+// ExpandCompositeValue can expand
+func IsCompositeType(tokenType string) bool {
+	return compositeTypes.Has(strings.ToLower(tokenType))
+}
+
+// ExpandCompositeValue writes a composite token's value as the CSS value of
+// the matching shorthand property, e.g. a shadow as a box-shadow value.
+// Aliases must be resolved first.
+//
+// dropped lists sub-values the CSS value cannot express, such as a
+// typography token's letterSpacing, which the font shorthand has no place for.
+func ExpandCompositeValue(token *tokens.Token) (value string, dropped []string, err error) {
+	raw := token.RawValue
+	if token.IsResolved && token.ResolvedValue != nil {
+		raw = token.ResolvedValue
+	}
+
+	switch strings.ToLower(token.Type) {
+	case "shadow":
+		value, err = expandShadow(raw)
+	case "border":
+		value, err = expandBorder(raw)
+	case "transition":
+		value, err = expandTransition(raw)
+	case "typography":
+		value, dropped, err = expandTypography(raw)
+	default:
+		err = fmt.Errorf("token type %q is not a composite type", token.Type)
+	}
+	if err != nil {
+		return "", nil, fmt.Errorf("cannot expand %s token: %w", token.Type, err)
+	}
+	return value, dropped, nil
+}
+
+// expandShadow writes one shadow or a list of shadows as a box-shadow value
+func expandShadow(raw any) (string, error) {
+	layers, ok := raw.([]any)
+	if !ok {
+		layers = []any{raw}
+	}
+
+	parts := make([]string, 0, len(layers))
+	for _, layer := range layers {
+		obj, ok := layer.(map[string]any)
+		if !ok {
+			return "", fmt.Errorf("shadow value %v is not an object", layer)
+		}
+		values, err := compositeFields(obj, []string{"offsetX", "offsetY", "blur", "spread", "color"})
+		if err != nil {
+			return "", err
+		}
+		if inset, _ := obj["inset"].(bool); inset {
+			values = append([]string{"inset"}, values...)
+		}
+		parts = append(parts, strings.Join(values, " "))
+	}
+	return strings.Join(parts, ", "), nil
+}
+
+// expandBorder writes a border as a border shorthand value
+func expandBorder(raw any) (string, error) {
+	obj, ok := raw.(map[string]any)
+	if !ok {
+		return "", fmt.Errorf("border value %v is not an object", raw)
+	}
+	values, err := compositeFields(obj, []string{"width", "style", "color"})
+	if err != nil {
+		return "", err
+	}
+	return strings.Join(values, " "), nil
+}
+
+// expandTransition writes a transition as a transition shorthand value,
+// without a property name, so it applies to all properties
+func expandTransition(raw any) (string, error) {
+	obj, ok := raw.(map[string]any)
+	if !ok {
+		return "", fmt.Errorf("transition value %v is not an object", raw)
+	}
+	values, err := compositeFields(obj, []string{"duration", "timingFunction", "delay"})
+	if err != nil {
+		return "", err
+	}
+	return strings.Join(values, " "), nil
+}
+
+// expandTypography writes a typography token as a font shorthand value
+func expandTypography(raw any) (string, []string, error) {
+	obj, ok := raw.(map[string]any)
+	if !ok {
+		return "", nil, fmt.Errorf("typography value %v is not an object", raw)
+	}
+
+	var parts []string
+	if weight, ok := obj["fontWeight"]; ok {
+		value, err := compositeField("fontWeight", weight)
+		if err != nil {
+			return "", nil, err
+		}
+		parts = append(parts, value)
+	}
+
+	size, err := requiredField(obj, "fontSize")
+	if err != nil {
+		return "", nil, err
+	}
+	if lineHeight, ok := obj["lineHeight"]; ok {
+		value, err := compositeField("lineHeight", lineHeight)
+		if err != nil {
+			return "", nil, err
+		}
+		size += "/" + value
+	}
+	parts = append(parts, size)
+
+	family, err := requiredField(obj, "fontFamily")
+	if err != nil {
+		return "", nil, err
+	}
+	parts = append(parts, family)
+
+	var dropped []string
+	if _, ok := obj["letterSpacing"]; ok {
+		dropped = append(dropped, "letterSpacing")
+	}
+	return strings.Join(parts, " "), dropped, nil
+}
+
+// compositeFields writes the named sub-values of a composite value, in
+// order. All of them are required.
+func compositeFields(obj map[string]any, names []string) ([]string, error) {
+	values := make([]string, 0, len(names))
+	for _, name := range names {
+		value, err := requiredField(obj, name)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+	return values, nil
+}
+
+// requiredField writes a sub-value which the composite value must have
+func requiredField(obj map[string]any, name string) (string, error) {
+	raw, ok := obj[name]
+	if !ok {
+		return "", fmt.Errorf("missing %s", name)
+	}
+	return compositeField(name, raw)
+}
+
+// compositeField writes a single sub-value of a composite value
+func compositeField(name string, raw any) (string, error) {
+	switch value := raw.(type) {
+	case string:
+		if strings.HasPrefix(value, "{") {
+			return "", fmt.Errorf("%s is an unresolved alias %s", name, value)
+		}
+		if name == "fontFamily" {
+			return FormatFontFamilyValue(value)
+		}
+		return value, nil
+	case float64:
+		return fmt.Sprintf("%g", value), nil
+	case int:
+		return fmt.Sprintf("%d", value), nil
+	case []any:
+		if name == "fontFamily" {
+			return FormatFontFamilyValue(value)
+		}
+		return compositeList(name, value)
+	case map[string]any:
+		// Structured dimension or duration, e.g. {"value": 4, "unit": "px"}
+		if unit, ok := value["unit"].(string); ok {
+			if number, ok := value["value"].(float64); ok {
+				return fmt.Sprintf("%g%s", number, unit), nil
+			}
+		}
+		// Structured color, written in its hex fallback
+		if hex, ok := value["hex"].(string); ok {
+			return hex, nil
+		}
+	}
+	return "", fmt.Errorf("%s has unsupported value %v", name, raw)
+}
+
+// compositeList writes a list sub-value: a font family stack, or a
+// cubic bezier timing function
+func compositeList(name string, values []any) (string, error) {
+	parts := make([]string, 0, len(values))
+	for _, v := range values {
+		part, err := compositeField(name, v)
+		if err != nil {
+			return "", err
+		}
+		parts = append(parts, part)
+	}
+	if name == "timingFunction" {
+		if len(parts) != 4 {
+			return "", fmt.Errorf("timingFunction must have 4 numbers, not %d", len(parts))
+		}
+		return "cubic-bezier(" + strings.Join(parts, ", ") + ")", nil
+	}
+	return strings.Join(parts, ", "), nil
+}
@@ -0,0 +1,164 @@
+package css_test
+
+import (
+	"testing"
+
+	"bennypowers.dev/dtls/internal/tokens"
+	"bennypowers.dev/dtls/lsp/helpers/css"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExpandCompositeValue(t *testing.T) {
+	tests := []struct {
+		name            string
+		token           *tokens.Token
+		expectedValue   string
+		expectedDropped []string
+		expectError     bool
+	}{
+		{
+			name: "shadow",
+			token: &tokens.Token{Type: "shadow", RawValue: map[string]any{
+				"color": "#00000080", "offsetX": "0px", "offsetY": "2px", "blur": "4px", "spread": "0px",
+			}},
+			expectedValue: "0px 2px 4px 0px #00000080",
+		},
+		{
+			name: "layered inset shadow with structured values",
+			token: &tokens.Token{Type: "shadow", RawValue: []any{
+				map[string]any{
+					"color":   map[string]any{"colorSpace": "srgb", "components": []any{0.0, 0.0, 0.0}, "hex": "#000000"},
+					"offsetX": map[string]any{"value": 0.0, "unit": "px"},
+					"offsetY": map[string]any{"value": 1.0, "unit": "px"},
+					"blur":    map[string]any{"value": 2.0, "unit": "px"},
+					"spread":  map[string]any{"value": 0.0, "unit": "px"},
+					"inset":   true,
+				},
+				map[string]any{"color": "red", "offsetX": "1px", "offsetY": "1px", "blur": "0px", "spread": "0px"},
+			}},
+			expectedValue: "inset 0px 1px 2px 0px #000000, 1px 1px 0px 0px red",
+		},
+		{
+			name: "resolved value is preferred",
+			token: &tokens.Token{
+				Type:          "border",
+				RawValue:      map[string]any{"color": "{color.edge}", "width": "1px", "style": "solid"},
+				ResolvedValue: map[string]any{"color": "#ccc", "width": "1px", "style": "solid"},
+				IsResolved:    true,
+			},
+			expectedValue: "1px solid #ccc",
+		},
+		{
+			name: "unresolved alias",
+			token: &tokens.Token{Type: "border", RawValue: map[string]any{
+				"color": "{color.edge}", "width": "1px", "style": "solid",
+			}},
+			expectError: true,
+		},
+		{
+			name: "transition",
+			token: &tokens.Token{Type: "transition", RawValue: map[string]any{
+				"duration": "200ms", "delay": "0ms", "timingFunction": []any{0.5, 0.0, 1.0, 1.0},
+			}},
+			expectedValue: "200ms cubic-bezier(0.5, 0, 1, 1) 0ms",
+		},
+		{
+			name: "typography drops letter spacing",
+			token: &tokens.Token{Type: "typography", RawValue: map[string]any{
+				"fontFamily":    []any{"Red Hat Text", "sans-serif"},
+				"fontSize":      "16px",
+				"fontWeight":    400.0,
+				"lineHeight":    1.5,
+				"letterSpacing": "0.1px",
+			}},
+			expectedValue:   `400 16px/1.5 "Red Hat Text", sans-serif`,
+			expectedDropped: []string{"letterSpacing"},
+		},
+		{
+			name:        "missing sub-value",
+			token:       &tokens.Token{Type: "border", RawValue: map[string]any{"width": "1px"}},
+			expectError: true,
+		},
+		{
+			name:        "not a composite type",
+			token:       &tokens.Token{Type: "gradient", RawValue: []any{}},
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			value, dropped, err := css.ExpandCompositeValue(tt.token)
+			if tt.expectError {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectedValue, value)
+			assert.Equal(t, tt.expectedDropped, dropped)
+		})
+	}
+}
+
+func TestIsCompositeType(t *testing.T) {
+	assert.True(t, css.IsCompositeType("shadow"))
+	assert.True(t, css.IsCompositeType("Typography"))
+	assert.False(t, css.IsCompositeType("color"))
+}
+
+func TestArrayValue(t *testing.T) {
+	tests := []struct {
+		name     string
+		token    *tokens.Token
+		expected string
+		err      bool
+	}{
+		{
+			name:     "font family stack",
+			token:    &tokens.Token{Type: "fontFamily", RawValue: []any{"Helvetica Neue", "Arial", "sans-serif"}},
+			expected: `"Helvetica Neue", Arial, sans-serif`,
+		},
+		{
+			name:     "cubic bezier",
+			token:    &tokens.Token{Type: "cubicBezier", RawValue: []any{0.42, 0.0, 0.58, 1.0}},
+			expected: "cubic-bezier(0.42, 0, 0.58, 1)",
+		},
+		{
+			name: "shadow list",
+			token: &tokens.Token{Type: "shadow", RawValue: []any{
+				map[string]any{"color": "#000", "offsetX": "0px", "offsetY": "1px", "blur": "2px", "spread": "0px"},
+				map[string]any{"color": "#111", "offsetX": "0px", "offsetY": "4px", "blur": "8px", "spread": "0px"},
+			}},
+			expected: "0px 1px 2px 0px #000, 0px 4px 8px 0px #111",
+		},
+		{
+			name:     "resolved alias of a font family stack",
+			token:    &tokens.Token{Type: "fontFamily", RawValue: "{font.body}", ResolvedValue: []any{"Inter", "system-ui"}, IsResolved: true},
+			expected: "Inter, system-ui",
+		},
+		{
+			name:  "cubic bezier with three numbers",
+			token: &tokens.Token{Type: "cubicBezier", RawValue: []any{0.42, 0.0, 0.58}},
+			err:   true,
+		},
+		{
+			name:  "not an array",
+			token: &tokens.Token{Type: "fontFamily", RawValue: "Inter"},
+			err:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			value, err := css.ArrayValue(tt.token)
+			if tt.err {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.True(t, css.IsArrayValue(tt.token))
+			assert.Equal(t, tt.expected, value)
+		})
+	}
+}
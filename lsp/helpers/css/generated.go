@@ -0,0 +1,25 @@
+package css
+
+import (
+	"slices"
+
+	"bennypowers.dev/dtls/internal/tokens"
+)
+
+// MissingCustomProperties returns the CSS variable names of tokens that no
+// generated custom properties file declares, sorted. Tokens loaded from the
+// generated files themselves have no source to compare, so they are skipped.
+func MissingCustomProperties(manager *tokens.Manager, declared map[string]bool, isGenerated func(path string) bool) []string {
+	missing := []string{}
+	for _, token := range manager.GetAll() {
+		if isGenerated(token.FilePath) {
+			continue
+		}
+		name := manager.CSSVariableName(token)
+		if !declared[name] {
+			missing = append(missing, name)
+		}
+	}
+	slices.Sort(missing)
+	return slices.Compact(missing)
+}
@@ -28,6 +28,12 @@ var (
 		"cursive", "fantasy", "system-ui",
 	)
 
+	// cssWideKeywords are keywords every property accepts, so family names
+	// spelled like them must be quoted
+	cssWideKeywords = collections.NewSet(
+		"inherit", "initial", "unset", "revert", "revert-layer", "default",
+	)
+
 	// cssNamedColors are all valid CSS named color keywords
 	cssNamedColors = collections.NewSet(
 		"transparent", "black", "white", "red", "green",
@@ -68,11 +74,26 @@ var (
 		"violet", "wheat", "whitesmoke", "yellowgreen",
 	)
 
+	// cssDimensionUnits are the units an untyped dimension may have: absolute
+	// and font-relative lengths, then viewport lengths, including the small,
+	// large and dynamic ones, then container query lengths
+	cssDimensionUnits = []string{
+		"px", "rem", "em", "%", "pt", "cm", "mm", "in", "pc", "ex", "ch",
+		"vh", "vw", "vi", "vb", "vmin", "vmax",
+		"svh", "svw", "svi", "svb", "svmin", "svmax",
+		"lvh", "lvw", "lvi", "lvb", "lvmin", "lvmax",
+		"dvh", "dvw", "dvi", "dvb", "dvmin", "dvmax",
+		"cqw", "cqh", "cqi", "cqb", "cqmin", "cqmax",
+	}
+
 	// Regex patterns for CSS value validation
-	cssNumberPattern         = regexp.MustCompile(`^-?\d+(\.\d+)?$`)
-	cssDimensionPattern      = regexp.MustCompile(`^-?\d+(\.\d+)?(px|rem|em|%|vh|vw|pt|cm|mm|in|pc|ex|ch|vmin|vmax)$`)
-	cssIdentifierPattern     = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9-]*$`)
-	fontWeightNumericPattern = regexp.MustCompile(`^\d+$`)
+	cssNumberPattern     = regexp.MustCompile(`^-?\d+(\.\d+)?$`)
+	cssDimensionPattern  = regexp.MustCompile(`^-?\d+(\.\d+)?(` + strings.Join(cssDimensionUnits, "|") + `)$`)
+	cssIdentifierPattern = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9-]*$`)
+	// fontFamilyIdentifierPattern matches a family name which CSS reads as a
+	// single identifier, so it needn't be quoted
+	fontFamilyIdentifierPattern = regexp.MustCompile(`^-?[_a-zA-Z\x{80}-\x{10FFFF}][_a-zA-Z0-9\x{80}-\x{10FFFF}-]*$`)
+	fontWeightNumericPattern    = regexp.MustCompile(`^\d+$`)
 )
 
 // formatFontWeightForCSS formats a font-weight value for CSS.
@@ -158,7 +179,14 @@ func FormatTokenValueForCSS(token *tokens.Token) (string, error) {
 	case "fontweight":
 		return formatFontWeightForCSS(value)
 	case "fontfamily":
+		if values, ok := arrayValue(token); ok {
+			return FormatFontFamilyValue(values)
+		}
 		return FormatFontFamilyValue(value)
+	case "cubicbezier":
+		if IsArrayValue(token) {
+			return ArrayValue(token)
+		}
 	case "":
 		// No type specified, inspect the value to determine if it's safe
 		return formatUntypedTokenForCSS(value)
@@ -173,44 +201,99 @@ func FormatTokenValueForCSS(token *tokens.Token) (string, error) {
 	return "", fmt.Errorf("token type %q cannot be used as CSS fallback value", tokenType)
 }
 
-// FormatFontFamilyValue formats a font family value for CSS.
+// FormatFontFamilyValue formats a font family value for CSS. The value is a
+// single family name, a font-family list written as CSS, or a DTCG array of
+// family names, e.g. ["Helvetica Neue", "Arial", "sans-serif"], which is
+// joined into a comma-separated list with each name quoted as CSS requires.
 // Returns the formatted value or an error if formatting fails.
-func FormatFontFamilyValue(value string) (string, error) {
+func FormatFontFamilyValue(value any) (string, error) {
+	switch v := value.(type) {
+	case string:
+		return formatFontFamilyString(v)
+	case []string:
+		return formatFontFamilyList(v)
+	case []any:
+		names := make([]string, 0, len(v))
+		for _, item := range v {
+			name, ok := item.(string)
+			if !ok {
+				return "", fmt.Errorf("font family %v is not a string", item)
+			}
+			names = append(names, name)
+		}
+		return formatFontFamilyList(names)
+	}
+	return "", fmt.Errorf("font family value %v is not a string or array", value)
+}
+
+// formatFontFamilyString formats a font family value written as a string
+func formatFontFamilyString(value string) (string, error) {
 	value = strings.TrimSpace(value)
 
 	// If it's already quoted, use as-is (assume it's properly formatted)
-	if (strings.HasPrefix(value, "\"") && strings.HasSuffix(value, "\"")) ||
-		(strings.HasPrefix(value, "'") && strings.HasSuffix(value, "'")) {
-		return value, nil
-	}
-
-	// Generic font families don't need quotes
-	if genericFontFamilies.Has(strings.ToLower(value)) {
+	if isQuotedFontFamily(value) {
 		return value, nil
 	}
 
 	// If it contains a comma, it's likely a font-family list (e.g., "Arial, sans-serif")
 	// These are typically already properly formatted, so return as-is
 	if strings.Contains(value, ",") {
-		// Check if it looks like a valid font-family list with quoted names
-		// If it contains quotes, assume it's already properly formatted
-		if strings.Contains(value, "\"") || strings.Contains(value, "'") {
-			return value, nil
-		}
-		// Otherwise, it's a simple comma-separated list, also safe to use
 		return value, nil
 	}
 
-	// If it contains spaces or special characters (but no comma), it needs quoting
-	needsQuoting := strings.ContainsAny(value, " \t\n\"'")
+	return formatFontFamilyName(value)
+}
 
-	if needsQuoting {
-		// Use %q to automatically quote and escape the string
-		return fmt.Sprintf("%q", value), nil
+// formatFontFamilyList joins the family names of an array value into a CSS
+// font-family list
+func formatFontFamilyList(names []string) (string, error) {
+	if len(names) == 0 {
+		return "", fmt.Errorf("font family list is empty")
+	}
+	families := make([]string, 0, len(names))
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		if strings.HasPrefix(name, "{") {
+			return "", fmt.Errorf("font family %s is an unresolved alias", name)
+		}
+		family, err := formatFontFamilyName(name)
+		if err != nil {
+			return "", err
+		}
+		families = append(families, family)
 	}
+	return strings.Join(families, ", "), nil
+}
 
-	// Single-word font names without special chars don't need quotes
-	return value, nil
+// formatFontFamilyName formats a single family name. Generic families and
+// names which are a single CSS identifier are written bare; other names,
+// e.g. ones with spaces, commas or leading digits, and names which would be
+// read as CSS-wide keywords, are quoted.
+func formatFontFamilyName(name string) (string, error) {
+	if name == "" {
+		return "", fmt.Errorf("font family name is empty")
+	}
+	if isQuotedFontFamily(name) || genericFontFamilies.Has(strings.ToLower(name)) {
+		return name, nil
+	}
+	if fontFamilyIdentifierPattern.MatchString(name) && !cssWideKeywords.Has(strings.ToLower(name)) {
+		return name, nil
+	}
+	return quoteCSSString(name), nil
+}
+
+// isQuotedFontFamily reports whether a font family value is a quoted string
+func isQuotedFontFamily(value string) bool {
+	return len(value) >= 2 &&
+		((strings.HasPrefix(value, "\"") && strings.HasSuffix(value, "\"")) ||
+			(strings.HasPrefix(value, "'") && strings.HasSuffix(value, "'")))
+}
+
+// quoteCSSString writes s as a double-quoted CSS string, escaping
+// backslashes, double quotes and newlines
+func quoteCSSString(s string) string {
+	escaped := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\a `).Replace(s)
+	return `"` + escaped + `"`
 }
 
 // isNamedColor checks if a value is a named CSS color
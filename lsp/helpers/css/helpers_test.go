@@ -186,6 +186,15 @@ func TestFormatTokenValueForCSS(t *testing.T) {
 			expectedValue: `"Font \"Name\" Here"`,
 			expectError:   false,
 		},
+		{
+			name: "font family array",
+			token: &tokens.Token{
+				Type:     "fontFamily",
+				Value:    `["Helvetica Neue","Arial","sans-serif"]`,
+				RawValue: []any{"Helvetica Neue", "Arial", "sans-serif"},
+			},
+			expectedValue: `"Helvetica Neue", Arial, sans-serif`,
+		},
 
 		// No type specified - heuristic detection
 		{
@@ -200,6 +209,18 @@ func TestFormatTokenValueForCSS(t *testing.T) {
 			expectedValue: "20px",
 			expectError:   false,
 		},
+		{
+			name:          "untyped container query dimension",
+			token:         &tokens.Token{Value: "50cqi"},
+			expectedValue: "50cqi",
+			expectError:   false,
+		},
+		{
+			name:          "untyped dynamic viewport dimension",
+			token:         &tokens.Token{Value: "100dvh"},
+			expectedValue: "100dvh",
+			expectError:   false,
+		},
 		{
 			name:          "untyped number",
 			token:         &tokens.Token{Value: "3.14"},
@@ -258,7 +279,7 @@ func TestFormatTokenValueForCSS(t *testing.T) {
 func TestFormatFontFamilyValue(t *testing.T) {
 	tests := []struct {
 		name          string
-		input         string
+		input         any
 		expectedValue string
 		expectError   bool
 	}{
@@ -298,6 +319,46 @@ func TestFormatFontFamilyValue(t *testing.T) {
 			expectedValue: `"Font \"Special\" Name"`,
 			expectError:   false,
 		},
+		{
+			name:          "font starting with a digit",
+			input:         "3Dumb",
+			expectedValue: `"3Dumb"`,
+		},
+		{
+			name:          "font named like a CSS-wide keyword",
+			input:         "Initial",
+			expectedValue: `"Initial"`,
+		},
+		{
+			name:          "array",
+			input:         []any{"Helvetica Neue", "Arial", "sans-serif"},
+			expectedValue: `"Helvetica Neue", Arial, sans-serif`,
+		},
+		{
+			name:          "string array",
+			input:         []string{"Inter", "system-ui"},
+			expectedValue: "Inter, system-ui",
+		},
+		{
+			name:          "array with quoted and escaped names",
+			input:         []any{`'Font Awesome'`, `Back\slash`, "Font, Inc.", "monospace"},
+			expectedValue: `'Font Awesome', "Back\\slash", "Font, Inc.", monospace`,
+		},
+		{
+			name:        "array with an unresolved alias",
+			input:       []any{"{font.brand}", "serif"},
+			expectError: true,
+		},
+		{
+			name:        "array with a non-string",
+			input:       []any{"Arial", 400},
+			expectError: true,
+		},
+		{
+			name:        "empty array",
+			input:       []any{},
+			expectError: true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -0,0 +1,82 @@
+package css
+
+import (
+	"slices"
+
+	cssparser "bennypowers.dev/dtls/internal/parser/css"
+	"bennypowers.dev/dtls/lsp/helpers"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+// ToProtocolRange converts a parser range to an LSP range
+func ToProtocolRange(r cssparser.Range) protocol.Range {
+	return protocol.Range{
+		Start: protocol.Position{Line: r.Start.Line, Character: r.Start.Character},
+		End:   protocol.Position{Line: r.End.Line, Character: r.End.Character},
+	}
+}
+
+// InRange reports whether pos is on a parser range, using the half-open
+// semantics of helpers.PositionInRange
+func InRange(pos protocol.Position, r cssparser.Range) bool {
+	return helpers.PositionInRange(pos, ToProtocolRange(r))
+}
+
+// VarCallsAt returns the var() calls under pos, innermost first.
+// For var(--a, var(--b)) with the cursor on --b, that is the --b call, then the --a call.
+func VarCallsAt(pos protocol.Position, varCalls []*cssparser.VarCall) []*cssparser.VarCall {
+	var matches []*cssparser.VarCall
+	for _, varCall := range varCalls {
+		if InRange(pos, varCall.Range) {
+			matches = append(matches, varCall)
+		}
+	}
+	slices.SortStableFunc(matches, func(a, b *cssparser.VarCall) int {
+		return helpers.RangeSize(ToProtocolRange(a.Range)) - helpers.RangeSize(ToProtocolRange(b.Range))
+	})
+	return matches
+}
+
+// VarCallAt returns the innermost var() call under pos, or nil
+func VarCallAt(pos protocol.Position, varCalls []*cssparser.VarCall) *cssparser.VarCall {
+	if matches := VarCallsAt(pos, varCalls); len(matches) > 0 {
+		return matches[0]
+	}
+	return nil
+}
+
+// DeclarationNameAt returns the custom property declaration whose name is under pos,
+// preferring the smallest match, or nil
+func DeclarationNameAt(pos protocol.Position, variables []*cssparser.Variable) *cssparser.Variable {
+	var best *cssparser.Variable
+	bestSize := -1
+	for _, variable := range variables {
+		if !InRange(pos, variable.Range) {
+			continue
+		}
+		if size := helpers.RangeSize(ToProtocolRange(variable.Range)); bestSize == -1 || size < bestSize {
+			best, bestSize = variable, size
+		}
+	}
+	return best
+}
+
+// DeclarationValueAt returns the custom property declaration whose value is under pos, or nil
+func DeclarationValueAt(pos protocol.Position, variables []*cssparser.Variable) *cssparser.Variable {
+	for _, variable := range variables {
+		if InRange(pos, variable.ValueRange) {
+			return variable
+		}
+	}
+	return nil
+}
+
+// AnnotationAt returns the token annotation whose token name is under pos, or nil
+func AnnotationAt(pos protocol.Position, annotations []*cssparser.Annotation) *cssparser.Annotation {
+	for _, annotation := range annotations {
+		if InRange(pos, annotation.Range) {
+			return annotation
+		}
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+package css_test
+
+import (
+	"testing"
+
+	cssparser "bennypowers.dev/dtls/internal/parser/css"
+	"bennypowers.dev/dtls/lsp/helpers/css"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+func parse(t *testing.T, source string) *cssparser.ParseResult {
+	t.Helper()
+	p := cssparser.AcquireParser()
+	defer cssparser.ReleaseParser(p)
+	result, err := p.Parse(source)
+	require.NoError(t, err)
+	return result
+}
+
+// TestVarCallsAt walks every character of a nested var() call and checks which
+// call each one is "on"
+func TestVarCallsAt(t *testing.T) {
+	//         0         1         2         3         4
+	//         0123456789012345678901234567890123456789012345
+	source := "a { b: var(--outer, var(--inner)) c; }"
+	result := parse(t, source)
+	require.Len(t, result.VarCalls, 2)
+
+	for char := range uint32(len(source)) {
+		pos := protocol.Position{Line: 0, Character: char}
+		calls := css.VarCallsAt(pos, result.VarCalls)
+
+		var names []string
+		for _, call := range calls {
+			names = append(names, call.TokenName)
+		}
+
+		switch {
+		case char < 7, char >= 33:
+			assert.Empty(t, names, "character %d (%q)", char, source[char])
+		case char >= 20 && char < 32:
+			assert.Equal(t, []string{"--inner", "--outer"}, names, "character %d (%q)", char, source[char])
+		default:
+			assert.Equal(t, []string{"--outer"}, names, "character %d (%q)", char, source[char])
+		}
+
+		if len(calls) > 0 {
+			assert.Same(t, calls[0], css.VarCallAt(pos, result.VarCalls))
+		} else {
+			assert.Nil(t, css.VarCallAt(pos, result.VarCalls))
+		}
+	}
+}
+
+// TestDeclarationAt tests hit testing on a custom property's name and value
+func TestDeclarationAt(t *testing.T) {
+	//         0         1         2
+	//         0123456789012345678901234567
+	source := ":root { --gap: var(--space); }"
+	result := parse(t, source)
+	require.Len(t, result.Variables, 1)
+	variable := result.Variables[0]
+
+	tests := []struct {
+		char      uint32
+		onName    bool
+		onValue   bool
+		onVarCall bool
+	}{
+		{char: 7},
+		{char: 8, onName: true},
+		{char: 12, onName: true},
+		{char: 13},
+		{char: 14},
+		{char: 15, onValue: true, onVarCall: true},
+		{char: 26, onValue: true, onVarCall: true},
+		{char: 27},
+	}
+
+	for _, tt := range tests {
+		pos := protocol.Position{Line: 0, Character: tt.char}
+		if tt.onName {
+			assert.Same(t, variable, css.DeclarationNameAt(pos, result.Variables), "name at %d", tt.char)
+		} else {
+			assert.Nil(t, css.DeclarationNameAt(pos, result.Variables), "name at %d", tt.char)
+		}
+		if tt.onValue {
+			assert.Same(t, variable, css.DeclarationValueAt(pos, result.Variables), "value at %d", tt.char)
+		} else {
+			assert.Nil(t, css.DeclarationValueAt(pos, result.Variables), "value at %d", tt.char)
+		}
+		assert.Equal(t, tt.onVarCall, css.VarCallAt(pos, result.VarCalls) != nil, "var() at %d", tt.char)
+	}
+}
+
+func TestToProtocolRange(t *testing.T) {
+	r := cssparser.Range{
+		Start: cssparser.Position{Line: 1, Character: 2},
+		End:   cssparser.Position{Line: 3, Character: 4},
+	}
+	assert.Equal(t, protocol.Range{
+		Start: protocol.Position{Line: 1, Character: 2},
+		End:   protocol.Position{Line: 3, Character: 4},
+	}, css.ToProtocolRange(r))
+}
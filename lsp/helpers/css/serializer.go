@@ -0,0 +1,64 @@
+package css
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+
+	"bennypowers.dev/dtls/internal/tokens"
+)
+
+// SerializeCompositeValue writes a composite token's value as CSS with a
+// user-supplied Go text/template, for design systems whose conventions differ
+// from the CSS shorthands ExpandCompositeValue writes. The template is run on
+// the value's sub-values, each written as CSS and keyed by name, e.g.
+//
+//	{{.offsetX}} {{.offsetY}} {{.blur}} {{.color}}
+//
+// Missing sub-values are empty, and true booleans such as a shadow's inset
+// are their name. A list of values, such as layered shadows, runs the
+// template on each and joins them with commas. Runs of whitespace in the
+// output are collapsed, so empty optional sub-values leave no gaps.
+// Aliases must be resolved first.
+func SerializeCompositeValue(token *tokens.Token, serializer string) (string, error) {
+	tmpl, err := template.New(token.Type).Option("missingkey=zero").Parse(serializer)
+	if err != nil {
+		return "", fmt.Errorf("invalid %s serializer: %w", token.Type, err)
+	}
+
+	raw := token.RawValue
+	if token.IsResolved && token.ResolvedValue != nil {
+		raw = token.ResolvedValue
+	}
+	layers, ok := raw.([]any)
+	if !ok {
+		layers = []any{raw}
+	}
+
+	parts := make([]string, 0, len(layers))
+	for _, layer := range layers {
+		obj, ok := layer.(map[string]any)
+		if !ok {
+			return "", fmt.Errorf("cannot serialize %s token: value %v is not an object", token.Type, layer)
+		}
+		data := make(map[string]string, len(obj))
+		for name, value := range obj {
+			if b, ok := value.(bool); ok {
+				if b {
+					data[name] = name
+				}
+				continue
+			}
+			if data[name], err = compositeField(name, value); err != nil {
+				return "", fmt.Errorf("cannot serialize %s token: %w", token.Type, err)
+			}
+		}
+
+		var b strings.Builder
+		if err := tmpl.Execute(&b, data); err != nil {
+			return "", fmt.Errorf("cannot serialize %s token: %w", token.Type, err)
+		}
+		parts = append(parts, strings.Join(strings.Fields(b.String()), " "))
+	}
+	return strings.Join(parts, ", "), nil
+}
@@ -0,0 +1,77 @@
+package css_test
+
+import (
+	"testing"
+
+	"bennypowers.dev/dtls/internal/tokens"
+	"bennypowers.dev/dtls/lsp/helpers/css"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSerializeCompositeValue(t *testing.T) {
+	tests := []struct {
+		name          string
+		token         *tokens.Token
+		serializer    string
+		expectedValue string
+		expectError   bool
+	}{
+		{
+			name: "typography with optional sub-values missing",
+			token: &tokens.Token{Type: "typography", RawValue: map[string]any{
+				"fontFamily": []any{"Helvetica Neue", "sans-serif"}, "fontSize": map[string]any{"value": 16.0, "unit": "px"},
+			}},
+			serializer:    "{{.fontWeight}} {{.fontSize}} {{.fontFamily}}",
+			expectedValue: `16px "Helvetica Neue", sans-serif`,
+		},
+		{
+			name: "layered shadows",
+			token: &tokens.Token{Type: "shadow", RawValue: []any{
+				map[string]any{"color": "#000", "offsetX": "0px", "offsetY": "1px", "blur": "2px", "inset": true},
+				map[string]any{"color": "#fff", "offsetX": "0px", "offsetY": "2px", "blur": "4px", "inset": false},
+			}},
+			serializer:    "{{.inset}} {{.offsetX}} {{.offsetY}} {{.blur}} {{.color}}",
+			expectedValue: "inset 0px 1px 2px #000, 0px 2px 4px #fff",
+		},
+		{
+			name: "resolved value",
+			token: &tokens.Token{Type: "border", IsResolved: true,
+				RawValue:      map[string]any{"color": "{color.line}", "width": "1px", "style": "solid"},
+				ResolvedValue: map[string]any{"color": "#ccc", "width": "1px", "style": "solid"},
+			},
+			serializer:    "{{.style}} {{.width}} {{.color}}",
+			expectedValue: "solid 1px #ccc",
+		},
+		{
+			name:        "unresolved alias",
+			token:       &tokens.Token{Type: "border", RawValue: map[string]any{"color": "{color.line}"}},
+			serializer:  "{{.color}}",
+			expectError: true,
+		},
+		{
+			name:        "invalid template",
+			token:       &tokens.Token{Type: "border", RawValue: map[string]any{"width": "1px"}},
+			serializer:  "{{.width",
+			expectError: true,
+		},
+		{
+			name:        "non-object value",
+			token:       &tokens.Token{Type: "border", RawValue: "1px solid red"},
+			serializer:  "{{.width}}",
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			value, err := css.SerializeCompositeValue(tt.token, tt.serializer)
+			if tt.expectError {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectedValue, value)
+		})
+	}
+}
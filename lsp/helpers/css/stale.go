@@ -0,0 +1,45 @@
+package css
+
+import (
+	"strings"
+
+	cssparser "bennypowers.dev/dtls/internal/parser/css"
+	"bennypowers.dev/dtls/internal/position"
+	"bennypowers.dev/dtls/internal/tokens"
+)
+
+// RangeText returns the document text covered by a UTF-16 range
+func RangeText(content string, r cssparser.Range) string {
+	lines := strings.Split(content, "\n")
+	if int(r.Start.Line) >= len(lines) || int(r.End.Line) >= len(lines) {
+		return ""
+	}
+
+	startLine := lines[r.Start.Line]
+	start := position.UTF16ToByteOffset(startLine, int(r.Start.Character))
+	if r.Start.Line == r.End.Line {
+		return startLine[start:position.UTF16ToByteOffset(startLine, int(r.End.Character))]
+	}
+
+	parts := []string{startLine[start:]}
+	parts = append(parts, lines[r.Start.Line+1:r.End.Line]...)
+	endLine := lines[r.End.Line]
+	parts = append(parts, endLine[:position.UTF16ToByteOffset(endLine, int(r.End.Character))])
+	return strings.Join(parts, "\n")
+}
+
+// StaleCustomPropertyValue checks a property declared in a generated custom
+// properties file against its token. It returns the token's current CSS value
+// and true when the declared value differs. Tokens loaded from the generated
+// file itself, and tokens that cannot be formatted, are never stale.
+func StaleCustomPropertyValue(token *tokens.Token, generatedPath, declared string) (string, bool) {
+	if token == nil || token.FilePath == generatedPath {
+		return "", false
+	}
+
+	expected, err := FormatTokenValueForCSS(token)
+	if err != nil || IsCSSValueSemanticallyEquivalent(declared, expected) {
+		return "", false
+	}
+	return expected, true
+}
@@ -0,0 +1,111 @@
+package css
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"bennypowers.dev/dtls/internal/collections"
+	"bennypowers.dev/dtls/internal/tokens"
+)
+
+var (
+	// durationProperties are the transition and animation properties which
+	// take <time> values
+	durationProperties = collections.NewSet(
+		"transition", "transition-duration", "transition-delay",
+		"animation", "animation-duration", "animation-delay",
+	)
+
+	// easingProperties are the transition and animation properties which
+	// take <easing-function> values
+	easingProperties = collections.NewSet(
+		"transition", "transition-timing-function",
+		"animation", "animation-timing-function",
+	)
+
+	// easingKeywords are the <easing-function> keywords
+	easingKeywords = collections.NewSet(
+		"linear", "ease", "ease-in", "ease-out", "ease-in-out",
+		"step-start", "step-end",
+	)
+
+	// timePattern matches a CSS <time>, e.g. 200ms or .2s
+	timePattern = regexp.MustCompile(`(?i)^[-+]?(?:\d+\.?\d*|\.\d+)(?:e[-+]?\d+)?(?:ms|s)$`)
+
+	// numberPattern matches a CSS <number>
+	numberPattern = regexp.MustCompile(`(?i)^[-+]?(?:\d+\.?\d*|\.\d+)(?:e[-+]?\d+)?$`)
+)
+
+// TimingValueError returns why a duration or cubicBezier value can't be used
+// in a transition or animation property, e.g. a duration without a time unit
+// or a cubic-bezier() with x coordinates outside 0 to 1. It returns nil for
+// well-formed values, other properties, and other token types.
+//
+// Durations must be times. Cubic beziers must be easing functions, so that
+// keyword fallbacks such as ease-in are well formed.
+func TimingValueError(property, tokenType, value string) error {
+	property = strings.ToLower(property)
+	value = strings.TrimSpace(value)
+	switch strings.ToLower(tokenType) {
+	case "duration":
+		if !durationProperties.Has(property) || timePattern.MatchString(value) {
+			return nil
+		}
+		if numberPattern.MatchString(value) {
+			return fmt.Errorf("%s has no time unit, such as ms or s", value)
+		}
+		return fmt.Errorf("%s is not a time, such as 200ms or 0.2s", value)
+	case "cubicbezier":
+		if !easingProperties.Has(property) {
+			return nil
+		}
+		return easingError(value)
+	}
+	return nil
+}
+
+// TimingTokenValue returns a duration or cubicBezier token's value as CSS,
+// writing a cubic bezier array as a cubic-bezier() function
+func TimingTokenValue(token *tokens.Token) string {
+	if IsArrayValue(token) {
+		if value, err := ArrayValue(token); err == nil {
+			return value
+		}
+	}
+	return token.DisplayValue()
+}
+
+// easingError returns why value is not an <easing-function>, or nil.
+// steps() and linear() functions are not checked.
+func easingError(value string) error {
+	if easingKeywords.Has(strings.ToLower(value)) {
+		return nil
+	}
+	if _, ok := functionArgs(value, "steps"); ok {
+		return nil
+	}
+	if _, ok := functionArgs(value, "linear"); ok {
+		return nil
+	}
+	args, ok := functionArgs(value, "cubic-bezier")
+	if !ok {
+		return fmt.Errorf("%s is not an easing function, such as cubic-bezier(0.4, 0, 0.2, 1)", value)
+	}
+	if len(args) != 4 {
+		return fmt.Errorf("cubic-bezier() takes 4 numbers, not %d", len(args))
+	}
+	for i, arg := range args {
+		if !numberPattern.MatchString(arg) {
+			return fmt.Errorf("cubic-bezier() argument %s is not a number", arg)
+		}
+		// The x coordinates are the first and third arguments
+		if i%2 == 0 {
+			if x, err := strconv.ParseFloat(arg, 64); err != nil || x < 0 || x > 1 {
+				return fmt.Errorf("cubic-bezier() x coordinate %s is not between 0 and 1", arg)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package css_test
+
+import (
+	"testing"
+
+	"bennypowers.dev/dtls/internal/tokens"
+	"bennypowers.dev/dtls/lsp/helpers/css"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTimingValueError(t *testing.T) {
+	tests := []struct {
+		name      string
+		property  string
+		tokenType string
+		value     string
+		expected  string
+	}{
+		{name: "milliseconds", property: "transition-duration", tokenType: "duration", value: "200ms"},
+		{name: "seconds", property: "animation-delay", tokenType: "duration", value: ".2S"},
+		{name: "shorthand", property: "transition", tokenType: "duration", value: "0.2s"},
+		{name: "unitless", property: "transition-duration", tokenType: "duration", value: "200", expected: "200 has no time unit, such as ms or s"},
+		{name: "unitless zero", property: "animation-duration", tokenType: "duration", value: "0", expected: "0 has no time unit, such as ms or s"},
+		{name: "length", property: "transition-delay", tokenType: "duration", value: "200px", expected: "200px is not a time, such as 200ms or 0.2s"},
+		{name: "duration elsewhere", property: "width", tokenType: "duration", value: "200"},
+		{name: "duration in timing function", property: "transition-timing-function", tokenType: "duration", value: "200"},
+
+		{name: "cubic bezier", property: "transition-timing-function", tokenType: "cubicBezier", value: "cubic-bezier(0.4, 0, 0.2, 1)"},
+		{name: "overshooting y", property: "animation", tokenType: "cubicBezier", value: "cubic-bezier(.68, -0.55, .27, 1.55)"},
+		{name: "keyword", property: "transition", tokenType: "cubicBezier", value: "ease-in-out"},
+		{name: "steps", property: "animation-timing-function", tokenType: "cubicBezier", value: "steps(4, end)"},
+		{name: "missing commas", property: "transition-timing-function", tokenType: "cubicBezier", value: "cubic-bezier(0.4 0 0.2 1)", expected: "cubic-bezier() takes 4 numbers, not 1"},
+		{name: "three numbers", property: "transition-timing-function", tokenType: "cubicBezier", value: "cubic-bezier(0.4, 0, 0.2)", expected: "cubic-bezier() takes 4 numbers, not 3"},
+		{name: "x out of range", property: "transition-timing-function", tokenType: "cubicBezier", value: "cubic-bezier(1.2, 0, 0.2, 1)", expected: "cubic-bezier() x coordinate 1.2 is not between 0 and 1"},
+		{name: "not a number", property: "transition-timing-function", tokenType: "cubicBezier", value: "cubic-bezier(a, 0, 0.2, 1)", expected: "cubic-bezier() argument a is not a number"},
+		{name: "bare array", property: "transition-timing-function", tokenType: "cubicBezier", value: "[0.4, 0, 0.2, 1]", expected: "[0.4, 0, 0.2, 1] is not an easing function, such as cubic-bezier(0.4, 0, 0.2, 1)"},
+		{name: "cubic bezier in duration", property: "transition-duration", tokenType: "cubicBezier", value: "0.4"},
+
+		{name: "other type", property: "transition", tokenType: "color", value: "red"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := css.TimingValueError(tt.property, tt.tokenType, tt.value)
+			if tt.expected == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tt.expected)
+			}
+		})
+	}
+}
+
+func TestTimingTokenValue(t *testing.T) {
+	assert.Equal(t, "cubic-bezier(0, 0, 0.58, 1)", css.TimingTokenValue(&tokens.Token{
+		Type:     "cubicBezier",
+		Value:    "[0,0,0.58,1]",
+		RawValue: []any{0.0, 0.0, 0.58, 1.0},
+	}))
+	assert.Equal(t, "200ms", css.TimingTokenValue(&tokens.Token{Type: "duration", Value: "200ms"}))
+}
@@ -0,0 +1,107 @@
+package helpers
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+
+	"bennypowers.dev/dtls/internal/position"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+// PrepareEdits returns a copy of edits sorted in descending document order,
+// after checking that each range is well-formed and no two ranges overlap.
+//
+// The LSP applies all TextEdits of a document against its original content,
+// but lenient clients apply them one at a time; in descending order, each
+// edit leaves the positions of the edits still to come untouched.
+// Insertions at the same position keep their relative order.
+func PrepareEdits(edits []protocol.TextEdit) ([]protocol.TextEdit, error) {
+	type indexed struct {
+		edit  protocol.TextEdit
+		index int
+	}
+	sorted := make([]indexed, len(edits))
+	for i, edit := range edits {
+		if ComparePositions(edit.Range.Start, edit.Range.End) > 0 {
+			return nil, fmt.Errorf("edit %d ends before it starts: %s", i, formatRange(edit.Range))
+		}
+		sorted[i] = indexed{edit, i}
+	}
+
+	slices.SortFunc(sorted, func(a, b indexed) int {
+		if c := ComparePositions(b.edit.Range.Start, a.edit.Range.Start); c != 0 {
+			return c
+		}
+		if c := ComparePositions(b.edit.Range.End, a.edit.Range.End); c != 0 {
+			return c
+		}
+		// Applied last-first, so later insertions go first to end up after earlier ones
+		return cmp.Compare(b.index, a.index)
+	})
+
+	prepared := make([]protocol.TextEdit, len(sorted))
+	for i, s := range sorted {
+		prepared[i] = s.edit
+		if i > 0 && ComparePositions(s.edit.Range.End, prepared[i-1].Range.Start) > 0 {
+			return nil, fmt.Errorf("edits overlap: %s and %s",
+				formatRange(s.edit.Range), formatRange(prepared[i-1].Range))
+		}
+	}
+	return prepared, nil
+}
+
+// CheckEditBounds reports an error if any edit reaches past the end of content
+func CheckEditBounds(content string, edits []protocol.TextEdit) error {
+	lines := strings.Split(content, "\n")
+	inBounds := func(pos protocol.Position) bool {
+		return int(pos.Line) < len(lines) &&
+			pos.Character <= position.StringLengthUTF16Uint32(strings.TrimSuffix(lines[pos.Line], "\r"))
+	}
+	for _, edit := range edits {
+		if !inBounds(edit.Range.Start) || !inBounds(edit.Range.End) {
+			return fmt.Errorf("edit %s is outside the document", formatRange(edit.Range))
+		}
+	}
+	return nil
+}
+
+// ApplyEdits applies edits to content, as a client would.
+// Edits are prepared and bounds-checked first, so a failing set of edits
+// never leaves content partially edited.
+func ApplyEdits(content string, edits []protocol.TextEdit) (string, error) {
+	prepared, err := PrepareEdits(edits)
+	if err != nil {
+		return content, err
+	}
+	if err := CheckEditBounds(content, prepared); err != nil {
+		return content, err
+	}
+
+	lineStarts := []int{0}
+	for i := range len(content) {
+		if content[i] == '\n' {
+			lineStarts = append(lineStarts, i+1)
+		}
+	}
+	offset := func(pos protocol.Position) int {
+		start := lineStarts[pos.Line]
+		end := len(content)
+		if int(pos.Line)+1 < len(lineStarts) {
+			end = lineStarts[pos.Line+1]
+		}
+		return start + position.UTF16ToByteOffset(content[start:end], int(pos.Character))
+	}
+
+	result := content
+	for _, edit := range prepared {
+		start, end := offset(edit.Range.Start), offset(edit.Range.End)
+		result = result[:start] + edit.NewText + result[end:]
+	}
+	return result, nil
+}
+
+func formatRange(r protocol.Range) string {
+	return fmt.Sprintf("%d:%d-%d:%d", r.Start.Line, r.Start.Character, r.End.Line, r.End.Character)
+}
@@ -0,0 +1,205 @@
+package helpers_test
+
+import (
+	"math/rand/v2"
+	"slices"
+	"strings"
+	"testing"
+	"testing/quick"
+	"unicode/utf16"
+
+	"bennypowers.dev/dtls/lsp/helpers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+// editCase is a randomly generated document with non-overlapping edits,
+// and the content expected after applying them
+type editCase struct {
+	content  string
+	edits    []protocol.TextEdit
+	expected string
+}
+
+// docPoint is a position in a generated document, in LSP and byte terms
+type docPoint struct {
+	pos    protocol.Position
+	offset int
+}
+
+var editAlphabet = []rune("ab{}:;- é😀")
+
+// genEditCase generates a document and a shuffled set of non-overlapping edits
+func genEditCase(r *rand.Rand) editCase {
+	lines := make([]string, 1+r.IntN(4))
+	for i := range lines {
+		var b strings.Builder
+		for range r.IntN(12) {
+			b.WriteRune(editAlphabet[r.IntN(len(editAlphabet))])
+		}
+		lines[i] = b.String()
+	}
+	content := strings.Join(lines, "\n")
+
+	// Every rune boundary is a valid edit position
+	var points []docPoint
+	offset := 0
+	for i, line := range lines {
+		var col uint32
+		for _, ch := range line {
+			points = append(points, docPoint{protocol.Position{Line: uint32(i), Character: col}, offset})
+			col += uint32(len(utf16.Encode([]rune{ch})))
+			offset += len(string(ch))
+		}
+		points = append(points, docPoint{protocol.Position{Line: uint32(i), Character: col}, offset})
+		offset++ // newline
+	}
+
+	// Sorted cut points, paired into ascending, non-overlapping ranges.
+	// Insertions sharing a position apply in array order, which shuffling
+	// changes, so at most one insertion is kept per position.
+	cuts := make([]int, 2*r.IntN(5))
+	for i := range cuts {
+		cuts[i] = r.IntN(len(points))
+	}
+	slices.Sort(cuts)
+
+	var edits []protocol.TextEdit
+	var expected strings.Builder
+	last, lastInsert := 0, -1
+	for i := 0; i < len(cuts); i += 2 {
+		if cuts[i] == cuts[i+1] {
+			if cuts[i] == lastInsert {
+				continue
+			}
+			lastInsert = cuts[i]
+		}
+		start, end := points[cuts[i]], points[cuts[i+1]]
+		newText := string(editAlphabet[r.IntN(len(editAlphabet))])
+		if r.IntN(3) == 0 {
+			newText = ""
+		}
+		edits = append(edits, protocol.TextEdit{Range: protocol.Range{Start: start.pos, End: end.pos}, NewText: newText})
+		expected.WriteString(content[last:start.offset])
+		expected.WriteString(newText)
+		last = end.offset
+	}
+	expected.WriteString(content[last:])
+
+	r.Shuffle(len(edits), func(i, j int) { edits[i], edits[j] = edits[j], edits[i] })
+	return editCase{content, edits, expected.String()}
+}
+
+// TestPrepareEdits_Properties checks PrepareEdits and ApplyEdits against
+// randomly generated documents and edits
+func TestPrepareEdits_Properties(t *testing.T) {
+	config := &quick.Config{MaxCount: 500}
+
+	t.Run("applying edits matches applying them to the original content", func(t *testing.T) {
+		property := func(seed uint64) bool {
+			c := genEditCase(rand.New(rand.NewPCG(seed, 0)))
+			result, err := helpers.ApplyEdits(c.content, c.edits)
+			return err == nil && result == c.expected
+		}
+		require.NoError(t, quick.Check(property, config))
+	})
+
+	t.Run("prepared edits are descending and keep every edit", func(t *testing.T) {
+		property := func(seed uint64) bool {
+			c := genEditCase(rand.New(rand.NewPCG(seed, 0)))
+			prepared, err := helpers.PrepareEdits(c.edits)
+			if err != nil || len(prepared) != len(c.edits) {
+				return false
+			}
+			for i := 1; i < len(prepared); i++ {
+				if helpers.ComparePositions(prepared[i].Range.End, prepared[i-1].Range.Start) > 0 {
+					return false
+				}
+			}
+			for _, edit := range c.edits {
+				if !slices.Contains(prepared, edit) {
+					return false
+				}
+			}
+			return true
+		}
+		require.NoError(t, quick.Check(property, config))
+	})
+
+	t.Run("prepared edits can be applied one at a time", func(t *testing.T) {
+		property := func(seed uint64) bool {
+			c := genEditCase(rand.New(rand.NewPCG(seed, 0)))
+			prepared, err := helpers.PrepareEdits(c.edits)
+			if err != nil {
+				return false
+			}
+			content := c.content
+			for _, edit := range prepared {
+				if content, err = helpers.ApplyEdits(content, []protocol.TextEdit{edit}); err != nil {
+					return false
+				}
+			}
+			return content == c.expected
+		}
+		require.NoError(t, quick.Check(property, config))
+	})
+
+	t.Run("edits overlapping another are rejected", func(t *testing.T) {
+		property := func(seed uint64) bool {
+			c := genEditCase(rand.New(rand.NewPCG(seed, 0)))
+			for _, edit := range c.edits {
+				if edit.Range.Start == edit.Range.End {
+					continue
+				}
+				// An edit covering the same range plus one more character
+				wider := edit
+				wider.Range.End.Character++
+				_, err := helpers.PrepareEdits(append(slices.Clone(c.edits), wider))
+				return err != nil
+			}
+			return true
+		}
+		require.NoError(t, quick.Check(property, config))
+	})
+}
+
+func TestPrepareEdits(t *testing.T) {
+	pos := func(line, char uint32) protocol.Position { return protocol.Position{Line: line, Character: char} }
+	edit := func(sl, sc, el, ec uint32, text string) protocol.TextEdit {
+		return protocol.TextEdit{Range: protocol.Range{Start: pos(sl, sc), End: pos(el, ec)}, NewText: text}
+	}
+
+	t.Run("insertions at the same position keep their order", func(t *testing.T) {
+		result, err := helpers.ApplyEdits("ab", []protocol.TextEdit{edit(0, 1, 0, 1, "1"), edit(0, 1, 0, 1, "2")})
+		require.NoError(t, err)
+		assert.Equal(t, "a12b", result)
+	})
+
+	t.Run("inverted range", func(t *testing.T) {
+		_, err := helpers.PrepareEdits([]protocol.TextEdit{edit(0, 5, 0, 2, "x")})
+		assert.ErrorContains(t, err, "ends before it starts")
+	})
+
+	t.Run("nested ranges overlap", func(t *testing.T) {
+		_, err := helpers.PrepareEdits([]protocol.TextEdit{edit(0, 0, 0, 10, "x"), edit(0, 2, 0, 4, "y")})
+		assert.ErrorContains(t, err, "overlap")
+	})
+
+	t.Run("out of bounds", func(t *testing.T) {
+		content := "a😀\nb"
+		assert.NoError(t, helpers.CheckEditBounds(content, []protocol.TextEdit{edit(0, 3, 1, 1, "")}))
+		assert.Error(t, helpers.CheckEditBounds(content, []protocol.TextEdit{edit(0, 4, 0, 4, "")}), "past end of line")
+		assert.Error(t, helpers.CheckEditBounds(content, []protocol.TextEdit{edit(2, 0, 2, 0, "")}), "past last line")
+
+		result, err := helpers.ApplyEdits(content, []protocol.TextEdit{edit(0, 0, 0, 1, "z"), edit(5, 0, 5, 0, "")})
+		assert.Error(t, err)
+		assert.Equal(t, content, result, "content is untouched when any edit is invalid")
+	})
+
+	t.Run("CRLF line endings", func(t *testing.T) {
+		result, err := helpers.ApplyEdits("ab\r\ncd", []protocol.TextEdit{edit(0, 2, 0, 2, "!"), edit(1, 0, 1, 1, "C")})
+		require.NoError(t, err)
+		assert.Equal(t, "ab!\r\nCd", result)
+	})
+}
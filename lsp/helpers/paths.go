@@ -0,0 +1,70 @@
+package helpers
+
+import (
+	"path/filepath"
+	"strings"
+
+	"bennypowers.dev/asimonim/specifier"
+)
+
+// PathWithin reports whether path is dir itself or lies beneath it
+func PathWithin(path, dir string) bool {
+	path = filepath.Clean(path)
+	dir = filepath.Clean(dir)
+	return path == dir || strings.HasPrefix(path, dir+string(filepath.Separator))
+}
+
+// RenamedPath returns where path ends up when oldPath is renamed to newPath.
+// oldPath may be the file itself or one of its ancestor directories.
+// Returns false if path is unaffected by the rename.
+func RenamedPath(path, oldPath, newPath string) (string, bool) {
+	path = filepath.Clean(path)
+	oldPath = filepath.Clean(oldPath)
+	newPath = filepath.Clean(newPath)
+
+	if path == oldPath {
+		return newPath, true
+	}
+	if rest, ok := strings.CutPrefix(path, oldPath+string(filepath.Separator)); ok {
+		return filepath.Join(newPath, rest), true
+	}
+	return "", false
+}
+
+// RenameConfigPath rewrites a configured file path (as written in tokensFiles)
+// to follow a rename from oldPath to newPath.
+// Relative paths resolve against rootPath and stay relative, keeping a leading "./";
+// absolute paths stay absolute. Package specifiers and home-relative paths are
+// never rewritten.
+// Returns false if the configured path is unaffected.
+func RenameConfigPath(configured, rootPath, oldPath, newPath string) (string, bool) {
+	if configured == "" || specifier.IsPackageSpecifier(configured) || strings.HasPrefix(configured, "~") {
+		return "", false
+	}
+
+	resolved := configured
+	if !filepath.IsAbs(resolved) {
+		if rootPath == "" {
+			return "", false
+		}
+		resolved = filepath.Join(rootPath, resolved)
+	}
+
+	target, ok := RenamedPath(resolved, oldPath, newPath)
+	if !ok {
+		return "", false
+	}
+	if filepath.IsAbs(configured) {
+		return target, true
+	}
+
+	rel, err := filepath.Rel(rootPath, target)
+	if err != nil {
+		return "", false
+	}
+	rel = filepath.ToSlash(rel)
+	if strings.HasPrefix(configured, "./") && !strings.HasPrefix(rel, "../") {
+		rel = "./" + rel
+	}
+	return rel, true
+}
@@ -0,0 +1,79 @@
+package helpers_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"bennypowers.dev/dtls/lsp/helpers"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestRenamedPath tests file and directory renames
+func TestRenamedPath(t *testing.T) {
+	root := filepath.FromSlash("/ws")
+	p := func(s string) string { return filepath.Join(root, filepath.FromSlash(s)) }
+
+	tests := []struct {
+		name   string
+		path   string
+		old    string
+		new    string
+		want   string
+		wantOK bool
+	}{
+		{"exact file", p("tokens.json"), p("tokens.json"), p("design.json"), p("design.json"), true},
+		{"parent directory", p("tokens/color.json"), p("tokens"), p("design"), p("design/color.json"), true},
+		{"sibling with shared prefix", p("tokens-extra/color.json"), p("tokens"), p("design"), "", false},
+		{"unrelated file", p("other.json"), p("tokens.json"), p("design.json"), "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := helpers.RenamedPath(tt.path, tt.old, tt.new)
+			assert.Equal(t, tt.wantOK, ok)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+// TestRenameConfigPath tests rewriting configured tokensFiles paths
+func TestRenameConfigPath(t *testing.T) {
+	root := filepath.FromSlash("/ws")
+	p := func(s string) string { return filepath.Join(root, filepath.FromSlash(s)) }
+
+	tests := []struct {
+		name       string
+		configured string
+		old        string
+		new        string
+		want       string
+		wantOK     bool
+	}{
+		{"relative file", "tokens.json", p("tokens.json"), p("design.json"), "design.json", true},
+		{"dot-relative file", "./tokens/color.json", p("tokens"), p("design/tokens"), "./design/tokens/color.json", true},
+		{"absolute file", p("tokens.json"), p("tokens.json"), p("design.json"), p("design.json"), true},
+		{"moved outside root", "./tokens.json", p("tokens.json"), filepath.FromSlash("/elsewhere/tokens.json"), "../elsewhere/tokens.json", true},
+		{"package specifier", "npm:@acme/tokens/tokens.json", p("tokens.json"), p("design.json"), "", false},
+		{"home relative", "~/tokens.json", p("tokens.json"), p("design.json"), "", false},
+		{"unaffected", "other.json", p("tokens.json"), p("design.json"), "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := helpers.RenameConfigPath(tt.configured, root, tt.old, tt.new)
+			assert.Equal(t, tt.wantOK, ok)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+// TestPathWithin tests directory containment on path segment boundaries
+func TestPathWithin(t *testing.T) {
+	root := filepath.FromSlash("/ws")
+	p := func(s string) string { return filepath.Join(root, filepath.FromSlash(s)) }
+
+	assert.True(t, helpers.PathWithin(p("tokens.json"), p("tokens.json")))
+	assert.True(t, helpers.PathWithin(p("tokens/color.json"), p("tokens")))
+	assert.False(t, helpers.PathWithin(p("tokens-extra/color.json"), p("tokens")))
+	assert.False(t, helpers.PathWithin(p("tokens"), p("tokens/color.json")))
+}
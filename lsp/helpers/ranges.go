@@ -1,10 +1,12 @@
 package helpers
 
 import (
+	"cmp"
 	"fmt"
 	"math"
 	"strings"
 
+	"bennypowers.dev/dtls/internal/position"
 	sitter "github.com/tree-sitter/go-tree-sitter"
 	protocol "github.com/tliron/glsp/protocol_3_16"
 )
@@ -38,6 +40,57 @@ func RangesIntersect(a, b protocol.Range) bool {
 	return true
 }
 
+// PositionInRange reports whether pos is "on" r.
+// Ranges are half-open [start, end): the character at Start is on the range,
+// the character at End is just past it. A cursor right after the closing
+// paren of var(--x) is therefore not on the call.
+//
+// Every feature that hit-tests a cursor (hover, definition, references,
+// code actions) uses this so they agree on which token is under it.
+func PositionInRange(pos protocol.Position, r protocol.Range) bool {
+	if pos.Line < r.Start.Line || pos.Line > r.End.Line {
+		return false
+	}
+	if pos.Line == r.Start.Line && pos.Character < r.Start.Character {
+		return false
+	}
+	if pos.Line == r.End.Line && pos.Character >= r.End.Character {
+		return false
+	}
+	return true
+}
+
+// ComparePositions orders two positions, returning -1 if a is before b,
+// 1 if a is after b, and 0 if they are equal.
+func ComparePositions(a, b protocol.Position) int {
+	if a.Line != b.Line {
+		return cmp.Compare(a.Line, b.Line)
+	}
+	return cmp.Compare(a.Character, b.Character)
+}
+
+// RangeSize is a metric for comparing range sizes, used to pick the innermost
+// of several ranges containing a position. Multi-line ranges always outweigh
+// single-line ones.
+func RangeSize(r protocol.Range) int {
+	lineDiff := int(r.End.Line) - int(r.Start.Line)
+	charDiff := int(r.End.Character) - int(r.Start.Character)
+	if lineDiff == 0 {
+		return charDiff
+	}
+	return lineDiff*10000 + charDiff
+}
+
+// RangeHits reports whether a requested range (e.g. a code action request)
+// selects target. An empty request is a bare cursor and hits target under the
+// same rule as PositionInRange; otherwise the ranges must intersect.
+func RangeHits(requested, target protocol.Range) bool {
+	if requested.Start == requested.End {
+		return PositionInRange(requested.Start, target)
+	}
+	return RangesIntersect(requested, target)
+}
+
 // PositionToUTF16 converts a tree-sitter Point (which uses byte offsets for Column)
 // to LSP Position (which uses UTF-16 code units for Character).
 //
@@ -49,12 +102,40 @@ func PositionToUTF16(source string, point sitter.Point) (protocol.Position, erro
 		return protocol.Position{}, fmt.Errorf("position overflow: row=%d, col=%d exceeds uint32 limit", point.Row, point.Column)
 	}
 
-	lines := strings.Split(source, "\n")
-	if point.Row >= uint(len(lines)) {
-		return protocol.Position{Line: uint32(point.Row), Character: uint32(point.Column)}, nil
+	// Find the line by scanning for newlines; splitting the source would
+	// allocate every line for each position converted
+	lineStart := 0
+	for range point.Row {
+		i := strings.IndexByte(source[lineStart:], '\n')
+		if i < 0 {
+			return protocol.Position{Line: uint32(point.Row), Character: uint32(point.Column)}, nil
+		}
+		lineStart += i + 1
+	}
+	return lineToUTF16(source[lineStart:], point), nil
+}
+
+// OffsetToUTF16 is PositionToUTF16 for a point whose byte offset in source is
+// known, as it is for tree-sitter nodes. The offset locates the line without
+// scanning the source, which matters for documents with many positions.
+func OffsetToUTF16(source string, point sitter.Point, offset uint) (protocol.Position, error) {
+	if offset > uint(len(source)) || point.Column > offset {
+		return PositionToUTF16(source, point)
+	}
+	if point.Row > math.MaxUint32 || point.Column > math.MaxUint32 {
+		return protocol.Position{}, fmt.Errorf("position overflow: row=%d, col=%d exceeds uint32 limit", point.Row, point.Column)
+	}
+	return lineToUTF16(source[offset-point.Column:], point), nil
+}
+
+// lineToUTF16 converts point to an LSP Position, given the source from the
+// start of its line. The row must fit in uint32.
+func lineToUTF16(rest string, point sitter.Point) protocol.Position {
+	line := rest
+	if i := strings.IndexByte(rest, '\n'); i >= 0 {
+		line = rest[:i]
 	}
 
-	line := lines[point.Row]
 	// point.Column is a byte offset within the line
 	// Convert it to UTF-16 code units
 	if point.Column > uint(len(line)) {
@@ -71,7 +152,37 @@ func PositionToUTF16(source string, point sitter.Point) (protocol.Position, erro
 	}
 
 	return protocol.Position{
-		Line:      uint32(point.Row),
+		Line:      uint32(point.Row), //nolint:gosec // G115: callers check the row fits
 		Character: utf16Count,
-	}, nil
+	}
+}
+
+// OffsetToPosition converts a byte offset in content to an LSP position
+func OffsetToPosition(content string, offset int) protocol.Position {
+	offset = min(max(offset, 0), len(content))
+	start := strings.LastIndexByte(content[:offset], '\n') + 1
+	return protocol.Position{
+		Line:      uint32(strings.Count(content[:offset], "\n")), //nolint:gosec // G115: line count is bounded by file size
+		Character: position.StringLengthUTF16Uint32(content[start:offset]),
+	}
+}
+
+// PositionToOffset converts an LSP position to a byte offset in content.
+// Positions past the end of their line or of the content are clamped.
+func PositionToOffset(content string, pos protocol.Position) int {
+	start := 0
+	for range pos.Line {
+		next := strings.IndexByte(content[start:], '\n')
+		if next < 0 {
+			return len(content)
+		}
+		start += next + 1
+	}
+	end := strings.IndexByte(content[start:], '\n')
+	if end < 0 {
+		end = len(content)
+	} else {
+		end += start
+	}
+	return start + position.UTF16ToByteOffset(content[start:end], int(pos.Character))
 }
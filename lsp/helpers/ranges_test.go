@@ -318,6 +318,84 @@ func TestRangesIntersect(t *testing.T) {
 	}
 }
 
+// TestPositionInRange tests the half-open [start, end) hit-testing rule at every boundary
+func TestPositionInRange(t *testing.T) {
+	pos := func(line, char uint32) protocol.Position { return protocol.Position{Line: line, Character: char} }
+	singleLine := protocol.Range{Start: pos(0, 5), End: pos(0, 10)}
+	multiLine := protocol.Range{Start: pos(1, 5), End: pos(3, 2)}
+	empty := protocol.Range{Start: pos(0, 5), End: pos(0, 5)}
+
+	tests := []struct {
+		name     string
+		pos      protocol.Position
+		r        protocol.Range
+		expected bool
+	}{
+		{"before start", pos(0, 4), singleLine, false},
+		{"at start - included", pos(0, 5), singleLine, true},
+		{"inside", pos(0, 7), singleLine, true},
+		{"last character - included", pos(0, 9), singleLine, true},
+		{"at end - excluded", pos(0, 10), singleLine, false},
+		{"after end", pos(0, 11), singleLine, false},
+		{"line before", pos(0, 7), multiLine, false},
+		{"start line before start", pos(1, 4), multiLine, false},
+		{"start line at start", pos(1, 5), multiLine, true},
+		{"start line past start", pos(1, 80), multiLine, true},
+		{"middle line column 0", pos(2, 0), multiLine, true},
+		{"end line before end", pos(3, 1), multiLine, true},
+		{"end line at end - excluded", pos(3, 2), multiLine, false},
+		{"line after", pos(4, 0), multiLine, false},
+		{"empty range contains nothing", pos(0, 5), empty, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, helpers.PositionInRange(tt.pos, tt.r))
+		})
+	}
+}
+
+// TestRangeSize tests that inner ranges measure smaller than the ranges enclosing them
+func TestRangeSize(t *testing.T) {
+	pos := func(line, char uint32) protocol.Position { return protocol.Position{Line: line, Character: char} }
+
+	inner := protocol.Range{Start: pos(0, 20), End: pos(0, 40)}
+	outer := protocol.Range{Start: pos(0, 5), End: pos(0, 45)}
+	multiLine := protocol.Range{Start: pos(0, 40), End: pos(1, 0)}
+
+	assert.Equal(t, 20, helpers.RangeSize(inner))
+	assert.Less(t, helpers.RangeSize(inner), helpers.RangeSize(outer))
+	assert.Less(t, helpers.RangeSize(outer), helpers.RangeSize(multiLine), "multi-line ranges outweigh single-line ones")
+}
+
+// TestRangeHits tests that a bare cursor follows PositionInRange while selections intersect
+func TestRangeHits(t *testing.T) {
+	pos := func(line, char uint32) protocol.Position { return protocol.Position{Line: line, Character: char} }
+	cursor := func(line, char uint32) protocol.Range { return protocol.Range{Start: pos(line, char), End: pos(line, char)} }
+	target := protocol.Range{Start: pos(0, 5), End: pos(0, 10)}
+
+	tests := []struct {
+		name      string
+		requested protocol.Range
+		expected  bool
+	}{
+		{"cursor before", cursor(0, 4), false},
+		{"cursor at start", cursor(0, 5), true},
+		{"cursor on last character", cursor(0, 9), true},
+		{"cursor at end", cursor(0, 10), false},
+		{"selection ending at start", protocol.Range{Start: pos(0, 0), End: pos(0, 5)}, false},
+		{"selection overlapping start", protocol.Range{Start: pos(0, 0), End: pos(0, 6)}, true},
+		{"selection starting at end", protocol.Range{Start: pos(0, 10), End: pos(0, 12)}, false},
+		{"selection covering target", protocol.Range{Start: pos(0, 0), End: pos(1, 0)}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, helpers.RangeHits(tt.requested, target))
+		})
+	}
+}
+
 // TestPositionToUTF16 tests the PositionToUTF16 function for correct UTF-16 conversion and overflow handling
 func TestPositionToUTF16(t *testing.T) {
 	t.Run("overflow detection - row exceeds uint32", func(t *testing.T) {
@@ -442,3 +520,61 @@ func TestPositionToUTF16(t *testing.T) {
 		assert.Equal(t, uint32(math.MaxUint32), pos.Line)
 	})
 }
+
+// TestOffsetToUTF16 tests that converting with a known byte offset agrees
+// with converting by row
+func TestOffsetToUTF16(t *testing.T) {
+	source := "a {}\nb { color: 😀 red }\n"
+	tests := []struct {
+		name   string
+		point  sitter.Point
+		offset uint
+	}{
+		{name: "start of source", point: sitter.Point{Row: 0, Column: 0}, offset: 0},
+		{name: "start of line", point: sitter.Point{Row: 1, Column: 0}, offset: 5},
+		{name: "after emoji", point: sitter.Point{Row: 1, Column: 17}, offset: 22},
+		{name: "end of source", point: sitter.Point{Row: 2, Column: 0}, offset: uint(len(source))},
+		{name: "offset beyond source", point: sitter.Point{Row: 1, Column: 4}, offset: 100},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			want, err := helpers.PositionToUTF16(source, tt.point)
+			require.NoError(t, err)
+			got, err := helpers.OffsetToUTF16(source, tt.point, tt.offset)
+			require.NoError(t, err)
+			assert.Equal(t, want, got)
+		})
+	}
+
+	pos, err := helpers.OffsetToUTF16(source, sitter.Point{Row: 1, Column: 17}, 22)
+	require.NoError(t, err)
+	assert.Equal(t, protocol.Position{Line: 1, Character: 15}, pos, "Emoji should count as 2 UTF-16 units")
+}
+
+// TestComparePositions tests ordering of LSP positions
+func TestComparePositions(t *testing.T) {
+	pos := func(line, char uint32) protocol.Position { return protocol.Position{Line: line, Character: char} }
+
+	assert.Equal(t, 0, helpers.ComparePositions(pos(1, 5), pos(1, 5)))
+	assert.Equal(t, -1, helpers.ComparePositions(pos(1, 5), pos(1, 6)))
+	assert.Equal(t, 1, helpers.ComparePositions(pos(2, 0), pos(1, 99)))
+	assert.Equal(t, -1, helpers.ComparePositions(pos(0, 99), pos(1, 0)))
+}
+
+func TestOffsetToPosition(t *testing.T) {
+	content := "ab\n😀c\n"
+	assert.Equal(t, protocol.Position{Line: 0, Character: 0}, helpers.OffsetToPosition(content, 0))
+	assert.Equal(t, protocol.Position{Line: 0, Character: 2}, helpers.OffsetToPosition(content, 2))
+	assert.Equal(t, protocol.Position{Line: 1, Character: 2}, helpers.OffsetToPosition(content, 7), "surrogate pairs count twice")
+	assert.Equal(t, protocol.Position{Line: 2, Character: 0}, helpers.OffsetToPosition(content, 99), "clamped to the end")
+}
+
+func TestPositionToOffset(t *testing.T) {
+	content := "ab\n😀c\n"
+	for _, offset := range []int{0, 1, 2, 3, 7, 8, 9} {
+		assert.Equal(t, offset, helpers.PositionToOffset(content, helpers.OffsetToPosition(content, offset)))
+	}
+	assert.Equal(t, 2, helpers.PositionToOffset(content, protocol.Position{Line: 0, Character: 10}), "clamped to the line")
+	assert.Equal(t, len(content), helpers.PositionToOffset(content, protocol.Position{Line: 5}))
+}
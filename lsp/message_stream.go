@@ -0,0 +1,286 @@
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"bennypowers.dev/dtls/internal/log"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// DefaultMaxMessageSize bounds the content of each message read from the client
+const DefaultMaxMessageSize = 64 << 20
+
+// MaxMessageSizeEnv names the environment variable which overrides
+// DefaultMaxMessageSize, in bytes
+const MaxMessageSizeEnv = "DTLS_MAX_MESSAGE_SIZE"
+
+// contentLengthHeader starts the header which frames each message
+const contentLengthHeader = "Content-Length:"
+
+// messageStream is a jsonrpc2.ObjectStream for the LSP base protocol.
+//
+// Unlike jsonrpc2.VSCodeObjectCodec, which ends the connection on the first
+// malformed message, it answers bad headers, missing or invalid
+// Content-Length, oversized messages and invalid JSON with a JSON-RPC error
+// and reads on. It also accepts bare \n line endings and header names in any
+// case. Only I/O errors end the connection.
+type messageStream struct {
+	conn    io.Closer
+	r       *bufio.Reader
+	w       *bufio.Writer
+	writeMu sync.Mutex
+
+	// maxSize bounds the content length of a message
+	maxSize int64
+
+	// resync is set after a message of unknown length, whose content must be
+	// skipped up to the next Content-Length header
+	resync bool
+}
+
+// newMessageStream creates a messageStream over conn, which drops messages
+// larger than maxSize bytes
+func newMessageStream(conn io.ReadWriteCloser, maxSize int64) *messageStream {
+	return &messageStream{
+		conn:    conn,
+		r:       bufio.NewReader(conn),
+		w:       bufio.NewWriter(conn),
+		maxSize: maxSize,
+	}
+}
+
+// maxMessageSize returns the message size limit from MaxMessageSizeEnv, or
+// DefaultMaxMessageSize when it is unset or invalid
+func maxMessageSize() int64 {
+	value := os.Getenv(MaxMessageSizeEnv)
+	if value == "" {
+		return DefaultMaxMessageSize
+	}
+	size, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || size <= 0 {
+		log.Warn("Ignoring %s=%q: expected a positive number of bytes", MaxMessageSizeEnv, value)
+		return DefaultMaxMessageSize
+	}
+	return size
+}
+
+// messageError is a malformed message, answered with a JSON-RPC error
+// rather than ending the connection
+type messageError struct {
+	code    int64
+	message string
+	// id is the request's ID, when the message is JSON with one
+	id json.RawMessage
+}
+
+func (e *messageError) Error() string {
+	return e.message
+}
+
+// WriteObject implements jsonrpc2.ObjectStream
+func (t *messageStream) WriteObject(obj any) error {
+	t.writeMu.Lock()
+	defer t.writeMu.Unlock()
+	if err := (jsonrpc2.VSCodeObjectCodec{}).WriteObject(t.w, obj); err != nil {
+		return err
+	}
+	return t.w.Flush()
+}
+
+// ReadObject implements jsonrpc2.ObjectStream. Malformed messages are
+// answered and skipped.
+func (t *messageStream) ReadObject(v any) error {
+	for {
+		err := t.readObject(v)
+		var msgErr *messageError
+		if !errors.As(err, &msgErr) {
+			return err
+		}
+		log.Warn("Dropped malformed message: %s", msgErr.message)
+		if err := t.writeError(msgErr); err != nil {
+			return err
+		}
+	}
+}
+
+// readObject reads the next message into v
+func (t *messageStream) readObject(v any) error {
+	length, err := t.readHeaders()
+	if err != nil {
+		return err
+	}
+
+	if length > t.maxSize {
+		if _, err := io.CopyN(io.Discard, t.r, length); err != nil {
+			return err
+		}
+		return &messageError{
+			code:    jsonrpc2.CodeInvalidRequest,
+			message: fmt.Sprintf("message of %d bytes exceeds the limit of %d bytes", length, t.maxSize),
+		}
+	}
+
+	content := make([]byte, length)
+	if _, err := io.ReadFull(t.r, content); err != nil {
+		return err
+	}
+	if !json.Valid(content) {
+		return &messageError{code: jsonrpc2.CodeParseError, message: "message content is not valid JSON"}
+	}
+	if err := json.Unmarshal(content, v); err != nil {
+		var request struct {
+			ID json.RawMessage `json:"id"`
+		}
+		_ = json.Unmarshal(content, &request)
+		return &messageError{code: jsonrpc2.CodeInvalidRequest, message: err.Error(), id: request.ID}
+	}
+	return nil
+}
+
+// readHeaders reads a message's headers, returning its content length. A
+// message with malformed headers is skipped when its length is known, or
+// else the input is skipped up to the next Content-Length header.
+func (t *messageStream) readHeaders() (int64, error) {
+	length := int64(-1)
+	var headerErr string
+	started := false
+	for {
+		line, err := t.readLine()
+		if err != nil {
+			return 0, err
+		}
+		if line == "" {
+			if !started {
+				// Stray line endings between messages
+				continue
+			}
+			break
+		}
+		started = true
+
+		name, value, ok := strings.Cut(line, ":")
+		if !ok {
+			headerErr = fmt.Sprintf("malformed header %q", truncate(line))
+			continue
+		}
+		if strings.EqualFold(strings.TrimSpace(name), "Content-Length") {
+			n, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+			if err != nil || n < 0 {
+				headerErr = fmt.Sprintf("invalid Content-Length %q", truncate(strings.TrimSpace(value)))
+				continue
+			}
+			length = n
+		}
+	}
+
+	if length < 0 {
+		t.resync = true
+		if headerErr == "" {
+			headerErr = "missing Content-Length header"
+		}
+	} else if headerErr != "" {
+		if _, err := io.CopyN(io.Discard, t.r, length); err != nil {
+			return 0, err
+		}
+	}
+	if headerErr != "" {
+		return 0, &messageError{code: jsonrpc2.CodeInvalidRequest, message: headerErr}
+	}
+	return length, nil
+}
+
+// readLine reads a header line without its line ending. Lines longer than
+// the read buffer, which no valid header needs, come back truncated. After a
+// message of unknown length, input is first skipped to the next header.
+func (t *messageStream) readLine() (string, error) {
+	prefix := ""
+	if t.resync {
+		if err := t.skipToHeader(); err != nil {
+			return "", err
+		}
+		t.resync = false
+		prefix = contentLengthHeader
+	}
+
+	line, err := t.r.ReadSlice('\n')
+	text := prefix + string(line)
+	for errors.Is(err, bufio.ErrBufferFull) {
+		_, err = t.r.ReadSlice('\n')
+	}
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(text, "\r\n"), nil
+}
+
+// skipToHeader discards input up to and including the next Content-Length
+// header name, in any case
+func (t *messageStream) skipToHeader() error {
+	matched := 0
+	for matched < len(contentLengthHeader) {
+		b, err := t.r.ReadByte()
+		if err != nil {
+			return err
+		}
+		switch {
+		case equalFoldByte(b, contentLengthHeader[matched]):
+			matched++
+		case equalFoldByte(b, contentLengthHeader[0]):
+			matched = 1
+		default:
+			matched = 0
+		}
+	}
+	return nil
+}
+
+// equalFoldByte reports whether two ASCII bytes are equal, ignoring case
+func equalFoldByte(a, b byte) bool {
+	const caseBit = 'a' - 'A'
+	if 'A' <= a && a <= 'Z' {
+		a += caseBit
+	}
+	if 'A' <= b && b <= 'Z' {
+		b += caseBit
+	}
+	return a == b
+}
+
+// writeError answers a malformed message with a JSON-RPC error
+func (t *messageStream) writeError(msgErr *messageError) error {
+	id := msgErr.id
+	if len(id) == 0 {
+		id = json.RawMessage("null")
+	}
+	return t.WriteObject(struct {
+		JSONRPC string          `json:"jsonrpc"`
+		ID      json.RawMessage `json:"id"`
+		Error   *jsonrpc2.Error `json:"error"`
+	}{
+		JSONRPC: "2.0",
+		ID:      id,
+		Error:   &jsonrpc2.Error{Code: msgErr.code, Message: msgErr.message},
+	})
+}
+
+// Close implements jsonrpc2.ObjectStream
+func (t *messageStream) Close() error {
+	return t.conn.Close()
+}
+
+// truncate shortens malformed input quoted in error messages
+func truncate(s string) string {
+	const limit = 64
+	if len(s) <= limit {
+		return s
+	}
+	return s[:limit] + "..."
+}
@@ -0,0 +1,171 @@
+package lsp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/sourcegraph/jsonrpc2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// pipeConn reads input and collects output
+type pipeConn struct {
+	io.Reader
+	bytes.Buffer
+}
+
+func (c *pipeConn) Write(p []byte) (int, error) { return c.Buffer.Write(p) }
+func (c *pipeConn) Read(p []byte) (int, error)  { return c.Reader.Read(p) }
+func (c *pipeConn) Close() error                { return nil }
+
+// frame wraps content in a Content-Length header
+func frame(content string) string {
+	return fmt.Sprintf("Content-Length: %d\r\n\r\n%s", len(content), content)
+}
+
+// notification returns the content of a notification of method
+func notification(method string) string {
+	return fmt.Sprintf(`{"jsonrpc":"2.0","method":%q}`, method)
+}
+
+// errorResponse is the part of a JSON-RPC error response the tests check
+type errorResponse struct {
+	ID    json.RawMessage `json:"id"`
+	Error jsonrpc2.Error  `json:"error"`
+}
+
+// serve runs a connection over input until it ends, returning the methods it
+// handled and the error responses it wrote
+func serve(t *testing.T, input string, maxSize int64) ([]string, []errorResponse) {
+	t.Helper()
+	conn := &pipeConn{Reader: strings.NewReader(input)}
+
+	var methods []string
+	rpc := jsonrpc2.NewConn(context.Background(), newMessageStream(conn, maxSize),
+		jsonrpc2.HandlerWithError(func(_ context.Context, _ *jsonrpc2.Conn, req *jsonrpc2.Request) (any, error) {
+			methods = append(methods, req.Method)
+			return nil, nil
+		}))
+	<-rpc.DisconnectNotify()
+
+	var responses []errorResponse
+	output := newMessageStream(&pipeConn{Reader: &conn.Buffer}, DefaultMaxMessageSize)
+	for {
+		var response errorResponse
+		err := output.ReadObject(&response)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		require.NoError(t, err)
+		responses = append(responses, response)
+	}
+	return methods, responses
+}
+
+func TestMessageStream(t *testing.T) {
+	ok := frame(notification("ok"))
+
+	tests := []struct {
+		name     string
+		input    string
+		methods  []string
+		wantCode int64
+		wantMsg  string
+		wantID   string
+	}{
+		{
+			name:    "well-formed messages",
+			input:   frame(notification("a")) + frame(notification("b")),
+			methods: []string{"a", "b"},
+		},
+		{
+			name:    "bare line endings, header case and stray lines",
+			input:   fmt.Sprintf("\r\ncontent-length: %d\nContent-Type: application/vscode-jsonrpc\n\n%s", len(notification("a")), notification("a")) + ok,
+			methods: []string{"a", "ok"},
+		},
+		{
+			name:     "missing Content-Length",
+			input:    "Content-Type: application/vscode-jsonrpc\r\n\r\n" + notification("lost") + ok,
+			methods:  []string{"ok"},
+			wantCode: jsonrpc2.CodeInvalidRequest,
+			wantMsg:  "missing Content-Length header",
+		},
+		{
+			name:     "resync to a header in another case",
+			input:    "Content-Type: application/vscode-jsonrpc\r\n\r\n" + notification("lost") + strings.ToLower(frame(notification("a"))) + ok,
+			methods:  []string{"a", "ok"},
+			wantCode: jsonrpc2.CodeInvalidRequest,
+			wantMsg:  "missing Content-Length header",
+		},
+		{
+			name:     "invalid Content-Length",
+			input:    "Content-Length: lots\r\n\r\n" + notification("lost") + ok,
+			methods:  []string{"ok"},
+			wantCode: jsonrpc2.CodeInvalidRequest,
+			wantMsg:  `invalid Content-Length "lots"`,
+		},
+		{
+			name:     "malformed header with a known length",
+			input:    "garbage\r\n" + frame(notification("lost")) + ok,
+			methods:  []string{"ok"},
+			wantCode: jsonrpc2.CodeInvalidRequest,
+			wantMsg:  `malformed header "garbage"`,
+		},
+		{
+			name:     "oversized message",
+			input:    frame(`{"jsonrpc":"2.0","method":"big","params":"`+strings.Repeat("x", 100)+`"}`) + ok,
+			methods:  []string{"ok"},
+			wantCode: jsonrpc2.CodeInvalidRequest,
+			wantMsg:  "exceeds the limit of 80 bytes",
+		},
+		{
+			name:     "invalid JSON",
+			input:    frame(`{"jsonrpc":`) + ok,
+			methods:  []string{"ok"},
+			wantCode: jsonrpc2.CodeParseError,
+			wantMsg:  "not valid JSON",
+		},
+		{
+			name:     "neither request nor response",
+			input:    frame(`{"jsonrpc":"2.0","id":7}`) + ok,
+			methods:  []string{"ok"},
+			wantCode: jsonrpc2.CodeInvalidRequest,
+			wantMsg:  "unable to determine message type",
+			wantID:   "7",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			methods, responses := serve(t, tt.input, 80)
+			assert.Equal(t, tt.methods, methods)
+			if tt.wantCode == 0 {
+				assert.Empty(t, responses)
+				return
+			}
+			require.Len(t, responses, 1)
+			assert.Equal(t, tt.wantCode, responses[0].Error.Code)
+			assert.Contains(t, responses[0].Error.Message, tt.wantMsg)
+			wantID := tt.wantID
+			if wantID == "" {
+				wantID = "null"
+			}
+			assert.JSONEq(t, wantID, string(responses[0].ID))
+		})
+	}
+}
+
+func TestMaxMessageSize(t *testing.T) {
+	t.Setenv(MaxMessageSizeEnv, "1024")
+	assert.Equal(t, int64(1024), maxMessageSize())
+
+	t.Setenv(MaxMessageSizeEnv, "big")
+	assert.Equal(t, int64(DefaultMaxMessageSize), maxMessageSize())
+}
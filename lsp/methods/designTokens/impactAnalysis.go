@@ -0,0 +1,234 @@
+package designtokens
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"bennypowers.dev/dtls/internal/log"
+	"bennypowers.dev/dtls/internal/parser"
+	"bennypowers.dev/dtls/internal/tokens"
+	"bennypowers.dev/dtls/internal/uriutil"
+	"bennypowers.dev/dtls/lsp/helpers/css"
+	"bennypowers.dev/dtls/lsp/types"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+// ImpactAnalysisMethod is the custom request that previews which usages a
+// token change would affect, before a designer edits a core token
+const ImpactAnalysisMethod = "designTokens/impactAnalysis"
+
+// ImpactAnalysisParams are the params of a designTokens/impactAnalysis request
+type ImpactAnalysisParams struct {
+	// TokenName is the token to change, as a CSS variable (--color-primary)
+	// or token name (color-primary)
+	TokenName string `json:"tokenName"`
+
+	// NewValue is the hypothetical new value (optional).
+	// When set, usages whose literal fallback would no longer match are flagged.
+	NewValue string `json:"newValue,omitempty"`
+}
+
+// ImpactAnalysisResult summarizes the usages affected by a token change
+type ImpactAnalysisResult struct {
+	// Token is the CSS variable name of the changed token
+	Token string `json:"token"`
+
+	// CurrentValue is the token's value before the change
+	CurrentValue string `json:"currentValue"`
+
+	// NewValue echoes the hypothetical new value
+	NewValue string `json:"newValue,omitempty"`
+
+	// Dependents are the CSS variable names of tokens that alias the changed
+	// token, directly or transitively, and so change with it
+	Dependents []string `json:"dependents"`
+
+	// Files lists the affected documents in URI order
+	Files []FileImpact `json:"files"`
+
+	// UsageCount is the total number of affected usages
+	UsageCount int `json:"usageCount"`
+
+	// Summary is a human-readable summary, e.g. "42 usages in 13 files"
+	Summary string `json:"summary"`
+}
+
+// FileImpact lists the affected usages in one document
+type FileImpact struct {
+	URI string `json:"uri"`
+
+	// Selectors are the distinct selectors containing affected usages
+	Selectors []string `json:"selectors"`
+
+	Usages []Usage `json:"usages"`
+}
+
+// Usage is one var() call (or SCSS variable use) of the changed token or a dependent
+type Usage struct {
+	Range protocol.Range `json:"range"`
+
+	// Token is the CSS variable name used, which differs from the changed
+	// token when the usage goes through a dependent
+	Token string `json:"token"`
+
+	// Selector is the selector of the containing rule, if any
+	Selector string `json:"selector,omitempty"`
+
+	// StaleFallback reports that the usage's literal fallback would no longer
+	// match the new value
+	StaleFallback bool `json:"staleFallback,omitempty"`
+}
+
+// ImpactAnalysis handles the designTokens/impactAnalysis request.
+// Usages are collected from open documents, as the editor has them, and from
+// the files the workspace index finds referencing an affected token, as they
+// are on disk, like textDocument/references.
+func ImpactAnalysis(req *types.RequestContext, params *ImpactAnalysisParams) (*ImpactAnalysisResult, error) {
+	log.Info("Impact analysis requested for %s", params.TokenName)
+
+	token := req.Server.Token(params.TokenName)
+	if token == nil {
+		return nil, fmt.Errorf("unknown token: %s", params.TokenName)
+	}
+
+	manager := req.Server.TokenManager()
+	affected := map[*tokens.Token]bool{token: true}
+	dependents := findDependents(manager.GetAll(), token, affected)
+
+	result := &ImpactAnalysisResult{
+		Token:        manager.CSSVariableName(token),
+		CurrentValue: token.Value,
+		NewValue:     params.NewValue,
+		Dependents:   make([]string, 0, len(dependents)),
+		Files:        []FileImpact{},
+	}
+	for _, dep := range dependents {
+		result.Dependents = append(result.Dependents, manager.CSSVariableName(dep))
+	}
+
+	// The token as it would be with the new value, which fallbacks are
+	// checked against as diagnostics check them
+	proposed := *token
+	proposed.Value = params.NewValue
+	proposed.RawValue = nil
+	proposed.ResolvedValue = nil
+	proposed.IsResolved = false
+
+	analyze := func(uri, content, languageID string) {
+		if !parser.IsCSSSupportedLanguage(languageID) {
+			return
+		}
+		parsed, err := parser.ParseCSSFromDocument(content, languageID)
+		if err != nil || parsed == nil {
+			return
+		}
+
+		file := FileImpact{URI: uri, Selectors: []string{}}
+		for _, vc := range parsed.VarCalls {
+			used := req.Server.Token(vc.TokenName)
+			if used == nil || !affected[used] {
+				continue
+			}
+
+			usage := Usage{
+				Range: protocol.Range{
+					Start: protocol.Position{Line: vc.Range.Start.Line, Character: vc.Range.Start.Character},
+					End:   protocol.Position{Line: vc.Range.End.Line, Character: vc.Range.End.Character},
+				},
+				Token:    vc.TokenName,
+				Selector: vc.Selector,
+			}
+			if params.NewValue != "" && used == token && vc.Fallback != nil && vc.FallbackVar == nil {
+				usage.StaleFallback = !css.FallbackMatches(*vc.Fallback, &proposed, req.Server.Token)
+			}
+			file.Usages = append(file.Usages, usage)
+
+			if vc.Selector != "" && !slices.Contains(file.Selectors, vc.Selector) {
+				file.Selectors = append(file.Selectors, vc.Selector)
+			}
+		}
+
+		if len(file.Usages) > 0 {
+			result.Files = append(result.Files, file)
+			result.UsageCount += len(file.Usages)
+		}
+	}
+
+	open := map[string]bool{}
+	for _, doc := range req.Server.AllDocuments() {
+		open[doc.URI()] = true
+		analyze(doc.URI(), doc.Content(), doc.LanguageID())
+	}
+	for _, uri := range indexedUsers(req, affected, open) {
+		path := uriutil.URIToPath(uri)
+		content, err := os.ReadFile(path) //nolint:gosec // G304: files under the workspace root
+		if err != nil {
+			log.Warn("Cannot read %s: %v", path, err)
+			continue
+		}
+		analyze(uri, string(content), parser.LanguageIDForPath(path))
+	}
+
+	slices.SortFunc(result.Files, func(a, b FileImpact) int { return strings.Compare(a.URI, b.URI) })
+	result.Summary = summarize(result.UsageCount, len(result.Files))
+	return result, nil
+}
+
+// indexedUsers returns the URIs of the files which aren't open and which the
+// workspace index finds referencing one of the affected tokens
+func indexedUsers(req *types.RequestContext, affected map[*tokens.Token]bool, open map[string]bool) []string {
+	manager := req.Server.TokenManager()
+	seen := map[string]bool{}
+	var uris []string
+	for token := range affected {
+		for _, location := range req.Server.WorkspaceIndex().References(manager.CSSVariableName(token)) {
+			if !open[location.URI] && !seen[location.URI] {
+				seen[location.URI] = true
+				uris = append(uris, location.URI)
+			}
+		}
+	}
+	return uris
+}
+
+// findDependents returns the tokens that alias target, directly or through
+// other aliases, marking each in affected
+func findDependents(all []*tokens.Token, target *tokens.Token, affected map[*tokens.Token]bool) []*tokens.Token {
+	var dependents []*tokens.Token
+	queue := []*tokens.Token{target}
+
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		if current.Reference == "" {
+			continue
+		}
+
+		for _, t := range all {
+			if affected[t] {
+				continue
+			}
+			if raw, ok := t.RawValue.(string); ok && strings.Contains(raw, current.Reference) {
+				affected[t] = true
+				dependents = append(dependents, t)
+				queue = append(queue, t)
+			}
+		}
+	}
+
+	return dependents
+}
+
+// summarize formats a usage summary such as "42 usages in 13 files"
+func summarize(usages, files int) string {
+	return fmt.Sprintf("%d %s in %d %s", usages, plural(usages, "usage"), files, plural(files, "file"))
+}
+
+func plural(n int, word string) string {
+	if n == 1 {
+		return word
+	}
+	return word + "s"
+}
@@ -0,0 +1,151 @@
+package designtokens
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"bennypowers.dev/dtls/internal/tokens"
+	"bennypowers.dev/dtls/internal/uriutil"
+	"bennypowers.dev/dtls/lsp/testutil"
+	"bennypowers.dev/dtls/lsp/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+func TestImpactAnalysis(t *testing.T) {
+	ctx := testutil.NewMockServerContext()
+	req := types.NewRequestContext(ctx, nil)
+
+	require.NoError(t, ctx.TokenManager().Add(&tokens.Token{
+		Name:      "color-blue-500",
+		Value:     "#0000ff",
+		Type:      "color",
+		Reference: "{color.blue.500}",
+	}))
+	require.NoError(t, ctx.TokenManager().Add(&tokens.Token{
+		Name:      "color-primary",
+		Value:     "#0000ff",
+		RawValue:  "{color.blue.500}",
+		Type:      "color",
+		Reference: "{color.primary}",
+	}))
+	require.NoError(t, ctx.TokenManager().Add(&tokens.Token{
+		Name:     "color-link",
+		Value:    "#0000ff",
+		RawValue: "{color.primary}",
+		Type:     "color",
+	}))
+	require.NoError(t, ctx.TokenManager().Add(&tokens.Token{
+		Name:  "space-small",
+		Value: "4px",
+		Type:  "dimension",
+	}))
+
+	require.NoError(t, ctx.DocumentManager().DidOpen("file:///b.css", "css", 1,
+		".card { color: var(--color-blue-500, #0000ff); padding: var(--space-small); }\n.card a { color: var(--color-link); }"))
+	require.NoError(t, ctx.DocumentManager().DidOpen("file:///a.html", "html", 1,
+		`<p style="color: var(--color-primary)">hi</p>`))
+	require.NoError(t, ctx.DocumentManager().DidOpen("file:///tokens.json", "json", 1, `{}`))
+
+	result, err := ImpactAnalysis(req, &ImpactAnalysisParams{TokenName: "--color-blue-500", NewValue: "#0000cc"})
+	require.NoError(t, err)
+
+	assert.Equal(t, "--color-blue-500", result.Token)
+	assert.Equal(t, "#0000ff", result.CurrentValue)
+	assert.Equal(t, []string{"--color-primary", "--color-link"}, result.Dependents, "aliases are followed transitively")
+	assert.Equal(t, 3, result.UsageCount)
+	assert.Equal(t, "3 usages in 2 files", result.Summary)
+
+	require.Len(t, result.Files, 2)
+	assert.Equal(t, "file:///a.html", result.Files[0].URI)
+	assert.Empty(t, result.Files[0].Selectors, "style attributes have no selector")
+
+	b := result.Files[1]
+	assert.Equal(t, "file:///b.css", b.URI)
+	assert.Equal(t, []string{".card", ".card a"}, b.Selectors)
+	require.Len(t, b.Usages, 2)
+	assert.Equal(t, Usage{
+		Range: protocol.Range{
+			Start: protocol.Position{Line: 0, Character: 15},
+			End:   protocol.Position{Line: 0, Character: 45},
+		},
+		Token:         "--color-blue-500",
+		Selector:      ".card",
+		StaleFallback: true,
+	}, b.Usages[0])
+	assert.Equal(t, "--color-link", b.Usages[1].Token)
+	assert.False(t, b.Usages[1].StaleFallback)
+}
+
+func TestImpactAnalysis_WorkspaceFiles(t *testing.T) {
+	root := t.TempDir()
+	for name, content := range map[string]string{
+		"closed.css":  ".closed { color: var(--color-primary); }",
+		"open.css":    ".stale-on-disk { color: var(--color-primary); }",
+		"unused.scss": ".unused { padding: var(--space-small); }",
+	} {
+		require.NoError(t, os.WriteFile(filepath.Join(root, name), []byte(content), 0o600))
+	}
+
+	ctx := testutil.NewMockServerContext()
+	require.NoError(t, ctx.WorkspaceIndex().Scan(root))
+	require.NoError(t, ctx.TokenManager().Add(&tokens.Token{Name: "color-primary", Value: "#0000ff", Type: "color"}))
+	require.NoError(t, ctx.TokenManager().Add(&tokens.Token{Name: "space-small", Value: "4px", Type: "dimension"}))
+
+	// Open documents are analyzed as the editor has them
+	open := uriutil.PathToURI(filepath.Join(root, "open.css"))
+	require.NoError(t, ctx.DocumentManager().DidOpen(open, "css", 1, ".edited { color: var(--color-primary); }"))
+
+	result, err := ImpactAnalysis(types.NewRequestContext(ctx, nil), &ImpactAnalysisParams{TokenName: "--color-primary"})
+	require.NoError(t, err)
+
+	assert.Equal(t, "2 usages in 2 files", result.Summary)
+	require.Len(t, result.Files, 2)
+	assert.Equal(t, uriutil.PathToURI(filepath.Join(root, "closed.css")), result.Files[0].URI)
+	assert.Equal(t, []string{".closed"}, result.Files[0].Selectors, "files which aren't open are read from disk")
+	assert.Equal(t, open, result.Files[1].URI)
+	assert.Equal(t, []string{".edited"}, result.Files[1].Selectors)
+}
+
+func TestImpactAnalysis_StaleFallback(t *testing.T) {
+	tests := []struct {
+		name     string
+		fallback string
+		newValue string
+		stale    bool
+	}{
+		{name: "same value", fallback: "#FFF", newValue: "#fff", stale: false},
+		{name: "other value", fallback: "#fff", newValue: "#eee", stale: true},
+		{name: "light-dark() written otherwise", fallback: "light-dark(#fff, #000)", newValue: "light-dark(#ffffff, rgb(0 0 0))", stale: false},
+		{name: "light-dark() of other colors", fallback: "light-dark(#fff, #111)", newValue: "light-dark(#fff, #000)", stale: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := testutil.NewMockServerContext()
+			require.NoError(t, ctx.TokenManager().Add(&tokens.Token{Name: "color-surface", Value: "#fff", Type: "color"}))
+			require.NoError(t, ctx.DocumentManager().DidOpen("file:///a.css", "css", 1, ".a { color: var(--color-surface, "+tt.fallback+"); }"))
+
+			result, err := ImpactAnalysis(types.NewRequestContext(ctx, nil), &ImpactAnalysisParams{TokenName: "--color-surface", NewValue: tt.newValue})
+			require.NoError(t, err)
+			require.Len(t, result.Files, 1)
+			require.Len(t, result.Files[0].Usages, 1)
+			assert.Equal(t, tt.stale, result.Files[0].Usages[0].StaleFallback)
+		})
+	}
+}
+
+func TestImpactAnalysis_UnknownToken(t *testing.T) {
+	ctx := testutil.NewMockServerContext()
+	req := types.NewRequestContext(ctx, nil)
+
+	_, err := ImpactAnalysis(req, &ImpactAnalysisParams{TokenName: "--nope"})
+	assert.ErrorContains(t, err, "unknown token: --nope")
+}
+
+func TestSummarize(t *testing.T) {
+	assert.Equal(t, "42 usages in 13 files", summarize(42, 13))
+	assert.Equal(t, "1 usage in 1 file", summarize(1, 1))
+	assert.Equal(t, "0 usages in 0 files", summarize(0, 0))
+}
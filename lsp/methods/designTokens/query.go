@@ -0,0 +1,140 @@
+package designtokens
+
+import (
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"bennypowers.dev/dtls/internal/tokens"
+	"bennypowers.dev/dtls/internal/uriutil"
+	"bennypowers.dev/dtls/lsp/types"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+// QueryMethod is the custom request that searches the loaded tokens, so
+// editor extensions can build a token explorer from the server's index
+const QueryMethod = "designTokens/query"
+
+// QueryParams are the params of a designTokens/query request. Every filter
+// is optional, and a token must match all of those set.
+type QueryParams struct {
+	// Type keeps tokens of this $type, e.g. "color"
+	Type string `json:"type,omitempty"`
+
+	// Prefix keeps tokens loaded with this prefix, e.g. "ds"
+	Prefix string `json:"prefix,omitempty"`
+
+	// Deprecated keeps only deprecated tokens when true, or only current
+	// tokens when false
+	Deprecated *bool `json:"deprecated,omitempty"`
+
+	// Text keeps tokens whose name, CSS variable, value or description
+	// contains it, ignoring case
+	Text string `json:"text,omitempty"`
+
+	// File keeps tokens defined in this file, as a path or file URI
+	File string `json:"file,omitempty"`
+}
+
+// QueryResult lists the matching tokens in CSS variable name order
+type QueryResult struct {
+	Tokens []QueryToken `json:"tokens"`
+}
+
+// QueryToken is a token matched by a designTokens/query request
+type QueryToken struct {
+	// Name is the token's dotted name, e.g. "color.primary"
+	Name string `json:"name"`
+
+	// Path is the token's group path, for building a tree
+	Path []string `json:"path"`
+
+	// CSSVariable is the token's CSS variable name, e.g. "--ds-color-primary"
+	CSSVariable string `json:"cssVariable"`
+
+	Type        string `json:"type,omitempty"`
+	Value       string `json:"value"`
+	Description string `json:"description,omitempty"`
+	Prefix      string `json:"prefix,omitempty"`
+
+	Deprecated         bool   `json:"deprecated,omitempty"`
+	DeprecationMessage string `json:"deprecationMessage,omitempty"`
+
+	// URI and Range locate the token's definition, when it has one in a
+	// token file
+	URI   string          `json:"uri,omitempty"`
+	Range *protocol.Range `json:"range,omitempty"`
+}
+
+// Query handles the designTokens/query request
+func Query(req *types.RequestContext, params *QueryParams) (*QueryResult, error) {
+	manager := req.Server.TokenManager()
+
+	file := params.File
+	if strings.HasPrefix(file, "file://") {
+		file = uriutil.URIToPath(file)
+	}
+	text := strings.ToLower(params.Text)
+
+	result := &QueryResult{Tokens: []QueryToken{}}
+	for _, token := range manager.GetAll() {
+		cssVariable := manager.CSSVariableName(token)
+		if !matchesQuery(token, cssVariable, params, file, text) {
+			continue
+		}
+
+		match := QueryToken{
+			Name:               token.Name,
+			Path:               token.Path,
+			CSSVariable:        cssVariable,
+			Type:               token.Type,
+			Value:              token.Value,
+			Description:        token.Description,
+			Prefix:             token.Prefix,
+			Deprecated:         token.Deprecated,
+			DeprecationMessage: token.DeprecationMessage,
+			URI:                token.DefinitionURI,
+		}
+		if match.Path == nil {
+			match.Path = []string{}
+		}
+		// Like textDocument/definition, only tokens with a path have a
+		// position in their file
+		if token.DefinitionURI != "" && len(token.Path) > 0 {
+			position := protocol.Position{Line: token.Line, Character: token.Character}
+			match.Range = &protocol.Range{Start: position, End: position}
+		}
+		result.Tokens = append(result.Tokens, match)
+	}
+
+	slices.SortFunc(result.Tokens, func(a, b QueryToken) int {
+		return strings.Compare(a.CSSVariable, b.CSSVariable)
+	})
+	return result, nil
+}
+
+// matchesQuery reports whether token matches every filter of params. file is
+// the File filter as a path and text the lowercased Text filter.
+func matchesQuery(token *tokens.Token, cssVariable string, params *QueryParams, file, text string) bool {
+	if params.Type != "" && token.Type != params.Type {
+		return false
+	}
+	if params.Prefix != "" && token.Prefix != params.Prefix {
+		return false
+	}
+	if params.Deprecated != nil && token.Deprecated != *params.Deprecated {
+		return false
+	}
+	if file != "" && filepath.Clean(token.FilePath) != filepath.Clean(file) {
+		return false
+	}
+	if text == "" {
+		return true
+	}
+	for _, field := range []string{token.Name, cssVariable, token.Value, token.Description} {
+		if strings.Contains(strings.ToLower(field), text) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,78 @@
+package designtokens
+
+import (
+	"testing"
+
+	"bennypowers.dev/dtls/internal/tokens"
+	"bennypowers.dev/dtls/lsp/testutil"
+	"bennypowers.dev/dtls/lsp/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+func TestQuery(t *testing.T) {
+	ctx := testutil.NewMockServerContext()
+	req := types.NewRequestContext(ctx, nil)
+
+	for _, token := range []*tokens.Token{
+		{
+			Name: "color-primary", Path: []string{"color", "primary"}, Value: "#0000ff", Type: "color", Prefix: "ds",
+			Description: "Brand blue", FilePath: "/ws/color.json", DefinitionURI: "file:///ws/color.json", Line: 3, Character: 4,
+		},
+		{
+			Name: "color-legacy", Path: []string{"color", "legacy"}, Value: "#ff0000", Type: "color", Prefix: "ds",
+			Deprecated: true, DeprecationMessage: "Use color.primary", FilePath: "/ws/color.json", DefinitionURI: "file:///ws/color.json", Line: 7, Character: 4,
+		},
+		{Name: "space-small", Value: "4px", Type: "dimension", FilePath: "/ws/space.json"},
+	} {
+		require.NoError(t, ctx.TokenManager().Add(token))
+	}
+	deprecated, current := true, false
+
+	tests := []struct {
+		name   string
+		params QueryParams
+		want   []string
+	}{
+		{"no filters", QueryParams{}, []string{"--ds-color-legacy", "--ds-color-primary", "--space-small"}},
+		{"type", QueryParams{Type: "color"}, []string{"--ds-color-legacy", "--ds-color-primary"}},
+		{"prefix", QueryParams{Prefix: "ds"}, []string{"--ds-color-legacy", "--ds-color-primary"}},
+		{"deprecated", QueryParams{Deprecated: &deprecated}, []string{"--ds-color-legacy"}},
+		{"not deprecated", QueryParams{Deprecated: &current}, []string{"--ds-color-primary", "--space-small"}},
+		{"text in description", QueryParams{Text: "BRAND"}, []string{"--ds-color-primary"}},
+		{"text in value", QueryParams{Text: "4px"}, []string{"--space-small"}},
+		{"file path", QueryParams{File: "/ws/space.json"}, []string{"--space-small"}},
+		{"file URI", QueryParams{File: "file:///ws/color.json", Text: "legacy"}, []string{"--ds-color-legacy"}},
+		{"no match", QueryParams{Type: "dimension", Prefix: "ds"}, []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := Query(req, &tt.params)
+			require.NoError(t, err)
+			names := []string{}
+			for _, token := range result.Tokens {
+				names = append(names, token.CSSVariable)
+			}
+			assert.Equal(t, tt.want, names)
+		})
+	}
+
+	t.Run("definition range", func(t *testing.T) {
+		result, err := Query(req, &QueryParams{Text: "primary"})
+		require.NoError(t, err)
+		require.Len(t, result.Tokens, 1)
+		token := result.Tokens[0]
+		assert.Equal(t, []string{"color", "primary"}, token.Path)
+		assert.Equal(t, "file:///ws/color.json", token.URI)
+		assert.Equal(t, &protocol.Range{
+			Start: protocol.Position{Line: 3, Character: 4},
+			End:   protocol.Position{Line: 3, Character: 4},
+		}, token.Range)
+
+		result, err = Query(req, &QueryParams{Text: "space"})
+		require.NoError(t, err)
+		require.Len(t, result.Tokens, 1)
+		assert.Nil(t, result.Tokens[0].Range, "tokens without a definition have no range")
+	})
+}
@@ -0,0 +1,204 @@
+package designtokens
+
+import (
+	"cmp"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"bennypowers.dev/dtls/internal/parser"
+	"bennypowers.dev/dtls/internal/tokens"
+	"bennypowers.dev/dtls/internal/uriutil"
+	"bennypowers.dev/dtls/lsp/types"
+)
+
+// StatsURI is the virtual document holding the token statistics dashboard,
+// served through workspace/textDocumentContent
+const StatsURI = "dtls://stats"
+
+// maxUnusedListed caps the unused tokens listed by name on the dashboard
+const maxUnusedListed = 50
+
+// usageStats counts var() usages per token across the workspace
+type usageStats struct {
+	// documents is the number of CSS-supported open documents scanned
+	documents int
+
+	// files is the number of workspace index files scanned which aren't open
+	files int
+
+	// total is the number of var() usages of known tokens
+	total int
+
+	// byToken counts usages of each token
+	byToken map[*tokens.Token]int
+
+	// uris lists the documents using each token
+	uris map[*tokens.Token][]string
+}
+
+// collectUsages counts token usages like textDocument/references: in open
+// documents as the editor has them, and in the other files of the workspace
+// index as they are on disk
+func collectUsages(req *types.RequestContext) usageStats {
+	stats := usageStats{
+		byToken: map[*tokens.Token]int{},
+		uris:    map[*tokens.Token][]string{},
+	}
+	open := map[string]bool{}
+	for _, doc := range req.Server.AllDocuments() {
+		open[doc.URI()] = true
+		if !parser.IsCSSSupportedLanguage(doc.LanguageID()) {
+			continue
+		}
+		parsed, err := parser.ParseCSSFromDocument(doc.Content(), doc.LanguageID())
+		if err != nil || parsed == nil {
+			continue
+		}
+		stats.documents++
+		for _, vc := range parsed.VarCalls {
+			token := req.Server.Token(vc.TokenName)
+			if token == nil {
+				continue
+			}
+			stats.total++
+			stats.byToken[token]++
+			if !slices.Contains(stats.uris[token], doc.URI()) {
+				stats.uris[token] = append(stats.uris[token], doc.URI())
+			}
+		}
+	}
+
+	index := req.Server.WorkspaceIndex()
+	for _, path := range index.Paths() {
+		if !open[uriutil.PathToURI(path)] {
+			stats.files++
+		}
+	}
+	manager := req.Server.TokenManager()
+	for _, token := range manager.GetAll() {
+		for _, location := range index.References(manager.CSSVariableName(token)) {
+			if open[location.URI] {
+				continue
+			}
+			stats.total++
+			stats.byToken[token]++
+			if !slices.Contains(stats.uris[token], location.URI) {
+				stats.uris[token] = append(stats.uris[token], location.URI)
+			}
+		}
+	}
+	return stats
+}
+
+// StatsMarkdown renders the token statistics dashboard: token counts by type
+// and source file, tokens not used anywhere in the workspace, and the
+// deprecated tokens used most often
+func StatsMarkdown(req *types.RequestContext) string {
+	manager := req.Server.TokenManager()
+	all := manager.GetAll()
+	usages := collectUsages(req)
+
+	var b strings.Builder
+	b.WriteString("# Design Token Statistics\n\n")
+	files := len(manager.GetSourceFiles())
+	fmt.Fprintf(&b, "**Tokens**: %d from %d %s\n\n", len(all), files, plural(files, "file"))
+	fmt.Fprintf(&b, "**Usages**: %d %s in %d open %s and %d other workspace %s\n",
+		usages.total, plural(usages.total, "usage"), usages.documents, plural(usages.documents, "document"),
+		usages.files, plural(usages.files, "file"))
+
+	writeCountTable(&b, "Tokens by type", "Type", countBy(all, func(t *tokens.Token) string {
+		return cmp.Or(t.Type, "(untyped)")
+	}))
+	writeCountTable(&b, "Tokens by file", "File", countBy(all, func(t *tokens.Token) string {
+		return cmp.Or(relativePath(req.Server.RootPath(), t.FilePath), "(inline)")
+	}))
+
+	var unused []string
+	for _, t := range all {
+		if usages.byToken[t] == 0 {
+			unused = append(unused, manager.CSSVariableName(t))
+		}
+	}
+	slices.Sort(unused)
+	b.WriteString("\n## Unused tokens\n\n")
+	if len(unused) == 0 {
+		b.WriteString("Every token is used in the workspace.\n")
+	} else {
+		fmt.Fprintf(&b, "%d %s not used in the workspace:\n\n", len(unused), plural(len(unused), "token"))
+		for _, name := range unused[:min(len(unused), maxUnusedListed)] {
+			fmt.Fprintf(&b, "- `%s`\n", name)
+		}
+		if len(unused) > maxUnusedListed {
+			fmt.Fprintf(&b, "- … and %d more\n", len(unused)-maxUnusedListed)
+		}
+	}
+
+	var deprecated []*tokens.Token
+	for t := range usages.byToken {
+		if t.Deprecated {
+			deprecated = append(deprecated, t)
+		}
+	}
+	slices.SortFunc(deprecated, func(a, b *tokens.Token) int {
+		return cmp.Or(cmp.Compare(usages.byToken[b], usages.byToken[a]),
+			strings.Compare(manager.CSSVariableName(a), manager.CSSVariableName(b)))
+	})
+	b.WriteString("\n## Deprecated usage hotspots\n\n")
+	if len(deprecated) == 0 {
+		b.WriteString("No deprecated tokens are used in the workspace.\n")
+	} else {
+		b.WriteString("| Token | Usages | Documents |\n| --- | ---: | ---: |\n")
+		for _, t := range deprecated {
+			fmt.Fprintf(&b, "| `%s` | %d | %d |\n", manager.CSSVariableName(t), usages.byToken[t], len(usages.uris[t]))
+		}
+	}
+
+	return b.String()
+}
+
+// label pairs a table key with its count
+type label struct {
+	name  string
+	count int
+}
+
+// countBy groups tokens by key, largest group first
+func countBy(all []*tokens.Token, key func(*tokens.Token) string) []label {
+	counts := map[string]int{}
+	for _, t := range all {
+		counts[key(t)]++
+	}
+	labels := make([]label, 0, len(counts))
+	for name, count := range counts {
+		labels = append(labels, label{name, count})
+	}
+	slices.SortFunc(labels, func(a, b label) int {
+		return cmp.Or(cmp.Compare(b.count, a.count), strings.Compare(a.name, b.name))
+	})
+	return labels
+}
+
+func writeCountTable(b *strings.Builder, heading, column string, labels []label) {
+	fmt.Fprintf(b, "\n## %s\n\n", heading)
+	if len(labels) == 0 {
+		b.WriteString("No tokens loaded.\n")
+		return
+	}
+	fmt.Fprintf(b, "| %s | Tokens |\n| --- | ---: |\n", column)
+	for _, l := range labels {
+		fmt.Fprintf(b, "| %s | %d |\n", l.name, l.count)
+	}
+}
+
+// relativePath shortens a token file path to be relative to the workspace root
+func relativePath(root, path string) string {
+	if root == "" || path == "" {
+		return path
+	}
+	if rel, err := filepath.Rel(root, path); err == nil && !strings.HasPrefix(rel, "..") {
+		return filepath.ToSlash(rel)
+	}
+	return path
+}
@@ -0,0 +1,66 @@
+package designtokens
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"bennypowers.dev/dtls/internal/tokens"
+	"bennypowers.dev/dtls/lsp/testutil"
+	"bennypowers.dev/dtls/lsp/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var update = flag.Bool("update", false, "update golden files")
+
+func TestStatsMarkdown(t *testing.T) {
+	ctx := testutil.NewMockServerContext()
+	ctx.SetRootPath("/project")
+	req := types.NewRequestContext(ctx, nil)
+
+	for _, token := range []*tokens.Token{
+		{Name: "color-primary", Value: "#0000ff", Type: "color", FilePath: "/project/tokens/color.json"},
+		{Name: "color-old", Value: "#ff0000", Type: "color", FilePath: "/project/tokens/color.json", Deprecated: true},
+		{Name: "color-legacy", Value: "#00ff00", Type: "color", FilePath: "/project/tokens/color.json", Deprecated: true},
+		{Name: "space-small", Value: "4px", Type: "dimension", FilePath: "/project/tokens/space.json"},
+		{Name: "space-large", Value: "16px", Type: "dimension", FilePath: "/project/tokens/space.json"},
+		{Name: "font-body", Value: "Inter", FilePath: "/elsewhere/fonts.json"},
+	} {
+		require.NoError(t, ctx.TokenManager().Add(token))
+	}
+
+	require.NoError(t, ctx.DocumentManager().DidOpen("file:///project/a.css", "css", 1,
+		".a { color: var(--color-old); background: var(--color-primary); padding: var(--space-small); }\n"+
+			".b { color: var(--color-old); border-color: var(--color-legacy); }"))
+	require.NoError(t, ctx.DocumentManager().DidOpen("file:///project/b.html", "html", 1,
+		`<p style="color: var(--color-old); margin: var(--unknown)">hi</p>`))
+	require.NoError(t, ctx.DocumentManager().DidOpen("file:///project/tokens/color.json", "json", 1, `{}`))
+
+	// Files which aren't open count as they are on disk
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "c.css"), []byte(".c { gap: var(--space-large); color: var(--color-old); }\n"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "d.css"), []byte(".d { color: red; }\n"), 0o644))
+	require.NoError(t, ctx.WorkspaceIndex().Scan(dir))
+
+	got := StatsMarkdown(req)
+
+	golden := "testdata/stats.md"
+	if *update {
+		require.NoError(t, os.WriteFile(golden, []byte(got), 0o644))
+		return
+	}
+	expected, err := os.ReadFile(golden)
+	require.NoError(t, err, "golden file %s not found; run with -update to create", golden)
+	assert.Equal(t, string(expected), got)
+}
+
+func TestStatsMarkdown_NoTokens(t *testing.T) {
+	req := types.NewRequestContext(testutil.NewMockServerContext(), nil)
+
+	got := StatsMarkdown(req)
+	assert.Contains(t, got, "**Tokens**: 0 from 0 files")
+	assert.Contains(t, got, "Every token is used in the workspace.")
+	assert.Contains(t, got, "No deprecated tokens are used in the workspace.")
+}
@@ -5,7 +5,10 @@ import (
 
 	"bennypowers.dev/dtls/internal/uriutil"
 	"bennypowers.dev/dtls/internal/version"
+	codeaction "bennypowers.dev/dtls/lsp/methods/textDocument/codeAction"
 	"bennypowers.dev/dtls/lsp/methods/textDocument/diagnostic"
+	semantictokens "bennypowers.dev/dtls/lsp/methods/textDocument/semanticTokens"
+	"bennypowers.dev/dtls/lsp/methods/workspace"
 	"bennypowers.dev/dtls/lsp/types"
 	protocol "github.com/tliron/glsp/protocol_3_16"
 )
@@ -19,6 +22,10 @@ func Initialize(req *types.RequestContext, params *protocol.InitializeParams) (a
 
 	log.Info("Initializing for client: %s", clientName)
 
+	if params.Trace != nil {
+		setTraceValue(*params.Trace)
+	}
+
 	// Store client capabilities for later use by handlers
 	req.Server.SetClientCapabilities(params.Capabilities)
 
@@ -56,6 +63,32 @@ func Initialize(req *types.RequestContext, params *protocol.InitializeParams) (a
 		log.Info("Workspace root (from rootPath): %s", req.Server.RootPath())
 	}
 
+	// Localize user-facing strings for the client's UI language
+	if params.Locale != nil {
+		req.Server.SetLocale(*params.Locale)
+		log.Info("Client locale: %s", *params.Locale)
+	}
+
+	// Apply configuration sent with initialize and from package.json now,
+	// so that feature toggles are known before capabilities are advertised
+	if !workspace.WorkspaceTrusted(params.InitializationOptions) {
+		req.Server.SetWorkspaceTrusted(false)
+		log.Info("Workspace is untrusted: running in read-only mode")
+	}
+	if params.InitializationOptions != nil {
+		config, err := workspace.ParseInitializationOptions(params.InitializationOptions)
+		if err != nil {
+			log.Warn("Failed to parse initializationOptions: %v", err)
+		} else {
+			req.Server.SetConfig(config)
+		}
+	}
+	if err := req.Server.LoadPackageJsonConfig(); err != nil {
+		log.Warn("Failed to load package.json config: %v", err)
+	}
+	config := req.Server.GetConfig()
+	features := config.Features
+
 	// Build server capabilities
 	//
 	// WORKAROUND: We use map[string]any instead of protocol.ServerCapabilities to include
@@ -67,33 +100,78 @@ func Initialize(req *types.RequestContext, params *protocol.InitializeParams) (a
 			OpenClose: boolPtr(true),
 			Change:    &syncKind,
 		},
-		"hoverProvider": true,
-		"completionProvider": protocol.CompletionOptions{
-			ResolveProvider: boolPtr(true),
-		},
 		"definitionProvider": true,
 		"referencesProvider": true,
-		"codeActionProvider": protocol.CodeActionOptions{
-			ResolveProvider: boolPtr(true),
+		// Lists the loaded tokens in editors' symbol search
+		"workspaceSymbolProvider": true,
+		// Links alias references in token files to the tokens they reference
+		"documentLinkProvider": protocol.DocumentLinkOptions{},
+		// Renames token file keys, with every token and reference below them
+		"renameProvider": protocol.RenameOptions{
+			PrepareProvider: boolPtr(true),
 		},
-		"colorProvider": true,
-		"semanticTokensProvider": map[string]any{
-			"legend": map[string]any{
-				"tokenTypes":     []string{"class", "property"}, // Match TypeScript: class for first part, property for rest
-				"tokenModifiers": []string{},
+		"executeCommandProvider": protocol.ExecuteCommandOptions{
+			Commands: workspace.AvailableCommands(config),
+		},
+		// LSP 3.18: virtual documents such as dtls://stats
+		"workspace": map[string]any{
+			"textDocumentContent": map[string]any{
+				"schemes": []string{workspace.VirtualDocumentScheme},
+			},
+			"fileOperations": protocol.ServerCapabilitiesWorkspaceFileOperations{
+				WillRename: &protocol.FileOperationRegistrationOptions{
+					Filters: workspace.TokenFileOperationFilters,
+				},
+				DidDelete: &protocol.FileOperationRegistrationOptions{
+					Filters: workspace.TokenFileOperationFilters,
+				},
 			},
+		},
+	}
+
+	// Features disabled in configuration are not advertised, so clients
+	// fall back to other extensions providing the same feature
+	if features.HoverEnabled() {
+		capabilities["hoverProvider"] = true
+	}
+	if features.CompletionEnabled() {
+		capabilities["completionProvider"] = protocol.CompletionOptions{
+			ResolveProvider: boolPtr(true),
+			// Alias references in token files, e.g. {color.brand.primary}
+			TriggerCharacters: []string{"{", "."},
+		}
+	}
+	if features.CodeActionsEnabled() {
+		capabilities["codeActionProvider"] = protocol.CodeActionOptions{
+			CodeActionKinds: codeaction.AdvertisedKinds(&params.Capabilities),
+			ResolveProvider: boolPtr(true),
+		}
+	}
+	if features.DocumentColorEnabled() {
+		capabilities["colorProvider"] = true
+	}
+	if features.SemanticTokensEnabled() {
+		var semanticTokensCaps *protocol.SemanticTokensClientCapabilities
+		if params.Capabilities.TextDocument != nil {
+			semanticTokensCaps = params.Capabilities.TextDocument.SemanticTokens
+		}
+		// Like TypeScript: class for the first part of a reference, property for the rest
+		legend := semantictokens.NegotiateLegend(req.Server.TokenManager(), semanticTokensCaps)
+		req.Server.SetSemanticTokensLegend(legend)
+		capabilities["semanticTokensProvider"] = map[string]any{
+			"legend": legend,
 			"full": map[string]any{
 				"delta": true,
 			},
-		},
+		}
 	}
 
 	// LSP 3.17: Only advertise pull diagnostics if client supports it
 	// For older clients, we'll use push diagnostics (textDocument/publishDiagnostics)
-	if supportsPullDiagnostics {
+	if supportsPullDiagnostics && features.DiagnosticsEnabled() {
 		capabilities["diagnosticProvider"] = diagnostic.DiagnosticOptions{
 			InterFileDependencies: false,
-			WorkspaceDiagnostics:  false,
+			WorkspaceDiagnostics:  true,
 		}
 	}
 
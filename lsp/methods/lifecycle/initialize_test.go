@@ -5,6 +5,9 @@ import (
 
 	"bennypowers.dev/dtls/internal/uriutil"
 	"bennypowers.dev/dtls/internal/version"
+	codeaction "bennypowers.dev/dtls/lsp/methods/textDocument/codeAction"
+	"bennypowers.dev/dtls/lsp/methods/textDocument/diagnostic"
+	"bennypowers.dev/dtls/lsp/methods/workspace"
 	"bennypowers.dev/dtls/lsp/testutil"
 	"bennypowers.dev/dtls/lsp/types"
 	"github.com/stretchr/testify/assert"
@@ -114,10 +117,29 @@ func TestInitialize(t *testing.T) {
 		assert.Contains(t, caps, "completionProvider")
 		assert.Contains(t, caps, "definitionProvider")
 		assert.Contains(t, caps, "referencesProvider")
+		assert.Contains(t, caps, "workspaceSymbolProvider")
+		assert.Contains(t, caps, "documentLinkProvider")
+		assert.Contains(t, caps, "renameProvider")
 		assert.Contains(t, caps, "codeActionProvider")
 		assert.Contains(t, caps, "colorProvider")
 		assert.Contains(t, caps, "semanticTokensProvider")
 		assert.Contains(t, caps, "diagnosticProvider")
+		assert.Equal(t, diagnostic.DiagnosticOptions{WorkspaceDiagnostics: true}, caps["diagnosticProvider"])
+
+		executeCommandProvider, ok := caps["executeCommandProvider"].(protocol.ExecuteCommandOptions)
+		require.True(t, ok)
+		assert.Contains(t, executeCommandProvider.Commands, workspace.TogglePrefixDisplayCommand)
+		assert.Equal(t, map[string]any{
+			"textDocumentContent": map[string]any{"schemes": []string{"dtls"}},
+			"fileOperations": protocol.ServerCapabilitiesWorkspaceFileOperations{
+				WillRename: &protocol.FileOperationRegistrationOptions{
+					Filters: workspace.TokenFileOperationFilters,
+				},
+				DidDelete: &protocol.FileOperationRegistrationOptions{
+					Filters: workspace.TokenFileOperationFilters,
+				},
+			},
+		}, caps["workspace"])
 
 		// Verify resolve providers are enabled
 		completionProvider, ok := caps["completionProvider"].(protocol.CompletionOptions)
@@ -129,6 +151,130 @@ func TestInitialize(t *testing.T) {
 		assert.True(t, ok)
 		assert.NotNil(t, codeActionProvider.ResolveProvider)
 		assert.True(t, *codeActionProvider.ResolveProvider)
+		assert.Equal(t, codeaction.Kinds, codeActionProvider.CodeActionKinds, "Should advertise the kinds of the actions it returns")
+	})
+
+	t.Run("negotiates the semantic tokens legend", func(t *testing.T) {
+		ctx := testutil.NewMockServerContext()
+		req := types.NewRequestContext(ctx, &glsp.Context{})
+
+		params := &protocol.InitializeParams{
+			Capabilities: protocol.ClientCapabilities{
+				TextDocument: &protocol.TextDocumentClientCapabilities{
+					SemanticTokens: &protocol.SemanticTokensClientCapabilities{
+						TokenModifiers: []string{"deprecated"},
+					},
+				},
+			},
+		}
+
+		result, err := Initialize(req, params)
+		require.NoError(t, err)
+
+		initResult := result.(struct {
+			Capabilities any                                  `json:"capabilities"`
+			ServerInfo   *protocol.InitializeResultServerInfo `json:"serverInfo,omitempty"`
+		})
+		caps, ok := initResult.Capabilities.(map[string]any)
+		require.True(t, ok, "Capabilities should be a map")
+		provider, ok := caps["semanticTokensProvider"].(map[string]any)
+		require.True(t, ok)
+
+		legend, ok := provider["legend"].(types.SemanticTokensLegend)
+		require.True(t, ok)
+		assert.Contains(t, legend.TokenTypes, "colorToken")
+		assert.Equal(t, []string{"deprecated", "alias", "unknown"}, legend.TokenModifiers)
+		assert.Equal(t, legend, ctx.SemanticTokensLegend(), "tokens are encoded with the declared legend")
+	})
+
+	t.Run("omits capabilities for features disabled in initializationOptions", func(t *testing.T) {
+		ctx := testutil.NewMockServerContext()
+		req := types.NewRequestContext(ctx, &glsp.Context{})
+		ctx.SetClientDiagnosticCapability(true)
+
+		params := &protocol.InitializeParams{
+			InitializationOptions: map[string]any{
+				"designTokensLanguageServer": map[string]any{
+					"features": map[string]any{
+						"hover":       false,
+						"diagnostics": false,
+					},
+				},
+			},
+		}
+
+		result, err := Initialize(req, params)
+		require.NoError(t, err)
+
+		initResult := result.(struct {
+			Capabilities any                                  `json:"capabilities"`
+			ServerInfo   *protocol.InitializeResultServerInfo `json:"serverInfo,omitempty"`
+		})
+		caps, ok := initResult.Capabilities.(map[string]any)
+		require.True(t, ok, "Capabilities should be a map")
+
+		assert.NotContains(t, caps, "hoverProvider")
+		assert.NotContains(t, caps, "diagnosticProvider")
+		assert.Contains(t, caps, "completionProvider")
+		assert.Contains(t, caps, "colorProvider")
+		assert.Contains(t, caps, "semanticTokensProvider")
+
+		// Configuration is stored so handlers honor the toggles too
+		assert.False(t, ctx.GetConfig().Features.HoverEnabled())
+	})
+
+	t.Run("accepts the bare settings block as initializationOptions", func(t *testing.T) {
+		ctx := testutil.NewMockServerContext()
+		req := types.NewRequestContext(ctx, &glsp.Context{})
+
+		params := &protocol.InitializeParams{
+			InitializationOptions: map[string]any{
+				"tokensFiles": []any{"tokens.json"},
+				"prefix":      "ds",
+				"features":    map[string]any{"diagnostics": false},
+			},
+		}
+
+		_, err := Initialize(req, params)
+		require.NoError(t, err)
+
+		cfg := ctx.GetConfig()
+		assert.Equal(t, []any{"tokens.json"}, cfg.TokensFiles)
+		assert.Equal(t, "ds", cfg.Prefix)
+		assert.False(t, cfg.Features.DiagnosticsEnabled())
+	})
+
+	t.Run("untrusted workspace runs in read-only mode", func(t *testing.T) {
+		ctx := testutil.NewMockServerContext()
+		req := types.NewRequestContext(ctx, &glsp.Context{})
+
+		params := &protocol.InitializeParams{
+			InitializationOptions: map[string]any{
+				"designTokensLanguageServer": map[string]any{
+					"networkFallback": true,
+					"valueHistory":    true,
+				},
+				"workspaceTrusted": false,
+			},
+		}
+
+		result, err := Initialize(req, params)
+		require.NoError(t, err)
+
+		assert.False(t, ctx.WorkspaceTrusted())
+		cfg := ctx.GetConfig()
+		assert.True(t, cfg.ReadOnly)
+		assert.False(t, cfg.NetworkFallback)
+		assert.False(t, cfg.ValueHistory)
+
+		initResult := result.(struct {
+			Capabilities any                                  `json:"capabilities"`
+			ServerInfo   *protocol.InitializeResultServerInfo `json:"serverInfo,omitempty"`
+		})
+		caps := initResult.Capabilities.(map[string]any)
+		commands := caps["executeCommandProvider"].(protocol.ExecuteCommandOptions).Commands
+		assert.Contains(t, commands, workspace.SnapshotTokensCommand)
+		assert.NotContains(t, commands, workspace.SyncThemeCommand)
 	})
 
 	t.Run("handles client info", func(t *testing.T) {
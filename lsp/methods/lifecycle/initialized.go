@@ -1,8 +1,11 @@
 package lifecycle
 
 import (
+	"time"
+
 	"bennypowers.dev/dtls/internal/log"
 
+	"bennypowers.dev/dtls/lsp/methods/workspace"
 	"bennypowers.dev/dtls/lsp/types"
 	protocol "github.com/tliron/glsp/protocol_3_16"
 )
@@ -27,11 +30,29 @@ func Initialized(req *types.RequestContext, params *protocol.InitializedParams)
 		// Don't fail initialization, just log the error
 	}
 
+	// Rather than leave newcomers wondering why nothing happens, say when
+	// there are no tokens to work with
+	workspace.SuggestTokenFiles(req)
+
 	// Register file watchers for token files
 	if err := req.Server.RegisterFileWatchers(req.GLSP); err != nil {
 		log.Info("Warning: failed to register file watchers: %v", err)
 		// Don't fail initialization, just log the error
 	}
 
+	// Index the workspace's CSS variable references in the background, so
+	// Find References covers files which aren't open
+	if root := req.Server.RootPath(); root != "" {
+		index := req.Server.WorkspaceIndex()
+		go func() {
+			start := time.Now()
+			if err := index.Scan(root); err != nil {
+				log.Warn("Failed to index workspace %s: %v", root, err)
+				return
+			}
+			log.Info("Indexed %d workspace files in %s", index.Len(), time.Since(start).Round(time.Millisecond))
+		}()
+	}
+
 	return nil
 }
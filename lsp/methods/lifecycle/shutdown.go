@@ -3,7 +3,6 @@ package lifecycle
 import (
 	"bennypowers.dev/dtls/internal/log"
 
-	"bennypowers.dev/dtls/internal/parser/css"
 	"bennypowers.dev/dtls/lsp/types"
 )
 
@@ -11,10 +10,7 @@ import (
 func Shutdown(req *types.RequestContext) error {
 	log.Info("Server shutting down")
 
-	// Clean up the CSS parser pool
-	// Note: This is currently handled by server.Close() but we put it here
-	// for completeness in case we need other cleanup logic
-	css.ClosePool()
-
+	// Parser pools are cleaned up by server.Close(), not here: in daemon
+	// mode, other clients' servers are still parsing with them
 	return nil
 }
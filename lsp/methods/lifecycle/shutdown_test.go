@@ -19,7 +19,7 @@ func TestShutdown(t *testing.T) {
 		assert.NoError(t, err)
 	})
 
-	t.Run("cleans up CSS parser pool", func(t *testing.T) {
+	t.Run("leaves the CSS parser pool to server.Close", func(t *testing.T) {
 		ctx := testutil.NewMockServerContext()
 		glspCtx := &glsp.Context{}
 		req := types.NewRequestContext(ctx, glspCtx)
@@ -28,7 +28,7 @@ func TestShutdown(t *testing.T) {
 		err := Shutdown(req)
 		assert.NoError(t, err)
 
-		// CSS parser pool should be closed (tested in main server tests)
+		// Other clients of a daemon share the pool (tested in main server tests)
 	})
 
 	t.Run("can be called multiple times safely", func(t *testing.T) {
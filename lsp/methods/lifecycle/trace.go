@@ -9,6 +9,19 @@ import (
 
 // SetTrace handles the $/setTrace notification
 func SetTrace(req *types.RequestContext, params *protocol.SetTraceParams) error {
-	log.Info("Trace level set to: %s", params.Value)
+	setTraceValue(params.Value)
 	return nil
 }
+
+// setTraceValue applies a trace level sent by the client. At "verbose",
+// each request is reported in a $/logTrace notification with its timing.
+// Unknown levels are logged and ignored.
+func setTraceValue(value protocol.TraceValue) {
+	switch value {
+	case protocol.TraceValueOff, protocol.TraceValueMessage, "messages", protocol.TraceValueVerbose:
+		protocol.SetTraceValue(value)
+		log.Info("Trace level set to: %s", value)
+	default:
+		log.Warn("Ignoring unknown trace level: %s", value)
+	}
+}
@@ -11,6 +11,8 @@ import (
 )
 
 func TestSetTrace(t *testing.T) {
+	t.Cleanup(func() { protocol.SetTraceValue(protocol.TraceValueOff) })
+
 	t.Run("handles off trace level", func(t *testing.T) {
 		ctx := testutil.NewMockServerContext()
 		glspCtx := &glsp.Context{}
@@ -22,6 +24,7 @@ func TestSetTrace(t *testing.T) {
 
 		err := SetTrace(req, params)
 		assert.NoError(t, err)
+		assert.Equal(t, protocol.TraceValueOff, protocol.GetTraceValue())
 	})
 
 	t.Run("handles messages trace level", func(t *testing.T) {
@@ -35,6 +38,7 @@ func TestSetTrace(t *testing.T) {
 
 		err := SetTrace(req, params)
 		assert.NoError(t, err)
+		assert.Equal(t, protocol.TraceValueMessage, protocol.GetTraceValue())
 	})
 
 	t.Run("handles verbose trace level", func(t *testing.T) {
@@ -48,6 +52,7 @@ func TestSetTrace(t *testing.T) {
 
 		err := SetTrace(req, params)
 		assert.NoError(t, err)
+		assert.Equal(t, protocol.TraceValueVerbose, protocol.GetTraceValue())
 	})
 
 	t.Run("handles invalid trace level gracefully", func(t *testing.T) {
@@ -59,8 +64,9 @@ func TestSetTrace(t *testing.T) {
 			Value: "invalid",
 		}
 
-		// Should not error, just log
+		// Should not error, just log and keep the previous level
 		err := SetTrace(req, params)
 		assert.NoError(t, err)
+		assert.Equal(t, protocol.TraceValueVerbose, protocol.GetTraceValue())
 	})
 }
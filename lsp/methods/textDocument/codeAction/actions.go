@@ -1,6 +1,7 @@
 package codeaction
 
 import (
+	"errors"
 	"fmt"
 	"strings"
 
@@ -28,7 +29,7 @@ func createReplacementAction(req *types.RequestContext, uri string, varCall cssp
 
 	kind := protocol.CodeActionKindQuickFix
 	action := protocol.CodeAction{
-		Title: fmt.Sprintf("Replace with '%s'", cssVarName),
+		Title: req.Localize("Replace with '%s'", cssVarName),
 		Kind:  &kind,
 		Edit: &protocol.WorkspaceEdit{
 			Changes: map[string][]protocol.TextEdit{
@@ -62,7 +63,7 @@ func createReplacementAction(req *types.RequestContext, uri string, varCall cssp
 
 // createLiteralValueAction creates a code action to replace a var() call with a literal value.
 // Returns nil if the token value cannot be formatted for CSS.
-func createLiteralValueAction(uri string, varCall cssparser.VarCall, token *tokens.Token, matchingDiag *protocol.Diagnostic) *protocol.CodeAction {
+func createLiteralValueAction(req *types.RequestContext, uri string, varCall cssparser.VarCall, token *tokens.Token, matchingDiag *protocol.Diagnostic) *protocol.CodeAction {
 	formattedValue, err := css.FormatTokenValueForCSS(token)
 	if err != nil {
 		return nil
@@ -70,7 +71,7 @@ func createLiteralValueAction(uri string, varCall cssparser.VarCall, token *toke
 
 	kind := protocol.CodeActionKindQuickFix
 	action := protocol.CodeAction{
-		Title: fmt.Sprintf("Replace with literal value '%s'", formattedValue),
+		Title: req.Localize("Replace with literal value '%s'", formattedValue),
 		Kind:  &kind,
 		Edit: &protocol.WorkspaceEdit{
 			Changes: map[string][]protocol.TextEdit{
@@ -114,8 +115,14 @@ func createFixFallbackAction(req *types.RequestContext, uri string, varCall cssp
 		}
 	}
 
+	via, err := aliasChainNote(req, token)
+	if err != nil {
+		req.AddWarning(fmt.Errorf("cannot format token %q for fallback: %w", token.Name, err))
+		return nil
+	}
+
 	// Format the token value for CSS
-	formattedValue, err := css.FormatTokenValueForCSS(token)
+	formattedValue, err := fixedFallbackValue(varCall, token)
 	if err != nil {
 		req.AddWarning(fmt.Errorf("cannot format token %q for fallback: %w", token.Name, err))
 		return nil
@@ -126,7 +133,7 @@ func createFixFallbackAction(req *types.RequestContext, uri string, varCall cssp
 
 	kind := protocol.CodeActionKindQuickFix
 	action := protocol.CodeAction{
-		Title: fmt.Sprintf("Fix fallback value to '%s'", formattedValue),
+		Title: req.Localize("Fix fallback value to '%s'", formattedValue) + via,
 		Kind:  &kind,
 		Edit: &protocol.WorkspaceEdit{
 			Changes: map[string][]protocol.TextEdit{
@@ -159,11 +166,63 @@ func createFixFallbackAction(req *types.RequestContext, uri string, varCall cssp
 	return &action
 }
 
+// formatFallback formats a token's value for a var() fallback. Composite
+// tokens are expanded when the compositeFallbacks setting allows it, with
+// their configured serializer if there is one, or else with a warning for
+// sub-values the expanded value leaves out.
+func formatFallback(req *types.RequestContext, token *tokens.Token) (string, error) {
+	if req.Config().CompositeFallbacks {
+		if serializer := req.Config().CompositeSerializer(token.Type); serializer != "" {
+			return css.SerializeCompositeValue(token, serializer)
+		}
+	}
+	if !req.Config().CompositeFallbacks || !css.IsCompositeType(token.Type) {
+		return css.FormatTokenValueForCSS(token)
+	}
+	value, dropped, err := css.ExpandCompositeValue(token)
+	if err != nil {
+		return "", err
+	}
+	if len(dropped) > 0 {
+		req.AddWarning(fmt.Errorf("fallback for %s token %q leaves out %s", token.Type, token.Name, strings.Join(dropped, ", ")))
+	}
+	return value, nil
+}
+
+// aliasChainNote describes, for action titles, the tokens an alias token's
+// value comes through, e.g. " (via color.brand → color.primary)", or is
+// empty for other tokens. Circular aliases, which have no value to use as a
+// fallback, are an error.
+func aliasChainNote(req *types.RequestContext, token *tokens.Token) (string, error) {
+	if tokens.AliasTarget(token) == "" {
+		return "", nil
+	}
+	chain, err := tokens.AliasChain(token, req.Server.TokenManager().Get)
+	var cycle *tokens.CircularReferenceError
+	if errors.As(err, &cycle) {
+		return "", err
+	}
+	if len(chain) < 2 {
+		return "", nil
+	}
+	paths := make([]string, 0, len(chain)-1)
+	for _, link := range chain[1:] {
+		paths = append(paths, tokens.TokenPath(strings.Join(link.Path, ".")))
+	}
+	return req.Localize(" (via %s)", strings.Join(paths, " → ")), nil
+}
+
 // createAddFallbackAction creates a code action to add a fallback value.
 // Returns nil if the token value cannot be safely formatted for CSS.
 func createAddFallbackAction(req *types.RequestContext, uri string, varCall cssparser.VarCall, token *tokens.Token) *protocol.CodeAction {
+	via, err := aliasChainNote(req, token)
+	if err != nil {
+		req.AddWarning(fmt.Errorf("cannot format token %q for fallback: %w", token.Name, err))
+		return nil
+	}
+
 	// Format the token value for safe CSS insertion
-	formattedValue, err := css.FormatTokenValueForCSS(token)
+	formattedValue, err := formatFallback(req, token)
 	if err != nil {
 		req.AddWarning(fmt.Errorf("cannot format token %q for fallback: %w", token.Name, err))
 		return nil
@@ -174,7 +233,7 @@ func createAddFallbackAction(req *types.RequestContext, uri string, varCall cssp
 
 	kind := protocol.CodeActionKindQuickFix
 	action := protocol.CodeAction{
-		Title: fmt.Sprintf("Add fallback value '%s'", formattedValue),
+		Title: req.Localize("Add fallback value '%s'", formattedValue) + via,
 		Kind:  &kind,
 		Edit: &protocol.WorkspaceEdit{
 			Changes: map[string][]protocol.TextEdit{
@@ -213,22 +272,21 @@ func createDeprecatedTokenActions(req *types.RequestContext, uri string, varCall
 		}
 	}
 
-	// Try to extract recommended replacement from deprecation message
-	recommendedToken := extractRecommendedToken(token.DeprecationMessage)
-
-	// If we found a recommended token, try to create a replacement action
-	if recommendedToken != "" {
-		cssVarName := "--" + strings.ReplaceAll(recommendedToken, ".", "-")
-		replacementToken := req.Server.Token(cssVarName)
-		if replacementToken != nil {
-			if action := createReplacementAction(req, uri, varCall, cssVarName, replacementToken, matchingDiag); action != nil {
-				actions = append(actions, *action)
-			}
+	// Replace with the token the deprecation message recommends, or else
+	// with a token of the same value, letting the user choose among several
+	switch candidates := replacementCandidates(req, token); len(candidates) {
+	case 0:
+	case 1:
+		cssVarName := req.Server.TokenManager().CSSVariableName(candidates[0])
+		if action := createReplacementAction(req, uri, varCall, cssVarName, candidates[0], matchingDiag); action != nil {
+			actions = append(actions, *action)
 		}
+	default:
+		actions = append(actions, createPickReplacementAction(req, uri, varCall, token, candidates, matchingDiag)...)
 	}
 
 	// Always try to add a literal value action as an alternative
-	if action := createLiteralValueAction(uri, varCall, token, matchingDiag); action != nil {
+	if action := createLiteralValueAction(req, uri, varCall, token, matchingDiag); action != nil {
 		actions = append(actions, *action)
 	}
 
@@ -249,7 +307,7 @@ func createToggleFallbackAction(req *types.RequestContext, uri string, varCall c
 		newText = fmt.Sprintf("var(%s)", varCall.TokenName)
 	} else {
 		// No fallback - add it
-		formattedValue, err := css.FormatTokenValueForCSS(token)
+		formattedValue, err := formatFallback(req, token)
 		if err != nil {
 			req.AddWarning(fmt.Errorf("cannot format token %q for fallback: %w", token.Name, err))
 			return nil
@@ -259,7 +317,7 @@ func createToggleFallbackAction(req *types.RequestContext, uri string, varCall c
 
 	kind := protocol.CodeActionKindRefactorRewrite
 	action := protocol.CodeAction{
-		Title: "Toggle design token fallback value",
+		Title: req.Localize("Toggle design token fallback value"),
 		Kind:  &kind,
 		Edit: &protocol.WorkspaceEdit{
 			Changes: map[string][]protocol.TextEdit{
@@ -300,7 +358,7 @@ func createToggleRangeFallbacksAction(req *types.RequestContext, uri string, var
 			newText = fmt.Sprintf("var(%s)", varCall.TokenName)
 		} else {
 			// No fallback - add it
-			formattedValue, err := css.FormatTokenValueForCSS(token)
+			formattedValue, err := formatFallback(req, token)
 			if err != nil {
 				req.AddWarning(fmt.Errorf("cannot format token %q for fallback: %w", token.Name, err))
 				continue
@@ -326,10 +384,11 @@ func createToggleRangeFallbacksAction(req *types.RequestContext, uri string, var
 	if len(edits) == 0 {
 		return nil
 	}
+	edits = resolveEditConflicts(req, edits)
 
 	kind := protocol.CodeActionKindRefactorRewrite
 	action := protocol.CodeAction{
-		Title: "Toggle design token fallback values (in range)",
+		Title: req.Localize("Toggle design token fallback values (in range)"),
 		Kind:  &kind,
 		Edit: &protocol.WorkspaceEdit{
 			Changes: map[string][]protocol.TextEdit{
@@ -342,10 +401,10 @@ func createToggleRangeFallbacksAction(req *types.RequestContext, uri string, var
 
 // createFixAllFallbacksAction creates a source fixAll action to fix all incorrect fallback values.
 // The actual edits are computed in the resolve step.
-func createFixAllFallbacksAction(uri string, varCalls []*cssparser.VarCall) *protocol.CodeAction {
-	kind := protocol.CodeActionKind("source.fixAll")
+func createFixAllFallbacksAction(req *types.RequestContext, uri string, varCalls []*cssparser.VarCall) *protocol.CodeAction {
+	kind := CodeActionKindSourceFixAllDesignTokens
 	action := protocol.CodeAction{
-		Title: "Fix all token fallback values",
+		Title: req.Localize("Fix all token fallback values"),
 		Kind:  &kind,
 		// Data field is used to pass var calls to resolve step
 		Data: map[string]any{
@@ -385,7 +444,7 @@ func createToggleActions(req *types.RequestContext, uri string, varCallsInRange
 
 // createFixAllActionIfNeeded creates a fix-all action if there are multiple incorrect-fallback diagnostics.
 // Returns the action or nil if not needed.
-func createFixAllActionIfNeeded(uri string, varCalls []*cssparser.VarCall, diagnostics []protocol.Diagnostic) *protocol.CodeAction {
+func createFixAllActionIfNeeded(req *types.RequestContext, uri string, varCalls []*cssparser.VarCall, diagnostics []protocol.Diagnostic) *protocol.CodeAction {
 	if len(diagnostics) < 2 {
 		return nil
 	}
@@ -403,5 +462,5 @@ func createFixAllActionIfNeeded(uri string, varCalls []*cssparser.VarCall, diagn
 		return nil
 	}
 
-	return createFixAllFallbacksAction(uri, varCalls)
+	return createFixAllFallbacksAction(req, uri, varCalls)
 }
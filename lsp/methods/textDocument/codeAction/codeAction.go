@@ -3,11 +3,11 @@ package codeaction
 import (
 	"bennypowers.dev/dtls/internal/log"
 	"fmt"
-	"strings"
 
 	"bennypowers.dev/dtls/internal/documents"
 	"bennypowers.dev/dtls/internal/parser"
 	cssparser "bennypowers.dev/dtls/internal/parser/css"
+	"bennypowers.dev/dtls/internal/parser/tokenfile"
 	"bennypowers.dev/dtls/lsp/helpers"
 	"bennypowers.dev/dtls/lsp/helpers/css"
 	"bennypowers.dev/dtls/lsp/types"
@@ -21,6 +21,7 @@ func validateCSSDocument(req *types.RequestContext, uri string) (*documents.Docu
 	if doc == nil {
 		return nil, false
 	}
+	req.TraceDocument(doc)
 	if !parser.IsCSSSupportedLanguage(doc.LanguageID()) {
 		return nil, false
 	}
@@ -42,23 +43,21 @@ func parseVarCalls(doc *documents.Document) ([]*cssparser.VarCall, error) {
 
 // processVarCalls processes all var() calls in the requested range and generates code actions.
 // Returns the list of code actions and the var calls that were in range.
-func processVarCalls(req *types.RequestContext, uri string, varCalls []*cssparser.VarCall, params *protocol.CodeActionParams) ([]protocol.CodeAction, []cssparser.VarCall) {
+func processVarCalls(req *types.RequestContext, doc *documents.Document, varCalls []*cssparser.VarCall, params *protocol.CodeActionParams) ([]protocol.CodeAction, []cssparser.VarCall) {
+	uri := doc.URI()
 	var actions []protocol.CodeAction
 	var varCallsInRange []cssparser.VarCall
 
 	// Check each var() call in the requested range
 	for _, varCall := range varCalls {
-		// Check if var call intersects with the requested range
-		if !helpers.RangesIntersect(params.Range, protocol.Range{
-			Start: protocol.Position{
-				Line:      varCall.Range.Start.Line,
-				Character: varCall.Range.Start.Character,
-			},
-			End: protocol.Position{
-				Line:      varCall.Range.End.Line,
-				Character: varCall.Range.End.Character,
-			},
-		}) {
+		// Uses of an SCSS variable are fixed where the variable is declared,
+		// and names in JS/TS strings have no var() call to fix
+		if varCall.Alias != "" || varCall.Type == cssparser.PropertyNameReference {
+			continue
+		}
+
+		// Check if the requested range (or cursor) is on the var call
+		if !helpers.RangeHits(params.Range, css.ToProtocolRange(varCall.Range)) {
 			continue
 		}
 
@@ -67,6 +66,8 @@ func processVarCalls(req *types.RequestContext, uri string, varCalls []*cssparse
 		// Look up the token
 		token := req.Server.Token(varCall.TokenName)
 		if token == nil {
+			// Offer to define the missing token
+			actions = append(actions, createTokenActions(req, *varCall)...)
 			continue
 		}
 
@@ -76,52 +77,33 @@ func processVarCalls(req *types.RequestContext, uri string, varCalls []*cssparse
 		}
 
 		// Create code actions for incorrect fallback
-		if varCall.Fallback != nil {
-			fallbackValue := *varCall.Fallback
-			tokenValue := token.Value
-
-			if !css.IsCSSValueSemanticallyEquivalent(fallbackValue, tokenValue) {
+		if varCall.FallbackVar != nil {
+			// Nested fallback chains are collapsed or rebuilt from their outermost call
+			if !varCall.Nested {
+				actions = append(actions, createFallbackChainActions(req, doc, *varCall, token)...)
+			}
+		} else if varCall.Fallback != nil {
+			if fallbackNeedsFix(req, *varCall, token) {
 				if action := createFixFallbackAction(req, uri, *varCall, token, params.Context.Diagnostics); action != nil {
 					actions = append(actions, *action)
 				}
 			}
-		} else if token.Type == "color" || token.Type == "dimension" {
-			// Suggest adding fallback for color and dimension tokens
+		} else if token.Type == "color" || token.Type == "dimension" ||
+			(req.Config().CompositeFallbacks && (css.IsCompositeType(token.Type) || req.Config().CompositeSerializer(token.Type) != "")) {
+			// Suggest adding fallback for color and dimension tokens, and
+			// composite tokens when their fallbacks are enabled
 			if action := createAddFallbackAction(req, uri, *varCall, token); action != nil {
 				actions = append(actions, *action)
 			}
 		}
-	}
-
-	return actions, varCallsInRange
-}
-
-// extractRecommendedToken extracts the recommended replacement token from a deprecation message.
-// Returns the token name if found, empty string otherwise.
-func extractRecommendedToken(deprecationMessage string) string {
-	if deprecationMessage == "" {
-		return ""
-	}
-
-	// Pattern: "Use X instead" or "Use X.Y instead"
-	if idx := strings.Index(deprecationMessage, "Use "); idx != -1 {
-		rest := deprecationMessage[idx+4:]
-		if endIdx := strings.Index(rest, " instead"); endIdx != -1 {
-			return strings.TrimSpace(rest[:endIdx])
-		}
-	}
 
-	// Pattern: "Replaced by X"
-	if idx := strings.Index(deprecationMessage, "Replaced by "); idx != -1 {
-		rest := deprecationMessage[idx+12:]
-		// Take until space or end of string
-		if spaceIdx := strings.Index(rest, " "); spaceIdx != -1 {
-			return rest[:spaceIdx]
+		// Offer the component local-override pattern
+		if action := createLocalOverrideAction(req, uri, *varCall, token); action != nil {
+			actions = append(actions, *action)
 		}
-		return rest
 	}
 
-	return ""
+	return actions, varCallsInRange
 }
 
 // resolveFixAllFallbacks resolves the fixAll action by computing edits for all incorrect fallbacks
@@ -138,23 +120,58 @@ func resolveFixAllFallbacks(req *types.RequestContext, action *protocol.CodeActi
 	}
 	uri := uriVal.(string)
 
+	edits, err := FixAllFallbacksEdits(req, uri)
+	if err != nil {
+		log.Error("Fix-all resolution: %v", err)
+		return action, nil
+	}
+	if edits == nil {
+		return action, nil
+	}
+
+	// Add edits to the action
+	edit := &protocol.WorkspaceEdit{
+		Changes: map[string][]protocol.TextEdit{
+			uri: edits,
+		},
+	}
+	if err := prepareWorkspaceEdit(req, edit); err != nil {
+		log.Error("Fix-all resolution: %v", err)
+		return action, nil
+	}
+	action.Edit = edit
+
+	return action, nil
+}
+
+// FixAllFallbacksEdits computes the edits that fix every incorrect literal
+// fallback in a document. Returns nil edits when the document is not open.
+func FixAllFallbacksEdits(req *types.RequestContext, uri string) ([]protocol.TextEdit, error) {
 	// Get document
 	doc := req.Server.Document(uri)
 	if doc == nil {
-		return action, nil
+		return nil, nil
 	}
+	req.TraceDocument(doc)
+	return fixAllFallbacksEdits(req, doc)
+}
+
+// fixAllFallbacksEdits computes the edits that fix every incorrect literal
+// fallback in a document, which may be open or read from disk
+func fixAllFallbacksEdits(req *types.RequestContext, doc *documents.Document) ([]protocol.TextEdit, error) {
+	uri := doc.URI()
 
 	// Parse CSS to find all var() calls
 	result, err := parser.ParseCSSFromDocument(doc.Content(), doc.LanguageID())
 	if err != nil {
-		log.Error("Failed to parse %s (%s) for fix-all resolution: %v", uri, doc.LanguageID(), err)
-		return action, nil
+		return nil, fmt.Errorf("failed to parse %s (%s): %w", uri, doc.LanguageID(), err)
 	}
 	if result == nil {
-		return action, nil
+		return nil, nil
 	}
+	req.TraceVarCalls(len(result.VarCalls))
 
-	var edits []protocol.TextEdit
+	edits := []protocol.TextEdit{}
 
 	// Fix all var() calls with incorrect fallbacks
 	for _, varCall := range result.VarCalls {
@@ -163,14 +180,11 @@ func resolveFixAllFallbacks(req *types.RequestContext, action *protocol.CodeActi
 			continue
 		}
 
-		// Only fix if there's a fallback that's incorrect
-		if varCall.Fallback != nil {
-			fallbackValue := *varCall.Fallback
-			tokenValue := token.Value
-
-			if !css.IsCSSValueSemanticallyEquivalent(fallbackValue, tokenValue) {
+		// Only fix if there's a literal fallback that's incorrect or malformed
+		if varCall.Fallback != nil && varCall.FallbackVar == nil {
+			if fallbackNeedsFix(req, *varCall, token) {
 				// Format the token value
-				formattedValue, err := css.FormatTokenValueForCSS(token)
+				formattedValue, err := fixedFallbackValue(*varCall, token)
 				if err != nil {
 					req.AddWarning(fmt.Errorf("cannot format token %q: %w", token.Name, err))
 					continue
@@ -195,14 +209,7 @@ func resolveFixAllFallbacks(req *types.RequestContext, action *protocol.CodeActi
 		}
 	}
 
-	// Add edits to the action
-	action.Edit = &protocol.WorkspaceEdit{
-		Changes: map[string][]protocol.TextEdit{
-			uri: edits,
-		},
-	}
-
-	return action, nil
+	return resolveEditConflicts(req, edits), nil
 }
 
 // CodeAction handles the textDocument/codeAction request
@@ -210,6 +217,11 @@ func CodeAction(req *types.RequestContext, params *protocol.CodeActionParams) (a
 	uri := params.TextDocument.URI
 	log.Info("CodeAction requested: %s", uri)
 
+	// Code actions may be disabled in configuration after initialize
+	if !req.Config().Features.CodeActionsEnabled() {
+		return nil, nil
+	}
+
 	// Check if client supports CodeAction literals
 	// Legacy clients only support Command, which we don't implement
 	if !req.Server.SupportsCodeActionLiterals() {
@@ -217,6 +229,15 @@ func CodeAction(req *types.RequestContext, params *protocol.CodeActionParams) (a
 		return nil, nil
 	}
 
+	// Token files get fixes for scale and keyword diagnostics, and refactors
+	// for the group under the cursor
+	if doc := req.Server.Document(uri); doc != nil && tokenfile.IsLanguage(doc.LanguageID()) && req.Server.ShouldProcessAsTokenFile(uri) {
+		actions := createScaleFixActions(req, uri, params.Context.Diagnostics)
+		actions = append(actions, createKeywordFixActions(req, uri, params.Context.Diagnostics)...)
+		actions = append(actions, createGroupActions(req, doc, params)...)
+		return editTextActions(req, applyClientKinds(req, prepareActionEdits(req, actions))), nil
+	}
+
 	// Validate document
 	doc, ok := validateCSSDocument(req, uri)
 	if !ok {
@@ -228,18 +249,33 @@ func CodeAction(req *types.RequestContext, params *protocol.CodeActionParams) (a
 	if err != nil {
 		return nil, err
 	}
+	req.TraceVarCalls(len(varCalls))
 
 	// Process var calls and collect actions
-	actions, varCallsInRange := processVarCalls(req, uri, varCalls, params)
+	actions, varCallsInRange := processVarCalls(req, doc, varCalls, params)
 
 	// Add toggle actions
 	actions = append(actions, createToggleActions(req, uri, varCallsInRange, params.Range)...)
 
+	// Add updates for stale values in generated custom properties files
+	actions = append(actions, createStaleCustomPropertyActions(req, doc, params)...)
+
 	// Add fix-all action if needed
-	if fixAllAction := createFixAllActionIfNeeded(uri, varCalls, params.Context.Diagnostics); fixAllAction != nil {
-		actions = append(actions, *fixAllAction)
+	if fixAllAction := createFixAllActionIfNeeded(req, uri, varCalls, params.Context.Diagnostics); fixAllAction != nil {
+		actions = append(actions, *fixAllAction,
+			createPreviewAction(req, PreviewFixAllFallbacksCommand, uri),
+			createWorkspacePreviewAction(req, PreviewFixAllFallbacksCommand))
 	}
 
+	// Offer a dry run of migrating several deprecated tokens at once
+	if countDeprecatedDiagnostics(params.Context.Diagnostics) >= 2 {
+		actions = append(actions,
+			createPreviewAction(req, PreviewDeprecatedMigrationCommand, uri),
+			createWorkspacePreviewAction(req, PreviewDeprecatedMigrationCommand))
+	}
+
+	actions = editTextActions(req, applyClientKinds(req, prepareActionEdits(req, actions)))
+
 	log.Info("Returning %d code actions", len(actions))
 	return actions, nil
 }
@@ -248,8 +284,10 @@ func CodeAction(req *types.RequestContext, params *protocol.CodeActionParams) (a
 func CodeActionResolve(req *types.RequestContext, action *protocol.CodeAction) (*protocol.CodeAction, error) {
 	log.Info("CodeActionResolve requested: %s", action.Title)
 
-	// Handle fixAllFallbacks which uses lazy resolution
-	if action.Title == "Fix all token fallback values" {
+	// Handle fixAllFallbacks which uses lazy resolution. The title is
+	// localized, so match the kind, which clients may have received as a
+	// parent kind
+	if action.Kind != nil && isKindOf(CodeActionKindSourceFixAllDesignTokens, *action.Kind) {
 		return resolveFixAllFallbacks(req, action)
 	}
 
@@ -1,6 +1,7 @@
 package codeaction_test
 
 import (
+	"encoding/json"
 	"testing"
 
 	"bennypowers.dev/dtls/internal/tokens"
@@ -12,19 +13,15 @@ import (
 	protocol "github.com/tliron/glsp/protocol_3_16"
 )
 
-const (
-	// CodeActionKindSourceFixAll is not defined in glsp v0.2.2
-	codeActionKindSourceFixAll protocol.CodeActionKind = "source.fixAll"
-)
-
 // ptrIntegerOrString returns a pointer to IntegerOrString from a string
 func ptrIntegerOrString(s string) *protocol.IntegerOrString {
 	return &protocol.IntegerOrString{Value: s}
 }
 
-// setCodeActionLiteralSupport sets the client capabilities to support CodeAction literals
+// setCodeActionLiteralSupport sets the client capabilities to support CodeAction literals and
+// workspace edits
 func setCodeActionLiteralSupport(s *lsp.Server) {
-	s.SetClientCapabilities(protocol.ClientCapabilities{
+	caps := protocol.ClientCapabilities{
 		TextDocument: &protocol.TextDocumentClientCapabilities{
 			CodeAction: &protocol.CodeActionClientCapabilities{
 				CodeActionLiteralSupport: &struct {
@@ -43,7 +40,10 @@ func setCodeActionLiteralSupport(s *lsp.Server) {
 				},
 			},
 		},
-	})
+	}
+	// Workspace capabilities are an anonymous struct, so decode them
+	_ = json.Unmarshal([]byte(`{"applyEdit": true}`), &caps.Workspace)
+	s.SetClientCapabilities(caps)
 }
 
 // TestRangesIntersect tests the rangesIntersect function with half-open range semantics [start, end)
@@ -335,7 +335,7 @@ func TestFixAllFallbacks(t *testing.T) {
 
 	require.NotNil(t, fixAllAction, "Should have fixAll action")
 	require.NotNil(t, fixAllAction.Kind)
-	assert.Equal(t, codeActionKindSourceFixAll, *fixAllAction.Kind)
+	assert.Equal(t, codeaction.CodeActionKindSourceFixAllDesignTokens, *fixAllAction.Kind)
 
 	// Resolve the action to get edits
 	req = types.NewRequestContext(s, nil)
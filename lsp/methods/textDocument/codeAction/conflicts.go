@@ -0,0 +1,85 @@
+package codeaction
+
+import (
+	"fmt"
+	"slices"
+
+	"bennypowers.dev/dtls/lsp/helpers"
+	"bennypowers.dev/dtls/lsp/types"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+// resolveEditConflicts drops edits that overlap another, since clients reject
+// a WorkspaceEdit whose TextEdits overlap. Nested var() calls produce such
+// edits, e.g. fixing both var(--a, var(--b, 1px)) and its inner var(--b, 1px).
+//
+// Duplicate edits collapse to one. When one edit contains another, the
+// outermost wins, since its text already covers the nested call. When edits
+// partially overlap, the earlier one wins. Each dropped edit is reported as a
+// request warning. Returns the kept edits in document order.
+func resolveEditConflicts(req *types.RequestContext, edits []protocol.TextEdit) []protocol.TextEdit {
+	if len(edits) < 2 {
+		return edits
+	}
+
+	// Document order, outermost first among edits starting together
+	sorted := slices.Clone(edits)
+	slices.SortStableFunc(sorted, func(a, b protocol.TextEdit) int {
+		if c := helpers.ComparePositions(a.Range.Start, b.Range.Start); c != 0 {
+			return c
+		}
+		return helpers.ComparePositions(b.Range.End, a.Range.End)
+	})
+
+	kept := []protocol.TextEdit{sorted[0]}
+	for _, edit := range sorted[1:] {
+		last := kept[len(kept)-1]
+		if edit == last {
+			continue
+		}
+		if helpers.RangesIntersect(last.Range, edit.Range) {
+			req.AddWarning(fmt.Errorf("skipped edit at %d:%d overlapping edit at %d:%d",
+				edit.Range.Start.Line, edit.Range.Start.Character,
+				last.Range.Start.Line, last.Range.Start.Character))
+			continue
+		}
+		kept = append(kept, edit)
+	}
+	return kept
+}
+
+// prepareActionEdits sorts each action's edits for safe application and drops
+// actions whose edits overlap or fall outside the tracked document, so a
+// client never applies a partial or corrupting edit.
+func prepareActionEdits(req *types.RequestContext, actions []protocol.CodeAction) []protocol.CodeAction {
+	prepared := actions[:0]
+	for _, action := range actions {
+		if err := prepareWorkspaceEdit(req, action.Edit); err != nil {
+			req.AddWarning(fmt.Errorf("dropped code action %q: %w", action.Title, err))
+			continue
+		}
+		prepared = append(prepared, action)
+	}
+	return prepared
+}
+
+// prepareWorkspaceEdit sorts and validates the edits of each document in edit,
+// checking bounds against documents the server tracks
+func prepareWorkspaceEdit(req *types.RequestContext, edit *protocol.WorkspaceEdit) error {
+	if edit == nil {
+		return nil
+	}
+	for uri, edits := range edit.Changes {
+		sorted, err := helpers.PrepareEdits(edits)
+		if err != nil {
+			return err
+		}
+		if doc := req.Server.Document(uri); doc != nil {
+			if err := helpers.CheckEditBounds(doc.Content(), sorted); err != nil {
+				return err
+			}
+		}
+		edit.Changes[uri] = sorted
+	}
+	return nil
+}
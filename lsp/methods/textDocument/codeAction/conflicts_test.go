@@ -0,0 +1,126 @@
+package codeaction
+
+import (
+	"testing"
+
+	cssparser "bennypowers.dev/dtls/internal/parser/css"
+	"bennypowers.dev/dtls/internal/tokens"
+	"bennypowers.dev/dtls/lsp/testutil"
+	"bennypowers.dev/dtls/lsp/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+func lineEdit(startChar, endChar uint32, text string) protocol.TextEdit {
+	return protocol.TextEdit{
+		Range: protocol.Range{
+			Start: protocol.Position{Line: 0, Character: startChar},
+			End:   protocol.Position{Line: 0, Character: endChar},
+		},
+		NewText: text,
+	}
+}
+
+func TestResolveEditConflicts(t *testing.T) {
+	tests := []struct {
+		name     string
+		edits    []protocol.TextEdit
+		want     []protocol.TextEdit
+		warnings int
+	}{
+		{
+			name:  "disjoint edits are sorted",
+			edits: []protocol.TextEdit{lineEdit(20, 30, "b"), lineEdit(0, 10, "a")},
+			want:  []protocol.TextEdit{lineEdit(0, 10, "a"), lineEdit(20, 30, "b")},
+		},
+		{
+			name:  "adjacent edits are kept",
+			edits: []protocol.TextEdit{lineEdit(0, 10, "a"), lineEdit(10, 20, "b")},
+			want:  []protocol.TextEdit{lineEdit(0, 10, "a"), lineEdit(10, 20, "b")},
+		},
+		{
+			name:  "duplicates collapse silently",
+			edits: []protocol.TextEdit{lineEdit(0, 10, "a"), lineEdit(0, 10, "a")},
+			want:  []protocol.TextEdit{lineEdit(0, 10, "a")},
+		},
+		{
+			name:     "outermost wins over nested",
+			edits:    []protocol.TextEdit{lineEdit(15, 25, "inner"), lineEdit(5, 30, "outer")},
+			want:     []protocol.TextEdit{lineEdit(5, 30, "outer")},
+			warnings: 1,
+		},
+		{
+			name:     "outermost wins when starting together",
+			edits:    []protocol.TextEdit{lineEdit(5, 10, "inner"), lineEdit(5, 30, "outer")},
+			want:     []protocol.TextEdit{lineEdit(5, 30, "outer")},
+			warnings: 1,
+		},
+		{
+			name:     "earlier wins on partial overlap",
+			edits:    []protocol.TextEdit{lineEdit(8, 20, "b"), lineEdit(0, 10, "a")},
+			want:     []protocol.TextEdit{lineEdit(0, 10, "a")},
+			warnings: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := types.NewRequestContext(testutil.NewMockServerContext(), nil)
+			assert.Equal(t, tt.want, resolveEditConflicts(req, tt.edits))
+			assert.Len(t, req.Warnings(), tt.warnings)
+		})
+	}
+}
+
+func TestToggleRangeFallbacks_NestedVarCalls(t *testing.T) {
+	ctx := testutil.NewMockServerContext()
+	require.NoError(t, ctx.TokenManager().Add(&tokens.Token{Name: "space-large", Value: "16px", Type: "dimension"}))
+	require.NoError(t, ctx.TokenManager().Add(&tokens.Token{Name: "space-small", Value: "4px", Type: "dimension"}))
+
+	uri := "file:///nested.css"
+	content := `.a { padding: var(--space-large, var(--space-small, 4px)); }`
+	require.NoError(t, ctx.DocumentManager().DidOpen(uri, "css", 1, content))
+
+	req := types.NewRequestContext(ctx, nil)
+	varCalls, err := parseVarCalls(ctx.Document(uri))
+	require.NoError(t, err)
+	require.Len(t, varCalls, 2)
+
+	calls := make([]cssparser.VarCall, len(varCalls))
+	for i, vc := range varCalls {
+		calls[i] = *vc
+	}
+	action := createToggleRangeFallbacksAction(req, uri, calls)
+	require.NotNil(t, action)
+
+	edits := action.Edit.Changes[uri]
+	require.Len(t, edits, 1, "the nested call's edit overlaps the outer call's and is dropped")
+	assert.Equal(t, "var(--space-large)", edits[0].NewText)
+}
+
+func TestPrepareActionEdits(t *testing.T) {
+	ctx := testutil.NewMockServerContext()
+	uri := "file:///prepare.css"
+	require.NoError(t, ctx.DocumentManager().DidOpen(uri, "css", 1, `.a { color: red; }`))
+
+	action := func(title string, edits ...protocol.TextEdit) protocol.CodeAction {
+		return protocol.CodeAction{
+			Title: title,
+			Edit:  &protocol.WorkspaceEdit{Changes: map[protocol.DocumentUri][]protocol.TextEdit{uri: edits}},
+		}
+	}
+
+	req := types.NewRequestContext(ctx, nil)
+	actions := prepareActionEdits(req, []protocol.CodeAction{
+		action("sorted", lineEdit(0, 2, "b"), lineEdit(12, 15, "blue")),
+		action("out of bounds", lineEdit(12, 40, "blue")),
+		action("overlapping", lineEdit(0, 10, "a"), lineEdit(5, 15, "b")),
+	})
+
+	require.Len(t, actions, 1)
+	assert.Equal(t, "sorted", actions[0].Title)
+	assert.Equal(t, []protocol.TextEdit{lineEdit(12, 15, "blue"), lineEdit(0, 2, "b")}, actions[0].Edit.Changes[uri],
+		"edits are returned in descending document order")
+	assert.Len(t, req.Warnings(), 2)
+}
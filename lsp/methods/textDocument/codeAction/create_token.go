@@ -0,0 +1,305 @@
+package codeaction
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"bennypowers.dev/dtls/internal/log"
+	cssparser "bennypowers.dev/dtls/internal/parser/css"
+	"bennypowers.dev/dtls/internal/tokens"
+	"bennypowers.dev/dtls/internal/uriutil"
+	"bennypowers.dev/dtls/lsp/helpers"
+	"bennypowers.dev/dtls/lsp/types"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+// defaultJSONIndent indents new tokens in files whose indentation can't be detected
+const defaultJSONIndent = "  "
+
+// propertyTypes maps CSS properties to the $type of tokens they usually take
+var propertyTypes = map[string]string{
+	"background":                 "color",
+	"fill":                       "color",
+	"stroke":                     "color",
+	"font-family":                "fontFamily",
+	"font-weight":                "fontWeight",
+	"line-height":                "number",
+	"opacity":                    "number",
+	"z-index":                    "number",
+	"flex-grow":                  "number",
+	"flex-shrink":                "number",
+	"transition-duration":        "duration",
+	"transition-delay":           "duration",
+	"animation-duration":         "duration",
+	"animation-delay":            "duration",
+	"transition-timing-function": "cubicBezier",
+	"animation-timing-function":  "cubicBezier",
+	"box-shadow":                 "shadow",
+	"text-shadow":                "shadow",
+	"border":                     "border",
+	"transition":                 "transition",
+	"font":                       "typography",
+}
+
+// dimensionProperties are properties (and property prefixes, ending in "-")
+// whose values are lengths
+var dimensionProperties = []string{
+	"width", "height", "min-", "max-", "margin", "padding", "gap", "row-gap", "column-gap",
+	"top", "right", "bottom", "left", "inset", "font-size", "letter-spacing", "word-spacing",
+	"text-indent", "border-radius", "outline-offset", "flex-basis",
+}
+
+// inferTokenType guesses a token's $type from the CSS property it is used in.
+// Returns "" when the property gives no hint.
+func inferTokenType(property string) string {
+	property = strings.ToLower(property)
+	if t, ok := propertyTypes[property]; ok {
+		return t
+	}
+	if strings.HasSuffix(property, "color") {
+		return "color"
+	}
+	if strings.HasSuffix(property, "-width") || strings.HasPrefix(property, "border-") && strings.HasSuffix(property, "-radius") {
+		return "dimension"
+	}
+	for _, p := range dimensionProperties {
+		if property == p || strings.HasPrefix(property, p+"-") || strings.HasSuffix(p, "-") && strings.HasPrefix(property, p) {
+			return "dimension"
+		}
+	}
+	return ""
+}
+
+// stubValue returns the JSON $value of a new token: the call's literal
+// fallback when it has one, else a placeholder for the type
+func stubValue(tokenType string, fallback *string) string {
+	if fallback != nil && !strings.Contains(*fallback, "var(") {
+		if tokenType == "number" || tokenType == "fontWeight" {
+			if _, err := strconv.ParseFloat(*fallback, 64); err == nil {
+				return *fallback
+			}
+		}
+		quoted, _ := json.Marshal(*fallback)
+		return string(quoted)
+	}
+	switch tokenType {
+	case "color":
+		return `"#000000"`
+	case "dimension":
+		return `"0px"`
+	case "number":
+		return "0"
+	case "fontWeight":
+		return "400"
+	case "duration":
+		return `"0ms"`
+	default:
+		return `""`
+	}
+}
+
+// createTokenActions creates quick fixes for a var() call of an unknown token,
+// each appending a stub token to one of the loaded JSON token files whose
+// prefix matches. The token is placed in the file's deepest group matching
+// the variable name, e.g. color.accent for --color-accent.
+func createTokenActions(req *types.RequestContext, varCall cssparser.VarCall) []protocol.CodeAction {
+	manager := req.Server.TokenManager()
+	format := manager.NameFormat()
+	if format.Case == tokens.NameCaseCamel {
+		// camelCase names have no separators to recover the path from
+		return nil
+	}
+
+	files := manager.GetSourceFiles()
+	slices.Sort(files)
+
+	tokenType := inferTokenType(varCall.Property)
+	var actions []protocol.CodeAction
+	for _, file := range files {
+		if ext := filepath.Ext(file); ext != ".json" && ext != ".jsonc" {
+			continue
+		}
+		path := newTokenPath(format, varCall.TokenName, manager.GetBySourceFile(file))
+		if path == nil {
+			continue
+		}
+
+		uri := uriutil.PathToURI(file)
+		content, err := tokenFileContent(req, uri, file)
+		if err != nil {
+			log.Warn("Cannot read token file %s: %v", file, err)
+			continue
+		}
+		edit, err := insertTokenEdit(content, path, tokenType, stubValue(tokenType, varCall.Fallback))
+		if err != nil {
+			log.Debug("Cannot add %s to %s: %v", strings.Join(path, "."), file, err)
+			continue
+		}
+
+		kind := protocol.CodeActionKindQuickFix
+		actions = append(actions, protocol.CodeAction{
+			Title: req.Localize("Create token '%s' in %s", strings.Join(path, "."), filepath.Base(file)),
+			Kind:  &kind,
+			Edit: &protocol.WorkspaceEdit{
+				Changes: map[string][]protocol.TextEdit{uri: {edit}},
+			},
+		})
+	}
+	return actions
+}
+
+// newTokenPath returns the token path that the given tokens' file would name
+// variable, or nil when the variable doesn't carry the file's prefix.
+// The deepest existing group whose name starts the variable holds the new
+// token under the rest of the name; without one, each separated word of the
+// name becomes a path segment.
+func newTokenPath(format tokens.NameFormat, variable string, fileTokens []*tokens.Token) []string {
+	if len(fileTokens) == 0 {
+		return nil
+	}
+	prefix := fileTokens[0].Prefix
+	separator := format.Separator
+	if separator == "" {
+		separator = tokens.DefaultSeparator
+	}
+
+	root := strings.TrimSuffix(format.PathName(prefix, []string{"x"}), "x")
+	if !strings.HasPrefix(variable, root) || len(variable) == len(root) {
+		return nil
+	}
+
+	var group []string
+	matched := len(root)
+	for _, token := range fileTokens {
+		for depth := 1; depth < len(token.Path); depth++ {
+			name := format.PathName(prefix, token.Path[:depth]) + separator
+			if len(name) > matched && strings.HasPrefix(variable, name) {
+				group, matched = token.Path[:depth], len(name)
+			}
+		}
+	}
+
+	rest := variable[matched:]
+	if rest == "" {
+		return nil
+	}
+	if group == nil {
+		return strings.Split(rest, separator)
+	}
+	return append(slices.Clone(group), rest)
+}
+
+// tokenFileContent returns the open document's content, or the file on disk
+func tokenFileContent(req *types.RequestContext, uri, path string) (string, error) {
+	if doc := req.Server.Document(uri); doc != nil {
+		return doc.Content(), nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// insertTokenEdit builds the edit that adds a token at path to a JSON token
+// file, creating any missing groups. It fails when the token already exists.
+func insertTokenEdit(content string, path []string, tokenType, value string) (protocol.TextEdit, error) {
+	objects, err := scanJSONObjects(content)
+	if err != nil {
+		return protocol.TextEdit{}, err
+	}
+	if _, exists := objects[strings.Join(path, jsonPathSep)]; exists {
+		return protocol.TextEdit{}, fmt.Errorf("%s already exists", strings.Join(path, "."))
+	}
+
+	// The deepest existing group along the path holds the new members
+	depth := len(path) - 1
+	for ; depth > 0; depth-- {
+		if _, ok := objects[strings.Join(path[:depth], jsonPathSep)]; ok {
+			break
+		}
+	}
+	parent, ok := objects[strings.Join(path[:depth], jsonPathSep)]
+	if !ok {
+		return protocol.TextEdit{}, fmt.Errorf("no root object")
+	}
+
+	unit := defaultJSONIndent
+	if root := objects[""]; root.firstKey >= 0 {
+		if indent := ownLineIndent(content, root.firstKey); indent != "" {
+			unit = indent
+		}
+	}
+	closing := lineIndent(content, parent.close)
+	if sameLine(content, parent.open, parent.close) {
+		closing = lineIndent(content, parent.open)
+	}
+	indent := closing + unit
+	if parent.firstKey >= 0 {
+		if ownIndent := ownLineIndent(content, parent.firstKey); ownIndent != "" {
+			indent = ownIndent
+		}
+	}
+
+	body := []string{}
+	if tokenType != "" {
+		body = append(body, fmt.Sprintf(`"$type": %q`, tokenType))
+	}
+	body = append(body, `"$value": `+value)
+	stub := renderTokenStub(path[depth:], indent, unit, body)
+
+	if parent.lastMemberEnd >= 0 {
+		at := helpers.OffsetToPosition(content, parent.lastMemberEnd)
+		return protocol.TextEdit{
+			Range:   protocol.Range{Start: at, End: at},
+			NewText: ",\n" + indent + stub,
+		}, nil
+	}
+	return protocol.TextEdit{
+		Range: protocol.Range{
+			Start: helpers.OffsetToPosition(content, parent.open+1),
+			End:   helpers.OffsetToPosition(content, parent.close),
+		},
+		NewText: "\n" + indent + stub + "\n" + closing,
+	}, nil
+}
+
+// renderTokenStub renders `"a": { "b": { body } }` for keys [a, b], with the
+// first line unindented
+func renderTokenStub(keys []string, indent, unit string, body []string) string {
+	key, _ := json.Marshal(keys[0])
+	inner := indent + unit
+	if len(keys) == 1 {
+		return string(key) + ": {\n" + inner + strings.Join(body, ",\n"+inner) + "\n" + indent + "}"
+	}
+	return string(key) + ": {\n" + inner + renderTokenStub(keys[1:], inner, unit, body) + "\n" + indent + "}"
+}
+
+// lineIndent returns the leading whitespace of the line containing offset
+func lineIndent(content string, offset int) string {
+	start := strings.LastIndexByte(content[:offset], '\n') + 1
+	end := start
+	for end < len(content) && (content[end] == ' ' || content[end] == '\t') {
+		end++
+	}
+	return content[start:end]
+}
+
+// ownLineIndent returns the indentation before offset when only whitespace
+// precedes it on its line, else ""
+func ownLineIndent(content string, offset int) string {
+	start := strings.LastIndexByte(content[:offset], '\n') + 1
+	if strings.TrimLeft(content[start:offset], " \t") != "" {
+		return ""
+	}
+	return content[start:offset]
+}
+
+func sameLine(content string, a, b int) bool {
+	return !strings.Contains(content[a:b], "\n")
+}
@@ -0,0 +1,147 @@
+package codeaction
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"bennypowers.dev/dtls/internal/tokens"
+	"bennypowers.dev/dtls/internal/uriutil"
+	"bennypowers.dev/dtls/lsp/helpers"
+	"bennypowers.dev/dtls/lsp/testutil"
+	"bennypowers.dev/dtls/lsp/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+func TestInferTokenType(t *testing.T) {
+	tests := map[string]string{
+		"color":            "color",
+		"background-color": "color",
+		"border-top-color": "color",
+		"fill":             "color",
+		"padding-inline":   "dimension",
+		"max-width":        "dimension",
+		"border-width":     "dimension",
+		"gap":              "dimension",
+		"font-weight":      "fontWeight",
+		"line-height":      "number",
+		"box-shadow":       "shadow",
+		"display":          "",
+		"--local":          "",
+	}
+	for property, want := range tests {
+		assert.Equal(t, want, inferTokenType(property), property)
+	}
+}
+
+func TestInsertTokenEdit(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		path    []string
+		want    string
+	}{
+		{
+			name:    "into an existing group",
+			content: "{\n  \"color\": {\n    \"primary\": { \"$value\": \"#00f\" }\n  }\n}\n",
+			path:    []string{"color", "accent"},
+			want:    "{\n  \"color\": {\n    \"primary\": { \"$value\": \"#00f\" },\n    \"accent\": {\n      \"$type\": \"color\",\n      \"$value\": \"#f0f\"\n    }\n  }\n}\n",
+		},
+		{
+			name:    "creates missing groups",
+			content: "{\n    \"space\": {}\n}",
+			path:    []string{"color", "accent"},
+			want:    "{\n    \"space\": {},\n    \"color\": {\n        \"accent\": {\n            \"$type\": \"color\",\n            \"$value\": \"#f0f\"\n        }\n    }\n}",
+		},
+		{
+			name:    "into an empty group",
+			content: "{\n  \"color\": {\n  }\n}",
+			path:    []string{"color", "accent"},
+			want:    "{\n  \"color\": {\n    \"accent\": {\n      \"$type\": \"color\",\n      \"$value\": \"#f0f\"\n    }\n  }\n}",
+		},
+		{
+			name:    "skips comments and strings",
+			content: "{\n  // \"color\": {\n  \"$description\": \"a } b\"\n}",
+			path:    []string{"accent"},
+			want:    "{\n  // \"color\": {\n  \"$description\": \"a } b\",\n  \"accent\": {\n    \"$type\": \"color\",\n    \"$value\": \"#f0f\"\n  }\n}",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			edit, err := insertTokenEdit(tt.content, tt.path, "color", `"#f0f"`)
+			require.NoError(t, err)
+			got, err := helpers.ApplyEdits(tt.content, []protocol.TextEdit{edit})
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	t.Run("existing token", func(t *testing.T) {
+		_, err := insertTokenEdit(`{"color": {"accent": {"$value": "#f0f"}}}`, []string{"color", "accent"}, "", `""`)
+		assert.Error(t, err)
+	})
+
+	t.Run("invalid JSON", func(t *testing.T) {
+		_, err := insertTokenEdit(`{"color": `, []string{"color"}, "", `""`)
+		assert.Error(t, err)
+	})
+}
+
+func TestCreateTokenActions(t *testing.T) {
+	dir := t.TempDir()
+	tokensPath := filepath.Join(dir, "tokens.json")
+	tokensContent := "{\n  \"color\": {\n    \"brand\": {\n      \"primary\": { \"$value\": \"#00f\" }\n    }\n  }\n}\n"
+	require.NoError(t, os.WriteFile(tokensPath, []byte(tokensContent), 0o644))
+
+	ctx := testutil.NewMockServerContext()
+	ctx.SetSupportsCodeActionLiterals(true)
+	req := types.NewRequestContext(ctx, nil)
+	require.NoError(t, ctx.TokenManager().Add(&tokens.Token{
+		Name:     "color-brand-primary",
+		Path:     []string{"color", "brand", "primary"},
+		Prefix:   "ds",
+		Value:    "#00f",
+		FilePath: tokensPath,
+	}))
+
+	actionsFor := func(css string) []protocol.CodeAction {
+		uri := "file:///test.css"
+		require.NoError(t, ctx.DocumentManager().DidOpen(uri, "css", 1, css))
+		defer func() { _ = ctx.DocumentManager().DidClose(uri) }()
+
+		result, err := CodeAction(req, &protocol.CodeActionParams{
+			TextDocument: protocol.TextDocumentIdentifier{URI: uri},
+			Range:        protocol.Range{Start: protocol.Position{Line: 0, Character: 20}, End: protocol.Position{Line: 0, Character: 20}},
+		})
+		require.NoError(t, err)
+		actions, _ := result.([]protocol.CodeAction)
+		return actions
+	}
+
+	t.Run("deepest matching group", func(t *testing.T) {
+		actions := actionsFor(".a { color: var(--ds-color-brand-accent, #f0f); }")
+		require.Len(t, actions, 1)
+		assert.Equal(t, "Create token 'color.brand.accent' in tokens.json", actions[0].Title)
+
+		edits := actions[0].Edit.Changes[uriutil.PathToURI(tokensPath)]
+		got, err := helpers.ApplyEdits(tokensContent, edits)
+		require.NoError(t, err)
+		assert.Equal(t, "{\n  \"color\": {\n    \"brand\": {\n      \"primary\": { \"$value\": \"#00f\" },\n      \"accent\": {\n        \"$type\": \"color\",\n        \"$value\": \"#f0f\"\n      }\n    }\n  }\n}\n", got)
+	})
+
+	t.Run("new groups", func(t *testing.T) {
+		actions := actionsFor(".a { margin: var(--ds-space-small); }")
+		require.Len(t, actions, 1)
+		assert.Equal(t, "Create token 'space.small' in tokens.json", actions[0].Title)
+		edits := actions[0].Edit.Changes[uriutil.PathToURI(tokensPath)]
+		require.Len(t, edits, 1)
+		assert.Contains(t, edits[0].NewText, "\"$type\": \"dimension\",\n      \"$value\": \"0px\"")
+	})
+
+	t.Run("other prefix", func(t *testing.T) {
+		assert.Empty(t, actionsFor(".a { color: var(--local-color-accent); }"))
+	})
+}
@@ -0,0 +1,63 @@
+package codeaction
+
+import (
+	"bennypowers.dev/dtls/internal/documents"
+	"bennypowers.dev/dtls/internal/parser"
+	"bennypowers.dev/dtls/internal/uriutil"
+	"bennypowers.dev/dtls/lsp/helpers"
+	"bennypowers.dev/dtls/lsp/helpers/css"
+	"bennypowers.dev/dtls/lsp/types"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+// createStaleCustomPropertyActions creates quick fixes that update stale values
+// in a generated postcss-custom-properties file to match the token files.
+// Returns nil for documents that are not configured custom properties files.
+func createStaleCustomPropertyActions(req *types.RequestContext, doc *documents.Document, params *protocol.CodeActionParams) []protocol.CodeAction {
+	path := uriutil.URIToPath(doc.URI())
+	if !req.Server.IsCustomPropertiesFile(path) {
+		return nil
+	}
+
+	result, err := parser.ParseCSSFromDocument(doc.Content(), doc.LanguageID())
+	if err != nil || result == nil {
+		return nil
+	}
+
+	var actions []protocol.CodeAction
+	for _, v := range result.Variables {
+		valueRange := css.ToProtocolRange(v.ValueRange)
+		if !helpers.RangeHits(params.Range, valueRange) {
+			continue
+		}
+
+		expected, stale := css.StaleCustomPropertyValue(req.Server.Token(v.Name), path, css.RangeText(doc.Content(), v.ValueRange))
+		if !stale {
+			continue
+		}
+
+		kind := protocol.CodeActionKindQuickFix
+		action := protocol.CodeAction{
+			Title: req.Localize("Update %s to '%s'", v.Name, expected),
+			Kind:  &kind,
+			Edit: &protocol.WorkspaceEdit{
+				Changes: map[string][]protocol.TextEdit{
+					doc.URI(): {{Range: valueRange, NewText: expected}},
+				},
+			},
+		}
+
+		for _, diag := range params.Context.Diagnostics {
+			if diag.Range.Start == valueRange.Start {
+				action.Diagnostics = []protocol.Diagnostic{diag}
+				preferred := true
+				action.IsPreferred = &preferred
+				break
+			}
+		}
+
+		actions = append(actions, action)
+	}
+
+	return actions
+}
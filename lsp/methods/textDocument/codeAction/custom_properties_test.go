@@ -0,0 +1,73 @@
+package codeaction
+
+import (
+	"testing"
+
+	"bennypowers.dev/dtls/internal/tokens"
+	"bennypowers.dev/dtls/lsp/testutil"
+	"bennypowers.dev/dtls/lsp/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+func TestStaleCustomPropertyActions(t *testing.T) {
+	ctx := testutil.NewMockServerContext()
+	ctx.SetSupportsCodeActionLiterals(true)
+	ctx.SetRootPath("/project")
+	config := types.DefaultConfig()
+	config.CustomPropertiesFiles = []string{"dist/tokens.css"}
+	ctx.SetConfig(config)
+	req := types.NewRequestContext(ctx, nil)
+
+	_ = ctx.TokenManager().Add(&tokens.Token{
+		Name:     "color-primary",
+		Value:    "#0000ff",
+		Type:     "color",
+		FilePath: "/project/tokens.json",
+	})
+	_ = ctx.TokenManager().Add(&tokens.Token{
+		Name:     "legacy-gap",
+		Value:    "4px",
+		FilePath: "/project/dist/tokens.css",
+	})
+
+	content := ":root {\n  --color-primary: #ff0000;\n  --legacy-gap: 8px;\n}"
+	wholeFile := protocol.Range{End: protocol.Position{Line: 4}}
+
+	t.Run("updates stale values in the generated file", func(t *testing.T) {
+		uri := "file:///project/dist/tokens.css"
+		require.NoError(t, ctx.DocumentManager().DidOpen(uri, "css", 1, content))
+
+		valueRange := protocol.Range{
+			Start: protocol.Position{Line: 1, Character: 19},
+			End:   protocol.Position{Line: 1, Character: 26},
+		}
+		diag := protocol.Diagnostic{Range: valueRange, Message: "--color-primary is stale: the token value is #0000ff"}
+
+		actions := createStaleCustomPropertyActions(req, ctx.Document(uri), &protocol.CodeActionParams{
+			TextDocument: protocol.TextDocumentIdentifier{URI: uri},
+			Range:        wholeFile,
+			Context:      protocol.CodeActionContext{Diagnostics: []protocol.Diagnostic{diag}},
+		})
+
+		// --legacy-gap comes from the generated file itself, so it is never stale
+		require.Len(t, actions, 1)
+		assert.Equal(t, "Update --color-primary to '#0000ff'", actions[0].Title)
+		assert.Equal(t, []protocol.TextEdit{{Range: valueRange, NewText: "#0000ff"}}, actions[0].Edit.Changes[uri])
+		assert.Equal(t, []protocol.Diagnostic{diag}, actions[0].Diagnostics)
+		require.NotNil(t, actions[0].IsPreferred)
+		assert.True(t, *actions[0].IsPreferred)
+	})
+
+	t.Run("ignores other files", func(t *testing.T) {
+		uri := "file:///project/src/app.css"
+		require.NoError(t, ctx.DocumentManager().DidOpen(uri, "css", 1, content))
+
+		actions := createStaleCustomPropertyActions(req, ctx.Document(uri), &protocol.CodeActionParams{
+			TextDocument: protocol.TextDocumentIdentifier{URI: uri},
+			Range:        wholeFile,
+		})
+		assert.Empty(t, actions)
+	})
+}
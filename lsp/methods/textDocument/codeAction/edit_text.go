@@ -0,0 +1,120 @@
+package codeaction
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"bennypowers.dev/dtls/internal/uriutil"
+	"bennypowers.dev/dtls/lsp/helpers"
+	"bennypowers.dev/dtls/lsp/types"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+// EditTextCommand returns the text of each document a workspace edit
+// changes, after the edit, and a unified diff of the change, without
+// applying anything. It takes the WorkspaceEdit as its only argument.
+//
+// Clients which can't apply workspace edits get code actions running this
+// command in place of edits, so wrapper plugins can apply them.
+const EditTextCommand = "designTokensLanguageServer.editText"
+
+// EditedDocument is a document as a workspace edit leaves it
+type EditedDocument struct {
+	URI string `json:"uri"`
+
+	// Text is the document's content after the edit
+	Text string `json:"text"`
+
+	// Diff is a unified diff from the document's content to Text
+	Diff string `json:"diff"`
+}
+
+// supportsWorkspaceEdits reports whether the client can apply workspace
+// edits, from code actions or workspace/applyEdit. Clients which declare
+// neither capability can't, though when capabilities are unknown, clients
+// are assumed to.
+func supportsWorkspaceEdits(caps *protocol.ClientCapabilities) bool {
+	if caps == nil {
+		return true
+	}
+	workspace := caps.Workspace
+	return workspace != nil && (workspace.WorkspaceEdit != nil || (workspace.ApplyEdit != nil && *workspace.ApplyEdit))
+}
+
+// editTextActions replaces the edits of actions with EditTextCommand, for
+// clients which can't apply workspace edits. The fix-all action's edits are
+// computed now, since running a command doesn't resolve the action first.
+func editTextActions(req *types.RequestContext, actions []protocol.CodeAction) []protocol.CodeAction {
+	if supportsWorkspaceEdits(req.Server.ClientCapabilities()) {
+		return actions
+	}
+	for i := range actions {
+		action := &actions[i]
+		if action.Edit == nil && action.Kind != nil && isKindOf(CodeActionKindSourceFixAllDesignTokens, *action.Kind) {
+			resolved, _ := resolveFixAllFallbacks(req, action)
+			action.Edit = resolved.Edit
+			action.Data = nil
+		}
+		if action.Edit == nil || action.Command != nil {
+			continue
+		}
+		action.Command = &protocol.Command{
+			Title:     action.Title,
+			Command:   EditTextCommand,
+			Arguments: []any{*action.Edit},
+		}
+		action.Edit = nil
+	}
+	return actions
+}
+
+// EditedDocuments applies a workspace edit to the content of the documents it
+// changes, which are read from disk when they aren't open, ordered by URI
+func EditedDocuments(req *types.RequestContext, edit protocol.WorkspaceEdit) ([]EditedDocument, error) {
+	var documents []EditedDocument
+	for _, uri := range slices.Sorted(maps.Keys(edit.Changes)) {
+		content, err := tokenFileContent(req, uri, uriutil.URIToPath(uri))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", uri, err)
+		}
+		edits := edit.Changes[uri]
+		text, err := helpers.ApplyEdits(content, edits)
+		if err != nil {
+			return nil, fmt.Errorf("failed to edit %s: %w", uri, err)
+		}
+		documents = append(documents, EditedDocument{
+			URI:  uri,
+			Text: text,
+			Diff: UnifiedDiff(uri, content, edits),
+		})
+	}
+	return documents, nil
+}
+
+// UnifiedDiff renders edits to a document as a unified diff without context
+// lines, which patch and git apply accept
+func UnifiedDiff(uri, content string, edits []protocol.TextEdit) string {
+	hunks := editHunks(content, edits)
+	if len(hunks) == 0 {
+		return ""
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "--- %s\n+++ %s\n", uri, uri)
+	// Lines added by earlier hunks shift the later ones
+	shift := 0
+	for _, h := range hunks {
+		start := int(h.first) + 1
+		fmt.Fprintf(&b, "@@ -%d,%d +%d,%d @@\n", start, len(h.old), start+shift, len(h.updated))
+		for _, line := range h.old {
+			fmt.Fprintf(&b, "-%s\n", line)
+		}
+		for _, line := range h.updated {
+			fmt.Fprintf(&b, "+%s\n", line)
+		}
+		shift += len(h.updated) - len(h.old)
+	}
+	return b.String()
+}
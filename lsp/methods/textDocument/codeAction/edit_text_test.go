@@ -0,0 +1,114 @@
+package codeaction
+
+import (
+	"testing"
+
+	"bennypowers.dev/dtls/internal/tokens"
+	"bennypowers.dev/dtls/lsp/testutil"
+	"bennypowers.dev/dtls/lsp/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tliron/glsp"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+func TestUnifiedDiff(t *testing.T) {
+	content := ".a {\n  color: red;\n}\n.b {\n  color: blue;\n}"
+	edits := []protocol.TextEdit{
+		{
+			Range: protocol.Range{
+				Start: protocol.Position{Line: 4, Character: 9},
+				End:   protocol.Position{Line: 4, Character: 13},
+			},
+			NewText: "var(--color-blue)",
+		},
+		{
+			Range: protocol.Range{
+				Start: protocol.Position{Line: 1, Character: 2},
+				End:   protocol.Position{Line: 1, Character: 13},
+			},
+			NewText: "color: red;\n  background: white;",
+		},
+	}
+
+	assert.Equal(t, "--- file:///test.css\n+++ file:///test.css\n"+
+		"@@ -2,1 +2,2 @@\n-  color: red;\n+  color: red;\n+  background: white;\n"+
+		"@@ -5,1 +6,1 @@\n-  color: blue;\n+  color: var(--color-blue);\n",
+		UnifiedDiff("file:///test.css", content, edits))
+	assert.Empty(t, UnifiedDiff("file:///test.css", content, nil))
+}
+
+func TestCodeAction_EditTextCommands(t *testing.T) {
+	ctx := testutil.NewMockServerContext()
+	ctx.SetSupportsCodeActionLiterals(true)
+	// A client declaring no way to apply workspace edits
+	ctx.SetClientCapabilities(protocol.ClientCapabilities{})
+
+	_ = ctx.TokenManager().Add(&tokens.Token{
+		Name:  "color.primary",
+		Value: "#0000ff",
+		Type:  "color",
+	})
+
+	uri := "file:///test.css"
+	content := ".button { color: var(--color-primary, #ff0000); }\n.link { color: var(--color-primary, #00ff00); }"
+	_ = ctx.DocumentManager().DidOpen(uri, "css", 1, content)
+
+	incorrectFallback := func(line, start, end uint32) protocol.Diagnostic {
+		return protocol.Diagnostic{
+			Range: protocol.Range{
+				Start: protocol.Position{Line: line, Character: start},
+				End:   protocol.Position{Line: line, Character: end},
+			},
+			Code: &protocol.IntegerOrString{Value: "incorrect-fallback"},
+		}
+	}
+	diagnostics := []protocol.Diagnostic{incorrectFallback(0, 17, 47), incorrectFallback(1, 15, 45)}
+	req := types.NewRequestContext(ctx, &glsp.Context{})
+	result, err := CodeAction(req, &protocol.CodeActionParams{
+		TextDocument: protocol.TextDocumentIdentifier{URI: uri},
+		Range:        diagnostics[0].Range,
+		Context:      protocol.CodeActionContext{Diagnostics: diagnostics},
+	})
+	require.NoError(t, err)
+	actions, ok := result.([]protocol.CodeAction)
+	require.True(t, ok)
+	require.NotEmpty(t, actions)
+
+	byTitle := map[string]protocol.CodeAction{}
+	for _, action := range actions {
+		assert.Nil(t, action.Edit, "%s should run a command instead of editing", action.Title)
+		require.NotNil(t, action.Command, action.Title)
+		byTitle[action.Title] = action
+	}
+
+	fix, ok := byTitle["Fix fallback value to '#0000ff'"]
+	require.True(t, ok)
+	assert.Equal(t, EditTextCommand, fix.Command.Command)
+	require.Len(t, fix.Command.Arguments, 1)
+	edit, ok := fix.Command.Arguments[0].(protocol.WorkspaceEdit)
+	require.True(t, ok)
+
+	documents, err := EditedDocuments(req, edit)
+	require.NoError(t, err)
+	require.Len(t, documents, 1)
+	assert.Equal(t, uri, documents[0].URI)
+	assert.Equal(t, ".button { color: var(--color-primary, #0000ff); }\n.link { color: var(--color-primary, #00ff00); }", documents[0].Text)
+	assert.Contains(t, documents[0].Diff, "+.button { color: var(--color-primary, #0000ff); }")
+
+	// The fix-all action's edits are computed up front
+	fixAll, ok := byTitle["Fix all token fallback values"]
+	require.True(t, ok)
+	assert.Equal(t, EditTextCommand, fixAll.Command.Command)
+	edit, ok = fixAll.Command.Arguments[0].(protocol.WorkspaceEdit)
+	require.True(t, ok)
+	assert.Len(t, edit.Changes[uri], 2)
+}
+
+func TestSupportsWorkspaceEdits(t *testing.T) {
+	assert.True(t, supportsWorkspaceEdits(nil), "unknown capabilities")
+	assert.False(t, supportsWorkspaceEdits(&protocol.ClientCapabilities{}))
+
+	caps := kindCapabilities(t, `["quickfix"]`)
+	assert.True(t, supportsWorkspaceEdits(&caps), "applyEdit")
+}
@@ -0,0 +1,99 @@
+package codeaction
+
+import (
+	"fmt"
+
+	"bennypowers.dev/dtls/internal/documents"
+	cssparser "bennypowers.dev/dtls/internal/parser/css"
+	"bennypowers.dev/dtls/internal/tokens"
+	"bennypowers.dev/dtls/lsp/helpers/css"
+	"bennypowers.dev/dtls/lsp/types"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+// createFallbackChainActions creates code actions for a nested fallback chain
+// such as var(--a, var(--b, 4px)), given its outermost call:
+//   - collapse the chain to var(--a, <value of --a>)
+//   - rebuild the chain in canonical form (see canonicalFallbackChain)
+func createFallbackChainActions(req *types.RequestContext, doc *documents.Document, varCall cssparser.VarCall, token *tokens.Token) []protocol.CodeAction {
+	var actions []protocol.CodeAction
+	uri := doc.URI()
+
+	if formattedValue, err := css.FormatTokenValueForCSS(token); err != nil {
+		req.AddWarning(fmt.Errorf("cannot format token %q for fallback: %w", token.Name, err))
+	} else {
+		newText := fmt.Sprintf("var(%s, %s)", varCall.TokenName, formattedValue)
+		actions = append(actions, chainRewriteAction(uri, varCall,
+			req.Localize("Collapse fallback chain to '%s'", newText), newText))
+	}
+
+	if newText := canonicalFallbackChain(req, &varCall); newText != css.RangeText(doc.Content(), varCall.Range) {
+		actions = append(actions, chainRewriteAction(uri, varCall, req.Localize("Rebuild fallback chain in canonical form"), newText))
+	}
+
+	return actions
+}
+
+// canonicalFallbackChain rebuilds a fallback chain with repeated tokens removed,
+// normalized spacing, and a terminal literal matching the last token's value.
+// If the last token is unknown, the chain's existing terminal literal is kept.
+func canonicalFallbackChain(req *types.RequestContext, head *cssparser.VarCall) string {
+	chain := head.Chain()
+
+	var names []string
+	seen := make(map[string]bool)
+	for _, link := range chain {
+		if !seen[link.TokenName] {
+			seen[link.TokenName] = true
+			names = append(names, link.TokenName)
+		}
+	}
+
+	var terminal string
+	if last := req.Server.Token(names[len(names)-1]); last != nil {
+		if formattedValue, err := css.FormatTokenValueForCSS(last); err == nil {
+			terminal = formattedValue
+		}
+	}
+	if innermost := chain[len(chain)-1]; terminal == "" && innermost.Fallback != nil {
+		terminal = *innermost.Fallback
+	}
+
+	text := terminal
+	for i := len(names) - 1; i >= 0; i-- {
+		if text == "" {
+			text = fmt.Sprintf("var(%s)", names[i])
+		} else {
+			text = fmt.Sprintf("var(%s, %s)", names[i], text)
+		}
+	}
+	return text
+}
+
+// chainRewriteAction creates a refactor action replacing the whole chain with newText
+func chainRewriteAction(uri string, varCall cssparser.VarCall, title, newText string) protocol.CodeAction {
+	kind := protocol.CodeActionKindRefactorRewrite
+	return protocol.CodeAction{
+		Title: title,
+		Kind:  &kind,
+		Edit: &protocol.WorkspaceEdit{
+			Changes: map[string][]protocol.TextEdit{
+				uri: {
+					{
+						Range: protocol.Range{
+							Start: protocol.Position{
+								Line:      varCall.Range.Start.Line,
+								Character: varCall.Range.Start.Character,
+							},
+							End: protocol.Position{
+								Line:      varCall.Range.End.Line,
+								Character: varCall.Range.End.Character,
+							},
+						},
+						NewText: newText,
+					},
+				},
+			},
+		},
+	}
+}
@@ -0,0 +1,106 @@
+package codeaction_test
+
+import (
+	"testing"
+
+	"bennypowers.dev/dtls/internal/tokens"
+	"bennypowers.dev/dtls/lsp"
+	codeaction "bennypowers.dev/dtls/lsp/methods/textDocument/codeAction"
+	"bennypowers.dev/dtls/lsp/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+// TestFallbackChainActions tests collapsing and rebuilding nested fallback chains
+func TestFallbackChainActions(t *testing.T) {
+	s, err := lsp.NewServer()
+	require.NoError(t, err)
+	setCodeActionLiteralSupport(s)
+
+	_ = s.TokenManager().Add(&tokens.Token{Name: "space-large", Value: "16px", Type: "dimension"})
+	_ = s.TokenManager().Add(&tokens.Token{Name: "space-small", Value: "4px", Type: "dimension"})
+
+	const (
+		collapse = "Collapse fallback chain to 'var(--space-large, 16px)'"
+		rebuild  = "Rebuild fallback chain in canonical form"
+	)
+
+	tests := []struct {
+		name    string
+		css     string
+		actions map[string]string // title -> edit; empty edit means the action must be absent
+	}{
+		{
+			name: "canonical chain only collapses",
+			css:  `.a { padding: var(--space-large, var(--space-small, 4px)); }`,
+			actions: map[string]string{
+				collapse: "var(--space-large, 16px)",
+				rebuild:  "",
+			},
+		},
+		{
+			name: "fixes terminal literal and spacing",
+			css:  `.a { padding: var(--space-large,var(--space-small,   16px)); }`,
+			actions: map[string]string{
+				collapse: "var(--space-large, 16px)",
+				rebuild:  "var(--space-large, var(--space-small, 4px))",
+			},
+		},
+		{
+			name: "removes repeated tokens",
+			css:  `.a { padding: var(--space-large, var(--space-small, var(--space-small, 4px))); }`,
+			actions: map[string]string{
+				rebuild: "var(--space-large, var(--space-small, 4px))",
+			},
+		},
+		{
+			name: "keeps terminal literal of unknown last token",
+			css:  `.a { padding: var(--space-large, var(--local-space, 2px)); }`,
+			actions: map[string]string{
+				rebuild: "",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			uri := "file:///chain.css"
+			require.NoError(t, s.DocumentManager().DidOpen(uri, "css", 1, tt.css))
+			defer func() { _ = s.DocumentManager().DidClose(uri) }()
+
+			result, err := codeaction.CodeAction(types.NewRequestContext(s, nil), &protocol.CodeActionParams{
+				TextDocument: protocol.TextDocumentIdentifier{URI: uri},
+				Range: protocol.Range{
+					Start: protocol.Position{Line: 0, Character: 20},
+					End:   protocol.Position{Line: 0, Character: 20},
+				},
+			})
+			require.NoError(t, err)
+			actions, _ := result.([]protocol.CodeAction)
+
+			for title, edit := range tt.actions {
+				var found *protocol.CodeAction
+				for i := range actions {
+					if actions[i].Title == title {
+						found = &actions[i]
+					}
+				}
+				if edit == "" {
+					assert.Nil(t, found, "unexpected action %q", title)
+					continue
+				}
+				require.NotNil(t, found, "missing action %q", title)
+				assert.Equal(t, protocol.CodeActionKindRefactorRewrite, *found.Kind)
+				edits := found.Edit.Changes[uri]
+				require.Len(t, edits, 1)
+				assert.Equal(t, edit, edits[0].NewText)
+			}
+
+			for _, action := range actions {
+				assert.NotContains(t, action.Title, "Fix fallback value to '16px'",
+					"the chain head's fallback is a var() call, not a literal to fix")
+			}
+		})
+	}
+}
@@ -0,0 +1,296 @@
+package codeaction
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/json"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"bennypowers.dev/dtls/internal/documents"
+	"bennypowers.dev/dtls/lsp/helpers"
+	"bennypowers.dev/dtls/lsp/types"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+// scaleValuePattern matches numeric values with an optional unit, e.g. 4px or 1.5
+var scaleValuePattern = regexp.MustCompile(`^(-?(?:\d+\.?\d*|\.\d+))([a-zA-Z%]*)$`)
+
+// tokenGroup is a group object in a JSON token file
+type tokenGroup struct {
+	path    []string
+	object  jsonObject
+	objects map[string]jsonObject
+}
+
+// children returns the group's tokens and subgroups, skipping $-properties
+func (g tokenGroup) children() []jsonMember {
+	var children []jsonMember
+	for _, member := range g.object.members {
+		if !strings.HasPrefix(member.key, "$") {
+			children = append(children, member)
+		}
+	}
+	return children
+}
+
+// childPath returns the path of one of the group's children
+func (g tokenGroup) childPath(child jsonMember) []string {
+	return append(slices.Clone(g.path), child.key)
+}
+
+// title returns an action title for the group: topLevel for the document's
+// top level, or nested formatted with the group's dotted path
+func (g tokenGroup) title(req *types.RequestContext, topLevel, nested string) string {
+	if len(g.path) == 0 {
+		return req.Localize(topLevel)
+	}
+	return req.Localize(nested, strings.Join(g.path, "."))
+}
+
+// createGroupActions creates refactors for the token group under the cursor
+// in a JSON token file: sorting its children, and merging tokens whose value
+// duplicates an earlier sibling's.
+func createGroupActions(req *types.RequestContext, doc *documents.Document, params *protocol.CodeActionParams) []protocol.CodeAction {
+	if languageID := doc.LanguageID(); languageID != "json" && languageID != "jsonc" {
+		return nil
+	}
+	content := doc.Content()
+	group, ok := groupAt(content, helpers.PositionToOffset(content, params.Range.Start))
+	if !ok {
+		return nil
+	}
+
+	var actions []protocol.CodeAction
+	if edits := sortGroupEdits(content, group, compareKeys); len(edits) > 0 {
+		actions = append(actions, refactorAction(group.title(req, "Sort top-level tokens alphabetically", "Sort group '%s' alphabetically"), doc.URI(), edits))
+	}
+	if values, ok := scaleValues(content, group); ok {
+		byValue := func(a, b jsonMember) int {
+			return cmp.Or(cmp.Compare(values[a.key], values[b.key]), compareKeys(a, b))
+		}
+		if edits := sortGroupEdits(content, group, byValue); len(edits) > 0 {
+			actions = append(actions, refactorAction(group.title(req, "Sort top-level tokens by value", "Sort group '%s' by value"), doc.URI(), edits))
+		}
+	}
+	return append(actions, mergeDuplicateActions(req, content, doc.URI(), group)...)
+}
+
+func refactorAction(title, uri string, edits []protocol.TextEdit) protocol.CodeAction {
+	kind := protocol.CodeActionKindRefactorRewrite
+	return protocol.CodeAction{
+		Title: title,
+		Kind:  &kind,
+		Edit: &protocol.WorkspaceEdit{
+			Changes: map[string][]protocol.TextEdit{uri: edits},
+		},
+	}
+}
+
+// groupAt returns the innermost group whose key or body contains offset.
+// Objects with a $value are tokens, so the cursor in a token selects its group.
+func groupAt(content string, offset int) (tokenGroup, bool) {
+	objects, err := scanJSONObjects(content)
+	if err != nil {
+		return tokenGroup{}, false
+	}
+
+	var found *tokenGroup
+	for key, object := range objects {
+		var path []string
+		if key != "" {
+			path = strings.Split(key, jsonPathSep)
+		}
+		if slices.ContainsFunc(object.members, func(m jsonMember) bool { return m.key == "$value" }) {
+			continue
+		}
+
+		start := object.open
+		if len(path) > 0 {
+			parent, ok := objects[strings.Join(path[:len(path)-1], jsonPathSep)]
+			if !ok {
+				// Objects in arrays are values, not groups
+				continue
+			}
+			i := slices.IndexFunc(parent.members, func(m jsonMember) bool { return m.key == path[len(path)-1] })
+			if i < 0 {
+				continue
+			}
+			start = parent.members[i].start
+		}
+		if offset < start || offset > object.close {
+			continue
+		}
+		if found == nil || len(path) > len(found.path) {
+			found = &tokenGroup{path: path, object: object, objects: objects}
+		}
+	}
+	if found == nil {
+		return tokenGroup{}, false
+	}
+	return *found, true
+}
+
+// sortGroupEdits reorders a group's children. Each child moves as a whole
+// into the slot of the child it replaces, so $-properties, separators and
+// comments between members stay where they are, and children already in
+// place are not edited.
+func sortGroupEdits(content string, group tokenGroup, compare func(a, b jsonMember) int) []protocol.TextEdit {
+	children := group.children()
+	sorted := slices.Clone(children)
+	slices.SortStableFunc(sorted, compare)
+
+	var edits []protocol.TextEdit
+	for i, child := range children {
+		if sorted[i].start == child.start {
+			continue
+		}
+		edits = append(edits, protocol.TextEdit{
+			Range: protocol.Range{
+				Start: helpers.OffsetToPosition(content, child.start),
+				End:   helpers.OffsetToPosition(content, child.end),
+			},
+			NewText: content[sorted[i].start:sorted[i].end],
+		})
+	}
+	return edits
+}
+
+// compareKeys orders keys alphabetically, numeric keys first and by number,
+// so that scales like 50, 100, 200 keep their order
+func compareKeys(a, b jsonMember) int {
+	x, xErr := strconv.ParseFloat(a.key, 64)
+	y, yErr := strconv.ParseFloat(b.key, 64)
+	switch {
+	case xErr == nil && yErr == nil:
+		return cmp.Or(cmp.Compare(x, y), cmp.Compare(a.key, b.key))
+	case xErr == nil:
+		return -1
+	case yErr == nil:
+		return 1
+	}
+	return cmp.Compare(a.key, b.key)
+}
+
+// scaleValues returns the numeric value of each child when the group is a
+// scale: at least two tokens whose values are numbers sharing one unit
+func scaleValues(content string, group tokenGroup) (map[string]float64, bool) {
+	children := group.children()
+	if len(children) < 2 {
+		return nil, false
+	}
+
+	values := map[string]float64{}
+	scaleUnit := ""
+	for i, child := range children {
+		value, ok := tokenValue(content, group, child)
+		if !ok {
+			return nil, false
+		}
+		n, unit, ok := scaleValue(value)
+		if !ok || i > 0 && unit != scaleUnit {
+			return nil, false
+		}
+		values[child.key], scaleUnit = n, unit
+	}
+	return values, true
+}
+
+// scaleValue parses a number, a numeric string like "4px", or a DTCG
+// dimension object like {"value": 4, "unit": "px"}
+func scaleValue(raw []byte) (float64, string, bool) {
+	var value any
+	if err := json.Unmarshal(raw, &value); err != nil {
+		return 0, "", false
+	}
+	switch v := value.(type) {
+	case float64:
+		return v, "", true
+	case string:
+		match := scaleValuePattern.FindStringSubmatch(strings.TrimSpace(v))
+		if match == nil {
+			return 0, "", false
+		}
+		n, err := strconv.ParseFloat(match[1], 64)
+		return n, match[2], err == nil
+	case map[string]any:
+		n, ok := v["value"].(float64)
+		unit, _ := v["unit"].(string)
+		return n, unit, ok
+	}
+	return 0, "", false
+}
+
+// tokenValue returns the raw $value of a child token, or false for groups
+func tokenValue(content string, group tokenGroup, child jsonMember) ([]byte, bool) {
+	object, ok := group.objects[strings.Join(group.childPath(child), jsonPathSep)]
+	if !ok {
+		return nil, false
+	}
+	for _, member := range object.members {
+		if member.key == "$value" {
+			return []byte(content[member.valueStart:member.end]), true
+		}
+	}
+	return nil, false
+}
+
+// tokenType returns the $type declared on a child token, if any
+func tokenType(content string, group tokenGroup, child jsonMember) string {
+	object := group.objects[strings.Join(group.childPath(child), jsonPathSep)]
+	for _, member := range object.members {
+		if member.key == "$type" {
+			var t string
+			_ = json.Unmarshal([]byte(content[member.valueStart:member.end]), &t)
+			return t
+		}
+	}
+	return ""
+}
+
+// mergeDuplicateActions offers to merge each token whose type and value
+// duplicate an earlier sibling's. Merging turns the duplicate into an alias
+// of the original, so its uses keep resolving to the same value.
+func mergeDuplicateActions(req *types.RequestContext, content, uri string, group tokenGroup) []protocol.CodeAction {
+	// originals maps each type and value to the first token with them
+	originals := map[string][]string{}
+
+	var actions []protocol.CodeAction
+	for _, child := range group.children() {
+		raw, ok := tokenValue(content, group, child)
+		if !ok {
+			continue
+		}
+		var compact bytes.Buffer
+		if err := json.Compact(&compact, raw); err != nil {
+			continue
+		}
+		key := tokenType(content, group, child) + "\x00" + compact.String()
+		path := group.childPath(child)
+
+		first, ok := originals[key]
+		if !ok {
+			originals[key] = path
+			continue
+		}
+
+		object := group.objects[strings.Join(path, jsonPathSep)]
+		i := slices.IndexFunc(object.members, func(m jsonMember) bool { return m.key == "$value" })
+		member := object.members[i]
+		alias, _ := json.Marshal("{" + strings.Join(first, ".") + "}")
+		actions = append(actions, refactorAction(
+			req.Localize("Merge duplicate '%s' into '%s'", strings.Join(path, "."), strings.Join(first, ".")),
+			uri,
+			[]protocol.TextEdit{{
+				Range: protocol.Range{
+					Start: helpers.OffsetToPosition(content, member.valueStart),
+					End:   helpers.OffsetToPosition(content, member.end),
+				},
+				NewText: string(alias),
+			}},
+		))
+	}
+	return actions
+}
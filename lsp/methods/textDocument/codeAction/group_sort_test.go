@@ -0,0 +1,131 @@
+package codeaction
+
+import (
+	"testing"
+
+	"bennypowers.dev/dtls/lsp/helpers"
+	"bennypowers.dev/dtls/lsp/testutil"
+	"bennypowers.dev/dtls/lsp/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+const groupTokensJSON = `{
+  "space": {
+    "$type": "dimension",
+    "lg": { "$value": "16px" },
+    "sm": { "$value": "4px" },
+    "md": { "$value": "8px" },
+    "gutter": { "$value": "8px" }
+  },
+  "color": {
+    "red": { "$type": "color", "$value": "#f00" },
+    "blue": { "$type": "color", "$value": "#00f" },
+    // brand colors
+    "crimson": { "$type": "color", "$value": "#f00" }
+  }
+}`
+
+func groupActionsAt(t *testing.T, content string, pos protocol.Position) map[string]protocol.CodeAction {
+	t.Helper()
+	ctx := testutil.NewMockServerContext()
+	ctx.SetSupportsCodeActionLiterals(true)
+	req := types.NewRequestContext(ctx, nil)
+
+	uri := "file:///tokens.json"
+	require.NoError(t, ctx.DocumentManager().DidOpen(uri, "jsonc", 1, content))
+
+	result, err := CodeAction(req, &protocol.CodeActionParams{
+		TextDocument: protocol.TextDocumentIdentifier{URI: uri},
+		Range:        protocol.Range{Start: pos, End: pos},
+	})
+	require.NoError(t, err)
+	actions, _ := result.([]protocol.CodeAction)
+
+	byTitle := map[string]protocol.CodeAction{}
+	for _, action := range actions {
+		byTitle[action.Title] = action
+	}
+	return byTitle
+}
+
+func applyGroupAction(t *testing.T, content string, action protocol.CodeAction) string {
+	t.Helper()
+	got, err := helpers.ApplyEdits(content, action.Edit.Changes["file:///tokens.json"])
+	require.NoError(t, err)
+	return got
+}
+
+func TestGroupActions_SortAlphabetically(t *testing.T) {
+	// On the group's key
+	actions := groupActionsAt(t, groupTokensJSON, protocol.Position{Line: 8, Character: 4})
+	action, ok := actions["Sort group 'color' alphabetically"]
+	require.True(t, ok, "actions: %v", actions)
+
+	// Edits only the slots whose member changes
+	assert.Len(t, action.Edit.Changes["file:///tokens.json"], 3)
+	assert.Equal(t, `{
+  "space": {
+    "$type": "dimension",
+    "lg": { "$value": "16px" },
+    "sm": { "$value": "4px" },
+    "md": { "$value": "8px" },
+    "gutter": { "$value": "8px" }
+  },
+  "color": {
+    "blue": { "$type": "color", "$value": "#00f" },
+    "crimson": { "$type": "color", "$value": "#f00" },
+    // brand colors
+    "red": { "$type": "color", "$value": "#f00" }
+  }
+}`, applyGroupAction(t, groupTokensJSON, action))
+
+	// Not a scale
+	assert.NotContains(t, actions, "Sort group 'color' by value")
+}
+
+func TestGroupActions_SortByValue(t *testing.T) {
+	// Inside a token selects its group
+	actions := groupActionsAt(t, groupTokensJSON, protocol.Position{Line: 3, Character: 12})
+	action, ok := actions["Sort group 'space' by value"]
+	require.True(t, ok, "actions: %v", actions)
+
+	assert.Equal(t, `{
+  "space": {
+    "$type": "dimension",
+    "sm": { "$value": "4px" },
+    "gutter": { "$value": "8px" },
+    "md": { "$value": "8px" },
+    "lg": { "$value": "16px" }
+  },
+  "color": {
+    "red": { "$type": "color", "$value": "#f00" },
+    "blue": { "$type": "color", "$value": "#00f" },
+    // brand colors
+    "crimson": { "$type": "color", "$value": "#f00" }
+  }
+}`, applyGroupAction(t, groupTokensJSON, action))
+}
+
+func TestGroupActions_MergeDuplicates(t *testing.T) {
+	actions := groupActionsAt(t, groupTokensJSON, protocol.Position{Line: 8, Character: 4})
+	action, ok := actions["Merge duplicate 'color.crimson' into 'color.red'"]
+	require.True(t, ok, "actions: %v", actions)
+	assert.Contains(t, applyGroupAction(t, groupTokensJSON, action), `"crimson": { "$type": "color", "$value": "{color.red}" }`)
+
+	actions = groupActionsAt(t, groupTokensJSON, protocol.Position{Line: 2, Character: 4})
+	assert.Contains(t, actions, "Merge duplicate 'space.gutter' into 'space.md'")
+}
+
+func TestGroupActions_SortedGroup(t *testing.T) {
+	content := `{ "space": { "50": { "$value": 2 }, "100": { "$value": 4 }, "a": { "$value": 8 } } }`
+	actions := groupActionsAt(t, content, protocol.Position{Line: 0, Character: 5})
+	assert.Empty(t, actions, "numeric keys sort by number, before names")
+}
+
+func TestCompareKeys(t *testing.T) {
+	assert.Negative(t, compareKeys(jsonMember{key: "50"}, jsonMember{key: "100"}))
+	assert.Negative(t, compareKeys(jsonMember{key: "100"}, jsonMember{key: "base"}))
+	assert.Positive(t, compareKeys(jsonMember{key: "md"}, jsonMember{key: "lg"}))
+}
@@ -120,6 +120,98 @@ func TestCodeAction_AddFallback(t *testing.T) {
 	assert.Contains(t, edits[0].NewText, "var(--color-primary, #0000ff)")
 }
 
+func TestCodeAction_AddFallback_AliasChain(t *testing.T) {
+	ctx := testutil.NewMockServerContext()
+	ctx.SetSupportsCodeActionLiterals(true)
+	req := types.NewRequestContext(ctx, &glsp.Context{})
+
+	for _, token := range []*tokens.Token{
+		{Name: "color-primary", Path: []string{"color", "primary"}, Value: "#0000ff", RawValue: "#0000ff", Type: "color"},
+		{Name: "color-brand", Path: []string{"color", "brand"}, Value: "#0000ff", RawValue: "{color.primary}", Type: "color"},
+		{Name: "color-accent", Path: []string{"color", "accent"}, Value: "#0000ff", RawValue: "{color.brand}", Type: "color"},
+		{Name: "loop-a", Path: []string{"loop", "a"}, Value: "{loop.b}", RawValue: "{loop.b}", Type: "color"},
+		{Name: "loop-b", Path: []string{"loop", "b"}, Value: "{loop.a}", RawValue: "{loop.a}", Type: "color"},
+	} {
+		require.NoError(t, ctx.TokenManager().Add(token))
+	}
+
+	titles := func(t *testing.T, css string) []string {
+		t.Helper()
+		uri := "file:///alias.css"
+		_ = ctx.DocumentManager().DidClose(uri)
+		require.NoError(t, ctx.DocumentManager().DidOpen(uri, "css", 1, css))
+		result, err := CodeAction(req, &protocol.CodeActionParams{
+			TextDocument: protocol.TextDocumentIdentifier{URI: uri},
+			Range: protocol.Range{
+				Start: protocol.Position{Line: 0, Character: 17},
+				End:   protocol.Position{Line: 0, Character: 17},
+			},
+		})
+		require.NoError(t, err)
+		actions, _ := result.([]protocol.CodeAction)
+		var titles []string
+		for _, action := range actions {
+			titles = append(titles, action.Title)
+		}
+		return titles
+	}
+
+	t.Run("names the tokens an alias goes through", func(t *testing.T) {
+		assert.Contains(t, titles(t, `.button { color: var(--color-accent); }`),
+			"Add fallback value '#0000ff' (via color.brand → color.primary)")
+	})
+
+	t.Run("offers no fallback for circular aliases", func(t *testing.T) {
+		for _, title := range titles(t, `.button { color: var(--loop-a); }`) {
+			assert.NotContains(t, title, "Add fallback value")
+		}
+	})
+}
+
+func TestCodeAction_FixFallback_FontFamilyArray(t *testing.T) {
+	ctx := testutil.NewMockServerContext()
+	ctx.SetSupportsCodeActionLiterals(true)
+	req := types.NewRequestContext(ctx, &glsp.Context{})
+
+	_ = ctx.TokenManager().Add(&tokens.Token{
+		Name:     "font.body",
+		Value:    `["Helvetica Neue","Arial","sans-serif"]`,
+		RawValue: []any{"Helvetica Neue", "Arial", "sans-serif"},
+		Type:     "fontFamily",
+	})
+
+	uri := "file:///test.css"
+	_ = ctx.DocumentManager().DidOpen(uri, "css", 1, `.button { font-family: var(--font-body, Arial); }`)
+
+	result, err := CodeAction(req, &protocol.CodeActionParams{
+		TextDocument: protocol.TextDocumentIdentifier{URI: uri},
+		Range: protocol.Range{
+			Start: protocol.Position{Line: 0, Character: 23},
+			End:   protocol.Position{Line: 0, Character: 46},
+		},
+		Context: protocol.CodeActionContext{
+			Diagnostics: []protocol.Diagnostic{},
+		},
+	})
+	require.NoError(t, err)
+	actions, ok := result.([]protocol.CodeAction)
+	require.True(t, ok)
+
+	// The array is written as a CSS font-family list, not as JSON
+	var fixAction *protocol.CodeAction
+	for i := range actions {
+		if actions[i].Title == `Fix fallback value to '"Helvetica Neue", Arial, sans-serif'` {
+			fixAction = &actions[i]
+			break
+		}
+	}
+	require.NotNil(t, fixAction, "Should offer the font family list as the fallback")
+	require.NotNil(t, fixAction.Edit)
+	edits := fixAction.Edit.Changes[uri]
+	require.Len(t, edits, 1)
+	assert.Contains(t, edits[0].NewText, `var(--font-body, "Helvetica Neue", Arial, sans-serif)`)
+}
+
 func TestCodeAction_NonCSSDocument(t *testing.T) {
 	ctx := testutil.NewMockServerContext()
 	glspCtx := &glsp.Context{}
@@ -219,3 +311,150 @@ func ptrCodeActionKind(kind protocol.CodeActionKind) *protocol.CodeActionKind {
 	return &kind
 }
 
+
+// TestCodeAction_CursorBoundaries tests that a bare cursor hits a var() call
+// under the same half-open rule as hover and definition
+func TestCodeAction_CursorBoundaries(t *testing.T) {
+	ctx := testutil.NewMockServerContext()
+	ctx.SetSupportsCodeActionLiterals(true)
+	req := types.NewRequestContext(ctx, &glsp.Context{})
+
+	_ = ctx.TokenManager().Add(&tokens.Token{Name: "color.primary", Value: "#0000ff", Type: "color"})
+
+	uri := "file:///test.css"
+	// var() spans characters [17, 37)
+	_ = ctx.DocumentManager().DidOpen(uri, "css", 1, `.button { color: var(--color-primary); }`)
+
+	actionsAt := func(char uint32) []protocol.CodeAction {
+		cursor := protocol.Position{Line: 0, Character: char}
+		result, err := CodeAction(req, &protocol.CodeActionParams{
+			TextDocument: protocol.TextDocumentIdentifier{URI: uri},
+			Range:        protocol.Range{Start: cursor, End: cursor},
+		})
+		require.NoError(t, err)
+		actions, _ := result.([]protocol.CodeAction)
+		return actions
+	}
+
+	assert.Empty(t, actionsAt(16), "before var()")
+	assert.NotEmpty(t, actionsAt(17), "at the start of var()")
+	assert.NotEmpty(t, actionsAt(36), "on the closing paren")
+	assert.Empty(t, actionsAt(37), "just past the closing paren")
+}
+
+func TestCodeAction_AddCompositeFallback(t *testing.T) {
+	addFallback := func(t *testing.T, enabled bool) (*protocol.CodeAction, *types.RequestContext) {
+		t.Helper()
+		ctx := testutil.NewMockServerContext()
+		ctx.SetSupportsCodeActionLiterals(true)
+		ctx.SetConfig(types.ServerConfig{CompositeFallbacks: enabled})
+		req := types.NewRequestContext(ctx, &glsp.Context{})
+
+		_ = ctx.TokenManager().Add(&tokens.Token{
+			Name: "type.body",
+			Type: "typography",
+			RawValue: map[string]any{
+				"fontFamily":    "serif",
+				"fontSize":      "16px",
+				"letterSpacing": "0.1px",
+			},
+		})
+
+		uri := "file:///test.css"
+		_ = ctx.DocumentManager().DidOpen(uri, "css", 1, `.body { font: var(--type-body); }`)
+
+		result, err := CodeAction(req, &protocol.CodeActionParams{
+			TextDocument: protocol.TextDocumentIdentifier{URI: uri},
+			Range: protocol.Range{
+				Start: protocol.Position{Line: 0, Character: 14},
+				End:   protocol.Position{Line: 0, Character: 31},
+			},
+		})
+		require.NoError(t, err)
+		actions, _ := result.([]protocol.CodeAction)
+		for i := range actions {
+			if actions[i].Title == "Add fallback value '16px serif'" {
+				return &actions[i], req
+			}
+		}
+		return nil, req
+	}
+
+	t.Run("disabled by default", func(t *testing.T) {
+		action, _ := addFallback(t, false)
+		assert.Nil(t, action)
+	})
+
+	t.Run("expands the value and warns about what it leaves out", func(t *testing.T) {
+		action, req := addFallback(t, true)
+		require.NotNil(t, action)
+		edits := action.Edit.Changes["file:///test.css"]
+		require.Len(t, edits, 1)
+		assert.Equal(t, "var(--type-body, 16px serif)", edits[0].NewText)
+
+		require.True(t, req.HasWarnings())
+		assert.Contains(t, req.Warnings()[0].Error(), "letterSpacing")
+	})
+
+	t.Run("uses the configured serializer", func(t *testing.T) {
+		ctx := testutil.NewMockServerContext()
+		ctx.SetSupportsCodeActionLiterals(true)
+		ctx.SetConfig(types.ServerConfig{
+			CompositeFallbacks:   true,
+			CompositeSerializers: map[string]string{"strokeStyle": "{{.dashArray}} {{.lineCap}}"},
+		})
+		req := types.NewRequestContext(ctx, &glsp.Context{})
+
+		_ = ctx.TokenManager().Add(&tokens.Token{
+			Name:     "stroke.dashed",
+			Type:     "strokeStyle",
+			RawValue: map[string]any{"dashArray": []any{"4px", "2px"}, "lineCap": "round"},
+		})
+
+		uri := "file:///test.css"
+		_ = ctx.DocumentManager().DidOpen(uri, "css", 1, `.a { --dash: var(--stroke-dashed); }`)
+
+		result, err := CodeAction(req, &protocol.CodeActionParams{
+			TextDocument: protocol.TextDocumentIdentifier{URI: uri},
+			Range: protocol.Range{
+				Start: protocol.Position{Line: 0, Character: 14},
+				End:   protocol.Position{Line: 0, Character: 34},
+			},
+		})
+		require.NoError(t, err)
+		actions, _ := result.([]protocol.CodeAction)
+		var titles []string
+		for _, action := range actions {
+			titles = append(titles, action.Title)
+		}
+		assert.Contains(t, titles, "Add fallback value '4px, 2px round'")
+	})
+}
+
+func TestCodeAction_LocalizedTitles(t *testing.T) {
+	ctx := testutil.NewMockServerContext()
+	ctx.SetSupportsCodeActionLiterals(true)
+	ctx.SetLocale("de-DE")
+	req := types.NewRequestContext(ctx, &glsp.Context{})
+
+	_ = ctx.TokenManager().Add(&tokens.Token{Name: "color.primary", Value: "#0000ff", Type: "color"})
+
+	uri := "file:///test.css"
+	_ = ctx.DocumentManager().DidOpen(uri, "css", 1, `.a { color: var(--color-primary); }`)
+
+	result, err := CodeAction(req, &protocol.CodeActionParams{
+		TextDocument: protocol.TextDocumentIdentifier{URI: uri},
+		Range: protocol.Range{
+			Start: protocol.Position{Line: 0, Character: 12},
+			End:   protocol.Position{Line: 0, Character: 33},
+		},
+	})
+	require.NoError(t, err)
+	actions, _ := result.([]protocol.CodeAction)
+
+	var titles []string
+	for _, action := range actions {
+		titles = append(titles, action.Title)
+	}
+	assert.Contains(t, titles, "Fallback-Wert '#0000ff' hinzufügen")
+}
@@ -0,0 +1,203 @@
+package codeaction
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// jsonPathSep joins the keys of a path in scanJSONObjects results
+const jsonPathSep = "\x1f"
+
+// jsonObject locates an object in a JSON document by byte offset
+type jsonObject struct {
+	open  int // the opening brace
+	close int // the closing brace
+
+	// firstKey is the start of the first member's key, or -1 when empty
+	firstKey int
+
+	// lastMemberEnd is the end of the last member's value, or -1 when empty
+	lastMemberEnd int
+
+	// members are the object's members in document order
+	members []jsonMember
+}
+
+// jsonMember locates a member of a JSON object by byte offset
+type jsonMember struct {
+	key        string
+	start      int // the opening quote of the key
+	valueStart int
+	end        int // the end of the value
+}
+
+// scanJSONObjects maps the key path of each object in a JSON document,
+// joined with jsonPathSep, to its location. The root object has the empty
+// path. Comments are skipped, so JSONC token files work too.
+func scanJSONObjects(content string) (map[string]jsonObject, error) {
+	s := &jsonScanner{src: content, objects: map[string]jsonObject{}}
+	s.skipSpace()
+	if err := s.value(nil); err != nil {
+		return nil, err
+	}
+	return s.objects, nil
+}
+
+type jsonScanner struct {
+	src     string
+	pos     int
+	objects map[string]jsonObject
+}
+
+func (s *jsonScanner) errorf(format string, args ...any) error {
+	return fmt.Errorf("invalid JSON at offset %d: %s", s.pos, fmt.Sprintf(format, args...))
+}
+
+// skipSpace skips whitespace and comments
+func (s *jsonScanner) skipSpace() {
+	for s.pos < len(s.src) {
+		switch {
+		case strings.ContainsRune(" \t\r\n", rune(s.src[s.pos])):
+			s.pos++
+		case strings.HasPrefix(s.src[s.pos:], "//"):
+			end := strings.IndexByte(s.src[s.pos:], '\n')
+			if end < 0 {
+				s.pos = len(s.src)
+			} else {
+				s.pos += end
+			}
+		case strings.HasPrefix(s.src[s.pos:], "/*"):
+			end := strings.Index(s.src[s.pos+2:], "*/")
+			if end < 0 {
+				s.pos = len(s.src)
+			} else {
+				s.pos += end + 4
+			}
+		default:
+			return
+		}
+	}
+}
+
+func (s *jsonScanner) value(path []string) error {
+	if s.pos >= len(s.src) {
+		return s.errorf("unexpected end of input")
+	}
+	switch s.src[s.pos] {
+	case '{':
+		return s.object(path)
+	case '[':
+		return s.array(path)
+	case '"':
+		_, err := s.string()
+		return err
+	default:
+		start := s.pos
+		for s.pos < len(s.src) && !strings.ContainsRune(",}] \t\r\n/", rune(s.src[s.pos])) {
+			s.pos++
+		}
+		if s.pos == start {
+			return s.errorf("unexpected %q", s.src[s.pos])
+		}
+		return nil
+	}
+}
+
+func (s *jsonScanner) object(path []string) error {
+	obj := jsonObject{open: s.pos, firstKey: -1, lastMemberEnd: -1}
+	s.pos++
+	for {
+		s.skipSpace()
+		if s.pos >= len(s.src) {
+			return s.errorf("unterminated object")
+		}
+		if s.src[s.pos] == '}' {
+			obj.close = s.pos
+			s.pos++
+			s.objects[strings.Join(path, jsonPathSep)] = obj
+			return nil
+		}
+		if obj.firstKey >= 0 {
+			if s.src[s.pos] != ',' {
+				return s.errorf("expected , or }")
+			}
+			s.pos++
+			s.skipSpace()
+			// Tolerate a trailing comma
+			if s.pos < len(s.src) && s.src[s.pos] == '}' {
+				continue
+			}
+		}
+
+		keyStart := s.pos
+		key, err := s.string()
+		if err != nil {
+			return err
+		}
+		s.skipSpace()
+		if s.pos >= len(s.src) || s.src[s.pos] != ':' {
+			return s.errorf("expected :")
+		}
+		s.pos++
+		s.skipSpace()
+		valueStart := s.pos
+		if err := s.value(append(path[:len(path):len(path)], key)); err != nil {
+			return err
+		}
+		if obj.firstKey < 0 {
+			obj.firstKey = keyStart
+		}
+		obj.lastMemberEnd = s.pos
+		obj.members = append(obj.members, jsonMember{key: key, start: keyStart, valueStart: valueStart, end: s.pos})
+	}
+}
+
+func (s *jsonScanner) array(path []string) error {
+	s.pos++
+	for i := 0; ; i++ {
+		s.skipSpace()
+		if s.pos >= len(s.src) {
+			return s.errorf("unterminated array")
+		}
+		if s.src[s.pos] == ']' {
+			s.pos++
+			return nil
+		}
+		if i > 0 {
+			if s.src[s.pos] != ',' {
+				return s.errorf("expected , or ]")
+			}
+			s.pos++
+			s.skipSpace()
+			if s.pos < len(s.src) && s.src[s.pos] == ']' {
+				continue
+			}
+		}
+		if err := s.value(append(path[:len(path):len(path)], fmt.Sprint(i))); err != nil {
+			return err
+		}
+	}
+}
+
+// string scans a string literal and returns its decoded value
+func (s *jsonScanner) string() (string, error) {
+	if s.pos >= len(s.src) || s.src[s.pos] != '"' {
+		return "", s.errorf("expected string")
+	}
+	start := s.pos
+	for s.pos++; s.pos < len(s.src); s.pos++ {
+		switch s.src[s.pos] {
+		case '\\':
+			s.pos++
+		case '"':
+			s.pos++
+			var decoded string
+			if err := json.Unmarshal([]byte(s.src[start:s.pos]), &decoded); err != nil {
+				return "", s.errorf("%v", err)
+			}
+			return decoded, nil
+		}
+	}
+	return "", s.errorf("unterminated string")
+}
@@ -0,0 +1,84 @@
+package codeaction
+
+import (
+	"slices"
+	"strings"
+
+	"bennypowers.dev/dtls/lsp/types"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+// CodeActionKindSourceFixAllDesignTokens is the kind of the action that
+// fixes every token fallback in a document. As a source.fixAll kind, editors
+// can run it on save with other fix-all actions.
+const CodeActionKindSourceFixAllDesignTokens protocol.CodeActionKind = "source.fixAll.designTokens"
+
+// Kinds are the kinds of the code actions the server returns
+var Kinds = []protocol.CodeActionKind{
+	protocol.CodeActionKindQuickFix,
+	protocol.CodeActionKindRefactorRewrite,
+	protocol.CodeActionKindSource,
+	CodeActionKindSourceFixAllDesignTokens,
+}
+
+// AdvertisedKinds returns the kinds to declare in initialize: the kinds the
+// server returns, as the client will receive them
+func AdvertisedKinds(caps *protocol.ClientCapabilities) []protocol.CodeActionKind {
+	valueSet := kindValueSet(caps)
+	var kinds []protocol.CodeActionKind
+	for _, kind := range Kinds {
+		if kind = clientKind(kind, valueSet); !slices.Contains(kinds, kind) {
+			kinds = append(kinds, kind)
+		}
+	}
+	return kinds
+}
+
+// kindValueSet returns the code action kinds a client lists as supported,
+// or nil when it lists none
+func kindValueSet(caps *protocol.ClientCapabilities) []protocol.CodeActionKind {
+	if caps == nil || caps.TextDocument == nil || caps.TextDocument.CodeAction == nil ||
+		caps.TextDocument.CodeAction.CodeActionLiteralSupport == nil {
+		return nil
+	}
+	return caps.TextDocument.CodeAction.CodeActionLiteralSupport.CodeActionKind.ValueSet
+}
+
+// clientKind returns the kind to send a client for kind. Some clients
+// compare kinds to the ones they list exactly, so a kind they don't list is
+// sent as its closest listed parent, e.g. "source.fixAll" for
+// "source.fixAll.designTokens". A kind with no listed parent is sent as is,
+// since clients listing kinds must handle others gracefully.
+func clientKind(kind protocol.CodeActionKind, valueSet []protocol.CodeActionKind) protocol.CodeActionKind {
+	if len(valueSet) == 0 || slices.Contains(valueSet, kind) {
+		return kind
+	}
+	parent := string(kind)
+	for {
+		i := strings.LastIndex(parent, ".")
+		if i < 0 {
+			return kind
+		}
+		parent = parent[:i]
+		if slices.Contains(valueSet, protocol.CodeActionKind(parent)) {
+			return protocol.CodeActionKind(parent)
+		}
+	}
+}
+
+// applyClientKinds sets the kind of each action to one the client supports
+func applyClientKinds(req *types.RequestContext, actions []protocol.CodeAction) []protocol.CodeAction {
+	valueSet := kindValueSet(req.Server.ClientCapabilities())
+	for i := range actions {
+		if actions[i].Kind != nil {
+			kind := clientKind(*actions[i].Kind, valueSet)
+			actions[i].Kind = &kind
+		}
+	}
+	return actions
+}
+
+// isKindOf reports whether kind is parent or one of its sub-kinds
+func isKindOf(kind, parent protocol.CodeActionKind) bool {
+	return kind == parent || strings.HasPrefix(string(kind), string(parent)+".")
+}
@@ -0,0 +1,110 @@
+package codeaction
+
+import (
+	"encoding/json"
+	"testing"
+
+	"bennypowers.dev/dtls/internal/tokens"
+	"bennypowers.dev/dtls/lsp/testutil"
+	"bennypowers.dev/dtls/lsp/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tliron/glsp"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+// kindCapabilities returns capabilities of a client which applies workspace
+// edits and lists the given code action kinds
+func kindCapabilities(t *testing.T, valueSet string) protocol.ClientCapabilities {
+	t.Helper()
+	var caps protocol.ClientCapabilities
+	require.NoError(t, json.Unmarshal([]byte(`{"workspace": {"applyEdit": true}, "textDocument": {"codeAction": {"codeActionLiteralSupport": {"codeActionKind": {"valueSet": `+valueSet+`}}}}}`), &caps))
+	return caps
+}
+
+func TestClientKind(t *testing.T) {
+	tests := []struct {
+		name     string
+		kind     protocol.CodeActionKind
+		valueSet []protocol.CodeActionKind
+		expected protocol.CodeActionKind
+	}{
+		{name: "no value set", kind: CodeActionKindSourceFixAllDesignTokens, expected: CodeActionKindSourceFixAllDesignTokens},
+		{name: "listed", kind: "refactor.rewrite", valueSet: []protocol.CodeActionKind{"quickfix", "refactor.rewrite"}, expected: "refactor.rewrite"},
+		{name: "closest parent", kind: CodeActionKindSourceFixAllDesignTokens, valueSet: []protocol.CodeActionKind{"source", "source.fixAll"}, expected: "source.fixAll"},
+		{name: "top-level parent", kind: "refactor.rewrite", valueSet: []protocol.CodeActionKind{"quickfix", "refactor"}, expected: "refactor"},
+		{name: "no listed parent", kind: CodeActionKindSourceFixAllDesignTokens, valueSet: []protocol.CodeActionKind{"quickfix"}, expected: CodeActionKindSourceFixAllDesignTokens},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, clientKind(tt.kind, tt.valueSet))
+		})
+	}
+}
+
+func TestAdvertisedKinds(t *testing.T) {
+	t.Run("without a value set", func(t *testing.T) {
+		assert.Equal(t, Kinds, AdvertisedKinds(&protocol.ClientCapabilities{}))
+		assert.Equal(t, Kinds, AdvertisedKinds(nil))
+	})
+
+	t.Run("as the client will receive them", func(t *testing.T) {
+		caps := kindCapabilities(t, `["quickfix", "refactor", "source"]`)
+		assert.Equal(t, []protocol.CodeActionKind{"quickfix", "refactor", "source"}, AdvertisedKinds(&caps))
+	})
+}
+
+func TestCodeAction_ClientKinds(t *testing.T) {
+	ctx := testutil.NewMockServerContext()
+	ctx.SetClientCapabilities(kindCapabilities(t, `["quickfix", "refactor", "source.fixAll"]`))
+	req := types.NewRequestContext(ctx, &glsp.Context{})
+
+	_ = ctx.TokenManager().Add(&tokens.Token{
+		Name:  "color.primary",
+		Value: "#0000ff",
+		Type:  "color",
+	})
+
+	uri := "file:///test.css"
+	_ = ctx.DocumentManager().DidOpen(uri, "css", 1, ".button { color: var(--color-primary, #ff0000); }\n"+
+		".link { color: var(--color-primary, #00ff00); }")
+
+	incorrectFallback := func(line, start, end uint32) protocol.Diagnostic {
+		return protocol.Diagnostic{
+			Range: protocol.Range{
+				Start: protocol.Position{Line: line, Character: start},
+				End:   protocol.Position{Line: line, Character: end},
+			},
+			Code: &protocol.IntegerOrString{Value: "incorrect-fallback"},
+		}
+	}
+	diagnostics := []protocol.Diagnostic{incorrectFallback(0, 17, 47), incorrectFallback(1, 15, 45)}
+	result, err := CodeAction(req, &protocol.CodeActionParams{
+		TextDocument: protocol.TextDocumentIdentifier{URI: uri},
+		Range:        diagnostics[0].Range,
+		Context:      protocol.CodeActionContext{Diagnostics: diagnostics},
+	})
+	require.NoError(t, err)
+	actions, ok := result.([]protocol.CodeAction)
+	require.True(t, ok)
+
+	kinds := map[protocol.CodeActionKind]*protocol.CodeAction{}
+	for i := range actions {
+		require.NotNil(t, actions[i].Kind)
+		kinds[*actions[i].Kind] = &actions[i]
+	}
+	assert.Contains(t, kinds, protocol.CodeActionKindQuickFix)
+	assert.Contains(t, kinds, protocol.CodeActionKindRefactor, "refactor.rewrite is sent as refactor")
+	assert.NotContains(t, kinds, protocol.CodeActionKindRefactorRewrite)
+
+	fixAll := kinds["source.fixAll"]
+	require.NotNil(t, fixAll, "source.fixAll.designTokens is sent as source.fixAll")
+
+	// The fix-all action resolves under the kind the client received
+	req = types.NewRequestContext(ctx, &glsp.Context{})
+	resolved, err := CodeActionResolve(req, fixAll)
+	require.NoError(t, err)
+	require.NotNil(t, resolved.Edit)
+	assert.NotEmpty(t, resolved.Edit.Changes[uri])
+}
@@ -0,0 +1,120 @@
+package codeaction
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+
+	cssparser "bennypowers.dev/dtls/internal/parser/css"
+	"bennypowers.dev/dtls/internal/tokens"
+	"bennypowers.dev/dtls/internal/uriutil"
+	"bennypowers.dev/dtls/lsp/helpers/css"
+	"bennypowers.dev/dtls/lsp/types"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+// selectorNamePattern matches the leading class, id, or element name of a selector
+var selectorNamePattern = regexp.MustCompile(`^[.#]?([a-zA-Z][\w-]*)`)
+
+// componentNamePattern matches file names which can name a component
+var componentNamePattern = regexp.MustCompile(`^[a-zA-Z][\w-]*$`)
+
+// createLocalOverrideAction creates a refactor that wraps a token reference in the
+// local-override pattern used for component theming:
+//
+//	var(--token) -> var(--_component-token, var(--token, <value>))
+//
+// The private variable name is derived from the rule's selector, or from the
+// file name for :host rules. Returns nil for calls that already use a private
+// variable or are part of a fallback chain, and for documents which aren't
+// files or whose component can't be named.
+func createLocalOverrideAction(req *types.RequestContext, uri string, varCall cssparser.VarCall, token *tokens.Token) *protocol.CodeAction {
+	if varCall.Nested || varCall.FallbackVar != nil || strings.HasPrefix(varCall.TokenName, "--_") {
+		return nil
+	}
+	if !uriutil.IsFileURI(uri) {
+		return nil
+	}
+	component := componentName(uri, varCall.Selector)
+	if component == "" {
+		return nil
+	}
+
+	formattedValue, err := css.FormatTokenValueForCSS(token)
+	if err != nil {
+		req.AddWarning(fmt.Errorf("cannot format token %q for fallback: %w", token.Name, err))
+		return nil
+	}
+
+	localName := localOverrideName(component, varCall, token)
+	newText := fmt.Sprintf("var(%s, var(%s, %s))", localName, varCall.TokenName, formattedValue)
+
+	kind := protocol.CodeActionKindRefactorRewrite
+	action := protocol.CodeAction{
+		Title: req.Localize("Wrap in local override '%s'", localName),
+		Kind:  &kind,
+		Edit: &protocol.WorkspaceEdit{
+			Changes: map[string][]protocol.TextEdit{
+				uri: {
+					{
+						Range: protocol.Range{
+							Start: protocol.Position{
+								Line:      varCall.Range.Start.Line,
+								Character: varCall.Range.Start.Character,
+							},
+							End: protocol.Position{
+								Line:      varCall.Range.End.Line,
+								Character: varCall.Range.End.Character,
+							},
+						},
+						NewText: newText,
+					},
+				},
+			},
+		},
+	}
+	return &action
+}
+
+// localOverrideName builds the private variable name "--_<component>-<token>",
+// with the token prefix removed from both parts
+// (e.g. "--_button-color-text" for --rh-color-text in rh-button.css)
+func localOverrideName(component string, varCall cssparser.VarCall, token *tokens.Token) string {
+	tokenPart := strings.TrimPrefix(varCall.TokenName, "--")
+
+	if token.Prefix != "" {
+		prefix := strings.ReplaceAll(token.Prefix, ".", "-") + "-"
+		tokenPart = strings.TrimPrefix(tokenPart, prefix)
+		component = strings.TrimPrefix(component, prefix)
+	}
+
+	if component == "" {
+		return "--_" + tokenPart
+	}
+	return "--_" + component + "-" + tokenPart
+}
+
+// componentName derives a component name from the first selector in a selector
+// list, falling back to the file name for :host and unnamed selectors.
+// It returns "" when neither is a valid name.
+func componentName(uri, selector string) string {
+	first, _, _ := strings.Cut(selector, ",")
+	first = strings.TrimSpace(first)
+
+	if !strings.HasPrefix(first, ":host") {
+		if match := selectorNamePattern.FindStringSubmatch(first); match != nil {
+			return strings.ToLower(match[1])
+		}
+	}
+
+	base := path.Base(uri)
+	base = strings.TrimSuffix(base, path.Ext(base))
+	// Lit components often keep styles in rh-button.css.ts or rh-button-styles.css
+	base = strings.TrimSuffix(base, ".css")
+	base = strings.TrimSuffix(base, "-styles")
+	if !componentNamePattern.MatchString(base) {
+		return ""
+	}
+	return strings.ToLower(base)
+}
@@ -0,0 +1,135 @@
+package codeaction
+
+import (
+	"testing"
+
+	cssparser "bennypowers.dev/dtls/internal/parser/css"
+	"bennypowers.dev/dtls/internal/tokens"
+	"bennypowers.dev/dtls/lsp/testutil"
+	"bennypowers.dev/dtls/lsp/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+func TestComponentName(t *testing.T) {
+	tests := []struct {
+		uri      string
+		selector string
+		want     string
+	}{
+		{"file:///src/rh-button.css", ":host", "rh-button"},
+		{"file:///src/rh-button.css", ":host([variant=danger])", "rh-button"},
+		{"file:///src/rh-card.css.ts", ":host", "rh-card"},
+		{"file:///src/rh-tabs-styles.css", "", "rh-tabs"},
+		{"file:///src/app.css", ".card > .header:hover, .other", "card"},
+		{"file:///src/app.css", "#Main", "main"},
+		{"file:///src/app.css", "rh-badge", "rh-badge"},
+		{"file:///src/app.css", "[data-theme]", "app"},
+		{"file:///src/my%20app.css", ":host", ""},
+		{"file:///src/2024.css", ":host", ""},
+		{"untitled:Untitled-1", ":host", ""},
+		{"untitled:Untitled-1", ".card", "card"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.uri+" "+tt.selector, func(t *testing.T) {
+			assert.Equal(t, tt.want, componentName(tt.uri, tt.selector))
+		})
+	}
+}
+
+func TestLocalOverrideAction(t *testing.T) {
+	ctx := testutil.NewMockServerContext()
+	ctx.SetSupportsCodeActionLiterals(true)
+	req := types.NewRequestContext(ctx, nil)
+
+	_ = ctx.TokenManager().Add(&tokens.Token{
+		Name:   "color-text-primary",
+		Prefix: "rh",
+		Value:  "#151515",
+		Type:   "color",
+	})
+
+	tests := []struct {
+		name  string
+		uri   string
+		css   string
+		title string
+		edit  string
+	}{
+		{
+			name:  "host rule uses the file name",
+			uri:   "file:///elements/rh-button/rh-button.css",
+			css:   `:host { color: var(--rh-color-text-primary); }`,
+			title: "Wrap in local override '--_button-color-text-primary'",
+			edit:  "var(--_button-color-text-primary, var(--rh-color-text-primary, #151515))",
+		},
+		{
+			name:  "class selector names the component",
+			uri:   "file:///app.css",
+			css:   `.card { color: var(--rh-color-text-primary, red); }`,
+			title: "Wrap in local override '--_card-color-text-primary'",
+			edit:  "var(--_card-color-text-primary, var(--rh-color-text-primary, #151515))",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.NoError(t, ctx.DocumentManager().DidOpen(tt.uri, "css", 1, tt.css))
+			defer func() { _ = ctx.DocumentManager().DidClose(tt.uri) }()
+
+			result, err := CodeAction(req, &protocol.CodeActionParams{
+				TextDocument: protocol.TextDocumentIdentifier{URI: tt.uri},
+				Range: protocol.Range{
+					Start: protocol.Position{Line: 0, Character: 20},
+					End:   protocol.Position{Line: 0, Character: 20},
+				},
+			})
+			require.NoError(t, err)
+
+			var found *protocol.CodeAction
+			for _, action := range result.([]protocol.CodeAction) {
+				if action.Title == tt.title {
+					found = &action
+				}
+			}
+			require.NotNil(t, found, "missing action %q", tt.title)
+			assert.Equal(t, protocol.CodeActionKindRefactorRewrite, *found.Kind)
+			edits := found.Edit.Changes[tt.uri]
+			require.Len(t, edits, 1)
+			assert.Equal(t, tt.edit, edits[0].NewText)
+		})
+	}
+}
+
+func TestLocalOverrideAction_SkipsPrivateVariables(t *testing.T) {
+	ctx := testutil.NewMockServerContext()
+	req := types.NewRequestContext(ctx, nil)
+	token := &tokens.Token{Name: "_button-color", Value: "red", Type: "color"}
+
+	action := createLocalOverrideAction(req, "file:///a.css", cssparser.VarCall{TokenName: "--_button-color"}, token)
+	assert.Nil(t, action)
+}
+
+func TestLocalOverrideAction_RequiresComponentName(t *testing.T) {
+	ctx := testutil.NewMockServerContext()
+	req := types.NewRequestContext(ctx, nil)
+	token := &tokens.Token{Name: "color-text", Value: "red", Type: "color"}
+
+	tests := []struct {
+		name     string
+		uri      string
+		selector string
+	}{
+		{"untitled document", "untitled:Untitled-1", ".card"},
+		{"file name is not a valid name", "file:///src/my%20app.css", ":host"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			varCall := cssparser.VarCall{TokenName: "--color-text", Selector: tt.selector}
+			assert.Nil(t, createLocalOverrideAction(req, tt.uri, varCall, token))
+		})
+	}
+}
@@ -0,0 +1,104 @@
+package codeaction
+
+import (
+	"slices"
+	"strings"
+
+	cssparser "bennypowers.dev/dtls/internal/parser/css"
+	"bennypowers.dev/dtls/internal/tokens"
+	"bennypowers.dev/dtls/lsp/types"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+// PickReplacementCommand asks the user to choose among several tokens that
+// could replace a deprecated one, then replaces the var() call with the
+// chosen token. It takes a PickReplacementArgs argument.
+const PickReplacementCommand = "designTokensLanguageServer.pickReplacement"
+
+// PickReplacementArgs is the argument of PickReplacementCommand
+type PickReplacementArgs struct {
+	URI string `json:"uri"`
+
+	// Token is the deprecated token's CSS variable name
+	Token string `json:"token"`
+
+	// Range is the var() call's range
+	Range protocol.Range `json:"range"`
+
+	Choices []ReplacementChoice `json:"choices"`
+}
+
+// ReplacementChoice is a token which can replace a deprecated one
+type ReplacementChoice struct {
+	// Name is the token's CSS variable name
+	Name string `json:"name"`
+
+	// NewText replaces the var() call
+	NewText string `json:"newText"`
+}
+
+// replacementCandidates returns the tokens which can replace a deprecated
+// token: the one its deprecation message recommends, else the tokens that
+// are not deprecated and share its type and value
+func replacementCandidates(req *types.RequestContext, token *tokens.Token) []*tokens.Token {
+	if replacement := deprecatedReplacement(req, token); replacement != nil {
+		return []*tokens.Token{replacement}
+	}
+
+	var candidates []*tokens.Token
+	for _, t := range req.Server.TokenManager().GetAll() {
+		if t != token && !t.Deprecated && t.Type == token.Type && t.Value == token.Value {
+			candidates = append(candidates, t)
+		}
+	}
+	slices.SortFunc(candidates, func(a, b *tokens.Token) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	return candidates
+}
+
+// createPickReplacementAction creates a command code action which asks the
+// user to choose among several replacement tokens. Clients which can't apply
+// a workspace edit from the server, and read-only mode, where the command is
+// disabled, get one replacement action per candidate.
+func createPickReplacementAction(req *types.RequestContext, uri string, varCall cssparser.VarCall, token *tokens.Token, candidates []*tokens.Token, matchingDiag *protocol.Diagnostic) []protocol.CodeAction {
+	var replacements []protocol.CodeAction
+	args := PickReplacementArgs{
+		URI:   uri,
+		Token: req.Server.TokenManager().CSSVariableName(token),
+		Range: protocol.Range{
+			Start: protocol.Position{Line: varCall.Range.Start.Line, Character: varCall.Range.Start.Character},
+			End:   protocol.Position{Line: varCall.Range.End.Line, Character: varCall.Range.End.Character},
+		},
+	}
+	for _, candidate := range candidates {
+		cssVarName := req.Server.TokenManager().CSSVariableName(candidate)
+		action := createReplacementAction(req, uri, varCall, cssVarName, candidate, matchingDiag)
+		if action == nil {
+			continue
+		}
+		// None of them is preferred over the others
+		action.IsPreferred = nil
+		replacements = append(replacements, *action)
+		args.Choices = append(args.Choices, ReplacementChoice{Name: cssVarName, NewText: action.Edit.Changes[uri][0].NewText})
+	}
+	if len(args.Choices) < 2 || req.Config().ReadOnly || !req.Server.SupportsApplyEdit() {
+		return replacements
+	}
+
+	kind := protocol.CodeActionKindQuickFix
+	title := req.Localize("Replace with one of %d tokens with the same value...", len(args.Choices))
+	action := protocol.CodeAction{
+		Title: title,
+		Kind:  &kind,
+		Command: &protocol.Command{
+			Title:     title,
+			Command:   PickReplacementCommand,
+			Arguments: []any{args},
+		},
+	}
+	if matchingDiag != nil {
+		action.Diagnostics = []protocol.Diagnostic{*matchingDiag}
+	}
+	return []protocol.CodeAction{action}
+}
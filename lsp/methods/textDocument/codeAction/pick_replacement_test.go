@@ -0,0 +1,92 @@
+package codeaction
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"bennypowers.dev/dtls/internal/tokens"
+	"bennypowers.dev/dtls/lsp/testutil"
+	"bennypowers.dev/dtls/lsp/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+func TestCodeAction_PickReplacement(t *testing.T) {
+	setup := func(t *testing.T, applyEdit, trusted bool) []protocol.CodeAction {
+		t.Helper()
+		ctx := testutil.NewMockServerContext()
+		ctx.SetWorkspaceTrusted(trusted)
+		ctx.SetSupportsCodeActionLiterals(true)
+		// The workspace capabilities are an anonymous struct
+		var caps protocol.ClientCapabilities
+		require.NoError(t, json.Unmarshal(fmt.Appendf(nil, `{"workspace": {"applyEdit": %t}}`, applyEdit), &caps))
+		ctx.SetClientCapabilities(caps)
+		req := types.NewRequestContext(ctx, nil)
+
+		for _, token := range []*tokens.Token{
+			{Name: "color.old", Value: "#0000ff", Type: "color", Deprecated: true},
+			{Name: "color.primary", Value: "#0000ff", Type: "color"},
+			{Name: "color.brand", Value: "#0000ff", Type: "color"},
+			{Name: "color.other", Value: "#ff0000", Type: "color"},
+			{Name: "color.retired", Value: "#0000ff", Type: "color", Deprecated: true},
+		} {
+			require.NoError(t, ctx.TokenManager().Add(token))
+		}
+
+		uri := "file:///test.css"
+		require.NoError(t, ctx.DocumentManager().DidOpen(uri, "css", 1, `.a { color: var(--color-old); }`))
+
+		result, err := CodeAction(req, &protocol.CodeActionParams{
+			TextDocument: protocol.TextDocumentIdentifier{URI: uri},
+			Range: protocol.Range{
+				Start: protocol.Position{Line: 0, Character: 14},
+				End:   protocol.Position{Line: 0, Character: 14},
+			},
+		})
+		require.NoError(t, err)
+		actions, ok := result.([]protocol.CodeAction)
+		require.True(t, ok)
+		return actions
+	}
+
+	titles := func(actions []protocol.CodeAction) []string {
+		var titles []string
+		for _, action := range actions {
+			titles = append(titles, action.Title)
+		}
+		return titles
+	}
+
+	t.Run("asks the client to choose", func(t *testing.T) {
+		actions := setup(t, true, true)
+		require.Contains(t, titles(actions), "Replace with one of 2 tokens with the same value...")
+
+		pick := actions[0]
+		require.NotNil(t, pick.Command)
+		assert.Nil(t, pick.Edit)
+		assert.Equal(t, PickReplacementCommand, pick.Command.Command)
+		require.Len(t, pick.Command.Arguments, 1)
+		args, ok := pick.Command.Arguments[0].(PickReplacementArgs)
+		require.True(t, ok)
+		assert.Equal(t, "--color-old", args.Token)
+		assert.Equal(t, []ReplacementChoice{
+			{Name: "--color-brand", NewText: "var(--color-brand)"},
+			{Name: "--color-primary", NewText: "var(--color-primary)"},
+		}, args.Choices)
+		assert.Equal(t, protocol.Position{Line: 0, Character: 12}, args.Range.Start)
+	})
+
+	t.Run("lists each candidate without workspace/applyEdit", func(t *testing.T) {
+		actions := setup(t, false, true)
+		assert.Subset(t, titles(actions), []string{"Replace with '--color-brand'", "Replace with '--color-primary'"})
+		assert.NotContains(t, titles(actions), "Replace with one of 2 tokens with the same value...")
+	})
+
+	t.Run("lists each candidate in read-only mode", func(t *testing.T) {
+		actions := setup(t, true, false)
+		assert.Subset(t, titles(actions), []string{"Replace with '--color-brand'", "Replace with '--color-primary'"})
+		assert.NotContains(t, titles(actions), "Replace with one of 2 tokens with the same value...")
+	})
+}
@@ -0,0 +1,233 @@
+package codeaction
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"bennypowers.dev/dtls/internal/documents"
+	"bennypowers.dev/dtls/internal/parser"
+	cssparser "bennypowers.dev/dtls/internal/parser/css"
+	"bennypowers.dev/dtls/internal/position"
+	"bennypowers.dev/dtls/lsp/types"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+// Dry-run commands preview a bulk edit as a markdown diff instead of applying it.
+// Each takes the document URI as its only argument, or no arguments to
+// preview the edit across the workspace.
+const (
+	PreviewFixAllFallbacksCommand     = "designTokensLanguageServer.previewFixAllFallbacks"
+	PreviewDeprecatedMigrationCommand = "designTokensLanguageServer.previewDeprecatedMigration"
+)
+
+// PreviewCommands lists the dry-run commands, with the titles used in their previews
+var PreviewCommands = map[string]string{
+	PreviewFixAllFallbacksCommand:     "Fix all token fallback values",
+	PreviewDeprecatedMigrationCommand: "Migrate deprecated tokens",
+}
+
+// PreviewFile is a document and the edits a dry-run command would make to it
+type PreviewFile struct {
+	URI     string
+	Content string
+	Edits   []protocol.TextEdit
+}
+
+// PreviewEdits computes the edits a dry-run command would make to a
+// document, which may be open or read from disk
+func PreviewEdits(req *types.RequestContext, command string, doc *documents.Document) ([]protocol.TextEdit, error) {
+	switch command {
+	case PreviewFixAllFallbacksCommand:
+		return fixAllFallbacksEdits(req, doc)
+	case PreviewDeprecatedMigrationCommand:
+		return deprecatedMigrationEdits(req, doc)
+	default:
+		return nil, fmt.Errorf("unknown preview command: %s", command)
+	}
+}
+
+// DeprecatedMigrationEdits computes the edits that replace every deprecated
+// token with its recommended replacement, as the individual "Replace with"
+// quick fixes would. Tokens without a known replacement are left alone.
+func DeprecatedMigrationEdits(req *types.RequestContext, uri string) ([]protocol.TextEdit, error) {
+	doc := req.Server.Document(uri)
+	if doc == nil {
+		return nil, nil
+	}
+	return deprecatedMigrationEdits(req, doc)
+}
+
+// deprecatedMigrationEdits computes the edits that migrate the deprecated
+// tokens of a document, which may be open or read from disk
+func deprecatedMigrationEdits(req *types.RequestContext, doc *documents.Document) ([]protocol.TextEdit, error) {
+	uri := doc.URI()
+	result, err := parser.ParseCSSFromDocument(doc.Content(), doc.LanguageID())
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s (%s): %w", uri, doc.LanguageID(), err)
+	}
+	if result == nil {
+		return nil, nil
+	}
+
+	edits := []protocol.TextEdit{}
+	for _, varCall := range result.VarCalls {
+		// Nested calls are rewritten along with their enclosing call's text,
+		// and names in JS/TS strings have no var() call to rewrite
+		if varCall.Alias != "" || varCall.Nested || varCall.Type == cssparser.PropertyNameReference {
+			continue
+		}
+		token := req.Server.Token(varCall.TokenName)
+		if token == nil || !token.Deprecated {
+			continue
+		}
+		replacementToken := deprecatedReplacement(req, token)
+		if replacementToken == nil {
+			continue
+		}
+		cssVarName := req.Server.TokenManager().CSSVariableName(replacementToken)
+		if action := createReplacementAction(req, uri, *varCall, cssVarName, replacementToken, nil); action != nil {
+			edits = append(edits, action.Edit.Changes[uri]...)
+		}
+	}
+
+	return resolveEditConflicts(req, edits), nil
+}
+
+// createPreviewAction creates a command code action that runs a dry-run command
+func createPreviewAction(req *types.RequestContext, command, uri string) protocol.CodeAction {
+	kind := protocol.CodeActionKindSource
+	title := req.Localize("Preview: %s", req.Localize(PreviewCommands[command]))
+	return protocol.CodeAction{
+		Title: title,
+		Kind:  &kind,
+		Command: &protocol.Command{
+			Title:     title,
+			Command:   command,
+			Arguments: []any{uri},
+		},
+	}
+}
+
+// createWorkspacePreviewAction creates a command code action that runs a
+// dry-run command across the workspace
+func createWorkspacePreviewAction(req *types.RequestContext, command string) protocol.CodeAction {
+	kind := protocol.CodeActionKindSource
+	title := req.Localize("Preview: %s in the workspace", req.Localize(PreviewCommands[command]))
+	return protocol.CodeAction{
+		Title: title,
+		Kind:  &kind,
+		Command: &protocol.Command{
+			Title:   title,
+			Command: command,
+		},
+	}
+}
+
+// countDeprecatedDiagnostics counts the diagnostics tagged as deprecated
+func countDeprecatedDiagnostics(diagnostics []protocol.Diagnostic) int {
+	count := 0
+	for _, diag := range diagnostics {
+		if slices.Contains(diag.Tags, protocol.DiagnosticTagDeprecated) {
+			count++
+		}
+	}
+	return count
+}
+
+// PreviewMarkdown renders edits to documents as a markdown diff, one
+// section per document. Edits are grouped into hunks of the lines they touch.
+func PreviewMarkdown(title string, files []PreviewFile) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Preview: %s\n\n", title)
+	if len(files) == 0 {
+		b.WriteString("No edits\n")
+	}
+
+	for i, file := range files {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		fmt.Fprintf(&b, "`%s`: %d %s\n", file.URI, len(file.Edits), pluralEdits(len(file.Edits)))
+		for _, h := range editHunks(file.Content, file.Edits) {
+			fmt.Fprintf(&b, "\n```diff\n@@ line %d @@\n", h.first+1)
+			for _, line := range h.old {
+				fmt.Fprintf(&b, "-%s\n", line)
+			}
+			for _, line := range h.updated {
+				fmt.Fprintf(&b, "+%s\n", line)
+			}
+			b.WriteString("```\n")
+		}
+	}
+
+	return b.String()
+}
+
+// hunk is the lines of a document touched by overlapping edits, before and
+// after the edits
+type hunk struct {
+	// first is the index of the hunk's first line
+	first   uint32
+	old     []string
+	updated []string
+}
+
+// editHunks groups edits whose lines overlap into hunks, in document order
+func editHunks(content string, edits []protocol.TextEdit) []hunk {
+	sorted := slices.Clone(edits)
+	slices.SortFunc(sorted, func(a, b protocol.TextEdit) int {
+		if a.Range.Start.Line != b.Range.Start.Line {
+			return int(a.Range.Start.Line) - int(b.Range.Start.Line)
+		}
+		return int(a.Range.Start.Character) - int(b.Range.Start.Character)
+	})
+
+	var hunks []hunk
+	lines := strings.Split(content, "\n")
+	for len(sorted) > 0 {
+		// Collect the edits whose lines overlap into one hunk
+		first, last := sorted[0].Range.Start.Line, sorted[0].Range.End.Line
+		n := 1
+		for n < len(sorted) && sorted[n].Range.Start.Line <= last {
+			last = max(last, sorted[n].Range.End.Line)
+			n++
+		}
+		if int(last) >= len(lines) {
+			break
+		}
+
+		old := lines[first : last+1]
+		updated := applyHunk(strings.Join(old, "\n"), first, sorted[:n])
+		hunks = append(hunks, hunk{first: first, old: old, updated: strings.Split(updated, "\n")})
+
+		sorted = sorted[n:]
+	}
+	return hunks
+}
+
+// applyHunk applies sorted, non-overlapping edits to the text of the lines
+// starting at firstLine, working backwards so earlier offsets stay valid
+func applyHunk(text string, firstLine uint32, edits []protocol.TextEdit) string {
+	offset := func(pos protocol.Position) int {
+		lines := strings.Split(text, "\n")
+		at := 0
+		for i := uint32(0); i < pos.Line-firstLine; i++ {
+			at += len(lines[i]) + 1
+		}
+		return at + position.UTF16ToByteOffset(lines[pos.Line-firstLine], int(pos.Character))
+	}
+
+	for i := len(edits) - 1; i >= 0; i-- {
+		start, end := offset(edits[i].Range.Start), offset(edits[i].Range.End)
+		text = text[:start] + edits[i].NewText + text[end:]
+	}
+	return text
+}
+
+func pluralEdits(n int) string {
+	if n == 1 {
+		return "edit"
+	}
+	return "edits"
+}
@@ -0,0 +1,141 @@
+package codeaction
+
+import (
+	"testing"
+
+	"bennypowers.dev/dtls/internal/tokens"
+	"bennypowers.dev/dtls/lsp/testutil"
+	"bennypowers.dev/dtls/lsp/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+func TestPreviewMarkdown(t *testing.T) {
+	content := ".a {\n  color: var(--x, red);\n  gap: var(--y, 1px) var(--y, 1px);\n}"
+	edit := func(line, start, end uint32, text string) protocol.TextEdit {
+		return protocol.TextEdit{
+			Range: protocol.Range{
+				Start: protocol.Position{Line: line, Character: start},
+				End:   protocol.Position{Line: line, Character: end},
+			},
+			NewText: text,
+		}
+	}
+
+	t.Run("groups edits on the same line into one hunk", func(t *testing.T) {
+		edits := []protocol.TextEdit{
+			edit(2, 21, 34, "var(--y, 2px)"),
+			edit(1, 9, 22, "var(--x, blue)"),
+			edit(2, 7, 20, "var(--y, 2px)"),
+		}
+
+		got := PreviewMarkdown("Fix all token fallback values", []PreviewFile{{URI: "file:///a.css", Content: content, Edits: edits}})
+
+		assert.Equal(t, "# Preview: Fix all token fallback values\n\n"+
+			"`file:///a.css`: 3 edits\n"+
+			"\n```diff\n@@ line 2 @@\n"+
+			"-  color: var(--x, red);\n"+
+			"+  color: var(--x, blue);\n"+
+			"```\n"+
+			"\n```diff\n@@ line 3 @@\n"+
+			"-  gap: var(--y, 1px) var(--y, 1px);\n"+
+			"+  gap: var(--y, 2px) var(--y, 2px);\n"+
+			"```\n", got)
+	})
+
+	t.Run("reports when there is nothing to change", func(t *testing.T) {
+		got := PreviewMarkdown("Migrate deprecated tokens", []PreviewFile{{URI: "file:///a.css", Content: content}})
+		assert.Equal(t, "# Preview: Migrate deprecated tokens\n\n`file:///a.css`: 0 edits\n", got)
+
+		got = PreviewMarkdown("Migrate deprecated tokens", nil)
+		assert.Equal(t, "# Preview: Migrate deprecated tokens\n\nNo edits\n", got)
+	})
+
+	t.Run("lists the edits of each document", func(t *testing.T) {
+		got := PreviewMarkdown("Fix all token fallback values", []PreviewFile{
+			{URI: "file:///a.css", Content: content, Edits: []protocol.TextEdit{edit(1, 9, 22, "var(--x, blue)")}},
+			{URI: "file:///b.css", Content: content, Edits: []protocol.TextEdit{edit(2, 7, 20, "var(--y, 2px)")}},
+		})
+		assert.Equal(t, "# Preview: Fix all token fallback values\n\n"+
+			"`file:///a.css`: 1 edit\n"+
+			"\n```diff\n@@ line 2 @@\n-  color: var(--x, red);\n+  color: var(--x, blue);\n```\n"+
+			"\n`file:///b.css`: 1 edit\n"+
+			"\n```diff\n@@ line 3 @@\n-  gap: var(--y, 1px) var(--y, 1px);\n+  gap: var(--y, 2px) var(--y, 1px);\n```\n", got)
+	})
+}
+
+func TestDeprecatedMigrationEdits(t *testing.T) {
+	ctx := testutil.NewMockServerContext()
+	req := types.NewRequestContext(ctx, nil)
+
+	_ = ctx.TokenManager().Add(&tokens.Token{
+		Name:               "color-old",
+		Value:              "#ff0000",
+		Deprecated:         true,
+		DeprecationMessage: "Use color.new instead",
+	})
+	_ = ctx.TokenManager().Add(&tokens.Token{
+		Name:               "color-gone",
+		Value:              "#000000",
+		Deprecated:         true,
+		DeprecationMessage: "No longer supported",
+	})
+	_ = ctx.TokenManager().Add(&tokens.Token{Name: "color-new", Value: "#0000ff"})
+
+	uri := "file:///test.css"
+	content := ".a {\n  color: var(--color-old);\n  fill: var(--color-gone);\n  stroke: var(--color-old);\n}"
+	require.NoError(t, ctx.DocumentManager().DidOpen(uri, "css", 1, content))
+
+	edits, err := DeprecatedMigrationEdits(req, uri)
+	require.NoError(t, err)
+
+	// --color-gone has no recommended replacement, so only --color-old migrates
+	require.Len(t, edits, 2)
+	for _, e := range edits {
+		assert.Equal(t, "var(--color-new)", e.NewText)
+	}
+	assert.Equal(t, uint32(1), edits[0].Range.Start.Line)
+	assert.Equal(t, uint32(3), edits[1].Range.Start.Line)
+
+	t.Run("unknown document", func(t *testing.T) {
+		edits, err := DeprecatedMigrationEdits(req, "file:///missing.css")
+		require.NoError(t, err)
+		assert.Nil(t, edits)
+	})
+}
+
+func TestCodeAction_PreviewActions(t *testing.T) {
+	ctx := testutil.NewMockServerContext()
+	ctx.SetSupportsCodeActionLiterals(true)
+	req := types.NewRequestContext(ctx, nil)
+
+	_ = ctx.TokenManager().Add(&tokens.Token{Name: "color-primary", Value: "#ff0000", Type: "color"})
+
+	uri := "file:///test.css"
+	require.NoError(t, ctx.DocumentManager().DidOpen(uri, "css", 1, ".a {\n  color: var(--color-primary, blue);\n}"))
+
+	deprecated := protocol.Diagnostic{Tags: []protocol.DiagnosticTag{protocol.DiagnosticTagDeprecated}}
+	result, err := CodeAction(req, &protocol.CodeActionParams{
+		TextDocument: protocol.TextDocumentIdentifier{URI: uri},
+		Range:        protocol.Range{End: protocol.Position{Line: 3}},
+		Context:      protocol.CodeActionContext{Diagnostics: []protocol.Diagnostic{deprecated, deprecated}},
+	})
+	require.NoError(t, err)
+
+	var document, workspace []string
+	for _, action := range result.([]protocol.CodeAction) {
+		if action.Command == nil {
+			continue
+		}
+		switch len(action.Command.Arguments) {
+		case 0:
+			workspace = append(workspace, action.Command.Command)
+		default:
+			document = append(document, action.Command.Command)
+			assert.Equal(t, []any{uri}, action.Command.Arguments)
+		}
+	}
+	assert.Contains(t, document, PreviewDeprecatedMigrationCommand)
+	assert.Contains(t, workspace, PreviewDeprecatedMigrationCommand, "without arguments, the preview covers the workspace")
+}
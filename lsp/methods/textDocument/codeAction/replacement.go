@@ -0,0 +1,101 @@
+package codeaction
+
+import (
+	"strings"
+	"unicode"
+
+	"bennypowers.dev/dtls/internal/tokens"
+	"bennypowers.dev/dtls/lsp/types"
+)
+
+// quotePairs are the delimiters of quoted names in deprecation messages
+var quotePairs = [][2]string{
+	{`"`, `"`}, {"'", "'"}, {"`", "`"}, {"“", "”"}, {"‘", "’"}, {"{", "}"},
+}
+
+// deprecatedReplacement returns the token a deprecated token's message
+// recommends, or nil. Each name the message mentions is looked up in the
+// token index, in order, so phrasing doesn't matter: "Use color.primary
+// instead", "see `--color-primary`", and "replaced by 'color-primary'" all
+// recommend the same token. Deprecated candidates are only offered when no
+// other candidate is a token.
+func deprecatedReplacement(req *types.RequestContext, token *tokens.Token) *tokens.Token {
+	var fallback *tokens.Token
+	for _, candidate := range deprecationMessageNames(token.DeprecationMessage) {
+		replacement := req.Server.Token(candidate)
+		if replacement == nil || (replacement.Name == token.Name && replacement.FilePath == token.FilePath) {
+			continue
+		}
+		if !replacement.Deprecated {
+			return replacement
+		}
+		if fallback == nil {
+			fallback = replacement
+		}
+	}
+	return fallback
+}
+
+// deprecationMessageNames returns the words of a deprecation message which may
+// name a token: dot paths, e.g. color.primary, CSS variables, e.g.
+// --color-primary or var(--color-primary), and quoted names, e.g.
+// 'color-primary' or {color.primary}. Surrounding punctuation is dropped.
+func deprecationMessageNames(message string) []string {
+	var candidates []string
+	for _, word := range strings.FieldsFunc(message, isCandidateSeparator) {
+		name, quoted := unwrapCandidate(word)
+		if name != "" && (quoted || strings.HasPrefix(name, "--") || isDotPath(name)) {
+			candidates = append(candidates, name)
+		}
+	}
+	return candidates
+}
+
+// isCandidateSeparator reports whether r separates the words of a message.
+// Commas and semicolons end words, so a var() call with a fallback yields
+// its variable.
+func isCandidateSeparator(r rune) bool {
+	return unicode.IsSpace(r) || r == ',' || r == ';'
+}
+
+// unwrapCandidate strips the punctuation around a word, reporting whether it
+// was quoted
+func unwrapCandidate(word string) (name string, quoted bool) {
+	for {
+		unwrapped := strings.TrimRight(word, ".:!?")
+		unwrapped = strings.TrimPrefix(unwrapped, "var(")
+		unwrapped = strings.TrimLeft(unwrapped, "([<")
+		unwrapped = strings.TrimRight(unwrapped, ")]>")
+		for _, pair := range quotePairs {
+			if len(unwrapped) > len(pair[0])+len(pair[1]) && strings.HasPrefix(unwrapped, pair[0]) && strings.HasSuffix(unwrapped, pair[1]) {
+				unwrapped = unwrapped[len(pair[0]) : len(unwrapped)-len(pair[1])]
+				quoted = true
+				break
+			}
+		}
+		if unwrapped == word {
+			return word, quoted
+		}
+		word = unwrapped
+	}
+}
+
+// isDotPath reports whether name is a token path of two or more segments,
+// e.g. color.primary
+func isDotPath(name string) bool {
+	segments := strings.Split(name, ".")
+	if len(segments) < 2 {
+		return false
+	}
+	for _, segment := range segments {
+		if segment == "" || strings.IndexFunc(segment, isNotPathRune) >= 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// isNotPathRune reports whether r can't appear in a token path segment
+func isNotPathRune(r rune) bool {
+	return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '-' && r != '_' && r != '$' && r != '@'
+}
@@ -0,0 +1,79 @@
+package codeaction
+
+import (
+	"testing"
+
+	"bennypowers.dev/dtls/internal/tokens"
+	"bennypowers.dev/dtls/lsp/testutil"
+	"bennypowers.dev/dtls/lsp/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tliron/glsp"
+)
+
+func TestDeprecationMessageNames(t *testing.T) {
+	tests := []struct {
+		message  string
+		expected []string
+	}{
+		{message: "Use color.primary instead", expected: []string{"color.primary"}},
+		{message: "Replaced by spacing.small for better consistency", expected: []string{"spacing.small"}},
+		{message: "Deprecated. Prefer color.brand.primary.", expected: []string{"color.brand.primary"}},
+		{message: "use --color-primary", expected: []string{"--color-primary"}},
+		{message: "Switch to var(--color-primary, #f00).", expected: []string{"--color-primary"}},
+		{message: "See `--ds-color-primary` (the new brand color)", expected: []string{"--ds-color-primary"}},
+		{message: `Use "color-primary" or 'color-secondary'`, expected: []string{"color-primary", "color-secondary"}},
+		{message: "Use “color-primary” now", expected: []string{"color-primary"}},
+		{message: "Alias {color.primary} directly", expected: []string{"color.primary"}},
+		{message: "Don't use it; it's going away", expected: nil},
+		{message: "Renamed (to color.accent)", expected: []string{"color.accent"}},
+		{message: "No longer supported", expected: nil},
+		{message: "", expected: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.message, func(t *testing.T) {
+			assert.Equal(t, tt.expected, deprecationMessageNames(tt.message))
+		})
+	}
+}
+
+func TestDeprecatedReplacement(t *testing.T) {
+	ctx := testutil.NewMockServerContext()
+	req := types.NewRequestContext(ctx, &glsp.Context{})
+	for _, token := range []*tokens.Token{
+		{Name: "color-primary", Value: "#00f", Type: "color"},
+		{Name: "color-legacy", Value: "#00e", Type: "color", Deprecated: true},
+		{Name: "color-older", Value: "#00d", Type: "color", Deprecated: true},
+	} {
+		require.NoError(t, ctx.TokenManager().Add(token))
+	}
+
+	tests := []struct {
+		name     string
+		message  string
+		expected string
+	}{
+		{name: "dot path", message: "Use color.primary instead", expected: "color-primary"},
+		{name: "CSS variable", message: "see var(--color-primary)", expected: "color-primary"},
+		{name: "quoted name", message: "replaced by 'color-primary'", expected: "color-primary"},
+		{name: "unknown names are skipped", message: "Use color.missing, or color.primary", expected: "color-primary"},
+		{name: "deprecated names are a last resort", message: "Use color.legacy or color.primary", expected: "color-primary"},
+		{name: "deprecated name", message: "Use color.legacy", expected: "color-legacy"},
+		{name: "the token itself", message: "color.older is going away", expected: ""},
+		{name: "no names", message: "No longer supported", expected: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			deprecated := &tokens.Token{Name: "color-older", Deprecated: true, DeprecationMessage: tt.message}
+			replacement := deprecatedReplacement(req, deprecated)
+			if tt.expected == "" {
+				assert.Nil(t, replacement)
+				return
+			}
+			require.NotNil(t, replacement)
+			assert.Equal(t, tt.expected, replacement.Name)
+		})
+	}
+}
@@ -0,0 +1,69 @@
+package codeaction
+
+import (
+	"bennypowers.dev/dtls/lsp/methods/textDocument/diagnostic"
+	"bennypowers.dev/dtls/lsp/types"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+// createScaleFixActions creates quick fixes that replace values off their
+// scale with the value the diagnostic suggests
+func createScaleFixActions(req *types.RequestContext, uri string, diagnostics []protocol.Diagnostic) []protocol.CodeAction {
+	var actions []protocol.CodeAction
+	for _, diag := range diagnostics {
+		data, ok := diag.Data.(map[string]any)
+		if !ok {
+			continue
+		}
+		suggestion, ok := data[diagnostic.ScaleSuggestionKey].(string)
+		if !ok {
+			continue
+		}
+
+		kind := protocol.CodeActionKindQuickFix
+		preferred := true
+		actions = append(actions, protocol.CodeAction{
+			Title:       req.Localize("Change value to %s", suggestion),
+			Kind:        &kind,
+			Diagnostics: []protocol.Diagnostic{diag},
+			IsPreferred: &preferred,
+			Edit: &protocol.WorkspaceEdit{
+				Changes: map[string][]protocol.TextEdit{
+					uri: {{Range: diag.Range, NewText: suggestion}},
+				},
+			},
+		})
+	}
+	return actions
+}
+
+// createKeywordFixActions creates quick fixes that rename unknown $-prefixed
+// properties to the DTCG keyword the diagnostic suggests
+func createKeywordFixActions(req *types.RequestContext, uri string, diagnostics []protocol.Diagnostic) []protocol.CodeAction {
+	var actions []protocol.CodeAction
+	for _, diag := range diagnostics {
+		data, ok := diag.Data.(map[string]any)
+		if !ok {
+			continue
+		}
+		keyword, ok := data[diagnostic.KeywordSuggestionKey].(string)
+		if !ok {
+			continue
+		}
+
+		kind := protocol.CodeActionKindQuickFix
+		preferred := true
+		actions = append(actions, protocol.CodeAction{
+			Title:       req.Localize("Rename to %s", keyword),
+			Kind:        &kind,
+			Diagnostics: []protocol.Diagnostic{diag},
+			IsPreferred: &preferred,
+			Edit: &protocol.WorkspaceEdit{
+				Changes: map[string][]protocol.TextEdit{
+					uri: {{Range: diag.Range, NewText: keyword}},
+				},
+			},
+		})
+	}
+	return actions
+}
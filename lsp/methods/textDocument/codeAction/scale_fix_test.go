@@ -0,0 +1,71 @@
+package codeaction
+
+import (
+	"testing"
+
+	"bennypowers.dev/dtls/lsp/helpers"
+	"bennypowers.dev/dtls/lsp/methods/textDocument/diagnostic"
+	"bennypowers.dev/dtls/lsp/testutil"
+	"bennypowers.dev/dtls/lsp/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+func TestScaleFixActions(t *testing.T) {
+	ctx := testutil.NewMockServerContext()
+	ctx.SetSupportsCodeActionLiterals(true)
+	ctx.SetConfig(types.ServerConfig{Scales: []types.ScaleRule{{Group: "space", Step: "4px"}}})
+	req := types.NewRequestContext(ctx, nil)
+
+	uri := "file:///tokens.yaml"
+	content := "space:\n  md:\n    $value: 6px\n"
+	require.NoError(t, ctx.DocumentManager().DidOpen(uri, "yaml", 1, content))
+
+	diagnostics, err := diagnostic.GetDiagnostics(ctx, uri)
+	require.NoError(t, err)
+	require.Len(t, diagnostics, 1)
+
+	result, err := CodeAction(req, &protocol.CodeActionParams{
+		TextDocument: protocol.TextDocumentIdentifier{URI: uri},
+		Range:        diagnostics[0].Range,
+		Context:      protocol.CodeActionContext{Diagnostics: diagnostics},
+	})
+	require.NoError(t, err)
+	actions, _ := result.([]protocol.CodeAction)
+	require.Len(t, actions, 1)
+	assert.Equal(t, "Change value to 8px", actions[0].Title)
+
+	got, err := helpers.ApplyEdits(content, actions[0].Edit.Changes[uri])
+	require.NoError(t, err)
+	assert.Equal(t, "space:\n  md:\n    $value: 8px\n", got)
+}
+
+func TestKeywordFixActions(t *testing.T) {
+	ctx := testutil.NewMockServerContext()
+	ctx.SetSupportsCodeActionLiterals(true)
+	ctx.SetConfig(types.ServerConfig{Strict: true})
+	req := types.NewRequestContext(ctx, nil)
+
+	uri := "file:///tokens.yaml"
+	content := "color:\n  primary:\n    $value: '#00f'\n    $descripton: Brand color\n"
+	require.NoError(t, ctx.DocumentManager().DidOpen(uri, "yaml", 1, content))
+
+	diagnostics, err := diagnostic.GetDiagnostics(ctx, uri)
+	require.NoError(t, err)
+	require.Len(t, diagnostics, 1)
+
+	result, err := CodeAction(req, &protocol.CodeActionParams{
+		TextDocument: protocol.TextDocumentIdentifier{URI: uri},
+		Range:        diagnostics[0].Range,
+		Context:      protocol.CodeActionContext{Diagnostics: diagnostics},
+	})
+	require.NoError(t, err)
+	actions, _ := result.([]protocol.CodeAction)
+	require.Len(t, actions, 1)
+	assert.Equal(t, "Rename to $description", actions[0].Title)
+
+	got, err := helpers.ApplyEdits(content, actions[0].Edit.Changes[uri])
+	require.NoError(t, err)
+	assert.Equal(t, "color:\n  primary:\n    $value: '#00f'\n    $description: Brand color\n", got)
+}
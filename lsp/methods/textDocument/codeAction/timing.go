@@ -0,0 +1,30 @@
+package codeaction
+
+import (
+	cssparser "bennypowers.dev/dtls/internal/parser/css"
+	"bennypowers.dev/dtls/internal/tokens"
+	"bennypowers.dev/dtls/lsp/helpers/css"
+	"bennypowers.dev/dtls/lsp/types"
+)
+
+// fallbackNeedsFix reports whether a var() call's literal fallback should be
+// replaced with its token's value: it doesn't match the value, or it is
+// malformed in a transition or animation property, e.g. a duration without a
+// time unit
+func fallbackNeedsFix(req *types.RequestContext, varCall cssparser.VarCall, token *tokens.Token) bool {
+	return !css.FallbackMatches(*varCall.Fallback, token, req.Server.Token) ||
+		css.TimingValueError(varCall.Property, token.Type, *varCall.Fallback) != nil
+}
+
+// fixedFallbackValue formats a token's value to replace a var() call's
+// fallback. A value which is malformed in the call's property is no fix.
+func fixedFallbackValue(varCall cssparser.VarCall, token *tokens.Token) (string, error) {
+	value, err := css.FormatTokenValueForCSS(token)
+	if err != nil {
+		return "", err
+	}
+	if err := css.TimingValueError(varCall.Property, token.Type, value); err != nil {
+		return "", err
+	}
+	return value, nil
+}
@@ -0,0 +1,62 @@
+package codeaction
+
+import (
+	"testing"
+
+	"bennypowers.dev/dtls/internal/tokens"
+	"bennypowers.dev/dtls/lsp/testutil"
+	"bennypowers.dev/dtls/lsp/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tliron/glsp"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+func TestCodeAction_MalformedTimingFallbacks(t *testing.T) {
+	ctx := testutil.NewMockServerContext()
+	ctx.SetSupportsCodeActionLiterals(true)
+
+	for _, token := range []*tokens.Token{
+		{Name: "duration.fast", Value: "200ms", Type: "duration"},
+		{Name: "duration.unitless", Value: "300", Type: "duration"},
+		{Name: "ease.out", Value: "[0,0,0.58,1]", RawValue: []any{0.0, 0.0, 0.58, 1.0}, Type: "cubicBezier"},
+	} {
+		require.NoError(t, ctx.TokenManager().Add(token))
+	}
+
+	uri := "file:///test.css"
+	_ = ctx.DocumentManager().DidOpen(uri, "css", 1, `.a { transition-duration: var(--duration-fast, 200); }
+.b { transition-timing-function: var(--ease-out, cubic-bezier(0 0 0.58 1)); }
+.c { animation-delay: var(--duration-unitless, 300px); }`)
+
+	req := types.NewRequestContext(ctx, &glsp.Context{})
+	result, err := CodeAction(req, &protocol.CodeActionParams{
+		TextDocument: protocol.TextDocumentIdentifier{URI: uri},
+		Range: protocol.Range{
+			Start: protocol.Position{Line: 0, Character: 0},
+			End:   protocol.Position{Line: 2, Character: 60},
+		},
+	})
+	require.NoError(t, err)
+	actions, ok := result.([]protocol.CodeAction)
+	require.True(t, ok)
+
+	fixes := map[string]string{}
+	for _, action := range actions {
+		if action.Edit != nil && len(action.Edit.Changes[uri]) == 1 {
+			fixes[action.Title] = action.Edit.Changes[uri][0].NewText
+		}
+	}
+	assert.Equal(t, "var(--duration-fast, 200ms)", fixes["Fix fallback value to '200ms'"])
+	assert.Equal(t, "var(--ease-out, cubic-bezier(0, 0, 0.58, 1))", fixes["Fix fallback value to 'cubic-bezier(0, 0, 0.58, 1)'"],
+		"cubic bezier arrays are written as cubic-bezier() functions")
+	assert.NotContains(t, fixes, "Fix fallback value to '300'", "a malformed token value is no fix")
+
+	edits, err := FixAllFallbacksEdits(types.NewRequestContext(ctx, &glsp.Context{}), uri)
+	require.NoError(t, err)
+	var fixed []string
+	for _, edit := range edits {
+		fixed = append(fixed, edit.NewText)
+	}
+	assert.Equal(t, []string{"var(--duration-fast, 200ms)", "var(--ease-out, cubic-bezier(0, 0, 0.58, 1))"}, fixed)
+}
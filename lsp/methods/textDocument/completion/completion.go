@@ -3,11 +3,17 @@ package completion
 import (
 	"bennypowers.dev/dtls/internal/log"
 	"bytes"
+	"cmp"
 	"fmt"
+	"slices"
 	"strings"
 	"text/template"
+	"unicode"
+	"unicode/utf8"
 
+	"bennypowers.dev/dtls/internal/gitblame"
 	"bennypowers.dev/dtls/internal/parser"
+	"bennypowers.dev/dtls/internal/parser/js"
 	"bennypowers.dev/dtls/internal/position"
 	"bennypowers.dev/dtls/internal/tokens"
 	"bennypowers.dev/dtls/lsp/types"
@@ -15,7 +21,7 @@ import (
 )
 
 // Template for token documentation
-// Note: {{.CSSVariableName}} calls the Token.CSSVariableName() method (not a field)
+// Note: {{.CSSVariableName}} is the formatted name from tokenDocData, not the Token method
 var tokenDocTemplate = template.Must(template.New("tokenDoc").Parse(`# {{.CSSVariableName}}
 {{if .Description}}
 {{.Description}}
@@ -26,12 +32,21 @@ var tokenDocTemplate = template.Must(template.New("tokenDoc").Parse(`# {{.CSSVar
 ⚠️ **DEPRECATED**{{if .DeprecationMessage}}: {{.DeprecationMessage}}{{end}}
 {{end}}{{if .FilePath}}
 *Defined in: {{.FilePath}}*
+{{end}}{{if .History}}
+*Last changed {{.History.DateString}}: {{.History.Subject}}*
 {{end}}`))
 
+// tokenDocData wraps a Token with its formatted CSS variable name for rendering
+type tokenDocData struct {
+	*tokens.Token
+	CSSVariableName string
+	History         *gitblame.Annotation
+}
+
 // renderTokenDoc renders the documentation markdown for a token
-func renderTokenDoc(token *tokens.Token) (string, error) {
+func renderTokenDoc(token *tokens.Token, cssVarName string, history *gitblame.Annotation) (string, error) {
 	var buf bytes.Buffer
-	if err := tokenDocTemplate.Execute(&buf, token); err != nil {
+	if err := tokenDocTemplate.Execute(&buf, tokenDocData{Token: token, CSSVariableName: cssVarName, History: history}); err != nil {
 		return "", err
 	}
 	return buf.String(), nil
@@ -46,11 +61,26 @@ func Completion(req *types.RequestContext, params *protocol.CompletionParams) (a
 
 	log.Info("Completion requested: %s at line %d, char %d", uri, pos.Line, pos.Character)
 
+	// Completion may be disabled in configuration after initialize
+	if !req.Config().Features.CompletionEnabled() {
+		return nil, nil
+	}
+
 	// Get document
 	doc := req.Server.Document(uri)
 	if doc == nil {
 		return nil, nil
 	}
+	req.TraceDocument(doc)
+
+	// Token files complete alias references
+	switch doc.LanguageID() {
+	case "json", "jsonc", "yaml":
+		if !req.Server.ShouldProcessAsTokenFile(uri) {
+			return nil, nil
+		}
+		return tokenFileCompletion(req, doc.Content(), pos)
+	}
 
 	// Only process CSS-supported files
 	if !parser.IsCSSSupportedLanguage(doc.LanguageID()) {
@@ -70,51 +100,182 @@ func Completion(req *types.RequestContext, params *protocol.CompletionParams) (a
 		return nil, nil
 	}
 
+	// In JS/TS strings holding CSS, insert the bare custom property name
+	// where var() is already written or a property name is expected
+	bareName := false
+	if styleString, before := styleStringAt(doc.Content(), doc.LanguageID(), pos); styleString != nil {
+		bareName = styleString.Kind == js.StyleProperty || strings.HasSuffix(strings.TrimRight(before, " "), "var(")
+	}
+	// Likewise for the property names compared in container style queries
+	if parser.ResolveLanguage(doc.LanguageID()) == "css" {
+		text, _ := textBeforePosition(doc.Content(), pos)
+		bareName = isStyleQueryName(conditionalPrelude(text))
+	}
+	// and in stylesheets where var( is already written
+	start, before := wordStart(doc.Content(), pos)
+	if strings.HasSuffix(strings.TrimRight(before, " "), "var(") {
+		bareName = true
+	}
+
 	// Filter tokens by the current word
-	var items []protocol.CompletionItem
 	normalizedWord := normalizeTokenName(word)
 
-	for _, token := range req.Server.TokenManager().GetAll() {
-		cssVar := token.CSSVariableName()
-		normalizedLabel := normalizeTokenName(cssVar)
-
+	showPrefix := req.Config().ShowPrefixEnabled()
+	manager := req.Server.TokenManager()
+	var matches []*tokens.Token
+	// unprefixed holds the tokens matched by their name without the prefix,
+	// e.g. --ds-color-primary for "--color-pr"
+	unprefixed := map[*tokens.Token]bool{}
+	for _, token := range manager.GetAll() {
 		// Check if the token matches the current word
-		if strings.HasPrefix(normalizedLabel, normalizedWord) {
-			kind := protocol.CompletionItemKindVariable
-
-			// Use snippets only if client supports them
-			var insertTextFormat protocol.InsertTextFormat
-			var insertText string
-			if req.Server.SupportsSnippets() {
-				insertTextFormat = protocol.InsertTextFormatSnippet
-				insertText = fmt.Sprintf("var(%s${1:, %s})$0", cssVar, token.Value)
-			} else {
-				insertTextFormat = protocol.InsertTextFormatPlainText
-				insertText = fmt.Sprintf("var(%s)", cssVar)
-			}
+		if hasNormalizedPrefix(manager.CSSVariableName(token), normalizedWord) {
+			matches = append(matches, token)
+		} else if token.Prefix != "" && hasNormalizedPrefix(manager.NameFormat().UnprefixedName(token), normalizedWord) {
+			matches = append(matches, token)
+			unprefixed[token] = true
+		}
+	}
 
-			item := protocol.CompletionItem{
-				Label:            cssVar,
-				Kind:             &kind,
-				InsertTextFormat: &insertTextFormat,
-				InsertText:       &insertText,
-				Data: map[string]any{
-					"tokenName": cssVar,
-				},
-			}
+	candidates := dedupeTokens(manager.NameFormat(), matches, doc.Content(), req.Config().Prefix)
+	total, limit := len(candidates), req.Config().CompletionItemLimit()
+	incomplete := total > limit
+	if incomplete {
+		candidates = closestMatches(manager, candidates, limit)
+	}
+
+	// Use snippets only if client supports them. The kind and format are
+	// the same for every item, so the items share them.
+	kind := protocol.CompletionItemKindVariable
+	insertTextFormat := protocol.InsertTextFormatPlainText
+	snippets := !bareName && req.Server.SupportsSnippets()
+	if snippets {
+		insertTextFormat = protocol.InsertTextFormatSnippet
+	}
+
+	items := make([]protocol.CompletionItem, 0, len(candidates))
+	for _, token := range candidates {
+		cssVar := manager.CSSVariableName(token)
+
+		var insertText string
+		switch {
+		case bareName:
+			insertText = cssVar
+		case snippets:
+			insertText = fmt.Sprintf("var(%s${1:, %s})$0", cssVar, token.Value)
+		default:
+			insertText = "var(" + cssVar + ")"
+		}
+
+		item := protocol.CompletionItem{
+			Label:            manager.DisplayName(token, showPrefix),
+			Kind:             &kind,
+			InsertTextFormat: &insertTextFormat,
+			InsertText:       &insertText,
+			Data: map[string]any{
+				"tokenName": cssVar,
+			},
+		}
 
-			items = append(items, item)
+		// The label may omit the prefix, so filter on the full variable name
+		if item.Label != cssVar {
+			item.FilterText = &cssVar
 		}
+
+		// A token matched without its prefix replaces the typed word with the
+		// prefixed variable, and filters on the name the user is typing
+		if unprefixed[token] {
+			filterText := manager.NameFormat().UnprefixedName(token)
+			item.FilterText = &filterText
+			item.TextEdit = protocol.TextEdit{
+				Range:   protocol.Range{Start: start, End: pos},
+				NewText: insertText,
+			}
+		}
+
+		items = append(items, item)
 	}
 
-	log.Info("Returning %d completion items", len(items))
+	if incomplete {
+		log.Info("Returning %d of %d completion items", len(items), total)
+	} else {
+		log.Info("Returning %d completion items", len(items))
+	}
 
 	return &protocol.CompletionList{
-		IsIncomplete: false,
+		IsIncomplete: incomplete,
 		Items:        items,
 	}, nil
 }
 
+// closestMatches keeps the limit tokens with the shortest names, which
+// extend the typed word the least. The list is then incomplete: typing more
+// narrows the matches, and the client asks again.
+func closestMatches(manager *tokens.Manager, candidates []*tokens.Token, limit int) []*tokens.Token {
+	closest := slices.Clone(candidates)
+	slices.SortStableFunc(closest, func(a, b *tokens.Token) int {
+		nameA, nameB := manager.CSSVariableName(a), manager.CSSVariableName(b)
+		return cmp.Or(cmp.Compare(len(nameA), len(nameB)), strings.Compare(nameA, nameB))
+	})
+	return closest[:limit]
+}
+
+// dedupeTokens keeps one variant of each logical token, where the same token
+// is loaded more than once, e.g. with and without a prefix, or from two
+// layers. The variant preferred is the one whose prefix the document already
+// uses, then the one with the project's prefix.
+func dedupeTokens(format tokens.NameFormat, all []*tokens.Token, content, projectPrefix string) []*tokens.Token {
+	best := make(map[string]*tokens.Token, len(all))
+	order := make([]string, 0, len(all))
+	for _, token := range all {
+		key := format.UnprefixedName(token)
+		current, ok := best[key]
+		if !ok {
+			order = append(order, key)
+			best[key] = token
+			continue
+		}
+		if preferVariant(format, token, current, content, projectPrefix) {
+			best[key] = token
+		}
+	}
+
+	deduped := make([]*tokens.Token, 0, len(order))
+	for _, key := range order {
+		deduped = append(deduped, best[key])
+	}
+	return deduped
+}
+
+// preferVariant reports whether candidate is a better completion than
+// current for the same logical token
+func preferVariant(format tokens.NameFormat, candidate, current *tokens.Token, content, projectPrefix string) bool {
+	if a, b := variantScore(format, candidate, content, projectPrefix), variantScore(format, current, content, projectPrefix); a != b {
+		return a > b
+	}
+	// Break ties the same way on every request
+	candidateName, currentName := format.CSSVariableName(candidate), format.CSSVariableName(current)
+	if candidateName != currentName {
+		return candidateName < currentName
+	}
+	return candidate.FilePath < current.FilePath
+}
+
+// variantScore ranks a token variant by how well it fits the document
+func variantScore(format tokens.NameFormat, token *tokens.Token, content, projectPrefix string) int {
+	score := 0
+	if token.Prefix != "" {
+		// The prefix part of the variable name, e.g. "--ds-"
+		stem := strings.TrimSuffix(format.CSSVariableName(token), strings.TrimPrefix(format.UnprefixedName(token), "--"))
+		if strings.Contains(content, stem) {
+			score += 2
+		}
+	}
+	if token.Prefix == projectPrefix {
+		score++
+	}
+	return score
+}
+
 // handleCompletionResolve handles the completionItem/resolve request
 
 // CompletionResolve resolves a completion item with additional details
@@ -140,7 +301,8 @@ func CompletionResolve(req *types.RequestContext, item *protocol.CompletionItem)
 	}
 
 	// Render documentation using template
-	documentation, err := renderTokenDoc(token)
+	displayName := req.Server.TokenManager().DisplayName(token, req.Config().ShowPrefixEnabled())
+	documentation, err := renderTokenDoc(token, displayName, req.Server.ValueHistory(req.Config(), token))
 	if err != nil {
 		log.Info("Failed to render token documentation: %v", err)
 		return item, nil
@@ -193,6 +355,45 @@ func getWordAtPosition(content string, pos protocol.Position) string {
 	return line[start:end]
 }
 
+// wordStart returns the position where the word at pos starts, and the text
+// of its line before that
+func wordStart(content string, pos protocol.Position) (protocol.Position, string) {
+	lines := strings.Split(content, "\n")
+	if int(pos.Line) >= len(lines) {
+		return pos, ""
+	}
+	line := lines[pos.Line]
+	start := min(position.UTF16ToByteOffset(line, int(pos.Character)), len(line))
+	for start > 0 && isWordChar(line[start-1]) {
+		start--
+	}
+	return protocol.Position{Line: pos.Line, Character: position.ByteOffsetToUTF16Uint32(line, start)}, line[:start]
+}
+
+// styleStringAt returns the JS/TS style string containing pos, if any,
+// along with the string's content before the word at pos
+func styleStringAt(content, languageID string, pos protocol.Position) (*js.StyleString, string) {
+	lines := strings.Split(content, "\n")
+	if int(pos.Line) >= len(lines) {
+		return nil, ""
+	}
+	line := lines[pos.Line]
+	byteOffset := position.UTF16ToByteOffset(line, int(pos.Character))
+
+	for _, str := range parser.StyleStrings(content, languageID) {
+		start := int(str.StartCol) //nolint:gosec // G115: columns from tree-sitter are bounded by file size
+		if str.StartLine != uint(pos.Line) || byteOffset < start || byteOffset > start+len(str.Content) {
+			continue
+		}
+		wordStart := byteOffset
+		for wordStart > start && isWordChar(line[wordStart-1]) {
+			wordStart--
+		}
+		return &str, line[start:wordStart]
+	}
+	return nil, ""
+}
+
 // isWordChar checks if a character is part of a CSS identifier
 func isWordChar(c byte) bool {
 	return (c >= 'a' && c <= 'z') ||
@@ -208,7 +409,7 @@ func isWordChar(c byte) bool {
 // completions to CSS identifier characters, so additional brace counting within
 // embedded CSS is unnecessary.
 func isInCompletionContext(content, languageID string, pos protocol.Position) bool {
-	if languageID == "css" {
+	if parser.ResolveLanguage(languageID) == "css" {
 		return isInCSSBlock(content, pos)
 	}
 
@@ -218,11 +419,22 @@ func isInCompletionContext(content, languageID string, pos protocol.Position) bo
 	return len(spans) > 0
 }
 
-// isInCSSBlock counts braces in CSS content up to the cursor position
+// isInCSSBlock counts braces in CSS content up to the cursor position.
+// The preludes of conditional group rules count too, since @supports
+// conditions and @container style queries name custom properties.
 func isInCSSBlock(content string, pos protocol.Position) bool {
+	text, ok := textBeforePosition(content, pos)
+	if !ok {
+		return false
+	}
+	return countUnclosedBraces(text) > 0 || conditionalPrelude(text) != ""
+}
+
+// textBeforePosition returns the content up to the cursor position
+func textBeforePosition(content string, pos protocol.Position) (string, bool) {
 	lines := strings.Split(content, "\n")
 	if int(pos.Line) >= len(lines) {
-		return false
+		return "", false
 	}
 
 	// Get all content up to and including the cursor position
@@ -244,7 +456,61 @@ func isInCSSBlock(content string, pos protocol.Position) bool {
 		}
 	}
 
-	return countUnclosedBraces(textUpToCursor.String()) > 0
+	return textUpToCursor.String(), true
+}
+
+// conditionalAtRules are the at-rules whose preludes may use tokens
+var conditionalAtRules = []string{"@container", "@media", "@supports"}
+
+// conditionalPrelude returns the prelude of the conditional group rule that
+// text ends in, e.g. "@supports (color: var(--col", or "" outside of one
+func conditionalPrelude(text string) string {
+	start := strings.LastIndexAny(text, "{};") + 1
+	prelude := strings.TrimLeft(text[start:], " \t\r\n")
+	for _, keyword := range conditionalAtRules {
+		if strings.HasPrefix(prelude, keyword) {
+			return prelude
+		}
+	}
+	return ""
+}
+
+// isStyleQueryName reports whether a conditional prelude ends where a
+// container style query expects a custom property name, as in
+// "@container style(--col" or "@container style((--a: 1) and (--col"
+func isStyleQueryName(prelude string) bool {
+	if !strings.HasPrefix(prelude, "@container") {
+		return false
+	}
+
+	// Drop the word being completed
+	before := prelude
+	for before != "" && isWordChar(before[len(before)-1]) {
+		before = before[:len(before)-1]
+	}
+	before = strings.TrimRight(before, " \t\r\n")
+	if !strings.HasSuffix(before, "(") {
+		return false
+	}
+	if !strings.HasSuffix(before, "style(") && len(before) > 1 && isWordChar(before[len(before)-2]) {
+		return false // A function's argument, e.g. var(
+	}
+
+	// The last style() query must still be open
+	open := strings.LastIndex(before, "style(")
+	if open < 0 {
+		return false
+	}
+	depth := 0
+	for _, c := range before[open:] {
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		}
+	}
+	return depth > 0
 }
 
 // countUnclosedBraces returns the number of unclosed braces in a CSS text fragment
@@ -262,6 +528,23 @@ func countUnclosedBraces(text string) int {
 	return openBraces - closeBraces
 }
 
+// hasNormalizedPrefix reports whether name, normalized, starts with the
+// normalized word, without allocating the normalized name
+func hasNormalizedPrefix(name, normalizedWord string) bool {
+	name = strings.TrimPrefix(name, "--")
+	for _, want := range normalizedWord {
+		for strings.HasPrefix(name, "-") {
+			name = name[1:]
+		}
+		got, size := utf8.DecodeRuneInString(name)
+		if size == 0 || unicode.ToLower(got) != want {
+			return false
+		}
+		name = name[size:]
+	}
+	return true
+}
+
 // normalizeTokenName normalizes a token name for comparison
 func normalizeTokenName(name string) string {
 	// Remove leading dashes and convert to lowercase
@@ -0,0 +1,54 @@
+package completion
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"bennypowers.dev/dtls/internal/tokens"
+	"bennypowers.dev/dtls/lsp/testutil"
+	"bennypowers.dev/dtls/lsp/types"
+	"github.com/tliron/glsp"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+// BenchmarkCompletion benchmarks completing at the end of large stylesheets
+func BenchmarkCompletion(b *testing.B) {
+	sizes := []int{100, 1000, 5000}
+
+	for _, size := range sizes {
+		b.Run(fmt.Sprintf("rules=%d", size), func(b *testing.B) {
+			ctx := testutil.NewMockServerContext()
+			for i := range 1000 {
+				_ = ctx.TokenManager().Add(&tokens.Token{
+					Name:  fmt.Sprintf("color-%d", i),
+					Path:  []string{"color", fmt.Sprint(i)},
+					Value: "#ff0000",
+					Type:  "color",
+				})
+			}
+
+			var css strings.Builder
+			for i := range size {
+				fmt.Fprintf(&css, ".rule-%d {\n  color: var(--color-%d);\n}\n", i, i%1000)
+			}
+			css.WriteString(".last { color: --col }\n")
+			uri := "file:///bench.css"
+			_ = ctx.DocumentManager().DidOpen(uri, "css", 1, css.String())
+
+			req := types.NewRequestContext(ctx, &glsp.Context{})
+			params := &protocol.CompletionParams{
+				TextDocumentPositionParams: protocol.TextDocumentPositionParams{
+					TextDocument: protocol.TextDocumentIdentifier{URI: uri},
+					Position:     protocol.Position{Line: uint32(size * 3), Character: 20}, //nolint:gosec // G115: benchmark sizes are small
+				},
+			}
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for b.Loop() {
+				_, _ = Completion(req, params)
+			}
+		})
+	}
+}
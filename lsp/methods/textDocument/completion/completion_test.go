@@ -1,8 +1,11 @@
 package completion
 
 import (
+	"strings"
 	"testing"
+	"time"
 
+	"bennypowers.dev/dtls/internal/gitblame"
 	"bennypowers.dev/dtls/internal/tokens"
 	"bennypowers.dev/dtls/lsp/testutil"
 	"bennypowers.dev/dtls/lsp/types"
@@ -102,6 +105,38 @@ func TestCompletion_AllTokens(t *testing.T) {
 	assert.Equal(t, 2, len(completionList.Items))
 }
 
+func TestCompletion_ItemLimit(t *testing.T) {
+	ctx := testutil.NewMockServerContext()
+	cfg := types.DefaultConfig()
+	cfg.MaxCompletionItems = 2
+	ctx.SetConfig(cfg)
+	req := types.NewRequestContext(ctx, &glsp.Context{})
+
+	for _, name := range []string{"color.primary.hover", "color.primary", "color.accent"} {
+		require.NoError(t, ctx.TokenManager().Add(&tokens.Token{Name: name, Value: "#ff0000"}))
+	}
+
+	uri := "file:///test.css"
+	require.NoError(t, ctx.DocumentManager().DidOpen(uri, "css", 1, `.button { color: --color }`))
+
+	result, err := Completion(req, &protocol.CompletionParams{
+		TextDocumentPositionParams: protocol.TextDocumentPositionParams{
+			TextDocument: protocol.TextDocumentIdentifier{URI: uri},
+			Position:     protocol.Position{Line: 0, Character: 24},
+		},
+	})
+	require.NoError(t, err)
+	completionList, ok := result.(*protocol.CompletionList)
+	require.True(t, ok)
+
+	assert.True(t, completionList.IsIncomplete, "the client asks again as the user types")
+	var labels []string
+	for _, item := range completionList.Items {
+		labels = append(labels, item.Label)
+	}
+	assert.Equal(t, []string{"--color-accent", "--color-primary"}, labels, "the closest matches are kept")
+}
+
 func TestCompletion_NonCSSDocument(t *testing.T) {
 	ctx := testutil.NewMockServerContext()
 	glspCtx := &glsp.Context{}
@@ -203,6 +238,151 @@ func TestCompletionResolve_AddsDocumentation(t *testing.T) {
 	assert.Contains(t, *resolved.Detail, "#ff0000")
 }
 
+func TestCompletion_NameFormat(t *testing.T) {
+	ctx := testutil.NewMockServerContext()
+	glspCtx := &glsp.Context{}
+	req := types.NewRequestContext(ctx, glspCtx)
+
+	ctx.TokenManager().SetNameFormat(tokens.NameFormat{Separator: "_"})
+	_ = ctx.TokenManager().Add(&tokens.Token{
+		Name:  "color-primary",
+		Path:  []string{"color", "primary"},
+		Value: "#ff0000",
+		Type:  "color",
+	})
+
+	uri := "file:///test.css"
+	_ = ctx.DocumentManager().DidOpen(uri, "css", 1, `.button { color: --col }`)
+
+	result, err := Completion(req, &protocol.CompletionParams{
+		TextDocumentPositionParams: protocol.TextDocumentPositionParams{
+			TextDocument: protocol.TextDocumentIdentifier{URI: uri},
+			Position:     protocol.Position{Line: 0, Character: 20},
+		},
+	})
+	require.NoError(t, err)
+
+	completionList, ok := result.(*protocol.CompletionList)
+	require.True(t, ok)
+	require.Len(t, completionList.Items, 1)
+	assert.Equal(t, "--color_primary", completionList.Items[0].Label)
+
+	resolved, err := CompletionResolve(req, &completionList.Items[0])
+	require.NoError(t, err)
+	doc, ok := resolved.Documentation.(protocol.MarkupContent)
+	require.True(t, ok)
+	assert.Contains(t, doc.Value, "# --color_primary")
+}
+
+func TestCompletion_HidePrefix(t *testing.T) {
+	ctx := testutil.NewMockServerContext()
+	glspCtx := &glsp.Context{}
+	req := types.NewRequestContext(ctx, glspCtx)
+
+	hide := false
+	ctx.SetConfig(types.ServerConfig{ShowPrefix: &hide})
+	_ = ctx.TokenManager().Add(&tokens.Token{
+		Name:   "color-primary",
+		Path:   []string{"color", "primary"},
+		Prefix: "ds",
+		Value:  "#ff0000",
+		Type:   "color",
+	})
+
+	uri := "file:///test.css"
+	_ = ctx.DocumentManager().DidOpen(uri, "css", 1, `.button { color: --ds }`)
+
+	result, err := Completion(req, &protocol.CompletionParams{
+		TextDocumentPositionParams: protocol.TextDocumentPositionParams{
+			TextDocument: protocol.TextDocumentIdentifier{URI: uri},
+			Position:     protocol.Position{Line: 0, Character: 21},
+		},
+	})
+	require.NoError(t, err)
+
+	completionList, ok := result.(*protocol.CompletionList)
+	require.True(t, ok)
+	require.Len(t, completionList.Items, 1)
+
+	item := completionList.Items[0]
+	assert.Equal(t, "--color-primary", item.Label, "label should omit the prefix")
+	require.NotNil(t, item.FilterText)
+	assert.Equal(t, "--ds-color-primary", *item.FilterText)
+	require.NotNil(t, item.InsertText)
+	assert.Equal(t, "var(--ds-color-primary)", *item.InsertText, "insert text should keep the prefix")
+
+	resolved, err := CompletionResolve(req, &item)
+	require.NoError(t, err)
+	doc, ok := resolved.Documentation.(protocol.MarkupContent)
+	require.True(t, ok)
+	assert.Contains(t, doc.Value, "# --color-primary\n")
+}
+
+func TestCompletion_UnprefixedMatch(t *testing.T) {
+	tests := []struct {
+		name       string
+		content    string
+		character  uint32
+		insertText string
+		editStart  uint32
+	}{
+		{
+			name:       "inside var()",
+			content:    `.a { color: var(--color-pr) }`,
+			character:  26,
+			insertText: "--ds-color-primary",
+			editStart:  16,
+		},
+		{
+			name:       "bare word",
+			content:    `.a { color: --color-pr }`,
+			character:  22,
+			insertText: "var(--ds-color-primary)",
+			editStart:  12,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := testutil.NewMockServerContext()
+			req := types.NewRequestContext(ctx, &glsp.Context{})
+			_ = ctx.TokenManager().Add(&tokens.Token{
+				Name:   "color-primary",
+				Path:   []string{"color", "primary"},
+				Prefix: "ds",
+				Value:  "#ff0000",
+				Type:   "color",
+			})
+
+			uri := "file:///test.css"
+			_ = ctx.DocumentManager().DidOpen(uri, "css", 1, tt.content)
+
+			result, err := Completion(req, &protocol.CompletionParams{
+				TextDocumentPositionParams: protocol.TextDocumentPositionParams{
+					TextDocument: protocol.TextDocumentIdentifier{URI: uri},
+					Position:     protocol.Position{Line: 0, Character: tt.character},
+				},
+			})
+			require.NoError(t, err)
+
+			completionList, ok := result.(*protocol.CompletionList)
+			require.True(t, ok)
+			require.Len(t, completionList.Items, 1)
+
+			item := completionList.Items[0]
+			require.NotNil(t, item.FilterText)
+			assert.Equal(t, "--color-primary", *item.FilterText, "filter on the typed, unprefixed name")
+			edit, ok := item.TextEdit.(protocol.TextEdit)
+			require.True(t, ok)
+			assert.Equal(t, tt.insertText, edit.NewText, "insert the prefixed variable")
+			assert.Equal(t, protocol.Range{
+				Start: protocol.Position{Line: 0, Character: tt.editStart},
+				End:   protocol.Position{Line: 0, Character: tt.character},
+			}, edit.Range, "replace the typed fragment")
+		})
+	}
+}
+
 func TestCompletionResolve_DeprecatedToken(t *testing.T) {
 	ctx := testutil.NewMockServerContext()
 	glspCtx := &glsp.Context{}
@@ -234,6 +414,28 @@ func TestCompletionResolve_DeprecatedToken(t *testing.T) {
 	assert.Contains(t, doc.Value, "Use color.primary instead")
 }
 
+func TestCompletionResolve_ValueHistory(t *testing.T) {
+	ctx := testutil.NewMockServerContext()
+	req := types.NewRequestContext(ctx, &glsp.Context{})
+
+	_ = ctx.TokenManager().Add(&tokens.Token{Name: "color-primary", Value: "#0000ff"})
+	ctx.SetValueHistory("color-primary", &gitblame.Annotation{
+		Commit:  "8c1b2f0",
+		Date:    time.Date(2026, 3, 14, 9, 30, 0, 0, time.UTC),
+		Subject: "Darken primary for contrast",
+	})
+
+	resolved, err := CompletionResolve(req, &protocol.CompletionItem{
+		Label: "--color-primary",
+		Data:  map[string]any{"tokenName": "--color-primary"},
+	})
+	require.NoError(t, err)
+
+	doc, ok := resolved.Documentation.(protocol.MarkupContent)
+	require.True(t, ok)
+	assert.Contains(t, doc.Value, "*Last changed 2026-03-14: Darken primary for contrast*")
+}
+
 func TestCompletionResolve_UnknownToken(t *testing.T) {
 	ctx := testutil.NewMockServerContext()
 	glspCtx := &glsp.Context{}
@@ -455,6 +657,24 @@ func TestIsInCompletionContext(t *testing.T) {
 			position: protocol.Position{Line: 0, Character: 15},
 			expected: true,
 		},
+		{
+			name:     "@supports condition",
+			content:  `@supports (color: var(--col`,
+			position: protocol.Position{Line: 0, Character: 27},
+			expected: true,
+		},
+		{
+			name:     "container style query",
+			content:  ".a { color: red; }\n@container style(--col",
+			position: protocol.Position{Line: 1, Character: 22},
+			expected: true,
+		},
+		{
+			name:     "other at-rule prelude",
+			content:  `@layer --col`,
+			position: protocol.Position{Line: 0, Character: 12},
+			expected: false,
+		},
 	}
 
 	for _, tt := range tests {
@@ -642,6 +862,85 @@ func TestCompletion_SnippetSupport(t *testing.T) {
 	})
 }
 
+func TestCompletion_JSStyleStrings(t *testing.T) {
+	tests := []struct {
+		name       string
+		content    string
+		character  uint32
+		insertText string
+	}{
+		{"JSX style value inside var()", "<a style={{ color: 'var(--col' }} />", 29, "--color-primary"},
+		{"JSX style value without var()", "<a style={{ color: '--col' }} />", 25, "var(--color-primary${1:, #ff0000})$0"},
+		{"setProperty name", "el.style.setProperty('--col', v);", 27, "--color-primary"},
+		{"setProperty value inside var()", "el.style.setProperty('--x', 'var(--col');", 37, "--color-primary"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := testutil.NewMockServerContext()
+			ctx.SetSupportsSnippets(true)
+			req := types.NewRequestContext(ctx, &glsp.Context{})
+			_ = ctx.TokenManager().Add(&tokens.Token{Name: "color.primary", Value: "#ff0000"})
+
+			uri := "file:///test.jsx"
+			_ = ctx.DocumentManager().DidOpen(uri, "javascriptreact", 1, tt.content)
+
+			result, err := Completion(req, &protocol.CompletionParams{
+				TextDocumentPositionParams: protocol.TextDocumentPositionParams{
+					TextDocument: protocol.TextDocumentIdentifier{URI: uri},
+					Position:     protocol.Position{Line: 0, Character: tt.character},
+				},
+			})
+			require.NoError(t, err)
+			list, ok := result.(*protocol.CompletionList)
+			require.True(t, ok, "expected completions")
+			require.Len(t, list.Items, 1)
+
+			assert.Equal(t, tt.insertText, *list.Items[0].InsertText)
+		})
+	}
+}
+
+func TestCompletion_ConditionalRules(t *testing.T) {
+	tests := []struct {
+		name       string
+		content    string
+		character  uint32
+		insertText string
+	}{
+		{"@supports condition", "@supports (color: --col", 23, "var(--color-primary${1:, #ff0000})$0"},
+		{"@media block", "@media (min-width: 1px) { .a { color: --col", 43, "var(--color-primary${1:, #ff0000})$0"},
+		{"style query name", "@container style(--col", 22, "--color-primary"},
+		{"nested style query name", "@container card style((--a: 1) and ( --col", 42, "--color-primary"},
+		{"style query value", "@container style(--a: --col", 28, "var(--color-primary${1:, #ff0000})$0"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := testutil.NewMockServerContext()
+			ctx.SetSupportsSnippets(true)
+			req := types.NewRequestContext(ctx, &glsp.Context{})
+			_ = ctx.TokenManager().Add(&tokens.Token{Name: "color.primary", Value: "#ff0000"})
+
+			uri := "file:///test.css"
+			_ = ctx.DocumentManager().DidOpen(uri, "css", 1, tt.content)
+
+			result, err := Completion(req, &protocol.CompletionParams{
+				TextDocumentPositionParams: protocol.TextDocumentPositionParams{
+					TextDocument: protocol.TextDocumentIdentifier{URI: uri},
+					Position:     protocol.Position{Line: 0, Character: tt.character},
+				},
+			})
+			require.NoError(t, err)
+			list, ok := result.(*protocol.CompletionList)
+			require.True(t, ok, "expected completions")
+			require.Len(t, list.Items, 1)
+
+			assert.Equal(t, tt.insertText, *list.Items[0].InsertText)
+		})
+	}
+}
+
 // TestNormalizeTokenName tests the normalizeTokenName helper function
 func TestNormalizeTokenName(t *testing.T) {
 	tests := []struct {
@@ -698,3 +997,77 @@ func TestNormalizeTokenName(t *testing.T) {
 		})
 	}
 }
+
+func TestHasNormalizedPrefix(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		word     string
+		expected bool
+	}{
+		{name: "prefix across dashes", input: "--color-primary", word: "colorpr", expected: true},
+		{name: "whole name", input: "--color-primary", word: "colorprimary", expected: true},
+		{name: "mixed case", input: "--Color-Primary-500", word: "colorprimary5", expected: true},
+		{name: "empty word", input: "--color-primary", word: "", expected: true},
+		{name: "longer than name", input: "--color", word: "colorprimary", expected: false},
+		{name: "different name", input: "--spacing-large", word: "color", expected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, hasNormalizedPrefix(tt.input, tt.word))
+			assert.Equal(t, tt.expected, strings.HasPrefix(normalizeTokenName(tt.input), tt.word))
+		})
+	}
+}
+
+func TestCompletion_DeduplicatesVariants(t *testing.T) {
+	complete := func(t *testing.T, ctx *testutil.MockServerContext, content string) []string {
+		t.Helper()
+		uri := "file:///test.css"
+		require.NoError(t, ctx.DocumentManager().DidOpen(uri, "css", 1, content))
+		result, err := Completion(types.NewRequestContext(ctx, &glsp.Context{}), &protocol.CompletionParams{
+			TextDocumentPositionParams: protocol.TextDocumentPositionParams{
+				TextDocument: protocol.TextDocumentIdentifier{URI: uri},
+				Position:     protocol.Position{Line: 0, Character: uint32(len(content) - 2)},
+			},
+		})
+		require.NoError(t, err)
+		var names []string
+		for _, item := range result.(*protocol.CompletionList).Items {
+			names = append(names, item.Data.(map[string]any)["tokenName"].(string))
+		}
+		return names
+	}
+
+	newContext := func() *testutil.MockServerContext {
+		ctx := testutil.NewMockServerContext()
+		for _, token := range []*tokens.Token{
+			{Name: "color-primary", Value: "#f00", FilePath: "/base.json"},
+			{Name: "color-primary", Value: "#f00", Prefix: "ds", FilePath: "/ds.json"},
+			{Name: "color-primary", Value: "#00f", Prefix: "brand", FilePath: "/brand.json"},
+		} {
+			require.NoError(t, ctx.TokenManager().Add(token))
+		}
+		return ctx
+	}
+
+	t.Run("prefers the project prefix", func(t *testing.T) {
+		ctx := newContext()
+		ctx.SetConfig(types.ServerConfig{Prefix: "ds"})
+		assert.Equal(t, []string{"--ds-color-primary"}, complete(t, ctx, ".a { color: -- }"))
+	})
+
+	t.Run("prefers a prefix the document uses", func(t *testing.T) {
+		ctx := newContext()
+		ctx.SetConfig(types.ServerConfig{Prefix: "ds"})
+		assert.Equal(t, []string{"--brand-color-primary"},
+			complete(t, ctx, ".a { margin: var(--brand-space); color: -- }"))
+	})
+
+	t.Run("keeps distinct tokens", func(t *testing.T) {
+		ctx := newContext()
+		require.NoError(t, ctx.TokenManager().Add(&tokens.Token{Name: "color-secondary", Value: "#0f0"}))
+		assert.Len(t, complete(t, ctx, ".a { color: -- }"), 2)
+	})
+}
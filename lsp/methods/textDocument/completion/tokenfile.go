@@ -0,0 +1,128 @@
+package completion
+
+import (
+	"cmp"
+	"regexp"
+	"slices"
+	"strings"
+
+	"bennypowers.dev/dtls/internal/log"
+	"bennypowers.dev/dtls/internal/position"
+	"bennypowers.dev/dtls/internal/tokens"
+	"bennypowers.dev/dtls/lsp/types"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+var (
+	// aliasStartPattern matches the text before the cursor when it is in a
+	// string value that starts an alias, capturing the path typed so far:
+	// "$value": "{color.br or - '{color.br
+	aliasStartPattern = regexp.MustCompile(`(?:^\s*-|[:,\[])\s*["']\{([^"'{}\s]*)$`)
+
+	// dollarKeyPattern matches a $-prefixed key ending the text before a value,
+	// e.g. "$description":
+	dollarKeyPattern = regexp.MustCompile(`["']?(\$[\pL\pN_-]+)["']?\s*:\s*$`)
+)
+
+// aliasContext returns the alias path typed before the cursor and the byte
+// offsets in the line of the path's start and of the end of the alias after
+// the cursor, including its closing brace. ok is false unless the cursor is
+// in a string which starts an alias in a token's value. Token files which
+// are being typed don't parse, so the line is matched as text.
+func aliasContext(line string, byteOffset int) (partial string, start, end int, ok bool) {
+	before := line[:byteOffset]
+	match := aliasStartPattern.FindStringSubmatchIndex(before)
+	if match == nil {
+		return "", 0, 0, false
+	}
+
+	// Aliases are values, not descriptions or other $-prefixed properties.
+	// Values without a key on the line are composite fields or array items.
+	if key := dollarKeyPattern.FindStringSubmatch(before[:strings.LastIndexAny(before, `"'`)]); key != nil && key[1] != "$value" {
+		return "", 0, 0, false
+	}
+
+	end = byteOffset
+	for end < len(line) && !strings.ContainsRune(`"'{}`, rune(line[end])) && line[end] != ' ' {
+		end++
+	}
+	if end < len(line) && line[end] == '}' {
+		end++
+	}
+	return before[match[2]:match[3]], match[2], end, true
+}
+
+// tokenFileCompletion completes alias references, e.g. {color.brand.primary},
+// in the $value strings of a token file
+func tokenFileCompletion(req *types.RequestContext, content string, pos protocol.Position) (any, error) {
+	lines := strings.Split(strings.ReplaceAll(content, "\r\n", "\n"), "\n")
+	if int(pos.Line) >= len(lines) {
+		return nil, nil
+	}
+	line := lines[pos.Line]
+	byteOffset := min(position.UTF16ToByteOffset(line, int(pos.Character)), len(line))
+
+	partial, start, end, ok := aliasContext(line, byteOffset)
+	if !ok {
+		return nil, nil
+	}
+
+	log.Info("Alias completion path: '%s'", partial)
+
+	// The same path may be loaded more than once, e.g. with two prefixes
+	lowerPartial := strings.ToLower(partial)
+	byPath := map[string]*tokens.Token{}
+	var paths []string
+	for _, token := range req.Server.TokenManager().GetAll() {
+		if len(token.Path) == 0 {
+			continue
+		}
+		path := strings.Join(token.Path, ".")
+		if _, seen := byPath[path]; seen || !strings.HasPrefix(strings.ToLower(path), lowerPartial) {
+			continue
+		}
+		byPath[path] = token
+		paths = append(paths, path)
+	}
+
+	// Like CSS completions, keep the paths which extend the typed path the least
+	slices.SortFunc(paths, func(a, b string) int {
+		return cmp.Or(cmp.Compare(len(a), len(b)), strings.Compare(a, b))
+	})
+	total, limit := len(paths), req.Config().CompletionItemLimit()
+	incomplete := total > limit
+	if incomplete {
+		paths = paths[:limit]
+	}
+
+	editRange := protocol.Range{
+		Start: protocol.Position{Line: pos.Line, Character: position.ByteOffsetToUTF16Uint32(line, start)},
+		End:   protocol.Position{Line: pos.Line, Character: position.ByteOffsetToUTF16Uint32(line, end)},
+	}
+	kind := protocol.CompletionItemKindVariable
+	insertTextFormat := protocol.InsertTextFormatPlainText
+
+	items := make([]protocol.CompletionItem, 0, len(paths))
+	for _, path := range paths {
+		items = append(items, protocol.CompletionItem{
+			Label:            path,
+			Kind:             &kind,
+			InsertTextFormat: &insertTextFormat,
+			TextEdit: protocol.TextEdit{
+				Range:   editRange,
+				NewText: path + "}",
+			},
+			// Documentation is rendered by completionItem/resolve
+			Data: map[string]any{
+				"tokenName": byPath[path].Name,
+			},
+		})
+	}
+
+	log.Info("Returning %d of %d alias completion items", len(items), total)
+
+	return &protocol.CompletionList{
+		IsIncomplete: incomplete,
+		Items:        items,
+	}, nil
+}
@@ -0,0 +1,136 @@
+package completion
+
+import (
+	"testing"
+
+	"bennypowers.dev/dtls/internal/tokens"
+	"bennypowers.dev/dtls/lsp/testutil"
+	"bennypowers.dev/dtls/lsp/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tliron/glsp"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+func TestAliasContext(t *testing.T) {
+	tests := []struct {
+		name    string
+		line    string
+		cursor  int
+		partial string
+		end     int
+		ok      bool
+	}{
+		{name: "JSON $value", line: `"$value": "{co`, cursor: 14, partial: "co", end: 14, ok: true},
+		{name: "empty path", line: `  "$value": "{`, cursor: 14, partial: "", end: 14, ok: true},
+		{name: "dotted path", line: `"$value": "{color.br"`, cursor: 20, partial: "color.br", end: 20, ok: true},
+		{name: "replaces the rest of the alias", line: `"$value": "{color.brand.primary}"`, cursor: 17, partial: "color", end: 32, ok: true},
+		{name: "YAML $value", line: `  $value: '{co`, cursor: 14, partial: "co", end: 14, ok: true},
+		{name: "composite field", line: `      "color": "{co`, cursor: 19, partial: "co", end: 19, ok: true},
+		{name: "array item", line: `"$value": ["Inter", "{fo`, cursor: 24, partial: "fo", end: 24, ok: true},
+		{name: "YAML list item", line: `  - "{fo`, cursor: 8, partial: "fo", end: 8, ok: true},
+		{name: "description", line: `"$description": "{co`, cursor: 20},
+		{name: "key", line: `  "{co`, cursor: 6},
+		{name: "not an alias", line: `"$value": "#f`, cursor: 13},
+		{name: "after the alias", line: `"$value": "{color.primary}`, cursor: 26},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			partial, _, end, ok := aliasContext(tt.line, tt.cursor)
+			assert.Equal(t, tt.ok, ok)
+			if tt.ok {
+				assert.Equal(t, tt.partial, partial)
+				assert.Equal(t, tt.end, end)
+			}
+		})
+	}
+}
+
+func TestCompletion_TokenFileAliases(t *testing.T) {
+	ctx := testutil.NewMockServerContext()
+	req := types.NewRequestContext(ctx, &glsp.Context{})
+
+	for _, token := range []*tokens.Token{
+		{Name: "color-brand-primary", Value: "#0066cc", Type: "color", Path: []string{"color", "brand", "primary"}},
+		{Name: "color-brand-secondary", Value: "#663399", Type: "color", Path: []string{"color", "brand", "secondary"}},
+		{Name: "color-brand-primary", Value: "#0066cc", Type: "color", Prefix: "ds", Path: []string{"color", "brand", "primary"}},
+		{Name: "spacing-small", Value: "8px", Type: "dimension", Path: []string{"spacing", "small"}},
+	} {
+		require.NoError(t, ctx.TokenManager().Add(token))
+	}
+
+	uri := "file:///tokens.json"
+	content := "{\n  \"color\": {\n    \"action\": { \"$type\": \"color\", \"$value\": \"{color.br\" }\n  }\n}"
+	require.NoError(t, ctx.DocumentManager().DidOpen(uri, "json", 1, content))
+
+	result, err := Completion(req, &protocol.CompletionParams{
+		TextDocumentPositionParams: protocol.TextDocumentPositionParams{
+			TextDocument: protocol.TextDocumentIdentifier{URI: uri},
+			Position:     protocol.Position{Line: 2, Character: 54}, // After "{color.br"
+		},
+	})
+	require.NoError(t, err)
+	list, ok := result.(*protocol.CompletionList)
+	require.True(t, ok)
+	require.Len(t, list.Items, 2, "one item per path, matching the typed path")
+
+	item := list.Items[0]
+	assert.Equal(t, "color.brand.primary", item.Label)
+	edit, ok := item.TextEdit.(protocol.TextEdit)
+	require.True(t, ok)
+	assert.Equal(t, "color.brand.primary}", edit.NewText)
+	assert.Equal(t, protocol.Range{
+		Start: protocol.Position{Line: 2, Character: 46},
+		End:   protocol.Position{Line: 2, Character: 54},
+	}, edit.Range)
+	assert.Equal(t, "color.brand.secondary", list.Items[1].Label)
+
+	t.Run("resolve adds documentation", func(t *testing.T) {
+		resolved, err := CompletionResolve(req, &item)
+		require.NoError(t, err)
+		doc, ok := resolved.Documentation.(protocol.MarkupContent)
+		require.True(t, ok)
+		assert.Contains(t, doc.Value, "#0066cc")
+	})
+
+	t.Run("outside an alias", func(t *testing.T) {
+		result, err := Completion(req, &protocol.CompletionParams{
+			TextDocumentPositionParams: protocol.TextDocumentPositionParams{
+				TextDocument: protocol.TextDocumentIdentifier{URI: uri},
+				Position:     protocol.Position{Line: 2, Character: 10}, // In "action"
+			},
+		})
+		require.NoError(t, err)
+		assert.Nil(t, result)
+	})
+
+	t.Run("YAML", func(t *testing.T) {
+		yamlURI := "file:///tokens.yaml"
+		require.NoError(t, ctx.DocumentManager().DidOpen(yamlURI, "yaml", 1, "gap:\n  $value: '{sp"))
+		result, err := Completion(req, &protocol.CompletionParams{
+			TextDocumentPositionParams: protocol.TextDocumentPositionParams{
+				TextDocument: protocol.TextDocumentIdentifier{URI: yamlURI},
+				Position:     protocol.Position{Line: 1, Character: 13},
+			},
+		})
+		require.NoError(t, err)
+		list, ok := result.(*protocol.CompletionList)
+		require.True(t, ok)
+		require.Len(t, list.Items, 1)
+		assert.Equal(t, "spacing.small", list.Items[0].Label)
+	})
+
+	t.Run("files which aren't token files", func(t *testing.T) {
+		ctx.ShouldProcessAsTokenFileFunc = func(string) bool { return false }
+		defer func() { ctx.ShouldProcessAsTokenFileFunc = nil }()
+		result, err := Completion(req, &protocol.CompletionParams{
+			TextDocumentPositionParams: protocol.TextDocumentPositionParams{
+				TextDocument: protocol.TextDocumentIdentifier{URI: uri},
+				Position:     protocol.Position{Line: 2, Character: 54},
+			},
+		})
+		require.NoError(t, err)
+		assert.Nil(t, result)
+	})
+}
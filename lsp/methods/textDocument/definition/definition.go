@@ -5,7 +5,7 @@ import (
 	"fmt"
 
 	"bennypowers.dev/dtls/internal/parser"
-	"bennypowers.dev/dtls/internal/parser/css"
+	csshelpers "bennypowers.dev/dtls/lsp/helpers/css"
 	"bennypowers.dev/dtls/lsp/types"
 	protocol "github.com/tliron/glsp/protocol_3_16"
 )
@@ -24,6 +24,7 @@ func Definition(req *types.RequestContext, params *protocol.DefinitionParams) (a
 	if doc == nil {
 		return nil, nil
 	}
+	req.TraceDocument(doc)
 
 	// Handle token files (JSON/YAML)
 	if doc.LanguageID() == "json" || doc.LanguageID() == "yaml" {
@@ -46,75 +47,48 @@ func Definition(req *types.RequestContext, params *protocol.DefinitionParams) (a
 	if result == nil {
 		return nil, nil
 	}
+	req.TraceVarCalls(len(result.VarCalls))
 
-	// Find var() call at the cursor position
-	for _, varCall := range result.VarCalls {
-		if isPositionInVarCall(position, varCall) {
-			// Look up the token
-			token := req.Server.Token(varCall.TokenName)
-			if token == nil {
-				// Unknown token
-				return nil, nil
-			}
-
-			// Return the definition location in the token file
-			if token.DefinitionURI != "" && len(token.Path) > 0 {
-				targetRange := protocol.Range{
-					Start: protocol.Position{Line: token.Line, Character: token.Character},
-					End:   protocol.Position{Line: token.Line, Character: token.Character},
-				}
-
-				log.Info("Found definition for %s in %s at line %d, char %d",
-					varCall.TokenName, token.DefinitionURI, token.Line, token.Character)
-
-				// Return LocationLink when client supports it (includes origin selection range)
-				if req.Server.SupportsDefinitionLinks() {
-					originRange := protocol.Range{
-						Start: protocol.Position{
-							Line:      varCall.Range.Start.Line,
-							Character: varCall.Range.Start.Character,
-						},
-						End: protocol.Position{
-							Line:      varCall.Range.End.Line,
-							Character: varCall.Range.End.Character,
-						},
-					}
-					return []protocol.LocationLink{{
-						OriginSelectionRange: &originRange,
-						TargetURI:            protocol.DocumentUri(token.DefinitionURI),
-						TargetRange:          targetRange,
-						TargetSelectionRange: targetRange,
-					}}, nil
-				}
-
-				// Return Location for legacy clients
-				return []protocol.Location{{
-					URI:   token.DefinitionURI,
-					Range: targetRange,
-				}}, nil
-			}
-
-			return nil, nil
-		}
+	// Find the innermost var() call at the cursor position
+	varCall := csshelpers.VarCallAt(position, result.VarCalls)
+	if varCall == nil {
+		return nil, nil
 	}
 
-	return nil, nil
-}
-
-// isPositionInVarCall checks if a position is within a var() call
-func isPositionInVarCall(pos protocol.Position, varCall *css.VarCall) bool {
-	// Check if position is within the var call range
-	if pos.Line < varCall.Range.Start.Line || pos.Line > varCall.Range.End.Line {
-		return false
+	// Look up the token
+	token := req.Server.Token(varCall.TokenName)
+	if token == nil {
+		// Unknown token
+		return nil, nil
 	}
 
-	if pos.Line == varCall.Range.Start.Line && pos.Character < varCall.Range.Start.Character {
-		return false
-	}
+	// Return the definition location in the token file
+	if token.DefinitionURI != "" && len(token.Path) > 0 {
+		targetRange := protocol.Range{
+			Start: protocol.Position{Line: token.Line, Character: token.Character},
+			End:   protocol.Position{Line: token.Line, Character: token.Character},
+		}
 
-	if pos.Line == varCall.Range.End.Line && pos.Character >= varCall.Range.End.Character {
-		return false
+		log.Info("Found definition for %s in %s at line %d, char %d",
+			varCall.TokenName, token.DefinitionURI, token.Line, token.Character)
+
+		// Return LocationLink when client supports it (includes origin selection range)
+		if req.Server.SupportsDefinitionLinks() {
+			originRange := csshelpers.ToProtocolRange(varCall.Range)
+			return []protocol.LocationLink{{
+				OriginSelectionRange: &originRange,
+				TargetURI:            protocol.DocumentUri(token.DefinitionURI),
+				TargetRange:          targetRange,
+				TargetSelectionRange: targetRange,
+			}}, nil
+		}
+
+		// Return Location for legacy clients
+		return []protocol.Location{{
+			URI:   token.DefinitionURI,
+			Range: targetRange,
+		}}, nil
 	}
 
-	return true
+	return nil, nil
 }
@@ -3,7 +3,6 @@ package definition
 import (
 	"testing"
 
-	"bennypowers.dev/dtls/internal/parser/css"
 	"bennypowers.dev/dtls/internal/tokens"
 	"bennypowers.dev/dtls/lsp/testutil"
 	"bennypowers.dev/dtls/lsp/types"
@@ -199,82 +198,43 @@ func TestDefinition_PreciseTokenPosition(t *testing.T) {
 	assert.Equal(t, uint32(4), locations[0].Range.Start.Character, "Should jump to character 4")
 }
 
-// TestIsPositionInVarCall tests the isPositionInVarCall function with half-open range semantics [start, end)
-func TestIsPositionInVarCall(t *testing.T) {
-	tests := []struct {
-		varCall  *css.VarCall
-		name     string
-		pos      protocol.Position
-		expected bool
-	}{
-		{
-			name: "position at start boundary - included",
-			pos:  protocol.Position{Line: 0, Character: 10},
-			varCall: &css.VarCall{
-				TokenName: "color-primary",
-				Range: css.Range{
-					Start: css.Position{Line: 0, Character: 10},
-					End:   css.Position{Line: 0, Character: 30},
-				},
-			},
-			expected: true, // Start is inclusive
-		},
-		{
-			name: "position at end boundary - excluded",
-			pos:  protocol.Position{Line: 0, Character: 30},
-			varCall: &css.VarCall{
-				TokenName: "color-primary",
-				Range: css.Range{
-					Start: css.Position{Line: 0, Character: 10},
-					End:   css.Position{Line: 0, Character: 30},
-				},
-			},
-			expected: false, // End is exclusive in half-open range [start, end)
-		},
-		{
-			name: "position before var call",
-			pos:  protocol.Position{Line: 0, Character: 9},
-			varCall: &css.VarCall{
-				TokenName: "color-primary",
-				Range: css.Range{
-					Start: css.Position{Line: 0, Character: 10},
-					End:   css.Position{Line: 0, Character: 30},
-				},
-			},
-			expected: false,
-		},
-		{
-			name: "position after var call",
-			pos:  protocol.Position{Line: 0, Character: 31},
-			varCall: &css.VarCall{
-				TokenName: "color-primary",
-				Range: css.Range{
-					Start: css.Position{Line: 0, Character: 10},
-					End:   css.Position{Line: 0, Character: 30},
-				},
-			},
-			expected: false,
-		},
-		{
-			name: "position inside var call",
-			pos:  protocol.Position{Line: 0, Character: 20},
-			varCall: &css.VarCall{
-				TokenName: "color-primary",
-				Range: css.Range{
-					Start: css.Position{Line: 0, Character: 10},
-					End:   css.Position{Line: 0, Character: 30},
-				},
-			},
-			expected: true,
-		},
+func TestDefinition_NestedVarCall(t *testing.T) {
+	ctx := testutil.NewMockServerContext()
+	req := types.NewRequestContext(ctx, &glsp.Context{})
+
+	for i, name := range []string{"color-outer", "color-inner"} {
+		_ = ctx.TokenManager().Add(&tokens.Token{
+			Name:          name,
+			Value:         "#ff0000",
+			DefinitionURI: "file:///tokens.json",
+			Line:          uint32(i),
+			Path:          []string{name},
+		})
 	}
 
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			result := isPositionInVarCall(tt.pos, tt.varCall)
-			assert.Equal(t, tt.expected, result, "isPositionInVarCall(%+v, %+v)", tt.pos, tt.varCall)
+	uri := "file:///test.css"
+	_ = ctx.DocumentManager().DidOpen(uri, "css", 1, `.a { color: var(--color-outer, var(--color-inner)); }`)
+
+	definitionAt := func(char uint32) []protocol.Location {
+		result, err := Definition(req, &protocol.DefinitionParams{
+			TextDocumentPositionParams: protocol.TextDocumentPositionParams{
+				TextDocument: protocol.TextDocumentIdentifier{URI: uri},
+				Position:     protocol.Position{Line: 0, Character: char},
+			},
 		})
+		require.NoError(t, err)
+		locations, _ := result.([]protocol.Location)
+		return locations
 	}
+
+	// On the inner call, the innermost token wins, as in hover
+	inner := definitionAt(36)
+	require.Len(t, inner, 1)
+	assert.Equal(t, uint32(1), inner[0].Range.Start.Line)
+
+	outer := definitionAt(16)
+	require.Len(t, outer, 1)
+	assert.Equal(t, uint32(0), outer[0].Range.Start.Line)
 }
 
 func TestDefinition_LinkSupport(t *testing.T) {
@@ -426,6 +386,38 @@ func TestDefinition_HTMLDocument(t *testing.T) {
 	assert.Equal(t, "file:///tokens.json", locations[0].URI)
 }
 
+func TestDefinition_JSPropertyName(t *testing.T) {
+	ctx := testutil.NewMockServerContext()
+	glspCtx := &glsp.Context{}
+	req := types.NewRequestContext(ctx, glspCtx)
+
+	_ = ctx.TokenManager().Add(&tokens.Token{
+		Name:          "color.primary",
+		Value:         "#ff0000",
+		DefinitionURI: "file:///tokens.json",
+		Path:          []string{"color", "primary"},
+	})
+
+	uri := "file:///test.js"
+	content := "const v = getComputedStyle(el).getPropertyValue('--color-primary');"
+	_ = ctx.DocumentManager().DidOpen(uri, "javascript", 1, content)
+
+	// Character 55 is inside the '--color-primary' literal
+	result, err := Definition(req, &protocol.DefinitionParams{
+		TextDocumentPositionParams: protocol.TextDocumentPositionParams{
+			TextDocument: protocol.TextDocumentIdentifier{URI: uri},
+			Position:     protocol.Position{Line: 0, Character: 55},
+		},
+	})
+	require.NoError(t, err)
+	require.NotNil(t, result)
+
+	locations, ok := result.([]protocol.Location)
+	require.True(t, ok)
+	require.Len(t, locations, 1)
+	assert.Equal(t, "file:///tokens.json", locations[0].URI)
+}
+
 func TestDefinition_HTMLNoCSS(t *testing.T) {
 	ctx := testutil.NewMockServerContext()
 	glspCtx := &glsp.Context{}
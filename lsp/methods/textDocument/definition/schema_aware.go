@@ -8,6 +8,7 @@ import (
 	"bennypowers.dev/dtls/internal/documents"
 	"bennypowers.dev/dtls/internal/parser/common"
 	posutil "bennypowers.dev/dtls/internal/position"
+	"bennypowers.dev/dtls/internal/tokens"
 	"bennypowers.dev/dtls/internal/uriutil"
 	"bennypowers.dev/dtls/lsp/types"
 	protocol "github.com/tliron/glsp/protocol_3_16"
@@ -103,17 +104,12 @@ func findJSONPointerReferenceAtPosition(line string, pos protocol.Position) stri
 	return ""
 }
 
-// getLineText retrieves the text of a specific line from a URI.
-// First checks the document manager (for open files), then falls back to reading from disk.
-func getLineText(req *types.RequestContext, uri string, lineNum uint32) (string, error) {
+// fileLines returns the lines of a file, from the document manager for open
+// files and from disk otherwise
+func fileLines(req *types.RequestContext, uri string) ([]string, error) {
 	// Try to get from document manager first (for open files)
 	if doc := req.Server.DocumentManager().Get(uri); doc != nil {
-		content := normalizeLineEndings(doc.Content())
-		lines := strings.Split(content, "\n")
-		if int(lineNum) < len(lines) {
-			return lines[lineNum], nil
-		}
-		return "", nil
+		return strings.Split(normalizeLineEndings(doc.Content()), "\n"), nil
 	}
 
 	// Fall back to reading from disk
@@ -121,16 +117,9 @@ func getLineText(req *types.RequestContext, uri string, lineNum uint32) (string,
 	filePath := filepath.Clean(uriutil.URIToPath(uri))
 	data, err := os.ReadFile(filePath)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
-
-	content := normalizeLineEndings(string(data))
-	lines := strings.Split(content, "\n")
-	if int(lineNum) < len(lines) {
-		return lines[lineNum], nil
-	}
-
-	return "", nil
+	return strings.Split(normalizeLineEndings(string(data)), "\n"), nil
 }
 
 // DefinitionForTokenFile handles go-to-definition for references within token files
@@ -147,21 +136,49 @@ func DefinitionForTokenFile(req *types.RequestContext, doc *documents.Document,
 		return nil, nil
 	}
 
-	// Return the definition location
-	if token.DefinitionURI != "" && len(token.Path) > 0 {
+	location := TokenLocation(req, token)
+	if location == nil {
+		return nil, nil
+	}
+	return []protocol.Location{*location}, nil
+}
+
+// TokenLocation returns the location of a token's name where it is defined,
+// or nil when the token has no definition in a token file
+func TokenLocation(req *types.RequestContext, token *tokens.Token) *protocol.Location {
+	return TokenLocator(req)(token)
+}
+
+// TokenLocator returns a function which locates tokens like TokenLocation,
+// reading each token file once, for locating many tokens
+func TokenLocator(req *types.RequestContext) func(token *tokens.Token) *protocol.Location {
+	files := map[string][]string{}
+	return func(token *tokens.Token) *protocol.Location {
+		if token.DefinitionURI == "" || len(token.Path) == 0 {
+			return nil
+		}
+
+		lines, ok := files[token.DefinitionURI]
+		if !ok {
+			lines, _ = fileLines(req, token.DefinitionURI)
+			files[token.DefinitionURI] = lines
+		}
+
 		// Get the line text where the token is defined
 		// token.Character is a byte offset, so we need to convert to UTF-16
-		lineText, err := getLineText(req, token.DefinitionURI, token.Line)
-		if err != nil || lineText == "" {
+		var lineText string
+		if int(token.Line) < len(lines) {
+			lineText = lines[token.Line]
+		}
+		if lineText == "" {
 			// If we can't get the line text, fall back to zero-width range
-			location := protocol.Location{
+			return &protocol.Location{
 				URI: token.DefinitionURI,
 				Range: protocol.Range{
 					Start: protocol.Position{Line: token.Line, Character: 0},
 					End:   protocol.Position{Line: token.Line, Character: 0},
 				},
 			}
-			return []protocol.Location{location}, nil
 		}
 
 		// Convert byte offset to UTF-16 position
@@ -171,16 +188,12 @@ func DefinitionForTokenFile(req *types.RequestContext, doc *documents.Document,
 		tokenNameLenUTF16 := posutil.StringLengthUTF16Uint32(token.Name)
 		endCharUTF16 := startCharUTF16 + tokenNameLenUTF16
 
-		location := protocol.Location{
+		return &protocol.Location{
 			URI: token.DefinitionURI,
 			Range: protocol.Range{
 				Start: protocol.Position{Line: token.Line, Character: startCharUTF16},
 				End:   protocol.Position{Line: token.Line, Character: endCharUTF16},
 			},
 		}
-
-		return []protocol.Location{location}, nil
 	}
-
-	return nil, nil
 }
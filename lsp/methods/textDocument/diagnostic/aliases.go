@@ -0,0 +1,202 @@
+package diagnostic
+
+import (
+	"errors"
+	"strings"
+
+	"bennypowers.dev/dtls/internal/documents"
+	"bennypowers.dev/dtls/internal/i18n"
+	"bennypowers.dev/dtls/internal/parser/common"
+	"bennypowers.dev/dtls/internal/parser/tokenfile"
+	"bennypowers.dev/dtls/internal/position"
+	"bennypowers.dev/dtls/internal/tokens"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+	"gopkg.in/yaml.v3"
+)
+
+// aliasToken is an alias in a token file, as the document has it, which may
+// be ahead of the loaded tokens
+type aliasToken struct {
+	token *tokens.Token
+	// node is the scalar holding the alias, which diagnostics cover
+	node *yaml.Node
+}
+
+// reference is a token path named in a token file's values, such as
+// color.primary in "{color.primary}" or "#/color/primary"
+type reference struct {
+	path string
+	node *yaml.Node
+	// start and end are the byte offsets of the reference in the scalar, or
+	// both 0 when it is the whole scalar
+	start, end int
+}
+
+// aliasDiagnostics reports the references in a token file which don't name
+// a token, and the aliases which never reach a concrete value because they
+// are circular, e.g. {color.a} aliasing {color.b} aliasing {color.a}, or lead
+// into a cycle. References to tokens in other files follow the loaded tokens.
+func aliasDiagnostics(locale string, doc *documents.Document, manager *tokens.Manager) []protocol.Diagnostic {
+	diagnostics := []protocol.Diagnostic{}
+	root := tokenfile.Outline(doc.Content(), doc.LanguageID() != "yaml")
+	if root == nil {
+		return diagnostics
+	}
+
+	local := map[string]*tokens.Token{}
+	var aliases []aliasToken
+	var references []reference
+	var walk func(node *yaml.Node, path []string)
+	walk = func(node *yaml.Node, path []string) {
+		if node.Kind != yaml.MappingNode {
+			return
+		}
+		if token, alias := documentToken(node, path); token != nil {
+			local[tokens.TokenPath(strings.Join(path, "."))] = token
+			if alias != nil {
+				aliases = append(aliases, aliasToken{token: token, node: alias})
+			}
+			if ref := tokenfile.MappingValue(node, "$ref"); ref != nil && ref.Kind == yaml.ScalarNode {
+				if target := pointerPath(ref.Value); target != "" {
+					references = append(references, reference{path: target, node: ref})
+				}
+			}
+			if value := tokenfile.MappingValue(node, "$value"); value != nil {
+				references = appendReferences(references, value)
+			}
+		}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key := node.Content[i].Value
+			// $root is a token; other keywords hold data rather than tokens
+			if strings.HasPrefix(key, "$") && key != "$root" {
+				continue
+			}
+			walk(node.Content[i+1], append(path[:len(path):len(path)], key))
+		}
+	}
+	walk(root, nil)
+
+	lookup := func(path string) *tokens.Token {
+		if token, ok := local[path]; ok {
+			return token
+		}
+		return manager.Get(path)
+	}
+
+	lines := strings.Split(doc.Content(), "\n")
+	for _, ref := range references {
+		if lookup(ref.path) != nil {
+			continue
+		}
+		severity := protocol.DiagnosticSeverityError
+		diagnostics = append(diagnostics, protocol.Diagnostic{
+			Range:    referenceRange(lines, ref),
+			Severity: &severity,
+			Message:  i18n.Sprintf(locale, "Unknown design token %s", ref.path),
+		})
+	}
+
+	for _, alias := range aliases {
+		var cycle *tokens.CircularReferenceError
+		if _, err := tokens.AliasChain(alias.token, lookup); !errors.As(err, &cycle) {
+			continue
+		}
+		severity := protocol.DiagnosticSeverityError
+		diagnostics = append(diagnostics, protocol.Diagnostic{
+			Range:    scalarRange(lines, alias.node),
+			Severity: &severity,
+			Message:  i18n.Sprintf(locale, "Circular reference: %s", strings.Join(cycle.Cycle, " → ")),
+		})
+	}
+	return diagnostics
+}
+
+// appendReferences appends the references in a token's value: the
+// "{color.primary}" references in its strings, however deeply nested, as in
+// composite values and expressions, and its $ref pointers
+func appendReferences(references []reference, node *yaml.Node) []reference {
+	switch node.Kind {
+	case yaml.ScalarNode:
+		if node.Tag != "!!str" {
+			return references
+		}
+		for _, match := range common.CurlyBraceReferenceRegexp.FindAllStringSubmatchIndex(node.Value, -1) {
+			references = append(references, reference{
+				path:  tokens.TokenPath(node.Value[match[2]:match[3]]),
+				node:  node,
+				start: match[0],
+				end:   match[1],
+			})
+		}
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			if key.Value == "$ref" && value.Kind == yaml.ScalarNode {
+				if path := pointerPath(value.Value); path != "" {
+					references = append(references, reference{path: path, node: value})
+				}
+				continue
+			}
+			references = appendReferences(references, value)
+		}
+	case yaml.SequenceNode:
+		for _, item := range node.Content {
+			references = appendReferences(references, item)
+		}
+	}
+	return references
+}
+
+// pointerPath returns the path of the token a JSON pointer in this document
+// points into, e.g. color.primary for "#/color/primary/$value/components/0",
+// or empty for pointers into other documents
+func pointerPath(pointer string) string {
+	if !strings.HasPrefix(pointer, "#/") {
+		return ""
+	}
+	var path []string
+	for _, segment := range strings.Split(strings.TrimPrefix(pointer, "#/"), "/") {
+		if strings.HasPrefix(segment, "$") {
+			break
+		}
+		path = append(path, segment)
+	}
+	return strings.Join(path, ".")
+}
+
+// referenceRange returns the range of a reference: the whole scalar holding
+// it, or its part of a single-line scalar
+func referenceRange(lines []string, ref reference) protocol.Range {
+	r := scalarRange(lines, ref.node)
+	if ref.end == 0 || strings.Contains(ref.node.Value, "\n") {
+		return r
+	}
+	r.Start.Character += position.StringLengthUTF16Uint32(ref.node.Value[:ref.start])
+	r.End.Character = r.Start.Character + position.StringLengthUTF16Uint32(ref.node.Value[ref.start:ref.end])
+	return r
+}
+
+// documentToken returns the token a token file mapping defines, if it is
+// one, and the scalar holding its alias, if it is an alias: a $value such as
+// "{color.primary}" or a $ref such as "#/color/primary"
+func documentToken(node *yaml.Node, path []string) (*tokens.Token, *yaml.Node) {
+	if value := tokenfile.MappingValue(node, "$value"); value != nil {
+		token := &tokens.Token{Path: path}
+		if value.Kind == yaml.ScalarNode {
+			token.RawValue = value.Value
+			if tokens.AliasTarget(token) != "" {
+				return token, value
+			}
+			return token, nil
+		}
+		if ref := tokenfile.MappingValue(value, "$ref"); ref != nil && ref.Kind == yaml.ScalarNode {
+			token.RawValue = map[string]any{"$ref": ref.Value}
+			return token, ref
+		}
+		return token, nil
+	}
+	if ref := tokenfile.MappingValue(node, "$ref"); ref != nil && ref.Kind == yaml.ScalarNode {
+		return &tokens.Token{Path: path, RawValue: map[string]any{"$ref": ref.Value}}, ref
+	}
+	return nil, nil
+}
@@ -0,0 +1,68 @@
+package diagnostic
+
+import (
+	"strings"
+
+	"bennypowers.dev/dtls/internal/i18n"
+	cssparser "bennypowers.dev/dtls/internal/parser/css"
+	"bennypowers.dev/dtls/lsp/helpers"
+	csshelpers "bennypowers.dev/dtls/lsp/helpers/css"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+// annotationDiagnostics warns about token annotation comments, e.g.
+// /* token: color.primary */, which name an unknown token or a token the
+// annotated declaration's var() calls don't use. Declarations without var()
+// calls, such as generated custom properties, are not checked.
+func annotationDiagnostics(ctx Context, lookup tokenLookup, result *cssparser.ParseResult) []protocol.Diagnostic {
+	var diagnostics []protocol.Diagnostic
+	for _, annotation := range result.Annotations {
+		token := lookup.token(annotation.TokenName)
+		if token == nil {
+			if lookup.vendor.Owns(annotation.TokenName) {
+				continue
+			}
+			diagnostics = append(diagnostics, annotationDiagnostic(annotation,
+				i18n.Sprintf(ctx.Locale(), "Unknown design token %s", annotation.TokenName)))
+			continue
+		}
+		if annotation.Declaration == nil {
+			continue
+		}
+
+		var used []string
+		matched := false
+		for _, call := range result.VarCalls {
+			if call.Alias != "" || !rangeWithin(call.Range, *annotation.Declaration) {
+				continue
+			}
+			if lookup.token(call.TokenName) == token {
+				matched = true
+				break
+			}
+			used = append(used, call.TokenName)
+		}
+		if !matched && len(used) > 0 {
+			diagnostics = append(diagnostics, annotationDiagnostic(annotation,
+				i18n.Sprintf(ctx.Locale(), "Annotated token %s does not match the declaration, which uses %s",
+					annotation.TokenName, strings.Join(used, ", "))))
+		}
+	}
+	return diagnostics
+}
+
+// annotationDiagnostic creates a warning on an annotation's token name
+func annotationDiagnostic(annotation *cssparser.Annotation, message string) protocol.Diagnostic {
+	severity := protocol.DiagnosticSeverityWarning
+	return protocol.Diagnostic{
+		Range:    csshelpers.ToProtocolRange(annotation.Range),
+		Severity: &severity,
+		Message:  message,
+	}
+}
+
+// rangeWithin reports whether inner lies within outer
+func rangeWithin(inner, outer cssparser.Range) bool {
+	in, out := csshelpers.ToProtocolRange(inner), csshelpers.ToProtocolRange(outer)
+	return helpers.ComparePositions(in.Start, out.Start) >= 0 && helpers.ComparePositions(in.End, out.End) <= 0
+}
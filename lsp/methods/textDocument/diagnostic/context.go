@@ -0,0 +1,26 @@
+package diagnostic
+
+import (
+	"bennypowers.dev/dtls/internal/documents"
+	"bennypowers.dev/dtls/internal/tokens"
+	"bennypowers.dev/dtls/lsp/types"
+)
+
+// Context is what diagnosing a document needs from the server: its tokens,
+// configuration, and knowledge of the workspace's files. types.ServerContext
+// satisfies it; pkg/analyzer provides it without running a language server.
+type Context interface {
+	Document(uri string) *documents.Document
+	Token(name string) *tokens.Token
+	TokenManager() *tokens.Manager
+	// VendorTokens returns the tokens of the node_modules package containing
+	// path, or nil when path is not in node_modules
+	VendorTokens(path string) *types.VendorTokens
+	GetConfig() types.ServerConfig
+	// Locale is the locale diagnostic messages are written in, or empty for English
+	Locale() string
+	IsCustomPropertiesFile(path string) bool
+	CustomPropertiesFiles() []string
+	ShouldProcessAsTokenFile(uri string) bool
+	SupportsDiagnosticRelatedInfo() bool
+}
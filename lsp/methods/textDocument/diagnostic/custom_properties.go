@@ -0,0 +1,108 @@
+package diagnostic
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"bennypowers.dev/dtls/internal/i18n"
+	"bennypowers.dev/dtls/internal/log"
+	"bennypowers.dev/dtls/internal/parser"
+	cssparser "bennypowers.dev/dtls/internal/parser/css"
+	"bennypowers.dev/dtls/internal/uriutil"
+	"bennypowers.dev/dtls/lsp/helpers/css"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+// staleCustomPropertyDiagnostics warns about properties in a generated
+// postcss-custom-properties file whose values no longer match the token files,
+// meaning the file needs to be regenerated.
+func staleCustomPropertyDiagnostics(ctx Context, content, path string, variables []*cssparser.Variable) []protocol.Diagnostic {
+	var diagnostics []protocol.Diagnostic
+
+	for _, v := range variables {
+		expected, stale := css.StaleCustomPropertyValue(ctx.Token(v.Name), path, css.RangeText(content, v.ValueRange))
+		if !stale {
+			continue
+		}
+
+		severity := protocol.DiagnosticSeverityWarning
+		diagnostics = append(diagnostics, protocol.Diagnostic{
+			Range: protocol.Range{
+				Start: protocol.Position{
+					Line:      v.ValueRange.Start.Line,
+					Character: v.ValueRange.Start.Character,
+				},
+				End: protocol.Position{
+					Line:      v.ValueRange.End.Line,
+					Character: v.ValueRange.End.Character,
+				},
+			},
+			Severity: &severity,
+			Message:  i18n.Sprintf(ctx.Locale(), "%s is stale: the token value is %s", v.Name, expected),
+		})
+	}
+
+	return diagnostics
+}
+
+// maxMissingNames caps the names listed in a missing declarations diagnostic
+const maxMissingNames = 5
+
+// missingCustomPropertiesDiagnostic warns, at the top of a generated custom
+// properties file, about tokens that none of the generated files declare,
+// meaning the build is out of date. Declarations in the other configured
+// files count, since builds often split output across files.
+func missingCustomPropertiesDiagnostic(ctx Context, path string, variables []*cssparser.Variable) *protocol.Diagnostic {
+	declared := map[string]bool{}
+	for _, v := range variables {
+		declared[v.Name] = true
+	}
+	for _, other := range ctx.CustomPropertiesFiles() {
+		if other == filepath.Clean(path) {
+			continue
+		}
+		for _, v := range generatedDeclarations(ctx, other) {
+			declared[v.Name] = true
+		}
+	}
+
+	missing := css.MissingCustomProperties(ctx.TokenManager(), declared, ctx.IsCustomPropertiesFile)
+	if len(missing) == 0 {
+		return nil
+	}
+
+	names := missing
+	more := ""
+	if len(names) > maxMissingNames {
+		names = names[:maxMissingNames]
+		more = i18n.Sprintf(ctx.Locale(), ", and %d more", len(missing)-maxMissingNames)
+	}
+	severity := protocol.DiagnosticSeverityWarning
+	return &protocol.Diagnostic{
+		Severity: &severity,
+		Message:  i18n.Sprintf(ctx.Locale(), "Generated output is missing %d tokens: %s%s", len(missing), strings.Join(names, ", "), more),
+	}
+}
+
+// generatedDeclarations returns the custom properties declared in a generated
+// file, reading the open document or the file on disk
+func generatedDeclarations(ctx Context, path string) []*cssparser.Variable {
+	var content string
+	if doc := ctx.Document(uriutil.PathToURI(path)); doc != nil {
+		content = doc.Content()
+	} else {
+		data, err := os.ReadFile(path) //nolint:gosec // G304: Custom properties file paths come from user configuration
+		if err != nil {
+			log.Warn("Failed to read custom properties file %s: %v", path, err)
+			return nil
+		}
+		content = string(data)
+	}
+
+	result, err := parser.ParseCSSFromDocument(content, "css")
+	if err != nil || result == nil {
+		return nil
+	}
+	return result.Variables
+}
@@ -1,11 +1,17 @@
 package diagnostic
 
 import (
+	"bennypowers.dev/dtls/internal/documents"
+	"bennypowers.dev/dtls/internal/i18n"
 	"bennypowers.dev/dtls/internal/log"
+	"bennypowers.dev/dtls/internal/metrics"
 	"fmt"
-	"strings"
 
 	"bennypowers.dev/dtls/internal/parser"
+	cssparser "bennypowers.dev/dtls/internal/parser/css"
+	"bennypowers.dev/dtls/internal/parser/tokenfile"
+	"bennypowers.dev/dtls/internal/uriutil"
+	csshelpers "bennypowers.dev/dtls/lsp/helpers/css"
 	"bennypowers.dev/dtls/lsp/types"
 	protocol "github.com/tliron/glsp/protocol_3_16"
 )
@@ -18,28 +24,80 @@ func DocumentDiagnostic(req *types.RequestContext, params *DocumentDiagnosticPar
 	uri := params.TextDocument.URI
 	log.Info("Pull diagnostics requested for: %s", uri)
 
-	diagnostics, err := GetDiagnostics(req.Server, uri)
+	// Diagnose under the request's configuration snapshot
+	doc := req.Server.Document(uri)
+	diagnostics := []protocol.Diagnostic{}
+	var err error
+	if doc != nil {
+		diagnostics, err = DocumentDiagnostics(req.Server, req.Config(), doc)
+	}
 	if err != nil {
 		log.Info("Error getting diagnostics: %v", err)
 		return nil, err
 	}
+	req.TraceDocument(doc)
+
+	// If the diagnostics are identical to the ones the client already has,
+	// send an unchanged report instead of the full list
+	resultID := req.Server.DiagnosticResultCache().Store(uri, Fingerprint(diagnostics))
+	if params.PreviousResultID != "" {
+		metrics.CacheLookup("diagnostics", params.PreviousResultID == resultID)
+	}
+	if params.PreviousResultID != "" && params.PreviousResultID == resultID {
+		return RelatedUnchangedDocumentDiagnosticReport{
+			Kind:     string(DiagnosticUnchanged),
+			ResultID: resultID,
+		}, nil
+	}
 
 	// Return a full document diagnostic report
 	return RelatedFullDocumentDiagnosticReport{
-		Kind:  string(DiagnosticFull),
-		Items: diagnostics,
+		Kind:     string(DiagnosticFull),
+		ResultID: resultID,
+		Items:    diagnostics,
 	}, nil
 }
 
 // GetDiagnostics returns diagnostics for a document
 // Always returns a non-nil array (empty if no diagnostics) to conform to LSP protocol.
 // Returning nil would serialize to JSON null which crashes some LSP clients like Neovim.
-func GetDiagnostics(ctx types.ServerContext, uri string) ([]protocol.Diagnostic, error) {
-	// Get document
+func GetDiagnostics(ctx Context, uri string) ([]protocol.Diagnostic, error) {
 	doc := ctx.Document(uri)
 	if doc == nil {
 		return []protocol.Diagnostic{}, nil
 	}
+	// Read the configuration once so one pass sees consistent settings
+	return DocumentDiagnostics(ctx, ctx.GetConfig(), doc)
+}
+
+// DocumentDiagnostics returns diagnostics for a document, which need not be
+// open, under the configuration cfg
+func DocumentDiagnostics(ctx Context, cfg types.ServerConfig, doc *documents.Document) ([]protocol.Diagnostic, error) {
+	// Diagnostics may be disabled in configuration after initialize.
+	// Returning an empty list (rather than nothing) clears stale push diagnostics.
+	if !cfg.Features.DiagnosticsEnabled() {
+		return []protocol.Diagnostic{}, nil
+	}
+	return documentDiagnostics(ctx, cfg, doc)
+}
+
+// documentDiagnostics returns diagnostics for a document, which may be open
+// in the editor or read from disk
+func documentDiagnostics(ctx Context, cfg types.ServerConfig, doc *documents.Document) ([]protocol.Diagnostic, error) {
+	uri := doc.URI()
+
+	// Token files are checked for unknown and circular aliases, for how they
+	// define their tokens, against the configured scales, and in strict mode
+	// for unknown $-prefixed properties
+	if tokenfile.IsLanguage(doc.LanguageID()) && ctx.ShouldProcessAsTokenFile(uri) {
+		diagnostics := aliasDiagnostics(ctx.Locale(), doc, ctx.TokenManager())
+		diagnostics = append(diagnostics, structureDiagnostics(ctx, cfg, doc)...)
+		diagnostics = append(diagnostics, scaleDiagnostics(ctx.Locale(), cfg.Scales, doc)...)
+		if cfg.Strict {
+			diagnostics = append(diagnostics, keywordDiagnostics(ctx.Locale(), doc)...)
+		}
+		return diagnostics, nil
+	}
 
 	// Only process CSS-supported files
 	if !parser.IsCSSSupportedLanguage(doc.LanguageID()) {
@@ -57,19 +115,33 @@ func GetDiagnostics(ctx types.ServerContext, uri string) ([]protocol.Diagnostic,
 
 	// Initialize as empty slice, not nil, to ensure proper JSON serialization
 	diagnostics := []protocol.Diagnostic{}
+	var prefixes []string
+
+	// Documents in node_modules use their package's tokens as well
+	path := uriutil.URIToPath(uri)
+	lookup := tokenLookup{ctx: ctx, vendor: ctx.VendorTokens(path)}
 
 	// Check each var() call
 	for _, varCall := range result.VarCalls {
 		// Look up the token
-		token := ctx.Token(varCall.TokenName)
+		token := lookup.token(varCall.TokenName)
 		if token == nil {
-			// Unknown tokens are not errors - they're handled by hover
+			// Unknown tokens are not errors - they're handled by hover -
+			// except for likely typos of token names in JS/TS strings
+			if varCall.Type == cssparser.PropertyNameReference && !lookup.vendor.Owns(varCall.TokenName) {
+				if diag := unknownPropertyNameDiagnostic(ctx, varCall, &prefixes); diag != nil {
+					diagnostics = append(diagnostics, *diag)
+				}
+			}
 			continue
 		}
 
 		// Check for deprecated token
 		if token.Deprecated {
-			message := fmt.Sprintf("%s is deprecated", varCall.TokenName)
+			message := i18n.Sprintf(ctx.Locale(), "%s is deprecated", varCall.TokenName)
+			if varCall.Alias != "" {
+				message = i18n.Sprintf(ctx.Locale(), "%s uses %s, which is deprecated", varCall.Alias, varCall.TokenName)
+			}
 			if token.DeprecationMessage != "" {
 				message += ": " + token.DeprecationMessage
 			}
@@ -101,20 +173,30 @@ func GetDiagnostics(ctx types.ServerContext, uri string) ([]protocol.Diagnostic,
 							End:   protocol.Position{Line: token.Line, Character: token.Character},
 						},
 					},
-					Message: fmt.Sprintf("Token %s defined here", token.CSSVariableName()),
+					Message: i18n.Sprintf(ctx.Locale(), "Token %s defined here", ctx.TokenManager().CSSVariableName(token)),
 				}}
 			}
 
 			diagnostics = append(diagnostics, diag)
 		}
 
-		// Check for incorrect fallback
-		if varCall.Fallback != nil {
-			fallbackValue := *varCall.Fallback
+		// Check the tokens in a nested fallback chain, starting from its outermost call
+		if varCall.FallbackVar != nil && !varCall.Nested {
+			diagnostics = append(diagnostics, fallbackChainDiagnostics(ctx, lookup, varCall)...)
+		}
+
+		// Check duration and cubicBezier tokens in transitions and animations
+		timing, malformedFallback := timingDiagnostics(ctx, varCall, token)
+		diagnostics = append(diagnostics, timing...)
+
+		// Check for incorrect fallback. A fallback that is itself a var() call
+		// is not a literal; its terminal literal is checked on the innermost call.
+		if varCall.Fallback != nil && varCall.FallbackVar == nil && !malformedFallback {
 			tokenValue := token.Value
 
-			// Check semantic equivalence (case-insensitive, whitespace-normalized)
-			if !isCSSValueSemanticallyEquivalent(fallbackValue, tokenValue) {
+			// Check semantic equivalence (case-insensitive, whitespace-normalized),
+			// evaluating calc(), color-mix(), and light-dark() fallbacks
+			if !csshelpers.FallbackMatches(*varCall.Fallback, token, lookup.token) {
 				severity := protocol.DiagnosticSeverityError
 				diagnostics = append(diagnostics, protocol.Diagnostic{
 					Range: protocol.Range{
@@ -128,28 +210,22 @@ func GetDiagnostics(ctx types.ServerContext, uri string) ([]protocol.Diagnostic,
 						},
 					},
 					Severity: &severity,
-					Message:  fmt.Sprintf("Token fallback does not match expected value: %s", tokenValue),
+					Message:  i18n.Sprintf(ctx.Locale(), "Token fallback does not match expected value: %s", tokenValue),
 				})
 			}
 		}
 	}
 
-	return diagnostics, nil
-}
+	// Check token annotation comments against the declarations they annotate
+	diagnostics = append(diagnostics, annotationDiagnostics(ctx, lookup, result)...)
 
-// PublishDiagnostics publishes diagnostics for a document
-
-// isCSSValueSemanticallyEquivalent checks if two CSS values are semantically equivalent
-// Ignores whitespace and case differences
-func isCSSValueSemanticallyEquivalent(a, b string) bool {
-	// Normalize: remove all whitespace and convert to lowercase
-	normalize := func(s string) string {
-		s = strings.ReplaceAll(s, " ", "")
-		s = strings.ReplaceAll(s, "\t", "")
-		s = strings.ReplaceAll(s, "\n", "")
-		s = strings.ToLower(s)
-		return s
+	// Check generated custom properties files against the token files
+	if ctx.IsCustomPropertiesFile(path) {
+		diagnostics = append(diagnostics, staleCustomPropertyDiagnostics(ctx, doc.Content(), path, result.Variables)...)
+		if diag := missingCustomPropertiesDiagnostic(ctx, path, result.Variables); diag != nil {
+			diagnostics = append(diagnostics, *diag)
+		}
 	}
 
-	return normalize(a) == normalize(b)
+	return diagnostics, nil
 }
@@ -0,0 +1,42 @@
+package diagnostic
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"bennypowers.dev/dtls/internal/tokens"
+	"bennypowers.dev/dtls/lsp/testutil"
+)
+
+// BenchmarkGetDiagnostics benchmarks diagnosing large stylesheets
+func BenchmarkGetDiagnostics(b *testing.B) {
+	sizes := []int{100, 1000, 5000}
+
+	for _, size := range sizes {
+		b.Run(fmt.Sprintf("rules=%d", size), func(b *testing.B) {
+			ctx := testutil.NewMockServerContext()
+			for i := range 100 {
+				_ = ctx.TokenManager().Add(&tokens.Token{
+					Name:  fmt.Sprintf("color-%d", i),
+					Path:  []string{"color", fmt.Sprint(i)},
+					Value: "#ff0000",
+					Type:  "color",
+				})
+			}
+
+			var css strings.Builder
+			for i := range size {
+				fmt.Fprintf(&css, ".rule-%d {\n  color: var(--color-%d, #ff0000);\n  background: var(--color-%d, var(--color-%d, blue));\n}\n", i, i%100, (i+1)%100, (i+2)%100)
+			}
+			uri := "file:///bench.css"
+			_ = ctx.DocumentManager().DidOpen(uri, "css", 1, css.String())
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for b.Loop() {
+				_, _ = GetDiagnostics(ctx, uri)
+			}
+		})
+	}
+}
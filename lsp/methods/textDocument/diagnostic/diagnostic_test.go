@@ -2,9 +2,16 @@ package diagnostic
 
 import (
 	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
 	"testing"
+	"time"
 
 	"bennypowers.dev/dtls/internal/tokens"
+	"bennypowers.dev/dtls/internal/uriutil"
 	"bennypowers.dev/dtls/lsp/testutil"
 	"bennypowers.dev/dtls/lsp/types"
 	"github.com/stretchr/testify/assert"
@@ -39,6 +46,131 @@ func TestGetDiagnostics_DeprecatedToken(t *testing.T) {
 	assert.Equal(t, []protocol.DiagnosticTag{protocol.DiagnosticTagDeprecated}, diagnostics[0].Tags)
 }
 
+func TestGetDiagnostics_SCSSVariable(t *testing.T) {
+	ctx := testutil.NewMockServerContext()
+
+	_ = ctx.TokenManager().Add(&tokens.Token{
+		Name:       "color.old",
+		Value:      "#ff0000",
+		Type:       "color",
+		Deprecated: true,
+	})
+
+	uri := "file:///test.scss"
+	scssContent := "$old: var(--color-old);\n.button { color: $old; }"
+	_ = ctx.DocumentManager().DidOpen(uri, "scss", 1, scssContent)
+
+	diagnostics, err := GetDiagnostics(ctx, uri)
+	require.NoError(t, err)
+	require.Len(t, diagnostics, 2)
+
+	// The declaration's var() call, then the use of $old
+	assert.Equal(t, uint32(0), diagnostics[0].Range.Start.Line)
+	assert.Equal(t, "$old uses --color-old, which is deprecated", diagnostics[1].Message)
+	assert.Equal(t, protocol.Range{
+		Start: protocol.Position{Line: 1, Character: 17},
+		End:   protocol.Position{Line: 1, Character: 21},
+	}, diagnostics[1].Range)
+}
+
+func TestGetDiagnostics_StaleCustomProperties(t *testing.T) {
+	ctx := testutil.NewMockServerContext()
+	ctx.SetRootPath("/project")
+	config := types.DefaultConfig()
+	config.CustomPropertiesFiles = []string{"dist/tokens.css"}
+	ctx.SetConfig(config)
+
+	_ = ctx.TokenManager().Add(&tokens.Token{
+		Name:     "color-primary",
+		Value:    "#0000ff",
+		Type:     "color",
+		FilePath: "/project/tokens.json",
+	})
+	_ = ctx.TokenManager().Add(&tokens.Token{
+		Name:     "space-small",
+		Value:    "4px",
+		Type:     "dimension",
+		FilePath: "/project/tokens.json",
+	})
+
+	content := ":root {\n  --color-primary: #ff0000;\n  --space-small: 4PX;\n}"
+
+	uri := "file:///project/dist/tokens.css"
+	_ = ctx.DocumentManager().DidOpen(uri, "css", 1, content)
+
+	diagnostics, err := GetDiagnostics(ctx, uri)
+	require.NoError(t, err)
+	require.Len(t, diagnostics, 1)
+	assert.Equal(t, "--color-primary is stale: the token value is #0000ff", diagnostics[0].Message)
+	assert.Equal(t, protocol.DiagnosticSeverityWarning, *diagnostics[0].Severity)
+	assert.Equal(t, protocol.Range{
+		Start: protocol.Position{Line: 1, Character: 19},
+		End:   protocol.Position{Line: 1, Character: 26},
+	}, diagnostics[0].Range)
+
+	// The same declarations in a hand-written file are not checked
+	other := "file:///project/src/app.css"
+	_ = ctx.DocumentManager().DidOpen(other, "css", 1, content)
+	diagnostics, err = GetDiagnostics(ctx, other)
+	require.NoError(t, err)
+	assert.Empty(t, diagnostics)
+}
+
+func TestGetDiagnostics_MissingCustomProperties(t *testing.T) {
+	ctx := testutil.NewMockServerContext()
+	ctx.SetRootPath("/project")
+	config := types.DefaultConfig()
+	config.CustomPropertiesFiles = []string{"dist/colors.css", "dist/space.css"}
+	ctx.SetConfig(config)
+
+	for _, name := range []string{"color-primary", "color-accent", "space-small", "space-large"} {
+		require.NoError(t, ctx.TokenManager().Add(&tokens.Token{
+			Name:     name,
+			Value:    "1",
+			FilePath: "/project/tokens.json",
+		}))
+	}
+	// Loaded from a generated file, so it has no source to compare
+	require.NoError(t, ctx.TokenManager().Add(&tokens.Token{
+		Name:     "legacy",
+		Value:    "1",
+		FilePath: "/project/dist/colors.css",
+	}))
+
+	colors := "file:///project/dist/colors.css"
+	require.NoError(t, ctx.DocumentManager().DidOpen(colors, "css", 1, ":root {\n  --color-primary: 1;\n  --legacy: 1;\n}"))
+	require.NoError(t, ctx.DocumentManager().DidOpen("file:///project/dist/space.css", "css", 1, ":root { --space-small: 1; }"))
+
+	diagnostics, err := GetDiagnostics(ctx, colors)
+	require.NoError(t, err)
+	require.Len(t, diagnostics, 1)
+	assert.Equal(t, "Generated output is missing 2 tokens: --color-accent, --space-large", diagnostics[0].Message)
+	assert.Equal(t, protocol.Range{}, diagnostics[0].Range)
+}
+
+func TestGetDiagnostics_DisabledInConfig(t *testing.T) {
+	ctx := testutil.NewMockServerContext()
+	disabled := false
+	config := types.DefaultConfig()
+	config.Features.Diagnostics = &disabled
+	ctx.SetConfig(config)
+
+	_ = ctx.TokenManager().Add(&tokens.Token{
+		Name:       "color.old",
+		Value:      "#ff0000",
+		Type:       "color",
+		Deprecated: true,
+	})
+
+	uri := "file:///test.css"
+	_ = ctx.DocumentManager().DidOpen(uri, "css", 1, `.button { color: var(--color-old); }`)
+
+	diagnostics, err := GetDiagnostics(ctx, uri)
+	require.NoError(t, err)
+	assert.NotNil(t, diagnostics, "should be an empty list so push clients clear stale diagnostics")
+	assert.Empty(t, diagnostics)
+}
+
 func TestGetDiagnostics_IncorrectFallback(t *testing.T) {
 	ctx := testutil.NewMockServerContext()
 
@@ -83,6 +215,107 @@ func TestGetDiagnostics_CorrectFallback(t *testing.T) {
 	assert.Empty(t, diagnostics, "Should not report diagnostic for correct fallback")
 }
 
+func TestGetDiagnostics_ComputedTokenFallback(t *testing.T) {
+	ctx := testutil.NewMockServerContext()
+
+	base := &tokens.Token{Name: "spacing.base", Path: []string{"spacing", "base"}, Value: "8px", RawValue: "8px", Type: "dimension"}
+	double := &tokens.Token{Name: "spacing.double", Path: []string{"spacing", "double"}, Value: "{spacing.base} * 2", RawValue: "{spacing.base} * 2", Type: "dimension"}
+	require.NoError(t, tokens.ResolveExpressions([]*tokens.Token{base, double}))
+	_ = ctx.TokenManager().Add(base)
+	_ = ctx.TokenManager().Add(double)
+
+	uri := "file:///test.css"
+	_ = ctx.DocumentManager().DidOpen(uri, "css", 1, `.a { padding: var(--spacing-double, calc(8px * 2)); }
+.b { padding: var(--spacing-double, 16px); }
+.c { padding: var(--spacing-double, 8px); }`)
+
+	diagnostics, err := GetDiagnostics(ctx, uri)
+	require.NoError(t, err)
+	require.Len(t, diagnostics, 1, "the calc() form and its result are both correct")
+	assert.Equal(t, uint32(2), diagnostics[0].Range.Start.Line)
+	assert.Contains(t, diagnostics[0].Message, "calc(8px * 2)")
+}
+
+func TestGetDiagnostics_ColorFunctionFallbacks(t *testing.T) {
+	ctx := testutil.NewMockServerContext()
+
+	for _, token := range []*tokens.Token{
+		// A token with light and dark variants
+		{Name: "color.surface", Value: "#ffffff", Type: "color"},
+		{Name: "color.surface.light", Value: "#ffffff", Type: "color"},
+		{Name: "color.surface.dark", Value: "#000000", Type: "color"},
+		// A token whose value pairs them
+		{Name: "color.text", Value: "light-dark(#111111, #eeeeee)", Type: "color"},
+		// A token without variants
+		{Name: "color.accent", Value: "#ff0000", Type: "color"},
+		// A mixed color
+		{Name: "color.muted", Value: "#808080", Type: "color"},
+	} {
+		require.NoError(t, ctx.TokenManager().Add(token))
+	}
+
+	uri := "file:///test.css"
+	_ = ctx.DocumentManager().DidOpen(uri, "css", 1, `.a { color: var(--color-surface, light-dark(white, black)); }
+.b { color: var(--color-text, light-dark(#111, #EEE)); }
+.c { color: var(--color-accent, light-dark(red, darkred)); }
+.d { color: var(--color-muted, color-mix(in srgb, white, black)); }
+.e { color: var(--color-surface, light-dark(white, white)); }
+.f { color: var(--color-text, light-dark(#eee, #111)); }
+.g { color: var(--color-muted, color-mix(in srgb, white 80%, black)); }`)
+
+	diagnostics, err := GetDiagnostics(ctx, uri)
+	require.NoError(t, err)
+	var lines []uint32
+	for _, diag := range diagnostics {
+		assert.Contains(t, diag.Message, "fallback does not match")
+		lines = append(lines, diag.Range.Start.Line)
+	}
+	assert.Equal(t, []uint32{4, 5, 6}, lines, "only the mismatched variants and mix are reported")
+}
+
+func TestGetDiagnostics_TimingTokens(t *testing.T) {
+	ctx := testutil.NewMockServerContext()
+
+	for _, token := range []*tokens.Token{
+		{Name: "duration.fast", Value: "200ms", Type: "duration"},
+		{Name: "duration.unitless", Value: "200", Type: "duration"},
+		{Name: "ease.out", Value: "cubic-bezier(0, 0, 0.58, 1)", RawValue: []any{0.0, 0.0, 0.58, 1.0}, Type: "cubicBezier"},
+		{Name: "ease.broken", Value: "cubic-bezier(1.5, 0, 0.58, 1)", Type: "cubicBezier"},
+	} {
+		require.NoError(t, ctx.TokenManager().Add(token))
+	}
+
+	uri := "file:///test.css"
+	_ = ctx.DocumentManager().DidOpen(uri, "css", 1, `.a { transition: opacity var(--duration-fast) var(--ease-out); }
+.b { transition-duration: var(--duration-unitless); }
+.c { animation-timing-function: var(--ease-broken); }
+.d { transition-duration: var(--duration-fast, 200); }
+.e { transition-timing-function: var(--ease-out, cubic-bezier(0 0 0.58 1)); }
+.f { transition-timing-function: var(--ease-out, ease-out); }
+.g { width: var(--duration-unitless); }`)
+
+	diagnostics, err := GetDiagnostics(ctx, uri)
+	require.NoError(t, err)
+
+	type report struct {
+		line     uint32
+		severity protocol.DiagnosticSeverity
+		message  string
+	}
+	var reports []report
+	for _, diag := range diagnostics {
+		require.NotNil(t, diag.Severity)
+		reports = append(reports, report{diag.Range.Start.Line, *diag.Severity, diag.Message})
+	}
+	assert.Equal(t, []report{
+		{1, protocol.DiagnosticSeverityWarning, "--duration-unitless is malformed in transition-duration: 200 has no time unit, such as ms or s"},
+		{2, protocol.DiagnosticSeverityWarning, "--ease-broken is malformed in animation-timing-function: cubic-bezier() x coordinate 1.5 is not between 0 and 1"},
+		{3, protocol.DiagnosticSeverityError, "Token fallback is malformed in transition-duration: 200 has no time unit, such as ms or s"},
+		{4, protocol.DiagnosticSeverityError, "Token fallback is malformed in transition-timing-function: cubic-bezier() takes 4 numbers, not 1"},
+		{5, protocol.DiagnosticSeverityError, "Token fallback does not match expected value: cubic-bezier(0, 0, 0.58, 1)"},
+	}, reports, "well-formed fallbacks which don't match are reported as incorrect, and other properties are not checked")
+}
+
 func TestGetDiagnostics_UnknownToken(t *testing.T) {
 	ctx := testutil.NewMockServerContext()
 
@@ -191,71 +424,97 @@ func TestDocumentDiagnostic(t *testing.T) {
 	require.True(t, ok, "Result should be RelatedFullDocumentDiagnosticReport")
 	assert.Equal(t, string(DiagnosticFull), report.Kind)
 	assert.Len(t, report.Items, 1)
+	assert.NotEmpty(t, report.ResultID)
 }
 
-func TestIsCSSValueSemanticallyEquivalent(t *testing.T) {
-	tests := []struct {
-		name     string
-		a        string
-		b        string
-		expected bool
-	}{
-		{
-			name:     "Exact match",
-			a:        "#ff0000",
-			b:        "#ff0000",
-			expected: true,
-		},
-		{
-			name:     "Case difference",
-			a:        "#FF0000",
-			b:        "#ff0000",
-			expected: true,
-		},
-		{
-			name:     "Whitespace difference",
-			a:        "rgb(255, 0, 0)",
-			b:        "rgb(255,0,0)",
-			expected: true,
-		},
-		{
-			name:     "Mixed whitespace and case",
-			a:        "RGB( 255, 0, 0 )",
-			b:        "rgb(255,0,0)",
-			expected: true,
-		},
-		{
-			name:     "Tab and newline",
-			a:        "rgba(\n255,\t0,\t0,\t1\n)",
-			b:        "rgba(255,0,0,1)",
-			expected: true,
-		},
-		{
-			name:     "Different values",
-			a:        "#ff0000",
-			b:        "#0000ff",
-			expected: false,
-		},
-		{
-			name:     "Empty strings",
-			a:        "",
-			b:        "",
-			expected: true,
-		},
-		{
-			name:     "One empty",
-			a:        "#ff0000",
-			b:        "",
-			expected: false,
-		},
-	}
+func TestDocumentDiagnostic_ConfigSnapshot(t *testing.T) {
+	ctx := testutil.NewMockServerContext()
+	req := types.NewRequestContext(ctx, &glsp.Context{})
 
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			result := isCSSValueSemanticallyEquivalent(tt.a, tt.b)
-			assert.Equal(t, tt.expected, result)
+	_ = ctx.TokenManager().Add(&tokens.Token{
+		Name:       "spacing.old",
+		Value:      "8px",
+		Type:       "dimension",
+		Deprecated: true,
+	})
+
+	uri := "file:///test.css"
+	_ = ctx.DocumentManager().DidOpen(uri, "css", 1, `.button { padding: var(--spacing-old); }`)
+
+	// The request takes its snapshot before the configuration changes
+	_ = req.Config()
+	disabled := false
+	cfg := ctx.GetConfig()
+	cfg.Features.Diagnostics = &disabled
+	ctx.SetConfig(cfg)
+
+	result, err := DocumentDiagnostic(req, &DocumentDiagnosticParams{
+		TextDocument: protocol.TextDocumentIdentifier{URI: uri},
+	})
+	require.NoError(t, err)
+	report, ok := result.(RelatedFullDocumentDiagnosticReport)
+	require.True(t, ok, "expected a full report")
+	assert.Len(t, report.Items, 1, "diagnostics use the request's configuration snapshot")
+}
+
+func TestDocumentDiagnostic_UnchangedReport(t *testing.T) {
+	ctx := testutil.NewMockServerContext()
+	req := types.NewRequestContext(ctx, &glsp.Context{})
+
+	_ = ctx.TokenManager().Add(&tokens.Token{
+		Name:       "spacing.old",
+		Value:      "8px",
+		Type:       "dimension",
+		Deprecated: true,
+	})
+
+	uri := "file:///test.css"
+	_ = ctx.DocumentManager().DidOpen(uri, "css", 1, `.button { padding: var(--spacing-old); }`)
+
+	pull := func(previousResultID string) any {
+		t.Helper()
+		result, err := DocumentDiagnostic(req, &DocumentDiagnosticParams{
+			TextDocument:     protocol.TextDocumentIdentifier{URI: uri},
+			PreviousResultID: previousResultID,
 		})
+		require.NoError(t, err)
+		return result
 	}
+
+	first, ok := pull("").(RelatedFullDocumentDiagnosticReport)
+	require.True(t, ok, "first pull should be a full report")
+
+	t.Run("same diagnostics return an unchanged report", func(t *testing.T) {
+		report, ok := pull(first.ResultID).(RelatedUnchangedDocumentDiagnosticReport)
+		require.True(t, ok, "expected an unchanged report")
+		assert.Equal(t, string(DiagnosticUnchanged), report.Kind)
+		assert.Equal(t, first.ResultID, report.ResultID)
+
+		data, err := json.Marshal(report)
+		require.NoError(t, err)
+		assert.NotContains(t, string(data), "items")
+	})
+
+	t.Run("unknown previous result id returns a full report", func(t *testing.T) {
+		report, ok := pull("diag-unknown").(RelatedFullDocumentDiagnosticReport)
+		require.True(t, ok, "expected a full report")
+		assert.Equal(t, first.ResultID, report.ResultID)
+	})
+
+	t.Run("edits that don't change diagnostics keep the result id", func(t *testing.T) {
+		_ = ctx.DocumentManager().DidOpen(uri, "css", 2, `.button { padding: var(--spacing-old); } .card {}`)
+		report, ok := pull(first.ResultID).(RelatedUnchangedDocumentDiagnosticReport)
+		require.True(t, ok, "expected an unchanged report")
+		assert.Equal(t, first.ResultID, report.ResultID)
+	})
+
+	t.Run("changed diagnostics return a full report with a new result id", func(t *testing.T) {
+		_ = ctx.DocumentManager().DidOpen(uri, "css", 3, `.button { padding: 8px; }`)
+		report, ok := pull(first.ResultID).(RelatedFullDocumentDiagnosticReport)
+		require.True(t, ok, "expected a full report")
+		assert.NotEqual(t, first.ResultID, report.ResultID)
+		assert.Empty(t, report.Items)
+	})
 }
 
 func TestGetDiagnostics_NumericFallbackNoFalsePositive(t *testing.T) {
@@ -468,6 +727,103 @@ func TestGetDiagnostics_JSDocument(t *testing.T) {
 	assert.Contains(t, diagnostics[0].Message, "fallback does not match")
 }
 
+func TestGetDiagnostics_StyledComponents(t *testing.T) {
+	ctx := testutil.NewMockServerContext()
+
+	_ = ctx.TokenManager().Add(&tokens.Token{
+		Name:       "color.primary",
+		Value:      "#0000ff",
+		Deprecated: true,
+	})
+
+	uri := "file:///test.jsx"
+	content := "const Link = styled(Button).attrs({ as: 'a' })`\n  margin: ${m};\n  color: var(--color-primary);\n`;"
+	_ = ctx.DocumentManager().DidOpen(uri, "javascriptreact", 1, content)
+
+	diagnostics, err := GetDiagnostics(ctx, uri)
+	require.NoError(t, err)
+	require.Len(t, diagnostics, 1)
+	assert.Equal(t, protocol.Position{Line: 2, Character: 9}, diagnostics[0].Range.Start)
+	assert.Contains(t, diagnostics[0].Message, "deprecated")
+}
+
+func TestGetDiagnostics_JSStyleStrings(t *testing.T) {
+	ctx := testutil.NewMockServerContext()
+
+	_ = ctx.TokenManager().Add(&tokens.Token{
+		Name:       "color.primary",
+		Value:      "#0000ff",
+		Deprecated: true,
+	})
+
+	uri := "file:///test.jsx"
+	content := "const a = <a style={{ color: 'var(--color-primary)' }} />;\nel.style.setProperty('--x', 'var(--color-primary, red)');"
+	_ = ctx.DocumentManager().DidOpen(uri, "javascriptreact", 1, content)
+
+	diagnostics, err := GetDiagnostics(ctx, uri)
+	require.NoError(t, err)
+	require.Len(t, diagnostics, 3)
+	assert.Equal(t, protocol.Position{Line: 0, Character: 30}, diagnostics[0].Range.Start)
+	assert.Contains(t, diagnostics[0].Message, "deprecated")
+	assert.Contains(t, diagnostics[2].Message, "fallback does not match")
+}
+
+func TestGetDiagnostics_JSPropertyNames(t *testing.T) {
+	ctx := testutil.NewMockServerContext()
+	_ = ctx.TokenManager().Add(&tokens.Token{
+		Name:       "color.legacy",
+		Value:      "#000",
+		Prefix:     "ds",
+		Deprecated: true,
+	})
+	_ = ctx.TokenManager().Add(&tokens.Token{Name: "color.primary", Value: "#00f", Prefix: "ds"})
+
+	tests := []struct {
+		name     string
+		content  string
+		messages []string
+	}{
+		{
+			name:    "known token",
+			content: "getComputedStyle(el).getPropertyValue('--ds-color-primary');",
+		},
+		{
+			name:     "deprecated token",
+			content:  "el.style.setProperty('--ds-color-legacy', 'red');",
+			messages: []string{"--ds-color-legacy is deprecated"},
+		},
+		{
+			name:     "unknown token with a token prefix",
+			content:  "getComputedStyle(el).getPropertyValue('--ds-colr-primary');",
+			messages: []string{"Unknown design token --ds-colr-primary"},
+		},
+		{
+			name:     "registerProperty",
+			content:  "CSS.registerProperty({ name: '--ds-colr', syntax: '<color>', inherits: true });",
+			messages: []string{"Unknown design token --ds-colr"},
+		},
+		{
+			name:    "local custom property",
+			content: "el.style.setProperty('--local-x', '1px');",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			uri := "file:///test.js"
+			_ = ctx.DocumentManager().DidOpen(uri, "javascript", 1, tt.content)
+			defer func() { _ = ctx.DocumentManager().DidClose(uri) }()
+
+			diagnostics, err := GetDiagnostics(ctx, uri)
+			require.NoError(t, err)
+			require.Len(t, diagnostics, len(tt.messages))
+			for i, message := range tt.messages {
+				assert.Contains(t, diagnostics[i].Message, message)
+			}
+		})
+	}
+}
+
 func TestGetDiagnostics_HTMLNoCSS(t *testing.T) {
 	ctx := testutil.NewMockServerContext()
 
@@ -479,3 +835,648 @@ func TestGetDiagnostics_HTMLNoCSS(t *testing.T) {
 	require.NoError(t, err)
 	assert.Empty(t, diagnostics)
 }
+
+func TestGetDiagnostics_FallbackChain(t *testing.T) {
+	ctx := testutil.NewMockServerContext()
+	_ = ctx.TokenManager().Add(&tokens.Token{Name: "space.large", Value: "16px", Type: "dimension"})
+	_ = ctx.TokenManager().Add(&tokens.Token{Name: "space.small", Value: "4px", Type: "dimension"})
+
+	tests := []struct {
+		name     string
+		css      string
+		messages []string
+	}{
+		{
+			name: "valid chain",
+			css:  `.a { padding: var(--space-large, var(--space-small, 4px)); }`,
+		},
+		{
+			name:     "terminal literal does not match last token",
+			css:      `.a { padding: var(--space-large, var(--space-small, 16px)); }`,
+			messages: []string{"Token fallback does not match expected value: 4px"},
+		},
+		{
+			name:     "unknown token in chain",
+			css:      `.a { padding: var(--space-large, var(--space-huge, 4px)); }`,
+			messages: []string{"Unknown token --space-huge in the fallback chain of --space-large"},
+		},
+		{
+			name: "repeated token in chain",
+			css:  `.a { padding: var(--space-large, var(--space-large, 16px)); }`,
+			messages: []string{
+				"--space-large appears more than once in the fallback chain of --space-large",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			uri := "file:///chain.css"
+			require.NoError(t, ctx.DocumentManager().DidOpen(uri, "css", 1, tt.css))
+			defer func() { _ = ctx.DocumentManager().DidClose(uri) }()
+
+			diagnostics, err := GetDiagnostics(ctx, uri)
+			require.NoError(t, err)
+
+			var messages []string
+			for _, d := range diagnostics {
+				messages = append(messages, d.Message)
+			}
+			assert.Equal(t, tt.messages, messages)
+		})
+	}
+}
+
+func TestGetDiagnostics_VendorTokens(t *testing.T) {
+	ctx := testutil.NewMockServerContext()
+	_ = ctx.TokenManager().Add(&tokens.Token{Name: "space.large", Value: "16px", Type: "dimension"})
+	vendorTokens := tokens.NewManager()
+	_ = vendorTokens.Add(&tokens.Token{Name: "color-accent", Path: []string{"color", "accent"}, Prefix: "acme", Value: "#f00", Type: "color"})
+	ctx.Vendor = &types.VendorTokens{Package: "@acme/elements", Prefixes: []string{"--acme-"}, Tokens: vendorTokens}
+
+	tests := []struct {
+		name     string
+		uri      string
+		css      string
+		messages []string
+	}{
+		{
+			name: "vendor prefix in node_modules",
+			uri:  "file:///ws/node_modules/@acme/elements/button.css",
+			css:  `.a { padding: var(--space-large, var(--acme-space-huge, 16px)); }`,
+		},
+		{
+			name:     "other prefix in node_modules",
+			uri:      "file:///ws/node_modules/@acme/elements/button.css",
+			css:      `.a { padding: var(--space-large, var(--other-space, 16px)); }`,
+			messages: []string{"Unknown token --other-space in the fallback chain of --space-large"},
+		},
+		{
+			name:     "resolves vendor tokens",
+			uri:      "file:///ws/node_modules/@acme/elements/button.css",
+			css:      `.a { color: var(--acme-color-accent, #00f); }`,
+			messages: []string{"Token fallback does not match expected value: #f00"},
+		},
+		{
+			name:     "vendor prefix outside node_modules",
+			uri:      "file:///ws/src/button.css",
+			css:      `.a { padding: var(--space-large, var(--acme-space-huge, 16px)); }`,
+			messages: []string{"Unknown token --acme-space-huge in the fallback chain of --space-large"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.NoError(t, ctx.DocumentManager().DidOpen(tt.uri, "css", 1, tt.css))
+			defer func() { _ = ctx.DocumentManager().DidClose(tt.uri) }()
+
+			diagnostics, err := GetDiagnostics(ctx, tt.uri)
+			require.NoError(t, err)
+
+			var messages []string
+			for _, d := range diagnostics {
+				messages = append(messages, d.Message)
+			}
+			assert.Equal(t, tt.messages, messages)
+		})
+	}
+}
+
+func TestGetDiagnostics_Annotations(t *testing.T) {
+	ctx := testutil.NewMockServerContext()
+	_ = ctx.TokenManager().Add(&tokens.Token{Name: "color.primary", Value: "#00f", Type: "color"})
+	_ = ctx.TokenManager().Add(&tokens.Token{Name: "color.secondary", Value: "#0f0", Type: "color"})
+
+	tests := []struct {
+		name     string
+		language string
+		source   string
+		messages []string
+	}{
+		{
+			name:   "annotation matches the var() call",
+			source: ".a {\n  /* token: color.primary */\n  color: var(--color-primary);\n}",
+		},
+		{
+			name:   "annotation matches a fallback",
+			source: ".a {\n  /* token: color.primary */\n  color: var(--local, var(--color-primary));\n}",
+		},
+		{
+			name:     "annotation names another token",
+			source:   ".a {\n  /* token: color.primary */\n  color: var(--color-secondary);\n}",
+			messages: []string{"Annotated token color.primary does not match the declaration, which uses --color-secondary"},
+		},
+		{
+			name:     "unknown annotated token",
+			source:   ".a {\n  /* token: color.tertiary */\n  color: var(--color-primary);\n}",
+			messages: []string{"Unknown design token color.tertiary"},
+		},
+		{
+			name:   "declaration without var() calls",
+			source: ":root {\n  /* token: color.primary */\n  --color-primary: #00f;\n}",
+		},
+		{
+			name:     "style tag",
+			language: "html",
+			source:   "<style>\n.a {\n  /* token: color.primary */\n  color: var(--color-secondary);\n}\n</style>",
+			messages: []string{"Annotated token color.primary does not match the declaration, which uses --color-secondary"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			language := tt.language
+			if language == "" {
+				language = "css"
+			}
+			uri := "file:///annotated." + language
+			require.NoError(t, ctx.DocumentManager().DidOpen(uri, language, 1, tt.source))
+			defer func() { _ = ctx.DocumentManager().DidClose(uri) }()
+
+			diagnostics, err := GetDiagnostics(ctx, uri)
+			require.NoError(t, err)
+
+			var messages []string
+			for _, d := range diagnostics {
+				messages = append(messages, d.Message)
+			}
+			assert.Equal(t, tt.messages, messages)
+		})
+	}
+}
+
+func TestGetDiagnostics_Scales(t *testing.T) {
+	ctx := testutil.NewMockServerContext()
+	ctx.SetConfig(types.ServerConfig{Scales: []types.ScaleRule{
+		{Group: "space", Step: "4px"},
+		{Group: "font.size", Ratio: 1.25, Base: "16px"},
+	}})
+
+	uri := "file:///tokens.json"
+	content := `{
+  "space": {
+    "$type": "dimension",
+    "sm": { "$value": "4px" },
+    "md": { "$value": "6px" },
+    "lg": { "$value": { "value": 18, "unit": "px" } },
+    "xl": { "$value": "2rem" },
+    "alias": { "$value": "{space.sm}" }
+  },
+  "font": {
+    "size": {
+      "base": { "$value": "16px" },
+      "lg": { "$value": "20px" },
+      "xl": { "$value": "31px" },
+      "xxl": { "$value": "36px" }
+    }
+  }
+}`
+	require.NoError(t, ctx.DocumentManager().DidOpen(uri, "json", 1, content))
+
+	diagnostics, err := GetDiagnostics(ctx, uri)
+	require.NoError(t, err)
+
+	messages := map[string]protocol.Diagnostic{}
+	for _, diag := range diagnostics {
+		messages[diag.Message] = diag
+	}
+	require.Len(t, messages, 4, "diagnostics: %v", diagnostics)
+
+	md, ok := messages["space.md is 6px, which is not a multiple of 4px. Did you mean 8px?"]
+	require.True(t, ok)
+	assert.Equal(t, protocol.DiagnosticSeverityWarning, *md.Severity)
+	assert.Equal(t, protocol.Range{
+		Start: protocol.Position{Line: 4, Character: 23},
+		End:   protocol.Position{Line: 4, Character: 26},
+	}, md.Range)
+	assert.Equal(t, map[string]any{ScaleSuggestionKey: "8px"}, md.Data)
+
+	// Dimension objects are fixed with a bare number
+	lg, ok := messages["space.lg is 18px, which is not a multiple of 4px. Did you mean 20px?"]
+	require.True(t, ok)
+	assert.Equal(t, map[string]any{ScaleSuggestionKey: "20"}, lg.Data)
+
+	xl, ok := messages[`space.xl is 2rem, but the scale is in "px"`]
+	require.True(t, ok)
+	assert.Nil(t, xl.Data)
+
+	// 31px is within rounding of 31.25px, 36px is not near 39.0625px
+	_, ok = messages["font.size.xxl is 36px, which is not on the 1.25 modular scale from 16px. Did you mean 39.0625px?"]
+	assert.True(t, ok)
+}
+
+func TestGetDiagnostics_StrictKeywords(t *testing.T) {
+	ctx := testutil.NewMockServerContext()
+	ctx.SetConfig(types.ServerConfig{Strict: true})
+
+	uri := "file:///tokens.json"
+	content := `{
+  "$schema": "https://www.designtokens.org/schemas/2025.10/format.json",
+  "color": {
+    "$tpye": "color",
+    "primary": {
+      "$value": "#00f",
+      "$descripton": "Brand color",
+      "$extensions": { "com.example": { "$custom": true } }
+    },
+    "$root": { "$value": "#000", "$figma": "ignored" }
+  }
+}`
+	require.NoError(t, ctx.DocumentManager().DidOpen(uri, "json", 1, content))
+
+	diagnostics, err := GetDiagnostics(ctx, uri)
+	require.NoError(t, err)
+	require.Len(t, diagnostics, 3, "diagnostics: %v", diagnostics)
+
+	assert.Equal(t, "Unknown property $tpye. Did you mean $type?", diagnostics[0].Message)
+	assert.Equal(t, protocol.DiagnosticSeverityError, *diagnostics[0].Severity)
+	assert.Equal(t, protocol.Range{
+		Start: protocol.Position{Line: 3, Character: 5},
+		End:   protocol.Position{Line: 3, Character: 10},
+	}, diagnostics[0].Range)
+	assert.Equal(t, map[string]any{KeywordSuggestionKey: "$type"}, diagnostics[0].Data)
+
+	assert.Equal(t, "Unknown property $descripton. Did you mean $description?", diagnostics[1].Message)
+
+	// Properties far from any keyword get no suggestion
+	assert.Equal(t, "Unknown property $figma", diagnostics[2].Message)
+	assert.Nil(t, diagnostics[2].Data)
+
+	t.Run("off by default", func(t *testing.T) {
+		ctx.SetConfig(types.ServerConfig{})
+		diagnostics, err := GetDiagnostics(ctx, uri)
+		require.NoError(t, err)
+		assert.Empty(t, diagnostics)
+	})
+}
+
+func TestGetDiagnostics_ScalesNotConfigured(t *testing.T) {
+	ctx := testutil.NewMockServerContext()
+	uri := "file:///tokens.json"
+	require.NoError(t, ctx.DocumentManager().DidOpen(uri, "json", 1, `{ "space": { "md": { "$value": "6px" } } }`))
+
+	diagnostics, err := GetDiagnostics(ctx, uri)
+	require.NoError(t, err)
+	assert.NotNil(t, diagnostics)
+	assert.Empty(t, diagnostics)
+}
+
+func TestGetDiagnostics_CircularAliases(t *testing.T) {
+	ctx := testutil.NewMockServerContext()
+	// An alias in another file, which leads back into this one
+	require.NoError(t, ctx.TokenManager().Add(&tokens.Token{
+		Name:     "other-loop",
+		Path:     []string{"other", "loop"},
+		RawValue: "{color.external}",
+		FilePath: "/other.json",
+	}))
+
+	uri := "file:///tokens.json"
+	content := `{
+  "color": {
+    "primary": { "$value": "#ff0000" },
+    "brand": { "$value": "{color.primary}" },
+    "a": { "$value": "{color.b}" },
+    "b": { "$value": "{color.a}" },
+    "into": { "$value": "{color.a}" },
+    "external": { "$value": "{other.loop}" }
+  }
+}`
+	require.NoError(t, ctx.DocumentManager().DidOpen(uri, "json", 1, content))
+
+	diagnostics, err := GetDiagnostics(ctx, uri)
+	require.NoError(t, err)
+
+	byLine := map[uint32]protocol.Diagnostic{}
+	for _, diag := range diagnostics {
+		byLine[diag.Range.Start.Line] = diag
+	}
+	require.Len(t, byLine, 4, "diagnostics: %v", diagnostics)
+
+	a := byLine[4]
+	assert.Equal(t, "Circular reference: color.a → color.b → color.a", a.Message)
+	assert.Equal(t, protocol.DiagnosticSeverityError, *a.Severity)
+	assert.Equal(t, protocol.Range{
+		Start: protocol.Position{Line: 4, Character: 22},
+		End:   protocol.Position{Line: 4, Character: 31},
+	}, a.Range, "the diagnostic covers the alias")
+
+	assert.Equal(t, "Circular reference: color.b → color.a → color.b", byLine[5].Message)
+	assert.Equal(t, "Circular reference: color.a → color.b → color.a", byLine[6].Message, "aliases leading into a cycle")
+	assert.Equal(t, "Circular reference: color.external → other.loop → color.external", byLine[7].Message)
+}
+
+func TestGetDiagnostics_CircularRefs(t *testing.T) {
+	ctx := testutil.NewMockServerContext()
+	uri := "file:///tokens.yaml"
+	content := `color:
+  a:
+    $value:
+      $ref: '#/color/b'
+  b:
+    $ref: '#/color/a/$value'
+`
+	require.NoError(t, ctx.DocumentManager().DidOpen(uri, "yaml", 1, content))
+
+	diagnostics, err := GetDiagnostics(ctx, uri)
+	require.NoError(t, err)
+	require.Len(t, diagnostics, 2)
+	assert.Equal(t, uint32(3), diagnostics[0].Range.Start.Line)
+	assert.Equal(t, "Circular reference: color.a → color.b → color.a", diagnostics[0].Message)
+	assert.Equal(t, uint32(5), diagnostics[1].Range.Start.Line)
+}
+
+func TestGetDiagnostics_TokenFileStructure(t *testing.T) {
+	ctx := testutil.NewMockServerContext()
+	ctx.SetConfig(types.ServerConfig{GroupMarkers: []string{"_"}})
+	require.NoError(t, ctx.TokenManager().Add(&tokens.Token{
+		Name:     "shared-primary",
+		Path:     []string{"shared", "primary"},
+		Value:    "#00f",
+		FilePath: "/shared.json",
+	}))
+
+	uri := "file:///tokens.json"
+	content := `{
+  "color": {
+    "$type": "colour",
+    "primary": { "$value": "#f00" }
+  },
+  "space": {
+    "$type": "dimension",
+    "sm": { "$value": 4 },
+    "zero": { "$value": 0 },
+    "md": { "$value": "{space.base} * 2" },
+    "lg": { "$value": "{shared.primary}" },
+    "md": { "$value": "8px" }
+  },
+  "ease": {
+    "$type": "cubicBezier",
+    "in": { "$value": [0.4, 0, 1] },
+    "out": { "$value": [0, 0, "{ease.in}", 1] }
+  },
+  "border": {
+    "$type": "border",
+    "focus": {
+      "$value": { "color": "{color.focus}", "width": "2px", "style": "solid" },
+      "thick": { "$value": "4px" }
+    },
+    "_": {
+      "$value": { "color": { "$ref": "#/color/primary" }, "width": "1px", "style": "solid" },
+      "dashed": { "$value": { "color": "#000", "width": "1px", "style": "dashed" } }
+    },
+    "ref": { "$ref": "#/border/missing/$value" }
+  }
+}`
+	require.NoError(t, ctx.DocumentManager().DidOpen(uri, "json", 1, content))
+
+	diagnostics, err := GetDiagnostics(ctx, uri)
+	require.NoError(t, err)
+
+	messages := map[string]protocol.Diagnostic{}
+	for _, diag := range diagnostics {
+		messages[diag.Message] = diag
+	}
+	assert.ElementsMatch(t, []string{
+		"Unknown design token space.base",
+		"Unknown design token color.focus",
+		"Unknown design token border.missing",
+		"Unknown token type colour. Did you mean color?",
+		"space.sm is a dimension token, but its $value is not a dimension string or object, such as 4px",
+		"space.md is defined more than once",
+		"ease.in is a cubicBezier token, but its $value is not an array of 4 numbers",
+		"border.focus is a token, so it cannot contain thick",
+	}, slices.Collect(maps.Keys(messages)))
+
+	t.Run("unknown references", func(t *testing.T) {
+		diag := messages["Unknown design token space.base"]
+		assert.Equal(t, protocol.DiagnosticSeverityError, *diag.Severity)
+		assert.Equal(t, protocol.Range{
+			Start: protocol.Position{Line: 9, Character: 23},
+			End:   protocol.Position{Line: 9, Character: 35},
+		}, diag.Range, "the diagnostic covers the reference in the expression")
+		assert.Equal(t, protocol.Range{
+			Start: protocol.Position{Line: 28, Character: 22},
+			End:   protocol.Position{Line: 28, Character: 45},
+		}, messages["Unknown design token border.missing"].Range, "pointers are covered whole")
+	})
+
+	t.Run("unknown types", func(t *testing.T) {
+		diag := messages["Unknown token type colour. Did you mean color?"]
+		assert.Equal(t, protocol.DiagnosticSeverityWarning, *diag.Severity)
+		assert.Equal(t, protocol.Range{
+			Start: protocol.Position{Line: 2, Character: 14},
+			End:   protocol.Position{Line: 2, Character: 20},
+		}, diag.Range)
+	})
+
+	t.Run("value shapes", func(t *testing.T) {
+		diag := messages["space.sm is a dimension token, but its $value is not a dimension string or object, such as 4px"]
+		assert.Equal(t, protocol.DiagnosticSeverityError, *diag.Severity)
+		assert.Equal(t, uint32(7), diag.Range.Start.Line)
+		// Arrays and objects are flagged at their $value key
+		assert.Equal(t, protocol.Range{
+			Start: protocol.Position{Line: 15, Character: 13},
+			End:   protocol.Position{Line: 15, Character: 19},
+		}, messages["ease.in is a cubicBezier token, but its $value is not an array of 4 numbers"].Range)
+	})
+
+	t.Run("naming collisions", func(t *testing.T) {
+		assert.Equal(t, uint32(11), messages["space.md is defined more than once"].Range.Start.Line)
+		assert.Equal(t, uint32(22), messages["border.focus is a token, so it cannot contain thick"].Range.Start.Line)
+		_, ok := messages["border._ is a token, so it cannot contain dashed"]
+		assert.False(t, ok, "group markers are tokens and groups")
+	})
+}
+
+func TestGetDiagnostics_CSSVariableCollisions(t *testing.T) {
+	ctx := testutil.NewMockServerContext()
+	for _, token := range []*tokens.Token{
+		{Name: "color-primary-dark", Path: []string{"color", "primary-dark"}, Value: "#008", FilePath: "/tokens.json"},
+		{Name: "color-primary-dark", Path: []string{"color", "primary", "dark"}, Value: "#004", FilePath: "/theme.json"},
+	} {
+		require.NoError(t, ctx.TokenManager().Add(token))
+	}
+
+	uri := "file:///tokens.json"
+	content := `{
+  "color": {
+    "primary-dark": { "$value": "#008" }
+  }
+}`
+	require.NoError(t, ctx.DocumentManager().DidOpen(uri, "json", 1, content))
+
+	diagnostics, err := GetDiagnostics(ctx, uri)
+	require.NoError(t, err)
+	require.Len(t, diagnostics, 1, "diagnostics: %v", diagnostics)
+	assert.Equal(t, "color.primary-dark shares the CSS variable --color-primary-dark with color.primary.dark", diagnostics[0].Message)
+	assert.Equal(t, protocol.DiagnosticSeverityWarning, *diagnostics[0].Severity)
+	assert.Equal(t, protocol.Range{
+		Start: protocol.Position{Line: 2, Character: 5},
+		End:   protocol.Position{Line: 2, Character: 17},
+	}, diagnostics[0].Range)
+}
+
+func TestWorkspaceDiagnostic(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]string{
+		"styles/button.css": `.button { padding: var(--spacing-old); }`,
+		"styles/card.css":   `.card { padding: 8px; }`,
+		"tokens.json":       `{ "spacing": { "old": { "$value": "8px", "$deprecated": true } }, "color": { "alias": { "$value": "{color.missing}" } } }`,
+	}
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o750))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	}
+	button := uriutil.PathToURI(filepath.Join(root, "styles", "button.css"))
+	card := uriutil.PathToURI(filepath.Join(root, "styles", "card.css"))
+	tokenFile := uriutil.PathToURI(filepath.Join(root, "tokens.json"))
+
+	ctx := testutil.NewMockServerContext()
+	require.NoError(t, ctx.WorkspaceIndex().Scan(root))
+	require.NoError(t, ctx.TokenManager().Add(&tokens.Token{
+		Name:       "spacing.old",
+		Path:       []string{"spacing", "old"},
+		Value:      "8px",
+		Deprecated: true,
+		FilePath:   filepath.Join(root, "tokens.json"),
+	}))
+	// The open document is checked as the editor has it, not as it is on disk
+	opened := "file:///open.css"
+	require.NoError(t, ctx.DocumentManager().DidOpen(opened, "css", 3, `.a { margin: var(--spacing-old); padding: var(--spacing-old); }`))
+	req := types.NewRequestContext(ctx, &glsp.Context{})
+
+	first, err := WorkspaceDiagnostic(req, &WorkspaceDiagnosticParams{})
+	require.NoError(t, err)
+
+	reports := map[string]WorkspaceFullDocumentDiagnosticReport{}
+	for _, item := range first.Items {
+		report, ok := item.(WorkspaceFullDocumentDiagnosticReport)
+		require.True(t, ok, "first pull should only have full reports")
+		reports[report.URI] = report
+	}
+	require.ElementsMatch(t, []string{button, card, tokenFile, opened}, slices.Collect(maps.Keys(reports)))
+
+	t.Run("reports files which were never opened", func(t *testing.T) {
+		assert.Len(t, reports[button].Items, 1)
+		assert.Nil(t, reports[button].Version)
+		assert.Empty(t, reports[card].Items)
+		require.Len(t, reports[tokenFile].Items, 1)
+		assert.Equal(t, "Unknown design token color.missing", reports[tokenFile].Items[0].Message)
+	})
+
+	t.Run("reports open documents with their version", func(t *testing.T) {
+		assert.Len(t, reports[opened].Items, 2)
+		require.NotNil(t, reports[opened].Version)
+		assert.Equal(t, protocol.Integer(3), *reports[opened].Version)
+	})
+
+	t.Run("unchanged diagnostics return unchanged reports", func(t *testing.T) {
+		var previous []PreviousResultID
+		for uri, report := range reports {
+			previous = append(previous, PreviousResultID{URI: uri, Value: report.ResultID})
+		}
+		require.NoError(t, ctx.DocumentManager().DidOpen(opened, "css", 4, `.a { margin: var(--spacing-old); }`))
+
+		second, err := WorkspaceDiagnostic(req, &WorkspaceDiagnosticParams{PreviousResultIDs: previous})
+		require.NoError(t, err)
+		require.Len(t, second.Items, 4)
+		for _, item := range second.Items {
+			switch report := item.(type) {
+			case WorkspaceUnchangedDocumentDiagnosticReport:
+				assert.NotEqual(t, opened, report.URI)
+				assert.Equal(t, reports[report.URI].ResultID, report.ResultID)
+			case WorkspaceFullDocumentDiagnosticReport:
+				assert.Equal(t, opened, report.URI, "only the edited document changed")
+				assert.Len(t, report.Items, 1)
+			}
+		}
+	})
+
+	t.Run("reports nothing when diagnostics are disabled", func(t *testing.T) {
+		disabled := false
+		ctx.SetConfig(types.ServerConfig{Features: types.FeatureToggles{Diagnostics: &disabled}})
+		report, err := WorkspaceDiagnostic(types.NewRequestContext(ctx, &glsp.Context{}), &WorkspaceDiagnosticParams{})
+		require.NoError(t, err)
+		assert.Empty(t, report.Items)
+	})
+}
+
+// workspaceFileItems pulls workspace diagnostics and returns the items of
+// the full report for uri
+func workspaceFileItems(t *testing.T, req *types.RequestContext, uri string) []protocol.Diagnostic {
+	t.Helper()
+	report, err := WorkspaceDiagnostic(req, &WorkspaceDiagnosticParams{})
+	require.NoError(t, err)
+	for _, item := range report.Items {
+		if full, ok := item.(WorkspaceFullDocumentDiagnosticReport); ok && full.URI == uri {
+			return full.Items
+		}
+	}
+	t.Fatalf("no full report for %s", uri)
+	return nil
+}
+
+func TestWorkspaceDiagnostic_FileCache(t *testing.T) {
+	root := t.TempDir()
+	path := filepath.Join(root, "button.css")
+	uri := uriutil.PathToURI(path)
+	require.NoError(t, os.WriteFile(path, []byte(`.button { padding: var(--spacing-old); }`), 0o600))
+	checked, err := os.Stat(path)
+	require.NoError(t, err)
+
+	ctx := testutil.NewMockServerContext()
+	require.NoError(t, ctx.WorkspaceIndex().Scan(root))
+	require.NoError(t, ctx.TokenManager().Add(&tokens.Token{Name: "spacing.old", Path: []string{"spacing", "old"}, Value: "8px", Deprecated: true}))
+	req := types.NewRequestContext(ctx, &glsp.Context{})
+	require.Len(t, workspaceFileItems(t, req, uri), 1)
+
+	// Same size and modification time: the file isn't read again
+	require.NoError(t, os.WriteFile(path, []byte(`.button { padding: var(--spacing-new); }`), 0o600))
+	require.NoError(t, os.Chtimes(path, checked.ModTime(), checked.ModTime()))
+
+	t.Run("reuses the diagnostics of unchanged files", func(t *testing.T) {
+		items := workspaceFileItems(t, req, uri)
+		require.Len(t, items, 1)
+		assert.Contains(t, items[0].Message, "--spacing-old")
+	})
+
+	t.Run("checks files again when the tokens change", func(t *testing.T) {
+		require.NoError(t, ctx.TokenManager().Add(&tokens.Token{Name: "spacing.new", Path: []string{"spacing", "new"}, Value: "8px", Deprecated: true}))
+		items := workspaceFileItems(t, req, uri)
+		require.Len(t, items, 1)
+		assert.Contains(t, items[0].Message, "--spacing-new")
+	})
+
+	t.Run("checks files again when they change", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(path, []byte(`.button { padding: 8px; }`), 0o600))
+		later := checked.ModTime().Add(time.Second)
+		require.NoError(t, os.Chtimes(path, later, later))
+		assert.Empty(t, workspaceFileItems(t, req, uri))
+	})
+}
+
+func TestWorkspaceDiagnostic_PartialResults(t *testing.T) {
+	ctx := testutil.NewMockServerContext()
+	var batches []protocol.ProgressParams
+	req := types.NewRequestContext(ctx, &glsp.Context{
+		Notify: func(method string, params any) {
+			require.Equal(t, protocol.MethodProgress, method)
+			batches = append(batches, params.(protocol.ProgressParams))
+		},
+	})
+	for i := range partialResultSize + 1 {
+		require.NoError(t, ctx.DocumentManager().DidOpen(fmt.Sprintf("file:///%03d.css", i), "css", 1, `.a { color: red; }`))
+	}
+
+	token := protocol.ProgressToken{Value: "diagnostics-1"}
+	report, err := WorkspaceDiagnostic(req, &WorkspaceDiagnosticParams{PartialResultToken: &token})
+	require.NoError(t, err)
+
+	assert.Empty(t, report.Items, "the reports were all sent with $/progress")
+	require.Len(t, batches, 2)
+	assert.Equal(t, token, batches[0].Token)
+	assert.Len(t, batches[0].Value.(WorkspaceDiagnosticReportPartialResult).Items, partialResultSize)
+	assert.Len(t, batches[1].Value.(WorkspaceDiagnosticReportPartialResult).Items, 1)
+}
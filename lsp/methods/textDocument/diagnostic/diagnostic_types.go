@@ -51,6 +51,19 @@ type RelatedFullDocumentDiagnosticReport struct {
 	RelatedDocuments map[string]any `json:"relatedDocuments,omitempty"`
 }
 
+// RelatedUnchangedDocumentDiagnosticReport represents a diagnostic report indicating
+// that nothing has changed since the report with the given result id
+type RelatedUnchangedDocumentDiagnosticReport struct {
+	// The kind of diagnostic report
+	Kind string `json:"kind"`
+
+	// The result id of the previous report, which is still valid
+	ResultID string `json:"resultId"`
+
+	// Related documents (not used in our implementation)
+	RelatedDocuments map[string]any `json:"relatedDocuments,omitempty"`
+}
+
 // DiagnosticOptions represents server capabilities for pull diagnostics
 type DiagnosticOptions struct {
 	// Whether the server has inter-file dependencies
@@ -59,3 +72,77 @@ type DiagnosticOptions struct {
 	// Whether the server supports workspace diagnostics
 	WorkspaceDiagnostics bool `json:"workspaceDiagnostics"`
 }
+
+// WorkspaceDiagnosticParams represents the parameters for workspace/diagnostic request
+//
+// See: https://microsoft.github.io/language-server-protocol/specifications/lsp/3.17/specification/#workspace_diagnostic
+type WorkspaceDiagnosticParams struct {
+	// The additional identifier provided during registration
+	Identifier string `json:"identifier,omitempty"`
+
+	// The result ids the client has for the documents it was sent reports for
+	PreviousResultIDs []PreviousResultID `json:"previousResultIds"`
+
+	// A token to report partial results through, if the client wants them
+	PartialResultToken *protocol.ProgressToken `json:"partialResultToken,omitempty"`
+}
+
+// PreviousResultID is the result id of a document's last diagnostic report
+type PreviousResultID struct {
+	// The URI of the document
+	URI protocol.DocumentUri `json:"uri"`
+
+	// The result id of the report
+	Value string `json:"value"`
+}
+
+// WorkspaceDiagnosticReport represents a workspace diagnostic report
+type WorkspaceDiagnosticReport struct {
+	// The reports of the workspace's documents, each a
+	// WorkspaceFullDocumentDiagnosticReport or a
+	// WorkspaceUnchangedDocumentDiagnosticReport
+	Items []any `json:"items"`
+}
+
+// WorkspaceDiagnosticReportPartialResult is a batch of a workspace
+// diagnostic report's items, sent with $/progress
+type WorkspaceDiagnosticReportPartialResult struct {
+	// The reports of some of the workspace's documents
+	Items []any `json:"items"`
+}
+
+// WorkspaceFullDocumentDiagnosticReport represents a full diagnostic report
+// of a document in the workspace
+type WorkspaceFullDocumentDiagnosticReport struct {
+	// The kind of diagnostic report
+	Kind string `json:"kind"`
+
+	// An optional result id
+	ResultID string `json:"resultId,omitempty"`
+
+	// The URI of the document
+	URI protocol.DocumentUri `json:"uri"`
+
+	// The version of the document, or null for documents which are not open
+	Version *protocol.Integer `json:"version"`
+
+	// The actual items
+	Items []protocol.Diagnostic `json:"items"`
+}
+
+// WorkspaceUnchangedDocumentDiagnosticReport represents a diagnostic report
+// of a document in the workspace indicating that nothing has changed since
+// the report with the given result id
+type WorkspaceUnchangedDocumentDiagnosticReport struct {
+	// The kind of diagnostic report
+	Kind string `json:"kind"`
+
+	// The result id of the previous report, which is still valid
+	ResultID string `json:"resultId"`
+
+	// The URI of the document
+	URI protocol.DocumentUri `json:"uri"`
+
+	// The version of the document, or null for documents which are not open
+	Version *protocol.Integer `json:"version"`
+}
@@ -0,0 +1,48 @@
+package diagnostic
+
+import (
+	"bennypowers.dev/dtls/internal/i18n"
+	cssparser "bennypowers.dev/dtls/internal/parser/css"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+// fallbackChainDiagnostics checks the tokens in a nested fallback chain such as
+// var(--a, var(--b, 4px)). The terminal literal is checked against the last
+// token's value by the incorrect-fallback check on the innermost call.
+func fallbackChainDiagnostics(ctx Context, lookup tokenLookup, head *cssparser.VarCall) []protocol.Diagnostic {
+	var diagnostics []protocol.Diagnostic
+	seen := map[string]bool{head.TokenName: true}
+
+	for _, link := range head.Chain()[1:] {
+		switch {
+		case seen[link.TokenName]:
+			diagnostics = append(diagnostics, chainDiagnostic(link,
+				i18n.Sprintf(ctx.Locale(), "%s appears more than once in the fallback chain of %s", link.TokenName, head.TokenName)))
+		case lookup.unknown(link.TokenName):
+			diagnostics = append(diagnostics, chainDiagnostic(link,
+				i18n.Sprintf(ctx.Locale(), "Unknown token %s in the fallback chain of %s", link.TokenName, head.TokenName)))
+		}
+		seen[link.TokenName] = true
+	}
+
+	return diagnostics
+}
+
+// chainDiagnostic creates a warning on one link of a fallback chain
+func chainDiagnostic(link *cssparser.VarCall, message string) protocol.Diagnostic {
+	severity := protocol.DiagnosticSeverityWarning
+	return protocol.Diagnostic{
+		Range: protocol.Range{
+			Start: protocol.Position{
+				Line:      link.Range.Start.Line,
+				Character: link.Range.Start.Character,
+			},
+			End: protocol.Position{
+				Line:      link.Range.End.Line,
+				Character: link.Range.End.Character,
+			},
+		},
+		Severity: &severity,
+		Message:  message,
+	}
+}
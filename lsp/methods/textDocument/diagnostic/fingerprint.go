@@ -0,0 +1,20 @@
+package diagnostic
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+// Fingerprint returns a stable hash of a diagnostics list
+func Fingerprint(diagnostics []protocol.Diagnostic) string {
+	data, err := json.Marshal(diagnostics)
+	if err != nil {
+		// Unreachable for protocol.Diagnostic, but never report "unchanged" on failure
+		return ""
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
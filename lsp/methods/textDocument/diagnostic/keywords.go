@@ -0,0 +1,112 @@
+package diagnostic
+
+import (
+	"strings"
+
+	"bennypowers.dev/dtls/internal/documents"
+	"bennypowers.dev/dtls/internal/i18n"
+	"bennypowers.dev/dtls/internal/parser/tokenfile"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+	"gopkg.in/yaml.v3"
+)
+
+// KeywordSuggestionKey is the key of the suggested DTCG keyword in the Data of
+// diagnostics for unknown $-prefixed properties, used by the quick fix
+const KeywordSuggestionKey = "keywordSuggestion"
+
+// dtcgKeywords are the $-prefixed properties the DTCG format defines
+var dtcgKeywords = []string{
+	"$value", "$type", "$description", "$extensions", "$deprecated",
+	"$schema", "$extends", "$ref", "$root",
+}
+
+// maxKeywordDistance is the largest edit distance at which an unknown
+// property is taken to be a typo of a keyword
+const maxKeywordDistance = 2
+
+// keywordDiagnostics reports $-prefixed properties in a token file which are
+// not DTCG keywords, such as "$descripton", suggesting the nearest keyword
+func keywordDiagnostics(locale string, doc *documents.Document) []protocol.Diagnostic {
+	diagnostics := []protocol.Diagnostic{}
+	root := tokenfile.Outline(doc.Content(), doc.LanguageID() != "yaml")
+	if root == nil {
+		return diagnostics
+	}
+
+	lines := strings.Split(doc.Content(), "\n")
+	var walk func(node *yaml.Node)
+	walk = func(node *yaml.Node) {
+		if node.Kind != yaml.MappingNode {
+			return
+		}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			// $root is a token; other keywords, such as $value and
+			// $extensions, hold data rather than tokens
+			if !strings.HasPrefix(key.Value, "$") || key.Value == "$root" {
+				walk(value)
+				continue
+			}
+			if isKeyword(key.Value) {
+				continue
+			}
+
+			severity := protocol.DiagnosticSeverityError
+			diag := protocol.Diagnostic{
+				Range:    scalarRange(lines, key),
+				Severity: &severity,
+				Message:  i18n.Sprintf(locale, "Unknown property %s", key.Value),
+			}
+			if suggestion := nearestKeyword(key.Value); suggestion != "" {
+				diag.Message += i18n.Sprintf(locale, ". Did you mean %s?", suggestion)
+				diag.Data = map[string]any{KeywordSuggestionKey: suggestion}
+			}
+			diagnostics = append(diagnostics, diag)
+		}
+	}
+	walk(root)
+	return diagnostics
+}
+
+// isKeyword reports whether name is a DTCG keyword
+func isKeyword(name string) bool {
+	for _, keyword := range dtcgKeywords {
+		if name == keyword {
+			return true
+		}
+	}
+	return false
+}
+
+// nearestKeyword returns the keyword closest to name, or "" if none is close
+func nearestKeyword(name string) string {
+	nearest, best := "", maxKeywordDistance+1
+	for _, keyword := range dtcgKeywords {
+		if d := editDistance(strings.ToLower(name), keyword); d < best {
+			nearest, best = keyword, d
+		}
+	}
+	return nearest
+}
+
+// editDistance returns the Levenshtein distance between a and b
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(rb)]
+}
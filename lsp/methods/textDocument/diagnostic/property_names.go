@@ -0,0 +1,49 @@
+package diagnostic
+
+import (
+	"slices"
+	"strings"
+
+	"bennypowers.dev/dtls/internal/i18n"
+	cssparser "bennypowers.dev/dtls/internal/parser/css"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+// unknownPropertyNameDiagnostic warns about a custom property named in a JS/TS
+// string, e.g. getPropertyValue('--ds-colour'), which carries a token prefix but
+// names no token. Other unknown names may be local properties, so they pass.
+// prefixes is filled on first use and reused for the rest of the document.
+func unknownPropertyNameDiagnostic(ctx Context, call *cssparser.VarCall, prefixes *[]string) *protocol.Diagnostic {
+	if *prefixes == nil {
+		*prefixes = tokenPrefixes(ctx)
+	}
+	for _, prefix := range *prefixes {
+		if strings.HasPrefix(call.TokenName, prefix) {
+			diag := chainDiagnostic(call, i18n.Sprintf(ctx.Locale(), "Unknown design token %s", call.TokenName))
+			return &diag
+		}
+	}
+	return nil
+}
+
+// tokenPrefixes returns the distinct CSS variable prefixes of the loaded
+// tokens, with their separator, e.g. "--ds-". Never nil.
+func tokenPrefixes(ctx Context) []string {
+	prefixes := []string{}
+	manager := ctx.TokenManager()
+	for _, token := range manager.GetAll() {
+		if token.Prefix == "" {
+			continue
+		}
+		name := manager.CSSVariableName(token)
+		start := "--" + token.Prefix
+		if !strings.HasPrefix(name, start) || len(name) <= len(start) {
+			continue
+		}
+		prefix := name[:len(start)+1]
+		if !slices.Contains(prefixes, prefix) {
+			prefixes = append(prefixes, prefix)
+		}
+	}
+	return prefixes
+}
@@ -0,0 +1,99 @@
+// Package resultcache tracks pull diagnostics result IDs per document.
+//
+// It is separate from the diagnostic package so that test helpers can
+// construct a cache without importing the diagnostic handlers.
+package resultcache
+
+import (
+	"fmt"
+	"sync"
+
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+// cacheEntry is the last diagnostics result reported for a document
+type cacheEntry struct {
+	resultID    string
+	fingerprint string
+
+	// stamp and diagnostics are kept for files checked as they are on disk
+	// (see StoreFile)
+	stamp       string
+	diagnostics []protocol.Diagnostic
+}
+
+// Cache tracks pull diagnostics result IDs per document.
+// It implements types.DiagnosticResultCacher interface.
+//
+// Result IDs are tied to a fingerprint of the diagnostics content rather than to
+// the document version, so token reloads and configuration changes that alter
+// diagnostics for an unchanged document still produce a new result ID.
+//
+// The zero value is ready to use.
+type Cache struct {
+	mu      sync.Mutex
+	byURI   map[string]cacheEntry
+	counter uint64
+}
+
+// New creates a new Cache
+func New() *Cache {
+	return &Cache{
+		byURI: make(map[string]cacheEntry),
+	}
+}
+
+// Store records the fingerprint of a document's diagnostics and returns its result ID.
+// If the fingerprint matches the previous one for this URI, the previous result ID
+// is returned; otherwise a new unique result ID is issued.
+func (c *Cache) Store(uri, fingerprint string) string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.store(uri, cacheEntry{fingerprint: fingerprint})
+}
+
+// StoreFile is Store for a file checked as it is on disk. It also keeps the
+// diagnostics, which File returns for as long as the stamp is the same. The
+// stamp identifies the file's content and whatever else its diagnostics
+// depend on.
+func (c *Cache) StoreFile(uri, stamp, fingerprint string, diagnostics []protocol.Diagnostic) string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.store(uri, cacheEntry{fingerprint: fingerprint, stamp: stamp, diagnostics: diagnostics})
+}
+
+// File returns the diagnostics and result ID StoreFile stored for a file,
+// if it stored them with the same stamp
+func (c *Cache) File(uri, stamp string) ([]protocol.Diagnostic, string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.byURI[uri]
+	if !ok || entry.stamp == "" || entry.stamp != stamp {
+		return nil, "", false
+	}
+	return entry.diagnostics, entry.resultID, true
+}
+
+// store records entry for uri, keeping the previous result ID if the
+// fingerprint is unchanged. The caller holds mu.
+func (c *Cache) store(uri string, entry cacheEntry) string {
+	if previous, ok := c.byURI[uri]; ok && previous.fingerprint == entry.fingerprint {
+		entry.resultID = previous.resultID
+	} else {
+		c.counter++
+		entry.resultID = fmt.Sprintf("diag-%d", c.counter)
+	}
+
+	if c.byURI == nil {
+		c.byURI = make(map[string]cacheEntry)
+	}
+	c.byURI[uri] = entry
+	return entry.resultID
+}
+
+// Invalidate removes the cache entry for a document URI
+func (c *Cache) Invalidate(uri string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.byURI, uri)
+}
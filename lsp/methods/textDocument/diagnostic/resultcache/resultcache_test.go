@@ -0,0 +1,81 @@
+package resultcache_test
+
+import (
+	"testing"
+
+	"bennypowers.dev/dtls/lsp/methods/textDocument/diagnostic/resultcache"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+func TestCache_Store(t *testing.T) {
+	t.Run("same fingerprint reuses the result id", func(t *testing.T) {
+		cache := resultcache.New()
+		first := cache.Store("file:///a.css", "abc")
+		assert.Equal(t, first, cache.Store("file:///a.css", "abc"))
+	})
+
+	t.Run("new fingerprint issues a new result id", func(t *testing.T) {
+		cache := resultcache.New()
+		first := cache.Store("file:///a.css", "abc")
+		assert.NotEqual(t, first, cache.Store("file:///a.css", "def"))
+	})
+
+	t.Run("result ids are unique across documents", func(t *testing.T) {
+		cache := resultcache.New()
+		assert.NotEqual(t, cache.Store("file:///a.css", "abc"), cache.Store("file:///b.css", "abc"))
+	})
+
+	t.Run("zero value is usable", func(t *testing.T) {
+		var cache resultcache.Cache
+		assert.NotEmpty(t, cache.Store("file:///a.css", "abc"))
+	})
+}
+
+func TestCache_Invalidate(t *testing.T) {
+	cache := resultcache.New()
+	first := cache.Store("file:///a.css", "abc")
+
+	cache.Invalidate("file:///a.css")
+
+	assert.NotEqual(t, first, cache.Store("file:///a.css", "abc"), "invalidated documents get a fresh result id")
+}
+
+func TestCache_File(t *testing.T) {
+	diagnostics := []protocol.Diagnostic{{Message: "stale"}}
+
+	t.Run("returns diagnostics stored with the same stamp", func(t *testing.T) {
+		cache := resultcache.New()
+		resultID := cache.StoreFile("file:///a.css", "1", "abc", diagnostics)
+
+		cached, cachedID, ok := cache.File("file:///a.css", "1")
+		require.True(t, ok)
+		assert.Equal(t, diagnostics, cached)
+		assert.Equal(t, resultID, cachedID)
+	})
+
+	t.Run("misses on another stamp", func(t *testing.T) {
+		cache := resultcache.New()
+		cache.StoreFile("file:///a.css", "1", "abc", diagnostics)
+		_, _, ok := cache.File("file:///a.css", "2")
+		assert.False(t, ok)
+	})
+
+	t.Run("misses for documents stored without one", func(t *testing.T) {
+		cache := resultcache.New()
+		cache.StoreFile("file:///a.css", "1", "abc", diagnostics)
+		cache.Store("file:///a.css", "abc")
+		_, _, ok := cache.File("file:///a.css", "1")
+		assert.False(t, ok, "the open document's diagnostics replace the file's")
+		_, _, ok = cache.File("file:///b.css", "")
+		assert.False(t, ok)
+	})
+
+	t.Run("keeps the result id while the fingerprint is the same", func(t *testing.T) {
+		cache := resultcache.New()
+		first := cache.StoreFile("file:///a.css", "1", "abc", diagnostics)
+		assert.Equal(t, first, cache.StoreFile("file:///a.css", "2", "abc", diagnostics))
+		assert.Equal(t, first, cache.Store("file:///a.css", "abc"))
+	})
+}
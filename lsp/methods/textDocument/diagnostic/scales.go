@@ -0,0 +1,227 @@
+package diagnostic
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"bennypowers.dev/dtls/internal/documents"
+	"bennypowers.dev/dtls/internal/i18n"
+	"bennypowers.dev/dtls/internal/log"
+	"bennypowers.dev/dtls/internal/parser/tokenfile"
+	"bennypowers.dev/dtls/internal/position"
+	"bennypowers.dev/dtls/lsp/types"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+	"gopkg.in/yaml.v3"
+)
+
+// ScaleSuggestionKey is the key of the suggested value in the Data of
+// diagnostics for tokens off their scale, used by the quick fix
+const ScaleSuggestionKey = "scaleSuggestion"
+
+// scaleTolerance is how far, relative to the nearest step, a value may be
+// from a modular scale, allowing for steps rounded to whole pixels
+const scaleTolerance = 0.01
+
+// scaleNumberPattern matches a number with an optional unit, e.g. 4px or 1.5
+var scaleNumberPattern = regexp.MustCompile(`^(-?(?:\d+\.?\d*|\.\d+))([a-zA-Z%]*)$`)
+
+// dimension is a number with an optional unit
+type dimension struct {
+	n    float64
+	unit string
+}
+
+func parseDimension(s string) (dimension, bool) {
+	match := scaleNumberPattern.FindStringSubmatch(strings.TrimSpace(s))
+	if match == nil {
+		return dimension{}, false
+	}
+	n, err := strconv.ParseFloat(match[1], 64)
+	return dimension{n: n, unit: match[2]}, err == nil
+}
+
+func (d dimension) String() string {
+	return strconv.FormatFloat(math.Round(d.n*1e4)/1e4, 'f', -1, 64) + d.unit
+}
+
+// scaleDiagnostics checks the tokens of each configured scale group in a
+// token file, warning about values off the scale and suggesting the nearest
+// value on it
+func scaleDiagnostics(locale string, scales []types.ScaleRule, doc *documents.Document) []protocol.Diagnostic {
+	diagnostics := []protocol.Diagnostic{}
+	if len(scales) == 0 {
+		return diagnostics
+	}
+
+	root := tokenfile.Outline(doc.Content(), doc.LanguageID() != "yaml")
+	if root == nil {
+		return diagnostics
+	}
+
+	lines := strings.Split(doc.Content(), "\n")
+	for _, rule := range scales {
+		group := mappingAt(root, strings.Split(rule.Group, "."))
+		if group == nil {
+			continue
+		}
+		check, err := scaleCheck(locale, rule)
+		if err != nil {
+			log.Warn("Ignoring scale %q: %v", rule.Group, err)
+			continue
+		}
+
+		for i := 0; i+1 < len(group.Content); i += 2 {
+			name := group.Content[i].Value
+			if strings.HasPrefix(name, "$") {
+				continue
+			}
+			valueNode, value, ok := scaleTokenValue(group.Content[i+1])
+			if !ok {
+				continue
+			}
+
+			message, suggestion := check(value)
+			if message == "" {
+				continue
+			}
+			path := rule.Group + "." + name
+			severity := protocol.DiagnosticSeverityWarning
+			diag := protocol.Diagnostic{
+				Range:    scalarRange(lines, valueNode),
+				Severity: &severity,
+				Message:  i18n.Sprintf(locale, "%s is %s, %s", path, value, message),
+			}
+			if suggestion != nil {
+				text := suggestion.String()
+				if valueNode.Tag != "!!str" {
+					// Bare numbers, e.g. in {"value": 4, "unit": "px"}
+					text = dimension{n: suggestion.n}.String()
+				}
+				diag.Message += i18n.Sprintf(locale, ". Did you mean %s?", suggestion)
+				diag.Data = map[string]any{ScaleSuggestionKey: text}
+			}
+			diagnostics = append(diagnostics, diag)
+		}
+	}
+	return diagnostics
+}
+
+// scaleCheck returns a function which checks a value against a scale rule,
+// returning a message and the nearest value on the scale when it is off
+func scaleCheck(locale string, rule types.ScaleRule) (func(dimension) (string, *dimension), error) {
+	unitMismatch := func(unit string) string {
+		return i18n.Sprintf(locale, "but the scale is in %q", unit)
+	}
+
+	if rule.Step != "" {
+		step, ok := parseDimension(rule.Step)
+		if !ok || step.n <= 0 {
+			return nil, fmt.Errorf("invalid step %q", rule.Step)
+		}
+		return func(value dimension) (string, *dimension) {
+			if value.unit != step.unit {
+				return unitMismatch(step.unit), nil
+			}
+			q := value.n / step.n
+			if math.Abs(q-math.Round(q)) < 1e-9 {
+				return "", nil
+			}
+			nearest := math.Max(math.Round(q), 1) * step.n
+			return i18n.Sprintf(locale, "which is not a multiple of %s", step), &dimension{n: nearest, unit: step.unit}
+		}, nil
+	}
+
+	base, ok := parseDimension(rule.Base)
+	if !ok || base.n <= 0 {
+		return nil, fmt.Errorf("invalid base %q", rule.Base)
+	}
+	if rule.Ratio <= 1 {
+		return nil, fmt.Errorf("invalid ratio %g", rule.Ratio)
+	}
+	return func(value dimension) (string, *dimension) {
+		if value.unit != base.unit {
+			return unitMismatch(base.unit), nil
+		}
+		if value.n <= 0 {
+			return "", nil
+		}
+		power := math.Round(math.Log(value.n/base.n) / math.Log(rule.Ratio))
+		nearest := base.n * math.Pow(rule.Ratio, power)
+		if math.Abs(value.n-nearest) <= scaleTolerance*nearest {
+			return "", nil
+		}
+		return i18n.Sprintf(locale, "which is not on the %g modular scale from %s", rule.Ratio, base), &dimension{n: nearest, unit: base.unit}
+	}, nil
+}
+
+// mappingAt returns the mapping node at path below node, or nil
+func mappingAt(node *yaml.Node, path []string) *yaml.Node {
+	for _, key := range path {
+		if node.Kind != yaml.MappingNode {
+			return nil
+		}
+		var next *yaml.Node
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if node.Content[i].Value == key {
+				next = node.Content[i+1]
+				break
+			}
+		}
+		if next == nil {
+			return nil
+		}
+		node = next
+	}
+	if node.Kind != yaml.MappingNode {
+		return nil
+	}
+	return node
+}
+
+// scaleTokenValue returns a token's numeric $value and the scalar node holding
+// its number. Aliases, groups and other values are skipped.
+func scaleTokenValue(token *yaml.Node) (*yaml.Node, dimension, bool) {
+	value := tokenfile.MappingValue(token, "$value")
+	if value == nil {
+		return nil, dimension{}, false
+	}
+	switch value.Kind {
+	case yaml.ScalarNode:
+		d, ok := parseDimension(value.Value)
+		return value, d, ok
+	case yaml.MappingNode:
+		// DTCG dimension objects: {"value": 4, "unit": "px"}
+		n := tokenfile.MappingValue(value, "value")
+		if n == nil || n.Kind != yaml.ScalarNode || n.Tag == "!!str" {
+			return nil, dimension{}, false
+		}
+		d, ok := parseDimension(n.Value)
+		if unit := tokenfile.MappingValue(value, "unit"); unit != nil {
+			d.unit = unit.Value
+		}
+		return n, d, ok
+	}
+	return nil, dimension{}, false
+}
+
+// scalarRange returns the range of a scalar's text, without quotes.
+// yaml.v3 columns count runes from 1 and point at the opening quote.
+func scalarRange(lines []string, node *yaml.Node) protocol.Range {
+	line := uint32(max(node.Line-1, 0)) //nolint:gosec // G115: yaml line numbers are bounded by file size
+	start := max(node.Column-1, 0)
+	if node.Style&(yaml.DoubleQuotedStyle|yaml.SingleQuotedStyle) != 0 {
+		start++
+	}
+	var character uint32
+	if node.Line >= 1 && node.Line <= len(lines) {
+		runes := []rune(lines[node.Line-1])
+		character = position.StringLengthUTF16Uint32(string(runes[:min(start, len(runes))]))
+	}
+	return protocol.Range{
+		Start: protocol.Position{Line: line, Character: character},
+		End:   protocol.Position{Line: line, Character: character + position.StringLengthUTF16Uint32(node.Value)},
+	}
+}
@@ -0,0 +1,260 @@
+package diagnostic
+
+import (
+	"slices"
+	"strings"
+
+	"bennypowers.dev/dtls/internal/documents"
+	"bennypowers.dev/dtls/internal/i18n"
+	"bennypowers.dev/dtls/internal/parser/tokenfile"
+	"bennypowers.dev/dtls/internal/tokens"
+	"bennypowers.dev/dtls/internal/uriutil"
+	"bennypowers.dev/dtls/lsp/types"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+	"gopkg.in/yaml.v3"
+)
+
+// valueShape is what a $value of a token type may be
+type valueShape struct {
+	accepts func(value *yaml.Node) bool
+	// description completes "its $value is not ...", e.g. "a number"
+	description string
+}
+
+// valueShapes are the $value shapes of the DTCG token types. Aliases,
+// expressions and $ref pointers may stand for any of them.
+var valueShapes = map[string]valueShape{
+	"color":       {anyOf(isString, isMapping), "a color string or object"},
+	"dimension":   {anyOf(isString, isMapping, isZero), "a dimension string or object, such as 4px"},
+	"duration":    {anyOf(isString, isMapping, isZero), "a duration string or object, such as 200ms"},
+	"number":      {isNumber, "a number"},
+	"fontWeight":  {anyOf(isNumber, isString), "a number or a string"},
+	"fontFamily":  {anyOf(isString, sequenceOf(isString)), "a string or an array of strings"},
+	"cubicBezier": {isCubicBezier, "an array of 4 numbers"},
+	"strokeStyle": {anyOf(isString, isMapping), "a string or an object"},
+	"border":      {isMapping, "an object"},
+	"transition":  {isMapping, "an object"},
+	"typography":  {isMapping, "an object"},
+	"shadow":      {anyOf(isMapping, sequenceOf(isMapping)), "an object or an array of objects"},
+	"gradient":    {isSequence, "an array"},
+}
+
+// structureDiagnostics checks how a token file defines its tokens: $type
+// values which are not DTCG types, $values which don't fit their token's
+// type, keys defined twice in a group, tokens which also contain tokens or
+// groups, and tokens whose CSS variable names collide once prefixed
+func structureDiagnostics(ctx Context, cfg types.ServerConfig, doc *documents.Document) []protocol.Diagnostic {
+	diagnostics := []protocol.Diagnostic{}
+	root := tokenfile.Outline(doc.Content(), doc.LanguageID() != "yaml")
+	if root == nil {
+		return diagnostics
+	}
+
+	locale := ctx.Locale()
+	lines := strings.Split(doc.Content(), "\n")
+	report := func(node *yaml.Node, severity protocol.DiagnosticSeverity, message string) {
+		diagnostics = append(diagnostics, protocol.Diagnostic{
+			Range:    scalarRange(lines, node),
+			Severity: &severity,
+			Message:  message,
+		})
+	}
+
+	var walk func(node *yaml.Node, path []string, tokenType string)
+	walk = func(node *yaml.Node, path []string, tokenType string) {
+		if node.Kind != yaml.MappingNode {
+			return
+		}
+
+		seen := map[string]bool{}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key := node.Content[i]
+			if seen[key.Value] {
+				report(key, protocol.DiagnosticSeverityWarning,
+					i18n.Sprintf(locale, "%s is defined more than once", strings.Join(append(path[:len(path):len(path)], key.Value), ".")))
+			}
+			seen[key.Value] = true
+		}
+
+		if typeNode := tokenfile.MappingValue(node, "$type"); typeNode != nil && typeNode.Kind == yaml.ScalarNode {
+			tokenType = typeNode.Value
+			if !slices.Contains(tokens.DTCGTypes, tokenType) {
+				message := i18n.Sprintf(locale, "Unknown token type %s", tokenType)
+				if suggestion := nearestType(tokenType); suggestion != "" {
+					message += i18n.Sprintf(locale, ". Did you mean %s?", suggestion)
+				}
+				report(typeNode, protocol.DiagnosticSeverityWarning, message)
+				// Don't check values against a type which doesn't exist
+				tokenType = ""
+			}
+		}
+
+		name := tokens.TokenPath(strings.Join(path, "."))
+		if value := tokenfile.MappingValue(node, "$value"); value != nil {
+			if shape, ok := valueShapes[tokenType]; ok && !isReference(value) && !shape.accepts(value) {
+				report(valueNode(node, value), protocol.DiagnosticSeverityError,
+					i18n.Sprintf(locale, "%s is a %s token, but its $value is not %s", name, tokenType, i18n.Translate(locale, shape.description)))
+			}
+			// Group markers name tokens which are groups as well
+			if len(path) > 0 && slices.Contains(cfg.GroupMarkers, path[len(path)-1]) {
+				return
+			}
+			for i := 0; i+1 < len(node.Content); i += 2 {
+				if key := node.Content[i]; !strings.HasPrefix(key.Value, "$") {
+					report(key, protocol.DiagnosticSeverityWarning,
+						i18n.Sprintf(locale, "%s is a token, so it cannot contain %s", name, key.Value))
+				}
+			}
+			return
+		}
+
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key := node.Content[i].Value
+			// $root is a token; other keywords hold data rather than tokens
+			if strings.HasPrefix(key, "$") && key != "$root" {
+				continue
+			}
+			walk(node.Content[i+1], append(path[:len(path):len(path)], key), tokenType)
+		}
+	}
+	walk(root, nil, "")
+
+	return append(diagnostics, collisionDiagnostics(ctx, doc, root, lines)...)
+}
+
+// collisionDiagnostics reports the tokens of a token file whose CSS variable
+// names, once prefixed and flattened, are also those of other tokens, e.g.
+// color.primary-dark and color.primary.dark, which are both
+// --color-primary-dark. Only one of them is used.
+func collisionDiagnostics(ctx Context, doc *documents.Document, root *yaml.Node, lines []string) []protocol.Diagnostic {
+	var diagnostics []protocol.Diagnostic
+	path := uriutil.URIToPath(doc.URI())
+	for _, collision := range ctx.TokenManager().Collisions() {
+		for _, token := range collision.Tokens {
+			if token.FilePath != path {
+				continue
+			}
+			key := keyAt(root, token.Path)
+			if key == nil {
+				continue
+			}
+			var others []string
+			for _, other := range collision.Tokens {
+				if other.Prefix != token.Prefix || !slices.Equal(other.Path, token.Path) {
+					others = append(others, tokens.TokenPath(strings.Join(other.Path, ".")))
+				}
+			}
+			if len(others) == 0 {
+				continue
+			}
+			severity := protocol.DiagnosticSeverityWarning
+			diagnostics = append(diagnostics, protocol.Diagnostic{
+				Range:    scalarRange(lines, key),
+				Severity: &severity,
+				Message: i18n.Sprintf(ctx.Locale(), "%s shares the CSS variable %s with %s",
+					tokens.TokenPath(strings.Join(token.Path, ".")), collision.Name, strings.Join(slices.Compact(others), ", ")),
+			})
+		}
+	}
+	return diagnostics
+}
+
+// keyAt returns the key node of the token at path below node, or nil
+func keyAt(node *yaml.Node, path []string) *yaml.Node {
+	if len(path) == 0 {
+		return nil
+	}
+	parent := node
+	if len(path) > 1 {
+		parent = mappingAt(node, path[:len(path)-1])
+	}
+	if parent == nil || parent.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(parent.Content); i += 2 {
+		if parent.Content[i].Value == path[len(path)-1] {
+			return parent.Content[i]
+		}
+	}
+	return nil
+}
+
+// nearestType returns the DTCG type closest to name, or "" if none is close
+func nearestType(name string) string {
+	nearest, best := "", maxKeywordDistance+1
+	for _, tokenType := range tokens.DTCGTypes {
+		if d := editDistance(strings.ToLower(name), strings.ToLower(tokenType)); d < best {
+			nearest, best = tokenType, d
+		}
+	}
+	return nearest
+}
+
+// isReference reports whether a $value stands for another token's value: an
+// alias or expression such as "{spacing.base} * 2", or a $ref pointer
+func isReference(value *yaml.Node) bool {
+	if isString(value) {
+		return strings.Contains(value.Value, "{")
+	}
+	return tokenfile.MappingValue(value, "$ref") != nil
+}
+
+// valueNode returns the node a diagnostic about a token's $value covers:
+// scalars themselves, or the $value key of objects and arrays
+func valueNode(token, value *yaml.Node) *yaml.Node {
+	if value.Kind == yaml.ScalarNode {
+		return value
+	}
+	for i := 0; i+1 < len(token.Content); i += 2 {
+		if token.Content[i+1] == value {
+			return token.Content[i]
+		}
+	}
+	return value
+}
+
+func isString(n *yaml.Node) bool {
+	return n.Kind == yaml.ScalarNode && n.Tag == "!!str"
+}
+
+func isNumber(n *yaml.Node) bool {
+	return n.Kind == yaml.ScalarNode && (n.Tag == "!!int" || n.Tag == "!!float")
+}
+
+// isZero matches 0, the only dimension which may go without a unit
+func isZero(n *yaml.Node) bool {
+	return isNumber(n) && n.Value == "0"
+}
+
+func isMapping(n *yaml.Node) bool {
+	return n.Kind == yaml.MappingNode
+}
+
+func isSequence(n *yaml.Node) bool {
+	return n.Kind == yaml.SequenceNode
+}
+
+// isCubicBezier matches an array of 4 numbers, any of which may be aliases
+func isCubicBezier(n *yaml.Node) bool {
+	return isSequence(n) && len(n.Content) == 4 && sequenceOf(anyOf(isNumber, isReference))(n)
+}
+
+func anyOf(checks ...func(*yaml.Node) bool) func(*yaml.Node) bool {
+	return func(n *yaml.Node) bool {
+		return slices.ContainsFunc(checks, func(check func(*yaml.Node) bool) bool { return check(n) })
+	}
+}
+
+func sequenceOf(check func(*yaml.Node) bool) func(*yaml.Node) bool {
+	return func(n *yaml.Node) bool {
+		if !isSequence(n) {
+			return false
+		}
+		for _, item := range n.Content {
+			if !check(item) {
+				return false
+			}
+		}
+		return true
+	}
+}
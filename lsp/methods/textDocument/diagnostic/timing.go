@@ -0,0 +1,42 @@
+package diagnostic
+
+import (
+	"bennypowers.dev/dtls/internal/i18n"
+	cssparser "bennypowers.dev/dtls/internal/parser/css"
+	"bennypowers.dev/dtls/internal/tokens"
+	csshelpers "bennypowers.dev/dtls/lsp/helpers/css"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+// timingDiagnostics checks a duration or cubicBezier token used in a
+// transition or animation property. A token value which is malformed there,
+// e.g. a duration without a time unit, is a warning; a malformed literal
+// fallback is an error, reported by malformedFallback so the caller can skip
+// the incorrect-fallback check, which would only repeat it.
+func timingDiagnostics(ctx Context, varCall *cssparser.VarCall, token *tokens.Token) (diagnostics []protocol.Diagnostic, malformedFallback bool) {
+	if varCall.Property == "" {
+		return nil, false
+	}
+
+	if err := csshelpers.TimingValueError(varCall.Property, token.Type, csshelpers.TimingTokenValue(token)); err != nil {
+		severity := protocol.DiagnosticSeverityWarning
+		diagnostics = append(diagnostics, protocol.Diagnostic{
+			Range:    csshelpers.ToProtocolRange(varCall.Range),
+			Severity: &severity,
+			Message:  i18n.Sprintf(ctx.Locale(), "%s is malformed in %s: %v", varCall.TokenName, varCall.Property, err),
+		})
+	}
+
+	if varCall.Fallback != nil && varCall.FallbackVar == nil {
+		if err := csshelpers.TimingValueError(varCall.Property, token.Type, *varCall.Fallback); err != nil {
+			severity := protocol.DiagnosticSeverityError
+			diagnostics = append(diagnostics, protocol.Diagnostic{
+				Range:    csshelpers.ToProtocolRange(varCall.Range),
+				Severity: &severity,
+				Message:  i18n.Sprintf(ctx.Locale(), "Token fallback is malformed in %s: %v", varCall.Property, err),
+			})
+			malformedFallback = true
+		}
+	}
+	return diagnostics, malformedFallback
+}
@@ -0,0 +1,27 @@
+package diagnostic
+
+import (
+	"bennypowers.dev/dtls/internal/tokens"
+	"bennypowers.dev/dtls/lsp/types"
+)
+
+// tokenLookup finds the tokens a document names: the workspace's, then, for
+// a document in node_modules, its package's
+type tokenLookup struct {
+	ctx    Context
+	vendor *types.VendorTokens
+}
+
+// token returns the token named name, or nil
+func (l tokenLookup) token(name string) *tokens.Token {
+	if token := l.ctx.Token(name); token != nil {
+		return token
+	}
+	return l.vendor.Token(name)
+}
+
+// unknown reports whether name is no token, and doesn't carry the prefix of
+// the document's package, whose token files may not be discoverable
+func (l tokenLookup) unknown(name string) bool {
+	return l.token(name) == nil && !l.vendor.Owns(name)
+}
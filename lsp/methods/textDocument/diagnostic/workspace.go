@@ -0,0 +1,203 @@
+package diagnostic
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"bennypowers.dev/dtls/internal/documents"
+	"bennypowers.dev/dtls/internal/log"
+	"bennypowers.dev/dtls/internal/metrics"
+	"bennypowers.dev/dtls/internal/parser"
+	"bennypowers.dev/dtls/internal/uriutil"
+	"bennypowers.dev/dtls/lsp/types"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+// partialResultSize is how many documents' reports are sent in each
+// $/progress notification, when the client asks for partial results
+const partialResultSize = 50
+
+// WorkspaceDiagnostic handles the workspace/diagnostic request (LSP 3.17),
+// reporting diagnostics for the files of the workspace index and the loaded
+// token files as well as the open documents, so problems show up in files
+// which were never opened. Open documents are checked as the editor has
+// them; other files as they are on disk, and only again once they, the
+// tokens or the configuration change. Documents whose diagnostics didn't
+// change since the client's previous result id get unchanged reports.
+//
+// Clients passing a partialResultToken get the reports in batches as they
+// are made.
+//
+// Like textDocument/diagnostic, this is called via CustomHandler.
+func WorkspaceDiagnostic(req *types.RequestContext, params *WorkspaceDiagnosticParams) (*WorkspaceDiagnosticReport, error) {
+	report := &WorkspaceDiagnosticReport{Items: []any{}}
+	cfg := req.Config()
+	if !cfg.Features.DiagnosticsEnabled() {
+		return report, nil
+	}
+
+	previous := make(map[string]string, len(params.PreviousResultIDs))
+	for _, id := range params.PreviousResultIDs {
+		previous[id.URI] = id.Value
+	}
+
+	var batch []any
+	flush := func() {
+		if len(batch) > 0 && !req.ReportPartialResult(params.PartialResultToken, WorkspaceDiagnosticReportPartialResult{Items: batch}) {
+			report.Items = append(report.Items, batch...)
+		}
+		batch = nil
+	}
+
+	inputs := diagnosticInputs(req.Server, cfg)
+	uris := workspaceURIs(req.Server)
+	log.Info("Workspace diagnostics requested for %d documents", len(uris))
+	for _, uri := range uris {
+		if item := documentReport(req.Server, cfg, inputs, uri, previous[uri]); item != nil {
+			batch = append(batch, item)
+		}
+		if len(batch) == partialResultSize {
+			flush()
+		}
+	}
+	flush()
+	return report, nil
+}
+
+// documentReport returns the full or unchanged report of a document of the
+// workspace, or nil if it can't be checked
+func documentReport(ctx types.ServerContext, cfg types.ServerConfig, inputs, uri, previousID string) any {
+	var (
+		diagnostics []protocol.Diagnostic
+		resultID    string
+		version     *protocol.Integer
+	)
+	if doc := ctx.Document(uri); doc != nil {
+		v := protocol.Integer(doc.Version()) //nolint:gosec // G115: document versions fit in int32
+		version = &v
+		var err error
+		if diagnostics, err = documentDiagnostics(ctx, cfg, doc); err != nil {
+			log.Warn("Cannot check %s: %v", uri, err)
+			return nil
+		}
+		resultID = ctx.DiagnosticResultCache().Store(uri, Fingerprint(diagnostics))
+	} else {
+		var ok bool
+		if diagnostics, resultID, ok = fileDiagnostics(ctx, cfg, inputs, uri); !ok {
+			return nil
+		}
+	}
+
+	if previousID != "" {
+		metrics.CacheLookup("diagnostics", previousID == resultID)
+		if previousID == resultID {
+			return WorkspaceUnchangedDocumentDiagnosticReport{
+				Kind:     string(DiagnosticUnchanged),
+				ResultID: resultID,
+				URI:      uri,
+				Version:  version,
+			}
+		}
+	}
+	return WorkspaceFullDocumentDiagnosticReport{
+		Kind:     string(DiagnosticFull),
+		ResultID: resultID,
+		URI:      uri,
+		Version:  version,
+		Items:    diagnostics,
+	}
+}
+
+// fileDiagnostics checks a file which is not open, as it is on disk, and
+// returns its diagnostics and result id. It reports false if the file can't
+// be read or is neither a stylesheet, a component nor a token file.
+//
+// The diagnostics are cached by the file's modification time and size and
+// by inputs, so that unchanged files aren't read and checked on every pull.
+func fileDiagnostics(ctx types.ServerContext, cfg types.ServerConfig, inputs, uri string) ([]protocol.Diagnostic, string, bool) {
+	path := uriutil.URIToPath(uri)
+	languageID := diskLanguageID(path)
+	if languageID == "" {
+		return nil, "", false
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		log.Warn("Cannot read %s: %v", path, err)
+		return nil, "", false
+	}
+
+	var stamp string
+	if inputs != "" {
+		stamp = fmt.Sprintf("%d:%d:%s", info.ModTime().UnixNano(), info.Size(), inputs)
+		diagnostics, resultID, ok := ctx.DiagnosticResultCache().File(uri, stamp)
+		metrics.CacheLookup("fileDiagnostics", ok)
+		if ok {
+			return diagnostics, resultID, true
+		}
+	}
+
+	content, err := os.ReadFile(path) //nolint:gosec // G304: files under the workspace root and configured token files
+	if err != nil {
+		log.Warn("Cannot read %s: %v", path, err)
+		return nil, "", false
+	}
+	diagnostics, err := documentDiagnostics(ctx, cfg, documents.NewDocument(uri, languageID, 0, string(content)))
+	if err != nil {
+		log.Warn("Cannot check %s: %v", uri, err)
+		return nil, "", false
+	}
+	return diagnostics, ctx.DiagnosticResultCache().StoreFile(uri, stamp, Fingerprint(diagnostics), diagnostics), true
+}
+
+// diagnosticInputs identifies what, besides a file's content, its
+// diagnostics depend on: the tokens (generated custom properties files
+// included), the configuration and the client's locale and capabilities.
+// It returns an empty string, which disables caching, if the configuration
+// can't be identified.
+func diagnosticInputs(ctx types.ServerContext, cfg types.ServerConfig) string {
+	config, err := json.Marshal(cfg)
+	if err != nil {
+		log.Warn("Cannot cache workspace diagnostics: %v", err)
+		return ""
+	}
+	return fmt.Sprintf("%d:%08x:%s:%t", ctx.TokenManager().Revision(), crc32.ChecksumIEEE(config), ctx.Locale(), ctx.SupportsDiagnosticRelatedInfo())
+}
+
+// workspaceURIs returns the URIs of the documents workspace diagnostics
+// cover: the open documents, the files in the workspace index and the loaded
+// token files, in order
+func workspaceURIs(ctx types.ServerContext) []string {
+	var uris []string
+	for _, doc := range ctx.AllDocuments() {
+		uris = append(uris, doc.URI())
+	}
+	for _, path := range ctx.WorkspaceIndex().Paths() {
+		uris = append(uris, uriutil.PathToURI(path))
+	}
+	for _, path := range ctx.TokenManager().GetSourceFiles() {
+		uris = append(uris, uriutil.PathToURI(path))
+	}
+	slices.Sort(uris)
+	return slices.Compact(uris)
+}
+
+// diskLanguageID returns the language of a file which is not open, or an
+// empty string if it is neither a stylesheet, a component nor a token file
+func diskLanguageID(path string) string {
+	if languageID := parser.LanguageIDForPath(path); languageID != "" {
+		return languageID
+	}
+	switch filepath.Ext(path) {
+	case ".json":
+		return "json"
+	case ".jsonc":
+		return "jsonc"
+	case ".yaml", ".yml":
+		return "yaml"
+	}
+	return ""
+}
@@ -0,0 +1,144 @@
+package documentcolor
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/mazznoer/csscolorparser"
+)
+
+// matrix3 converts between color spaces' linear-light coordinates
+type matrix3 [3][3]float64
+
+func (m matrix3) apply(v [3]float64) [3]float64 {
+	var out [3]float64
+	for i, row := range m {
+		out[i] = row[0]*v[0] + row[1]*v[1] + row[2]*v[2]
+	}
+	return out
+}
+
+// rgbSpace is a predefined RGB color space of CSS Color 4's color() function
+type rgbSpace struct {
+	// toLinear undoes the space's transfer function
+	toLinear func(float64) float64
+	// toXYZ converts linear-light coordinates to CIE XYZ (D65)
+	toXYZ matrix3
+}
+
+var identity = matrix3{{1, 0, 0}, {0, 1, 0}, {0, 0, 1}}
+
+var srgbToXYZ = matrix3{
+	{0.41239079926595934, 0.357584339383878, 0.1804807884018343},
+	{0.21263900587151027, 0.715168678767756, 0.07219231536073371},
+	{0.01933081871559182, 0.11919477979462598, 0.9505321522496607},
+}
+
+var xyzToLinearSRGB = matrix3{
+	{3.2409699419045226, -1.537383177570094, -0.4986107602930034},
+	{-0.9692436362808796, 1.8759675015077202, 0.04155505740717559},
+	{0.05563007969699366, -0.20397695888897652, 1.0569715142428786},
+}
+
+// rgbSpaces are the color() spaces swatches can be shown for, by name.
+// Matrices and transfer functions are CSS Color 4's.
+var rgbSpaces = map[string]rgbSpace{
+	"srgb":        {toLinear: srgbToLinear, toXYZ: srgbToXYZ},
+	"srgb-linear": {toLinear: linear, toXYZ: srgbToXYZ},
+	"display-p3": {toLinear: srgbToLinear, toXYZ: matrix3{
+		{0.4865709486482162, 0.26566769316909306, 0.1982172852343625},
+		{0.2289745640697488, 0.6917385218365064, 0.079286914093745},
+		{0, 0.04511338185890264, 1.043944368900976},
+	}},
+	"a98-rgb": {toLinear: a98ToLinear, toXYZ: matrix3{
+		{0.5766690429101305, 0.1855582379065463, 0.1882286462349947},
+		{0.29734497525053605, 0.6273635662554661, 0.07529145849399788},
+		{0.02703136138641234, 0.07068885253582723, 0.9913375368376388},
+	}},
+	"rec2020": {toLinear: rec2020ToLinear, toXYZ: matrix3{
+		{0.6369580483012914, 0.14461690358620832, 0.1688809751641721},
+		{0.2627002120112671, 0.6779980715188708, 0.05930171646986196},
+		{0, 0.028072693049087428, 1.060985057710791},
+	}},
+	"xyz":     {toLinear: linear, toXYZ: identity},
+	"xyz-d65": {toLinear: linear, toXYZ: identity},
+}
+
+func linear(v float64) float64 { return v }
+
+func srgbToLinear(v float64) float64 {
+	abs := math.Abs(v)
+	if abs <= 0.04045 {
+		return v / 12.92
+	}
+	return math.Copysign(math.Pow((abs+0.055)/1.055, 2.4), v)
+}
+
+func a98ToLinear(v float64) float64 {
+	return math.Copysign(math.Pow(math.Abs(v), 563.0/256.0), v)
+}
+
+func rec2020ToLinear(v float64) float64 {
+	const alpha, beta = 1.09929682680944, 0.018053968510807
+	abs := math.Abs(v)
+	if abs < beta*4.5 {
+		return v / 4.5
+	}
+	return math.Copysign(math.Pow((abs+alpha-1)/alpha, 1/0.45), v)
+}
+
+// parseColorFunction parses a CSS color() function in one of rgbSpaces,
+// e.g. color(display-p3 1 0.5 0 / 50%), to sRGB. Colors outside sRGB's gamut
+// have channels outside 0-1.
+func parseColorFunction(value string) (csscolorparser.Color, error) {
+	body, ok := strings.CutPrefix(strings.ToLower(value), "color(")
+	body, closed := strings.CutSuffix(body, ")")
+	if !ok || !closed {
+		return csscolorparser.Color{}, fmt.Errorf("not a color() function: %s", value)
+	}
+
+	body, alphaArg, hasAlpha := strings.Cut(body, "/")
+	fields := strings.Fields(body)
+	if len(fields) != 4 {
+		return csscolorparser.Color{}, fmt.Errorf("color() needs a color space and 3 components: %s", value)
+	}
+	space, ok := rgbSpaces[fields[0]]
+	if !ok {
+		return csscolorparser.Color{}, fmt.Errorf("unsupported color space %s", fields[0])
+	}
+
+	var coords [3]float64
+	for i, field := range fields[1:] {
+		v, err := parseComponent(field)
+		if err != nil {
+			return csscolorparser.Color{}, fmt.Errorf("invalid color() component %q: %w", field, err)
+		}
+		coords[i] = space.toLinear(v)
+	}
+
+	alpha := 1.0
+	if hasAlpha {
+		var err error
+		if alpha, err = parseComponent(strings.TrimSpace(alphaArg)); err != nil {
+			return csscolorparser.Color{}, fmt.Errorf("invalid color() alpha %q: %w", alphaArg, err)
+		}
+	}
+
+	rgb := xyzToLinearSRGB.apply(space.toXYZ.apply(coords))
+	return csscolorparser.FromLinearRGB(rgb[0], rgb[1], rgb[2], alpha), nil
+}
+
+// parseComponent parses a color() component or alpha: a number, a
+// percentage of 1, or none, which is 0
+func parseComponent(s string) (float64, error) {
+	if s == "none" {
+		return 0, nil
+	}
+	if percent, ok := strings.CutSuffix(s, "%"); ok {
+		v, err := strconv.ParseFloat(percent, 64)
+		return v / 100, err
+	}
+	return strconv.ParseFloat(s, 64)
+}
@@ -2,10 +2,13 @@ package documentcolor
 
 import (
 	"bennypowers.dev/dtls/internal/log"
+	"cmp"
 	"fmt"
+	"slices"
 	"strings"
 
 	"bennypowers.dev/dtls/internal/parser"
+	"bennypowers.dev/dtls/internal/parser/tokenfile"
 	"bennypowers.dev/dtls/lsp/types"
 	"github.com/mazznoer/csscolorparser"
 	protocol "github.com/tliron/glsp/protocol_3_16"
@@ -17,11 +20,30 @@ func DocumentColor(req *types.RequestContext, params *protocol.DocumentColorPara
 
 	log.Info("DocumentColor requested: %s", uri)
 
+	// Document colors may be disabled in configuration after initialize
+	if !req.Config().Features.DocumentColorEnabled() {
+		return nil, nil
+	}
+
 	// Get document
 	doc := req.Server.Document(uri)
 	if doc == nil {
 		return nil, nil
 	}
+	req.TraceDocument(doc)
+
+	// Token files get swatches for their color tokens' values
+	if tokenfile.IsLanguage(doc.LanguageID()) && req.Server.ShouldProcessAsTokenFile(doc.URI()) {
+		found, parseErrors := tokenFileColors(doc)
+		for _, err := range parseErrors {
+			req.AddWarning(err)
+		}
+		var colors []protocol.ColorInformation
+		for _, color := range found {
+			colors = append(colors, color.info)
+		}
+		return limitColors(req, uri, colors), nil
+	}
 
 	// Only process CSS-supported files
 	if !parser.IsCSSSupportedLanguage(doc.LanguageID()) {
@@ -36,12 +58,18 @@ func DocumentColor(req *types.RequestContext, params *protocol.DocumentColorPara
 	if result == nil {
 		return nil, nil
 	}
+	req.TraceVarCalls(len(result.VarCalls))
 
 	var colors []protocol.ColorInformation
 	var parseErrors []error
 
 	// Find all var() calls that reference color tokens
 	for _, varCall := range result.VarCalls {
+		// Color presentations would replace the SCSS variable with a literal
+		if varCall.Alias != "" {
+			continue
+		}
+
 		// Look up the token
 		token := req.Server.Token(varCall.TokenName)
 		if token == nil {
@@ -113,6 +141,7 @@ func DocumentColor(req *types.RequestContext, params *protocol.DocumentColorPara
 	}
 
 	log.Info("Found %d colors", len(colors))
+	colors = limitColors(req, uri, colors)
 
 	// Add parse errors as warnings
 	// Don't fail the operation - we can still return partial results
@@ -124,6 +153,24 @@ func DocumentColor(req *types.RequestContext, params *protocol.DocumentColorPara
 	return colors, nil
 }
 
+// limitColors keeps the first colors in a document up to the configured
+// limit. Huge generated stylesheets can hold more swatches than the editor
+// can render promptly.
+func limitColors(req *types.RequestContext, uri string, colors []protocol.ColorInformation) []protocol.ColorInformation {
+	limit := req.Config().DocumentColorLimit()
+	if len(colors) <= limit {
+		return colors
+	}
+	slices.SortFunc(colors, func(a, b protocol.ColorInformation) int {
+		return cmp.Or(
+			cmp.Compare(a.Range.Start.Line, b.Range.Start.Line),
+			cmp.Compare(a.Range.Start.Character, b.Range.Start.Character),
+		)
+	})
+	req.AddWarning(fmt.Errorf("returning the first %d of %d colors in %s (maxDocumentColors)", limit, len(colors), uri))
+	return colors[:limit]
+}
+
 // ColorPresentation handles the textDocument/colorPresentation request
 // Returns token names that have the same color value as the requested color
 func ColorPresentation(req *types.RequestContext, params *protocol.ColorPresentationParams) ([]protocol.ColorPresentation, error) {
@@ -132,6 +179,11 @@ func ColorPresentation(req *types.RequestContext, params *protocol.ColorPresenta
 
 	log.Info("ColorPresentation requested: %s", uri)
 
+	// Colors picked in token files are written into the token's $value
+	if doc := req.Server.Document(uri); doc != nil && tokenfile.IsLanguage(doc.LanguageID()) && req.Server.ShouldProcessAsTokenFile(uri) {
+		return tokenFilePresentations(doc, params), nil
+	}
+
 	// Convert protocol.Color to csscolorparser.Color for comparison
 	requestedColor := csscolorparser.Color{
 		R: float64(color.Red),
@@ -187,23 +239,37 @@ func ColorPresentation(req *types.RequestContext, params *protocol.ColorPresenta
 	return presentations, nil
 }
 
-// parseColor parses a color string (hex, rgb, rgba, hsl, hsla, etc.) and returns a protocol.Color
+// parseColor parses a color string (hex, rgb, rgba, hsl, hsla, oklch,
+// color(display-p3 ...), etc.) and returns a protocol.Color. The protocol's
+// colors are sRGB, so wide-gamut colors are clamped to it.
 func parseColor(value string) (*protocol.Color, error) {
 	value = strings.TrimSpace(value)
 
-	// Use csscolorparser for all color formats (hex, rgb, rgba, hsl, hsla, named colors, etc.)
-	// This is a battle-tested library that handles all CSS color formats correctly
-	parsed, err := csscolorparser.Parse(value)
+	// csscolorparser handles hex, rgb, hsl, hwb, lab, lch, oklab, oklch and named
+	// colors, but not color() in predefined color spaces such as display-p3
+	var parsed csscolorparser.Color
+	var err error
+	if strings.HasPrefix(strings.ToLower(value), "color(") {
+		parsed, err = parseColorFunction(value)
+	} else {
+		parsed, err = csscolorparser.Parse(value)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("unsupported color format: %s", value)
 	}
 
 	// Convert csscolorparser.Color to protocol.Color
-	// csscolorparser.Color has R, G, B, A fields as float64 values (0-1)
+	// csscolorparser.Color has R, G, B, A fields as float64 values (0-1), but
+	// leaves colors outside sRGB's gamut, e.g. vivid oklch(), out of that range
 	return &protocol.Color{
-		Red:   protocol.Decimal(parsed.R),
-		Green: protocol.Decimal(parsed.G),
-		Blue:  protocol.Decimal(parsed.B),
-		Alpha: protocol.Decimal(parsed.A),
+		Red:   protocol.Decimal(clamp(parsed.R)),
+		Green: protocol.Decimal(clamp(parsed.G)),
+		Blue:  protocol.Decimal(clamp(parsed.B)),
+		Alpha: protocol.Decimal(clamp(parsed.A)),
 	}, nil
 }
+
+// clamp clamps a color channel to 0-1
+func clamp(v float64) float64 {
+	return min(max(v, 0), 1)
+}
@@ -43,6 +43,29 @@ func TestDocumentColor_ColorTokenInVar(t *testing.T) {
 	assert.Equal(t, protocol.Decimal(1.0), result[0].Color.Alpha)
 }
 
+func TestDocumentColor_Limit(t *testing.T) {
+	ctx := testutil.NewMockServerContext()
+	cfg := types.DefaultConfig()
+	cfg.MaxDocumentColors = 2
+	ctx.SetConfig(cfg)
+	req := types.NewRequestContext(ctx, &glsp.Context{})
+
+	require.NoError(t, ctx.TokenManager().Add(&tokens.Token{Name: "color.primary", Value: "#ff0000", Type: "color"}))
+
+	uri := "file:///test.css"
+	cssContent := ".a { color: var(--color-primary); }\n.b { color: var(--color-primary); }\n.c { color: var(--color-primary); }"
+	require.NoError(t, ctx.DocumentManager().DidOpen(uri, "css", 1, cssContent))
+
+	result, err := DocumentColor(req, &protocol.DocumentColorParams{
+		TextDocument: protocol.TextDocumentIdentifier{URI: uri},
+	})
+	require.NoError(t, err)
+	require.Len(t, result, 2)
+	assert.Equal(t, protocol.UInteger(0), result[0].Range.Start.Line)
+	assert.Equal(t, protocol.UInteger(1), result[1].Range.Start.Line)
+	assert.Len(t, req.Warnings(), 1)
+}
+
 func TestDocumentColor_ColorTokenInDeclaration(t *testing.T) {
 	ctx := testutil.NewMockServerContext()
 	glspCtx := &glsp.Context{}
@@ -511,6 +534,128 @@ func TestParseColor(t *testing.T) {
 			},
 			expectError: false,
 		},
+		{
+			name:  "rgb() with slash alpha",
+			input: "rgb(255 0 0 / 50%)",
+			expected: &protocol.Color{
+				Red:   1.0,
+				Green: 0.0,
+				Blue:  0.0,
+				Alpha: 0.5,
+			},
+			expectError: false,
+		},
+		{
+			name:  "hsl() with slash alpha",
+			input: "hsl(240 100% 50% / 0.25)",
+			expected: &protocol.Color{
+				Red:   0.0,
+				Green: 0.0,
+				Blue:  1.0,
+				Alpha: 0.25,
+			},
+			expectError: false,
+		},
+		{
+			name:  "oklch() - white",
+			input: "oklch(1 0 0)",
+			expected: &protocol.Color{
+				Red:   1.0,
+				Green: 1.0,
+				Blue:  1.0,
+				Alpha: 1.0,
+			},
+			expectError: false,
+		},
+		{
+			name:  "oklch() with alpha",
+			input: "oklch(0.627955 0.257683 29.2339 / 0.5)",
+			expected: &protocol.Color{
+				Red:   1.0,
+				Green: 0.0,
+				Blue:  0.0,
+				Alpha: 0.5,
+			},
+			expectError: false,
+		},
+		{
+			name:  "oklch() outside sRGB is clamped",
+			input: "oklch(1 0.4 145)",
+			expected: &protocol.Color{
+				Red:   0.0,
+				Green: 1.0,
+				Blue:  0.0,
+				Alpha: 1.0,
+			},
+			expectError: false,
+		},
+		{
+			name:  "color() in srgb",
+			input: "color(srgb 1 0.5 0)",
+			expected: &protocol.Color{
+				Red:   1.0,
+				Green: 0.5,
+				Blue:  0.0,
+				Alpha: 1.0,
+			},
+			expectError: false,
+		},
+		{
+			name:  "color() in srgb-linear",
+			input: "color(srgb-linear 0.2140 0 1)",
+			expected: &protocol.Color{
+				Red:   0.5,
+				Green: 0.0,
+				Blue:  1.0,
+				Alpha: 1.0,
+			},
+			expectError: false,
+		},
+		{
+			name:  "color() in display-p3 inside sRGB",
+			input: "color(display-p3 0.917488 0.200287 0.138561)",
+			expected: &protocol.Color{
+				Red:   1.0,
+				Green: 0.0,
+				Blue:  0.0,
+				Alpha: 1.0,
+			},
+			expectError: false,
+		},
+		{
+			name:  "color() in display-p3 outside sRGB is clamped",
+			input: "color(display-p3 0 1 0 / 50%)",
+			expected: &protocol.Color{
+				Red:   0.0,
+				Green: 1.0,
+				Blue:  0.0,
+				Alpha: 0.5,
+			},
+			expectError: false,
+		},
+		{
+			name:  "color() with none components",
+			input: "color(display-p3 none none 1)",
+			expected: &protocol.Color{
+				Red:   0.0,
+				Green: 0.0,
+				Blue:  1.0,
+				Alpha: 1.0,
+			},
+			expectError: false,
+		},
+		{
+			name:        "color() in unsupported color space",
+			input:       "color(prophoto-rgb 1 0 0)",
+			expected:    nil,
+			expectError: true,
+		},
+		{
+			name:        "color() with missing components",
+			input:       "color(display-p3 1 0)",
+			expected:    nil,
+			expectError: true,
+		},
 		{
 			name:        "empty string",
 			input:       "",
@@ -585,3 +730,121 @@ func TestDocumentColor_HTMLNoCSS(t *testing.T) {
 	require.NoError(t, err)
 	assert.Empty(t, colors)
 }
+
+func TestDocumentColor_TokenFile(t *testing.T) {
+	ctx := testutil.NewMockServerContext()
+	req := types.NewRequestContext(ctx, &glsp.Context{})
+
+	uri := "file:///tokens.json"
+	content := `{
+  "color": {
+    "$type": "color",
+    "hex": { "$value": "#FF0000" },
+    "rgb": { "$value": "rgb(0 0 255)" },
+    "alias": { "$value": "{color.hex}" },
+    "p3": { "$value": { "colorSpace": "display-p3", "components": [0, 1, 0] } },
+    "oklch": { "$value": { "colorSpace": "oklch", "components": [0.7, 0.1, 150], "alpha": 0.5 } }
+  },
+  "space": { "sm": { "$type": "dimension", "$value": "4px" } }
+}`
+	require.NoError(t, ctx.DocumentManager().DidOpen(uri, "json", 1, content))
+
+	colors, err := DocumentColor(req, &protocol.DocumentColorParams{
+		TextDocument: protocol.TextDocumentIdentifier{URI: uri},
+	})
+	require.NoError(t, err)
+	require.Len(t, colors, 4, "aliases and other types have no swatches")
+
+	// Strings are covered with their quotes
+	assert.Equal(t, protocol.Range{
+		Start: protocol.Position{Line: 3, Character: 23},
+		End:   protocol.Position{Line: 3, Character: 32},
+	}, colors[0].Range)
+	assert.Equal(t, protocol.Color{Red: 1, Green: 0, Blue: 0, Alpha: 1}, colors[0].Color)
+	assert.Equal(t, protocol.Color{Red: 0, Green: 0, Blue: 1, Alpha: 1}, colors[1].Color)
+
+	// Color objects are covered by their $value key
+	assert.Equal(t, protocol.Range{
+		Start: protocol.Position{Line: 6, Character: 12},
+		End:   protocol.Position{Line: 6, Character: 20},
+	}, colors[2].Range)
+	assert.Equal(t, protocol.Decimal(0), colors[2].Color.Red, "display-p3 green is clamped to sRGB")
+	assert.Equal(t, protocol.Decimal(1), colors[2].Color.Green)
+	assert.Equal(t, protocol.Decimal(0.5), colors[3].Color.Alpha)
+}
+
+func TestColorPresentation_TokenFile(t *testing.T) {
+	ctx := testutil.NewMockServerContext()
+	req := types.NewRequestContext(ctx, &glsp.Context{})
+	blue := protocol.Color{Red: 0, Green: 0, Blue: 1, Alpha: 1}
+
+	// present picks a color for the swatch at index i of a token file
+	present := func(t *testing.T, uri, languageID, content string, i int, color protocol.Color) protocol.ColorPresentation {
+		t.Helper()
+		require.NoError(t, ctx.DocumentManager().DidOpen(uri, languageID, 1, content))
+		colors, err := DocumentColor(req, &protocol.DocumentColorParams{
+			TextDocument: protocol.TextDocumentIdentifier{URI: uri},
+		})
+		require.NoError(t, err)
+		require.Greater(t, len(colors), i)
+
+		presentations, err := ColorPresentation(req, &protocol.ColorPresentationParams{
+			TextDocument: protocol.TextDocumentIdentifier{URI: uri},
+			Color:        color,
+			Range:        colors[i].Range,
+		})
+		require.NoError(t, err)
+		require.Len(t, presentations, 1)
+		require.NotNil(t, presentations[0].TextEdit)
+		return presentations[0]
+	}
+
+	t.Run("hex strings stay hex, in their case", func(t *testing.T) {
+		p := present(t, "file:///hex.json", "json", `{ "red": { "$type": "color", "$value": "#FF0000" } }`, 0, blue)
+		assert.Equal(t, "#0000FF", p.Label)
+		assert.Equal(t, `"#0000FF"`, p.TextEdit.NewText)
+		assert.Equal(t, protocol.Range{
+			Start: protocol.Position{Line: 0, Character: 39},
+			End:   protocol.Position{Line: 0, Character: 48},
+		}, p.TextEdit.Range)
+	})
+
+	t.Run("functional notations are kept", func(t *testing.T) {
+		p := present(t, "file:///hsl.yaml", "yaml", "red:\n  $type: color\n  $value: hsl(0 100% 50%)\n", 0, blue)
+		assert.Equal(t, "hsl(240 100% 50%)", p.TextEdit.NewText, "plain YAML scalars stay plain")
+	})
+
+	t.Run("color objects are edited in their color space", func(t *testing.T) {
+		p := present(t, "file:///oklch.json", "json",
+			`{ "c": { "$type": "color", "$value": { "colorSpace": "oklch", "components": [0.5, 0.1, 20], "alpha": 1, "hex": "#aabbcc" } } }`,
+			0, protocol.Color{Red: 1, Green: 1, Blue: 1, Alpha: 0.5})
+		assert.Equal(t, "oklch(1 0 0 / 0.5)", p.Label)
+		var texts []string
+		for _, edit := range append([]protocol.TextEdit{*p.TextEdit}, p.AdditionalTextEdits...) {
+			texts = append(texts, edit.NewText)
+		}
+		assert.Equal(t, []string{"1", "0", "0", "0.5", `"#ffffff"`}, texts)
+	})
+
+	t.Run("color spaces without a conversion become srgb", func(t *testing.T) {
+		p := present(t, "file:///p3.json", "json",
+			`{ "c": { "$type": "color", "$value": { "colorSpace": "display-p3", "components": [0, 1, 0] } } }`, 0, blue)
+		assert.Equal(t, "color(srgb 0 0 1)", p.Label)
+		assert.Equal(t, `"srgb"`, p.TextEdit.NewText)
+		assert.Len(t, p.AdditionalTextEdits, 3)
+	})
+
+	t.Run("alpha is added in the document's style", func(t *testing.T) {
+		content := "c:\n  $type: color\n  $value:\n    colorSpace: srgb\n    components: [1, 0, 0]\n"
+		p := present(t, "file:///alpha.yaml", "yaml", content, 0, protocol.Color{Red: 1, Alpha: 0.25})
+		inserted := p.AdditionalTextEdits[len(p.AdditionalTextEdits)-1]
+		assert.Equal(t, "alpha: 0.25\n    ", inserted.NewText)
+		assert.Equal(t, protocol.Position{Line: 3, Character: 4}, inserted.Range.Start)
+		assert.Equal(t, inserted.Range.Start, inserted.Range.End)
+
+		p = present(t, "file:///alpha.json", "json",
+			`{ "c": { "$type": "color", "$value": { "colorSpace": "srgb", "components": [1, 0, 0] } } }`, 0, protocol.Color{Red: 1, Alpha: 0.25})
+		inserted = p.AdditionalTextEdits[len(p.AdditionalTextEdits)-1]
+		assert.Equal(t, `"alpha": 0.25, `, inserted.NewText)
+	})
+}
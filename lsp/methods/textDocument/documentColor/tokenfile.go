@@ -0,0 +1,328 @@
+package documentcolor
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"bennypowers.dev/dtls/internal/documents"
+	"bennypowers.dev/dtls/internal/parser/tokenfile"
+	"github.com/mazznoer/csscolorparser"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+	"gopkg.in/yaml.v3"
+)
+
+// tokenColor is the $value of a color token in a token file
+type tokenColor struct {
+	info protocol.ColorInformation
+	// value is the $value node: a color string, or a color object such as
+	// {"colorSpace": "srgb", "components": [1, 0, 0]}
+	value *yaml.Node
+}
+
+// tokenFileColors returns the color $values of a token file's color tokens,
+// whose $type may be their group's. Swatches of color strings cover the
+// string; those of color objects cover their $value key.
+func tokenFileColors(doc *documents.Document) ([]tokenColor, []error) {
+	root := tokenfile.Outline(doc.Content(), doc.LanguageID() != "yaml")
+	if root == nil {
+		return nil, nil
+	}
+
+	var colors []tokenColor
+	var parseErrors []error
+	var walk func(node *yaml.Node, path []string, tokenType string)
+	walk = func(node *yaml.Node, path []string, tokenType string) {
+		if node.Kind != yaml.MappingNode {
+			return
+		}
+		if t := tokenfile.MappingValue(node, "$type"); t != nil {
+			tokenType = t.Value
+		}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			if key.Value == "$value" {
+				if tokenType != "color" {
+					continue
+				}
+				color, err := tokenColorValue(value)
+				if err != nil {
+					parseErrors = append(parseErrors, fmt.Errorf("failed to parse color token %s: %w", strings.Join(path, "."), err))
+					continue
+				}
+				if color == nil {
+					continue
+				}
+				rangeNode := value
+				if value.Kind == yaml.MappingNode {
+					rangeNode = key
+				}
+				colors = append(colors, tokenColor{
+					info:  protocol.ColorInformation{Range: tokenfile.ScalarTextRange(doc.Content(), rangeNode), Color: *color},
+					value: value,
+				})
+				continue
+			}
+			// $root is a token; other keywords hold data rather than tokens
+			if strings.HasPrefix(key.Value, "$") && key.Value != "$root" {
+				continue
+			}
+			walk(value, append(path[:len(path):len(path)], key.Value), tokenType)
+		}
+	}
+	walk(root, nil, "")
+	return colors, parseErrors
+}
+
+// tokenColorValue parses a color $value, or returns nil for aliases and
+// other values which aren't colors themselves
+func tokenColorValue(value *yaml.Node) (*protocol.Color, error) {
+	switch value.Kind {
+	case yaml.ScalarNode:
+		if strings.Contains(value.Value, "{") {
+			return nil, nil
+		}
+		return parseColor(value.Value)
+	case yaml.MappingNode:
+		if tokenfile.MappingValue(value, "colorSpace") == nil {
+			return nil, nil
+		}
+		color, err := parseColor(colorObjectCSS(value))
+		if err != nil {
+			// The hex fallback is for tools which don't know the space
+			if hex := tokenfile.MappingValue(value, "hex"); hex != nil {
+				return parseColor(hex.Value)
+			}
+		}
+		return color, err
+	}
+	return nil, nil
+}
+
+// colorObjectCSS writes a DTCG color object as a CSS color, e.g.
+// color(display-p3 1 0 0 / 0.5) or oklch(0.7 0.1 150)
+func colorObjectCSS(object *yaml.Node) string {
+	space := tokenfile.MappingValue(object, "colorSpace").Value
+	var components []string
+	if c := tokenfile.MappingValue(object, "components"); c != nil {
+		for _, component := range c.Content {
+			components = append(components, component.Value)
+		}
+	}
+	alpha := "1"
+	if a := tokenfile.MappingValue(object, "alpha"); a != nil {
+		alpha = a.Value
+	}
+	return colorCSS(space, components, alpha)
+}
+
+// colorCSS writes the components of a color in a DTCG color space as a CSS
+// color, with its alpha unless it is 1
+func colorCSS(space string, components []string, alpha string) string {
+	args := strings.Join(components, " ")
+	switch space {
+	case "hsl", "hwb":
+		// Saturation, lightness, whiteness and blackness are percentages
+		if len(components) == 3 {
+			args = fmt.Sprintf("%s %s%% %s%%", components[0], components[1], components[2])
+		}
+	case "lab", "lch", "oklab", "oklch":
+	default:
+		space, args = "color", space+" "+args
+	}
+	if alpha != "1" {
+		args += " / " + alpha
+	}
+	return space + "(" + args + ")"
+}
+
+// tokenFilePresentations returns the presentation of a color picked for a
+// token file's color $value, in the value's own format: color strings in
+// their notation, and color objects in their color space where it can be
+// written, or srgb otherwise. Only the scalars that change are edited.
+func tokenFilePresentations(doc *documents.Document, params *protocol.ColorPresentationParams) []protocol.ColorPresentation {
+	colors, _ := tokenFileColors(doc)
+	for _, color := range colors {
+		if color.info.Range != params.Range {
+			continue
+		}
+		picked := csscolorparser.Color{
+			R: float64(params.Color.Red),
+			G: float64(params.Color.Green),
+			B: float64(params.Color.Blue),
+			A: float64(params.Color.Alpha),
+		}
+		if color.value.Kind == yaml.ScalarNode {
+			text := colorString(color.value.Value, picked)
+			return []protocol.ColorPresentation{{
+				Label:    text,
+				TextEdit: &protocol.TextEdit{Range: tokenfile.ScalarTextRange(doc.Content(), color.value), NewText: tokenfile.ScalarText(color.value, text, doc.LanguageID() != "yaml")},
+			}}
+		}
+		return []protocol.ColorPresentation{colorObjectPresentation(doc, color.value, picked)}
+	}
+	return nil
+}
+
+// colorString writes a color in the notation of the string it replaces:
+// hex, rgb() or hsl(), or hex for other notations
+func colorString(original string, c csscolorparser.Color) string {
+	original = strings.TrimSpace(original)
+	switch lower := strings.ToLower(original); {
+	case strings.HasPrefix(lower, "rgb"):
+		return c.RGBString()
+	case strings.HasPrefix(lower, "hsl"):
+		h, s, l := rgbToHSL(c)
+		return colorCSS("hsl", []string{formatNumber(h), formatNumber(s), formatNumber(l)}, formatNumber(c.A))
+	}
+	hex := c.HexString()
+	if original != strings.ToLower(original) {
+		hex = strings.ToUpper(hex)
+	}
+	return hex
+}
+
+// colorObjectPresentation edits a color object's components, alpha and hex
+// fallback to a picked color
+func colorObjectPresentation(doc *documents.Document, object *yaml.Node, c csscolorparser.Color) protocol.ColorPresentation {
+	content := doc.Content()
+	isJSON := doc.LanguageID() != "yaml"
+	spaceNode := tokenfile.MappingValue(object, "colorSpace")
+	space := spaceNode.Value
+	components, ok := componentsIn(space, c)
+	var edits []protocol.TextEdit
+	if !ok {
+		space = "srgb"
+		components, _ = componentsIn(space, c)
+		edits = append(edits, protocol.TextEdit{Range: tokenfile.ScalarTextRange(content, spaceNode), NewText: tokenfile.ScalarText(spaceNode, space, isJSON)})
+	}
+
+	if sequence := tokenfile.MappingValue(object, "components"); sequence != nil && len(sequence.Content) == 3 {
+		for i, component := range sequence.Content {
+			edits = append(edits, protocol.TextEdit{Range: tokenfile.ScalarTextRange(content, component), NewText: tokenfile.ScalarText(component, formatNumber(components[i]), isJSON)})
+		}
+	}
+
+	alpha := formatNumber(c.A)
+	if a := tokenfile.MappingValue(object, "alpha"); a != nil {
+		edits = append(edits, protocol.TextEdit{Range: tokenfile.ScalarTextRange(content, a), NewText: tokenfile.ScalarText(a, alpha, isJSON)})
+	} else if c.A < 1 {
+		edits = append(edits, insertBefore(doc, object, spaceKey(object), "alpha", alpha))
+	}
+
+	if hex := tokenfile.MappingValue(object, "hex"); hex != nil {
+		r, g, b, _ := c.RGBA255()
+		text := fmt.Sprintf("#%02x%02x%02x", r, g, b)
+		if hex.Value != strings.ToLower(hex.Value) {
+			text = strings.ToUpper(text)
+		}
+		edits = append(edits, protocol.TextEdit{Range: tokenfile.ScalarTextRange(content, hex), NewText: tokenfile.ScalarText(hex, text, isJSON)})
+	}
+
+	formatted := []string{formatNumber(components[0]), formatNumber(components[1]), formatNumber(components[2])}
+	presentation := protocol.ColorPresentation{Label: colorCSS(space, formatted, alpha)}
+	if len(edits) > 0 {
+		presentation.TextEdit = &edits[0]
+		presentation.AdditionalTextEdits = edits[1:]
+	}
+	return presentation
+}
+
+// spaceKey returns the colorSpace key of a color object
+func spaceKey(object *yaml.Node) *yaml.Node {
+	for i := 0; i+1 < len(object.Content); i += 2 {
+		if object.Content[i].Value == "colorSpace" {
+			return object.Content[i]
+		}
+	}
+	return nil
+}
+
+// insertBefore inserts a member into a mapping before one of its keys, in
+// the style of the document: quoted in JSON, and on its own line in YAML
+// block mappings
+func insertBefore(doc *documents.Document, mapping, key *yaml.Node, name, value string) protocol.TextEdit {
+	at := tokenfile.ScalarTextRange(doc.Content(), key).Start
+	text := name + ": " + value + ", "
+	switch {
+	case doc.LanguageID() != "yaml":
+		quoted, _ := json.Marshal(name)
+		text = string(quoted) + ": " + value + ", "
+	case mapping.Style&yaml.FlowStyle == 0:
+		text = name + ": " + value + "\n" + strings.Repeat(" ", max(key.Column-1, 0))
+	}
+	return protocol.TextEdit{Range: protocol.Range{Start: at, End: at}, NewText: text}
+}
+
+// componentsIn converts an sRGB color to the components of a DTCG color
+// space, if it is one colors can be converted to
+func componentsIn(space string, c csscolorparser.Color) ([3]float64, bool) {
+	switch space {
+	case "srgb":
+		return [3]float64{c.R, c.G, c.B}, true
+	case "srgb-linear":
+		return [3]float64{srgbToLinear(c.R), srgbToLinear(c.G), srgbToLinear(c.B)}, true
+	case "hsl":
+		h, s, l := rgbToHSL(c)
+		return [3]float64{h, s, l}, true
+	case "hwb":
+		h, _, _ := rgbToHSL(c)
+		return [3]float64{h, min(c.R, c.G, c.B) * 100, (1 - max(c.R, c.G, c.B)) * 100}, true
+	case "oklab":
+		return rgbToOklab(c), true
+	case "oklch":
+		lab := rgbToOklab(c)
+		chroma := math.Hypot(lab[1], lab[2])
+		hue := 0.0
+		if chroma > 1e-4 {
+			hue = math.Mod(math.Atan2(lab[2], lab[1])*180/math.Pi+360, 360)
+		}
+		return [3]float64{lab[0], chroma, hue}, true
+	}
+	return [3]float64{}, false
+}
+
+// rgbToHSL converts an sRGB color to hue in degrees, and saturation and
+// lightness in percent
+func rgbToHSL(c csscolorparser.Color) (h, s, l float64) {
+	hi, lo := max(c.R, c.G, c.B), min(c.R, c.G, c.B)
+	l = (hi + lo) / 2
+	d := hi - lo
+	if d == 0 {
+		return 0, 0, l * 100
+	}
+	s = d / (1 - math.Abs(2*l-1))
+	switch hi {
+	case c.R:
+		h = math.Mod((c.G-c.B)/d+6, 6)
+	case c.G:
+		h = (c.B-c.R)/d + 2
+	default:
+		h = (c.R-c.G)/d + 4
+	}
+	return h * 60, s * 100, l * 100
+}
+
+// rgbToOklab converts an sRGB color to Oklab
+func rgbToOklab(c csscolorparser.Color) [3]float64 {
+	r, g, b := srgbToLinear(c.R), srgbToLinear(c.G), srgbToLinear(c.B)
+	l := math.Cbrt(0.4122214708*r + 0.5363325363*g + 0.0514459929*b)
+	m := math.Cbrt(0.2119034982*r + 0.6806995451*g + 0.1073969566*b)
+	s := math.Cbrt(0.0883024619*r + 0.2817188376*g + 0.6299787005*b)
+	return [3]float64{
+		0.2104542553*l + 0.7936177850*m - 0.0040720468*s,
+		1.9779984951*l - 2.4285922050*m + 0.4505937099*s,
+		0.0259040371*l + 0.7827717662*m - 0.8086757660*s,
+	}
+}
+
+// formatNumber writes a color component to 4 decimal places at most
+func formatNumber(v float64) string {
+	v = math.Round(v*1e4) / 1e4
+	if v == 0 {
+		v = 0 // no -0
+	}
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
@@ -0,0 +1,100 @@
+package documentlink
+
+import (
+	"fmt"
+	"strings"
+
+	"bennypowers.dev/dtls/internal/i18n"
+	"bennypowers.dev/dtls/internal/log"
+	"bennypowers.dev/dtls/internal/parser/common"
+	posutil "bennypowers.dev/dtls/internal/position"
+	"bennypowers.dev/dtls/lsp/methods/textDocument/definition"
+	"bennypowers.dev/dtls/lsp/types"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+// reference is an alias reference in a token file, e.g. {color.brand.primary}
+// or "$ref": "#/color/brand/primary"
+type reference struct {
+	// tokenName is the referenced token's name, e.g. color-brand-primary
+	tokenName string
+	// start and end are the byte offsets of the reference in its line
+	start, end int
+}
+
+// DocumentLink handles the textDocument/documentLink request, linking alias
+// references in token files to the definitions of the tokens they reference,
+// which may be in other token files
+func DocumentLink(req *types.RequestContext, params *protocol.DocumentLinkParams) ([]protocol.DocumentLink, error) {
+	uri := params.TextDocument.URI
+
+	log.Info("DocumentLink requested: %s", uri)
+
+	// Get document
+	doc := req.Server.Document(uri)
+	if doc == nil {
+		return nil, nil
+	}
+	req.TraceDocument(doc)
+
+	// Only process token files
+	switch doc.LanguageID() {
+	case "json", "jsonc", "yaml":
+	default:
+		return nil, nil
+	}
+	if !req.Server.ShouldProcessAsTokenFile(uri) {
+		return nil, nil
+	}
+
+	content := strings.ReplaceAll(doc.Content(), "\r\n", "\n")
+	links := []protocol.DocumentLink{}
+	for lineNum, line := range strings.Split(content, "\n") {
+		for _, ref := range lineReferences(line) {
+			token := req.Server.Token(ref.tokenName)
+			if token == nil {
+				continue
+			}
+			location := definition.TokenLocation(req, token)
+			if location == nil {
+				continue
+			}
+
+			// Clients open the target at the fragment's 1-based line and column
+			target := fmt.Sprintf("%s#L%d,%d", location.URI, location.Range.Start.Line+1, location.Range.Start.Character+1)
+			tooltip := i18n.Sprintf(req.Server.Locale(), "Go to %s", token.Name)
+			links = append(links, protocol.DocumentLink{
+				Range: protocol.Range{
+					Start: protocol.Position{Line: uint32(lineNum), Character: posutil.ByteOffsetToUTF16Uint32(line, ref.start)},
+					End:   protocol.Position{Line: uint32(lineNum), Character: posutil.ByteOffsetToUTF16Uint32(line, ref.end)},
+				},
+				Target:  &target,
+				Tooltip: &tooltip,
+			})
+		}
+	}
+
+	return links, nil
+}
+
+// lineReferences finds the curly brace and JSON Pointer references in a line.
+// Links cover the reference itself, not the quotes or $ref key around it.
+func lineReferences(line string) []reference {
+	var refs []reference
+	for _, match := range common.CurlyBraceReferenceRegexp.FindAllStringSubmatchIndex(line, -1) {
+		refs = append(refs, reference{
+			tokenName: strings.ReplaceAll(line[match[2]:match[3]], ".", "-"),
+			start:     match[0],
+			end:       match[1],
+		})
+	}
+	for _, match := range common.JSONPointerReferenceRegexp.FindAllStringSubmatchIndex(line, -1) {
+		path := strings.TrimPrefix(line[match[2]:match[3]], "#/")
+		refs = append(refs, reference{
+			tokenName: strings.ReplaceAll(path, "/", "-"),
+			start:     match[2],
+			end:       match[3],
+		})
+	}
+	return refs
+}
@@ -0,0 +1,96 @@
+package documentlink_test
+
+import (
+	"testing"
+
+	"bennypowers.dev/dtls/internal/tokens"
+	documentlink "bennypowers.dev/dtls/lsp/methods/textDocument/documentLink"
+	"bennypowers.dev/dtls/lsp/testutil"
+	"bennypowers.dev/dtls/lsp/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tliron/glsp"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+func lineRange(line, start, end uint32) protocol.Range {
+	return protocol.Range{
+		Start: protocol.Position{Line: line, Character: start},
+		End:   protocol.Position{Line: line, Character: end},
+	}
+}
+
+func TestDocumentLink(t *testing.T) {
+	ctx := testutil.NewMockServerContext()
+
+	brand := `{
+  "color": {
+    "brand": {
+      "primary": { "$type": "color", "$value": "#0066cc" }
+    }
+  }
+}`
+	semantic := `{
+  "color": {
+    "action": { "$type": "color", "$value": "{color.brand.primary}" },
+    "link": { "$ref": "#/color/brand/primary" },
+    "🎨": { "$type": "color", "$value": "{color.action}" },
+    "missing": { "$type": "color", "$value": "{color.unknown}" }
+  }
+}`
+	require.NoError(t, ctx.DocumentManager().DidOpen("file:///brand.json", "json", 1, brand))
+	require.NoError(t, ctx.DocumentManager().DidOpen("file:///semantic.json", "json", 1, semantic))
+
+	for _, token := range []*tokens.Token{
+		{Name: "color-brand-primary", Value: "#0066cc", Type: "color", DefinitionURI: "file:///brand.json", Line: 3, Character: 7, Path: []string{"color", "brand", "primary"}},
+		{Name: "color-action", Value: "{color.brand.primary}", Type: "color", DefinitionURI: "file:///semantic.json", Line: 2, Character: 5, Path: []string{"color", "action"}},
+	} {
+		require.NoError(t, ctx.TokenManager().Add(token))
+	}
+
+	req := types.NewRequestContext(ctx, &glsp.Context{})
+	links, err := documentlink.DocumentLink(req, &protocol.DocumentLinkParams{
+		TextDocument: protocol.TextDocumentIdentifier{URI: "file:///semantic.json"},
+	})
+	require.NoError(t, err)
+	require.Len(t, links, 3, "unknown tokens are not linked")
+
+	t.Run("cross-file curly brace reference", func(t *testing.T) {
+		assert.Equal(t, lineRange(2, 45, 66), links[0].Range)
+		require.NotNil(t, links[0].Target)
+		assert.Equal(t, "file:///brand.json#L4,8", *links[0].Target)
+		require.NotNil(t, links[0].Tooltip)
+		assert.Equal(t, "Go to color-brand-primary", *links[0].Tooltip)
+	})
+
+	t.Run("JSON pointer reference", func(t *testing.T) {
+		assert.Equal(t, lineRange(3, 23, 44), links[1].Range, "links the pointer, not the $ref key")
+		assert.Equal(t, "file:///brand.json#L4,8", *links[1].Target)
+	})
+
+	t.Run("same-file reference after non-ASCII characters", func(t *testing.T) {
+		assert.Equal(t, lineRange(4, 41, 55), links[2].Range, "ranges are in UTF-16")
+		assert.Equal(t, "file:///semantic.json#L3,6", *links[2].Target)
+	})
+}
+
+func TestDocumentLink_NonTokenFiles(t *testing.T) {
+	ctx := testutil.NewMockServerContext()
+	require.NoError(t, ctx.TokenManager().Add(&tokens.Token{
+		Name: "color-primary", Value: "#0066cc", DefinitionURI: "file:///tokens.json", Path: []string{"color", "primary"},
+	}))
+	require.NoError(t, ctx.DocumentManager().DidOpen("file:///style.css", "css", 1, `/* {color.primary} */`))
+
+	req := types.NewRequestContext(ctx, &glsp.Context{})
+	links, err := documentlink.DocumentLink(req, &protocol.DocumentLinkParams{
+		TextDocument: protocol.TextDocumentIdentifier{URI: "file:///style.css"},
+	})
+	require.NoError(t, err)
+	assert.Empty(t, links)
+
+	links, err = documentlink.DocumentLink(req, &protocol.DocumentLinkParams{
+		TextDocument: protocol.TextDocumentIdentifier{URI: "file:///unopened.json"},
+	})
+	require.NoError(t, err)
+	assert.Empty(t, links)
+}
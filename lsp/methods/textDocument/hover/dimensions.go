@@ -0,0 +1,76 @@
+package hover
+
+import (
+	"regexp"
+	"strconv"
+
+	"bennypowers.dev/dtls/internal/tokens"
+	csshelpers "bennypowers.dev/dtls/lsp/helpers/css"
+	"bennypowers.dev/dtls/lsp/types"
+)
+
+// numericValuePattern matches a plain number with an optional px or rem unit
+var numericValuePattern = regexp.MustCompile(`^(-?(?:\d+\.?\d*|\.\d+)(?:[eE][-+]?\d+)?)(px|rem)?$`)
+
+// displayValue returns the token's CSS value as configured by dimensionDisplay.
+// Array values are written as CSS. Only dimension and number tokens with
+// plain numeric, px or rem values are reformatted; everything else is shown
+// as written.
+func displayValue(token *tokens.Token, config types.ServerConfig) string {
+	raw := token.DisplayValue()
+	if csshelpers.IsArrayValue(token) {
+		if value, err := csshelpers.ArrayValue(token); err == nil {
+			raw = value
+		}
+	}
+	if config.DimensionDisplay == "" || config.DimensionDisplay == types.DimensionDisplayRaw {
+		return raw
+	}
+	if token.Type != "dimension" && token.Type != "number" {
+		return raw
+	}
+
+	match := numericValuePattern.FindStringSubmatch(raw)
+	if match == nil {
+		return raw
+	}
+	n, err := strconv.ParseFloat(match[1], 64)
+	if err != nil {
+		return raw
+	}
+
+	unit := match[2]
+	rootFontSize := config.RootFontSizePx()
+	px := n
+	switch unit {
+	case "":
+		return formatNumber(n, "")
+	case "rem":
+		px = n * rootFontSize
+	}
+
+	if config.DimensionDisplay == types.DimensionDisplayNormalized {
+		return formatNumber(px, "px")
+	}
+
+	// both: the value as written first, then its counterpart
+	if unit == "rem" {
+		return formatNumber(n, "rem") + " (" + formatNumber(px, "px") + ")"
+	}
+	return formatNumber(px, "px") + " (" + formatNumber(px/rootFontSize, "rem") + ")"
+}
+
+// formatNumber formats n with at most four decimal places and no trailing zeros
+func formatNumber(n float64, unit string) string {
+	s := strconv.FormatFloat(n, 'f', 4, 64)
+	for s[len(s)-1] == '0' {
+		s = s[:len(s)-1]
+	}
+	if s[len(s)-1] == '.' {
+		s = s[:len(s)-1]
+	}
+	if s == "-0" {
+		s = "0"
+	}
+	return s + unit
+}
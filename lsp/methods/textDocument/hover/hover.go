@@ -2,16 +2,21 @@ package hover
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"strings"
 	"text/template"
 
 	"bennypowers.dev/dtls/internal/documents"
+	"bennypowers.dev/dtls/internal/gitblame"
+	"bennypowers.dev/dtls/internal/i18n"
 	"bennypowers.dev/dtls/internal/log"
 	"bennypowers.dev/dtls/internal/parser"
 	"bennypowers.dev/dtls/internal/parser/common"
 	"bennypowers.dev/dtls/internal/parser/css"
+	"bennypowers.dev/dtls/internal/schema"
 	"bennypowers.dev/dtls/internal/tokens"
+	csshelpers "bennypowers.dev/dtls/lsp/helpers/css"
 	"bennypowers.dev/dtls/lsp/types"
 	protocol "github.com/tliron/glsp/protocol_3_16"
 )
@@ -19,7 +24,35 @@ import (
 // hoverData wraps a Token with additional structured fields for hover rendering.
 type hoverData struct {
 	*tokens.Token
-	Color *colorDetails
+	// CSSVariableName is the displayed variable name; it shadows Token.CSSVariableName()
+	CSSVariableName string
+	// DisplayValue is the formatted value; it shadows Token.DisplayValue()
+	DisplayValue string
+	// Expression is the expression a computed token's value comes from,
+	// e.g. "{spacing.base} * 2"
+	Expression string
+	// Aliases is the chain of an alias's value, from the token to the one
+	// holding the value, e.g. [color.accent color.brand color.primary]
+	Aliases []string
+	// Cycle is the circular part of an alias chain which never reaches a
+	// value, e.g. "color.a → color.b → color.a"
+	Cycle string
+	// Preview is an image of a gradient or shadow token, as a data URI
+	// (empty unless hoverPreviews is on)
+	Preview string
+	Color   *colorDetails
+	// History is the last commit to change the definition (nil unless valueHistory is on)
+	History *gitblame.Annotation
+	Schema  *schemaDetails
+}
+
+// schemaDetails describes the schema version of a token's source file
+type schemaDetails struct {
+	// Version is the schema version the file was parsed as, e.g. "draft"
+	Version string
+	// Mismatch warns that the file mixes constructs from different schema
+	// versions; empty if it doesn't
+	Mismatch string
 }
 
 // colorDetails holds structured color information for 2025.10 color tokens.
@@ -30,49 +63,86 @@ type colorDetails struct {
 	Hex        string
 }
 
+// templateFuncs are the functions available to hover templates. t translates
+// a label; it is bound to the request's locale by localized.
+var templateFuncs = template.FuncMap{
+	"t": func(format string, args ...any) string { return fmt.Sprintf(format, args...) },
+}
+
 // Template for token hover content
-var tokenHoverTemplate = template.Must(template.New("tokenHover").Parse(`# {{.CSSVariableName}}
+var tokenHoverTemplate = template.Must(template.New("tokenHover").Funcs(templateFuncs).Parse(`# {{.CSSVariableName}}
 {{if .Description}}
 {{.Description}}
+{{end}}{{if .Preview}}
+![{{t "Preview"}}]({{.Preview}})
 {{end}}
-**Value (CSS)**: ` + "`{{.DisplayValue}}`" + `
-{{if .Type}}**Type**: ` + "`{{.Type}}`" + `
-{{end}}{{if .Color}}**Color Space**: ` + "`{{.Color.ColorSpace}}`" + `
-**Components**: ` + "`{{.Color.Components}}`" + `
-{{if .Color.Alpha}}**Alpha**: ` + "`{{.Color.Alpha}}`" + `
-{{end}}{{if .Color.Hex}}**Hex**: ` + "`{{.Color.Hex}}`" + `
+**{{t "Value (CSS)"}}**: ` + "`{{.DisplayValue}}`" + `
+{{if .Expression}}**{{t "Expression"}}**: ` + "`{{.Expression}}`" + `
+{{end}}{{if .Aliases}}**{{t "Alias chain"}}**: {{range $i, $path := .Aliases}}{{if $i}} → {{end}}` + "`{{$path}}`" + `{{end}}
+{{end}}{{if .Type}}**{{t "Type"}}**: ` + "`{{.Type}}`" + `
+{{end}}{{if .Schema}}**{{t "Schema"}}**: ` + "`{{.Schema.Version}}`" + `
+{{end}}{{if .Color}}**{{t "Color Space"}}**: ` + "`{{.Color.ColorSpace}}`" + `
+**{{t "Components"}}**: ` + "`{{.Color.Components}}`" + `
+{{if .Color.Alpha}}**{{t "Alpha"}}**: ` + "`{{.Color.Alpha}}`" + `
+{{end}}{{if .Color.Hex}}**{{t "Hex"}}**: ` + "`{{.Color.Hex}}`" + `
 {{end}}{{end}}{{if .Deprecated}}
-⚠️ **DEPRECATED**{{if .DeprecationMessage}}: {{.DeprecationMessage}}{{end}}
+⚠️ **{{t "DEPRECATED"}}**{{if .DeprecationMessage}}: {{.DeprecationMessage}}{{end}}
+{{end}}{{if .Cycle}}
+⚠️ **{{t "Circular reference"}}**: {{.Cycle}}
+{{end}}{{if and .Schema .Schema.Mismatch}}
+⚠️ **{{t "Mixed schema versions"}}**: {{.Schema.Mismatch}}
 {{end}}{{if .FilePath}}
-*Defined in: {{.FilePath}}*
+*{{t "Defined in: %s" .FilePath}}*
+{{end}}{{if .History}}
+*{{t "Last changed %s: %s" .History.DateString .History.Subject}}*
 {{end}}`))
 
 // Template for unknown token message
-var unknownTokenTemplate = template.Must(template.New("unknownToken").Parse(`❌ **Unknown token**: ` + "`{{.}}`" + `
+var unknownTokenTemplate = template.Must(template.New("unknownToken").Funcs(templateFuncs).Parse(`❌ **{{t "Unknown token"}}**: ` + "`{{.}}`" + `
 
-This token is not defined in any loaded token files.`))
+{{t "This token is not defined in any loaded token files."}}`))
 
 // Plaintext template for token hover content
-var tokenHoverPlaintextTemplate = template.Must(template.New("tokenHoverPlaintext").Parse(`{{.CSSVariableName}}
+var tokenHoverPlaintextTemplate = template.Must(template.New("tokenHoverPlaintext").Funcs(templateFuncs).Parse(`{{.CSSVariableName}}
 {{if .Description}}
 {{.Description}}
 {{end}}
-Value (CSS): {{.DisplayValue}}
-{{if .Type}}Type: {{.Type}}
-{{end}}{{if .Color}}Color Space: {{.Color.ColorSpace}}
-Components: {{.Color.Components}}
-{{if .Color.Alpha}}Alpha: {{.Color.Alpha}}
-{{end}}{{if .Color.Hex}}Hex: {{.Color.Hex}}
+{{t "Value (CSS)"}}: {{.DisplayValue}}
+{{if .Expression}}{{t "Expression"}}: {{.Expression}}
+{{end}}{{if .Aliases}}{{t "Alias chain"}}: {{range $i, $path := .Aliases}}{{if $i}} → {{end}}{{$path}}{{end}}
+{{end}}{{if .Type}}{{t "Type"}}: {{.Type}}
+{{end}}{{if .Schema}}{{t "Schema"}}: {{.Schema.Version}}
+{{end}}{{if .Color}}{{t "Color Space"}}: {{.Color.ColorSpace}}
+{{t "Components"}}: {{.Color.Components}}
+{{if .Color.Alpha}}{{t "Alpha"}}: {{.Color.Alpha}}
+{{end}}{{if .Color.Hex}}{{t "Hex"}}: {{.Color.Hex}}
 {{end}}{{end}}{{if .Deprecated}}
-DEPRECATED{{if .DeprecationMessage}}: {{.DeprecationMessage}}{{end}}
+{{t "DEPRECATED"}}{{if .DeprecationMessage}}: {{.DeprecationMessage}}{{end}}
+{{end}}{{if .Cycle}}
+{{t "Circular reference"}}: {{.Cycle}}
+{{end}}{{if and .Schema .Schema.Mismatch}}
+{{t "Mixed schema versions"}}: {{.Schema.Mismatch}}
 {{end}}{{if .FilePath}}
-Defined in: {{.FilePath}}
+{{t "Defined in: %s" .FilePath}}
+{{end}}{{if .History}}
+{{t "Last changed %s: %s" .History.DateString .History.Subject}}
 {{end}}`))
 
 // Plaintext template for unknown token message
-var unknownTokenPlaintextTemplate = template.Must(template.New("unknownTokenPlaintext").Parse(`Unknown token: {{.}}
+var unknownTokenPlaintextTemplate = template.Must(template.New("unknownTokenPlaintext").Funcs(templateFuncs).Parse(`{{t "Unknown token"}}: {{.}}
 
-This token is not defined in any loaded token files.`))
+{{t "This token is not defined in any loaded token files."}}`))
+
+// localized returns a copy of tmpl whose labels are translated for locale
+func localized(tmpl *template.Template, locale string) (*template.Template, error) {
+	clone, err := tmpl.Clone()
+	if err != nil {
+		return nil, err
+	}
+	return clone.Funcs(template.FuncMap{
+		"t": func(format string, args ...any) string { return i18n.Sprintf(locale, format, args...) },
+	}), nil
+}
 
 // extractColorDetails extracts structured color information from a token's
 // raw or resolved value. Returns nil if the value is not a structured color object.
@@ -119,6 +189,15 @@ func extractColorDetails(token *tokens.Token) *colorDetails {
 	return cd
 }
 
+// tokenExpression returns the expression a token's value is computed from,
+// or empty for other tokens
+func tokenExpression(token *tokens.Token) string {
+	if !tokens.IsExpression(token.RawValue) {
+		return ""
+	}
+	return token.RawValue.(string)
+}
+
 // formatComponents formats a slice of color components for display.
 func formatComponents(components []any) string {
 	parts := make([]string, len(components))
@@ -135,11 +214,85 @@ func formatComponents(components []any) string {
 	return strings.Join(parts, ", ")
 }
 
+// displayName returns the token name shown in hover titles, honoring showPrefix
+func displayName(req *types.RequestContext, token *tokens.Token) string {
+	return req.Server.TokenManager().DisplayName(token, req.Config().ShowPrefixEnabled())
+}
+
+// renderRequestTokenHover renders the hover content for a token, applying the
+// server's display settings
+func renderRequestTokenHover(req *types.RequestContext, token *tokens.Token, format protocol.MarkupKind) (string, error) {
+	config := req.Config()
+	value := displayValue(token, config)
+	preview := ""
+	if config.HoverPreviews && format == protocol.MarkupKindMarkdown {
+		preview = tokenPreview(token, config.RootFontSizePx())
+	}
+	return renderTokenHover(req.Server.Locale(), token, displayName(req, token), value, preview, req.Server.ValueHistory(req.Config(), token), tokenSchema(req, token), tokenAliases(req, token), format)
+}
+
+// tokenAliases follows an alias token's chain through the loaded tokens. It
+// returns nil for tokens which aren't aliases.
+func tokenAliases(req *types.RequestContext, token *tokens.Token) *aliasChain {
+	if tokens.AliasTarget(token) == "" {
+		return nil
+	}
+	chain, err := tokens.AliasChain(token, req.Server.TokenManager().Get)
+	aliases := &aliasChain{}
+	// An alias of an unknown token has no chain to show
+	if len(chain) > 1 {
+		for _, link := range chain {
+			aliases.paths = append(aliases.paths, tokens.TokenPath(strings.Join(link.Path, ".")))
+		}
+	}
+	var cycle *tokens.CircularReferenceError
+	if errors.As(err, &cycle) {
+		aliases.cycle = strings.Join(cycle.Cycle, " → ")
+	}
+	return aliases
+}
+
+// aliasChain is the chain of an alias token's value
+type aliasChain struct {
+	paths []string
+	cycle string
+}
+
+// tokenSchema describes the schema version of the file a token was loaded
+// from, or returns nil for tokens not loaded from a token file
+func tokenSchema(req *types.RequestContext, token *tokens.Token) *schemaDetails {
+	if token.FilePath == "" || token.SchemaVersion == schema.Unknown {
+		return nil
+	}
+	details := &schemaDetails{Version: token.SchemaVersion.String()}
+
+	mismatches := req.Server.TokenManager().SchemaMismatches(token.FilePath)
+	if len(mismatches) > 0 {
+		first := mismatches[0]
+		details.Mismatch = req.Localize("this file was parsed as %s, but `%s` uses a %s %s",
+			details.Version, strings.Join(first.Token.Path, "."), first.Version, first.Construct)
+		if len(mismatches) > 1 {
+			details.Mismatch += req.Localize(" (and %d more)", len(mismatches)-1)
+		}
+	}
+	return details
+}
+
 // renderTokenHover renders the hover content for a token in the specified format
-func renderTokenHover(token *tokens.Token, format protocol.MarkupKind) (string, error) {
+func renderTokenHover(locale string, token *tokens.Token, cssVarName, value, preview string, history *gitblame.Annotation, schemaInfo *schemaDetails, aliases *aliasChain, format protocol.MarkupKind) (string, error) {
 	data := hoverData{
-		Token: token,
-		Color: extractColorDetails(token),
+		Token:           token,
+		CSSVariableName: cssVarName,
+		DisplayValue:    value,
+		Expression:      tokenExpression(token),
+		Preview:         preview,
+		Color:           extractColorDetails(token),
+		History:         history,
+		Schema:          schemaInfo,
+	}
+	if aliases != nil {
+		data.Aliases = aliases.paths
+		data.Cycle = aliases.cycle
 	}
 
 	var buf bytes.Buffer
@@ -149,6 +302,10 @@ func renderTokenHover(token *tokens.Token, format protocol.MarkupKind) (string,
 	} else {
 		tmpl = tokenHoverTemplate
 	}
+	tmpl, err := localized(tmpl, locale)
+	if err != nil {
+		return "", err
+	}
 	if err := tmpl.Execute(&buf, &data); err != nil {
 		return "", err
 	}
@@ -156,7 +313,7 @@ func renderTokenHover(token *tokens.Token, format protocol.MarkupKind) (string,
 }
 
 // renderUnknownToken renders the hover content for an unknown token in the specified format
-func renderUnknownToken(tokenName string, format protocol.MarkupKind) (string, error) {
+func renderUnknownToken(locale, tokenName string, format protocol.MarkupKind) (string, error) {
 	var buf bytes.Buffer
 	var tmpl *template.Template
 	if format == protocol.MarkupKindPlainText {
@@ -164,50 +321,16 @@ func renderUnknownToken(tokenName string, format protocol.MarkupKind) (string, e
 	} else {
 		tmpl = unknownTokenTemplate
 	}
+	tmpl, err := localized(tmpl, locale)
+	if err != nil {
+		return "", err
+	}
 	if err := tmpl.Execute(&buf, tokenName); err != nil {
 		return "", err
 	}
 	return buf.String(), nil
 }
 
-// findInnermostVarCall finds the innermost (smallest) var() call containing the cursor position.
-// Returns nil if no var() call contains the position.
-func findInnermostVarCall(position protocol.Position, varCalls []*css.VarCall) *css.VarCall {
-	var bestVarCall *css.VarCall
-	var smallestRangeSize = -1
-
-	for _, varCall := range varCalls {
-		if isPositionInRange(position, varCall.Range) {
-			rangeSize := calculateRangeSize(varCall.Range)
-			if smallestRangeSize == -1 || rangeSize < smallestRangeSize {
-				smallestRangeSize = rangeSize
-				bestVarCall = varCall
-			}
-		}
-	}
-
-	return bestVarCall
-}
-
-// findInnermostVariable finds the innermost (smallest) variable declaration containing the cursor position.
-// Returns nil if no variable declaration contains the position.
-func findInnermostVariable(position protocol.Position, variables []*css.Variable) *css.Variable {
-	var bestVariable *css.Variable
-	var smallestRangeSize = -1
-
-	for _, variable := range variables {
-		if isPositionInRange(position, variable.Range) {
-			rangeSize := calculateRangeSize(variable.Range)
-			if smallestRangeSize == -1 || rangeSize < smallestRangeSize {
-				smallestRangeSize = rangeSize
-				bestVariable = variable
-			}
-		}
-	}
-
-	return bestVariable
-}
-
 // createHoverResponse creates a protocol.Hover response with content in the specified format.
 // This is a common helper to avoid duplication across different hover scenarios.
 func createHoverResponse(content string, cssRange css.Range, format protocol.MarkupKind) *protocol.Hover {
@@ -233,26 +356,78 @@ func createHoverResponse(content string, cssRange css.Range, format protocol.Mar
 // Returns hover response or error. Shows "unknown token" message if token is not found.
 func processVarCallHover(req *types.RequestContext, varCall *css.VarCall) (*protocol.Hover, error) {
 	format := req.Server.PreferredHoverFormat()
+	content, err := renderVarCallContent(req, varCall, format)
+	if err != nil {
+		return nil, err
+	}
+	return createHoverResponse(content, varCall.Range, format), nil
+}
+
+// renderVarCallContent renders the hover content for a var() call's token,
+// or the "unknown token" message if it is not found
+func renderVarCallContent(req *types.RequestContext, varCall *css.VarCall, format protocol.MarkupKind) (string, error) {
 	token := req.Server.Token(varCall.TokenName)
 
 	if token == nil {
 		// Token not found - render unknown token message
-		content, err := renderUnknownToken(varCall.TokenName, format)
+		content, err := renderUnknownToken(req.Server.Locale(), varCall.TokenName, format)
 		if err != nil {
-			return nil, fmt.Errorf("failed to render unknown token message: %w", err)
+			return "", fmt.Errorf("failed to render unknown token message: %w", err)
 		}
-		return createHoverResponse(content, varCall.Range, format), nil
+		return content, nil
 	}
 
 	// Render token hover content
-	content, err := renderTokenHover(token, format)
+	content, err := renderRequestTokenHover(req, token, format)
 	if err != nil {
-		return nil, fmt.Errorf("failed to render token hover: %w", err)
+		return "", fmt.Errorf("failed to render token hover: %w", err)
 	}
+	return content, nil
+}
 
-	return createHoverResponse(content, varCall.Range, format), nil
+// processOverlappingHover combines the hover content for every token at the
+// cursor: the innermost var() call, the enclosing var() calls, and the token
+// defined by the enclosing declaration. Each token keeps its own heading and
+// sections are separated by a rule. The hover range is the innermost call's.
+// Unknown tokens are only reported for the innermost call, so local variables
+// wrapping a token don't add noise.
+func processOverlappingHover(req *types.RequestContext, varCalls []*css.VarCall, variable *css.Variable) (*protocol.Hover, error) {
+	format := req.Server.PreferredHoverFormat()
+
+	content, err := renderVarCallContent(req, varCalls[0], format)
+	if err != nil {
+		return nil, err
+	}
+	sections := []string{content}
+	seen := map[string]bool{varCalls[0].TokenName: true}
+
+	names := make([]string, 0, len(varCalls))
+	for _, varCall := range varCalls[1:] {
+		names = append(names, varCall.TokenName)
+	}
+	if variable != nil {
+		names = append(names, variable.Name)
+	}
+
+	for _, name := range names {
+		token := req.Server.Token(name)
+		if token == nil || seen[name] {
+			continue
+		}
+		seen[name] = true
+		section, err := renderRequestTokenHover(req, token, format)
+		if err != nil {
+			return nil, fmt.Errorf("failed to render token hover for %s: %w", name, err)
+		}
+		sections = append(sections, section)
+	}
+
+	return createHoverResponse(strings.Join(sections, sectionSeparator), varCalls[0].Range, format), nil
 }
 
+// sectionSeparator separates the tokens in a combined hover
+const sectionSeparator = "\n---\n\n"
+
 // processVariableHover processes hover for a variable declaration, looking up the token and rendering content.
 // Returns nil if the token is not found (local CSS variables without token definitions).
 func processVariableHover(req *types.RequestContext, variable *css.Variable) (*protocol.Hover, error) {
@@ -263,7 +438,7 @@ func processVariableHover(req *types.RequestContext, variable *css.Variable) (*p
 	}
 
 	// Render token hover content
-	content, err := renderTokenHover(token, format)
+	content, err := renderRequestTokenHover(req, token, format)
 	if err != nil {
 		return nil, fmt.Errorf("failed to render token hover for declaration: %w", err)
 	}
@@ -271,6 +446,26 @@ func processVariableHover(req *types.RequestContext, variable *css.Variable) (*p
 	return createHoverResponse(content, variable.Range, format), nil
 }
 
+// processAnnotationHover processes hover for the token named in an annotation
+// comment. Shows "unknown token" message if token is not found.
+func processAnnotationHover(req *types.RequestContext, annotation *css.Annotation) (*protocol.Hover, error) {
+	format := req.Server.PreferredHoverFormat()
+	token := req.Server.Token(annotation.TokenName)
+	if token == nil {
+		content, err := renderUnknownToken(req.Server.Locale(), annotation.TokenName, format)
+		if err != nil {
+			return nil, fmt.Errorf("failed to render unknown token message: %w", err)
+		}
+		return createHoverResponse(content, annotation.Range, format), nil
+	}
+
+	content, err := renderRequestTokenHover(req, token, format)
+	if err != nil {
+		return nil, fmt.Errorf("failed to render token hover for annotation: %w", err)
+	}
+	return createHoverResponse(content, annotation.Range, format), nil
+}
+
 // createTokenRefHoverResponse creates a protocol.Hover response for token references.
 func createTokenRefHoverResponse(content string, ref *common.TokenReferenceWithRange, format protocol.MarkupKind) *protocol.Hover {
 	return &protocol.Hover{
@@ -301,7 +496,7 @@ func processTokenReferenceHover(req *types.RequestContext, ref *common.TokenRefe
 
 	if token == nil {
 		// Token not found - render unknown token message
-		content, err := renderUnknownToken(ref.TokenName, format)
+		content, err := renderUnknownToken(req.Server.Locale(), ref.TokenName, format)
 		if err != nil {
 			return nil, fmt.Errorf("failed to render unknown token message: %w", err)
 		}
@@ -309,7 +504,7 @@ func processTokenReferenceHover(req *types.RequestContext, ref *common.TokenRefe
 	}
 
 	// Render token hover content
-	content, err := renderTokenHover(token, format)
+	content, err := renderRequestTokenHover(req, token, format)
 	if err != nil {
 		return nil, fmt.Errorf("failed to render token hover: %w", err)
 	}
@@ -334,11 +529,17 @@ func Hover(req *types.RequestContext, params *protocol.HoverParams) (*protocol.H
 
 	log.Info("Hover requested: %s at line %d, char %d", uri, position.Line, position.Character)
 
+	// Hover may be disabled in configuration after initialize
+	if !req.Config().Features.HoverEnabled() {
+		return nil, nil
+	}
+
 	// Get document
 	doc := req.Server.Document(uri)
 	if doc == nil {
 		return nil, nil
 	}
+	req.TraceDocument(doc)
 
 	languageID := doc.LanguageID()
 
@@ -365,17 +566,23 @@ func handleCSSHover(req *types.RequestContext, doc *documents.Document, position
 	if result == nil {
 		return nil, nil
 	}
+	req.TraceVarCalls(len(result.VarCalls))
 
-	// Check for var() calls first (priority for nested cases)
-	if varCall := findInnermostVarCall(position, result.VarCalls); varCall != nil {
-		return processVarCallHover(req, varCall)
+	// var() calls take priority; the innermost one leads the hover
+	if varCalls := csshelpers.VarCallsAt(position, result.VarCalls); len(varCalls) > 0 {
+		return processOverlappingHover(req, varCalls, csshelpers.DeclarationValueAt(position, result.Variables))
 	}
 
 	// Check for variable declarations
-	if variable := findInnermostVariable(position, result.Variables); variable != nil {
+	if variable := csshelpers.DeclarationNameAt(position, result.Variables); variable != nil {
 		return processVariableHover(req, variable)
 	}
 
+	// Check for token annotation comments, e.g. /* token: color.primary */
+	if annotation := csshelpers.AnnotationAt(position, result.Annotations); annotation != nil {
+		return processAnnotationHover(req, annotation)
+	}
+
 	return nil, nil
 }
 
@@ -391,43 +598,3 @@ func handleTokenFileHover(req *types.RequestContext, doc *documents.Document, po
 
 	return processTokenReferenceHover(req, ref)
 }
-
-// isPositionInRange checks if a position is within a range
-func isPositionInRange(pos protocol.Position, r css.Range) bool {
-	// Convert to comparable format
-	posLine := pos.Line
-	posChar := pos.Character
-
-	// Check if position is within range
-	if posLine < r.Start.Line || posLine > r.End.Line {
-		return false
-	}
-
-	if posLine == r.Start.Line && posChar < r.Start.Character {
-		return false
-	}
-
-	if posLine == r.End.Line && posChar >= r.End.Character {
-		return false
-	}
-
-	return true
-}
-
-// calculateRangeSize computes a metric for range size to find the smallest (innermost) range
-// For nested ranges, we want to select the innermost one containing the cursor position
-func calculateRangeSize(r css.Range) int {
-	// Calculate total character span across all lines
-	// Simple metric: (line difference * 10000) + character difference
-	// This ensures multi-line ranges are always larger than single-line ranges
-	lineDiff := r.End.Line - r.Start.Line
-	charDiff := r.End.Character - r.Start.Character
-
-	if lineDiff == 0 {
-		// Single line: just use character difference
-		return int(charDiff)
-	}
-
-	// Multi-line: weight lines heavily to ensure they're always larger
-	return int(lineDiff)*10000 + int(charDiff)
-}
@@ -3,10 +3,13 @@ package hover
 import (
 	"flag"
 	"os"
+	"strings"
 	"testing"
+	"time"
 
 	asimonim "bennypowers.dev/asimonim/parser"
-	"bennypowers.dev/dtls/internal/parser/css"
+	"bennypowers.dev/dtls/internal/gitblame"
+	"bennypowers.dev/dtls/internal/schema"
 	tokens "bennypowers.dev/dtls/internal/tokens"
 	"bennypowers.dev/dtls/lsp/testutil"
 	"bennypowers.dev/dtls/lsp/types"
@@ -18,51 +21,6 @@ import (
 
 var update = flag.Bool("update", false, "update golden files")
 
-// TestIsPositionInRange tests the isPositionInRange function with half-open range semantics [start, end)
-func TestIsPositionInRange(t *testing.T) {
-	tests := []struct {
-		name     string
-		pos      protocol.Position
-		r        css.Range
-		expected bool
-	}{
-		{
-			name: "position at start boundary - included",
-			pos:  protocol.Position{Line: 0, Character: 5},
-			r: css.Range{
-				Start: css.Position{Line: 0, Character: 5},
-				End:   css.Position{Line: 0, Character: 10},
-			},
-			expected: true,
-		},
-		{
-			name: "position at end boundary - excluded",
-			pos:  protocol.Position{Line: 0, Character: 10},
-			r: css.Range{
-				Start: css.Position{Line: 0, Character: 5},
-				End:   css.Position{Line: 0, Character: 10},
-			},
-			expected: false,
-		},
-		{
-			name: "position inside range",
-			pos:  protocol.Position{Line: 0, Character: 7},
-			r: css.Range{
-				Start: css.Position{Line: 0, Character: 5},
-				End:   css.Position{Line: 0, Character: 10},
-			},
-			expected: true,
-		},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			result := isPositionInRange(tt.pos, tt.r)
-			assert.Equal(t, tt.expected, result)
-		})
-	}
-}
-
 func TestHover_CSSVariableReference(t *testing.T) {
 	ctx := testutil.NewMockServerContext()
 	glspCtx := &glsp.Context{}
@@ -106,6 +64,300 @@ func TestHover_CSSVariableReference(t *testing.T) {
 	require.NotNil(t, hover.Range, "Range should be present for var() call")
 }
 
+func TestHover_DisabledInConfig(t *testing.T) {
+	ctx := testutil.NewMockServerContext()
+	req := types.NewRequestContext(ctx, &glsp.Context{})
+
+	disabled := false
+	config := types.DefaultConfig()
+	config.Features.Hover = &disabled
+	ctx.SetConfig(config)
+
+	require.NoError(t, ctx.TokenManager().Add(&tokens.Token{
+		Name:  "color.primary",
+		Value: "#ff0000",
+		Type:  "color",
+	}))
+
+	uri := "file:///test.css"
+	require.NoError(t, ctx.DocumentManager().DidOpen(uri, "css", 1, `.button { color: var(--color-primary); }`))
+
+	hover, err := Hover(req, &protocol.HoverParams{
+		TextDocumentPositionParams: protocol.TextDocumentPositionParams{
+			TextDocument: protocol.TextDocumentIdentifier{URI: uri},
+			Position:     protocol.Position{Line: 0, Character: 24},
+		},
+	})
+
+	require.NoError(t, err)
+	assert.Nil(t, hover)
+}
+
+func TestHover_HidePrefix(t *testing.T) {
+	ctx := testutil.NewMockServerContext()
+	req := types.NewRequestContext(ctx, &glsp.Context{})
+
+	hide := false
+	config := types.DefaultConfig()
+	config.ShowPrefix = &hide
+	ctx.SetConfig(config)
+
+	require.NoError(t, ctx.TokenManager().Add(&tokens.Token{
+		Name:   "color-primary",
+		Path:   []string{"color", "primary"},
+		Prefix: "ds",
+		Value:  "#ff0000",
+		Type:   "color",
+	}))
+
+	uri := "file:///test.css"
+	require.NoError(t, ctx.DocumentManager().DidOpen(uri, "css", 1, `.button { color: var(--ds-color-primary); }`))
+
+	hover, err := Hover(req, &protocol.HoverParams{
+		TextDocumentPositionParams: protocol.TextDocumentPositionParams{
+			TextDocument: protocol.TextDocumentIdentifier{URI: uri},
+			Position:     protocol.Position{Line: 0, Character: 24},
+		},
+	})
+	require.NoError(t, err)
+	require.NotNil(t, hover)
+
+	content, ok := hover.Contents.(protocol.MarkupContent)
+	require.True(t, ok)
+	assert.True(t, strings.HasPrefix(content.Value, "# --color-primary\n"), "title should omit the prefix: %q", content.Value)
+}
+
+func TestHover_SCSSVariable(t *testing.T) {
+	ctx := testutil.NewMockServerContext()
+	req := types.NewRequestContext(ctx, &glsp.Context{})
+
+	require.NoError(t, ctx.TokenManager().Add(&tokens.Token{
+		Name:  "color-primary",
+		Path:  []string{"color", "primary"},
+		Value: "#0000ff",
+		Type:  "color",
+	}))
+
+	uri := "file:///test.scss"
+	scssContent := "$primary: var(--color-primary);\n.button { color: $primary; }"
+	require.NoError(t, ctx.DocumentManager().DidOpen(uri, "scss", 1, scssContent))
+
+	hover, err := Hover(req, &protocol.HoverParams{
+		TextDocumentPositionParams: protocol.TextDocumentPositionParams{
+			TextDocument: protocol.TextDocumentIdentifier{URI: uri},
+			Position:     protocol.Position{Line: 1, Character: 20},
+		},
+	})
+	require.NoError(t, err)
+	require.NotNil(t, hover)
+
+	content, ok := hover.Contents.(protocol.MarkupContent)
+	require.True(t, ok)
+	assert.Contains(t, content.Value, "# --color-primary")
+	assert.Equal(t, protocol.Range{
+		Start: protocol.Position{Line: 1, Character: 17},
+		End:   protocol.Position{Line: 1, Character: 25},
+	}, *hover.Range)
+}
+
+func TestHover_Annotation(t *testing.T) {
+	ctx := testutil.NewMockServerContext()
+	req := types.NewRequestContext(ctx, &glsp.Context{})
+
+	require.NoError(t, ctx.TokenManager().Add(&tokens.Token{
+		Name:  "color-primary",
+		Path:  []string{"color", "primary"},
+		Value: "#0000ff",
+		Type:  "color",
+	}))
+
+	uri := "file:///test.scss"
+	content := ".button {\n  /* token: color.primary */\n  color: $primary;\n  /* token: color.missing */\n}"
+	require.NoError(t, ctx.DocumentManager().DidOpen(uri, "scss", 1, content))
+
+	hoverAt := func(line, character uint32) *protocol.Hover {
+		hover, err := Hover(req, &protocol.HoverParams{
+			TextDocumentPositionParams: protocol.TextDocumentPositionParams{
+				TextDocument: protocol.TextDocumentIdentifier{URI: uri},
+				Position:     protocol.Position{Line: line, Character: character},
+			},
+		})
+		require.NoError(t, err)
+		return hover
+	}
+
+	hover := hoverAt(1, 16)
+	require.NotNil(t, hover)
+	markup, ok := hover.Contents.(protocol.MarkupContent)
+	require.True(t, ok)
+	assert.Contains(t, markup.Value, "# --color-primary")
+	assert.Equal(t, protocol.Range{
+		Start: protocol.Position{Line: 1, Character: 12},
+		End:   protocol.Position{Line: 1, Character: 25},
+	}, *hover.Range)
+
+	hover = hoverAt(3, 16)
+	require.NotNil(t, hover)
+	markup, ok = hover.Contents.(protocol.MarkupContent)
+	require.True(t, ok)
+	assert.Contains(t, markup.Value, "color.missing", "unknown tokens are reported")
+
+	assert.Nil(t, hoverAt(1, 5), "the rest of the comment has no hover")
+}
+
+func TestHover_ValueHistory(t *testing.T) {
+	ctx := testutil.NewMockServerContext()
+	req := types.NewRequestContext(ctx, &glsp.Context{})
+
+	require.NoError(t, ctx.TokenManager().Add(&tokens.Token{
+		Name:     "color-primary",
+		Value:    "#0000ff",
+		FilePath: "/project/tokens.json",
+	}))
+	ctx.SetValueHistory("color-primary", &gitblame.Annotation{
+		Commit:  "8c1b2f0",
+		Date:    time.Date(2026, 3, 14, 9, 30, 0, 0, time.UTC),
+		Subject: "Darken primary for contrast",
+	})
+
+	uri := "file:///test.css"
+	require.NoError(t, ctx.DocumentManager().DidOpen(uri, "css", 1, ".a { color: var(--color-primary); }"))
+
+	hover, err := Hover(req, &protocol.HoverParams{
+		TextDocumentPositionParams: protocol.TextDocumentPositionParams{
+			TextDocument: protocol.TextDocumentIdentifier{URI: uri},
+			Position:     protocol.Position{Line: 0, Character: 20},
+		},
+	})
+	require.NoError(t, err)
+	require.NotNil(t, hover)
+
+	content, ok := hover.Contents.(protocol.MarkupContent)
+	require.True(t, ok)
+	assert.True(t, strings.HasSuffix(content.Value, "*Last changed 2026-03-14: Darken primary for contrast*\n"),
+		"history goes at the bottom of the hover, got:\n%s", content.Value)
+}
+
+func TestHover_ComputedToken(t *testing.T) {
+	ctx := testutil.NewMockServerContext()
+	req := types.NewRequestContext(ctx, &glsp.Context{})
+
+	base := &tokens.Token{Name: "spacing.base", Path: []string{"spacing", "base"}, Value: "8px", RawValue: "8px", Type: "dimension"}
+	double := &tokens.Token{Name: "spacing.double", Path: []string{"spacing", "double"}, Value: "{spacing.base} * 2", RawValue: "{spacing.base} * 2", Type: "dimension"}
+	require.NoError(t, tokens.ResolveExpressions([]*tokens.Token{base, double}))
+	require.NoError(t, ctx.TokenManager().Add(base))
+	require.NoError(t, ctx.TokenManager().Add(double))
+
+	uri := "file:///test.css"
+	require.NoError(t, ctx.DocumentManager().DidOpen(uri, "css", 1, `.a { padding: var(--spacing-double); }`))
+
+	hover, err := Hover(req, &protocol.HoverParams{
+		TextDocumentPositionParams: protocol.TextDocumentPositionParams{
+			TextDocument: protocol.TextDocumentIdentifier{URI: uri},
+			Position:     protocol.Position{Line: 0, Character: 22},
+		},
+	})
+	require.NoError(t, err)
+	require.NotNil(t, hover)
+
+	content, ok := hover.Contents.(protocol.MarkupContent)
+	require.True(t, ok)
+	assert.Contains(t, content.Value, "**Value (CSS)**: `16px`")
+	assert.Contains(t, content.Value, "**Expression**: `{spacing.base} * 2`")
+}
+
+func TestHover_AliasToken(t *testing.T) {
+	ctx := testutil.NewMockServerContext()
+	req := types.NewRequestContext(ctx, &glsp.Context{})
+
+	primary := &tokens.Token{Name: "color-primary", Path: []string{"color", "primary"}, RawValue: "#ff0000", Type: "color"}
+	brand := &tokens.Token{Name: "color-brand", Path: []string{"color", "brand"}, RawValue: "{color.primary}", Type: "color"}
+	accent := &tokens.Token{Name: "color-accent", Path: []string{"color", "accent"}, RawValue: "{color.brand}", Type: "color"}
+	loopA := &tokens.Token{Name: "loop-a", Path: []string{"loop", "a"}, RawValue: "{loop.b}", Type: "color"}
+	loopB := &tokens.Token{Name: "loop-b", Path: []string{"loop", "b"}, RawValue: "{loop.a}", Type: "color"}
+	all := []*tokens.Token{primary, brand, accent, loopA, loopB}
+	assert.Error(t, tokens.ResolveAliasChains(all))
+	for _, token := range all {
+		require.NoError(t, ctx.TokenManager().Add(token))
+	}
+
+	uri := "file:///test.css"
+	require.NoError(t, ctx.DocumentManager().DidOpen(uri, "css", 1, ".a { color: var(--color-accent); }\n.b { color: var(--loop-a); }"))
+	hoverAt := func(line uint32) string {
+		hover, err := Hover(req, &protocol.HoverParams{
+			TextDocumentPositionParams: protocol.TextDocumentPositionParams{
+				TextDocument: protocol.TextDocumentIdentifier{URI: uri},
+				Position:     protocol.Position{Line: line, Character: 20},
+			},
+		})
+		require.NoError(t, err)
+		require.NotNil(t, hover)
+		return hover.Contents.(protocol.MarkupContent).Value
+	}
+
+	content := hoverAt(0)
+	assert.Contains(t, content, "**Value (CSS)**: `#ff0000`")
+	assert.Contains(t, content, "**Alias chain**: `color.accent` → `color.brand` → `color.primary`")
+	assert.NotContains(t, content, "Circular reference")
+
+	content = hoverAt(1)
+	assert.Contains(t, content, "**Alias chain**: `loop.a` → `loop.b`")
+	assert.Contains(t, content, "⚠️ **Circular reference**: loop.a → loop.b → loop.a")
+}
+
+func TestHover_ArrayValue(t *testing.T) {
+	ctx := testutil.NewMockServerContext()
+	req := types.NewRequestContext(ctx, &glsp.Context{})
+
+	require.NoError(t, ctx.TokenManager().Add(&tokens.Token{
+		Name:     "font.body",
+		Value:    `["Helvetica Neue","Arial","sans-serif"]`,
+		RawValue: []any{"Helvetica Neue", "Arial", "sans-serif"},
+		Type:     "fontFamily",
+	}))
+
+	uri := "file:///test.css"
+	require.NoError(t, ctx.DocumentManager().DidOpen(uri, "css", 1, `.a { font-family: var(--font-body); }`))
+
+	hover, err := Hover(req, &protocol.HoverParams{
+		TextDocumentPositionParams: protocol.TextDocumentPositionParams{
+			TextDocument: protocol.TextDocumentIdentifier{URI: uri},
+			Position:     protocol.Position{Line: 0, Character: 25},
+		},
+	})
+	require.NoError(t, err)
+	require.NotNil(t, hover)
+
+	content, ok := hover.Contents.(protocol.MarkupContent)
+	require.True(t, ok)
+	assert.Contains(t, content.Value, "**Value (CSS)**: `\"Helvetica Neue\", Arial, sans-serif`")
+}
+
+func TestHover_ConditionalRules(t *testing.T) {
+	ctx := testutil.NewMockServerContext()
+	req := types.NewRequestContext(ctx, &glsp.Context{})
+	require.NoError(t, ctx.TokenManager().Add(&tokens.Token{Name: "color.primary", Value: "#0000ff", Type: "color"}))
+
+	uri := "file:///test.css"
+	content := "@supports (color: var(--color-primary)) {}\n@container style(--color-primary: #0000ff) {}"
+	require.NoError(t, ctx.DocumentManager().DidOpen(uri, "css", 1, content))
+
+	for _, position := range []protocol.Position{{Line: 0, Character: 25}, {Line: 1, Character: 20}} {
+		hover, err := Hover(req, &protocol.HoverParams{
+			TextDocumentPositionParams: protocol.TextDocumentPositionParams{
+				TextDocument: protocol.TextDocumentIdentifier{URI: uri},
+				Position:     position,
+			},
+		})
+		require.NoError(t, err)
+		require.NotNil(t, hover, "hover at %v", position)
+
+		markup, ok := hover.Contents.(protocol.MarkupContent)
+		require.True(t, ok)
+		assert.Contains(t, markup.Value, "#0000ff")
+	}
+}
+
 func TestHover_DeprecatedToken(t *testing.T) {
 	ctx := testutil.NewMockServerContext()
 	glspCtx := &glsp.Context{}
@@ -233,6 +485,71 @@ func TestHover_NestedVarCalls(t *testing.T) {
 	assert.Contains(t, content.Value, "--color-primary")
 }
 
+func TestHover_OverlappingTokens(t *testing.T) {
+	ctx := testutil.NewMockServerContext()
+	req := types.NewRequestContext(ctx, &glsp.Context{})
+
+	for _, token := range []*tokens.Token{
+		{Name: "color-primary", Value: "#ff0000", Type: "color"},
+		{Name: "color-accent", Value: "#00ff00", Type: "color"},
+		{Name: "button-bg", Value: "#ff0000", Type: "color"},
+	} {
+		require.NoError(t, ctx.TokenManager().Add(token))
+	}
+
+	uri := "file:///test.css"
+	css := strings.Join([]string{
+		".a { color: var(--color-accent, var(--color-primary)); }",
+		":root { --button-bg: var(--color-primary); }",
+		".b { color: var(--local, var(--color-primary)); }",
+	}, "\n")
+	require.NoError(t, ctx.DocumentManager().DidOpen(uri, "css", 1, css))
+
+	hoverAt := func(line, char uint32) *protocol.Hover {
+		t.Helper()
+		hover, err := Hover(req, &protocol.HoverParams{
+			TextDocumentPositionParams: protocol.TextDocumentPositionParams{
+				TextDocument: protocol.TextDocumentIdentifier{URI: uri},
+				Position:     protocol.Position{Line: line, Character: char},
+			},
+		})
+		require.NoError(t, err)
+		require.NotNil(t, hover)
+		return hover
+	}
+
+	t.Run("nested var() shows inner then outer", func(t *testing.T) {
+		hover := hoverAt(0, 40)
+		content := hover.Contents.(protocol.MarkupContent).Value
+		sections := strings.Split(content, "\n---\n\n")
+		require.Len(t, sections, 2)
+		assert.True(t, strings.HasPrefix(sections[0], "# --color-primary\n"))
+		assert.True(t, strings.HasPrefix(sections[1], "# --color-accent\n"))
+		assert.Equal(t, uint32(32), hover.Range.Start.Character, "range is the innermost call")
+	})
+
+	t.Run("declaration that defines and uses a token", func(t *testing.T) {
+		content := hoverAt(1, 28).Contents.(protocol.MarkupContent).Value
+		sections := strings.Split(content, "\n---\n\n")
+		require.Len(t, sections, 2)
+		assert.True(t, strings.HasPrefix(sections[0], "# --color-primary\n"))
+		assert.True(t, strings.HasPrefix(sections[1], "# --button-bg\n"))
+	})
+
+	t.Run("outer unknown variables are omitted", func(t *testing.T) {
+		content := hoverAt(2, 32).Contents.(protocol.MarkupContent).Value
+		assert.NotContains(t, content, "---")
+		assert.NotContains(t, content, "Unknown token")
+		assert.True(t, strings.HasPrefix(content, "# --color-primary\n"))
+	})
+
+	t.Run("single token is unchanged", func(t *testing.T) {
+		content := hoverAt(0, 18).Contents.(protocol.MarkupContent).Value
+		assert.NotContains(t, content, "---")
+		assert.True(t, strings.HasPrefix(content, "# --color-accent\n"))
+	})
+}
+
 func TestHover_VarCallOutsideCursorRange(t *testing.T) {
 	ctx := testutil.NewMockServerContext()
 	glspCtx := &glsp.Context{}
@@ -852,6 +1169,72 @@ func TestHover_TSXCSSTemplate(t *testing.T) {
 	assert.Equal(t, uint32(1), hover.Range.Start.Line)
 }
 
+func TestHover_StyledComponentsTemplate(t *testing.T) {
+	ctx := testutil.NewMockServerContext()
+	glspCtx := &glsp.Context{}
+	req := types.NewRequestContext(ctx, glspCtx)
+
+	require.NoError(t, ctx.TokenManager().Add(&tokens.Token{
+		Name:  "spacing.small",
+		Value: "8px",
+		Type:  "dimension",
+	}))
+
+	uri := "file:///test.tsx"
+	content := "const Button = styled.button<Props>`\n  color: ${(p) => p.color};\n  padding: var(--spacing-small);\n`;"
+	require.NoError(t, ctx.DocumentManager().DidOpen(uri, "typescriptreact", 1, content))
+
+	// Character 20 is inside var(--spacing-small) on line 2, after the substitution
+	hover, err := Hover(req, &protocol.HoverParams{
+		TextDocumentPositionParams: protocol.TextDocumentPositionParams{
+			TextDocument: protocol.TextDocumentIdentifier{URI: uri},
+			Position:     protocol.Position{Line: 2, Character: 20},
+		},
+	})
+
+	require.NoError(t, err)
+	require.NotNil(t, hover)
+	mc, ok := hover.Contents.(protocol.MarkupContent)
+	require.True(t, ok)
+	assert.Contains(t, mc.Value, "--spacing-small")
+
+	require.NotNil(t, hover.Range)
+	assert.Equal(t, protocol.Position{Line: 2, Character: 11}, hover.Range.Start)
+}
+
+func TestHover_JSPropertyName(t *testing.T) {
+	ctx := testutil.NewMockServerContext()
+	glspCtx := &glsp.Context{}
+	req := types.NewRequestContext(ctx, glspCtx)
+
+	require.NoError(t, ctx.TokenManager().Add(&tokens.Token{
+		Name:  "spacing.small",
+		Value: "8px",
+		Type:  "dimension",
+	}))
+
+	uri := "file:///test.ts"
+	content := "const v = getComputedStyle(el).getPropertyValue('--spacing-small');"
+	require.NoError(t, ctx.DocumentManager().DidOpen(uri, "typescript", 1, content))
+
+	hover, err := Hover(req, &protocol.HoverParams{
+		TextDocumentPositionParams: protocol.TextDocumentPositionParams{
+			TextDocument: protocol.TextDocumentIdentifier{URI: uri},
+			Position:     protocol.Position{Line: 0, Character: 55},
+		},
+	})
+
+	require.NoError(t, err)
+	require.NotNil(t, hover)
+	mc, ok := hover.Contents.(protocol.MarkupContent)
+	require.True(t, ok)
+	assert.Contains(t, mc.Value, "--spacing-small")
+
+	require.NotNil(t, hover.Range)
+	assert.Equal(t, protocol.Position{Line: 0, Character: 49}, hover.Range.Start)
+	assert.Equal(t, protocol.Position{Line: 0, Character: 64}, hover.Range.End)
+}
+
 // ============================================================================
 // JSON/YAML Token Reference Hover Tests
 // ============================================================================
@@ -1148,7 +1531,7 @@ func TestRenderTokenHover_StructuredColor(t *testing.T) {
 			token, ok := tt.tokens[tt.tokenName]
 			require.True(t, ok, "token %q not found in fixture", tt.tokenName)
 
-			content, err := renderTokenHover(token, tt.format)
+			content, err := renderTokenHover("", token, token.CSSVariableName(), token.DisplayValue(), "", nil, nil, nil, tt.format)
 			require.NoError(t, err)
 
 			if *update {
@@ -1163,3 +1546,113 @@ func TestRenderTokenHover_StructuredColor(t *testing.T) {
 		})
 	}
 }
+
+func TestDisplayValue(t *testing.T) {
+	tests := []struct {
+		name      string
+		token     *tokens.Token
+		display   string
+		rootFont  float64
+		wantValue string
+	}{
+		{"raw by default", &tokens.Token{Type: "dimension", Value: "1.50rem"}, "", 0, "1.50rem"},
+		{"raw", &tokens.Token{Type: "dimension", Value: "24px"}, "raw", 0, "24px"},
+		{"normalized rem", &tokens.Token{Type: "dimension", Value: "1.50rem"}, "normalized", 0, "24px"},
+		{"normalized px", &tokens.Token{Type: "dimension", Value: "24.0px"}, "normalized", 0, "24px"},
+		{"normalized number", &tokens.Token{Type: "number", Value: ".50"}, "normalized", 0, "0.5"},
+		{"both px", &tokens.Token{Type: "dimension", Value: "24px"}, "both", 0, "24px (1.5rem)"},
+		{"both rem", &tokens.Token{Type: "dimension", Value: "1.5rem"}, "both", 0, "1.5rem (24px)"},
+		{"root font size", &tokens.Token{Type: "dimension", Value: "20px"}, "both", 10, "20px (2rem)"},
+		{"repeating decimal", &tokens.Token{Type: "dimension", Value: "10px"}, "both", 0, "10px (0.625rem)"},
+		{"other units", &tokens.Token{Type: "dimension", Value: "50%"}, "both", 0, "50%"},
+		{"other types", &tokens.Token{Type: "fontWeight", Value: "400.0"}, "normalized", 0, "400.0"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := types.ServerConfig{DimensionDisplay: tt.display, RootFontSize: tt.rootFont}
+			assert.Equal(t, tt.wantValue, displayValue(tt.token, config))
+		})
+	}
+}
+
+func TestHover_DimensionDisplay(t *testing.T) {
+	ctx := testutil.NewMockServerContext()
+	req := types.NewRequestContext(ctx, &glsp.Context{})
+	ctx.SetConfig(types.ServerConfig{DimensionDisplay: types.DimensionDisplayBoth, RootFontSize: 10})
+
+	require.NoError(t, ctx.TokenManager().Add(&tokens.Token{
+		Name:  "spacing.small",
+		Value: "8px",
+		Type:  "dimension",
+	}))
+
+	uri := "file:///test.css"
+	require.NoError(t, ctx.DocumentManager().DidOpen(uri, "css", 1, ".a { padding: var(--spacing-small); }"))
+
+	hover, err := Hover(req, &protocol.HoverParams{
+		TextDocumentPositionParams: protocol.TextDocumentPositionParams{
+			TextDocument: protocol.TextDocumentIdentifier{URI: uri},
+			Position:     protocol.Position{Line: 0, Character: 22},
+		},
+	})
+	require.NoError(t, err)
+	require.NotNil(t, hover)
+	mc, ok := hover.Contents.(protocol.MarkupContent)
+	require.True(t, ok)
+	assert.Contains(t, mc.Value, "**Value (CSS)**: `8px (0.8rem)`")
+}
+
+func TestHover_SchemaVersion(t *testing.T) {
+	ctx := testutil.NewMockServerContext()
+	req := types.NewRequestContext(ctx, &glsp.Context{})
+
+	require.NoError(t, ctx.TokenManager().Add(&tokens.Token{
+		Name:          "color-primary",
+		Path:          []string{"color", "primary"},
+		Value:         "#ff0000",
+		RawValue:      "#ff0000",
+		Type:          "color",
+		FilePath:      "/project/tokens.json",
+		SchemaVersion: schema.Draft,
+	}))
+
+	uri := "file:///test.css"
+	require.NoError(t, ctx.DocumentManager().DidOpen(uri, "css", 1, `.a { color: var(--color-primary); }`))
+	hoverAt := func() string {
+		hover, err := Hover(req, &protocol.HoverParams{
+			TextDocumentPositionParams: protocol.TextDocumentPositionParams{
+				TextDocument: protocol.TextDocumentIdentifier{URI: uri},
+				Position:     protocol.Position{Line: 0, Character: 20},
+			},
+		})
+		require.NoError(t, err)
+		require.NotNil(t, hover)
+		return hover.Contents.(protocol.MarkupContent).Value
+	}
+
+	content := hoverAt()
+	assert.Contains(t, content, "**Schema**: `draft`")
+	assert.NotContains(t, content, "Mixed schema versions")
+
+	// Another token in the same file uses 2025.10 constructs
+	for _, name := range []string{"accent", "brand"} {
+		require.NoError(t, ctx.TokenManager().Add(&tokens.Token{
+			Name:          "color-" + name,
+			Path:          []string{"color", name},
+			RawValue:      map[string]any{"colorSpace": "srgb", "components": []any{0.0, 0.0, 1.0}},
+			Type:          "color",
+			FilePath:      "/project/tokens.json",
+			SchemaVersion: schema.Draft,
+		}))
+	}
+
+	content = hoverAt()
+	assert.Contains(t, content, "⚠️ **Mixed schema versions**: this file was parsed as draft, but `color.accent` uses a v2025.10 structured color value (and 1 more)")
+}
+
+func TestHover_SchemaVersion_NotFromFile(t *testing.T) {
+	token := &tokens.Token{Name: "color-primary", Value: "#ff0000", SchemaVersion: schema.Draft}
+	req := types.NewRequestContext(testutil.NewMockServerContext(), &glsp.Context{})
+	assert.Nil(t, tokenSchema(req, token))
+}
@@ -0,0 +1,171 @@
+package hover
+
+import (
+	"encoding/base64"
+	"fmt"
+	"html"
+	"strconv"
+	"strings"
+
+	"bennypowers.dev/dtls/internal/tokens"
+)
+
+// Preview image geometry, in px. Shadows are cast by a box inset in a
+// larger canvas, so offsets and blurs up to previewPadding stay visible.
+const (
+	previewWidth    = 160
+	previewHeight   = 24
+	previewPadding  = 20
+	shadowBoxWidth  = 80
+	shadowBoxHeight = 40
+)
+
+// tokenPreview returns an inline SVG image, as a data URI, previewing a
+// gradient or shadow token, or "" for other tokens and values the preview
+// can't draw. rootFontSize converts rem offsets to px.
+func tokenPreview(token *tokens.Token, rootFontSize float64) string {
+	raw := token.RawValue
+	if token.IsResolved && token.ResolvedValue != nil {
+		raw = token.ResolvedValue
+	}
+
+	var svg string
+	var ok bool
+	switch strings.ToLower(token.Type) {
+	case "gradient":
+		svg, ok = gradientSVG(raw)
+	case "shadow":
+		svg, ok = shadowSVG(raw, rootFontSize)
+	}
+	if !ok {
+		return ""
+	}
+	return "data:image/svg+xml;base64," + base64.StdEncoding.EncodeToString([]byte(svg))
+}
+
+// gradientSVG draws a DTCG gradient, a list of color stops, as a left to
+// right linear gradient
+func gradientSVG(raw any) (string, bool) {
+	stops, ok := raw.([]any)
+	if !ok || len(stops) == 0 {
+		return "", false
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d"><linearGradient id="g">`, previewWidth, previewHeight)
+	for _, stop := range stops {
+		obj, ok := stop.(map[string]any)
+		if !ok {
+			return "", false
+		}
+		color, ok := previewColor(obj["color"])
+		if !ok {
+			return "", false
+		}
+		position, ok := obj["position"].(float64)
+		if !ok {
+			return "", false
+		}
+		fmt.Fprintf(&b, `<stop offset="%g" stop-color="%s"/>`, min(max(position, 0), 1), html.EscapeString(color))
+	}
+	fmt.Fprintf(&b, `</linearGradient><rect width="%d" height="%d" rx="4" fill="url(#g)"/></svg>`, previewWidth, previewHeight)
+	return b.String(), true
+}
+
+// shadowSVG draws one shadow or a list of shadows cast by a white box.
+// Each shadow is a blurred copy of the box, offset and grown by its spread,
+// under the box. Inset shadows are not drawn.
+func shadowSVG(raw any, rootFontSize float64) (string, bool) {
+	layers, ok := raw.([]any)
+	if !ok {
+		layers = []any{raw}
+	}
+
+	var defs, shapes strings.Builder
+	// The first shadow is on top, so shadows are drawn last to first
+	for i := len(layers) - 1; i >= 0; i-- {
+		obj, ok := layers[i].(map[string]any)
+		if !ok {
+			return "", false
+		}
+		if inset, _ := obj["inset"].(bool); inset {
+			continue
+		}
+		var lengths [4]float64
+		for j, name := range []string{"offsetX", "offsetY", "blur", "spread"} {
+			if _, set := obj[name]; !set && name == "spread" {
+				continue // no spread
+			}
+			if lengths[j], ok = previewLength(obj[name], rootFontSize); !ok {
+				return "", false
+			}
+		}
+		color, ok := previewColor(obj["color"])
+		if !ok {
+			return "", false
+		}
+
+		offsetX, offsetY, blur, spread := lengths[0], lengths[1], lengths[2], lengths[3]
+		filter := ""
+		if blur > 0 {
+			// A CSS blur radius is twice the standard deviation of the blur
+			fmt.Fprintf(&defs, `<filter id="s%d" x="-1" y="-1" width="3" height="3"><feGaussianBlur stdDeviation="%g"/></filter>`, i, blur/2)
+			filter = fmt.Sprintf(` filter="url(#s%d)"`, i)
+		}
+		fmt.Fprintf(&shapes, `<rect x="%g" y="%g" width="%g" height="%g" rx="4" fill="%s"%s/>`,
+			previewPadding+offsetX-spread, previewPadding+offsetY-spread,
+			max(shadowBoxWidth+2*spread, 0), max(shadowBoxHeight+2*spread, 0),
+			html.EscapeString(color), filter)
+	}
+
+	width, height := shadowBoxWidth+2*previewPadding, shadowBoxHeight+2*previewPadding
+	return fmt.Sprintf(`<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d">%s%s<rect x="%d" y="%d" width="%d" height="%d" rx="4" fill="#fff" stroke="#ddd"/></svg>`,
+		width, height, defs.String(), shapes.String(), previewPadding, previewPadding, shadowBoxWidth, shadowBoxHeight), true
+}
+
+// previewColor returns a color sub-value as CSS: a string as written, or a
+// structured color's hex fallback
+func previewColor(raw any) (string, bool) {
+	switch value := raw.(type) {
+	case string:
+		return value, value != "" && !strings.HasPrefix(value, "{")
+	case map[string]any:
+		hex, ok := value["hex"].(string)
+		return hex, ok
+	}
+	return "", false
+}
+
+// previewLength returns a dimension sub-value in px: a px, rem or unitless
+// string or number, or a structured dimension
+func previewLength(raw any, rootFontSize float64) (float64, bool) {
+	switch value := raw.(type) {
+	case float64:
+		return value, true
+	case string:
+		match := numericValuePattern.FindStringSubmatch(strings.TrimSpace(value))
+		if match == nil {
+			return 0, false
+		}
+		n, err := strconv.ParseFloat(match[1], 64)
+		if err != nil {
+			return 0, false
+		}
+		if match[2] == "rem" {
+			n *= rootFontSize
+		}
+		return n, true
+	case map[string]any:
+		n, ok := value["value"].(float64)
+		if !ok {
+			return 0, false
+		}
+		switch value["unit"] {
+		case "px":
+			return n, true
+		case "rem":
+			return n * rootFontSize, true
+		}
+	}
+	return 0, false
+}
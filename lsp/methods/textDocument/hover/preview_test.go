@@ -0,0 +1,117 @@
+package hover
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"bennypowers.dev/dtls/internal/tokens"
+	"bennypowers.dev/dtls/lsp/testutil"
+	"bennypowers.dev/dtls/lsp/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tliron/glsp"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+// previewSVG decodes a preview data URI
+func previewSVG(t *testing.T, uri string) string {
+	t.Helper()
+	data, ok := strings.CutPrefix(uri, "data:image/svg+xml;base64,")
+	require.True(t, ok, "preview is an SVG data URI: %s", uri)
+	svg, err := base64.StdEncoding.DecodeString(data)
+	require.NoError(t, err)
+	return string(svg)
+}
+
+func TestTokenPreview(t *testing.T) {
+	tests := []struct {
+		name     string
+		token    *tokens.Token
+		expected []string
+	}{
+		{
+			name: "gradient",
+			token: &tokens.Token{Type: "gradient", RawValue: []any{
+				map[string]any{"color": "#0066cc", "position": 0.0},
+				map[string]any{"color": map[string]any{"colorSpace": "srgb", "components": []any{1.0, 0.0, 0.0}, "hex": "#ff0000"}, "position": 1.0},
+			}},
+			expected: []string{`<stop offset="0" stop-color="#0066cc"/>`, `<stop offset="1" stop-color="#ff0000"/>`},
+		},
+		{
+			name: "resolved gradient",
+			token: &tokens.Token{Type: "gradient", IsResolved: true,
+				RawValue:      []any{map[string]any{"color": "{color.brand}", "position": 0.5}},
+				ResolvedValue: []any{map[string]any{"color": "rgb(0 0 0 / 50%)", "position": 0.5}},
+			},
+			expected: []string{`<stop offset="0.5" stop-color="rgb(0 0 0 / 50%)"/>`},
+		},
+		{
+			name: "shadow",
+			token: &tokens.Token{Type: "shadow", RawValue: map[string]any{
+				"offsetX": "0px", "offsetY": "4px", "blur": "8px", "spread": "0px", "color": "#00000080",
+			}},
+			expected: []string{`<feGaussianBlur stdDeviation="4"/>`, `<rect x="20" y="24" width="80" height="40" rx="4" fill="#00000080" filter="url(#s0)"/>`},
+		},
+		{
+			name: "shadow list with rem, structured dimensions and spread",
+			token: &tokens.Token{Type: "shadow", RawValue: []any{
+				map[string]any{"offsetX": "0.125rem", "offsetY": map[string]any{"value": 2.0, "unit": "px"}, "blur": "0", "spread": "1px", "color": "#000"},
+				map[string]any{"offsetX": 0.0, "offsetY": 0.0, "blur": 0.0, "color": "#fff", "inset": true},
+			}},
+			expected: []string{`<rect x="20" y="21" width="82" height="42" rx="4" fill="#000"/>`},
+		},
+		{name: "unresolved alias", token: &tokens.Token{Type: "shadow", RawValue: map[string]any{"offsetX": "0", "offsetY": "0", "blur": "0", "color": "{color.shadow}"}}},
+		{name: "unsupported unit", token: &tokens.Token{Type: "shadow", RawValue: map[string]any{"offsetX": "1em", "offsetY": "0", "blur": "0", "color": "#000"}}},
+		{name: "CSS gradient string", token: &tokens.Token{Type: "gradient", RawValue: "linear-gradient(red, blue)"}},
+		{name: "other type", token: &tokens.Token{Type: "color", RawValue: "#fff"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			preview := tokenPreview(tt.token, 8)
+			if tt.expected == nil {
+				assert.Empty(t, preview)
+				return
+			}
+			svg := previewSVG(t, preview)
+			for _, want := range tt.expected {
+				assert.Contains(t, svg, want)
+			}
+		})
+	}
+}
+
+func TestHover_Previews(t *testing.T) {
+	ctx := testutil.NewMockServerContext()
+	require.NoError(t, ctx.TokenManager().Add(&tokens.Token{
+		Name:  "shadow-raised",
+		Value: "0px 4px 8px 0px #00000080",
+		Type:  "shadow",
+		RawValue: map[string]any{
+			"offsetX": "0px", "offsetY": "4px", "blur": "8px", "spread": "0px", "color": "#00000080",
+		},
+	}))
+	uri := "file:///test.css"
+	require.NoError(t, ctx.DocumentManager().DidOpen(uri, "css", 1, ".a { box-shadow: var(--shadow-raised); }"))
+
+	hoverAt := func() string {
+		hover, err := Hover(types.NewRequestContext(ctx, &glsp.Context{}), &protocol.HoverParams{
+			TextDocumentPositionParams: protocol.TextDocumentPositionParams{
+				TextDocument: protocol.TextDocumentIdentifier{URI: uri},
+				Position:     protocol.Position{Line: 0, Character: 25},
+			},
+		})
+		require.NoError(t, err)
+		require.NotNil(t, hover)
+		return hover.Contents.(protocol.MarkupContent).Value
+	}
+
+	assert.NotContains(t, hoverAt(), "data:image/svg+xml", "off by default")
+
+	ctx.SetConfig(types.ServerConfig{HoverPreviews: true})
+	assert.Contains(t, hoverAt(), "![Preview](data:image/svg+xml;base64,")
+
+	ctx.SetPreferredHoverFormat(protocol.MarkupKindPlainText)
+	assert.NotContains(t, hoverAt(), "data:image/svg+xml", "plain text hovers can't show images")
+}
@@ -3,13 +3,26 @@ package textDocument
 import (
 	"bennypowers.dev/dtls/internal/documents"
 	"bennypowers.dev/dtls/internal/log"
+	"bennypowers.dev/dtls/internal/uriutil"
 
 	"bennypowers.dev/dtls/lsp/types"
 	protocol "github.com/tliron/glsp/protocol_3_16"
 )
 
+// acceptsDocument reports whether a document should be tracked.
+// Documents without a file URI are only processed when the nonFileDocuments
+// setting opts in to them.
+func acceptsDocument(req *types.RequestContext, uri string) bool {
+	return uriutil.IsFileURI(uri) || req.Config().NonFileDocuments
+}
+
 // DidOpen handles the textDocument/didOpen notification
 func DidOpen(req *types.RequestContext, params *protocol.DidOpenTextDocumentParams) error {
+	if !acceptsDocument(req, params.TextDocument.URI) {
+		log.Debug("Ignoring non-file document: %s", params.TextDocument.URI)
+		return nil
+	}
+
 	log.Info("Document opened: %s (language: %s, version: %d)",
 		params.TextDocument.URI, params.TextDocument.LanguageID, int(params.TextDocument.Version))
 
@@ -20,10 +33,12 @@ func DidOpen(req *types.RequestContext, params *protocol.DidOpenTextDocumentPara
 	}
 
 	// Auto-load tokens from files that look like DTCG token files
-	// This enables semantic tokens and other features for token files not in config
+	// This enables semantic tokens and other features for token files not in config.
+	// Tokens are keyed by file path, so scratch buffers are never loaded.
 	languageID := params.TextDocument.LanguageID
 	content := params.TextDocument.Text
-	if (languageID == "json" || languageID == "yaml") &&
+	if uriutil.IsFileURI(params.TextDocument.URI) &&
+		(languageID == "json" || languageID == "yaml") &&
 		(documents.IsDesignTokensSchema(content) || documents.LooksLikeDTCGContent(content)) {
 		if err := req.Server.LoadTokensFromDocumentContent(
 			params.TextDocument.URI,
@@ -52,6 +67,11 @@ func DidChange(req *types.RequestContext, params *protocol.DidChangeTextDocument
 	uri := params.TextDocument.URI
 	version := int(params.TextDocument.Version)
 
+	// Ignored non-file documents were never opened
+	if !uriutil.IsFileURI(uri) && req.Server.Document(uri) == nil {
+		return nil
+	}
+
 	log.Info("Document changed: %s (version: %d, changes: %d)", uri, version, len(params.ContentChanges))
 
 	// Convert any[] to proper type, filtering out invalid entries
@@ -67,6 +87,17 @@ func DidChange(req *types.RequestContext, params *protocol.DidChangeTextDocument
 		return err
 	}
 
+	// Edits to a custom properties file change the tokens it declares
+	if uriutil.IsFileURI(uri) {
+		if path := uriutil.URIToPath(uri); req.Server.IsCustomPropertiesFile(path) {
+			if doc := req.Server.Document(uri); doc != nil {
+				if err := req.Server.ReloadCustomPropertiesFile(path, doc.Content()); err != nil {
+					log.Warn("Failed to reload custom properties file %s: %v", path, err)
+				}
+			}
+		}
+	}
+
 	// Publish diagnostics after document change (only if using push model)
 	// If client supports pull diagnostics (LSP 3.17), it will request them via textDocument/diagnostic
 	if !req.Server.UsePullDiagnostics() {
@@ -84,10 +115,22 @@ func DidChange(req *types.RequestContext, params *protocol.DidChangeTextDocument
 func DidClose(req *types.RequestContext, params *protocol.DidCloseTextDocumentParams) error {
 	uri := params.TextDocument.URI
 
+	// Ignored non-file documents were never opened
+	if !uriutil.IsFileURI(uri) && req.Server.Document(uri) == nil {
+		return nil
+	}
+
 	log.Info("Document closed: %s", uri)
 
 	// Invalidate semantic token cache for this document
 	req.Server.SemanticTokenCache().Invalidate(uri)
+	req.Server.DiagnosticResultCache().Invalidate(uri)
+
+	// The document may have been saved while it was open, so the workspace
+	// index re-reads it from disk
+	if uriutil.IsFileURI(uri) {
+		req.Server.WorkspaceIndex().Update(uriutil.URIToPath(uri))
+	}
 
 	return req.Server.DocumentManager().DidClose(uri)
 }
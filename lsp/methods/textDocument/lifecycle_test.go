@@ -251,6 +251,30 @@ func TestDidChange(t *testing.T) {
 		err := DidChange(req, params)
 		require.NoError(t, err)
 	})
+
+	t.Run("reloads custom properties files", func(t *testing.T) {
+		ctx := testutil.NewMockServerContext()
+		req := types.NewRequestContext(ctx, &glsp.Context{})
+		ctx.SetRootPath("/workspace")
+		ctx.SetConfig(types.ServerConfig{CustomPropertiesFiles: []string{"generated.css"}})
+
+		for _, uri := range []string{"file:///workspace/generated.css", "file:///workspace/other.css"} {
+			_ = ctx.DocumentManager().DidOpen(uri, "css", 1, ":root { --gap: 4px; }")
+
+			textChange := protocol.TextDocumentContentChangeEvent{}
+			textChange.Text = ":root { --gap: 8px; }"
+			err := DidChange(req, &protocol.DidChangeTextDocumentParams{
+				TextDocument: protocol.VersionedTextDocumentIdentifier{
+					TextDocumentIdentifier: protocol.TextDocumentIdentifier{URI: uri},
+					Version:                2,
+				},
+				ContentChanges: []interface{}{textChange},
+			})
+			require.NoError(t, err)
+		}
+
+		assert.Equal(t, [][2]string{{"/workspace/generated.css", ":root { --gap: 8px; }"}}, ctx.ReloadedCustomProperties)
+	})
 }
 
 func TestDidClose(t *testing.T) {
@@ -310,3 +334,50 @@ func TestDidClose(t *testing.T) {
 		assert.NotNil(t, ctx.Document("file:///test2.css"))
 	})
 }
+
+func TestNonFileDocuments(t *testing.T) {
+	const uri = "untitled:Untitled-1"
+	open := &protocol.DidOpenTextDocumentParams{
+		TextDocument: protocol.TextDocumentItem{
+			URI:        uri,
+			LanguageID: "json",
+			Version:    1,
+			Text:       `{"color": {"$type": "color", "primary": {"$value": "#f00"}}}`,
+		},
+	}
+	change := &protocol.DidChangeTextDocumentParams{
+		TextDocument: protocol.VersionedTextDocumentIdentifier{
+			TextDocumentIdentifier: protocol.TextDocumentIdentifier{URI: uri},
+			Version:                2,
+		},
+		ContentChanges: []any{protocol.TextDocumentContentChangeEventWhole{Text: "{}"}},
+	}
+	closeParams := &protocol.DidCloseTextDocumentParams{
+		TextDocument: protocol.TextDocumentIdentifier{URI: uri},
+	}
+
+	t.Run("ignored by default", func(t *testing.T) {
+		ctx := testutil.NewMockServerContext()
+		req := types.NewRequestContext(ctx, &glsp.Context{})
+
+		require.NoError(t, DidOpen(req, open))
+		assert.Nil(t, ctx.Document(uri))
+		require.NoError(t, DidChange(req, change), "changes to ignored documents are not errors")
+		require.NoError(t, DidClose(req, closeParams), "closing ignored documents is not an error")
+	})
+
+	t.Run("tracked when opted in", func(t *testing.T) {
+		ctx := testutil.NewMockServerContext()
+		req := types.NewRequestContext(ctx, &glsp.Context{})
+		cfg := ctx.GetConfig()
+		cfg.NonFileDocuments = true
+		ctx.SetConfig(cfg)
+
+		require.NoError(t, DidOpen(req, open))
+		require.NotNil(t, ctx.Document(uri))
+		assert.False(t, ctx.LoadTokensFromDocumentContentCalled, "scratch buffers never load tokens")
+
+		require.NoError(t, DidClose(req, closeParams))
+		assert.Nil(t, ctx.Document(uri))
+	})
+}
@@ -8,10 +8,10 @@ import (
 
 	"bennypowers.dev/dtls/internal/documents"
 	"bennypowers.dev/dtls/internal/parser"
-	"bennypowers.dev/dtls/internal/parser/css"
+	"bennypowers.dev/dtls/internal/parser/tokenfile"
 	"bennypowers.dev/dtls/internal/tokens"
+	csshelpers "bennypowers.dev/dtls/lsp/helpers/css"
 	"bennypowers.dev/dtls/lsp/types"
-	"github.com/tidwall/jsonc"
 	protocol "github.com/tliron/glsp/protocol_3_16"
 	"gopkg.in/yaml.v3"
 )
@@ -25,42 +25,26 @@ func handleCSSReferences(req *types.RequestContext, doc *documents.Document, pos
 	if result == nil {
 		return nil, nil
 	}
+	req.TraceVarCalls(len(result.VarCalls))
 
-	for _, varCall := range result.VarCalls {
-		if isPositionInVarCall(position, varCall) {
-			token := req.Server.Token(varCall.TokenName)
-			if token == nil {
-				return nil, nil
-			}
-
-			if token.DefinitionURI != "" {
-				location := protocol.Location{
-					URI: token.DefinitionURI,
-					Range: protocol.Range{
-						Start: protocol.Position{Line: token.Line, Character: token.Character},
-						End:   protocol.Position{Line: token.Line, Character: token.Character},
-					},
-				}
-				return []protocol.Location{location}, nil
-			}
-			return nil, nil
-		}
+	varCall := csshelpers.VarCallAt(position, result.VarCalls)
+	if varCall == nil {
+		return nil, nil
 	}
-	return nil, nil
-}
 
-// isPositionInVarCall checks if a position is within a var() call
-func isPositionInVarCall(pos protocol.Position, varCall *css.VarCall) bool {
-	if pos.Line < varCall.Range.Start.Line || pos.Line > varCall.Range.End.Line {
-		return false
-	}
-	if pos.Line == varCall.Range.Start.Line && pos.Character < varCall.Range.Start.Character {
-		return false
+	token := req.Server.Token(varCall.TokenName)
+	if token == nil || token.DefinitionURI == "" {
+		return nil, nil
 	}
-	if pos.Line == varCall.Range.End.Line && pos.Character >= varCall.Range.End.Character {
-		return false
+
+	location := protocol.Location{
+		URI: token.DefinitionURI,
+		Range: protocol.Range{
+			Start: protocol.Position{Line: token.Line, Character: token.Character},
+			End:   protocol.Position{Line: token.Line, Character: token.Character},
+		},
 	}
-	return true
+	return []protocol.Location{location}, nil
 }
 
 // validateTokenContext validates the basic request context and extracts the token at cursor.
@@ -71,6 +55,7 @@ func validateTokenContext(req *types.RequestContext, uri protocol.DocumentUri, p
 	if doc == nil {
 		return nil, ""
 	}
+	req.TraceDocument(doc)
 
 	// Only process files that should be treated as token files
 	if !req.Server.ShouldProcessAsTokenFile(string(uri)) {
@@ -113,50 +98,29 @@ func isValidCSSReference(content string, endPos protocol.Position) bool {
 	return false
 }
 
-// findCSSReferences finds all CSS var() references to a token across documents.
-// Adds valid references to the locationMap for deduplication.
-func findCSSReferences(docs []*documents.Document, cssVarName string, locationMap map[string]protocol.Location) {
-	for _, document := range docs {
-		if !parser.IsCSSSupportedLanguage(document.LanguageID()) {
-			continue
-		}
-
-		docContent := document.Content()
-		docURI := document.URI()
-		ranges := findSubstringRanges(docContent, cssVarName)
-
-		for _, r := range ranges {
-			if isValidCSSReference(docContent, r.End) {
-				loc := protocol.Location{URI: docURI, Range: r}
-				key := fmt.Sprintf("%s:%d:%d", docURI, r.Start.Line, r.Start.Character)
-				locationMap[key] = loc
-			}
+// findCSSReferences finds the var() references to a token in a CSS or
+// CSS-embedding document
+func findCSSReferences(document *documents.Document, cssVarName string) []protocol.Location {
+	var locations []protocol.Location
+	docContent := document.Content()
+	for _, r := range findSubstringRanges(docContent, cssVarName) {
+		if isValidCSSReference(docContent, r.End) {
+			locations = append(locations, protocol.Location{URI: document.URI(), Range: r})
 		}
 	}
+	return locations
 }
 
-// findJSONReferences finds all JSON/YAML token references across documents.
-// Adds references to the locationMap for deduplication.
-func findJSONReferences(docs []*documents.Document, tokenReference string, locationMap map[string]protocol.Location) {
+// findJSONReferences finds the references to a token in a JSON or YAML document
+func findJSONReferences(document *documents.Document, tokenReference string) []protocol.Location {
 	if tokenReference == "" {
-		return
+		return nil
 	}
-
-	for _, document := range docs {
-		if parser.IsCSSSupportedLanguage(document.LanguageID()) {
-			continue
-		}
-
-		docContent := document.Content()
-		docURI := document.URI()
-		ranges := findSubstringRanges(docContent, tokenReference)
-
-		for _, r := range ranges {
-			loc := protocol.Location{URI: docURI, Range: r}
-			key := fmt.Sprintf("%s:%d:%d", docURI, r.Start.Line, r.Start.Character)
-			locationMap[key] = loc
-		}
+	var locations []protocol.Location
+	for _, r := range findSubstringRanges(document.Content(), tokenReference) {
+		locations = append(locations, protocol.Location{URI: document.URI(), Range: r})
 	}
+	return locations
 }
 
 // addDeclarationIfRequested adds the token declaration location if requested.
@@ -181,7 +145,8 @@ func addDeclarationIfRequested(req *types.RequestContext, params *protocol.Refer
 
 // References returns all references to a token
 // For CSS files: returns the token definition location
-// For JSON/YAML files: finds all references to the token at cursor
+// For JSON/YAML files: finds all references to the token at cursor, in open
+// documents and in the workspace files the server has indexed
 func References(req *types.RequestContext, params *protocol.ReferenceParams) ([]protocol.Location, error) {
 	uri := params.TextDocument.URI
 	position := params.Position
@@ -193,6 +158,7 @@ func References(req *types.RequestContext, params *protocol.ReferenceParams) ([]
 	if doc == nil {
 		return nil, nil
 	}
+	req.TraceDocument(doc)
 
 	// Handle CSS and CSS-embedded files - return token definition location
 	if parser.IsCSSSupportedLanguage(doc.LanguageID()) {
@@ -205,54 +171,58 @@ func References(req *types.RequestContext, params *protocol.ReferenceParams) ([]
 		return nil, nil
 	}
 
+	cssVarName := req.Server.TokenManager().CSSVariableName(token)
 	log.Info("Finding references for %s (CSS name: %s, reference: %s)",
-		tokenName, token.CSSVariableName(), token.Reference)
-
-	// Find all references across all documents
-	var locations []protocol.Location
-	cssVarName := token.CSSVariableName()
-	tokenReference := token.Reference
-
-	// Deduplicate locations using a map (JSON.stringify equivalent)
-	locationMap := make(map[string]protocol.Location)
-
-	// Find CSS var() references
-	findCSSReferences(req.Server.AllDocuments(), cssVarName, locationMap)
-
-	// Find JSON/YAML token references
-	findJSONReferences(req.Server.AllDocuments(), tokenReference, locationMap)
+		tokenName, cssVarName, token.Reference)
+
+	// Find all references across all documents, streaming each document's
+	// references when the client asked for partial results
+	locations := []protocol.Location{}
+	found := 0
+	open := map[string]bool{}
+	for _, document := range req.Server.AllDocuments() {
+		open[document.URI()] = true
+		var docLocations []protocol.Location
+		if parser.IsCSSSupportedLanguage(document.LanguageID()) {
+			docLocations = findCSSReferences(document, cssVarName)
+		} else {
+			docLocations = findJSONReferences(document, token.Reference)
+		}
+		if len(docLocations) == 0 {
+			continue
+		}
+		found += len(docLocations)
+		if !req.ReportPartialResult(params.PartialResultToken, docLocations) {
+			locations = append(locations, docLocations...)
+		}
+	}
 
-	// Convert map to slice
-	for _, loc := range locationMap {
-		locations = append(locations, loc)
+	// Files which aren't open are searched in the workspace index
+	var indexed []protocol.Location
+	for _, location := range req.Server.WorkspaceIndex().References(cssVarName) {
+		if !open[location.URI] {
+			indexed = append(indexed, location)
+		}
+	}
+	if len(indexed) > 0 {
+		found += len(indexed)
+		if !req.ReportPartialResult(params.PartialResultToken, indexed) {
+			locations = append(locations, indexed...)
+		}
 	}
 
 	// Include declaration if requested
 	addDeclarationIfRequested(req, params, token, &locations)
 
-	log.Info("Found %d references", len(locations))
+	log.Info("Found %d references", found)
 	return locations, nil
 }
 
 // findTokenAtPosition finds the token name at the given position in a JSON/YAML file
 // Uses yaml.v3 AST for robust parsing (YAML is a superset of JSON)
 func findTokenAtPosition(content string, pos protocol.Position, languageID string) string {
-	var data []byte
-
-	// For JSON/JSONC, strip comments first (preserves line numbers)
-	if languageID == "json" || languageID == "jsonc" {
-		data = jsonc.ToJSON([]byte(content))
-	} else {
-		data = []byte(content)
-	}
-
-	// Parse with yaml.v3 (works for both JSON and YAML)
-	var root yaml.Node
-	if err := yaml.Unmarshal(data, &root); err != nil {
-		return ""
-	}
-
-	if len(root.Content) == 0 {
+	root := tokenfile.Outline(content, languageID == "json" || languageID == "jsonc")
+	if root == nil {
 		return ""
 	}
 
@@ -261,7 +231,7 @@ func findTokenAtPosition(content string, pos protocol.Position, languageID strin
 	yamlCol := int(pos.Character) + 1
 
 	// Find the path at the cursor position
-	path := findPathAtPosition(root.Content[0], yamlLine, yamlCol, nil)
+	path := findPathAtPosition(root, yamlLine, yamlCol, nil)
 	if len(path) > 0 {
 		return strings.Join(path, "-")
 	}
@@ -332,9 +302,9 @@ func findSubstringRanges(content, substring string) []protocol.Range {
 			}
 
 			// Convert to uint32 after validation (gosec doesn't recognize validation above)
-			lineU32 := uint32(lineNum)           //nolint:gosec // G115: validated above
-			actualIdxU32 := uint32(actualIdx)    //nolint:gosec // G115: validated above
-			endIdxU32 := uint32(endIdx)          //nolint:gosec // G115: validated above
+			lineU32 := uint32(lineNum)        //nolint:gosec // G115: validated above
+			actualIdxU32 := uint32(actualIdx) //nolint:gosec // G115: validated above
+			endIdxU32 := uint32(endIdx)       //nolint:gosec // G115: validated above
 
 			ranges = append(ranges, protocol.Range{
 				Start: protocol.Position{
@@ -380,9 +350,9 @@ func findTokenDefinitionRange(content string, path []string, languageID string)
 				}
 
 				// Convert to uint32 after validation (gosec doesn't recognize validation above)
-				lineU32 := uint32(lineNum)    //nolint:gosec // G115: validated above
-				idxU32 := uint32(idx)          //nolint:gosec // G115: validated above
-				endIdxU32 := uint32(endIdx)    //nolint:gosec // G115: validated above
+				lineU32 := uint32(lineNum)  //nolint:gosec // G115: validated above
+				idxU32 := uint32(idx)       //nolint:gosec // G115: validated above
+				endIdxU32 := uint32(endIdx) //nolint:gosec // G115: validated above
 
 				return protocol.Range{
 					Start: protocol.Position{
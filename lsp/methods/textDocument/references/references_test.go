@@ -1,9 +1,12 @@
 package references
 
 import (
+	"os"
+	"path/filepath"
 	"testing"
 
 	"bennypowers.dev/dtls/internal/tokens"
+	"bennypowers.dev/dtls/internal/uriutil"
 	"bennypowers.dev/dtls/lsp/testutil"
 	"bennypowers.dev/dtls/lsp/types"
 	"github.com/stretchr/testify/assert"
@@ -268,6 +271,119 @@ func TestReferences_JSONFile_FindsReferencesInCSS(t *testing.T) {
 	assert.True(t, foundInCSS2, "Should find var() reference in styles2.css")
 }
 
+// TestReferences_JSONFile_FindsReferencesInWorkspaceFiles tests that
+// references are found in indexed workspace files which were never opened
+func TestReferences_JSONFile_FindsReferencesInWorkspaceFiles(t *testing.T) {
+	root := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+		return path
+	}
+	write(".gitignore", "dist/\n")
+	closed := write("styles/button.css", ".button { color: var(--color-primary); }")
+	opened := write("styles/link.css", ".link { color: var(--color-primary); }")
+	write("dist/bundle.css", ".button { color: var(--color-primary); }")
+
+	ctx := testutil.NewMockServerContext()
+	ctx.SetRootPath(root)
+	require.NoError(t, ctx.WorkspaceIndex().Scan(root))
+	req := types.NewRequestContext(ctx, &glsp.Context{})
+
+	_ = ctx.TokenManager().Add(&tokens.Token{
+		Name:          "color-primary",
+		Value:         "#ff0000",
+		Type:          "color",
+		Path:          []string{"color", "primary"},
+		Reference:     "{color.primary}",
+		DefinitionURI: "file:///tokens.json",
+	})
+	jsonURI := "file:///tokens.json"
+	_ = ctx.DocumentManager().DidOpen(jsonURI, "json", 1, `{
+  "color": {
+    "primary": {
+      "$type": "color",
+      "$value": "#ff0000"
+    }
+  }
+}`)
+
+	// The open document's unsaved content wins over the file on disk
+	openedURI := uriutil.PathToURI(opened)
+	_ = ctx.DocumentManager().DidOpen(openedURI, "css", 2, "\n.link { color: var(--color-primary); }")
+
+	result, err := References(req, &protocol.ReferenceParams{
+		TextDocumentPositionParams: protocol.TextDocumentPositionParams{
+			TextDocument: protocol.TextDocumentIdentifier{URI: jsonURI},
+			Position:     protocol.Position{Line: 2, Character: 6},
+		},
+	})
+	require.NoError(t, err)
+
+	assert.ElementsMatch(t, []protocol.Location{
+		{URI: openedURI, Range: protocol.Range{
+			Start: protocol.Position{Line: 1, Character: 19},
+			End:   protocol.Position{Line: 1, Character: 34},
+		}},
+		{URI: uriutil.PathToURI(closed), Range: protocol.Range{
+			Start: protocol.Position{Line: 0, Character: 21},
+			End:   protocol.Position{Line: 0, Character: 36},
+		}},
+	}, result, "ignored files are not indexed")
+}
+
+// TestReferences_PartialResults tests that each document's references are
+// streamed with $/progress when the client passes a partialResultToken
+func TestReferences_PartialResults(t *testing.T) {
+	ctx := testutil.NewMockServerContext()
+	var batches []protocol.ProgressParams
+	req := types.NewRequestContext(ctx, &glsp.Context{
+		Notify: func(method string, params any) {
+			require.Equal(t, protocol.MethodProgress, method)
+			batches = append(batches, params.(protocol.ProgressParams))
+		},
+	})
+
+	_ = ctx.TokenManager().Add(&tokens.Token{
+		Name:          "color-primary",
+		Path:          []string{"color", "primary"},
+		Reference:     "{color.primary}",
+		DefinitionURI: "file:///tokens.json",
+	})
+	jsonURI := "file:///tokens.json"
+	_ = ctx.DocumentManager().DidOpen(jsonURI, "json", 1, `{
+  "color": {
+    "primary": {"$type": "color", "$value": "#ff0000"}
+  }
+}`)
+	_ = ctx.DocumentManager().DidOpen("file:///a.css", "css", 1, `.a { color: var(--color-primary); border-color: var(--color-primary); }`)
+	_ = ctx.DocumentManager().DidOpen("file:///b.css", "css", 1, `.b { color: var(--color-primary); }`)
+
+	token := protocol.ProgressToken{Value: "refs-1"}
+	result, err := References(req, &protocol.ReferenceParams{
+		TextDocumentPositionParams: protocol.TextDocumentPositionParams{
+			TextDocument: protocol.TextDocumentIdentifier{URI: jsonURI},
+			Position:     protocol.Position{Line: 2, Character: 6},
+		},
+		PartialResultParams: protocol.PartialResultParams{PartialResultToken: &token},
+		Context:             protocol.ReferenceContext{IncludeDeclaration: true},
+	})
+	require.NoError(t, err)
+
+	require.Len(t, batches, 2, "one batch per document with references")
+	sizes := map[protocol.DocumentUri]int{}
+	for _, batch := range batches {
+		assert.Equal(t, token, batch.Token)
+		locations := batch.Value.([]protocol.Location)
+		sizes[locations[0].URI] = len(locations)
+	}
+	assert.Equal(t, map[protocol.DocumentUri]int{"file:///a.css": 2, "file:///b.css": 1}, sizes)
+
+	require.Len(t, result, 1, "the final response holds only what wasn't streamed")
+	assert.Equal(t, jsonURI, result[0].URI)
+}
+
 // TestReferences_JSONFile_FindsReferencesInJSON tests finding token references in other JSON files
 func TestReferences_JSONFile_FindsReferencesInJSON(t *testing.T) {
 	ctx := testutil.NewMockServerContext()
@@ -0,0 +1,345 @@
+package rename
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"bennypowers.dev/dtls/internal/log"
+	"bennypowers.dev/dtls/internal/parser"
+	"bennypowers.dev/dtls/internal/parser/css"
+	"bennypowers.dev/dtls/internal/parser/tokenfile"
+	"bennypowers.dev/dtls/internal/position"
+	"bennypowers.dev/dtls/internal/uriutil"
+	"bennypowers.dev/dtls/lsp/helpers"
+	"bennypowers.dev/dtls/lsp/types"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+	"gopkg.in/yaml.v3"
+)
+
+// tokenKey is a group or token key in a token file
+type tokenKey struct {
+	// Path is the key's path from the root, ending in the key itself
+	Path []string
+
+	// Range covers the key's name, without quotes
+	Range protocol.Range
+
+	// siblings are the other keys of the containing object
+	siblings []string
+}
+
+// PrepareRename handles the textDocument/prepareRename request, reporting the
+// group or token key under the cursor in a token file
+func PrepareRename(req *types.RequestContext, params *protocol.PrepareRenameParams) (any, error) {
+	key := keyAtPosition(req, params.TextDocument.URI, params.Position)
+	if key == nil {
+		return nil, nil
+	}
+	return &protocol.RangeWithPlaceholder{Range: key.Range, Placeholder: key.Path[len(key.Path)-1]}, nil
+}
+
+// Rename handles the textDocument/rename request on a group or token key in
+// a token file. Renaming a group renames every token below it, so the edit
+// rewrites the key, alias references to the renamed tokens in the loaded token
+// files, and uses of their CSS variables in open documents and the
+// workspace's other CSS, HTML, and JavaScript files.
+func Rename(req *types.RequestContext, params *protocol.RenameParams) (*protocol.WorkspaceEdit, error) {
+	uri := params.TextDocument.URI
+	log.Info("Rename requested: %s at line %d, char %d", uri, params.Position.Line, params.Position.Character)
+
+	key := keyAtPosition(req, uri, params.Position)
+	if key == nil {
+		return nil, nil
+	}
+
+	oldName := key.Path[len(key.Path)-1]
+	newName := params.NewName
+	if err := validateKeyName(newName); err != nil {
+		return nil, err
+	}
+	if newName == oldName {
+		return nil, nil
+	}
+	if slices.Contains(key.siblings, newName) {
+		return nil, fmt.Errorf("%s already exists", strings.Join(append(slices.Clone(key.Path[:len(key.Path)-1]), newName), "."))
+	}
+
+	newPath := append(slices.Clone(key.Path[:len(key.Path)-1]), newName)
+	changes := map[protocol.DocumentUri][]protocol.TextEdit{
+		uri: {{Range: key.Range, NewText: newName}},
+	}
+
+	addReferenceEdits(req, uri, key.Path, newPath, changes)
+	addCSSEdits(req, uriutil.URIToPath(uri), key.Path, newPath, changes)
+
+	for editURI, edits := range changes {
+		prepared, err := helpers.PrepareEdits(edits)
+		if err != nil {
+			return nil, fmt.Errorf("cannot rename %s in %s: %w", oldName, editURI, err)
+		}
+		changes[editURI] = prepared
+	}
+	return &protocol.WorkspaceEdit{Changes: changes}, nil
+}
+
+// validateKeyName rejects names which can't be a DTCG group or token name
+func validateKeyName(name string) error {
+	switch {
+	case name == "":
+		return fmt.Errorf("token names cannot be empty")
+	case strings.HasPrefix(name, "$"):
+		return fmt.Errorf("token names cannot start with $: %s", name)
+	case strings.ContainsAny(name, ".{}"):
+		return fmt.Errorf("token names cannot contain '.', '{' or '}': %s", name)
+	}
+	return nil
+}
+
+// keyAtPosition returns the group or token key at pos in a token file, or nil
+func keyAtPosition(req *types.RequestContext, uri string, pos protocol.Position) *tokenKey {
+	doc := req.Server.Document(uri)
+	if doc == nil || !req.Server.ShouldProcessAsTokenFile(uri) {
+		return nil
+	}
+
+	languageID := doc.LanguageID()
+	isJSON := languageID == "json" || languageID == "jsonc" || strings.HasSuffix(uri, ".json")
+	root := tokenfile.Outline(doc.Content(), isJSON)
+	if root == nil {
+		return nil
+	}
+	return findKey(doc.Content(), root, pos, nil)
+}
+
+// findKey searches a mapping node for the key at pos
+func findKey(content string, node *yaml.Node, pos protocol.Position, path []string) *tokenKey {
+	if node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		keyNode, valueNode := node.Content[i], node.Content[i+1]
+
+		// $-prefixed keys are DTCG properties, not tokens
+		if strings.HasPrefix(keyNode.Value, "$") {
+			continue
+		}
+
+		keyPath := append(slices.Clone(path), keyNode.Value)
+		if r, ok := keyRange(content, keyNode); ok && helpers.PositionInRange(pos, r) {
+			var siblings []string
+			for j := 0; j+1 < len(node.Content); j += 2 {
+				if j != i {
+					siblings = append(siblings, node.Content[j].Value)
+				}
+			}
+			return &tokenKey{Path: keyPath, Range: r, siblings: siblings}
+		}
+
+		if found := findKey(content, valueNode, pos, keyPath); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+// keyRange returns the range of a key's name. yaml.v3 columns count runes
+// from 1 and point at the opening quote of quoted keys.
+func keyRange(content string, keyNode *yaml.Node) (protocol.Range, bool) {
+	lines := strings.Split(content, "\n")
+	if keyNode.Line < 1 || keyNode.Line > len(lines) {
+		return protocol.Range{}, false
+	}
+	line := []rune(lines[keyNode.Line-1])
+	start := keyNode.Column - 1
+	if keyNode.Style&(yaml.DoubleQuotedStyle|yaml.SingleQuotedStyle) != 0 {
+		start++
+	}
+	if start < 0 || start > len(line) {
+		return protocol.Range{}, false
+	}
+
+	lineNum := uint32(keyNode.Line - 1) //nolint:gosec // G115: yaml line numbers are bounded by file size
+	startChar := position.StringLengthUTF16Uint32(string(line[:start]))
+	return protocol.Range{
+		Start: protocol.Position{Line: lineNum, Character: startChar},
+		End:   protocol.Position{Line: lineNum, Character: startChar + position.StringLengthUTF16Uint32(keyNode.Value)},
+	}, true
+}
+
+// addReferenceEdits rewrites alias references ({a.b.c}) and JSON pointer
+// references (#/a/b/c) to the renamed tokens in every loaded token file and
+// the renamed file itself
+func addReferenceEdits(req *types.RequestContext, uri string, oldPath, newPath []string, changes map[protocol.DocumentUri][]protocol.TextEdit) {
+	files := req.Server.TokenManager().GetSourceFiles()
+	if path := uriutil.URIToPath(uri); !slices.Contains(files, path) {
+		files = append(files, path)
+	}
+	slices.Sort(files)
+
+	patterns := []struct {
+		old, new   string
+		terminator string
+	}{
+		{"{" + strings.Join(oldPath, "."), "{" + strings.Join(newPath, "."), ".}"},
+		{"#/" + strings.Join(oldPath, "/"), "#/" + strings.Join(newPath, "/"), `/"'`},
+	}
+
+	for _, file := range files {
+		switch filepath.Ext(file) {
+		case ".json", ".jsonc", ".yaml", ".yml":
+		default:
+			continue
+		}
+		fileURI := uriutil.PathToURI(file)
+		content, err := fileContent(req, fileURI, file)
+		if err != nil {
+			log.Warn("Cannot read token file %s: %v", file, err)
+			continue
+		}
+
+		for _, p := range patterns {
+			for offset := 0; ; {
+				idx := strings.Index(content[offset:], p.old)
+				if idx < 0 {
+					break
+				}
+				start := offset + idx
+				end := start + len(p.old)
+				offset = end
+				if end >= len(content) || !strings.ContainsRune(p.terminator, rune(content[end])) {
+					continue
+				}
+				changes[fileURI] = append(changes[fileURI], protocol.TextEdit{
+					Range:   protocol.Range{Start: helpers.OffsetToPosition(content, start), End: helpers.OffsetToPosition(content, end)},
+					NewText: p.new,
+				})
+			}
+		}
+	}
+}
+
+// cssSource is a CSS-supported document whose uses of renamed tokens are edited
+type cssSource struct {
+	uri, languageID, content string
+}
+
+// cssSources returns the open CSS-supported documents, then the other files
+// of the workspace index. The index only lists the files: their content is
+// read from disk now, so edits apply to it.
+func cssSources(req *types.RequestContext) []cssSource {
+	var sources []cssSource
+	open := map[string]bool{}
+	for _, doc := range req.Server.AllDocuments() {
+		open[doc.URI()] = true
+		if parser.IsCSSSupportedLanguage(doc.LanguageID()) {
+			sources = append(sources, cssSource{uri: doc.URI(), languageID: doc.LanguageID(), content: doc.Content()})
+		}
+	}
+
+	for _, path := range req.Server.WorkspaceIndex().Paths() {
+		uri := uriutil.PathToURI(path)
+		if open[uri] {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Clean(path))
+		if err != nil {
+			log.Warn("Cannot read %s: %v", path, err)
+			continue
+		}
+		sources = append(sources, cssSource{uri: uri, languageID: parser.LanguageIDForPath(path), content: string(data)})
+	}
+	return sources
+}
+
+// addCSSEdits renames the CSS variables of the renamed tokens wherever
+// documents and workspace files use or declare them
+func addCSSEdits(req *types.RequestContext, filePath string, oldPath, newPath []string, changes map[protocol.DocumentUri][]protocol.TextEdit) {
+	manager := req.Server.TokenManager()
+	format := manager.NameFormat()
+
+	renamed := map[string]string{}
+	for _, token := range manager.GetBySourceFile(filePath) {
+		if len(token.Path) < len(oldPath) || !slices.Equal(token.Path[:len(oldPath)], oldPath) {
+			continue
+		}
+		oldGroup := format.PathName(token.Prefix, oldPath)
+		oldName := manager.CSSVariableName(token)
+		if !strings.HasPrefix(oldName, oldGroup) {
+			continue
+		}
+		renamed[oldName] = format.PathName(token.Prefix, newPath) + oldName[len(oldGroup):]
+	}
+	if len(renamed) == 0 {
+		return
+	}
+
+	for _, source := range cssSources(req) {
+		// Skip files without the renamed names before parsing them
+		if !containsAny(source.content, renamed) {
+			continue
+		}
+		result, err := parser.ParseCSSFromDocument(source.content, source.languageID)
+		if err != nil || result == nil {
+			continue
+		}
+
+		content := source.content
+		rename := func(name string, within css.Range) {
+			newName, ok := renamed[name]
+			if !ok {
+				return
+			}
+			if r, found := nameRange(content, name, within); found {
+				changes[source.uri] = append(changes[source.uri], protocol.TextEdit{Range: r, NewText: newName})
+			}
+		}
+		for _, call := range result.VarCalls {
+			// SCSS aliases are renamed where the variable is declared
+			if call.Alias == "" {
+				rename(call.TokenName, call.Range)
+			}
+		}
+		for _, variable := range result.Variables {
+			rename(variable.Name, variable.Range)
+		}
+	}
+}
+
+// containsAny reports whether content contains any of the names
+func containsAny(content string, names map[string]string) bool {
+	for name := range names {
+		if strings.Contains(content, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// nameRange finds the first occurrence of name within a parsed range
+func nameRange(content, name string, within css.Range) (protocol.Range, bool) {
+	start := helpers.PositionToOffset(content, protocol.Position{Line: within.Start.Line, Character: within.Start.Character})
+	end := helpers.PositionToOffset(content, protocol.Position{Line: within.End.Line, Character: within.End.Character})
+	idx := strings.Index(content[start:end], name)
+	if idx < 0 {
+		return protocol.Range{}, false
+	}
+	return protocol.Range{
+		Start: helpers.OffsetToPosition(content, start+idx),
+		End:   helpers.OffsetToPosition(content, start+idx+len(name)),
+	}, true
+}
+
+// fileContent returns the open document's content, or the file on disk
+func fileContent(req *types.RequestContext, uri, path string) (string, error) {
+	if doc := req.Server.Document(uri); doc != nil {
+		return doc.Content(), nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
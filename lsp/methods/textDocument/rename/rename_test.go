@@ -0,0 +1,216 @@
+package rename
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"bennypowers.dev/dtls/internal/tokens"
+	"bennypowers.dev/dtls/internal/uriutil"
+	"bennypowers.dev/dtls/lsp/helpers"
+	"bennypowers.dev/dtls/lsp/testutil"
+	"bennypowers.dev/dtls/lsp/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tliron/glsp"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+const tokensURI = "file:///tokens.json"
+
+const tokensJSON = `{
+  "brand": {
+    "base": { "$type": "color", "$value": "#ff0000" },
+    "accent": { "$type": "color", "$value": "{brand.base}" }
+  },
+  "surface": {
+    "$type": "color",
+    "$value": { "$ref": "#/brand/base/$value" }
+  },
+  "space": { "$type": "dimension", "$value": "4px" }
+}`
+
+func setupRename(t *testing.T) (*testutil.MockServerContext, *types.RequestContext) {
+	t.Helper()
+	ctx := testutil.NewMockServerContext()
+	req := types.NewRequestContext(ctx, &glsp.Context{})
+
+	for _, token := range []*tokens.Token{
+		{Name: "brand-base", Path: []string{"brand", "base"}, Value: "#ff0000", FilePath: "/tokens.json", DefinitionURI: tokensURI},
+		{Name: "brand-accent", Path: []string{"brand", "accent"}, Value: "{brand.base}", FilePath: "/tokens.json", DefinitionURI: tokensURI},
+		{Name: "space", Path: []string{"space"}, Value: "4px", FilePath: "/tokens.json", DefinitionURI: tokensURI},
+	} {
+		require.NoError(t, ctx.TokenManager().Add(token))
+	}
+	require.NoError(t, ctx.DocumentManager().DidOpen(tokensURI, "json", 1, tokensJSON))
+	return ctx, req
+}
+
+func renameParams(uri string, pos protocol.Position, newName string) *protocol.RenameParams {
+	return &protocol.RenameParams{
+		TextDocumentPositionParams: protocol.TextDocumentPositionParams{
+			TextDocument: protocol.TextDocumentIdentifier{URI: uri},
+			Position:     pos,
+		},
+		NewName: newName,
+	}
+}
+
+func TestRename_Group(t *testing.T) {
+	ctx, req := setupRename(t)
+
+	cssURI := "file:///styles.css"
+	css := `:root { --brand-base: red; }
+.a { color: var(--brand-accent, var(--brand-base)); margin: var(--space); }`
+	require.NoError(t, ctx.DocumentManager().DidOpen(cssURI, "css", 1, css))
+
+	edit, err := Rename(req, renameParams(tokensURI, protocol.Position{Line: 1, Character: 4}, "primary"))
+	require.NoError(t, err)
+	require.NotNil(t, edit)
+
+	jsonResult, err := helpers.ApplyEdits(tokensJSON, edit.Changes[tokensURI])
+	require.NoError(t, err)
+	assert.Equal(t, `{
+  "primary": {
+    "base": { "$type": "color", "$value": "#ff0000" },
+    "accent": { "$type": "color", "$value": "{primary.base}" }
+  },
+  "surface": {
+    "$type": "color",
+    "$value": { "$ref": "#/primary/base/$value" }
+  },
+  "space": { "$type": "dimension", "$value": "4px" }
+}`, jsonResult)
+
+	cssResult, err := helpers.ApplyEdits(css, edit.Changes[cssURI])
+	require.NoError(t, err)
+	assert.Equal(t, `:root { --primary-base: red; }
+.a { color: var(--primary-accent, var(--primary-base)); margin: var(--space); }`, cssResult)
+}
+
+func TestRename_OtherTokenFiles(t *testing.T) {
+	ctx, req := setupRename(t)
+
+	other := `{ "x": { "$value": "{brand.baseline}" }, "y": { "$value": "{brandy.base}" } }`
+	require.NoError(t, ctx.DocumentManager().DidOpen("file:///other.json", "json", 1, other))
+	require.NoError(t, ctx.TokenManager().Add(&tokens.Token{Name: "x", Path: []string{"x"}, FilePath: "/other.json"}))
+
+	edit, err := Rename(req, renameParams(tokensURI, protocol.Position{Line: 1, Character: 4}, "primary"))
+	require.NoError(t, err)
+	require.NotNil(t, edit)
+
+	// brandy is a different group
+	result, err := helpers.ApplyEdits(other, edit.Changes["file:///other.json"])
+	require.NoError(t, err)
+	assert.Equal(t, `{ "x": { "$value": "{primary.baseline}" }, "y": { "$value": "{brandy.base}" } }`, result)
+}
+
+func TestRename_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		newName string
+	}{
+		{"existing sibling", "space"},
+		{"dotted", "brand.new"},
+		{"curly", "{brand}"},
+		{"dollar", "$brand"},
+		{"empty", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, req := setupRename(t)
+			_, err := Rename(req, renameParams(tokensURI, protocol.Position{Line: 1, Character: 4}, tt.newName))
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestRename_NotOnKey(t *testing.T) {
+	_, req := setupRename(t)
+
+	// On a $value
+	edit, err := Rename(req, renameParams(tokensURI, protocol.Position{Line: 2, Character: 40}, "primary"))
+	require.NoError(t, err)
+	assert.Nil(t, edit)
+
+	// On a $-property key
+	edit, err = Rename(req, renameParams(tokensURI, protocol.Position{Line: 6, Character: 6}, "primary"))
+	require.NoError(t, err)
+	assert.Nil(t, edit)
+}
+
+func TestPrepareRename(t *testing.T) {
+	_, req := setupRename(t)
+
+	result, err := PrepareRename(req, &protocol.PrepareRenameParams{
+		TextDocumentPositionParams: protocol.TextDocumentPositionParams{
+			TextDocument: protocol.TextDocumentIdentifier{URI: tokensURI},
+			Position:     protocol.Position{Line: 2, Character: 7},
+		},
+	})
+	require.NoError(t, err)
+	require.IsType(t, &protocol.RangeWithPlaceholder{}, result)
+	placeholder := result.(*protocol.RangeWithPlaceholder)
+	assert.Equal(t, "base", placeholder.Placeholder)
+	assert.Equal(t, protocol.Range{
+		Start: protocol.Position{Line: 2, Character: 5},
+		End:   protocol.Position{Line: 2, Character: 9},
+	}, placeholder.Range)
+
+	result, err = PrepareRename(req, &protocol.PrepareRenameParams{
+		TextDocumentPositionParams: protocol.TextDocumentPositionParams{
+			TextDocument: protocol.TextDocumentIdentifier{URI: tokensURI},
+			Position:     protocol.Position{Line: 0, Character: 0},
+		},
+	})
+	require.NoError(t, err)
+	assert.Nil(t, result)
+}
+
+func TestRename_WorkspaceFiles(t *testing.T) {
+	ctx, req := setupRename(t)
+	root := t.TempDir()
+	ctx.SetRootPath(root)
+
+	write := func(rel, content string) string {
+		path := filepath.Join(root, rel)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+		return uriutil.PathToURI(path)
+	}
+	closedCSS := write("src/button.css", ".a { margin: var(--space); }")
+	closedJS := write("src/card.ts", "const styles = css`.b { padding: var(--space); }`;")
+	unrelated := write("src/other.css", ".c { color: red; }")
+	vendored := write("node_modules/pkg/index.css", ".d { margin: var(--space); }")
+	hidden := write(".cache/index.css", ".e { margin: var(--space); }")
+
+	// Open documents are edited as open, not as saved
+	openCSS := write("src/open.css", ".f { margin: 0; }")
+	require.NoError(t, ctx.DocumentManager().DidOpen(openCSS, "css", 1, ".f { margin: var(--space); }"))
+
+	// The files are found through the workspace index
+	require.NoError(t, ctx.WorkspaceIndex().Scan(root))
+
+	edit, err := Rename(req, renameParams(tokensURI, protocol.Position{Line: 9, Character: 4}, "gap"))
+	require.NoError(t, err)
+	require.NotNil(t, edit)
+
+	for uri, expected := range map[string]string{
+		closedCSS: ".a { margin: var(--gap); }",
+		closedJS:  "const styles = css`.b { padding: var(--gap); }`;",
+	} {
+		content, err := os.ReadFile(uriutil.URIToPath(uri))
+		require.NoError(t, err)
+		result, err := helpers.ApplyEdits(string(content), edit.Changes[uri])
+		require.NoError(t, err)
+		assert.Equal(t, expected, result, uri)
+	}
+
+	result, err := helpers.ApplyEdits(".f { margin: var(--space); }", edit.Changes[openCSS])
+	require.NoError(t, err)
+	assert.Equal(t, ".f { margin: var(--gap); }", result)
+
+	for _, uri := range []string{unrelated, vendored, hidden} {
+		assert.NotContains(t, edit.Changes, uri)
+	}
+}
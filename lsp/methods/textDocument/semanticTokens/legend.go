@@ -0,0 +1,90 @@
+package semantictokens
+
+import (
+	"slices"
+	"strings"
+
+	"bennypowers.dev/dtls/internal/tokens"
+	"bennypowers.dev/dtls/lsp/types"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+// baseTokenTypes are the standard token types, at the indices of the
+// TokenType constants
+var baseTokenTypes = []string{"class", "property", "variable", "keyword", "string"}
+
+// Token modifiers of design token references
+const (
+	// ModifierDeprecated marks references to deprecated tokens
+	ModifierDeprecated = "deprecated"
+	// ModifierAlias marks references to tokens whose value is a reference
+	ModifierAlias = "alias"
+	// ModifierUnknown marks references to tokens that aren't loaded
+	ModifierUnknown = "unknown"
+)
+
+// DesignTokenType returns the semantic token type of tokens with a $type,
+// e.g. "colorToken" for color tokens
+func DesignTokenType(tokenType string) string {
+	return tokenType + "Token"
+}
+
+// BaseLegend returns the legend of the standard token types, without
+// modifiers. References are highlighted with it when no legend was
+// negotiated.
+func BaseLegend() types.SemanticTokensLegend {
+	return types.SemanticTokensLegend{
+		TokenTypes:     slices.Clone(baseTokenTypes),
+		TokenModifiers: []string{},
+	}
+}
+
+// NegotiateLegend builds the legend declared in initialize. It is a snapshot:
+// besides the standard types, it declares a type for each DTCG type and for
+// each $type of the loaded tokens, so editors can theme them apart, and the
+// token modifiers. Tokens of types loaded later are highlighted as properties.
+//
+// Clients without semantic tokens capabilities get the base legend. The
+// standard deprecated modifier is only declared to clients that list it,
+// when they list any.
+func NegotiateLegend(manager *tokens.Manager, caps *protocol.SemanticTokensClientCapabilities) types.SemanticTokensLegend {
+	legend := BaseLegend()
+	if caps == nil {
+		return legend
+	}
+
+	// The DTCG types are declared before any tokens are loaded
+	designTypes := slices.Clone(tokens.DTCGTypes)
+	for _, token := range manager.GetAll() {
+		if token.Type != "" && !slices.Contains(designTypes, token.Type) {
+			designTypes = append(designTypes, token.Type)
+		}
+	}
+	slices.Sort(designTypes)
+	for _, tokenType := range designTypes {
+		legend.TokenTypes = append(legend.TokenTypes, DesignTokenType(tokenType))
+	}
+
+	if len(caps.TokenModifiers) == 0 || slices.Contains(caps.TokenModifiers, ModifierDeprecated) {
+		legend.TokenModifiers = append(legend.TokenModifiers, ModifierDeprecated)
+	}
+	legend.TokenModifiers = append(legend.TokenModifiers, ModifierAlias, ModifierUnknown)
+	return legend
+}
+
+// legendFor returns the legend to encode a server's semantic tokens with
+func legendFor(ctx types.ServerContext) types.SemanticTokensLegend {
+	if legend := ctx.SemanticTokensLegend(); len(legend.TokenTypes) > 0 {
+		return legend
+	}
+	return BaseLegend()
+}
+
+// isAlias reports whether a token's value is a reference to another token
+func isAlias(token *tokens.Token) bool {
+	if strings.HasPrefix(token.Reference, "#/") {
+		return true
+	}
+	raw, ok := token.RawValue.(string)
+	return ok && strings.HasPrefix(raw, "{") && strings.HasSuffix(raw, "}")
+}
@@ -0,0 +1,88 @@
+package semantictokens_test
+
+import (
+	"testing"
+
+	"bennypowers.dev/dtls/internal/documents"
+	"bennypowers.dev/dtls/internal/tokens"
+	semantictokens "bennypowers.dev/dtls/lsp/methods/textDocument/semanticTokens"
+	"bennypowers.dev/dtls/lsp/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+func TestNegotiateLegend(t *testing.T) {
+	manager := tokens.NewManager()
+	_ = manager.Add(&tokens.Token{Name: "space-fluid", Value: "clamp(1rem, 2vw, 2rem)", Type: "fluidSize"})
+
+	tests := []struct {
+		name      string
+		caps      *protocol.SemanticTokensClientCapabilities
+		types     []string
+		modifiers []string
+	}{
+		{
+			name:      "no semantic tokens capabilities",
+			modifiers: []string{},
+		},
+		{
+			name:      "client lists deprecated",
+			caps:      &protocol.SemanticTokensClientCapabilities{TokenModifiers: []string{"deprecated", "readonly"}},
+			types:     []string{"colorToken", "fluidSizeToken", "typographyToken"},
+			modifiers: []string{"deprecated", "alias", "unknown"},
+		},
+		{
+			name:      "client omits deprecated",
+			caps:      &protocol.SemanticTokensClientCapabilities{TokenModifiers: []string{"readonly"}},
+			types:     []string{"colorToken"},
+			modifiers: []string{"alias", "unknown"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			legend := semantictokens.NegotiateLegend(manager, tt.caps)
+			assert.Equal(t, semantictokens.BaseLegend().TokenTypes, legend.TokenTypes[:5], "standard types keep their indices")
+			for _, tokenType := range tt.types {
+				assert.Contains(t, legend.TokenTypes, tokenType)
+			}
+			if tt.caps == nil {
+				assert.Len(t, legend.TokenTypes, 5)
+			}
+			assert.Equal(t, tt.modifiers, legend.TokenModifiers)
+		})
+	}
+}
+
+func TestGetSemanticTokensForDocument_NegotiatedLegend(t *testing.T) {
+	ctx := testutil.NewMockServerContext()
+	_ = ctx.TokenManager().Add(&tokens.Token{Name: "color-brand-primary", Value: "#FF6B35", Type: "color"})
+	_ = ctx.TokenManager().Add(&tokens.Token{
+		Name: "color-brand-old", Value: "#FF6B35", RawValue: "{color.brand.primary}", Type: "color", Deprecated: true,
+	})
+	legend := semantictokens.NegotiateLegend(ctx.TokenManager(), &protocol.SemanticTokensClientCapabilities{})
+	ctx.SetSemanticTokensLegend(legend)
+
+	colorToken, ok := legend.TypeIndex("colorToken")
+	require.True(t, ok)
+	deprecatedAlias := legend.ModifierMask(semantictokens.ModifierDeprecated) | legend.ModifierMask(semantictokens.ModifierAlias)
+	unknown := legend.ModifierMask(semantictokens.ModifierUnknown)
+
+	doc := documents.NewDocument("file:///test.json", "json", 1, `{
+  "a": { "$value": "{color.brand.primary}" },
+  "b": { "$value": "{color.brand.old}" },
+  "c": { "$value": "{color.missing}" }
+}`)
+	result := semantictokens.GetSemanticTokensForDocument(ctx, doc)
+
+	assert.Equal(t, []semantictokens.SemanticTokenIntermediate{
+		{Line: 1, StartChar: 21, Length: 5, TokenType: semantictokens.TokenTypeVariable},
+		{Line: 1, StartChar: 27, Length: 5, TokenType: semantictokens.TokenTypeProperty},
+		{Line: 1, StartChar: 33, Length: 7, TokenType: colorToken},
+		{Line: 2, StartChar: 21, Length: 5, TokenType: semantictokens.TokenTypeVariable, TokenModifiers: deprecatedAlias},
+		{Line: 2, StartChar: 27, Length: 5, TokenType: semantictokens.TokenTypeProperty, TokenModifiers: deprecatedAlias},
+		{Line: 2, StartChar: 33, Length: 3, TokenType: colorToken, TokenModifiers: deprecatedAlias},
+		{Line: 3, StartChar: 21, Length: 5, TokenType: semantictokens.TokenTypeVariable, TokenModifiers: unknown},
+		{Line: 3, StartChar: 27, Length: 7, TokenType: semantictokens.TokenTypeProperty, TokenModifiers: unknown},
+	}, result)
+}
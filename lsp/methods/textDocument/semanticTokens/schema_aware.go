@@ -7,6 +7,7 @@ import (
 	"bennypowers.dev/dtls/internal/parser/common"
 	"bennypowers.dev/dtls/internal/position"
 	"bennypowers.dev/dtls/internal/schema"
+	"bennypowers.dev/dtls/internal/uriutil"
 	"bennypowers.dev/dtls/lsp/types"
 )
 
@@ -37,6 +38,16 @@ func detectSchemaVersion(content string) schema.SchemaVersion {
 	return schema.Draft
 }
 
+// documentSchemaVersion returns the schema version of a token file: the one
+// it was loaded as, when it is loaded, so configured versions apply, or else
+// the one its content declares
+func documentSchemaVersion(ctx types.ServerContext, doc *documents.Document) schema.SchemaVersion {
+	if version := ctx.TokenManager().GetSchemaVersionForFile(uriutil.URIToPath(doc.URI())); version != schema.Unknown {
+		return version
+	}
+	return detectSchemaVersion(doc.Content())
+}
+
 // extractJSONPointerTokens extracts semantic tokens for JSON Pointer references
 // Returns tokens for both the $ref keyword and the pointer path
 func extractJSONPointerTokens(line string, lineNum int) []SemanticTokenIntermediate {
@@ -117,14 +128,15 @@ func GetSemanticTokensForDocumentSchemaAware(ctx types.ServerContext, doc *docum
 	tokens := []SemanticTokenIntermediate{}
 
 	// Detect schema version
-	version := detectSchemaVersion(content)
+	version := documentSchemaVersion(ctx, doc)
 
 	// Split content into lines
 	lines := strings.Split(content, "\n")
 
+	legend := legendFor(ctx)
 	for lineNum, line := range lines {
 		// Extract curly brace references (both schemas)
-		curlyBraceTokens := extractCurlyBraceReferences(ctx, line, lineNum)
+		curlyBraceTokens := extractCurlyBraceReferences(ctx, legend, line, lineNum)
 		tokens = append(tokens, curlyBraceTokens...)
 
 		// Extract 2025.10-specific features
@@ -144,7 +156,9 @@ func GetSemanticTokensForDocumentSchemaAware(ctx types.ServerContext, doc *docum
 
 // extractCurlyBraceReferences extracts semantic tokens for curly brace references
 // This is the original logic extracted for reuse
-func extractCurlyBraceReferences(ctx types.ServerContext, line string, lineNum int) []SemanticTokenIntermediate {
+// The last part of a reference takes the type of the token's $type, and all
+// parts its modifiers, when the legend declares them.
+func extractCurlyBraceReferences(ctx types.ServerContext, legend types.SemanticTokensLegend, line string, lineNum int) []SemanticTokenIntermediate {
 	tokens := []SemanticTokenIntermediate{}
 
 	// Find all token references in this line
@@ -162,9 +176,26 @@ func extractCurlyBraceReferences(ctx types.ServerContext, line string, lineNum i
 		// Convert dots to dashes for token lookup (design tokens use dots, but we store as dashes)
 		tokenName := strings.ReplaceAll(reference, ".", "-")
 
-		// Check if this reference exists in our token manager
-		if ctx.Token(tokenName) == nil {
-			continue
+		// Check if this reference exists in our token manager. Unknown
+		// references are only highlighted for legends with the modifier.
+		token := ctx.Token(tokenName)
+		modifiers := 0
+		lastType := TokenTypeProperty
+		if token == nil {
+			modifiers = legend.ModifierMask(ModifierUnknown)
+			if modifiers == 0 {
+				continue
+			}
+		} else {
+			if token.Deprecated {
+				modifiers |= legend.ModifierMask(ModifierDeprecated)
+			}
+			if isAlias(token) {
+				modifiers |= legend.ModifierMask(ModifierAlias)
+			}
+			if i, ok := legend.TypeIndex(DesignTokenType(token.Type)); ok && token.Type != "" {
+				lastType = i
+			}
 		}
 
 		// Split reference into parts (e.g., "color.brand.primary" -> ["color", "brand", "primary"])
@@ -177,7 +208,10 @@ func extractCurlyBraceReferences(ctx types.ServerContext, line string, lineNum i
 
 		for i, part := range parts {
 			tokenType := TokenTypeProperty
-			if i == 0 {
+			switch {
+			case i == len(parts)-1 && lastType != TokenTypeProperty:
+				tokenType = lastType
+			case i == 0:
 				tokenType = TokenTypeVariable
 			}
 
@@ -186,7 +220,7 @@ func extractCurlyBraceReferences(ctx types.ServerContext, line string, lineNum i
 				StartChar:      partStartChar,
 				Length:         position.StringLengthUTF16(part),
 				TokenType:      tokenType,
-				TokenModifiers: 0,
+				TokenModifiers: modifiers,
 			})
 
 			// Move to the next part (add UTF-16 length of part + 1 for the dot)
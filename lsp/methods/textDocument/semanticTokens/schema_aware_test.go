@@ -156,3 +156,30 @@ func TestSemanticTokens_Draft_NoJSONPointerHighlighting(t *testing.T) {
 		}
 	}
 }
+
+func TestSemanticTokens_YAML(t *testing.T) {
+	content := `$schema: https://www.designtokens.org/schemas/2025.10.json
+color:
+  brand:
+    $root:
+      $type: color
+      $value: '{color.primary}'
+  accent:
+    $ref: '#/color/brand/$root'
+`
+
+	mockServer := testutil.NewMockServer()
+	doc := documents.NewDocument("file:///test.yaml", "yaml", 1, content)
+	_ = mockServer.TokenManager().Add(&tokens.Token{Name: "color-primary", Value: "#FF0000"})
+
+	semTokens := semantictokens.GetSemanticTokensForDocument(mockServer, doc)
+
+	lines := map[int][]semantictokens.SemanticTokenIntermediate{}
+	for _, token := range semTokens {
+		lines[token.Line] = append(lines[token.Line], token)
+	}
+	assert.Len(t, lines[3], 1, "$root keyword")
+	assert.Len(t, lines[5], 2, "both parts of the curly brace reference")
+	assert.NotEmpty(t, lines[7], "$ref keyword and pointer")
+	assert.Equal(t, semantictokens.TokenTypeKeyword, lines[7][0].TokenType)
+}
@@ -6,6 +6,7 @@ import (
 	"math"
 
 	"bennypowers.dev/dtls/internal/documents"
+	"bennypowers.dev/dtls/internal/metrics"
 	"bennypowers.dev/dtls/lsp/types"
 	protocol "github.com/tliron/glsp/protocol_3_16"
 )
@@ -27,10 +28,16 @@ func SemanticTokensFull(req *types.RequestContext, params *protocol.SemanticToke
 	uri := params.TextDocument.URI
 	log.Info("Semantic tokens requested for: %s", uri)
 
+	// Semantic tokens may be disabled in configuration after initialize
+	if !req.Config().Features.SemanticTokensEnabled() {
+		return nil, nil
+	}
+
 	doc := req.Server.Document(uri)
 	if doc == nil {
 		return nil, fmt.Errorf("document not found: %s", uri)
 	}
+	req.TraceDocument(doc)
 
 	// Only provide semantic tokens for JSON and YAML token files
 	languageID := doc.LanguageID()
@@ -135,11 +142,17 @@ func GetSemanticTokensForDocument(ctx types.ServerContext, doc *documents.Docume
 
 // SemanticTokensRange handles the textDocument/semanticTokens/range request
 func SemanticTokensRange(req *types.RequestContext, params *protocol.SemanticTokensRangeParams) (*protocol.SemanticTokens, error) {
+	// Semantic tokens may be disabled in configuration after initialize
+	if !req.Config().Features.SemanticTokensEnabled() {
+		return nil, nil
+	}
+
 	// Get the document
 	doc := req.Server.Document(params.TextDocument.URI)
 	if doc == nil {
 		return nil, fmt.Errorf("document not found: %s", params.TextDocument.URI)
 	}
+	req.TraceDocument(doc)
 
 	// Get all semantic tokens for the document
 	intermediateTokens := GetSemanticTokensForDocument(req.Server, doc)
@@ -191,10 +204,16 @@ func SemanticTokensFullDelta(req *types.RequestContext, params *SemanticTokensDe
 	uri := params.TextDocument.URI
 	log.Info("Semantic tokens delta requested for: %s (previousResultId: %s)", uri, params.PreviousResultID)
 
+	// Semantic tokens may be disabled in configuration after initialize
+	if !req.Config().Features.SemanticTokensEnabled() {
+		return nil, nil
+	}
+
 	doc := req.Server.Document(uri)
 	if doc == nil {
 		return nil, fmt.Errorf("document not found: %s", uri)
 	}
+	req.TraceDocument(doc)
 
 	// Only provide semantic tokens for JSON and YAML token files
 	languageID := doc.LanguageID()
@@ -212,6 +231,7 @@ func SemanticTokensFullDelta(req *types.RequestContext, params *SemanticTokensDe
 	// Try to get the previous result from cache, validating it belongs to this document
 	// This prevents delta computation from using tokens from a different file
 	prevEntry := cache.GetForURI(params.PreviousResultID, uri)
+	metrics.CacheLookup("semanticTokens", prevEntry != nil)
 
 	// Compute current tokens
 	intermediateTokens := GetSemanticTokensForDocument(req.Server, doc)
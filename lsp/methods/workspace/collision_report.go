@@ -0,0 +1,118 @@
+package workspace
+
+import (
+	"fmt"
+	"strings"
+
+	"bennypowers.dev/dtls/internal/tokens"
+	"bennypowers.dev/dtls/lsp/methods/textDocument/definition"
+	"bennypowers.dev/dtls/lsp/types"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+// CollisionReportCommand lists the tokens whose CSS variable names collide
+// once prefixed and flattened, e.g. color.primary-dark and
+// color.primary.dark, which are both --color-primary-dark. Only one of them
+// is used, so the others are silently shadowed. It returns a CollisionReport.
+const CollisionReportCommand = "designTokensLanguageServer.collisionReport"
+
+// CollidingToken is one of the tokens sharing a CSS variable name
+type CollidingToken struct {
+	// Path is the token's dot-separated path, e.g. "color.primary.dark"
+	Path   string `json:"path"`
+	Prefix string `json:"prefix,omitempty"`
+
+	// Location is where the token is defined, if it is defined in a file
+	Location *protocol.Location `json:"location,omitempty"`
+
+	// Used marks the token that the CSS variable name resolves to
+	Used bool `json:"used"`
+}
+
+// TokenCollision is a CSS variable name shared by tokens with different
+// prefixes or paths
+type TokenCollision struct {
+	Name   string           `json:"name"`
+	Tokens []CollidingToken `json:"tokens"`
+}
+
+// CollisionReport lists the colliding CSS variable names, in name order
+type CollisionReport struct {
+	Collisions []TokenCollision `json:"collisions"`
+
+	// Summary is a human-readable summary, e.g. "2 CSS variable names are shared by 5 tokens"
+	Summary string `json:"summary"`
+
+	// Markdown renders the report for display
+	Markdown string `json:"markdown"`
+}
+
+// collisionReport checks the loaded tokens for collisions.
+// Clients that support window/showDocument are also shown the report.
+func collisionReport(req *types.RequestContext) *CollisionReport {
+	manager := req.Server.TokenManager()
+	report := &CollisionReport{Collisions: []TokenCollision{}}
+	collisions := manager.Collisions()
+	count := 0
+	for _, collision := range collisions {
+		used := manager.Get(collision.Name)
+		entry := TokenCollision{Name: collision.Name}
+		for _, token := range collision.Tokens {
+			entry.Tokens = append(entry.Tokens, CollidingToken{
+				Path:     tokenPath(token),
+				Prefix:   token.Prefix,
+				Location: definition.TokenLocation(req, token),
+				Used:     token == used,
+			})
+		}
+		count += len(entry.Tokens)
+		report.Collisions = append(report.Collisions, entry)
+	}
+
+	if len(report.Collisions) == 0 {
+		report.Summary = "No tokens share a CSS variable name"
+	} else {
+		report.Summary = fmt.Sprintf("%d CSS variable names are shared by %d tokens", len(report.Collisions), count)
+	}
+	report.Markdown = collisionReportMarkdown(req, report, collisions)
+
+	if supportsShowDocument(req.Server.ClientCapabilities()) && req.GLSP != nil && req.GLSP.Call != nil {
+		showPreview(req, CollisionReportCommand, report.Markdown)
+	}
+	return report
+}
+
+// tokenPath returns a token's dot-separated path, or its name if it has none
+func tokenPath(token *tokens.Token) string {
+	if len(token.Path) == 0 {
+		return token.Name
+	}
+	return strings.Join(token.Path, ".")
+}
+
+// collisionReportMarkdown renders a collision report for display
+func collisionReportMarkdown(req *types.RequestContext, report *CollisionReport, collisions []tokens.Collision) string {
+	var b strings.Builder
+	b.WriteString("# Token name collisions\n\n")
+	fmt.Fprintf(&b, "%s\n", report.Summary)
+	if len(report.Collisions) == 0 {
+		return b.String()
+	}
+
+	b.WriteString("\nEach CSS variable resolves to one of its tokens; the others are shadowed. Rename them, or give their files different prefixes.\n")
+	for i, collision := range report.Collisions {
+		fmt.Fprintf(&b, "\n## `%s`\n\n| Token | Prefix | Defined in | Used |\n| --- | --- | --- | --- |\n", collision.Name)
+		for j, token := range collision.Tokens {
+			defined := ""
+			if source := collisions[i].Tokens[j]; source.FilePath != "" {
+				defined = fmt.Sprintf("%s:%d", relativePath(req, source.FilePath), source.Line+1)
+			}
+			used := "no"
+			if token.Used {
+				used = "yes"
+			}
+			fmt.Fprintf(&b, "| `%s` | %s | %s | %s |\n", token.Path, token.Prefix, defined, used)
+		}
+	}
+	return b.String()
+}
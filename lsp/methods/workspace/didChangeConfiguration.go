@@ -14,7 +14,7 @@ func DidChangeConfiguration(req *types.RequestContext, params *protocol.DidChang
 	log.Info("Configuration changed")
 
 	// Parse the settings
-	config, err := parseConfiguration(params.Settings)
+	config, err := ParseConfiguration(params.Settings)
 	if err != nil {
 		log.Info("Warning: failed to parse configuration: %v", err)
 		return nil // Don't fail, just use defaults
@@ -30,23 +30,51 @@ func DidChangeConfiguration(req *types.RequestContext, params *protocol.DidChang
 		log.Info("Warning: failed to reload tokens: %v", err)
 	}
 
-	// Republish diagnostics for all open documents (only if using push model)
-	// If client supports pull diagnostics (LSP 3.17), it will request them via textDocument/diagnostic
-	if !req.Server.UsePullDiagnostics() {
-		if req.GLSP != nil {
-			for _, doc := range req.Server.AllDocuments() {
-				if err := req.Server.PublishDiagnostics(req.GLSP, doc.URI()); err != nil {
-					log.Info("Warning: failed to publish diagnostics for %s: %v", doc.URI(), err)
-				}
-			}
+	// Replace file watchers so they track the new tokensFiles
+	if err := req.Server.RegisterFileWatchers(req.GLSP); err != nil {
+		log.Info("Warning: failed to update file watchers: %v", err)
+	}
+
+	// Refresh diagnostics for all open documents (pull or push, as the client supports)
+	if req.GLSP != nil {
+		if err := req.Server.RefreshDiagnostics(req.GLSP); err != nil {
+			log.Info("Warning: failed to refresh diagnostics: %v", err)
 		}
 	}
 
 	return nil
 }
 
-// parseConfiguration parses the configuration from the settings
-func parseConfiguration(settings any) (types.ServerConfig, error) {
+// ParseInitializationOptions parses configuration sent in InitializeParams.initializationOptions.
+// Clients may send the settings nested under "designTokensLanguageServer", as
+// with didChangeConfiguration, or the settings block itself, which lets
+// minimal clients configure the server at startup.
+func ParseInitializationOptions(options any) (types.ServerConfig, error) {
+	if optionsMap, ok := options.(map[string]any); ok {
+		_, nested := optionsMap["designTokensLanguageServer"]
+		_, nestedKebab := optionsMap["design-tokens-language-server"]
+		if !nested && !nestedKebab {
+			options = map[string]any{"designTokensLanguageServer": optionsMap}
+		}
+	}
+	return ParseConfiguration(options)
+}
+
+// WorkspaceTrusted reads the workspaceTrusted flag that clients send in
+// initializationOptions, beside the settings. A workspace is trusted unless
+// the client says otherwise.
+func WorkspaceTrusted(options any) bool {
+	optionsMap, ok := options.(map[string]any)
+	if !ok {
+		return true
+	}
+	trusted, ok := optionsMap["workspaceTrusted"].(bool)
+	return !ok || trusted
+}
+
+// ParseConfiguration parses the configuration from client settings.
+// Settings are nested under "designTokensLanguageServer" (or "design-tokens-language-server").
+func ParseConfiguration(settings any) (types.ServerConfig, error) {
 	// Default configuration
 	config := types.DefaultConfig()
 
@@ -37,6 +37,9 @@ func TestDidChangeConfiguration_WithValidConfig(t *testing.T) {
 	// Verify config was updated
 	config := ctx.GetConfig()
 	assert.Equal(t, "--custom", config.Prefix)
+
+	// File watchers are re-registered for the new tokensFiles
+	assert.True(t, ctx.RegisterWatchersCalled, "RegisterFileWatchers should be called")
 }
 
 func TestDidChangeConfiguration_WithNilSettings(t *testing.T) {
@@ -115,7 +118,7 @@ func TestDidChangeConfiguration_WithoutGLSPContext(t *testing.T) {
 }
 
 func TestParseConfiguration_DefaultConfig(t *testing.T) {
-	config, err := parseConfiguration(nil)
+	config, err := ParseConfiguration(nil)
 	require.NoError(t, err)
 	assert.Equal(t, types.DefaultConfig(), config)
 }
@@ -132,7 +135,7 @@ func TestParseConfiguration_ValidSettings(t *testing.T) {
 		},
 	}
 
-	config, err := parseConfiguration(settings)
+	config, err := ParseConfiguration(settings)
 	require.NoError(t, err)
 	assert.Equal(t, "--my-prefix", config.Prefix)
 	assert.Len(t, config.TokensFiles, 2)
@@ -154,7 +157,7 @@ func TestParseConfiguration_WithComplexTokensFiles(t *testing.T) {
 		},
 	}
 
-	config, err := parseConfiguration(settings)
+	config, err := ParseConfiguration(settings)
 	require.NoError(t, err)
 	assert.Len(t, config.TokensFiles, 2)
 }
@@ -163,7 +166,7 @@ func TestParseConfiguration_InvalidMap(t *testing.T) {
 	// Settings that's not a map
 	settings := "not a map"
 
-	_, err := parseConfiguration(settings)
+	_, err := ParseConfiguration(settings)
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "not a map")
 }
@@ -176,7 +179,7 @@ func TestParseConfiguration_MissingKey(t *testing.T) {
 		},
 	}
 
-	config, err := parseConfiguration(settings)
+	config, err := ParseConfiguration(settings)
 	require.NoError(t, err)
 	// Should return default config
 	assert.Equal(t, types.DefaultConfig(), config)
@@ -191,7 +194,7 @@ func TestParseConfiguration_InvalidJSON(t *testing.T) {
 		},
 	}
 
-	_, err := parseConfiguration(settings)
+	_, err := ParseConfiguration(settings)
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "marshal")
 }
@@ -204,7 +207,7 @@ func TestParseConfiguration_NetworkFallback(t *testing.T) {
 		},
 	}
 
-	config, err := parseConfiguration(settings)
+	config, err := ParseConfiguration(settings)
 	require.NoError(t, err)
 	assert.True(t, config.NetworkFallback)
 	assert.Equal(t, 60, config.NetworkTimeout)
@@ -218,7 +221,7 @@ func TestParseConfiguration_CDNProvider(t *testing.T) {
 		},
 	}
 
-	config, err := parseConfiguration(settings)
+	config, err := ParseConfiguration(settings)
 	require.NoError(t, err)
 	assert.Equal(t, "jsdelivr", config.CDN)
 }
@@ -230,9 +233,45 @@ func TestParseConfiguration_NetworkFallbackDefaults(t *testing.T) {
 		},
 	}
 
-	config, err := parseConfiguration(settings)
+	config, err := ParseConfiguration(settings)
 	require.NoError(t, err)
 	assert.False(t, config.NetworkFallback)
 	assert.Equal(t, 0, config.NetworkTimeout)
 	assert.Equal(t, "", config.CDN)
 }
+
+func TestParseInitializationOptions(t *testing.T) {
+	t.Run("nested settings", func(t *testing.T) {
+		config, err := ParseInitializationOptions(map[string]any{
+			"designTokensLanguageServer": map[string]any{"prefix": "ds"},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, "ds", config.Prefix)
+	})
+
+	t.Run("bare settings block", func(t *testing.T) {
+		config, err := ParseInitializationOptions(map[string]any{
+			"prefix":       "ds",
+			"tokensFiles":  []any{"tokens.json", map[string]any{"path": "more.json", "prefix": "more"}},
+			"groupMarkers": []any{"DEFAULT"},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, "ds", config.Prefix)
+		assert.Len(t, config.TokensFiles, 2)
+		assert.Equal(t, []string{"DEFAULT"}, config.GroupMarkers)
+		assert.True(t, config.GroupMarkersSet)
+	})
+
+	t.Run("nil options", func(t *testing.T) {
+		config, err := ParseInitializationOptions(nil)
+		require.NoError(t, err)
+		assert.Equal(t, types.DefaultConfig(), config)
+	})
+}
+
+func TestWorkspaceTrusted(t *testing.T) {
+	assert.True(t, WorkspaceTrusted(nil))
+	assert.True(t, WorkspaceTrusted(map[string]any{"prefix": "ds"}))
+	assert.True(t, WorkspaceTrusted(map[string]any{"workspaceTrusted": true}))
+	assert.False(t, WorkspaceTrusted(map[string]any{"workspaceTrusted": false}))
+}
@@ -1,8 +1,11 @@
 package workspace
 
 import (
+	"os"
+
 	"bennypowers.dev/dtls/internal/log"
 
+	"bennypowers.dev/dtls/internal/parser"
 	"bennypowers.dev/dtls/internal/uriutil"
 	"bennypowers.dev/dtls/lsp/types"
 	protocol "github.com/tliron/glsp/protocol_3_16"
@@ -21,6 +24,11 @@ func DidChangeWatchedFiles(req *types.RequestContext, params *protocol.DidChange
 		path := uriutil.URIToPath(uri)
 		log.Info("File change: %s (type: %d)", path, change.Type)
 
+		// Keep the workspace index of CSS variable references current
+		if parser.LanguageIDForPath(path) != "" {
+			req.Server.WorkspaceIndex().Update(path)
+		}
+
 		// Check if this is a token file we're watching
 		if req.Server.IsTokenFile(path) {
 			// If the file was deleted, remove it from loaded files
@@ -34,6 +42,21 @@ func DidChangeWatchedFiles(req *types.RequestContext, params *protocol.DidChange
 
 			// File was created, modified, or deleted - trigger reload
 			needsReload = true
+		} else if req.Server.IsCustomPropertiesFile(path) {
+			// A custom properties file only owns its own tokens, so reload just
+			// those and re-diagnose the documents which use them
+			content := ""
+			if change.Type != protocol.FileChangeTypeDeleted {
+				data, err := os.ReadFile(path) //nolint:gosec // G304: Custom properties file paths come from user configuration
+				if err != nil {
+					log.Warn("Failed to read custom properties file %s: %v", path, err)
+					continue
+				}
+				content = string(data)
+			}
+			if err := req.Server.ReloadCustomPropertiesFile(path, content); err != nil {
+				log.Warn("Failed to reload custom properties file %s: %v", path, err)
+			}
 		}
 	}
 
@@ -52,16 +75,10 @@ func DidChangeWatchedFiles(req *types.RequestContext, params *protocol.DidChange
 			log.Info("Warning: failed to reload tokens: %v", err)
 		}
 
-		// Republish diagnostics for all open documents (only if using push model)
-		// If client supports pull diagnostics (LSP 3.17), it will request them via textDocument/diagnostic
-		if !req.Server.UsePullDiagnostics() {
-			glspCtx := req.Server.GLSPContext()
-			if glspCtx != nil {
-				for _, doc := range req.Server.AllDocuments() {
-					if err := req.Server.PublishDiagnostics(glspCtx, doc.URI()); err != nil {
-						log.Info("Warning: failed to publish diagnostics for %s: %v", doc.URI(), err)
-					}
-				}
+		// Refresh diagnostics for all open documents (pull or push, as the client supports)
+		if glspCtx := req.Server.GLSPContext(); glspCtx != nil {
+			if err := req.Server.RefreshDiagnostics(glspCtx); err != nil {
+				log.Info("Warning: failed to refresh diagnostics: %v", err)
 			}
 		}
 	}
@@ -1,10 +1,15 @@
 package workspace
 
 import (
+	"os"
+	"path/filepath"
 	"testing"
 
+	"bennypowers.dev/dtls/internal/uriutil"
 	"bennypowers.dev/dtls/lsp/testutil"
 	"bennypowers.dev/dtls/lsp/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"github.com/tliron/glsp"
 	protocol "github.com/tliron/glsp/protocol_3_16"
 )
@@ -233,3 +238,28 @@ func TestHandleDidChangeWatchedFiles_PublishesDiagnostics(t *testing.T) {
 		t.Errorf("Expected diagnostics for test.css, got %s", publishedURIs[0])
 	}
 }
+
+func TestHandleDidChangeWatchedFiles_CustomPropertiesFile(t *testing.T) {
+	ctx := testutil.NewMockServerContext()
+	req := types.NewRequestContext(ctx, nil)
+	root := t.TempDir()
+	ctx.SetRootPath(root)
+	ctx.SetConfig(types.ServerConfig{CustomPropertiesFiles: []string{"generated.css"}})
+
+	generated := filepath.Join(root, "generated.css")
+	require.NoError(t, os.WriteFile(generated, []byte(":root { --gap: 4px; }"), 0o600))
+
+	err := DidChangeWatchedFiles(req, &protocol.DidChangeWatchedFilesParams{
+		Changes: []protocol.FileEvent{
+			{URI: uriutil.PathToURI(generated), Type: protocol.FileChangeTypeChanged},
+			{URI: uriutil.PathToURI(generated), Type: protocol.FileChangeTypeDeleted},
+		},
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, [][2]string{
+		{generated, ":root { --gap: 4px; }"},
+		{generated, ""},
+	}, ctx.ReloadedCustomProperties, "deleted files declare no properties")
+	assert.False(t, ctx.LoadTokensCalled, "other token sources are not reloaded")
+}
@@ -0,0 +1,102 @@
+package workspace
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"bennypowers.dev/dtls/internal/log"
+	"bennypowers.dev/dtls/internal/parser"
+	"bennypowers.dev/dtls/internal/uriutil"
+	"bennypowers.dev/dtls/lsp/helpers"
+	"bennypowers.dev/dtls/lsp/types"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+// DidDeleteFiles handles the workspace/didDeleteFiles notification.
+// Tokens from deleted token files (or token files inside deleted folders)
+// are purged, diagnostics are republished, and the user is warned about
+// open documents which now reference missing tokens.
+func DidDeleteFiles(req *types.RequestContext, params *protocol.DeleteFilesParams) error {
+	manager := req.Server.TokenManager()
+
+	// CSS variable names of purged tokens, to find now-dangling references
+	purged := map[string]bool{}
+	var removedFiles []string
+
+	for _, file := range params.Files {
+		deleted := uriutil.URIToPath(file.URI)
+		for _, source := range manager.GetSourceFiles() {
+			if !helpers.PathWithin(source, deleted) {
+				continue
+			}
+			for _, token := range manager.GetBySourceFile(source) {
+				purged[manager.CSSVariableName(token)] = true
+			}
+			count := manager.RemoveBySourceFile(source)
+			req.Server.RemoveLoadedFile(source)
+			removedFiles = append(removedFiles, source)
+			log.Info("Token file deleted: %s (%d tokens removed)", source, count)
+		}
+		// Files tracked without any tokens still need to be forgotten
+		req.Server.RemoveLoadedFile(deleted)
+	}
+
+	if len(removedFiles) == 0 {
+		return nil
+	}
+
+	glspCtx := req.Server.GLSPContext()
+	if glspCtx != nil {
+		if err := req.Server.RefreshDiagnostics(glspCtx); err != nil {
+			log.Info("Warning: failed to refresh diagnostics: %v", err)
+		}
+	}
+
+	if affected := documentsWithMissingTokens(req.Server, purged); len(affected) > 0 {
+		ShowMessage(glspCtx, protocol.MessageTypeWarning, fmt.Sprintf(
+			"Deleted token files left %d open document(s) referencing missing tokens: %s",
+			len(affected), strings.Join(affected, ", ")))
+	}
+
+	return nil
+}
+
+// documentsWithMissingTokens lists open CSS documents (relative to the
+// workspace root where possible) using any purged token which no longer resolves
+func documentsWithMissingTokens(server types.ServerContext, purged map[string]bool) []string {
+	rootPath := server.RootPath()
+
+	var affected []string
+	for _, doc := range server.AllDocuments() {
+		if !parser.IsCSSSupportedLanguage(doc.LanguageID()) {
+			continue
+		}
+		result, err := parser.ParseCSSFromDocument(doc.Content(), doc.LanguageID())
+		if err != nil || result == nil {
+			continue
+		}
+		missing := false
+		for _, call := range result.VarCalls {
+			if purged[call.TokenName] && server.Token(call.TokenName) == nil {
+				missing = true
+				break
+			}
+		}
+		if !missing {
+			continue
+		}
+
+		name := uriutil.URIToPath(doc.URI())
+		if rootPath != "" {
+			if rel, err := filepath.Rel(rootPath, name); err == nil && !strings.HasPrefix(rel, "..") {
+				name = filepath.ToSlash(rel)
+			}
+		}
+		affected = append(affected, name)
+	}
+
+	slices.Sort(affected)
+	return affected
+}
@@ -0,0 +1,100 @@
+package workspace
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"bennypowers.dev/dtls/internal/documents"
+	"bennypowers.dev/dtls/internal/tokens"
+	"bennypowers.dev/dtls/internal/uriutil"
+	"bennypowers.dev/dtls/lsp/testutil"
+	"bennypowers.dev/dtls/lsp/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tliron/glsp"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+func setupDeleteWorkspace(t *testing.T) (*testutil.MockServerContext, chan *protocol.ShowMessageParams, string) {
+	t.Helper()
+	root := filepath.FromSlash("/workspace")
+
+	messages := make(chan *protocol.ShowMessageParams, 1)
+	ctx := testutil.NewMockServerContext()
+	ctx.SetRootPath(root)
+	ctx.SetGLSPContext(&glsp.Context{
+		Notify: func(method string, params any) {
+			if method == protocol.ServerWindowShowMessage {
+				messages <- params.(*protocol.ShowMessageParams)
+			}
+		},
+	})
+	ctx.RefreshDiagnosticsFunc = func(*glsp.Context) error { return nil }
+
+	colorPath := filepath.Join(root, "tokens", "color.json")
+	spacePath := filepath.Join(root, "space.json")
+	require.NoError(t, ctx.TokenManager().Add(&tokens.Token{Name: "color-primary", Value: "#00f", FilePath: colorPath}))
+	require.NoError(t, ctx.TokenManager().Add(&tokens.Token{Name: "space-small", Value: "4px", FilePath: spacePath}))
+
+	ctx.AddDocument(documents.NewDocument(uriutil.PathToURI(filepath.Join(root, "src", "button.css")), "css", 1,
+		".btn { color: var(--color-primary); }"))
+	ctx.AddDocument(documents.NewDocument(uriutil.PathToURI(filepath.Join(root, "src", "card.css")), "css", 1,
+		".card { padding: var(--space-small); }"))
+
+	return ctx, messages, root
+}
+
+func TestDidDeleteFiles_TokenFile(t *testing.T) {
+	ctx, messages, root := setupDeleteWorkspace(t)
+	req := types.NewRequestContext(ctx, nil)
+
+	err := DidDeleteFiles(req, &protocol.DeleteFilesParams{
+		Files: []protocol.FileDelete{{URI: uriutil.PathToURI(filepath.Join(root, "tokens", "color.json"))}},
+	})
+	require.NoError(t, err)
+
+	assert.Nil(t, ctx.Token("color-primary"), "tokens from the deleted file are purged")
+	assert.NotNil(t, ctx.Token("space-small"), "tokens from other files are kept")
+	assert.True(t, ctx.RefreshDiagnosticsCalled)
+
+	select {
+	case msg := <-messages:
+		assert.Equal(t, protocol.MessageTypeWarning, msg.Type)
+		assert.Contains(t, msg.Message, "src/button.css")
+		assert.NotContains(t, msg.Message, "src/card.css")
+	case <-time.After(time.Second):
+		t.Fatal("expected a warning listing affected documents")
+	}
+}
+
+func TestDidDeleteFiles_Folder(t *testing.T) {
+	ctx, _, root := setupDeleteWorkspace(t)
+	req := types.NewRequestContext(ctx, nil)
+
+	err := DidDeleteFiles(req, &protocol.DeleteFilesParams{
+		Files: []protocol.FileDelete{{URI: uriutil.PathToURI(filepath.Join(root, "tokens"))}},
+	})
+	require.NoError(t, err)
+
+	assert.Nil(t, ctx.Token("color-primary"))
+	assert.Equal(t, 1, ctx.TokenManager().Count())
+}
+
+func TestDidDeleteFiles_Unrelated(t *testing.T) {
+	ctx, messages, root := setupDeleteWorkspace(t)
+	req := types.NewRequestContext(ctx, nil)
+
+	err := DidDeleteFiles(req, &protocol.DeleteFilesParams{
+		Files: []protocol.FileDelete{{URI: uriutil.PathToURI(filepath.Join(root, "src", "button.css"))}},
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, 2, ctx.TokenManager().Count())
+	assert.False(t, ctx.RefreshDiagnosticsCalled)
+	select {
+	case msg := <-messages:
+		t.Fatalf("unexpected message: %s", msg.Message)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
@@ -0,0 +1,30 @@
+package workspace
+
+import (
+	"encoding/json"
+	"fmt"
+
+	codeaction "bennypowers.dev/dtls/lsp/methods/textDocument/codeAction"
+	"bennypowers.dev/dtls/lsp/types"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+// editText returns the documents a code action's workspace edit changes, as
+// the edit would leave them, for clients to apply themselves
+func editText(req *types.RequestContext, params *protocol.ExecuteCommandParams) ([]codeaction.EditedDocument, error) {
+	var edit protocol.WorkspaceEdit
+	if len(params.Arguments) > 0 {
+		// Round-trip through JSON to decode the argument object
+		data, err := json.Marshal(params.Arguments[0])
+		if err == nil {
+			err = json.Unmarshal(data, &edit)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s expects a workspace edit argument: %w", params.Command, err)
+		}
+	}
+	if len(edit.Changes) == 0 {
+		return nil, fmt.Errorf("%s expects a workspace edit argument", params.Command)
+	}
+	return codeaction.EditedDocuments(req, edit)
+}
@@ -0,0 +1,100 @@
+package workspace
+
+import (
+	"fmt"
+	"slices"
+
+	"bennypowers.dev/dtls/internal/log"
+	codeaction "bennypowers.dev/dtls/lsp/methods/textDocument/codeAction"
+	"bennypowers.dev/dtls/lsp/types"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+// TogglePrefixDisplayCommand toggles whether hover titles and completion labels
+// include token prefixes. Inserted text always uses the fully prefixed variable.
+const TogglePrefixDisplayCommand = "designTokensLanguageServer.togglePrefixDisplay"
+
+// Commands lists the commands advertised in executeCommandProvider
+var Commands = []string{
+	TogglePrefixDisplayCommand,
+	RefreshStatsCommand,
+	SnapshotTokensCommand,
+	DiffTokenSnapshotCommand,
+	VerifyGeneratedOutputCommand,
+	SyntaxReportCommand,
+	CollisionReportCommand,
+	CheckTokensFilesCommand,
+	SyncThemeCommand,
+	ProfileCommand,
+	codeaction.PreviewFixAllFallbacksCommand,
+	codeaction.PreviewDeprecatedMigrationCommand,
+	codeaction.PickReplacementCommand,
+	codeaction.EditTextCommand,
+}
+
+// writeCommands edit workspace files, so they are disabled in read-only mode
+var writeCommands = []string{
+	SyncThemeCommand,
+	codeaction.PickReplacementCommand,
+}
+
+// AvailableCommands returns the commands to advertise for config, which in
+// read-only mode leaves out those that edit workspace files
+func AvailableCommands(config types.ServerConfig) []string {
+	if !config.ReadOnly {
+		return Commands
+	}
+	return slices.DeleteFunc(slices.Clone(Commands), func(command string) bool {
+		return slices.Contains(writeCommands, command)
+	})
+}
+
+// ExecuteCommand handles the workspace/executeCommand request
+func ExecuteCommand(req *types.RequestContext, params *protocol.ExecuteCommandParams) (any, error) {
+	if req.Config().ReadOnly && slices.Contains(writeCommands, params.Command) {
+		return nil, fmt.Errorf("%s is disabled in read-only mode", params.Command)
+	}
+	switch params.Command {
+	case TogglePrefixDisplayCommand:
+		return togglePrefixDisplay(req), nil
+	case RefreshStatsCommand:
+		return refreshStats(req), nil
+	case SnapshotTokensCommand:
+		return snapshotTokens(req), nil
+	case DiffTokenSnapshotCommand:
+		return diffTokenSnapshot(req)
+	case VerifyGeneratedOutputCommand:
+		return verifyGeneratedOutput(req)
+	case SyntaxReportCommand:
+		return syntaxReport(req), nil
+	case CollisionReportCommand:
+		return collisionReport(req), nil
+	case CheckTokensFilesCommand:
+		return checkTokensFiles(req)
+	case SyncThemeCommand:
+		return syncTheme(req, params)
+	case ProfileCommand:
+		return profile(req, params)
+	case codeaction.PreviewFixAllFallbacksCommand, codeaction.PreviewDeprecatedMigrationCommand:
+		return previewEdits(req, params)
+	case codeaction.PickReplacementCommand:
+		return pickReplacement(req, params)
+	case codeaction.EditTextCommand:
+		return editText(req, params)
+	default:
+		return nil, fmt.Errorf("unknown command: %s", params.Command)
+	}
+}
+
+// togglePrefixDisplay flips the showPrefix setting and returns the new value.
+// The change lasts until the client next sends configuration.
+func togglePrefixDisplay(req *types.RequestContext) bool {
+	// Read-modify-write of the live configuration, not the request snapshot
+	config := req.Server.GetConfig()
+	show := !config.ShowPrefixEnabled()
+	config.ShowPrefix = &show
+	req.Server.SetConfig(config)
+
+	log.Info("Prefix display in hover and completion labels: %v", show)
+	return show
+}
@@ -0,0 +1,492 @@
+package workspace
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"bennypowers.dev/dtls/internal/tokens"
+	"bennypowers.dev/dtls/internal/uriutil"
+	codeaction "bennypowers.dev/dtls/lsp/methods/textDocument/codeAction"
+	"bennypowers.dev/dtls/lsp/testutil"
+	"bennypowers.dev/dtls/lsp/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tliron/glsp"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+func TestExecuteCommand_TogglePrefixDisplay(t *testing.T) {
+	ctx := testutil.NewMockServerContext()
+	req := types.NewRequestContext(ctx, &glsp.Context{})
+	params := &protocol.ExecuteCommandParams{Command: TogglePrefixDisplayCommand}
+
+	require.True(t, ctx.GetConfig().ShowPrefixEnabled(), "prefixes are shown by default")
+
+	result, err := ExecuteCommand(req, params)
+	require.NoError(t, err)
+	assert.Equal(t, false, result)
+	assert.False(t, ctx.GetConfig().ShowPrefixEnabled())
+
+	result, err = ExecuteCommand(req, params)
+	require.NoError(t, err)
+	assert.Equal(t, true, result)
+	assert.True(t, ctx.GetConfig().ShowPrefixEnabled())
+}
+
+func TestExecuteCommand_Unknown(t *testing.T) {
+	ctx := testutil.NewMockServerContext()
+	req := types.NewRequestContext(ctx, &glsp.Context{})
+
+	_, err := ExecuteCommand(req, &protocol.ExecuteCommandParams{Command: "nope"})
+	assert.Error(t, err)
+}
+
+func TestExecuteCommand_ReadOnly(t *testing.T) {
+	ctx := testutil.NewMockServerContext()
+	ctx.SetWorkspaceTrusted(false)
+	req := types.NewRequestContext(ctx, &glsp.Context{})
+
+	for _, command := range []string{SyncThemeCommand, codeaction.PickReplacementCommand} {
+		_, err := ExecuteCommand(req, &protocol.ExecuteCommandParams{Command: command})
+		assert.ErrorContains(t, err, "disabled in read-only mode", command)
+		assert.NotContains(t, AvailableCommands(req.Config()), command)
+	}
+
+	_, err := ExecuteCommand(req, &protocol.ExecuteCommandParams{Command: SyntaxReportCommand})
+	assert.NoError(t, err, "commands that only read are available")
+}
+
+func TestExecuteCommand_PreviewDeprecatedMigration(t *testing.T) {
+	ctx := testutil.NewMockServerContext()
+	req := types.NewRequestContext(ctx, nil)
+
+	_ = ctx.TokenManager().Add(&tokens.Token{
+		Name:               "color-old",
+		Value:              "#ff0000",
+		Deprecated:         true,
+		DeprecationMessage: "Use color.new instead",
+	})
+	_ = ctx.TokenManager().Add(&tokens.Token{Name: "color-new", Value: "#0000ff"})
+
+	uri := "file:///test.css"
+	content := ".a {\n  color: var(--color-old);\n}"
+	require.NoError(t, ctx.DocumentManager().DidOpen(uri, "css", 1, content))
+
+	result, err := ExecuteCommand(req, &protocol.ExecuteCommandParams{
+		Command:   codeaction.PreviewDeprecatedMigrationCommand,
+		Arguments: []any{uri},
+	})
+	require.NoError(t, err)
+
+	markdown, ok := result.(string)
+	require.True(t, ok)
+	assert.Contains(t, markdown, "-  color: var(--color-old);\n+  color: var(--color-new);\n")
+
+	// Previews never touch the document
+	assert.Equal(t, content, ctx.Document(uri).Content())
+
+	t.Run("rejects extra arguments", func(t *testing.T) {
+		_, err := ExecuteCommand(req, &protocol.ExecuteCommandParams{
+			Command:   codeaction.PreviewDeprecatedMigrationCommand,
+			Arguments: []any{uri, uri},
+		})
+		assert.Error(t, err)
+	})
+}
+
+func TestExecuteCommand_PreviewWorkspace(t *testing.T) {
+	ctx := testutil.NewMockServerContext()
+	req := types.NewRequestContext(ctx, nil)
+
+	_ = ctx.TokenManager().Add(&tokens.Token{
+		Name:               "color-old",
+		Value:              "#ff0000",
+		Deprecated:         true,
+		DeprecationMessage: "Use color.new instead",
+	})
+	_ = ctx.TokenManager().Add(&tokens.Token{Name: "color-new", Value: "#0000ff"})
+
+	dir := t.TempDir()
+	onDisk := filepath.Join(dir, "b.css")
+	require.NoError(t, os.WriteFile(onDisk, []byte(".b {\n  color: var(--color-old);\n}\n"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "clean.css"), []byte(".c {\n  color: var(--color-new);\n}\n"), 0o644))
+	require.NoError(t, ctx.WorkspaceIndex().Scan(dir))
+
+	open := uriutil.PathToURI(filepath.Join(dir, "a.css"))
+	require.NoError(t, ctx.DocumentManager().DidOpen(open, "css", 1, ".a {\n  background: var(--color-old);\n}"))
+
+	result, err := ExecuteCommand(req, &protocol.ExecuteCommandParams{Command: codeaction.PreviewDeprecatedMigrationCommand})
+	require.NoError(t, err)
+
+	markdown, ok := result.(string)
+	require.True(t, ok)
+	assert.Contains(t, markdown, "+  background: var(--color-new);\n")
+	assert.Contains(t, markdown, "+  color: var(--color-new);\n")
+	assert.Less(t, strings.Index(markdown, open), strings.Index(markdown, uriutil.PathToURI(onDisk)), "files are in URI order")
+	assert.NotContains(t, markdown, "clean.css", "files without edits are left out")
+
+	content, err := os.ReadFile(onDisk)
+	require.NoError(t, err)
+	assert.Contains(t, string(content), "var(--color-old)", "previews never touch files")
+}
+
+func TestPreviewPath_ReusedPerCommand(t *testing.T) {
+	ctx := testutil.NewMockServerContext()
+	req := types.NewRequestContext(ctx, nil)
+
+	first, err := previewPath(req, codeaction.PreviewDeprecatedMigrationCommand)
+	require.NoError(t, err)
+	again, err := previewPath(req, codeaction.PreviewDeprecatedMigrationCommand)
+	require.NoError(t, err)
+	other, err := previewPath(req, SyntaxReportCommand)
+	require.NoError(t, err)
+
+	assert.Equal(t, first, again, "a command's previews overwrite one file")
+	assert.NotEqual(t, first, other)
+	assert.Equal(t, filepath.Dir(first), filepath.Dir(other))
+}
+
+func TestExecuteCommand_TokenSnapshot(t *testing.T) {
+	ctx := testutil.NewMockServerContext()
+	req := types.NewRequestContext(ctx, &glsp.Context{})
+
+	_, err := ExecuteCommand(req, &protocol.ExecuteCommandParams{Command: DiffTokenSnapshotCommand})
+	assert.Error(t, err, "diffing requires a snapshot")
+
+	require.NoError(t, ctx.TokenManager().Add(&tokens.Token{Name: "color.primary", Value: "#00f", Type: "color"}))
+	require.NoError(t, ctx.TokenManager().Add(&tokens.Token{Name: "color.legacy", Value: "#000", Type: "color"}))
+
+	result, err := ExecuteCommand(req, &protocol.ExecuteCommandParams{Command: SnapshotTokensCommand})
+	require.NoError(t, err)
+	assert.Equal(t, 2, result)
+
+	require.NoError(t, ctx.TokenManager().Remove("color.legacy"))
+	require.NoError(t, ctx.TokenManager().Add(&tokens.Token{Name: "color.primary", Value: "#00e", Type: "color"}))
+	require.NoError(t, ctx.TokenManager().Add(&tokens.Token{Name: "color.accent", Value: "#f0f", Type: "color"}))
+
+	result, err = ExecuteCommand(req, &protocol.ExecuteCommandParams{Command: DiffTokenSnapshotCommand})
+	require.NoError(t, err)
+	report, ok := result.(*TokenSnapshotReport)
+	require.True(t, ok)
+
+	assert.Equal(t, "1 added, 1 removed, 1 changed", report.Summary)
+	assert.Contains(t, report.Markdown, "## Added\n\n| Token | Value |\n| --- | --- |\n| `--color-accent` | `#f0f` |\n")
+	assert.Contains(t, report.Markdown, "| `--color-legacy` | `#000` |\n")
+	assert.Contains(t, report.Markdown, "| `--color-primary` | `#00f` (color) | `#00e` (color) |\n")
+}
+
+func TestExecuteCommand_VerifyGeneratedOutput(t *testing.T) {
+	ctx := testutil.NewMockServerContext()
+	req := types.NewRequestContext(ctx, &glsp.Context{})
+
+	_, err := ExecuteCommand(req, &protocol.ExecuteCommandParams{Command: VerifyGeneratedOutputCommand})
+	assert.Error(t, err, "verifying requires generated output")
+
+	dir := t.TempDir()
+	ctx.SetRootPath(dir)
+	config := types.DefaultConfig()
+	config.CustomPropertiesFiles = []string{"tokens.css"}
+	ctx.SetConfig(config)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "tokens.css"), []byte(":root {\n  --color-primary: #f00;\n  --space-small: 4px;\n}\n"), 0o644))
+
+	for _, token := range []*tokens.Token{
+		{Name: "color-primary", Value: "#00f", Type: "color", FilePath: filepath.Join(dir, "tokens.json")},
+		{Name: "space-small", Value: "4px", Type: "dimension", FilePath: filepath.Join(dir, "tokens.json")},
+		{Name: "space-large", Value: "16px", Type: "dimension", FilePath: filepath.Join(dir, "tokens.json")},
+	} {
+		require.NoError(t, ctx.TokenManager().Add(token))
+	}
+
+	result, err := ExecuteCommand(req, &protocol.ExecuteCommandParams{Command: VerifyGeneratedOutputCommand})
+	require.NoError(t, err)
+	report, ok := result.(*GeneratedOutputReport)
+	require.True(t, ok)
+
+	assert.Equal(t, "1 missing, 1 stale", report.Summary)
+	assert.Equal(t, []string{"--space-large"}, report.Missing)
+	assert.Equal(t, []StaleDeclaration{{
+		Name:     "--color-primary",
+		File:     filepath.Join(dir, "tokens.css"),
+		Line:     1,
+		Declared: "#f00",
+		Expected: "#00f",
+	}}, report.Stale)
+	assert.Contains(t, report.Markdown, "| `--color-primary` | tokens.css:2 | `#f00` | `#00f` |\n")
+}
+
+func TestExecuteCommand_CheckTokensFiles(t *testing.T) {
+	ctx := testutil.NewMockServerContext()
+	req := types.NewRequestContext(ctx, &glsp.Context{})
+
+	_, err := ExecuteCommand(req, &protocol.ExecuteCommandParams{Command: CheckTokensFilesCommand})
+	assert.Error(t, err, "checking requires tokensFiles")
+
+	dir := t.TempDir()
+	ctx.SetRootPath(dir)
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "tokens"), 0o755))
+	for _, name := range []string{"color.json", "space.json"} {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "tokens", name), []byte(`{}`), 0o644))
+	}
+	config := types.DefaultConfig()
+	config.TokensFiles = []any{
+		"./tokens/*.json",
+		map[string]any{"path": "./missing.json", "prefix": "ds"},
+	}
+	ctx.SetConfig(config)
+	require.NoError(t, ctx.TokenManager().Add(&tokens.Token{
+		Name: "color-primary", Value: "#00f", Type: "color", FilePath: filepath.Join(dir, "tokens", "color.json"),
+	}))
+	ctx.WatchedPatterns = []string{filepath.ToSlash(filepath.Join(dir, "tokens", "color.json"))}
+
+	req = types.NewRequestContext(ctx, &glsp.Context{})
+	result, err := ExecuteCommand(req, &protocol.ExecuteCommandParams{Command: CheckTokensFilesCommand})
+	require.NoError(t, err)
+	report, ok := result.(*TokensFilesReport)
+	require.True(t, ok)
+
+	assert.Equal(t, "2 files with 1 tokens from 2 entries", report.Summary)
+	require.Len(t, report.Entries, 2)
+	assert.Equal(t, []TokensFileMatch{
+		{Path: filepath.Join(dir, "tokens", "color.json"), Tokens: 1, Watched: true},
+		{Path: filepath.Join(dir, "tokens", "space.json"), Tokens: 0, Watched: false},
+	}, report.Entries[0].Files)
+	assert.Equal(t, "./missing.json", report.Entries[1].Path)
+	assert.Empty(t, report.Entries[1].Files)
+	assert.False(t, report.WatchingSupported)
+	assert.Contains(t, report.Markdown, "| "+filepath.Join("tokens", "space.json")+" | 0 | no |\n")
+	assert.Contains(t, report.Markdown, "Matches no files")
+	assert.Contains(t, report.Markdown, "does not support file watchers")
+}
+
+func TestExecuteCommand_SyntaxReport(t *testing.T) {
+	ctx := testutil.NewMockServerContext()
+	req := types.NewRequestContext(ctx, &glsp.Context{})
+
+	require.NoError(t, ctx.DocumentManager().DidOpen("file:///b.css", "css", 1,
+		".card {\n  &:hover { color: var(--a); }\n}\n@scope (.card) to (.content) {\n  img { color: var(--b); }\n}\n"))
+	require.NoError(t, ctx.DocumentManager().DidOpen("file:///a.css", "css", 1, ".a { color: var(--a); }"))
+	require.NoError(t, ctx.DocumentManager().DidOpen("file:///tokens.json", "json", 1, `{}`))
+
+	result, err := ExecuteCommand(req, &protocol.ExecuteCommandParams{Command: SyntaxReportCommand})
+	require.NoError(t, err)
+	report, ok := result.(*SyntaxReport)
+	require.True(t, ok)
+
+	assert.Equal(t, 2, report.Checked)
+	assert.Equal(t, "1 unsupported lines in 1 of 2 files", report.Summary)
+	require.Len(t, report.Files, 1)
+	assert.Equal(t, "file:///b.css", report.Files[0].URI)
+	require.Len(t, report.Files[0].Unsupported, 1)
+	assert.Equal(t, uint32(3), report.Files[0].Unsupported[0].Range.Start.Line)
+	assert.Contains(t, report.Markdown, "| 4 | `@scope (.card) to (.content) {` |\n")
+}
+
+func TestExecuteCommand_CollisionReport(t *testing.T) {
+	ctx := testutil.NewMockServerContext()
+	req := types.NewRequestContext(ctx, &glsp.Context{})
+
+	result, err := ExecuteCommand(req, &protocol.ExecuteCommandParams{Command: CollisionReportCommand})
+	require.NoError(t, err)
+	report, ok := result.(*CollisionReport)
+	require.True(t, ok)
+	assert.Empty(t, report.Collisions)
+	assert.Equal(t, "No tokens share a CSS variable name", report.Summary)
+
+	uri := "file:///tokens.json"
+	require.NoError(t, ctx.DocumentManager().DidOpen(uri, "json", 1,
+		"{\n  \"color\": {\n    \"primary-dark\": { \"$value\": \"#003\" },\n    \"primary\": {\n      \"dark\": { \"$value\": \"#006\" }\n    }\n  }\n}"))
+	for _, token := range []*tokens.Token{
+		{Name: "color-primary-dark", Path: []string{"color", "primary-dark"}, Value: "#003", FilePath: "/tokens.json", DefinitionURI: uri, Line: 2, Character: 5},
+		{Name: "color-primary-dark", Path: []string{"color", "primary", "dark"}, Value: "#006", FilePath: "/tokens.json", DefinitionURI: uri, Line: 4, Character: 7},
+	} {
+		require.NoError(t, ctx.TokenManager().Add(token))
+	}
+
+	result, err = ExecuteCommand(req, &protocol.ExecuteCommandParams{Command: CollisionReportCommand})
+	require.NoError(t, err)
+	report, ok = result.(*CollisionReport)
+	require.True(t, ok)
+
+	assert.Equal(t, "1 CSS variable names are shared by 2 tokens", report.Summary)
+	require.Len(t, report.Collisions, 1)
+	collision := report.Collisions[0]
+	assert.Equal(t, "--color-primary-dark", collision.Name)
+	require.Len(t, collision.Tokens, 2)
+	assert.Equal(t, "color.primary-dark", collision.Tokens[0].Path)
+	assert.False(t, collision.Tokens[0].Used, "the first token is shadowed")
+	require.NotNil(t, collision.Tokens[0].Location)
+	assert.Equal(t, protocol.Range{
+		Start: protocol.Position{Line: 2, Character: 5},
+		End:   protocol.Position{Line: 2, Character: 23},
+	}, collision.Tokens[0].Location.Range)
+	assert.Equal(t, "color.primary.dark", collision.Tokens[1].Path)
+	assert.True(t, collision.Tokens[1].Used)
+	assert.Contains(t, report.Markdown, "| `color.primary-dark` |  | /tokens.json:3 | no |\n")
+	assert.Contains(t, report.Markdown, "| `color.primary.dark` |  | /tokens.json:5 | yes |\n")
+}
+
+func TestExecuteCommand_Profile(t *testing.T) {
+	ctx := testutil.NewMockServerContext()
+	req := types.NewRequestContext(ctx, &glsp.Context{})
+
+	t.Run("heap profile is written immediately", func(t *testing.T) {
+		result, err := ExecuteCommand(req, &protocol.ExecuteCommandParams{
+			Command:   ProfileCommand,
+			Arguments: []any{map[string]any{"kind": "heap"}},
+		})
+		require.NoError(t, err)
+		profile, ok := result.(*ProfileResult)
+		require.True(t, ok)
+		t.Cleanup(func() { _ = os.Remove(profile.Path) })
+
+		assert.Equal(t, "heap", profile.Kind)
+		info, err := os.Stat(profile.Path)
+		require.NoError(t, err)
+		assert.NotZero(t, info.Size())
+	})
+
+	t.Run("unknown profile", func(t *testing.T) {
+		_, err := ExecuteCommand(req, &protocol.ExecuteCommandParams{
+			Command:   ProfileCommand,
+			Arguments: []any{map[string]any{"kind": "nope"}},
+		})
+		assert.ErrorContains(t, err, `unknown profile "nope"`)
+	})
+
+	t.Run("CPU profile duration is capped", func(t *testing.T) {
+		_, err := ExecuteCommand(req, &protocol.ExecuteCommandParams{
+			Command:   ProfileCommand,
+			Arguments: []any{map[string]any{"seconds": 3600}},
+		})
+		assert.ErrorContains(t, err, "seconds must be between 1 and 300")
+	})
+}
+
+func TestExecuteCommand_SyncTheme(t *testing.T) {
+	ctx := testutil.NewMockServerContext()
+	req := types.NewRequestContext(ctx, &glsp.Context{})
+
+	_, err := ExecuteCommand(req, &protocol.ExecuteCommandParams{Command: SyncThemeCommand})
+	assert.ErrorContains(t, err, "no theme file to sync")
+
+	dir := t.TempDir()
+	ctx.SetRootPath(dir)
+	config := types.DefaultConfig()
+	config.ThemeFile = "theme.css"
+	ctx.SetConfig(config)
+	req = types.NewRequestContext(ctx, &glsp.Context{})
+	themePath := filepath.Join(dir, "theme.css")
+	tokensPath := filepath.Join(dir, "tokens.json")
+	require.NoError(t, os.WriteFile(themePath, []byte(":root {\n  --color-primary: #f00;\n  --color-link: #0f0;\n  --space-small: 4px;\n}\n"), 0o644))
+	require.NoError(t, os.WriteFile(tokensPath, []byte(`{
+  "color": {
+    "primary": { "$value": "#00f", "$type": "color" },
+    "link": { "$value": "{color.primary}", "$type": "color" }
+  },
+  "space": { "small": { "$value": "4px", "$type": "dimension" } }
+}
+`), 0o644))
+
+	for _, token := range []*tokens.Token{
+		{Name: "color-primary", Path: []string{"color", "primary"}, Value: "#00f", Type: "color", FilePath: tokensPath},
+		{Name: "color-link", Path: []string{"color", "link"}, Value: "#00f", Type: "color", FilePath: tokensPath},
+		{Name: "space-small", Path: []string{"space", "small"}, Value: "4px", Type: "dimension", FilePath: tokensPath},
+	} {
+		require.NoError(t, ctx.TokenManager().Add(token))
+	}
+
+	t.Run("update CSS from tokens", func(t *testing.T) {
+		result, err := ExecuteCommand(req, &protocol.ExecuteCommandParams{Command: SyncThemeCommand, Arguments: []any{nil, ThemeFromTokens}})
+		require.NoError(t, err)
+		report, ok := result.(*ThemeSyncReport)
+		require.True(t, ok)
+
+		assert.Equal(t, "2 properties differ, 1 can only be updated in CSS", report.Summary)
+		assert.Equal(t, []string{"--color-link"}, report.Skipped)
+		require.NotNil(t, report.Edit)
+		assert.Equal(t, []protocol.TextEdit{
+			{Range: protocol.Range{Start: protocol.Position{Line: 1, Character: 19}, End: protocol.Position{Line: 1, Character: 23}}, NewText: "#00f"},
+			{Range: protocol.Range{Start: protocol.Position{Line: 2, Character: 16}, End: protocol.Position{Line: 2, Character: 20}}, NewText: "#00f"},
+		}, report.Edit.Changes["file://"+themePath])
+	})
+
+	t.Run("update tokens from CSS", func(t *testing.T) {
+		result, err := ExecuteCommand(req, &protocol.ExecuteCommandParams{Command: SyncThemeCommand, Arguments: []any{"file://" + themePath, TokensFromTheme}})
+		require.NoError(t, err)
+		report, ok := result.(*ThemeSyncReport)
+		require.True(t, ok)
+
+		require.NotNil(t, report.Edit)
+		assert.Equal(t, []protocol.TextEdit{
+			{Range: protocol.Range{Start: protocol.Position{Line: 2, Character: 27}, End: protocol.Position{Line: 2, Character: 33}}, NewText: `"#f00"`},
+		}, report.Edit.Changes["file://"+tokensPath])
+	})
+
+	t.Run("unknown direction", func(t *testing.T) {
+		_, err := ExecuteCommand(req, &protocol.ExecuteCommandParams{Command: SyncThemeCommand, Arguments: []any{nil, "both"}})
+		assert.ErrorContains(t, err, "direction must be")
+	})
+}
+
+func TestExecuteCommand_PickReplacement(t *testing.T) {
+	ctx := testutil.NewMockServerContext()
+
+	t.Run("missing choices", func(t *testing.T) {
+		req := types.NewRequestContext(ctx, &glsp.Context{})
+		_, err := ExecuteCommand(req, &protocol.ExecuteCommandParams{
+			Command:   codeaction.PickReplacementCommand,
+			Arguments: []any{map[string]any{"uri": "file:///test.css", "token": "--color-old"}},
+		})
+		assert.ErrorContains(t, err, "expects a {uri, token, range, choices} argument")
+	})
+
+	t.Run("without a client", func(t *testing.T) {
+		req := types.NewRequestContext(ctx, nil)
+		_, err := ExecuteCommand(req, &protocol.ExecuteCommandParams{
+			Command: codeaction.PickReplacementCommand,
+			Arguments: []any{codeaction.PickReplacementArgs{
+				URI:     "file:///test.css",
+				Token:   "--color-old",
+				Choices: []codeaction.ReplacementChoice{{Name: "--color-a", NewText: "--color-a"}},
+			}},
+		})
+		assert.Error(t, err)
+	})
+}
+
+func TestExecuteCommand_EditText(t *testing.T) {
+	ctx := testutil.NewMockServerContext()
+	req := types.NewRequestContext(ctx, nil)
+
+	uri := "file:///test.css"
+	require.NoError(t, ctx.DocumentManager().DidOpen(uri, "css", 1, ".a {\n  color: red;\n}"))
+
+	// The edit arrives from the client as decoded JSON
+	edit := map[string]any{
+		"changes": map[string]any{
+			uri: []any{map[string]any{
+				"range": map[string]any{
+					"start": map[string]any{"line": 1, "character": 9},
+					"end":   map[string]any{"line": 1, "character": 12},
+				},
+				"newText": "var(--color-red)",
+			}},
+		},
+	}
+	result, err := ExecuteCommand(req, &protocol.ExecuteCommandParams{
+		Command:   codeaction.EditTextCommand,
+		Arguments: []any{edit},
+	})
+	require.NoError(t, err)
+	documents, ok := result.([]codeaction.EditedDocument)
+	require.True(t, ok)
+	require.Len(t, documents, 1)
+	assert.Equal(t, ".a {\n  color: var(--color-red);\n}", documents[0].Text)
+	assert.Equal(t, "--- file:///test.css\n+++ file:///test.css\n@@ -2,1 +2,1 @@\n-  color: red;\n+  color: var(--color-red);\n", documents[0].Diff)
+	assert.Equal(t, ".a {\n  color: red;\n}", ctx.Document(uri).Content(), "the document is not edited")
+
+	_, err = ExecuteCommand(req, &protocol.ExecuteCommandParams{Command: codeaction.EditTextCommand})
+	assert.ErrorContains(t, err, "expects a workspace edit argument")
+}
@@ -0,0 +1,142 @@
+package workspace
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"bennypowers.dev/dtls/internal/parser"
+	"bennypowers.dev/dtls/internal/uriutil"
+	"bennypowers.dev/dtls/lsp/helpers/css"
+	"bennypowers.dev/dtls/lsp/types"
+)
+
+// VerifyGeneratedOutputCommand checks the configured generated custom
+// properties files against the token files, reporting tokens with no
+// generated declaration and declarations with stale values. It returns a
+// GeneratedOutputReport.
+const VerifyGeneratedOutputCommand = "designTokensLanguageServer.verifyGeneratedOutput"
+
+// StaleDeclaration is a generated declaration whose value no longer matches its token
+type StaleDeclaration struct {
+	Name     string `json:"name"`
+	File     string `json:"file"`
+	Line     uint32 `json:"line"`
+	Declared string `json:"declared"`
+	Expected string `json:"expected"`
+}
+
+// GeneratedOutputReport lists the differences between generated output and
+// the token files. An up-to-date build has no missing or stale entries.
+type GeneratedOutputReport struct {
+	// Files are the generated files checked
+	Files []string `json:"files"`
+
+	// Missing are the CSS variable names of tokens no file declares
+	Missing []string `json:"missing"`
+
+	Stale []StaleDeclaration `json:"stale"`
+
+	// Summary is a human-readable summary, e.g. "2 missing, 1 stale"
+	Summary string `json:"summary"`
+
+	// Markdown renders the report for display
+	Markdown string `json:"markdown"`
+}
+
+// verifyGeneratedOutput checks every configured custom properties file.
+// Clients that support window/showDocument are also shown the report.
+func verifyGeneratedOutput(req *types.RequestContext) (*GeneratedOutputReport, error) {
+	files := req.Server.CustomPropertiesFiles()
+	if len(files) == 0 {
+		return nil, errors.New("no generated output to verify: configure customPropertiesFiles")
+	}
+
+	report := &GeneratedOutputReport{Files: files, Stale: []StaleDeclaration{}}
+	declared := map[string]bool{}
+	for _, path := range files {
+		content, err := generatedContent(req, path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read generated output %s: %w", path, err)
+		}
+		result, err := parser.ParseCSSFromDocument(content, "css")
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse generated output %s: %w", path, err)
+		}
+		if result == nil {
+			continue
+		}
+
+		for _, v := range result.Variables {
+			declared[v.Name] = true
+			value := css.RangeText(content, v.ValueRange)
+			if expected, stale := css.StaleCustomPropertyValue(req.Server.Token(v.Name), path, value); stale {
+				report.Stale = append(report.Stale, StaleDeclaration{
+					Name:     v.Name,
+					File:     path,
+					Line:     v.Range.Start.Line,
+					Declared: value,
+					Expected: expected,
+				})
+			}
+		}
+	}
+
+	report.Missing = css.MissingCustomProperties(req.Server.TokenManager(), declared, req.Server.IsCustomPropertiesFile)
+	report.Summary = fmt.Sprintf("%d missing, %d stale", len(report.Missing), len(report.Stale))
+	report.Markdown = generatedOutputMarkdown(req, report)
+
+	if supportsShowDocument(req.Server.ClientCapabilities()) && req.GLSP != nil && req.GLSP.Call != nil {
+		showPreview(req, VerifyGeneratedOutputCommand, report.Markdown)
+	}
+	return report, nil
+}
+
+// generatedContent returns the open document's content, or the file on disk
+func generatedContent(req *types.RequestContext, path string) (string, error) {
+	if doc := req.Server.Document(uriutil.PathToURI(path)); doc != nil {
+		return doc.Content(), nil
+	}
+	data, err := os.ReadFile(path) //nolint:gosec // G304: Custom properties file paths come from user configuration
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// generatedOutputMarkdown renders a generated output report for display
+func generatedOutputMarkdown(req *types.RequestContext, report *GeneratedOutputReport) string {
+	var b strings.Builder
+	b.WriteString("# Generated output\n\n")
+	fmt.Fprintf(&b, "%s\n", report.Summary)
+	if len(report.Missing) == 0 && len(report.Stale) == 0 {
+		b.WriteString("\nThe generated output is up to date.\n")
+		return b.String()
+	}
+
+	if len(report.Missing) > 0 {
+		b.WriteString("\n## Missing\n\n")
+		for _, name := range report.Missing {
+			fmt.Fprintf(&b, "- `%s`\n", name)
+		}
+	}
+	if len(report.Stale) > 0 {
+		b.WriteString("\n## Stale\n\n| Property | File | Generated | Token |\n| --- | --- | --- | --- |\n")
+		for _, stale := range report.Stale {
+			fmt.Fprintf(&b, "| `%s` | %s:%d | `%s` | `%s` |\n", stale.Name, relativePath(req, stale.File), stale.Line+1, stale.Declared, stale.Expected)
+		}
+	}
+	return b.String()
+}
+
+// relativePath shortens path to be relative to the workspace root when inside it
+func relativePath(req *types.RequestContext, path string) string {
+	if root := req.Server.RootPath(); root != "" {
+		if rel, err := filepath.Rel(root, path); err == nil && !strings.HasPrefix(rel, "..") {
+			return rel
+		}
+	}
+	return path
+}
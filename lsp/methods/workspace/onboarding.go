@@ -0,0 +1,117 @@
+package workspace
+
+import (
+	"os"
+	"path/filepath"
+
+	"bennypowers.dev/dtls/internal/log"
+	"bennypowers.dev/dtls/internal/uriutil"
+	"bennypowers.dev/dtls/internal/workspacefiles"
+	"bennypowers.dev/dtls/lsp/types"
+	"github.com/tliron/glsp"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+// maxLocatedTokenFiles limits the token files offered to choose from, as
+// clients show each as a button
+const maxLocatedTokenFiles = 5
+
+// SuggestTokenFiles helps newcomers set up the server: when no token sources
+// are configured and no tokens are loaded, it says so through
+// window/showMessageRequest, rather than leaving every feature silently
+// idle. The user may then locate a token file in the workspace to load, or
+// open the package.json whose designTokensLanguageServer field configures
+// the server.
+func SuggestTokenFiles(req *types.RequestContext) {
+	config := req.Config()
+	if config.TokensFiles != nil || config.Resolvers != nil || config.CustomPropertiesFiles != nil || config.Figma != nil {
+		return
+	}
+	root := req.Server.RootPath()
+	if root == "" || req.Server.TokenCount() > 0 || req.GLSP == nil || req.GLSP.Call == nil {
+		return
+	}
+
+	locate := protocol.MessageActionItem{Title: req.Localize("Locate tokens file…")}
+	settings := protocol.MessageActionItem{Title: req.Localize("Open settings")}
+	actions := []protocol.MessageActionItem{locate}
+	packageJSON := filepath.Join(root, "package.json")
+	if _, err := os.Stat(packageJSON); err == nil && supportsShowDocument(req.Server.ClientCapabilities()) {
+		actions = append(actions, settings)
+	}
+	message := protocol.ShowMessageRequestParams{
+		Type:    protocol.MessageTypeInfo,
+		Message: req.Localize("No design token files are configured, so tokens won't be completed, checked or shown on hover. Configure tokensFiles to load them."),
+		Actions: actions,
+	}
+
+	// A request to the client: send it from a goroutine so the message
+	// handler loop can read the response
+	go func(ctx *glsp.Context) {
+		var choice *protocol.MessageActionItem
+		ctx.Call(protocol.ServerWindowShowMessageRequest, message, &choice)
+		switch {
+		case choice == nil:
+			return
+		case choice.Title == locate.Title:
+			locateTokenFiles(req)
+		case choice.Title == settings.Title:
+			takeFocus := true
+			params := protocol.ShowDocumentParams{URI: uriutil.PathToURI(packageJSON), TakeFocus: &takeFocus}
+			var result protocol.ShowDocumentResult
+			ctx.Call(protocol.ServerWindowShowDocument, params, &result)
+		}
+	}(req.GLSP)
+}
+
+// locateTokenFiles finds the workspace's conventionally named token files
+// and asks the user which to load
+func locateTokenFiles(req *types.RequestContext) {
+	root := req.Server.RootPath()
+	found, err := workspacefiles.FindTokenFiles(root)
+	if err != nil {
+		log.Warn("Failed to search %s for token files: %v", root, err)
+	}
+	if len(found) == 0 {
+		ShowMessage(req.GLSP, protocol.MessageTypeWarning, req.Localize("No token files found in the workspace. Token files are usually named tokens.json or *.tokens.json: add yours to tokensFiles."))
+		return
+	}
+
+	actions := make([]protocol.MessageActionItem, 0, min(len(found), maxLocatedTokenFiles))
+	for _, path := range found[:cap(actions)] {
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			continue
+		}
+		actions = append(actions, protocol.MessageActionItem{Title: filepath.ToSlash(rel)})
+	}
+	message := protocol.ShowMessageRequestParams{
+		Type:    protocol.MessageTypeInfo,
+		Message: req.Localize("Load design tokens from:"),
+		Actions: actions,
+	}
+	var picked *protocol.MessageActionItem
+	req.GLSP.Call(protocol.ServerWindowShowMessageRequest, message, &picked)
+	if picked == nil {
+		return
+	}
+	useTokensFile(req, picked.Title)
+}
+
+// useTokensFile loads a token file for the rest of the session, as though
+// it were configured in tokensFiles, and reminds the user to configure it
+func useTokensFile(req *types.RequestContext, path string) {
+	req.Server.SetTokensFiles([]any{path})
+
+	if err := req.Server.LoadTokensFromConfig(); err != nil {
+		log.Warn("Failed to load %s: %v", path, err)
+	}
+	if err := req.Server.RegisterFileWatchers(req.GLSP); err != nil {
+		log.Warn("Failed to update file watchers: %v", err)
+	}
+	if err := req.Server.RefreshDiagnostics(req.GLSP); err != nil {
+		log.Warn("Failed to refresh diagnostics: %v", err)
+	}
+
+	ShowMessage(req.GLSP, protocol.MessageTypeInfo, req.Localize("Loaded %d tokens from %s for this session. Add it to tokensFiles to load it every time.", req.Server.TokenCount(), path))
+}
@@ -0,0 +1,151 @@
+package workspace
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"bennypowers.dev/dtls/internal/tokens"
+	"bennypowers.dev/dtls/internal/uriutil"
+	"bennypowers.dev/dtls/lsp/testutil"
+	"bennypowers.dev/dtls/lsp/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tliron/glsp"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+// clientMessage is a request or notification sent to the client
+type clientMessage struct {
+	method string
+	params any
+}
+
+// answeringClient returns a glsp.Context whose showMessageRequests are
+// answered with choices, in order, and which sends everything it receives
+func answeringClient(choices ...string) (*glsp.Context, chan clientMessage) {
+	received := make(chan clientMessage, 10)
+	ctx := &glsp.Context{
+		Call: func(method string, params any, result any) {
+			received <- clientMessage{method, params}
+			if choice, ok := result.(**protocol.MessageActionItem); ok && len(choices) > 0 {
+				*choice = &protocol.MessageActionItem{Title: choices[0]}
+				choices = choices[1:]
+			}
+		},
+		Notify: func(method string, params any) {
+			received <- clientMessage{method, params}
+		},
+	}
+	return ctx, received
+}
+
+func nextMessage(t *testing.T, received chan clientMessage) clientMessage {
+	t.Helper()
+	select {
+	case msg := <-received:
+		return msg
+	case <-time.After(time.Second):
+		require.FailNow(t, "the client received no message")
+		return clientMessage{}
+	}
+}
+
+func actionTitles(params any) []string {
+	var titles []string
+	for _, action := range params.(protocol.ShowMessageRequestParams).Actions {
+		titles = append(titles, action.Title)
+	}
+	return titles
+}
+
+func onboardingWorkspace(t *testing.T, files ...string) *testutil.MockServerContext {
+	t.Helper()
+	root := t.TempDir()
+	for _, file := range files {
+		path := filepath.Join(root, file)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o750))
+		require.NoError(t, os.WriteFile(path, []byte("{}"), 0o600))
+	}
+	server := testutil.NewMockServerContext()
+	server.SetRootPath(root)
+	server.SetConfig(types.DefaultConfig())
+	return server
+}
+
+func TestSuggestTokenFiles(t *testing.T) {
+	t.Run("loads a located token file", func(t *testing.T) {
+		server := onboardingWorkspace(t, "tokens/color.tokens.json", "src/main.css")
+		ctx, received := answeringClient("Locate tokens file…", "tokens/color.tokens.json")
+
+		SuggestTokenFiles(types.NewRequestContext(server, ctx))
+
+		msg := nextMessage(t, received)
+		assert.Equal(t, protocol.ServerWindowShowMessageRequest, msg.method)
+		assert.Equal(t, []string{"Locate tokens file…"}, actionTitles(msg.params), "without package.json, there are no settings to open")
+
+		msg = nextMessage(t, received)
+		assert.Equal(t, []string{"tokens/color.tokens.json"}, actionTitles(msg.params))
+
+		msg = nextMessage(t, received)
+		assert.Equal(t, protocol.ServerWindowShowMessage, msg.method)
+		assert.Contains(t, msg.params.(*protocol.ShowMessageParams).Message, "tokens/color.tokens.json")
+		assert.Equal(t, []any{"tokens/color.tokens.json"}, server.GetConfig().TokensFiles)
+		assert.True(t, server.LoadTokensCalled)
+		assert.True(t, server.RegisterWatchersCalled)
+	})
+
+	t.Run("opens package.json", func(t *testing.T) {
+		server := onboardingWorkspace(t, "package.json")
+		var caps protocol.ClientCapabilities
+		require.NoError(t, json.Unmarshal([]byte(`{"window": {"showDocument": {"support": true}}}`), &caps))
+		server.SetClientCapabilities(caps)
+		ctx, received := answeringClient("Open settings")
+
+		SuggestTokenFiles(types.NewRequestContext(server, ctx))
+
+		msg := nextMessage(t, received)
+		assert.Equal(t, []string{"Locate tokens file…", "Open settings"}, actionTitles(msg.params))
+
+		msg = nextMessage(t, received)
+		assert.Equal(t, protocol.ServerWindowShowDocument, msg.method)
+		assert.Equal(t, uriutil.PathToURI(filepath.Join(server.RootPath(), "package.json")), msg.params.(protocol.ShowDocumentParams).URI)
+	})
+
+	t.Run("warns when no token files are found", func(t *testing.T) {
+		server := onboardingWorkspace(t, "src/main.css")
+		ctx, received := answeringClient("Locate tokens file…")
+
+		SuggestTokenFiles(types.NewRequestContext(server, ctx))
+
+		nextMessage(t, received)
+		msg := nextMessage(t, received)
+		assert.Equal(t, protocol.ServerWindowShowMessage, msg.method)
+		assert.Equal(t, protocol.MessageTypeWarning, msg.params.(*protocol.ShowMessageParams).Type)
+		assert.False(t, server.LoadTokensCalled)
+	})
+
+	t.Run("is silent when token files are configured", func(t *testing.T) {
+		server := onboardingWorkspace(t, "tokens.json")
+		config := types.DefaultConfig()
+		config.TokensFiles = []any{"missing.json"}
+		server.SetConfig(config)
+		ctx, received := answeringClient()
+
+		SuggestTokenFiles(types.NewRequestContext(server, ctx))
+
+		assert.Empty(t, received)
+	})
+
+	t.Run("is silent when tokens are loaded", func(t *testing.T) {
+		server := onboardingWorkspace(t, "tokens.json")
+		require.NoError(t, server.TokenManager().Add(&tokens.Token{Name: "color-primary", Value: "red"}))
+		ctx, received := answeringClient()
+
+		SuggestTokenFiles(types.NewRequestContext(server, ctx))
+
+		assert.Empty(t, received)
+	})
+}
@@ -0,0 +1,65 @@
+package workspace
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	codeaction "bennypowers.dev/dtls/lsp/methods/textDocument/codeAction"
+	"bennypowers.dev/dtls/lsp/types"
+	"github.com/tliron/glsp"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+// pickReplacement asks the user which token should replace a deprecated one,
+// through window/showMessageRequest, then applies the chosen replacement
+func pickReplacement(req *types.RequestContext, params *protocol.ExecuteCommandParams) (any, error) {
+	var args codeaction.PickReplacementArgs
+	if len(params.Arguments) > 0 {
+		// Round-trip through JSON to decode the argument object
+		data, err := json.Marshal(params.Arguments[0])
+		if err == nil {
+			err = json.Unmarshal(data, &args)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s expects a {uri, token, range, choices} argument: %w", params.Command, err)
+		}
+	}
+	if args.URI == "" || len(args.Choices) == 0 {
+		return nil, fmt.Errorf("%s expects a {uri, token, range, choices} argument", params.Command)
+	}
+	if req.GLSP == nil || req.GLSP.Call == nil {
+		return nil, errors.New("the client cannot be asked to choose a replacement")
+	}
+
+	actions := make([]protocol.MessageActionItem, len(args.Choices))
+	for i, choice := range args.Choices {
+		actions[i] = protocol.MessageActionItem{Title: choice.Name}
+	}
+	message := protocol.ShowMessageRequestParams{
+		Type:    protocol.MessageTypeInfo,
+		Message: req.Localize("Replace deprecated %s with:", args.Token),
+		Actions: actions,
+	}
+
+	// A request to the client: send it from a goroutine so the message
+	// handler loop can read the response
+	go func(ctx *glsp.Context) {
+		var picked *protocol.MessageActionItem
+		ctx.Call(protocol.ServerWindowShowMessageRequest, message, &picked)
+		if picked == nil {
+			return
+		}
+		for _, choice := range args.Choices {
+			if choice.Name == picked.Title {
+				applyWorkspaceEdit(ctx, "Replace "+args.Token, &protocol.WorkspaceEdit{
+					Changes: map[string][]protocol.TextEdit{
+						args.URI: {{Range: args.Range, NewText: choice.NewText}},
+					},
+				})
+				return
+			}
+		}
+	}(req.GLSP)
+	return nil, nil
+}
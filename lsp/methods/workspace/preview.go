@@ -0,0 +1,147 @@
+package workspace
+
+import (
+	"fmt"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	"bennypowers.dev/dtls/internal/documents"
+	"bennypowers.dev/dtls/internal/log"
+	"bennypowers.dev/dtls/internal/parser"
+	"bennypowers.dev/dtls/internal/uriutil"
+	codeaction "bennypowers.dev/dtls/lsp/methods/textDocument/codeAction"
+	"bennypowers.dev/dtls/lsp/types"
+	"github.com/tliron/glsp"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+// previewEdits runs a dry-run command: it renders the edits the command
+// would make as a markdown diff and returns it without applying anything.
+// With a document URI argument, it previews the edits to that document;
+// without arguments, the edits to every stylesheet and component in the
+// workspace. Clients that support window/showDocument are also shown the
+// diff, written to a file.
+func previewEdits(req *types.RequestContext, params *protocol.ExecuteCommandParams) (string, error) {
+	var files []codeaction.PreviewFile
+	switch len(params.Arguments) {
+	case 0:
+		files = workspacePreviewFiles(req, params.Command)
+	case 1:
+		uri, ok := params.Arguments[0].(string)
+		if !ok {
+			return "", fmt.Errorf("%s expects a document URI argument, got %T", params.Command, params.Arguments[0])
+		}
+		doc := req.Server.Document(uri)
+		if doc == nil {
+			return "", fmt.Errorf("document not open: %s", uri)
+		}
+		edits, err := codeaction.PreviewEdits(req, params.Command, doc)
+		if err != nil {
+			return "", err
+		}
+		files = []codeaction.PreviewFile{{URI: uri, Content: doc.Content(), Edits: edits}}
+	default:
+		return "", fmt.Errorf("%s expects a document URI argument, or none to preview the workspace", params.Command)
+	}
+
+	markdown := codeaction.PreviewMarkdown(codeaction.PreviewCommands[params.Command], files)
+	if supportsShowDocument(req.Server.ClientCapabilities()) && req.GLSP != nil && req.GLSP.Call != nil {
+		showPreview(req, params.Command, markdown)
+	}
+	return markdown, nil
+}
+
+// workspacePreviewFiles computes the edits a dry-run command would make to
+// the open documents, as the editor has them, and to the files of the
+// workspace index, as they are on disk. Only files with edits are returned,
+// in URI order.
+func workspacePreviewFiles(req *types.RequestContext, command string) []codeaction.PreviewFile {
+	var files []codeaction.PreviewFile
+	add := func(doc *documents.Document) {
+		if !parser.IsCSSSupportedLanguage(doc.LanguageID()) {
+			return
+		}
+		edits, err := codeaction.PreviewEdits(req, command, doc)
+		if err != nil {
+			req.AddWarning(err)
+			return
+		}
+		if len(edits) > 0 {
+			files = append(files, codeaction.PreviewFile{URI: doc.URI(), Content: doc.Content(), Edits: edits})
+		}
+	}
+
+	open := map[string]bool{}
+	for _, doc := range req.Server.AllDocuments() {
+		open[doc.URI()] = true
+		add(doc)
+	}
+	for _, path := range req.Server.WorkspaceIndex().Paths() {
+		uri := uriutil.PathToURI(path)
+		if open[uri] {
+			continue
+		}
+		content, err := os.ReadFile(path) //nolint:gosec // G304: files under the workspace root
+		if err != nil {
+			log.Warn("Cannot read %s: %v", path, err)
+			continue
+		}
+		add(documents.NewDocument(uri, parser.LanguageIDForPath(path), 0, string(content)))
+	}
+
+	slices.SortFunc(files, func(a, b codeaction.PreviewFile) int { return strings.Compare(a.URI, b.URI) })
+	return files
+}
+
+// previewDir is the directory preview files are written to, created once
+// per process
+var previewDir = sync.OnceValues(func() (string, error) {
+	return os.MkdirTemp("", "dtls-previews-*")
+})
+
+// previewPath returns the file the previews of a command are written to.
+// Each command has one file, which its next preview overwrites, so previews
+// don't pile up. Servers sharing a process (see daemon mode) have their own
+// files, named by workspace.
+func previewPath(req *types.RequestContext, command string) (string, error) {
+	dir, err := previewDir()
+	if err != nil {
+		return "", err
+	}
+	name := command[strings.LastIndex(command, ".")+1:]
+	if root := req.Server.RootURI(); root != "" {
+		name += fmt.Sprintf("-%08x", crc32.ChecksumIEEE([]byte(root)))
+	}
+	return filepath.Join(dir, name+".md"), nil
+}
+
+// showPreview writes a command's preview to its markdown file and asks the client to open it
+func showPreview(req *types.RequestContext, command, markdown string) {
+	path, err := previewPath(req, command)
+	if err != nil {
+		log.Warn("Failed to create preview directory: %v", err)
+		return
+	}
+	if err := os.WriteFile(path, []byte(markdown), 0o600); err != nil {
+		log.Warn("Failed to write preview file %s: %v", path, err)
+		return
+	}
+
+	// A request to the client: send it from a goroutine so the message
+	// handler loop can read the response
+	takeFocus := true
+	params := protocol.ShowDocumentParams{URI: uriutil.PathToURI(path), TakeFocus: &takeFocus}
+	go func(ctx *glsp.Context) {
+		var result protocol.ShowDocumentResult
+		ctx.Call(protocol.ServerWindowShowDocument, params, &result)
+	}(req.GLSP)
+}
+
+// supportsShowDocument reports whether the client handles window/showDocument
+func supportsShowDocument(caps *protocol.ClientCapabilities) bool {
+	return caps != nil && caps.Window != nil && caps.Window.ShowDocument != nil && caps.Window.ShowDocument.Support
+}
@@ -0,0 +1,129 @@
+package workspace
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"bennypowers.dev/dtls/internal/log"
+	"bennypowers.dev/dtls/internal/metrics"
+	"bennypowers.dev/dtls/lsp/types"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+// ProfileCommand captures a pprof profile of the server to a temporary file,
+// or the metrics.ProfileDirEnv directory, for attaching to performance bug
+// reports and collecting from benchmarks. It takes an optional
+// {"kind": "cpu", "seconds": 30} argument; kind is "cpu" (the default) or a
+// runtime profile such as "heap" or "goroutine". It returns a ProfileResult.
+const ProfileCommand = "designTokensLanguageServer.profile"
+
+const (
+	// defaultProfileSeconds is how long CPU profiles sample by default
+	defaultProfileSeconds = 30
+
+	// maxProfileSeconds caps how long a CPU profile may sample
+	maxProfileSeconds = 300
+)
+
+// ProfileResult describes a captured profile
+type ProfileResult struct {
+	// Path is the profile file, readable with `go tool pprof`
+	Path string `json:"path"`
+	Kind string `json:"kind"`
+
+	// Seconds is how long a CPU profile samples. The file is complete once
+	// the server shows a message saying so.
+	Seconds int `json:"seconds,omitempty"`
+}
+
+// profileArgs are the optional arguments of ProfileCommand
+type profileArgs struct {
+	Kind    string `json:"kind"`
+	Seconds int    `json:"seconds"`
+}
+
+// cpuProfiling is set while a CPU profile is sampling; only one may run at a time
+var cpuProfiling atomic.Bool
+
+// profile captures a profile. CPU profiles sample in the background so the
+// server stays responsive (and worth profiling) in the meantime.
+func profile(req *types.RequestContext, params *protocol.ExecuteCommandParams) (*ProfileResult, error) {
+	args := profileArgs{Kind: metrics.ProfileCPU, Seconds: defaultProfileSeconds}
+	if len(params.Arguments) > 0 {
+		// Round-trip through JSON to decode the argument object
+		data, err := json.Marshal(params.Arguments[0])
+		if err == nil {
+			err = json.Unmarshal(data, &args)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s expects a {kind, seconds} argument: %w", params.Command, err)
+		}
+	}
+	if args.Kind == "" {
+		args.Kind = metrics.ProfileCPU
+	}
+	if !metrics.HasProfile(args.Kind) {
+		return nil, fmt.Errorf("unknown profile %q", args.Kind)
+	}
+
+	if args.Kind != metrics.ProfileCPU {
+		path, err := writeProfileFile(args.Kind, 0)
+		if err != nil {
+			return nil, err
+		}
+		log.Info("Wrote %s profile to %s", args.Kind, path)
+		return &ProfileResult{Path: path, Kind: args.Kind}, nil
+	}
+
+	if args.Seconds <= 0 || args.Seconds > maxProfileSeconds {
+		return nil, fmt.Errorf("%s: seconds must be between 1 and %d", params.Command, maxProfileSeconds)
+	}
+	if !cpuProfiling.CompareAndSwap(false, true) {
+		return nil, errors.New("a CPU profile is already being captured")
+	}
+
+	f, err := metrics.CreateProfileFile(metrics.ProfileCPU)
+	if err != nil {
+		cpuProfiling.Store(false)
+		return nil, err
+	}
+	path := f.Name()
+	glspCtx := req.GLSP
+	go func() {
+		defer cpuProfiling.Store(false)
+		err := metrics.WriteProfile(f, metrics.ProfileCPU, time.Duration(args.Seconds)*time.Second)
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			log.Error("Failed to capture CPU profile: %v", err)
+			return
+		}
+		log.Info("Wrote CPU profile to %s", path)
+		if glspCtx != nil && glspCtx.Notify != nil {
+			ShowMessage(glspCtx, protocol.MessageTypeInfo, "Design Tokens Language Server: CPU profile written to "+path)
+		}
+	}()
+
+	log.Info("Capturing a %ds CPU profile to %s", args.Seconds, path)
+	return &ProfileResult{Path: path, Kind: args.Kind, Seconds: args.Seconds}, nil
+}
+
+// writeProfileFile writes a profile to a new file and returns its path
+func writeProfileFile(kind string, duration time.Duration) (string, error) {
+	f, err := metrics.CreateProfileFile(kind)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = f.Close() }()
+
+	if err := metrics.WriteProfile(f, kind, duration); err != nil {
+		_ = os.Remove(f.Name())
+		return "", err
+	}
+	return f.Name(), nil
+}
@@ -0,0 +1,107 @@
+package workspace
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"bennypowers.dev/dtls/internal/log"
+	"bennypowers.dev/dtls/internal/tokens"
+	"bennypowers.dev/dtls/lsp/types"
+)
+
+// SnapshotTokensCommand saves the current resolved token set, so it can be
+// compared against the live tokens after edits, branch switches or upgrades.
+// It returns the number of tokens saved.
+const SnapshotTokensCommand = "designTokensLanguageServer.snapshotTokens"
+
+// DiffTokenSnapshotCommand compares the saved snapshot against the live
+// tokens and returns a TokenSnapshotReport
+const DiffTokenSnapshotCommand = "designTokensLanguageServer.diffTokenSnapshot"
+
+// TokenSnapshotReport lists the tokens added, removed and changed since a snapshot
+type TokenSnapshotReport struct {
+	tokens.SnapshotDiff
+
+	// Since is when the snapshot was taken
+	Since time.Time `json:"since"`
+
+	// Summary is a human-readable summary, e.g. "2 added, 1 removed, 3 changed"
+	Summary string `json:"summary"`
+
+	// Markdown renders the report as a table per section
+	Markdown string `json:"markdown"`
+}
+
+// snapshotTokens saves the live token set and returns its size
+func snapshotTokens(req *types.RequestContext) int {
+	snapshot := req.Server.TokenManager().Snapshot()
+	req.Server.SetTokenSnapshot(snapshot)
+	log.Info("Saved token snapshot of %d tokens", snapshot.Len())
+	return snapshot.Len()
+}
+
+// diffTokenSnapshot compares the saved snapshot against the live token set.
+// Clients that support window/showDocument are also shown the report.
+func diffTokenSnapshot(req *types.RequestContext) (*TokenSnapshotReport, error) {
+	snapshot := req.Server.TokenSnapshot()
+	if snapshot == nil {
+		return nil, errors.New("no token snapshot: run " + SnapshotTokensCommand + " first")
+	}
+
+	diff := snapshot.Diff(req.Server.TokenManager().Snapshot())
+	report := &TokenSnapshotReport{
+		SnapshotDiff: diff,
+		Since:        snapshot.Taken,
+		Summary:      fmt.Sprintf("%d added, %d removed, %d changed", len(diff.Added), len(diff.Removed), len(diff.Changed)),
+	}
+	report.Markdown = snapshotMarkdown(report)
+
+	if supportsShowDocument(req.Server.ClientCapabilities()) && req.GLSP != nil && req.GLSP.Call != nil {
+		showPreview(req, DiffTokenSnapshotCommand, report.Markdown)
+	}
+	return report, nil
+}
+
+// snapshotMarkdown renders a snapshot report for display
+func snapshotMarkdown(report *TokenSnapshotReport) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Token changes since %s\n\n", report.Since.Format(time.DateTime))
+	fmt.Fprintf(&b, "%s\n", report.Summary)
+	if report.Empty() {
+		return b.String()
+	}
+
+	entries := func(title string, list []tokens.SnapshotEntry) {
+		if len(list) == 0 {
+			return
+		}
+		fmt.Fprintf(&b, "\n## %s\n\n| Token | Value |\n| --- | --- |\n", title)
+		for _, entry := range list {
+			fmt.Fprintf(&b, "| `%s` | `%s` |\n", entry.Name, entry.Value)
+		}
+	}
+	entries("Added", report.Added)
+	entries("Removed", report.Removed)
+
+	if len(report.Changed) > 0 {
+		b.WriteString("\n## Changed\n\n| Token | Before | After |\n| --- | --- | --- |\n")
+		for _, change := range report.Changed {
+			fmt.Fprintf(&b, "| `%s` | %s | %s |\n", change.Name, describeEntry(change.Old), describeEntry(change.New))
+		}
+	}
+	return b.String()
+}
+
+// describeEntry formats a changed token's state for the report table
+func describeEntry(entry tokens.SnapshotEntry) string {
+	s := fmt.Sprintf("`%s`", entry.Value)
+	if entry.Type != "" {
+		s += " (" + entry.Type + ")"
+	}
+	if entry.Deprecated {
+		s += " deprecated"
+	}
+	return s
+}
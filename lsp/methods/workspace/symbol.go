@@ -0,0 +1,90 @@
+package workspace
+
+import (
+	"cmp"
+	"slices"
+	"strings"
+	"unicode"
+
+	"bennypowers.dev/dtls/internal/tokens"
+	"bennypowers.dev/dtls/lsp/methods/textDocument/definition"
+	"bennypowers.dev/dtls/lsp/types"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+// Symbol handles the workspace/symbol request, listing the loaded design
+// tokens which match the query, so editors' symbol search can jump to their
+// definitions in the token files. Symbols are named by CSS variable name, and
+// their container names give the token's type and value.
+func Symbol(req *types.RequestContext, params *protocol.WorkspaceSymbolParams) ([]protocol.SymbolInformation, error) {
+	manager := req.Server.TokenManager()
+	query := symbolKey(params.Query)
+	locate := definition.TokenLocator(req)
+
+	symbols := []protocol.SymbolInformation{}
+	for _, token := range manager.GetAll() {
+		name := manager.CSSVariableName(token)
+		if !isSubsequence(query, symbolKey(name)) && !isSubsequence(query, symbolKey(strings.Join(token.Path, "."))) {
+			continue
+		}
+		location := locate(token)
+		if location == nil {
+			continue
+		}
+		symbol := protocol.SymbolInformation{
+			Name:     name,
+			Kind:     protocol.SymbolKindVariable,
+			Location: *location,
+		}
+		if detail := symbolDetail(token); detail != "" {
+			symbol.ContainerName = &detail
+		}
+		if token.Deprecated {
+			symbol.Tags = []protocol.SymbolTag{protocol.SymbolTagDeprecated}
+		}
+		symbols = append(symbols, symbol)
+	}
+
+	slices.SortFunc(symbols, func(a, b protocol.SymbolInformation) int {
+		return cmp.Or(
+			strings.Compare(a.Name, b.Name),
+			strings.Compare(a.Location.URI, b.Location.URI),
+		)
+	})
+	return symbols, nil
+}
+
+// symbolDetail describes a token for symbol search, e.g. "color: #ff0000"
+func symbolDetail(token *tokens.Token) string {
+	if token.Type == "" {
+		return token.Value
+	}
+	if token.Value == "" {
+		return token.Type
+	}
+	return token.Type + ": " + token.Value
+}
+
+// symbolKey normalizes a query or token name for matching, so that
+// "colorprim", "color.prim" and "--color-prim" all match --color-primary
+func symbolKey(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '-' || r == '.' || r == '_' || unicode.IsSpace(r) {
+			return -1
+		}
+		return unicode.ToLower(r)
+	}, s)
+}
+
+// isSubsequence reports whether the runes of query appear in s in order,
+// as editors' fuzzy symbol search expects
+func isSubsequence(query, s string) bool {
+	for _, r := range query {
+		i := strings.IndexRune(s, r)
+		if i < 0 {
+			return false
+		}
+		s = s[i+len(string(r)):]
+	}
+	return true
+}
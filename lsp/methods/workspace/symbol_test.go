@@ -0,0 +1,84 @@
+package workspace
+
+import (
+	"testing"
+
+	"bennypowers.dev/dtls/internal/tokens"
+	"bennypowers.dev/dtls/lsp/testutil"
+	"bennypowers.dev/dtls/lsp/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tliron/glsp"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+func TestSymbol(t *testing.T) {
+	server := testutil.NewMockServerContext()
+	uri := "file:///tokens.json"
+	content := `{
+  "color": {
+    "primary": { "$type": "color", "$value": "#ff0000" },
+    "old": { "$type": "color", "$value": "#00f", "$deprecated": true }
+  },
+  "space": { "sm": { "$value": "4px" } }
+}`
+	require.NoError(t, server.DocumentManager().DidOpen(uri, "json", 1, content))
+	for _, token := range []*tokens.Token{
+		{Name: "color-primary", Path: []string{"color", "primary"}, Type: "color", Value: "#ff0000", DefinitionURI: uri, Line: 2, Character: 5},
+		{Name: "color-old", Path: []string{"color", "old"}, Type: "color", Value: "#00f", Deprecated: true, DefinitionURI: uri, Line: 3, Character: 5},
+		{Name: "space-sm", Path: []string{"space", "sm"}, Value: "4px", DefinitionURI: uri, Line: 5, Character: 13},
+		// Tokens without a definition in a token file can't be jumped to
+		{Name: "inline", Value: "1px"},
+	} {
+		require.NoError(t, server.TokenManager().Add(token))
+	}
+	req := types.NewRequestContext(server, &glsp.Context{})
+
+	names := func(symbols []protocol.SymbolInformation) []string {
+		var names []string
+		for _, symbol := range symbols {
+			names = append(names, symbol.Name)
+		}
+		return names
+	}
+
+	t.Run("lists every token for an empty query", func(t *testing.T) {
+		symbols, err := Symbol(req, &protocol.WorkspaceSymbolParams{})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"--color-old", "--color-primary", "--space-sm"}, names(symbols))
+	})
+
+	t.Run("matches names and paths fuzzily", func(t *testing.T) {
+		for _, query := range []string{"colprim", "color.primary", "--color-pri", "PRIMARY"} {
+			symbols, err := Symbol(req, &protocol.WorkspaceSymbolParams{Query: query})
+			require.NoError(t, err)
+			assert.Equal(t, []string{"--color-primary"}, names(symbols), query)
+		}
+	})
+
+	t.Run("details the type and value", func(t *testing.T) {
+		symbols, err := Symbol(req, &protocol.WorkspaceSymbolParams{Query: "color"})
+		require.NoError(t, err)
+		require.Len(t, symbols, 2)
+
+		old, primary := symbols[0], symbols[1]
+		assert.Equal(t, protocol.SymbolKindVariable, primary.Kind)
+		require.NotNil(t, primary.ContainerName)
+		assert.Equal(t, "color: #ff0000", *primary.ContainerName)
+		assert.Equal(t, protocol.Location{
+			URI: uri,
+			Range: protocol.Range{
+				Start: protocol.Position{Line: 2, Character: 5},
+				End:   protocol.Position{Line: 2, Character: 18},
+			},
+		}, primary.Location)
+		assert.Equal(t, []protocol.SymbolTag{protocol.SymbolTagDeprecated}, old.Tags)
+	})
+
+	t.Run("details the value of untyped tokens", func(t *testing.T) {
+		symbols, err := Symbol(req, &protocol.WorkspaceSymbolParams{Query: "space"})
+		require.NoError(t, err)
+		require.Len(t, symbols, 1)
+		assert.Equal(t, "4px", *symbols[0].ContainerName)
+	})
+}
@@ -0,0 +1,110 @@
+package workspace
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"bennypowers.dev/dtls/internal/parser"
+	"bennypowers.dev/dtls/internal/uriutil"
+	"bennypowers.dev/dtls/lsp/types"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+// SyntaxReportCommand lists the CSS in open documents that the server could
+// not analyze, so that missing hovers or diagnostics there read as
+// "unsupported" rather than "fine". It returns a SyntaxReport.
+const SyntaxReportCommand = "designTokensLanguageServer.syntaxReport"
+
+// UnsupportedSyntax is one line of CSS the server could not analyze
+type UnsupportedSyntax struct {
+	Range protocol.Range `json:"range"`
+
+	// Text is the trimmed source line
+	Text string `json:"text"`
+}
+
+// SyntaxReportFile lists the unsupported syntax in one document
+type SyntaxReportFile struct {
+	URI         string              `json:"uri"`
+	Unsupported []UnsupportedSyntax `json:"unsupported"`
+}
+
+// SyntaxReport lists the unsupported syntax in open documents, in URI order.
+// Documents the server analyzed completely are left out.
+type SyntaxReport struct {
+	Files []SyntaxReportFile `json:"files"`
+
+	// Checked is the number of documents checked
+	Checked int `json:"checked"`
+
+	// Summary is a human-readable summary, e.g. "3 unsupported lines in 2 of 5 files"
+	Summary string `json:"summary"`
+
+	// Markdown renders the report for display
+	Markdown string `json:"markdown"`
+}
+
+// syntaxReport checks every open stylesheet and document with embedded CSS.
+// Clients that support window/showDocument are also shown the report.
+func syntaxReport(req *types.RequestContext) *SyntaxReport {
+	report := &SyntaxReport{Files: []SyntaxReportFile{}}
+	count := 0
+	for _, doc := range req.Server.AllDocuments() {
+		if !parser.IsCSSSupportedLanguage(doc.LanguageID()) {
+			continue
+		}
+		result, err := parser.ParseCSSFromDocument(doc.Content(), doc.LanguageID())
+		if err != nil || result == nil {
+			continue
+		}
+		report.Checked++
+		if len(result.Unsupported) == 0 {
+			continue
+		}
+
+		file := SyntaxReportFile{URI: doc.URI()}
+		for _, u := range result.Unsupported {
+			file.Unsupported = append(file.Unsupported, UnsupportedSyntax{
+				Range: protocol.Range{
+					Start: protocol.Position{Line: u.Range.Start.Line, Character: u.Range.Start.Character},
+					End:   protocol.Position{Line: u.Range.End.Line, Character: u.Range.End.Character},
+				},
+				Text: u.Text,
+			})
+		}
+		count += len(file.Unsupported)
+		report.Files = append(report.Files, file)
+	}
+	slices.SortFunc(report.Files, func(a, b SyntaxReportFile) int {
+		return strings.Compare(a.URI, b.URI)
+	})
+
+	report.Summary = fmt.Sprintf("%d unsupported lines in %d of %d files", count, len(report.Files), report.Checked)
+	report.Markdown = syntaxReportMarkdown(req, report)
+
+	if supportsShowDocument(req.Server.ClientCapabilities()) && req.GLSP != nil && req.GLSP.Call != nil {
+		showPreview(req, SyntaxReportCommand, report.Markdown)
+	}
+	return report
+}
+
+// syntaxReportMarkdown renders a syntax report for display
+func syntaxReportMarkdown(req *types.RequestContext, report *SyntaxReport) string {
+	var b strings.Builder
+	b.WriteString("# Unsupported syntax\n\n")
+	fmt.Fprintf(&b, "%s\n", report.Summary)
+	if len(report.Files) == 0 {
+		b.WriteString("\nThe server analyzed all CSS in the open documents.\n")
+		return b.String()
+	}
+
+	b.WriteString("\nToken features may be missing or wrong on these lines.\n")
+	for _, file := range report.Files {
+		fmt.Fprintf(&b, "\n## %s\n\n| Line | Source |\n| --- | --- |\n", relativePath(req, uriutil.URIToPath(file.URI)))
+		for _, u := range file.Unsupported {
+			fmt.Fprintf(&b, "| %d | `%s` |\n", u.Range.Start.Line+1, strings.ReplaceAll(u.Text, "|", `\|`))
+		}
+	}
+	return b.String()
+}
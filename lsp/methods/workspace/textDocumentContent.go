@@ -0,0 +1,64 @@
+package workspace
+
+import (
+	"fmt"
+
+	"bennypowers.dev/dtls/internal/log"
+	designtokens "bennypowers.dev/dtls/lsp/methods/designTokens"
+	"bennypowers.dev/dtls/lsp/types"
+	"github.com/tliron/glsp"
+)
+
+// LSP 3.18 virtual documents: the server advertises the URI schemes it serves
+// and clients request their content with workspace/textDocumentContent.
+const (
+	TextDocumentContentMethod        = "workspace/textDocumentContent"
+	TextDocumentContentRefreshMethod = "workspace/textDocumentContent/refresh"
+
+	// VirtualDocumentScheme is the URI scheme of documents generated by the server
+	VirtualDocumentScheme = "dtls"
+)
+
+// RefreshStatsCommand asks the client to reload the dtls://stats dashboard.
+// It also returns the dashboard, for clients without virtual document support.
+const RefreshStatsCommand = "designTokensLanguageServer.refreshStats"
+
+// TextDocumentContentParams are the params of a workspace/textDocumentContent request
+type TextDocumentContentParams struct {
+	URI string `json:"uri"`
+}
+
+// TextDocumentContentResult is the content of a virtual document
+type TextDocumentContentResult struct {
+	Text string `json:"text"`
+}
+
+// textDocumentContentRefreshParams are the params of a
+// workspace/textDocumentContent/refresh request
+type textDocumentContentRefreshParams struct {
+	URI string `json:"uri"`
+}
+
+// TextDocumentContent handles the workspace/textDocumentContent request
+func TextDocumentContent(req *types.RequestContext, params *TextDocumentContentParams) (*TextDocumentContentResult, error) {
+	switch params.URI {
+	case designtokens.StatsURI:
+		return &TextDocumentContentResult{Text: designtokens.StatsMarkdown(req)}, nil
+	default:
+		return nil, fmt.Errorf("unknown virtual document: %s", params.URI)
+	}
+}
+
+// refreshStats regenerates the stats dashboard and tells the client to reload it
+func refreshStats(req *types.RequestContext) string {
+	if req.GLSP != nil && req.GLSP.Call != nil {
+		// A request to the client: send it from a goroutine so the message
+		// handler loop can read the response
+		go func(ctx *glsp.Context) {
+			var result any
+			ctx.Call(TextDocumentContentRefreshMethod, textDocumentContentRefreshParams{URI: designtokens.StatsURI}, &result)
+			log.Info("Requested refresh of %s", designtokens.StatsURI)
+		}(req.GLSP)
+	}
+	return designtokens.StatsMarkdown(req)
+}
@@ -0,0 +1,42 @@
+package workspace
+
+import (
+	"testing"
+
+	"bennypowers.dev/dtls/internal/tokens"
+	designtokens "bennypowers.dev/dtls/lsp/methods/designTokens"
+	"bennypowers.dev/dtls/lsp/testutil"
+	"bennypowers.dev/dtls/lsp/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tliron/glsp"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+func TestTextDocumentContent_Stats(t *testing.T) {
+	ctx := testutil.NewMockServerContext()
+	req := types.NewRequestContext(ctx, &glsp.Context{})
+	require.NoError(t, ctx.TokenManager().Add(&tokens.Token{Name: "color-primary", Value: "#0000ff", Type: "color"}))
+
+	result, err := TextDocumentContent(req, &TextDocumentContentParams{URI: designtokens.StatsURI})
+	require.NoError(t, err)
+	assert.Contains(t, result.Text, "# Design Token Statistics")
+	assert.Contains(t, result.Text, "`--color-primary`")
+
+	t.Run("unknown virtual document", func(t *testing.T) {
+		_, err := TextDocumentContent(req, &TextDocumentContentParams{URI: "dtls://nope"})
+		assert.Error(t, err)
+	})
+}
+
+func TestExecuteCommand_RefreshStats(t *testing.T) {
+	ctx := testutil.NewMockServerContext()
+	req := types.NewRequestContext(ctx, &glsp.Context{})
+
+	result, err := ExecuteCommand(req, &protocol.ExecuteCommandParams{Command: RefreshStatsCommand})
+	require.NoError(t, err)
+
+	markdown, ok := result.(string)
+	require.True(t, ok)
+	assert.Contains(t, markdown, "# Design Token Statistics")
+}
@@ -0,0 +1,265 @@
+package workspace
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"bennypowers.dev/dtls/internal/log"
+	"bennypowers.dev/dtls/internal/parser"
+	"bennypowers.dev/dtls/internal/parser/tokenfile"
+	"bennypowers.dev/dtls/internal/tokens"
+	"bennypowers.dev/dtls/internal/uriutil"
+	"bennypowers.dev/dtls/lsp/helpers/css"
+	"bennypowers.dev/dtls/lsp/types"
+	"github.com/tliron/glsp"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+	"gopkg.in/yaml.v3"
+)
+
+// SyncThemeCommand compares a theme CSS file's custom properties with the
+// tokens they declare, and updates one side to match the other. Its optional
+// arguments are the theme file's URI, defaulting to the themeFile setting,
+// and a direction, ThemeFromTokens or TokensFromTheme. Without a direction,
+// the user chooses one through window/showMessageRequest. It returns a
+// ThemeSyncReport.
+const SyncThemeCommand = "designTokensLanguageServer.syncTheme"
+
+const (
+	// ThemeFromTokens updates the theme CSS from the token files
+	ThemeFromTokens = "css"
+
+	// TokensFromTheme updates the token files from the theme CSS
+	TokensFromTheme = "tokens"
+)
+
+// ThemeDifference is a theme declaration whose value differs from its token
+type ThemeDifference struct {
+	Name string `json:"name"`
+
+	// Line is the declaration's 0-based line in the theme file
+	Line uint32 `json:"line"`
+
+	// Declared is the value in the theme file
+	Declared string `json:"declared"`
+
+	// Expected is the token's value
+	Expected string `json:"expected"`
+
+	// TokenFile is the file defining the token
+	TokenFile string `json:"tokenFile"`
+}
+
+// ThemeSyncReport lists the differences between a theme file and the tokens
+type ThemeSyncReport struct {
+	File        string            `json:"file"`
+	Differences []ThemeDifference `json:"differences"`
+
+	// Skipped names the tokens the theme can't update, because their token
+	// file value is an alias, expression or structured value
+	Skipped []string `json:"skipped"`
+
+	// Summary is a human-readable summary, e.g. "3 properties differ"
+	Summary string `json:"summary"`
+
+	// Edit holds the edits for the requested direction, if one was passed
+	Edit *protocol.WorkspaceEdit `json:"edit,omitempty"`
+
+	// themeEdit and tokensEdit are the edits for each direction
+	themeEdit, tokensEdit *protocol.WorkspaceEdit
+}
+
+// syncTheme diffs the theme file against the tokens, then applies the
+// edits for the requested or chosen direction
+func syncTheme(req *types.RequestContext, params *protocol.ExecuteCommandParams) (*ThemeSyncReport, error) {
+	path, direction, err := syncThemeArguments(req, params.Arguments)
+	if err != nil {
+		return nil, err
+	}
+
+	report, err := diffTheme(req, path)
+	if err != nil {
+		return nil, err
+	}
+	if len(report.Differences) == 0 {
+		return report, nil
+	}
+
+	canApply := req.Server.SupportsApplyEdit() && req.GLSP != nil && req.GLSP.Call != nil
+	if direction != "" {
+		report.Edit = report.edit(direction)
+		if canApply {
+			go applyWorkspaceEdit(req.GLSP, "Sync theme", report.Edit)
+		}
+		return report, nil
+	}
+
+	if canApply {
+		themeAction := protocol.MessageActionItem{Title: req.Localize("Update CSS from tokens")}
+		tokensAction := protocol.MessageActionItem{Title: req.Localize("Update tokens from CSS")}
+		message := protocol.ShowMessageRequestParams{
+			Type:    protocol.MessageTypeInfo,
+			Message: req.Localize("%s differs from the tokens: %s", filepath.Base(path), report.Summary),
+			Actions: []protocol.MessageActionItem{themeAction, tokensAction},
+		}
+		// A request to the client: send it from a goroutine so the message
+		// handler loop can read the response
+		go func(ctx *glsp.Context) {
+			var choice *protocol.MessageActionItem
+			ctx.Call(protocol.ServerWindowShowMessageRequest, message, &choice)
+			switch {
+			case choice == nil:
+				return
+			case choice.Title == themeAction.Title:
+				applyWorkspaceEdit(ctx, "Sync theme", report.edit(ThemeFromTokens))
+			case choice.Title == tokensAction.Title:
+				applyWorkspaceEdit(ctx, "Sync theme", report.edit(TokensFromTheme))
+			}
+		}(req.GLSP)
+	}
+	return report, nil
+}
+
+// syncThemeArguments reads the theme file path and direction arguments
+func syncThemeArguments(req *types.RequestContext, args []any) (path, direction string, err error) {
+	if len(args) > 0 && args[0] != nil {
+		uri, ok := args[0].(string)
+		if !ok {
+			return "", "", fmt.Errorf("%s expects a theme file URI argument, got %T", SyncThemeCommand, args[0])
+		}
+		path = uriutil.URIToPath(uri)
+	} else if path = req.Config().ThemeFile; path == "" {
+		return "", "", errors.New("no theme file to sync: pass a CSS file URI or configure themeFile")
+	} else if root := req.Server.RootPath(); root != "" && !filepath.IsAbs(path) {
+		path = filepath.Join(root, path)
+	}
+
+	if len(args) > 1 {
+		direction, _ = args[1].(string)
+		if direction != ThemeFromTokens && direction != TokensFromTheme {
+			return "", "", fmt.Errorf("%s direction must be %q or %q, got %v", SyncThemeCommand, ThemeFromTokens, TokensFromTheme, args[1])
+		}
+	}
+	return filepath.Clean(path), direction, nil
+}
+
+// diffTheme compares the theme file's declarations with their tokens, and
+// prepares the edits for both directions
+func diffTheme(req *types.RequestContext, path string) (*ThemeSyncReport, error) {
+	content, err := generatedContent(req, path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read theme file %s: %w", path, err)
+	}
+	result, err := parser.ParseCSSFromDocument(content, "css")
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse theme file %s: %w", path, err)
+	}
+	if result == nil {
+		return nil, fmt.Errorf("no declarations in theme file %s", path)
+	}
+
+	report := &ThemeSyncReport{
+		File:        path,
+		Differences: []ThemeDifference{},
+		Skipped:     []string{},
+		themeEdit:   &protocol.WorkspaceEdit{Changes: map[string][]protocol.TextEdit{}},
+		tokensEdit:  &protocol.WorkspaceEdit{Changes: map[string][]protocol.TextEdit{}},
+	}
+	themeURI := uriutil.PathToURI(path)
+	for _, v := range result.Variables {
+		token := req.Server.Token(v.Name)
+		declared := css.RangeText(content, v.ValueRange)
+		expected, stale := css.StaleCustomPropertyValue(token, path, declared)
+		if !stale {
+			continue
+		}
+
+		report.Differences = append(report.Differences, ThemeDifference{
+			Name:      v.Name,
+			Line:      v.Range.Start.Line,
+			Declared:  declared,
+			Expected:  expected,
+			TokenFile: token.FilePath,
+		})
+		report.themeEdit.Changes[themeURI] = append(report.themeEdit.Changes[themeURI], protocol.TextEdit{
+			Range: protocol.Range{
+				Start: protocol.Position{Line: v.ValueRange.Start.Line, Character: v.ValueRange.Start.Character},
+				End:   protocol.Position{Line: v.ValueRange.End.Line, Character: v.ValueRange.End.Character},
+			},
+			NewText: expected,
+		})
+
+		uri, edit, ok := tokenValueEdit(req, token, declared)
+		if !ok {
+			report.Skipped = append(report.Skipped, v.Name)
+			continue
+		}
+		report.tokensEdit.Changes[uri] = append(report.tokensEdit.Changes[uri], edit)
+	}
+
+	report.Summary = fmt.Sprintf("%d properties differ", len(report.Differences))
+	if len(report.Skipped) > 0 {
+		report.Summary += fmt.Sprintf(", %d can only be updated in CSS", len(report.Skipped))
+	}
+	return report, nil
+}
+
+// edit returns the edits for a direction
+func (r *ThemeSyncReport) edit(direction string) *protocol.WorkspaceEdit {
+	if direction == TokensFromTheme {
+		return r.tokensEdit
+	}
+	return r.themeEdit
+}
+
+// applyWorkspaceEdit asks the client to apply an edit. Call it from a
+// goroutine, not the message handler loop, which must read the response.
+func applyWorkspaceEdit(ctx *glsp.Context, label string, edit *protocol.WorkspaceEdit) {
+	var result protocol.ApplyWorkspaceEditResponse
+	ctx.Call(protocol.ServerWorkspaceApplyEdit, protocol.ApplyWorkspaceEditParams{Label: &label, Edit: *edit}, &result)
+	if !result.Applied {
+		reason := "no reason given"
+		if result.FailureReason != nil {
+			reason = *result.FailureReason
+		}
+		log.Warn("Client did not apply %q edit: %s", label, reason)
+	}
+}
+
+// tokenValueEdit returns an edit setting a token's $value in its token file
+// to value. Only literal scalar values are replaced; aliases, expressions
+// and structured values are not.
+func tokenValueEdit(req *types.RequestContext, token *tokens.Token, value string) (string, protocol.TextEdit, bool) {
+	uri := token.DefinitionURI
+	if uri == "" {
+		uri = uriutil.PathToURI(token.FilePath)
+	}
+
+	var content string
+	if doc := req.Server.Document(uri); doc != nil {
+		content = doc.Content()
+	} else {
+		data, err := os.ReadFile(token.FilePath) //nolint:gosec // G304: Token file paths come from user configuration
+		if err != nil {
+			return "", protocol.TextEdit{}, false
+		}
+		content = string(data)
+	}
+
+	isJSON := !strings.HasSuffix(token.FilePath, ".yaml") && !strings.HasSuffix(token.FilePath, ".yml")
+	root := tokenfile.Outline(content, isJSON)
+	if root == nil {
+		return "", protocol.TextEdit{}, false
+	}
+	node := tokenfile.ValueNode(root, token.Path)
+	if node == nil || node.Kind != yaml.ScalarNode || strings.Contains(node.Value, "{") {
+		return "", protocol.TextEdit{}, false
+	}
+
+	return uri, protocol.TextEdit{
+		Range:   tokenfile.ScalarTextRange(content, node),
+		NewText: tokenfile.ScalarText(node, value, isJSON),
+	}, true
+}
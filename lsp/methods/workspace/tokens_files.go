@@ -0,0 +1,173 @@
+package workspace
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"bennypowers.dev/dtls/lsp/types"
+	"github.com/bmatcuk/doublestar/v4"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+// CheckTokensFilesCommand expands the configured tokensFiles and reports the
+// files each entry matches, the tokens loaded from them and whether the
+// client watches them, to explain tokens that don't load. It returns a
+// TokensFilesReport.
+const CheckTokensFilesCommand = "designTokensLanguageServer.checkTokensFiles"
+
+// TokensFileMatch is a file matched by a tokensFiles entry
+type TokensFileMatch struct {
+	Path string `json:"path"`
+
+	// Tokens is the number of tokens loaded from the file
+	Tokens int `json:"tokens"`
+
+	// Watched is true when a registered file watcher covers the file, so
+	// changes made outside the editor reload it
+	Watched bool `json:"watched"`
+}
+
+// TokensFilesEntry is one configured tokensFiles entry and what it matches
+type TokensFilesEntry struct {
+	// Path is the entry's path or glob pattern, as configured
+	Path string `json:"path"`
+
+	Files []TokensFileMatch `json:"files"`
+
+	// Error explains why the path could not be resolved
+	Error string `json:"error,omitempty"`
+}
+
+// TokensFilesReport lists the configured tokensFiles entries, in
+// configuration order, with the file watcher registration
+type TokensFilesReport struct {
+	Entries []TokensFilesEntry `json:"entries"`
+
+	// WatchedPatterns are the glob patterns registered with the client's
+	// file watcher
+	WatchedPatterns []string `json:"watchedPatterns"`
+
+	// WatchingSupported is false when the client can't register file
+	// watchers, so changes made outside the editor are missed
+	WatchingSupported bool `json:"watchingSupported"`
+
+	// Summary is a human-readable summary, e.g. "3 files with 120 tokens from 2 entries"
+	Summary string `json:"summary"`
+
+	// Markdown renders the report for display
+	Markdown string `json:"markdown"`
+}
+
+// checkTokensFiles checks every configured tokensFiles entry.
+// Clients that support window/showDocument are also shown the report.
+func checkTokensFiles(req *types.RequestContext) (*TokensFilesReport, error) {
+	config := req.Config()
+	if len(config.TokensFiles) == 0 {
+		return nil, errors.New("no token files to check: configure tokensFiles")
+	}
+
+	report := &TokensFilesReport{
+		Entries:           []TokensFilesEntry{},
+		WatchedPatterns:   req.Server.WatchedFilePatterns(),
+		WatchingSupported: supportsFileWatchers(req.Server.ClientCapabilities()),
+	}
+	if report.WatchedPatterns == nil {
+		report.WatchedPatterns = []string{}
+	}
+
+	files, tokens := 0, 0
+	for _, item := range config.TokensFiles {
+		path := tokensFilePath(item)
+		if path == "" {
+			continue
+		}
+		entry := TokensFilesEntry{Path: path, Files: []TokensFileMatch{}}
+		matches, err := req.Server.TokensFileMatches(path)
+		if err != nil {
+			entry.Error = err.Error()
+		}
+		for _, match := range matches {
+			file := TokensFileMatch{
+				Path:    match,
+				Tokens:  len(req.Server.TokenManager().GetBySourceFile(match)),
+				Watched: watched(report.WatchedPatterns, match),
+			}
+			tokens += file.Tokens
+			entry.Files = append(entry.Files, file)
+		}
+		files += len(entry.Files)
+		report.Entries = append(report.Entries, entry)
+	}
+
+	report.Summary = fmt.Sprintf("%d files with %d tokens from %d entries", files, tokens, len(report.Entries))
+	report.Markdown = tokensFilesMarkdown(req, report)
+
+	if supportsShowDocument(req.Server.ClientCapabilities()) && req.GLSP != nil && req.GLSP.Call != nil {
+		showPreview(req, CheckTokensFilesCommand, report.Markdown)
+	}
+	return report, nil
+}
+
+// tokensFilePath returns the path of a tokensFiles entry, which is either a
+// path or an object with a path
+func tokensFilePath(item any) string {
+	switch v := item.(type) {
+	case string:
+		return v
+	case map[string]any:
+		path, _ := v["path"].(string)
+		return path
+	}
+	return ""
+}
+
+// watched reports whether any of the file watcher's patterns matches path
+func watched(patterns []string, path string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := doublestar.Match(pattern, filepath.ToSlash(path)); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// supportsFileWatchers reports whether the client can register
+// workspace/didChangeWatchedFiles watchers
+func supportsFileWatchers(caps *protocol.ClientCapabilities) bool {
+	return caps != nil && caps.Workspace != nil && caps.Workspace.DidChangeWatchedFiles != nil &&
+		caps.Workspace.DidChangeWatchedFiles.DynamicRegistration != nil && *caps.Workspace.DidChangeWatchedFiles.DynamicRegistration
+}
+
+// tokensFilesMarkdown renders a tokensFiles report for display
+func tokensFilesMarkdown(req *types.RequestContext, report *TokensFilesReport) string {
+	var b strings.Builder
+	b.WriteString("# Token files\n\n")
+	fmt.Fprintf(&b, "%s\n", report.Summary)
+	if !report.WatchingSupported {
+		b.WriteString("\nThe editor does not support file watchers, so changes to token files made outside it are not reloaded.\n")
+	} else if len(report.WatchedPatterns) == 0 {
+		b.WriteString("\nNo file watchers are registered, so changes to token files made outside the editor are not reloaded.\n")
+	}
+
+	for _, entry := range report.Entries {
+		fmt.Fprintf(&b, "\n## `%s`\n\n", entry.Path)
+		switch {
+		case entry.Error != "":
+			fmt.Fprintf(&b, "Could not resolve this path: %s\n", entry.Error)
+		case len(entry.Files) == 0:
+			b.WriteString("Matches no files. Relative paths resolve against the workspace root.\n")
+		default:
+			b.WriteString("| File | Tokens | Watched |\n| --- | --- | --- |\n")
+			for _, file := range entry.Files {
+				watchedMark := "no"
+				if file.Watched {
+					watchedMark = "yes"
+				}
+				fmt.Fprintf(&b, "| %s | %d | %s |\n", relativePath(req, file.Path), file.Tokens, watchedMark)
+			}
+		}
+	}
+	return b.String()
+}
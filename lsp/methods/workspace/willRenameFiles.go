@@ -0,0 +1,176 @@
+package workspace
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"bennypowers.dev/dtls/internal/log"
+	"bennypowers.dev/dtls/internal/position"
+	"bennypowers.dev/dtls/internal/uriutil"
+	"bennypowers.dev/dtls/lsp/helpers"
+	"bennypowers.dev/dtls/lsp/types"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+// configKey is the package.json key holding the server configuration
+const configKey = `"designTokensLanguageServer"`
+
+var (
+	fileOperationKindFile   = protocol.FileOperationPatternKindFile
+	fileOperationKindFolder = protocol.FileOperationPatternKindFolder
+)
+
+// TokenFileOperationFilters selects the file operations the server wants to
+// hear about: token files themselves, and folders which might contain them
+var TokenFileOperationFilters = []protocol.FileOperationFilter{
+	{Pattern: protocol.FileOperationPattern{Glob: "**/*.{json,yaml,yml}", Matches: &fileOperationKindFile}},
+	{Pattern: protocol.FileOperationPattern{Glob: "**", Matches: &fileOperationKindFolder}},
+}
+
+// WillRenameFiles handles the workspace/willRenameFiles request.
+// It returns edits retargeting tokensFiles entries in package.json, and
+// remaps loaded token files so features keep working without a reload.
+func WillRenameFiles(req *types.RequestContext, params *protocol.RenameFilesParams) (*protocol.WorkspaceEdit, error) {
+	rootPath := req.Server.RootPath()
+
+	// Configured paths as written, mapped to their renamed form.
+	// Computed before remapping, which rewrites the in-memory configuration.
+	replacements := map[string]string{}
+	for _, rename := range params.Files {
+		oldPath := uriutil.URIToPath(rename.OldURI)
+		newPath := uriutil.URIToPath(rename.NewURI)
+		for _, configured := range configuredTokenPaths(req.Config()) {
+			if renamed, ok := helpers.RenameConfigPath(configured, rootPath, oldPath, newPath); ok {
+				replacements[configured] = renamed
+			}
+		}
+		if req.Server.RenameTokenFile(oldPath, newPath) {
+			log.Info("Retargeted token files for rename %s -> %s", oldPath, newPath)
+		}
+	}
+
+	if rootPath == "" || len(replacements) == 0 {
+		return nil, nil
+	}
+
+	pkgPath := filepath.Join(rootPath, "package.json")
+	pkgURI := uriutil.PathToURI(pkgPath)
+	content, ok := packageJSONContent(req, pkgURI, pkgPath)
+	if !ok {
+		return nil, nil
+	}
+
+	edits := packageJSONRenameEdits(content, replacements)
+	if len(edits) == 0 {
+		return nil, nil
+	}
+	edits, err := helpers.PrepareEdits(edits)
+	if err == nil {
+		err = helpers.CheckEditBounds(content, edits)
+	}
+	if err != nil {
+		log.Error("Cannot retarget package.json for rename: %v", err)
+		return nil, nil
+	}
+
+	return &protocol.WorkspaceEdit{
+		Changes: map[protocol.DocumentUri][]protocol.TextEdit{pkgURI: edits},
+	}, nil
+}
+
+// configuredTokenPaths returns the paths of tokensFiles entries, as written
+func configuredTokenPaths(cfg types.ServerConfig) []string {
+	var paths []string
+	for _, item := range cfg.TokensFiles {
+		switch v := item.(type) {
+		case string:
+			paths = append(paths, v)
+		case map[string]any:
+			if path, ok := v["path"].(string); ok {
+				paths = append(paths, path)
+			}
+		}
+	}
+	return paths
+}
+
+// packageJSONContent prefers the open document's content over the file on disk
+func packageJSONContent(req *types.RequestContext, uri, path string) (string, bool) {
+	if doc := req.Server.Document(uri); doc != nil {
+		return doc.Content(), true
+	}
+	data, err := os.ReadFile(path) //nolint:gosec // G304: Reading workspace package.json - local trusted environment
+	if err != nil {
+		return "", false
+	}
+	return string(data), true
+}
+
+// packageJSONRenameEdits replaces string literals inside the
+// designTokensLanguageServer object whose values are keys of replacements
+func packageJSONRenameEdits(content string, replacements map[string]string) []protocol.TextEdit {
+	start := strings.Index(content, configKey)
+	if start < 0 {
+		return nil
+	}
+
+	var edits []protocol.TextEdit
+	depth := 0
+	for i := start + len(configKey); i < len(content); i++ {
+		switch content[i] {
+		case '{', '[':
+			depth++
+		case '}', ']':
+			depth--
+			if depth == 0 {
+				return edits
+			}
+		case '"':
+			end := stringLiteralEnd(content, i)
+			if end < 0 {
+				return edits
+			}
+			var value string
+			if depth > 0 && json.Unmarshal([]byte(content[i:end]), &value) == nil {
+				if renamed, ok := replacements[value]; ok {
+					literal, _ := json.Marshal(renamed)
+					edits = append(edits, protocol.TextEdit{
+						Range: protocol.Range{
+							Start: offsetToPosition(content, i+1),
+							End:   offsetToPosition(content, end-1),
+						},
+						NewText: string(literal[1 : len(literal)-1]),
+					})
+				}
+			}
+			i = end - 1
+		}
+	}
+	return edits
+}
+
+// stringLiteralEnd returns the offset just past the closing quote of the
+// JSON string literal opening at start, or -1 if it is unterminated
+func stringLiteralEnd(content string, start int) int {
+	for i := start + 1; i < len(content); i++ {
+		switch content[i] {
+		case '\\':
+			i++
+		case '"':
+			return i + 1
+		}
+	}
+	return -1
+}
+
+// offsetToPosition converts a byte offset to an LSP position (UTF-16 columns)
+func offsetToPosition(content string, offset int) protocol.Position {
+	lineStart := strings.LastIndexByte(content[:offset], '\n') + 1
+	line := strings.Count(content[:lineStart], "\n")
+	return protocol.Position{
+		Line:      uint32(line), //nolint:gosec // G115: line count bounded by document size
+		Character: position.ByteOffsetToUTF16Uint32(content[lineStart:], offset-lineStart),
+	}
+}
@@ -0,0 +1,124 @@
+package workspace
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"bennypowers.dev/dtls/internal/documents"
+	"bennypowers.dev/dtls/internal/uriutil"
+	"bennypowers.dev/dtls/lsp/testutil"
+	"bennypowers.dev/dtls/lsp/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+const renamePackageJSON = `{
+  "name": "app",
+  "designTokensLanguageServer": {
+    "prefix": "ds",
+    "tokensFiles": [
+      "./tokens/color.json",
+      { "path": "tokens/space.json", "prefix": "sp" },
+      "npm:@acme/tokens/tokens.json"
+    ]
+  }
+}
+`
+
+func setupRenameWorkspace(t *testing.T) (*testutil.MockServerContext, *types.RequestContext, string) {
+	t.Helper()
+	root := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(root, "package.json"), []byte(renamePackageJSON), 0o600))
+
+	ctx := testutil.NewMockServerContext()
+	ctx.SetRootPath(root)
+	cfg := ctx.GetConfig()
+	cfg.TokensFiles = []any{
+		"./tokens/color.json",
+		map[string]any{"path": "tokens/space.json", "prefix": "sp"},
+		"npm:@acme/tokens/tokens.json",
+	}
+	ctx.SetConfig(cfg)
+	return ctx, types.NewRequestContext(ctx, nil), root
+}
+
+func TestWillRenameFiles_File(t *testing.T) {
+	ctx, req, root := setupRenameWorkspace(t)
+	oldPath := filepath.Join(root, "tokens", "color.json")
+	newPath := filepath.Join(root, "tokens", "colors.json")
+
+	edit, err := WillRenameFiles(req, &protocol.RenameFilesParams{
+		Files: []protocol.FileRename{{OldURI: uriutil.PathToURI(oldPath), NewURI: uriutil.PathToURI(newPath)}},
+	})
+	require.NoError(t, err)
+	require.NotNil(t, edit)
+
+	pkgURI := uriutil.PathToURI(filepath.Join(root, "package.json"))
+	assert.Equal(t, []protocol.TextEdit{{
+		Range: protocol.Range{
+			Start: protocol.Position{Line: 5, Character: 7},
+			End:   protocol.Position{Line: 5, Character: 26},
+		},
+		NewText: "./tokens/colors.json",
+	}}, edit.Changes[pkgURI])
+
+	assert.Equal(t, [][2]string{{oldPath, newPath}}, ctx.RenamedTokenFiles)
+}
+
+func TestWillRenameFiles_Folder(t *testing.T) {
+	_, req, root := setupRenameWorkspace(t)
+
+	edit, err := WillRenameFiles(req, &protocol.RenameFilesParams{
+		Files: []protocol.FileRename{{
+			OldURI: uriutil.PathToURI(filepath.Join(root, "tokens")),
+			NewURI: uriutil.PathToURI(filepath.Join(root, "design")),
+		}},
+	})
+	require.NoError(t, err)
+	require.NotNil(t, edit)
+
+	pkgURI := uriutil.PathToURI(filepath.Join(root, "package.json"))
+	edits := edit.Changes[pkgURI]
+	require.Len(t, edits, 2)
+	// Edits are returned last-first
+	assert.Equal(t, "design/space.json", edits[0].NewText)
+	assert.Equal(t, protocol.Position{Line: 6, Character: 17}, edits[0].Range.Start)
+	assert.Equal(t, "./design/color.json", edits[1].NewText)
+}
+
+func TestWillRenameFiles_OpenPackageJSON(t *testing.T) {
+	ctx, req, root := setupRenameWorkspace(t)
+	pkgURI := uriutil.PathToURI(filepath.Join(root, "package.json"))
+
+	// Unsaved edits in the open document take precedence over the file on disk
+	unsaved := `{"designTokensLanguageServer": {"tokensFiles": ["tokens/space.json"]}}`
+	ctx.AddDocument(documents.NewDocument(pkgURI, "json", 1, unsaved))
+
+	edit, err := WillRenameFiles(req, &protocol.RenameFilesParams{
+		Files: []protocol.FileRename{{
+			OldURI: uriutil.PathToURI(filepath.Join(root, "tokens", "space.json")),
+			NewURI: uriutil.PathToURI(filepath.Join(root, "tokens", "spacing.json")),
+		}},
+	})
+	require.NoError(t, err)
+	require.NotNil(t, edit)
+	require.Len(t, edit.Changes[pkgURI], 1)
+	assert.Equal(t, "tokens/spacing.json", edit.Changes[pkgURI][0].NewText)
+	assert.Equal(t, protocol.Position{Line: 0, Character: 49}, edit.Changes[pkgURI][0].Range.Start)
+}
+
+func TestWillRenameFiles_Unrelated(t *testing.T) {
+	ctx, req, root := setupRenameWorkspace(t)
+
+	edit, err := WillRenameFiles(req, &protocol.RenameFilesParams{
+		Files: []protocol.FileRename{{
+			OldURI: uriutil.PathToURI(filepath.Join(root, "src", "app.json")),
+			NewURI: uriutil.PathToURI(filepath.Join(root, "src", "main.json")),
+		}},
+	})
+	require.NoError(t, err)
+	assert.Nil(t, edit)
+	assert.Len(t, ctx.RenamedTokenFiles, 1)
+}
@@ -1,15 +1,59 @@
 package lsp
 
 import (
-	"bennypowers.dev/dtls/internal/log"
 	"fmt"
 	"runtime/debug"
+	"sync"
+	"time"
 
+	"bennypowers.dev/dtls/internal/log"
+	"bennypowers.dev/dtls/internal/metrics"
 	"bennypowers.dev/dtls/lsp/methods/workspace"
 	"bennypowers.dev/dtls/lsp/types"
 	"github.com/tliron/glsp"
+	protocol "github.com/tliron/glsp/protocol_3_16"
 )
 
+// InternalError is returned by the middleware when a handler panics.
+// The transport reports it to the client as a JSON-RPC InternalError (-32603)
+// instead of the generic request error used for ordinary handler failures.
+type InternalError struct {
+	Method string
+}
+
+func (e *InternalError) Error() string {
+	return fmt.Sprintf("internal error in %s", e.Method)
+}
+
+// panicNoticeInterval limits how often window/showMessage is sent for panics
+// in the same method, so a bug triggered on every keystroke doesn't flood the UI.
+const panicNoticeInterval = 30 * time.Second
+
+// lastPanicNotice tracks when a panic notice was last shown, per method
+var lastPanicNotice sync.Map // map[string]time.Time
+
+// handlePanic logs a recovered panic with its stack trace, reports it to the
+// client via window/logMessage and (rate-limited) window/showMessage, and
+// returns an *InternalError for the JSON-RPC response.
+func handlePanic(glspCtx *glsp.Context, methodName string, r any) error {
+	stackTrace := string(debug.Stack())
+	log.Error("PANIC in %s: %v\nStack trace:\n%s",
+		methodName, r, stackTrace)
+
+	// Log panic to LSP client
+	workspace.LogError(glspCtx, "Internal error in %s: %v", methodName, r)
+
+	now := time.Now()
+	if last, ok := lastPanicNotice.Load(methodName); !ok || now.Sub(last.(time.Time)) >= panicNoticeInterval {
+		lastPanicNotice.Store(methodName, now)
+		workspace.ShowMessage(glspCtx, protocol.MessageTypeError, fmt.Sprintf(
+			"Design Tokens Language Server: internal error in %s. The request was skipped; see the output log for details.",
+			methodName))
+	}
+
+	return &InternalError{Method: methodName}
+}
+
 // method wraps an LSP handler that returns (result, error) with middleware
 // Returns the underlying function type so it's compatible with protocol.Handler field types
 func method[P, R any](
@@ -18,15 +62,14 @@ func method[P, R any](
 	handler func(*types.RequestContext, P) (R, error),
 ) func(*glsp.Context, P) (R, error) {
 	return func(glspCtx *glsp.Context, params P) (result R, err error) {
+		// Deferred first so it runs last, after panic recovery has set err
+		start := time.Now()
+		defer func() { metrics.ObserveRequest(methodName, time.Since(start), err) }()
+
 		// Panic recovery - prevents LSP server crashes
 		defer func() {
 			if r := recover(); r != nil {
-				stackTrace := string(debug.Stack())
-				log.Error("PANIC in %s: %v\nStack trace:\n%s",
-					methodName, r, stackTrace)
-				// Log panic to LSP client
-				workspace.LogError(glspCtx, "Internal error in %s: %v", methodName, r)
-				err = fmt.Errorf("internal error in %s", methodName)
+				err = handlePanic(glspCtx, methodName, r)
 				var zero R
 				result = zero
 			}
@@ -40,12 +83,11 @@ func method[P, R any](
 
 		// Execute handler with request context
 		result, err = handler(req, params)
+		traceRequest(glspCtx, methodName, time.Since(start), req)
 
-		// Log warnings if operation succeeded
-		if err == nil && req.HasWarnings() {
-			for _, w := range req.Warnings() {
-				workspace.LogWarning(glspCtx, "%s warning: %v", methodName, w)
-			}
+		// Report warnings if operation succeeded
+		if err == nil {
+			reportWarnings(glspCtx, methodName, req)
 		}
 
 		// Error context wrapping
@@ -69,14 +111,12 @@ func notify[P any](
 	handler func(*types.RequestContext, P) error,
 ) func(*glsp.Context, P) error {
 	return func(glspCtx *glsp.Context, params P) (err error) {
+		start := time.Now()
+		defer func() { metrics.ObserveRequest(methodName, time.Since(start), err) }()
+
 		defer func() {
 			if r := recover(); r != nil {
-				stackTrace := string(debug.Stack())
-				log.Error("PANIC in %s: %v\nStack trace:\n%s",
-					methodName, r, stackTrace)
-				// Log panic to LSP client
-				workspace.LogError(glspCtx, "Internal error in %s: %v", methodName, r)
-				err = fmt.Errorf("internal error in %s", methodName)
+				err = handlePanic(glspCtx, methodName, r)
 			}
 		}()
 
@@ -87,12 +127,11 @@ func notify[P any](
 
 		// Execute handler
 		err = handler(req, params)
+		traceRequest(glspCtx, methodName, time.Since(start), req)
 
-		// Log warnings if operation succeeded
-		if err == nil && req.HasWarnings() {
-			for _, w := range req.Warnings() {
-				workspace.LogWarning(glspCtx, "%s warning: %v", methodName, w)
-			}
+		// Report warnings if operation succeeded
+		if err == nil {
+			reportWarnings(glspCtx, methodName, req)
 		}
 
 		if err != nil {
@@ -114,14 +153,12 @@ func noParam(
 	handler func(*types.RequestContext) error,
 ) func(*glsp.Context) error {
 	return func(glspCtx *glsp.Context) (err error) {
+		start := time.Now()
+		defer func() { metrics.ObserveRequest(methodName, time.Since(start), err) }()
+
 		defer func() {
 			if r := recover(); r != nil {
-				stackTrace := string(debug.Stack())
-				log.Error("PANIC in %s: %v\nStack trace:\n%s",
-					methodName, r, stackTrace)
-				// Log panic to LSP client
-				workspace.LogError(glspCtx, "Internal error in %s: %v", methodName, r)
-				err = fmt.Errorf("internal error in %s", methodName)
+				err = handlePanic(glspCtx, methodName, r)
 			}
 		}()
 
@@ -132,12 +169,11 @@ func noParam(
 
 		// Execute handler
 		err = handler(req)
+		traceRequest(glspCtx, methodName, time.Since(start), req)
 
-		// Log warnings if operation succeeded
-		if err == nil && req.HasWarnings() {
-			for _, w := range req.Warnings() {
-				workspace.LogWarning(glspCtx, "%s warning: %v", methodName, w)
-			}
+		// Report warnings if operation succeeded
+		if err == nil {
+			reportWarnings(glspCtx, methodName, req)
 		}
 
 		if err != nil {
@@ -6,8 +6,11 @@ import (
 	"testing"
 
 	"bennypowers.dev/dtls/internal/documents"
+	"bennypowers.dev/dtls/internal/gitblame"
 	"bennypowers.dev/dtls/internal/log"
 	"bennypowers.dev/dtls/internal/tokens"
+	"bennypowers.dev/dtls/internal/workspacefiles"
+	"bennypowers.dev/dtls/lsp/methods/textDocument/diagnostic/resultcache"
 	semantictokens "bennypowers.dev/dtls/lsp/methods/textDocument/semanticTokens"
 	"bennypowers.dev/dtls/lsp/types"
 	"github.com/stretchr/testify/assert"
@@ -24,6 +27,9 @@ type mockServerContext struct {
 	// cache holds a stable semantic token cache instance, lazily initialized on first access.
 	// This ensures consistent behavior across multiple SemanticTokenCache() calls.
 	cache types.SemanticTokenCacher
+
+	// diagnosticCache holds a stable diagnostic result cache, lazily initialized on first access.
+	diagnosticCache types.DiagnosticResultCacher
 }
 
 func (m *mockServerContext) Document(uri string) *documents.Document      { return nil }
@@ -36,13 +42,29 @@ func (m *mockServerContext) RootURI() string                              { retu
 func (m *mockServerContext) RootPath() string                             { return "" }
 func (m *mockServerContext) SetRootURI(uri string)                        {}
 func (m *mockServerContext) SetRootPath(path string)                      {}
+func (m *mockServerContext) Locale() string                               { return "" }
+func (m *mockServerContext) SetLocale(locale string)                      {}
+func (m *mockServerContext) WorkspaceTrusted() bool                       { return true }
+func (m *mockServerContext) SetWorkspaceTrusted(trusted bool)             {}
 func (m *mockServerContext) GetConfig() types.ServerConfig                { return types.ServerConfig{} }
 func (m *mockServerContext) SetConfig(config types.ServerConfig)          {}
+func (m *mockServerContext) SetTokensFiles(files []any)                   {}
 func (m *mockServerContext) LoadPackageJsonConfig() error                 { return nil }
 func (m *mockServerContext) IsTokenFile(path string) bool                 { return false }
+func (m *mockServerContext) IsCustomPropertiesFile(path string) bool      { return false }
+func (m *mockServerContext) CustomPropertiesFiles() []string                { return nil }
+func (m *mockServerContext) ReloadCustomPropertiesFile(path, content string) error { return nil }
+func (m *mockServerContext) TokensFileMatches(path string) ([]string, error) { return nil, nil }
+func (m *mockServerContext) WatchedFilePatterns() []string                  { return nil }
+func (m *mockServerContext) ValueHistory(cfg types.ServerConfig, token *tokens.Token) *gitblame.Annotation { return nil }
+func (m *mockServerContext) TokenSnapshot() *tokens.Snapshot                         { return nil }
+func (m *mockServerContext) SetTokenSnapshot(snapshot *tokens.Snapshot)              {}
+func (m *mockServerContext) VendorTokens(path string) *types.VendorTokens                  { return nil }
+func (m *mockServerContext) WorkspaceIndex() *workspacefiles.Index                         { return workspacefiles.NewIndex() }
 func (m *mockServerContext) LoadTokensFromConfig() error                  { return nil }
 func (m *mockServerContext) RegisterFileWatchers(ctx *glsp.Context) error { return nil }
 func (m *mockServerContext) RemoveLoadedFile(path string)                 {}
+func (m *mockServerContext) RenameTokenFile(oldPath, newPath string) bool { return false }
 func (m *mockServerContext) GLSPContext() *glsp.Context                   { return nil }
 func (m *mockServerContext) SetGLSPContext(ctx *glsp.Context)             {}
 func (m *mockServerContext) ClientDiagnosticCapability() *bool            { return nil }
@@ -54,9 +76,11 @@ func (m *mockServerContext) PreferredHoverFormat() protocol.MarkupKind { return
 func (m *mockServerContext) SupportsDefinitionLinks() bool { return false }
 func (m *mockServerContext) SupportsDiagnosticRelatedInfo() bool { return false }
 func (m *mockServerContext) SupportsCodeActionLiterals() bool   { return true }
+func (m *mockServerContext) SupportsApplyEdit() bool { return false }
 func (m *mockServerContext) PublishDiagnostics(context *glsp.Context, uri string) error {
 	return nil
 }
+func (m *mockServerContext) RefreshDiagnostics(context *glsp.Context) error { return nil }
 func (m *mockServerContext) UsePullDiagnostics() bool         { return false }
 func (m *mockServerContext) SetUsePullDiagnostics(use bool)   {}
 func (m *mockServerContext) AddWarning(err error)             {}
@@ -71,6 +95,16 @@ func (m *mockServerContext) SemanticTokenCache() types.SemanticTokenCacher {
 	}
 	return m.cache
 }
+func (m *mockServerContext) SemanticTokensLegend() types.SemanticTokensLegend {
+	return types.SemanticTokensLegend{}
+}
+func (m *mockServerContext) SetSemanticTokensLegend(legend types.SemanticTokensLegend) {}
+func (m *mockServerContext) DiagnosticResultCache() types.DiagnosticResultCacher {
+	if m.diagnosticCache == nil {
+		m.diagnosticCache = resultcache.New()
+	}
+	return m.diagnosticCache
+}
 
 func TestMethod_PanicRecovery(t *testing.T) {
 	// Capture log output
@@ -211,3 +245,23 @@ func TestNoParam_Success(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Contains(t, logBuf.String(), "completed")
 }
+
+func TestMethod_PanicReturnsInternalError(t *testing.T) {
+	var logBuf bytes.Buffer
+	log.SetOutput(&logBuf)
+	defer log.SetOutput(nil)
+
+	panicHandler := func(req *types.RequestContext, params string) (string, error) {
+		var m map[string]string
+		m["boom"] = params // nil map write
+		return "", nil
+	}
+
+	wrapped := method(&mockServerContext{}, "textDocument/hover", panicHandler)
+	_, err := wrapped(nil, "x")
+
+	var internalErr *InternalError
+	assert.ErrorAs(t, err, &internalErr)
+	assert.Equal(t, "textDocument/hover", internalErr.Method)
+	assert.Contains(t, logBuf.String(), "Stack trace")
+}
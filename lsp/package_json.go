@@ -112,26 +112,33 @@ func parseTokensFilesField(configMap map[string]any) []any {
 // parseResolversField parses the resolvers field from configuration.
 // Handles []any and []string types, returning nil if not present.
 func parseResolversField(configMap map[string]any) []string {
-	r, ok := configMap["resolvers"]
+	return parseStringListField(configMap, "resolvers")
+}
+
+// parseStringListField parses a list of strings from configuration.
+// Handles []any and []string types, returning nil if not present.
+// Non-string entries are logged and skipped.
+func parseStringListField(configMap map[string]any, field string) []string {
+	r, ok := configMap[field]
 	if !ok {
 		return nil
 	}
 
 	switch v := r.(type) {
 	case []any:
-		resolvers := make([]string, 0, len(v))
+		items := make([]string, 0, len(v))
 		for _, item := range v {
 			if str, ok := item.(string); ok {
-				resolvers = append(resolvers, str)
+				items = append(items, str)
 			} else {
-				log.Warn("Ignoring non-string resolver entry: %v", item)
+				log.Warn("Ignoring non-string %s entry: %v", field, item)
 			}
 		}
-		return resolvers
+		return items
 	case []string:
 		return v
 	default:
-		log.Warn("Ignoring resolvers field with unexpected type %T", r)
+		log.Warn("Ignoring %s field with unexpected type %T", field, r)
 		return nil
 	}
 }
@@ -168,6 +175,90 @@ func buildServerConfig(configMap map[string]any) *types.ServerConfig {
 	// Parse resolvers
 	config.Resolvers = parseResolversField(configMap)
 
+	// Parse features
+	config.Features = parseFeaturesField(configMap)
+
+	// Parse naming
+	config.Naming = parseNamingField(configMap)
+
+	// Parse showPrefix
+	if sp, ok := configMap["showPrefix"].(bool); ok {
+		config.ShowPrefix = &sp
+	}
+
+	// Parse customPropertiesFiles
+	config.CustomPropertiesFiles = parseStringListField(configMap, "customPropertiesFiles")
+
+	// Parse themeFile
+	if tf, ok := configMap["themeFile"].(string); ok {
+		config.ThemeFile = tf
+	}
+
+	// Parse queriesDir
+	if qd, ok := configMap["queriesDir"].(string); ok {
+		config.QueriesDir = qd
+	}
+
+	// Parse valueHistory
+	if vh, ok := configMap["valueHistory"].(bool); ok {
+		config.ValueHistory = vh
+	}
+
+	// Parse compositeFallbacks
+	if cf, ok := configMap["compositeFallbacks"].(bool); ok {
+		config.CompositeFallbacks = cf
+	}
+
+	// Parse compositeSerializers
+	config.CompositeSerializers = parseStringMapField(configMap, "compositeSerializers")
+
+	// Parse hoverPreviews
+	if hp, ok := configMap["hoverPreviews"].(bool); ok {
+		config.HoverPreviews = hp
+	}
+
+	// Parse nonFileDocuments
+	if nfd, ok := configMap["nonFileDocuments"].(bool); ok {
+		config.NonFileDocuments = nfd
+	}
+
+	// Parse dimensionDisplay
+	if dd, ok := configMap["dimensionDisplay"].(string); ok {
+		switch dd {
+		case types.DimensionDisplayRaw, types.DimensionDisplayNormalized, types.DimensionDisplayBoth:
+			config.DimensionDisplay = dd
+		default:
+			log.Warn("Invalid dimensionDisplay %q, valid values: raw, normalized, both", dd)
+		}
+	}
+
+	// Parse rootFontSize
+	if rfs, ok := configMap["rootFontSize"].(float64); ok {
+		config.RootFontSize = rfs
+	}
+
+	// Parse response limits
+	if mci, ok := configMap["maxCompletionItems"].(float64); ok {
+		config.MaxCompletionItems = int(mci)
+	}
+	if mdc, ok := configMap["maxDocumentColors"].(float64); ok {
+		config.MaxDocumentColors = int(mdc)
+	}
+
+	// Parse languageOverrides
+	config.LanguageOverrides = parseStringMapField(configMap, "languageOverrides")
+
+	// Parse scales
+	config.Scales = parseScalesField(configMap)
+
+	// Parse strict
+	if strict, ok := configMap["strict"].(bool); ok {
+		config.Strict = strict
+	}
+
+	// Parse figma
+	config.Figma = parseFigmaField(configMap)
+
 	// Parse cdn
 	if cdn, ok := configMap["cdn"].(string); ok {
 		validCDNs := specifier.ValidCDNs()
@@ -181,6 +272,141 @@ func buildServerConfig(configMap map[string]any) *types.ServerConfig {
 	return config
 }
 
+// parseStringMapField parses an object of strings from configuration, such
+// as the language ID overrides. Non-string values are ignored.
+func parseStringMapField(configMap map[string]any, field string) map[string]string {
+	valuesMap, ok := configMap[field].(map[string]any)
+	if !ok {
+		return nil
+	}
+
+	values := make(map[string]string, len(valuesMap))
+	for key, value := range valuesMap {
+		if str, ok := value.(string); ok {
+			values[key] = str
+		} else {
+			log.Warn("Ignoring non-string %s entry %q: %v", field, key, value)
+		}
+	}
+	return values
+}
+
+// parseScalesField parses the scale rules from configuration.
+// Rules without a group, or without a step or ratio and base, are ignored.
+func parseScalesField(configMap map[string]any) []types.ScaleRule {
+	list, ok := configMap["scales"].([]any)
+	if !ok {
+		return nil
+	}
+
+	scales := make([]types.ScaleRule, 0, len(list))
+	for _, item := range list {
+		ruleMap, ok := item.(map[string]any)
+		if !ok {
+			log.Warn("Ignoring non-object scales entry: %v", item)
+			continue
+		}
+		var rule types.ScaleRule
+		rule.Group, _ = ruleMap["group"].(string)
+		rule.Step, _ = ruleMap["step"].(string)
+		rule.Ratio, _ = ruleMap["ratio"].(float64)
+		rule.Base, _ = ruleMap["base"].(string)
+
+		switch {
+		case rule.Group == "":
+			log.Warn("Ignoring scales entry without a group: %v", item)
+		case rule.Step == "" && (rule.Ratio <= 1 || rule.Base == ""):
+			log.Warn("Ignoring scale %q: set step, or ratio (greater than 1) and base", rule.Group)
+		default:
+			scales = append(scales, rule)
+		}
+	}
+	return scales
+}
+
+// parseFigmaField parses the Figma import from package.json configuration.
+// An import without a fileKey is ignored.
+//
+// tokenEnv and endpoint are ignored too: together they would let a
+// repository send any environment variable to a host of its choosing. They
+// are only read from the editor's settings (see mergePackageJsonConfig).
+func parseFigmaField(configMap map[string]any) *types.FigmaConfig {
+	figmaMap, ok := configMap["figma"].(map[string]any)
+	if !ok {
+		return nil
+	}
+
+	var figma types.FigmaConfig
+	figma.FileKey, _ = figmaMap["fileKey"].(string)
+	for _, field := range []string{"tokenEnv", "endpoint"} {
+		if _, ok := figmaMap[field]; ok {
+			log.Warn("Ignoring figma.%s from package.json: set it in the editor's settings", field)
+		}
+	}
+	figma.Prefix, _ = figmaMap["prefix"].(string)
+	if interval, ok := figmaMap["refreshInterval"].(float64); ok {
+		figma.RefreshInterval = int(interval)
+	}
+	if modes, ok := figmaMap["modes"].(map[string]any); ok {
+		figma.Modes = make(map[string]string, len(modes))
+		for collection, mode := range modes {
+			if str, ok := mode.(string); ok {
+				figma.Modes[collection] = str
+			}
+		}
+	}
+
+	if figma.FileKey == "" {
+		log.Warn("Ignoring figma configuration without a fileKey")
+		return nil
+	}
+	return &figma
+}
+
+// parseFeaturesField parses the features toggles from configuration.
+// Non-boolean values are ignored, leaving the feature enabled.
+func parseFeaturesField(configMap map[string]any) types.FeatureToggles {
+	var features types.FeatureToggles
+
+	featuresMap, ok := configMap["features"].(map[string]any)
+	if !ok {
+		return features
+	}
+
+	toggle := func(name string) *bool {
+		if b, ok := featuresMap[name].(bool); ok {
+			return &b
+		}
+		return nil
+	}
+
+	features.Hover = toggle("hover")
+	features.Completion = toggle("completion")
+	features.CodeActions = toggle("codeActions")
+	features.DocumentColor = toggle("documentColor")
+	features.SemanticTokens = toggle("semanticTokens")
+	features.Diagnostics = toggle("diagnostics")
+
+	return features
+}
+
+// parseNamingField parses the CSS variable naming options from configuration.
+// Non-string values are ignored.
+func parseNamingField(configMap map[string]any) types.NamingConfig {
+	var naming types.NamingConfig
+
+	namingMap, ok := configMap["naming"].(map[string]any)
+	if !ok {
+		return naming
+	}
+
+	naming.Separator, _ = namingMap["separator"].(string)
+	naming.Case, _ = namingMap["case"].(string)
+	naming.Collisions, _ = namingMap["collisions"].(string)
+
+	return naming
+}
+
 // expandTokensFileGlobs expands glob patterns in tokensFiles to actual file paths.
 // Non-glob paths are kept as-is. Returns expanded paths.
 func expandTokensFileGlobs(tokensFiles []any, rootPath string) []any {
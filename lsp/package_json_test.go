@@ -6,6 +6,7 @@ import (
 	"path/filepath"
 	"testing"
 
+	"bennypowers.dev/dtls/lsp/types"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -519,6 +520,66 @@ func TestBuildServerConfig_Resolvers(t *testing.T) {
 	})
 }
 
+func TestBuildServerConfig_Features(t *testing.T) {
+	t.Run("parses feature toggles from config map", func(t *testing.T) {
+		configMap := map[string]any{
+			"features": map[string]any{
+				"hover":          false,
+				"semanticTokens": true,
+				"diagnostics":    "no", // not a bool: ignored
+			},
+		}
+		config := buildServerConfig(configMap)
+		require.NotNil(t, config.Features.Hover)
+		assert.False(t, *config.Features.Hover)
+		require.NotNil(t, config.Features.SemanticTokens)
+		assert.True(t, *config.Features.SemanticTokens)
+		assert.Nil(t, config.Features.Diagnostics)
+		assert.Nil(t, config.Features.Completion)
+	})
+
+	t.Run("features unset when not present", func(t *testing.T) {
+		config := buildServerConfig(map[string]any{"prefix": "ds"})
+		assert.Equal(t, types.FeatureToggles{}, config.Features)
+	})
+}
+
+func TestBuildServerConfig_Naming(t *testing.T) {
+	config := buildServerConfig(map[string]any{
+		"naming": map[string]any{
+			"separator":  "_",
+			"case":       "camel",
+			"collisions": 1, // not a string: ignored
+		},
+	})
+	assert.Equal(t, types.NamingConfig{Separator: "_", Case: "camel"}, config.Naming)
+}
+
+func TestBuildServerConfig_ShowPrefix(t *testing.T) {
+	config := buildServerConfig(map[string]any{"showPrefix": false})
+	require.NotNil(t, config.ShowPrefix)
+	assert.False(t, config.ShowPrefixEnabled())
+
+	config = buildServerConfig(map[string]any{})
+	assert.Nil(t, config.ShowPrefix)
+	assert.True(t, config.ShowPrefixEnabled())
+}
+
+func TestBuildServerConfig_QueriesDir(t *testing.T) {
+	config := buildServerConfig(map[string]any{"queriesDir": "./queries"})
+	assert.Equal(t, "./queries", config.QueriesDir)
+}
+
+func TestBuildServerConfig_Strict(t *testing.T) {
+	assert.True(t, buildServerConfig(map[string]any{"strict": true}).Strict)
+	assert.False(t, buildServerConfig(map[string]any{}).Strict)
+}
+
+func TestBuildServerConfig_ThemeFile(t *testing.T) {
+	config := buildServerConfig(map[string]any{"themeFile": "src/theme.css"})
+	assert.Equal(t, "src/theme.css", config.ThemeFile)
+}
+
 func TestReadPackageJsonConfig_Resolvers(t *testing.T) {
 	t.Run("parses resolvers from package.json", func(t *testing.T) {
 		tmpDir := t.TempDir()
@@ -568,3 +629,96 @@ func TestParseGroupMarkersField(t *testing.T) {
 		assert.Nil(t, result)
 	})
 }
+
+func TestBuildServerConfig_CustomPropertiesFiles(t *testing.T) {
+	config := buildServerConfig(map[string]any{"customPropertiesFiles": []any{"dist/tokens.css", 42}})
+	assert.Equal(t, []string{"dist/tokens.css"}, config.CustomPropertiesFiles)
+}
+
+func TestBuildServerConfig_ValueHistory(t *testing.T) {
+	assert.True(t, buildServerConfig(map[string]any{"valueHistory": true}).ValueHistory)
+	assert.False(t, buildServerConfig(map[string]any{}).ValueHistory)
+}
+
+func TestBuildServerConfig_HoverPreviews(t *testing.T) {
+	assert.True(t, buildServerConfig(map[string]any{"hoverPreviews": true}).HoverPreviews)
+	assert.False(t, buildServerConfig(map[string]any{}).HoverPreviews)
+}
+
+func TestBuildServerConfig_CompositeSerializers(t *testing.T) {
+	config := buildServerConfig(map[string]any{
+		"compositeSerializers": map[string]any{"shadow": "{{.offsetX}} {{.color}}", "border": false},
+	})
+	assert.Equal(t, map[string]string{"shadow": "{{.offsetX}} {{.color}}"}, config.CompositeSerializers)
+	assert.Nil(t, buildServerConfig(map[string]any{}).CompositeSerializers)
+}
+
+func TestBuildServerConfig_DimensionDisplay(t *testing.T) {
+	config := buildServerConfig(map[string]any{"dimensionDisplay": "both", "rootFontSize": float64(10)})
+	assert.Equal(t, types.DimensionDisplayBoth, config.DimensionDisplay)
+	assert.Equal(t, float64(10), config.RootFontSizePx())
+
+	config = buildServerConfig(map[string]any{"dimensionDisplay": "metric"})
+	assert.Empty(t, config.DimensionDisplay)
+	assert.Equal(t, float64(types.DefaultRootFontSize), config.RootFontSizePx())
+}
+
+func TestBuildServerConfig_ResponseLimits(t *testing.T) {
+	config := buildServerConfig(map[string]any{"maxCompletionItems": float64(50), "maxDocumentColors": float64(200)})
+	assert.Equal(t, 50, config.CompletionItemLimit())
+	assert.Equal(t, 200, config.DocumentColorLimit())
+
+	config = buildServerConfig(map[string]any{})
+	assert.Equal(t, types.DefaultMaxCompletionItems, config.CompletionItemLimit())
+	assert.Equal(t, types.DefaultMaxDocumentColors, config.DocumentColorLimit())
+}
+
+func TestBuildServerConfig_LanguageOverrides(t *testing.T) {
+	config := buildServerConfig(map[string]any{
+		"languageOverrides": map[string]any{"postcss": "css", "bogus": 42},
+	})
+	assert.Equal(t, map[string]string{"postcss": "css"}, config.LanguageOverrides)
+	assert.Nil(t, buildServerConfig(map[string]any{}).LanguageOverrides)
+}
+
+func TestBuildServerConfig_Scales(t *testing.T) {
+	config := buildServerConfig(map[string]any{
+		"scales": []any{
+			map[string]any{"group": "space", "step": "4px"},
+			map[string]any{"group": "font.size", "ratio": 1.25, "base": "16px"},
+			map[string]any{"group": "radius"},
+			map[string]any{"step": "2px"},
+			"space",
+		},
+	})
+	assert.Equal(t, []types.ScaleRule{
+		{Group: "space", Step: "4px"},
+		{Group: "font.size", Ratio: 1.25, Base: "16px"},
+	}, config.Scales)
+}
+
+func TestBuildServerConfig_Figma(t *testing.T) {
+	config := buildServerConfig(map[string]any{
+		"figma": map[string]any{
+			"fileKey":         "abc123",
+			"modes":           map[string]any{"Theme": "Dark", "Size": 2.0},
+			"refreshInterval": 60.0,
+		},
+	})
+	assert.Equal(t, &types.FigmaConfig{
+		FileKey:         "abc123",
+		Modes:           map[string]string{"Theme": "Dark"},
+		RefreshInterval: 60,
+	}, config.Figma)
+
+	config = buildServerConfig(map[string]any{"figma": map[string]any{"tokenEnv": "TOKEN"}})
+	assert.Nil(t, config.Figma, "an import without a fileKey is ignored")
+
+	config = buildServerConfig(map[string]any{"figma": map[string]any{
+		"fileKey":  "abc123",
+		"tokenEnv": "AWS_SECRET_ACCESS_KEY",
+		"endpoint": "https://attacker.example",
+	}})
+	assert.Equal(t, &types.FigmaConfig{FileKey: "abc123"}, config.Figma,
+		"a repository can't choose which environment variable is sent where")
+}
@@ -2,10 +2,14 @@ package lsp
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io/fs"
 	"os"
 	"path/filepath"
 	"strings"
+
+	"github.com/bmatcuk/doublestar/v4"
 )
 
 // normalizePath resolves a token file path based on its prefix and workspace root.
@@ -314,3 +318,22 @@ func readPackageJSON(packageDir string) (*PackageJSON, error) {
 
 	return &pkg, nil
 }
+
+// TokensFileMatches resolves a tokensFiles path like the token loader does
+// and expands glob patterns, returning the existing files it matches
+func (s *Server) TokensFileMatches(path string) ([]string, error) {
+	resolved, err := normalizePath(path, s.RootPath())
+	if err != nil {
+		return nil, err
+	}
+	if containsGlobChars(path) {
+		return doublestar.FilepathGlob(resolved)
+	}
+	if _, err := os.Stat(resolved); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return []string{resolved}, nil
+}
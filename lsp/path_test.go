@@ -316,3 +316,37 @@ func TestResolveNpmPath_BoundaryValidation(t *testing.T) {
 		})
 	}
 }
+
+func TestTokensFileMatches(t *testing.T) {
+	root := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "tokens", "brand"), 0o755))
+	for _, name := range []string{"tokens/color.json", "tokens/brand/space.yaml"} {
+		require.NoError(t, os.WriteFile(filepath.Join(root, name), []byte(`{}`), 0o644))
+	}
+
+	server, err := NewServer()
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = server.Close() })
+	server.SetRootPath(root)
+
+	tests := []struct {
+		name string
+		path string
+		want []string
+	}{
+		{"literal path", "./tokens/color.json", []string{filepath.Join(root, "tokens", "color.json")}},
+		{"missing file", "./tokens/missing.json", nil},
+		{"glob", "tokens/*.json", []string{filepath.Join(root, "tokens", "color.json")}},
+		{"recursive glob", "./tokens/**/*.{json,yaml}", []string{
+			filepath.Join(root, "tokens", "brand", "space.yaml"),
+			filepath.Join(root, "tokens", "color.json"),
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			matches, err := server.TokensFileMatches(tt.path)
+			require.NoError(t, err)
+			assert.ElementsMatch(t, tt.want, matches)
+		})
+	}
+}
@@ -3,30 +3,35 @@ package lsp
 import (
 	"fmt"
 	"path/filepath"
+	"slices"
 	"sync"
+	"sync/atomic"
 
 	"bennypowers.dev/dtls/internal/documents"
+	"bennypowers.dev/dtls/internal/gitblame"
 	"bennypowers.dev/dtls/internal/log"
 	"bennypowers.dev/dtls/internal/parser/css"
 	htmlparser "bennypowers.dev/dtls/internal/parser/html"
 	jsparser "bennypowers.dev/dtls/internal/parser/js"
 	"bennypowers.dev/dtls/internal/tokens"
 	"bennypowers.dev/dtls/internal/uriutil"
+	"bennypowers.dev/dtls/internal/workspacefiles"
 	"bennypowers.dev/dtls/lsp/methods/lifecycle"
 	"bennypowers.dev/dtls/lsp/methods/textDocument"
 	codeaction "bennypowers.dev/dtls/lsp/methods/textDocument/codeAction"
 	"bennypowers.dev/dtls/lsp/methods/textDocument/completion"
 	"bennypowers.dev/dtls/lsp/methods/textDocument/definition"
-	"bennypowers.dev/dtls/lsp/methods/textDocument/diagnostic"
+	"bennypowers.dev/dtls/lsp/methods/textDocument/diagnostic/resultcache"
 	documentcolor "bennypowers.dev/dtls/lsp/methods/textDocument/documentColor"
+	documentlink "bennypowers.dev/dtls/lsp/methods/textDocument/documentLink"
 	"bennypowers.dev/dtls/lsp/methods/textDocument/hover"
 	"bennypowers.dev/dtls/lsp/methods/textDocument/references"
+	"bennypowers.dev/dtls/lsp/methods/textDocument/rename"
 	semantictokens "bennypowers.dev/dtls/lsp/methods/textDocument/semanticTokens"
 	"bennypowers.dev/dtls/lsp/methods/workspace"
 	"bennypowers.dev/dtls/lsp/types"
 	"github.com/tliron/glsp"
 	protocol "github.com/tliron/glsp/protocol_3_16"
-	"github.com/tliron/glsp/server"
 )
 
 // Verify that Server implements ServerContext interface
@@ -36,18 +41,42 @@ var _ types.ServerContext = (*Server)(nil)
 type Server struct {
 	documents          *documents.Manager
 	tokens             *tokens.Manager
-	glspServer         *server.Server
+	handler            glsp.Handler
 	context            *glsp.Context
 	rootURI                     string                                // Workspace root URI
 	rootPath                    string                                // Workspace root path (file system)
+	locale                      string                                // Client UI locale from initialize
+	workspaceUntrusted          bool                                  // Client reported an untrusted workspace in initialize
 	config                      types.ServerConfig                    // Server configuration
-	configMu                    sync.RWMutex                          // Protects config, context, clientDiagnosticCapability, clientCapabilities, and usePullDiagnostics from concurrent access
+	configMu                    sync.RWMutex                          // Protects config, context, clientDiagnosticCapability, clientCapabilities, usePullDiagnostics, and diagnosticRefreshSupport from concurrent access
 	loadedFiles                 map[string]*TokenFileOptions          // Track loaded files: filepath -> options (prefix, groupMarkers)
 	loadedFilesMu               sync.RWMutex                          // Protects loadedFiles from concurrent access
 	clientDiagnosticCapability  *bool                                 // Client's diagnostic capability detected from raw initialize params (nil = not detected yet)
 	clientCapabilities          *protocol.ClientCapabilities          // Full client capabilities stored during initialize
 	usePullDiagnostics          bool                                  // Whether to use pull diagnostics (LSP 3.17) vs push (LSP 3.0)
+	diagnosticRefreshSupport    bool                                  // Whether the client supports workspace/diagnostic/refresh (LSP 3.17)
 	semanticTokenCache          *semantictokens.TokenCache            // Cache for semantic tokens delta support
+	diagnosticResultCache       resultcache.Cache                     // Result IDs for pull diagnostics unchanged reports
+	diagnosticsThrottle         *diagnosticsThrottle                  // Coalesces publishDiagnostics per URI (nil = publish immediately)
+	watcherRegistration         *fileWatcherRegistration              // Current didChangeWatchedFiles registration (nil = none)
+	watcherSeq                  int                                   // Sequence number for unique watcher registration IDs
+	watcherQueue                []func()                              // Pending file watcher (un)registration requests, sent in order
+	watcherSending              bool                                  // Whether a goroutine is draining watcherQueue
+	watcherMu                   sync.Mutex                            // Protects watcherRegistration, watcherSeq, watcherQueue and watcherSending
+	blame                       *gitblame.Cache                       // Cached git blame annotations for valueHistory
+	tokenSnapshot               atomic.Pointer[tokens.Snapshot]       // Token set saved by the snapshotTokens command
+	stagedTokens                atomic.Pointer[tokens.Manager]        // Token set being built by an in-progress reload (nil = none)
+	reloadMu                    sync.Mutex                            // Serializes token reloads
+	figma                       *figmaImport                          // Running poll of Figma variables (nil = none)
+	figmaMu                     sync.Mutex                            // Protects figma
+	figmaTokens                 atomic.Pointer[[]byte]                // Token document from the last Figma import (nil = none)
+	daemon                      *Daemon                               // Daemon serving this client alongside others (nil = stdio)
+	sharedTokens                atomic.Pointer[tokens.Manager]        // Token index shared through the daemon (nil = tokens)
+	sharedKey                   string                                // Daemon key of sharedTokens, guarded by reloadMu
+	vendorTokens                map[string]*types.VendorTokens        // Tokens of node_modules packages by package directory
+	vendorMu                    sync.Mutex                            // Protects vendorTokens
+	semanticTokensLegend        types.SemanticTokensLegend            // Legend declared in initialize, guarded by configMu
+	workspaceIndex              *workspacefiles.Index                 // CSS variable references in files under the workspace root
 }
 
 // NewServer creates a new Design Tokens LSP server
@@ -58,7 +87,10 @@ func NewServer() (*Server, error) {
 		config:             types.DefaultConfig(),
 		loadedFiles:        make(map[string]*TokenFileOptions),
 		semanticTokenCache: semantictokens.NewTokenCache(),
+		blame:              gitblame.NewCache(),
+		workspaceIndex:     workspacefiles.NewIndex(),
 	}
+	s.diagnosticsThrottle = newDiagnosticsThrottle(defaultDiagnosticsDelay, s.publishThrottledDiagnostics)
 
 	// Create the GLSP server with our handlers wrapped with middleware
 	protocolHandler := protocol.Handler{
@@ -68,6 +100,10 @@ func NewServer() (*Server, error) {
 		SetTrace:                        notify(s, "$/setTrace", lifecycle.SetTrace),
 		WorkspaceDidChangeConfiguration: notify(s, "workspace/didChangeConfiguration", workspace.DidChangeConfiguration),
 		WorkspaceDidChangeWatchedFiles:  notify(s, "workspace/didChangeWatchedFiles", workspace.DidChangeWatchedFiles),
+		WorkspaceExecuteCommand:         method(s, "workspace/executeCommand", workspace.ExecuteCommand),
+		WorkspaceWillRenameFiles:        method(s, "workspace/willRenameFiles", workspace.WillRenameFiles),
+		WorkspaceSymbol:                 method(s, "workspace/symbol", workspace.Symbol),
+		WorkspaceDidDeleteFiles:         notify(s, "workspace/didDeleteFiles", workspace.DidDeleteFiles),
 		TextDocumentDidOpen:             notify(s, "textDocument/didOpen", textDocument.DidOpen),
 		TextDocumentDidChange:           notify(s, "textDocument/didChange", textDocument.DidChange),
 		TextDocumentDidClose:            notify(s, "textDocument/didClose", textDocument.DidClose),
@@ -76,8 +112,11 @@ func NewServer() (*Server, error) {
 		CompletionItemResolve:           method(s, "completionItem/resolve", completion.CompletionResolve),
 		TextDocumentDefinition:          method(s, "textDocument/definition", definition.Definition),
 		TextDocumentReferences:          method(s, "textDocument/references", references.References),
+		TextDocumentRename:              method(s, "textDocument/rename", rename.Rename),
+		TextDocumentPrepareRename:       method(s, "textDocument/prepareRename", rename.PrepareRename),
 		TextDocumentColor:               method(s, "textDocument/documentColor", documentcolor.DocumentColor),
 		TextDocumentColorPresentation:   method(s, "textDocument/colorPresentation", documentcolor.ColorPresentation),
+		TextDocumentDocumentLink:        method(s, "textDocument/documentLink", documentlink.DocumentLink),
 		TextDocumentCodeAction:          method(s, "textDocument/codeAction", codeaction.CodeAction),
 		CodeActionResolve:               method(s, "codeAction/resolve", codeaction.CodeActionResolve),
 		TextDocumentSemanticTokensFull:  method(s, "textDocument/semanticTokens/full", semantictokens.SemanticTokensFull),
@@ -91,28 +130,34 @@ func NewServer() (*Server, error) {
 		Handler: &protocolHandler,
 		server:  s,
 	}
-
-	// Create GLSP server with debug enabled for stdio
-	s.glspServer = server.NewServer(customHandler, "design-tokens-language-server", true)
+	s.handler = customHandler
 
 	return s, nil
 }
 
-// RunStdio starts the LSP server using stdio transport
-func (s *Server) RunStdio() error {
-	return s.glspServer.RunStdio()
-}
-
-// Close releases server resources including the CSS, HTML, and JS parser pools.
-// It is safe to call Close multiple times.
+// Close stops any Figma import and releases the server's shared token index,
+// if it is a daemon's server. It is safe to call Close multiple times.
 // This method should be called when the server is no longer needed,
 // typically in test cleanup via defer server.Close().
+//
+// The parser pools are process-wide, so servers and other users of the
+// server, such as pkg/analyzer, share them: Close leaves them alone, and the
+// process drains them as it stops serving (see RunStdio and Daemon.Serve).
 func (s *Server) Close() error {
-	// Clean up parser pools
+	s.stopFigmaImport()
+
+	if s.daemon != nil {
+		s.unshareTokens()
+	}
+	return nil
+}
+
+// closeParserPools closes the CSS, HTML, and JS parser pools, once nothing
+// in the process parses anymore
+func closeParserPools() {
 	css.ClosePool()
 	htmlparser.ClosePool()
 	jsparser.ClosePool()
-	return nil
 }
 
 // ServerContext interface implementation
@@ -132,19 +177,28 @@ func (s *Server) AllDocuments() []*documents.Document {
 	return s.documents.GetAll()
 }
 
+// liveTokens returns the token index in use: the one shared with the
+// daemon's other clients, if any, else the server's own
+func (s *Server) liveTokens() *tokens.Manager {
+	if shared := s.sharedTokens.Load(); shared != nil {
+		return shared
+	}
+	return s.tokens
+}
+
 // Token returns the token with the given name
 func (s *Server) Token(name string) *tokens.Token {
-	return s.tokens.Get(name)
+	return s.liveTokens().Get(name)
 }
 
 // TokenManager returns the token manager
 func (s *Server) TokenManager() *tokens.Manager {
-	return s.tokens
+	return s.liveTokens()
 }
 
 // TokenCount returns the number of tokens
 func (s *Server) TokenCount() int {
-	return s.tokens.Count()
+	return s.liveTokens().Count()
 }
 
 // RootURI returns the workspace root URI
@@ -175,6 +229,42 @@ func (s *Server) SetRootPath(path string) {
 	s.rootPath = path
 }
 
+// Locale returns the client's UI locale
+func (s *Server) Locale() string {
+	s.configMu.RLock()
+	defer s.configMu.RUnlock()
+	return s.locale
+}
+
+// SetLocale sets the client's UI locale
+func (s *Server) SetLocale(locale string) {
+	s.configMu.Lock()
+	defer s.configMu.Unlock()
+	s.locale = locale
+}
+
+// WorkspaceTrusted reports whether the client trusts the workspace
+func (s *Server) WorkspaceTrusted() bool {
+	s.configMu.RLock()
+	defer s.configMu.RUnlock()
+	return !s.workspaceUntrusted
+}
+
+// SetWorkspaceTrusted records whether the client trusts the workspace. An
+// untrusted workspace gets the read-only configuration.
+func (s *Server) SetWorkspaceTrusted(trusted bool) {
+	s.configMu.Lock()
+	defer s.configMu.Unlock()
+	s.workspaceUntrusted = !trusted
+}
+
+// WorkspaceIndex returns the index of CSS variable references in the files
+// under the workspace root. It is scanned in the background after
+// initialization, and is empty until then.
+func (s *Server) WorkspaceIndex() *workspacefiles.Index {
+	return s.workspaceIndex
+}
+
 // GLSPContext returns the GLSP context.
 // Access is protected by configMu to prevent concurrent races.
 func (s *Server) GLSPContext() *glsp.Context {
@@ -336,6 +426,16 @@ func (s *Server) SupportsCodeActionLiterals() bool {
 	return s.clientCapabilities.TextDocument.CodeAction.CodeActionLiteralSupport != nil
 }
 
+// SupportsApplyEdit returns whether the client handles workspace/applyEdit.
+// Checks capabilities.workspace.applyEdit.
+func (s *Server) SupportsApplyEdit() bool {
+	s.configMu.RLock()
+	defer s.configMu.RUnlock()
+
+	caps := s.clientCapabilities
+	return caps != nil && caps.Workspace != nil && caps.Workspace.ApplyEdit != nil && *caps.Workspace.ApplyEdit
+}
+
 // UsePullDiagnostics returns whether the client supports pull diagnostics (LSP 3.17)
 // If true, the server should NOT send push diagnostics (textDocument/publishDiagnostics)
 // and instead wait for the client to request diagnostics via textDocument/diagnostic
@@ -352,43 +452,38 @@ func (s *Server) SetUsePullDiagnostics(use bool) {
 	s.usePullDiagnostics = use
 }
 
+// TokenSnapshot returns the token snapshot saved by the snapshotTokens command, or nil
+func (s *Server) TokenSnapshot() *tokens.Snapshot {
+	return s.tokenSnapshot.Load()
+}
+
+// SetTokenSnapshot saves a token snapshot for later comparison
+func (s *Server) SetTokenSnapshot(snapshot *tokens.Snapshot) {
+	s.tokenSnapshot.Store(snapshot)
+}
+
 // SemanticTokenCache returns the semantic tokens cache for delta support
 func (s *Server) SemanticTokenCache() types.SemanticTokenCacher {
 	return s.semanticTokenCache
 }
 
-// PublishDiagnostics publishes diagnostics for a document
-func (s *Server) PublishDiagnostics(context *glsp.Context, uri string) error {
-	log.Info("Publishing diagnostics for: %s", uri)
-
-	// Select a working context: use passed-in context if non-nil, otherwise fall back to server's context
-	workingContext := context
-	if workingContext == nil {
-		workingContext = s.GLSPContext()
-	}
-
-	// If we still don't have a context, fail fast
-	if workingContext == nil {
-		return fmt.Errorf("cannot publish diagnostics: no client context available")
-	}
-
-	// If server is configured to use pull diagnostics, don't publish (client will request)
-	if s.UsePullDiagnostics() {
-		return nil
-	}
-
-	diagnostics, err := diagnostic.GetDiagnostics(s, uri)
-	if err != nil {
-		return err
-	}
+// SemanticTokensLegend returns the semantic tokens legend declared in initialize
+func (s *Server) SemanticTokensLegend() types.SemanticTokensLegend {
+	s.configMu.RLock()
+	defer s.configMu.RUnlock()
+	return s.semanticTokensLegend
+}
 
-	// Publish diagnostics to the client using the selected context
-	workingContext.Notify(protocol.ServerTextDocumentPublishDiagnostics, protocol.PublishDiagnosticsParams{
-		URI:         uri,
-		Diagnostics: diagnostics,
-	})
+// SetSemanticTokensLegend saves the semantic tokens legend declared in initialize
+func (s *Server) SetSemanticTokensLegend(legend types.SemanticTokensLegend) {
+	s.configMu.Lock()
+	defer s.configMu.Unlock()
+	s.semanticTokensLegend = legend
+}
 
-	return nil
+// DiagnosticResultCache returns the pull diagnostics result ID cache
+func (s *Server) DiagnosticResultCache() types.DiagnosticResultCacher {
+	return &s.diagnosticResultCache
 }
 
 // IsTokenFile checks if a file path is one of our token files
@@ -488,7 +583,22 @@ func (s *Server) RemoveLoadedFile(path string) {
 	s.loadedFilesMu.Unlock()
 }
 
-// RegisterFileWatchers registers file watchers with the client
+// fileWatcherIDPrefix prefixes the IDs of didChangeWatchedFiles registrations.
+// A sequence number is appended so each registration has a unique ID.
+const fileWatcherIDPrefix = "design-tokens-file-watcher"
+
+// fileWatcherRegistration records the watchers currently registered with the client
+type fileWatcherRegistration struct {
+	id       string
+	patterns []string
+}
+
+// RegisterFileWatchers registers file watchers for the configured token files with the client.
+//
+// It is safe to call repeatedly (e.g. on configuration change): if the watched
+// patterns changed, the previous registration is unregistered before the new one
+// is registered, so stale watchers don't accumulate. If nothing changed, no
+// requests are sent.
 func (s *Server) RegisterFileWatchers(context *glsp.Context) error {
 	// Guard against nil or empty context (can happen in tests without real LSP connection)
 	// An empty context (created with &glsp.Context{}) won't have Call initialized
@@ -497,90 +607,168 @@ func (s *Server) RegisterFileWatchers(context *glsp.Context) error {
 		return nil
 	}
 
-	// Get config and state snapshots for thread-safe access
-	cfg := s.GetConfig()
-	state := s.GetState()
+	patterns := fileWatcherPatterns(s.GetConfig(), s.GetState())
 
-	// Build file watchers for configured token files
-	watchers := []protocol.FileSystemWatcher{}
-
-	if len(cfg.TokensFiles) > 0 {
-		for _, item := range cfg.TokensFiles {
-			var tokenPath string
-			switch v := item.(type) {
-			case string:
-				tokenPath = v
-			case map[string]any:
-				if pathVal, ok := v["path"]; ok {
-					tokenPath, _ = pathVal.(string)
-				}
-			}
+	s.watcherMu.Lock()
+	previous := s.watcherRegistration
+	if previous != nil && slices.Equal(previous.patterns, patterns) {
+		s.watcherMu.Unlock()
+		log.Info("File watchers unchanged (%d watchers)", len(patterns))
+		return nil
+	}
 
-			if tokenPath == "" {
-				continue
-			}
+	var next *fileWatcherRegistration
+	if len(patterns) > 0 {
+		s.watcherSeq++
+		next = &fileWatcherRegistration{
+			id:       fmt.Sprintf("%s-%d", fileWatcherIDPrefix, s.watcherSeq),
+			patterns: patterns,
+		}
+	}
+	s.watcherRegistration = next
+	if previous != nil || next != nil {
+		s.queueWatcherRequests(func() { sendFileWatcherRequests(context, previous, next) })
+	}
+	s.watcherMu.Unlock()
 
-			// Convert to filesystem path pattern (forward-slash separated)
-			// Glob patterns use filesystem paths, not URIs
-			var pattern string
-			switch {
-			case filepath.IsAbs(tokenPath):
-				// Absolute path: convert to forward slashes
-				pattern = filepath.ToSlash(filepath.Clean(tokenPath))
-			case state.RootPath != "":
-				// Relative path: join with root and convert to forward slashes
-				absPath := filepath.Join(state.RootPath, tokenPath)
-				pattern = filepath.ToSlash(filepath.Clean(absPath))
-			default:
-				// No root path: keep relative, convert to forward slashes
-				pattern = filepath.ToSlash(tokenPath)
+	if previous == nil && next == nil {
+		log.Info("No file watchers to register")
+		return nil
+	}
+
+	log.Info("Sent file watcher registration request (%d watchers)", len(patterns))
+	return nil
+}
+
+// queueWatcherRequests queues the (un)registration requests of a call to
+// RegisterFileWatchers. The caller holds watcherMu, so requests are queued in
+// the order the registrations were recorded.
+//
+// IMPORTANT: The requests are sent from a goroutine to avoid blocking the main
+// message handler loop. If we call context.Call synchronously, the server
+// cannot read the client's response because the message handler is blocked
+// waiting for it (deadlock). One goroutine drains the queue, so the client
+// sees the requests of successive calls in order, and never an unregistration
+// before the registration it removes.
+func (s *Server) queueWatcherRequests(send func()) {
+	s.watcherQueue = append(s.watcherQueue, send)
+	if s.watcherSending {
+		return
+	}
+	s.watcherSending = true
+	go func() {
+		for {
+			s.watcherMu.Lock()
+			if len(s.watcherQueue) == 0 {
+				s.watcherSending = false
+				s.watcherMu.Unlock()
+				return
 			}
+			send := s.watcherQueue[0]
+			s.watcherQueue = s.watcherQueue[1:]
+			s.watcherMu.Unlock()
+			send()
+		}
+	}()
+}
+
+// sendFileWatcherRequests unregisters the previous file watcher registration
+// and registers the next one, either of which may be nil.
+//
+// Note: client/(un)registerCapability are requests (not notifications) per LSP
+// spec. We use context.Call instead of context.Notify to properly send a request.
+//
+// Error handling note: glsp.Context.Call doesn't return errors - errors from the
+// underlying jsonrpc2.Conn.Call are logged by the transport (see handleRPC).
+// Since client capability registration failures are not fatal (the client
+// continues working, just without file watching), this fire-and-forget
+// approach with logging is acceptable.
+func sendFileWatcherRequests(ctx *glsp.Context, previous, next *fileWatcherRegistration) {
+	if previous != nil {
+		var result any
+		ctx.Call("client/unregisterCapability", protocol.UnregistrationParams{
+			Unregisterations: []protocol.Unregistration{
+				{
+					ID:     previous.id,
+					Method: "workspace/didChangeWatchedFiles",
+				},
+			},
+		}, &result)
+		log.Info("File watcher unregistration completed (%s)", previous.id)
+	}
 
+	if next != nil {
+		watchers := make([]protocol.FileSystemWatcher, 0, len(next.patterns))
+		for _, pattern := range next.patterns {
 			watchers = append(watchers, protocol.FileSystemWatcher{
 				GlobPattern: pattern,
 			})
 		}
+
+		var result any
+		ctx.Call("client/registerCapability", protocol.RegistrationParams{
+			Registrations: []protocol.Registration{
+				{
+					ID:     next.id,
+					Method: "workspace/didChangeWatchedFiles",
+					RegisterOptions: protocol.DidChangeWatchedFilesRegistrationOptions{
+						Watchers: watchers,
+					},
+				},
+			},
+		}, &result)
+		log.Info("File watcher registration completed (%s)", next.id)
 	}
+}
 
-	if len(watchers) == 0 {
-		log.Info("No file watchers to register")
+// WatchedFilePatterns returns the glob patterns of the current file watcher
+// registration, or nil when none is registered
+func (s *Server) WatchedFilePatterns() []string {
+	s.watcherMu.Lock()
+	defer s.watcherMu.Unlock()
+	if s.watcherRegistration == nil {
 		return nil
 	}
+	return slices.Clone(s.watcherRegistration.patterns)
+}
 
-	// Register the watchers with the client
-	params := protocol.RegistrationParams{
-		Registrations: []protocol.Registration{
-			{
-				ID:     "design-tokens-file-watcher",
-				Method: "workspace/didChangeWatchedFiles",
-				RegisterOptions: protocol.DidChangeWatchedFilesRegistrationOptions{
-					Watchers: watchers,
-				},
-			},
-		},
-	}
-
-	// Send registration request to client
-	// Note: client/registerCapability is a request (not notification) per LSP spec.
-	// We use context.Call instead of context.Notify to properly send a request.
-	//
-	// IMPORTANT: We must call this in a goroutine to avoid blocking the main message
-	// handler loop. If we call context.Call synchronously, the server cannot read the
-	// client's response because the message handler is blocked waiting for it (deadlock).
-	//
-	// Error handling note: glsp.Context.Call doesn't return errors - the underlying
-	// jsonrpc2.Conn.Call errors are caught and logged by the glsp wrapper
-	// (see github.com/tliron/glsp@v0.2.2/server/handle.go:24-28).
-	// If the client rejects the registration, the error response will be logged
-	// to stderr by the glsp library. Since client capability registration failures
-	// are not fatal (the client continues working, just without file watching),
-	// this fire-and-forget approach with logging is acceptable.
-	go func(ctx *glsp.Context) {
-		var result any
-		ctx.Call("client/registerCapability", params, &result)
-		log.Info("File watcher registration completed")
-	}(context)
+// fileWatcherPatterns builds glob patterns for the configured token files
+func fileWatcherPatterns(cfg types.ServerConfig, state types.ServerState) []string {
+	var patterns []string
 
-	log.Info("Sent file watcher registration request (%d watchers)", len(watchers))
-	return nil
+	for _, item := range cfg.TokensFiles {
+		var tokenPath string
+		switch v := item.(type) {
+		case string:
+			tokenPath = v
+		case map[string]any:
+			if pathVal, ok := v["path"]; ok {
+				tokenPath, _ = pathVal.(string)
+			}
+		}
+
+		if tokenPath == "" {
+			continue
+		}
+
+		// Convert to filesystem path pattern (forward-slash separated)
+		// Glob patterns use filesystem paths, not URIs
+		var pattern string
+		switch {
+		case filepath.IsAbs(tokenPath):
+			// Absolute path: convert to forward slashes
+			pattern = filepath.ToSlash(filepath.Clean(tokenPath))
+		case state.RootPath != "":
+			// Relative path: join with root and convert to forward slashes
+			absPath := filepath.Join(state.RootPath, tokenPath)
+			pattern = filepath.ToSlash(filepath.Clean(absPath))
+		default:
+			// No root path: keep relative, convert to forward slashes
+			pattern = filepath.ToSlash(tokenPath)
+		}
+
+		patterns = append(patterns, pattern)
+	}
+
+	return patterns
 }
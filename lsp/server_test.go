@@ -1,12 +1,14 @@
 package lsp
 
 import (
+	"encoding/json"
 	"testing"
 
 	"bennypowers.dev/dtls/lsp/types"
 
 	"bennypowers.dev/dtls/internal/documents"
 	"bennypowers.dev/dtls/internal/tokens"
+	"bennypowers.dev/dtls/internal/workspacefiles"
 	"bennypowers.dev/dtls/lsp/methods/lifecycle"
 	"bennypowers.dev/dtls/lsp/methods/textDocument"
 	codeaction "bennypowers.dev/dtls/lsp/methods/textDocument/codeAction"
@@ -35,6 +37,7 @@ func TestHandlers_WrappersSmokeTest(t *testing.T) {
 		config:             types.ServerConfig{},
 		loadedFiles:        make(map[string]*TokenFileOptions),
 		semanticTokenCache: semantictokens.NewTokenCache(),
+		workspaceIndex:     workspacefiles.NewIndex(),
 	}
 
 	// Dummy context (nil is fine for these simple wrappers)
@@ -221,7 +224,7 @@ func TestHandlers_WrappersSmokeTest(t *testing.T) {
 
 // TestServer_Close tests that Close() properly releases resources
 func TestServer_Close(t *testing.T) {
-	t.Run("Close releases CSS parser pool", func(t *testing.T) {
+	t.Run("Close releases server resources", func(t *testing.T) {
 		server, err := NewServer()
 		assert.NoError(t, err)
 		assert.NotNil(t, server)
@@ -791,3 +794,21 @@ func TestServer_SupportsCodeActionLiterals(t *testing.T) {
 		assert.True(t, s.SupportsCodeActionLiterals())
 	})
 }
+
+func TestServer_SupportsApplyEdit(t *testing.T) {
+	s, err := NewServer()
+	require.NoError(t, err)
+	assert.False(t, s.SupportsApplyEdit(), "capabilities are unknown")
+
+	for raw, expected := range map[string]bool{
+		`{}`:                                  false,
+		`{"workspace": {}}`:                   false,
+		`{"workspace": {"applyEdit": false}}`: false,
+		`{"workspace": {"applyEdit": true}}`:  true,
+	} {
+		var caps protocol.ClientCapabilities
+		require.NoError(t, json.Unmarshal([]byte(raw), &caps))
+		s.SetClientCapabilities(caps)
+		assert.Equal(t, expected, s.SupportsApplyEdit(), raw)
+	}
+}
@@ -2,11 +2,17 @@ package testutil
 
 import (
 	"path/filepath"
+	"slices"
+	"strings"
 
 	"bennypowers.dev/dtls/internal/documents"
+	"bennypowers.dev/dtls/internal/gitblame"
 	"bennypowers.dev/dtls/internal/tokens"
+	"bennypowers.dev/dtls/internal/workspacefiles"
+	"bennypowers.dev/dtls/lsp/methods/textDocument/diagnostic/resultcache"
 	semantictokens "bennypowers.dev/dtls/lsp/methods/textDocument/semanticTokens"
 	"bennypowers.dev/dtls/lsp/types"
+	"github.com/bmatcuk/doublestar/v4"
 	"github.com/tliron/glsp"
 	protocol "github.com/tliron/glsp/protocol_3_16"
 )
@@ -18,6 +24,8 @@ type MockServerContext struct {
 	tokens      *tokens.Manager
 	rootURI     string
 	rootPath    string
+	locale      string
+	untrusted   bool
 	config                     types.ServerConfig
 	loadedFiles                map[string]string
 	glspContext                *glsp.Context
@@ -30,6 +38,11 @@ type MockServerContext struct {
 	supportsCodeActionLiterals    *bool
 	usePullDiagnostics            bool
 	semanticTokenCache         *semantictokens.TokenCache
+	diagnosticResultCache      *resultcache.Cache
+	valueHistory               map[string]*gitblame.Annotation
+	tokenSnapshot              *tokens.Snapshot
+	semanticTokensLegend       types.SemanticTokensLegend
+	workspaceIndex             *workspacefiles.Index
 
 	// Optional callbacks for custom behavior in tests.
 	// When set, these functions are called instead of the default implementations.
@@ -38,6 +51,7 @@ type MockServerContext struct {
 	IsTokenFileFunc                   func(string) bool
 	ShouldProcessAsTokenFileFunc      func(string) bool
 	PublishDiagnosticsFunc            func(*glsp.Context, string) error
+	RefreshDiagnosticsFunc            func(*glsp.Context) error
 	// LoadTokensFromDocumentContentFunc is called when LoadTokensFromDocumentContent is invoked.
 	// Use this to customize auto-load behavior or verify the parameters passed.
 	LoadTokensFromDocumentContentFunc func(uri, languageID, content string) error
@@ -46,9 +60,19 @@ type MockServerContext struct {
 	// These are set to true when the corresponding method is invoked.
 	LoadTokensCalled bool
 	RegisterWatchersCalled bool
+	RefreshDiagnosticsCalled bool
 	// LoadTokensFromDocumentContentCalled is set to true when LoadTokensFromDocumentContent is called.
 	// Use this to verify that the auto-load path was triggered during didOpen.
 	LoadTokensFromDocumentContentCalled bool
+	// RenamedTokenFiles records the [old, new] path pairs passed to RenameTokenFile.
+	RenamedTokenFiles [][2]string
+	// ReloadedCustomProperties records the paths and contents passed to
+	// ReloadCustomPropertiesFile, in call order.
+	ReloadedCustomProperties [][2]string
+	// WatchedPatterns is returned by WatchedFilePatterns.
+	WatchedPatterns []string
+	// Vendor is returned by VendorTokens for paths in node_modules.
+	Vendor *types.VendorTokens
 }
 
 // NewMockServerContext creates a new mock server context with default behavior
@@ -61,6 +85,9 @@ func NewMockServerContext() *MockServerContext {
 		rootURI:            "",
 		rootPath:           "",
 		semanticTokenCache: semantictokens.NewTokenCache(),
+		workspaceIndex:     workspacefiles.NewIndex(),
+
+		diagnosticResultCache: resultcache.New(),
 	}
 }
 
@@ -89,6 +116,40 @@ func (m *MockServerContext) TokenManager() *tokens.Manager {
 	return m.tokens
 }
 
+// ValueHistory returns the annotation set for the token with SetValueHistory
+func (m *MockServerContext) ValueHistory(_ types.ServerConfig, token *tokens.Token) *gitblame.Annotation {
+	if token == nil {
+		return nil
+	}
+	return m.valueHistory[token.Name]
+}
+
+// SetValueHistory sets the annotation returned by ValueHistory for a token name
+func (m *MockServerContext) SetValueHistory(tokenName string, annotation *gitblame.Annotation) {
+	if m.valueHistory == nil {
+		m.valueHistory = make(map[string]*gitblame.Annotation)
+	}
+	m.valueHistory[tokenName] = annotation
+}
+
+// TokenSnapshot returns the snapshot saved with SetTokenSnapshot
+func (m *MockServerContext) TokenSnapshot() *tokens.Snapshot {
+	return m.tokenSnapshot
+}
+
+// VendorTokens returns Vendor for paths in node_modules
+func (m *MockServerContext) VendorTokens(path string) *types.VendorTokens {
+	if !strings.Contains(filepath.ToSlash(path), "/node_modules/") {
+		return nil
+	}
+	return m.Vendor
+}
+
+// SetTokenSnapshot saves a token snapshot
+func (m *MockServerContext) SetTokenSnapshot(snapshot *tokens.Snapshot) {
+	m.tokenSnapshot = snapshot
+}
+
 // TokenCount returns the number of tokens
 func (m *MockServerContext) TokenCount() int {
 	return m.tokens.Count()
@@ -114,8 +175,36 @@ func (m *MockServerContext) SetRootPath(path string) {
 	m.rootPath = path
 }
 
-// GetConfig returns the server configuration
+// WorkspaceIndex returns the workspace index, which is empty until scanned
+func (m *MockServerContext) WorkspaceIndex() *workspacefiles.Index {
+	return m.workspaceIndex
+}
+
+// Locale returns the client's UI locale
+func (m *MockServerContext) Locale() string {
+	return m.locale
+}
+
+// SetLocale sets the client's UI locale
+func (m *MockServerContext) SetLocale(locale string) {
+	m.locale = locale
+}
+
+// WorkspaceTrusted reports whether the workspace is trusted
+func (m *MockServerContext) WorkspaceTrusted() bool {
+	return !m.untrusted
+}
+
+// SetWorkspaceTrusted sets whether the workspace is trusted
+func (m *MockServerContext) SetWorkspaceTrusted(trusted bool) {
+	m.untrusted = !trusted
+}
+
+// GetConfig returns the server configuration, restricted like the server's
 func (m *MockServerContext) GetConfig() types.ServerConfig {
+	if m.untrusted || m.config.ReadOnly {
+		return m.config.Restricted()
+	}
 	return m.config
 }
 
@@ -124,6 +213,11 @@ func (m *MockServerContext) SetConfig(config types.ServerConfig) {
 	m.config = config
 }
 
+// SetTokensFiles replaces the configured token files
+func (m *MockServerContext) SetTokensFiles(files []any) {
+	m.config.TokensFiles = files
+}
+
 // IsTokenFile checks if a file path is a token file
 func (m *MockServerContext) IsTokenFile(path string) bool {
 	if m.IsTokenFileFunc != nil {
@@ -157,6 +251,34 @@ func (m *MockServerContext) IsTokenFile(path string) bool {
 	return false
 }
 
+// IsCustomPropertiesFile checks if a file path is a configured custom properties file.
+// Relative config paths resolve against the mock's root path.
+func (m *MockServerContext) IsCustomPropertiesFile(path string) bool {
+	return slices.Contains(m.CustomPropertiesFiles(), filepath.Clean(path))
+}
+
+// CustomPropertiesFiles returns the configured custom properties files,
+// resolved against the mock's root path
+func (m *MockServerContext) CustomPropertiesFiles() []string {
+	paths := make([]string, 0, len(m.config.CustomPropertiesFiles))
+	for _, p := range m.config.CustomPropertiesFiles {
+		if m.rootPath != "" && !filepath.IsAbs(p) {
+			p = filepath.Join(m.rootPath, p)
+		}
+		paths = append(paths, filepath.Clean(p))
+	}
+	return paths
+}
+
+// TokensFileMatches expands a tokensFiles path, resolved against the mock's
+// root path, to the files it matches
+func (m *MockServerContext) TokensFileMatches(path string) ([]string, error) {
+	if m.rootPath != "" && !filepath.IsAbs(path) {
+		path = filepath.Join(m.rootPath, path)
+	}
+	return doublestar.FilepathGlob(path)
+}
+
 // ShouldProcessAsTokenFile checks if a document should receive token file features
 func (m *MockServerContext) ShouldProcessAsTokenFile(uri string) bool {
 	if m.ShouldProcessAsTokenFileFunc != nil {
@@ -191,6 +313,17 @@ func (m *MockServerContext) RegisterFileWatchers(ctx *glsp.Context) error {
 	return nil
 }
 
+// WatchedFilePatterns returns WatchedPatterns
+func (m *MockServerContext) WatchedFilePatterns() []string {
+	return m.WatchedPatterns
+}
+
+// ReloadCustomPropertiesFile records the path and content it is called with
+func (m *MockServerContext) ReloadCustomPropertiesFile(path, content string) error {
+	m.ReloadedCustomProperties = append(m.ReloadedCustomProperties, [2]string{path, content})
+	return nil
+}
+
 // LoadTokensFromDocumentContent loads tokens from document content
 func (m *MockServerContext) LoadTokensFromDocumentContent(uri, languageID, content string) error {
 	m.LoadTokensFromDocumentContentCalled = true
@@ -207,6 +340,18 @@ func (m *MockServerContext) RemoveLoadedFile(path string) {
 	delete(m.loadedFiles, cleanPath)
 }
 
+// RenameTokenFile records the rename and rekeys matching loaded files
+func (m *MockServerContext) RenameTokenFile(oldPath, newPath string) bool {
+	m.RenamedTokenFiles = append(m.RenamedTokenFiles, [2]string{oldPath, newPath})
+	oldPath = filepath.Clean(oldPath)
+	if opts, exists := m.loadedFiles[oldPath]; exists {
+		delete(m.loadedFiles, oldPath)
+		m.loadedFiles[filepath.Clean(newPath)] = opts
+		return true
+	}
+	return false
+}
+
 // GLSPContext returns the GLSP context
 func (m *MockServerContext) GLSPContext() *glsp.Context {
 	return m.glspContext
@@ -339,6 +484,13 @@ func (m *MockServerContext) SupportsCodeActionLiterals() bool {
 	return false
 }
 
+// SupportsApplyEdit returns whether the client handles workspace/applyEdit,
+// from clientCapabilities
+func (m *MockServerContext) SupportsApplyEdit() bool {
+	caps := m.clientCapabilities
+	return caps != nil && caps.Workspace != nil && caps.Workspace.ApplyEdit != nil && *caps.Workspace.ApplyEdit
+}
+
 // SetSupportsCodeActionLiterals sets the code action literal support override for testing
 func (m *MockServerContext) SetSupportsCodeActionLiterals(supports bool) {
 	m.supportsCodeActionLiterals = &supports
@@ -352,6 +504,24 @@ func (m *MockServerContext) PublishDiagnostics(context *glsp.Context, uri string
 	return nil
 }
 
+// RefreshDiagnostics refreshes diagnostics for all open documents.
+// By default, push mode publishes each open document and pull mode does nothing.
+func (m *MockServerContext) RefreshDiagnostics(context *glsp.Context) error {
+	m.RefreshDiagnosticsCalled = true
+	if m.RefreshDiagnosticsFunc != nil {
+		return m.RefreshDiagnosticsFunc(context)
+	}
+	if m.usePullDiagnostics {
+		return nil
+	}
+	for _, doc := range m.AllDocuments() {
+		if err := m.PublishDiagnostics(context, doc.URI()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // UsePullDiagnostics returns whether to use pull diagnostics (LSP 3.17)
 func (m *MockServerContext) UsePullDiagnostics() bool {
 	return m.usePullDiagnostics
@@ -367,6 +537,21 @@ func (m *MockServerContext) SemanticTokenCache() types.SemanticTokenCacher {
 	return m.semanticTokenCache
 }
 
+// SemanticTokensLegend returns the legend saved with SetSemanticTokensLegend
+func (m *MockServerContext) SemanticTokensLegend() types.SemanticTokensLegend {
+	return m.semanticTokensLegend
+}
+
+// SetSemanticTokensLegend saves the semantic tokens legend
+func (m *MockServerContext) SetSemanticTokensLegend(legend types.SemanticTokensLegend) {
+	m.semanticTokensLegend = legend
+}
+
+// DiagnosticResultCache returns the pull diagnostics result ID cache
+func (m *MockServerContext) DiagnosticResultCache() types.DiagnosticResultCacher {
+	return m.diagnosticResultCache
+}
+
 // AddDocument adds a document to the manager
 func (m *MockServerContext) AddDocument(doc *documents.Document) {
 	_ = m.docs.DidOpen(doc.URI(), doc.LanguageID(), doc.Version(), doc.Content())
@@ -9,6 +9,7 @@ import (
 	"strings"
 
 	"bennypowers.dev/dtls/internal/log"
+	"bennypowers.dev/dtls/internal/tokens"
 	"github.com/bmatcuk/doublestar/v4"
 )
 
@@ -143,7 +144,7 @@ func (s *Server) LoadTokenFiles(config TokenFileConfig) error {
 		return err
 	}
 
-	log.Info("Total tokens loaded: %d", s.tokens.Count())
+	log.Info("Total tokens loaded: %d", s.loadTarget().Count())
 	return nil
 }
 
@@ -160,9 +161,45 @@ func matchGlobPattern(pattern, path string) (bool, error) {
 func (s *Server) ReloadTokens(config TokenFileConfig) error {
 	log.Info("Reloading all tokens")
 
-	// Clear existing tokens
-	s.tokens.Clear()
+	return s.reload(func() error {
+		return s.LoadTokenFiles(config)
+	})
+}
+
+// reload replaces all tokens with the ones added by load. The new set is
+// built off to the side and swapped in when load returns, so requests
+// arriving during a reload are served the previous tokens instead of
+// waiting on the reload or seeing a partial set.
+func (s *Server) reload(load func() error) error {
+	s.reloadMu.Lock()
+	defer s.reloadMu.Unlock()
+
+	live := s.liveTokens()
+	staged := live.Staged()
+	s.stagedTokens.Store(staged)
+	err := load()
+	s.stagedTokens.Store(nil)
+	live.Replace(staged)
+	return err
+}
 
-	// Reload from files
-	return s.LoadTokenFiles(config)
+// replaceFileTokens replaces the tokens of a source file in one step, like
+// tokens.Manager.ReplaceFile, for loaders which check the file's tokens
+// against the others as they add them. fn receives a batch (see
+// tokens.Manager.Batch) holding the other files' tokens, and adds the
+// file's new ones.
+func replaceFileTokens(target *tokens.Manager, filePath string, fn func(batch *tokens.Manager)) {
+	target.Batch(func(batch *tokens.Manager) {
+		batch.RemoveBySourceFile(filePath)
+		fn(batch)
+	})
+}
+
+// loadTarget returns the token manager that loaded tokens are added to:
+// the staged set while a reload is in progress, otherwise the live one
+func (s *Server) loadTarget() *tokens.Manager {
+	if staged := s.stagedTokens.Load(); staged != nil {
+		return staged
+	}
+	return s.liveTokens()
 }
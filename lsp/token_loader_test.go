@@ -1,6 +1,8 @@
 package lsp
 
 import (
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -200,3 +202,33 @@ func TestMatchGlobPattern_InvalidPatterns(t *testing.T) {
 		})
 	}
 }
+
+// TestReload_ServesPreviousTokens verifies requests see the previous tokens
+// until a reload completes, rather than an empty or partial set
+func TestReload_ServesPreviousTokens(t *testing.T) {
+	tmpDir := t.TempDir()
+	oldFile := filepath.Join(tmpDir, "old.json")
+	require.NoError(t, os.WriteFile(oldFile, []byte(`{"color": {"primary": {"$value": "#ff0000", "$type": "color"}}}`), 0o644))
+	newFile := filepath.Join(tmpDir, "new.json")
+	require.NoError(t, os.WriteFile(newFile, []byte(`{"spacing": {"small": {"$value": "8px", "$type": "dimension"}}}`), 0o644))
+
+	server, err := NewServer()
+	require.NoError(t, err)
+	defer func() { _ = server.Close() }()
+	require.NoError(t, server.LoadTokenFile(oldFile, ""))
+
+	err = server.reload(func() error {
+		require.NoError(t, server.loadTokenFileInternal(newFile, nil))
+
+		// Mid-reload, the new tokens are staged but not served
+		assert.NotNil(t, server.Token("color-primary"))
+		assert.Nil(t, server.Token("spacing-small"))
+		assert.Equal(t, 1, server.loadTarget().Count())
+		return nil
+	})
+	require.NoError(t, err)
+
+	assert.Nil(t, server.Token("color-primary"))
+	assert.NotNil(t, server.Token("spacing-small"))
+	assert.Same(t, server.tokens, server.loadTarget())
+}
@@ -1,6 +1,7 @@
 package lsp
 
 import (
+	"crypto/sha256"
 	"errors"
 	"fmt"
 	"os"
@@ -11,21 +12,14 @@ import (
 	"bennypowers.dev/asimonim/schema"
 	asimonimToken "bennypowers.dev/asimonim/token"
 	"bennypowers.dev/asimonim/validator"
+	"bennypowers.dev/dtls/internal/collections"
 	"bennypowers.dev/dtls/internal/log"
+	"bennypowers.dev/dtls/internal/metrics"
+	yamlparser "bennypowers.dev/dtls/internal/parser/yaml"
+	"bennypowers.dev/dtls/internal/tokens"
 	"bennypowers.dev/dtls/internal/uriutil"
 )
 
-// detectSchemaVersion returns the schema version from the first token that has one set.
-// Falls back to schema.Draft if no token has a schema version.
-func detectSchemaVersion(tokens []*asimonimToken.Token) schema.Version {
-	for _, t := range tokens {
-		if t.SchemaVersion != schema.Unknown {
-			return t.SchemaVersion
-		}
-	}
-	return schema.Draft
-}
-
 // logValidationErrors logs schema validation errors as warnings.
 func logValidationErrors(validationErrors []validator.ValidationError) {
 	for _, ve := range validationErrors {
@@ -41,6 +35,10 @@ type TokenFileOptions struct {
 	// GroupMarkers indicate terminal paths that are also groups
 	// e.g., a token named "color" that is also the parent of "color.primary"
 	GroupMarkers []string
+
+	// SchemaVersion is the file's configured schema version. Unknown means
+	// it is detected from the file's content.
+	SchemaVersion schema.Version
 }
 
 // LoadTokenFile loads a token file (JSON or YAML) and adds tokens to the manager
@@ -101,33 +99,103 @@ func (s *Server) loadTokenFileInternal(filePath string, opts *TokenFileOptions)
 	return err
 }
 
+// parsedTokensCacheSize is how many parsed token files are kept. Large token
+// files take hundreds of milliseconds to parse, and are parsed again whenever
+// they are reopened or reloaded without changes.
+const parsedTokensCacheSize = 8
+
+// parsedTokenFile is a parsed token file and the schema version it was
+// parsed as
+type parsedTokenFile struct {
+	tokens []*asimonimToken.Token
+	schema tokens.SchemaDetection
+}
+
+// parsedTokensCache holds parsed tokens keyed by parsedTokensKey. Cached
+// tokens are never added to a manager; parseTokens hands out copies.
+var parsedTokensCache = collections.NewLRU[[sha256.Size]byte, parsedTokenFile](parsedTokensCacheSize)
+
+// parsedTokensKey hashes token data with the options that affect parsing
+func parsedTokensKey(data []byte, opts *TokenFileOptions) [sha256.Size]byte {
+	h := sha256.New()
+	h.Write([]byte(opts.Prefix))
+	h.Write([]byte{byte(opts.SchemaVersion)})
+	for _, marker := range opts.GroupMarkers {
+		h.Write([]byte{0})
+		h.Write([]byte(marker))
+	}
+	h.Write([]byte{1})
+	h.Write(data)
+	var key [sha256.Size]byte
+	h.Sum(key[:0])
+	return key
+}
+
+// parseTokens parses token data (JSON or YAML) as the schema version
+// detected for it, reusing the result for content it has parsed before. The
+// returned tokens are copies the caller may modify. cached reports whether
+// the parse was skipped.
+func parseTokens(data []byte, opts *TokenFileOptions) (parsed []*asimonimToken.Token, detected tokens.SchemaDetection, cached bool, err error) {
+	key := parsedTokensKey(data, opts)
+	file, cached := parsedTokensCache.Get(key)
+	metrics.CacheLookup("parsedTokens", cached)
+	if !cached {
+		file.schema = tokens.DetectSchemaVersion(data, opts.SchemaVersion)
+		parser := asimonimParser.NewJSONParser()
+		file.tokens, err = parser.Parse(data, asimonimParser.Options{
+			Prefix:        opts.Prefix,
+			SchemaVersion: file.schema.Version,
+			GroupMarkers:  opts.GroupMarkers,
+		})
+		if err != nil {
+			return nil, tokens.SchemaDetection{}, false, err
+		}
+		parsedTokensCache.Put(key, file)
+	}
+
+	copies := make([]*asimonimToken.Token, len(file.tokens))
+	for i, token := range file.tokens {
+		clone := *token
+		clone.SchemaVersion = file.schema.Version
+		copies[i] = &clone
+	}
+	return copies, file.schema, cached, nil
+}
+
 // parseAndAddTokens parses token data, validates it, and adds the tokens to the manager.
 // filePath and fileURI are set on each token for definition tracking.
 // Returns the number of successfully added tokens.
 func (s *Server) parseAndAddTokens(data []byte, filePath, fileURI string, opts *TokenFileOptions) (int, error) {
+	return addTokens(s.loadTarget(), data, filePath, fileURI, opts)
+}
+
+// addTokens parses token data, validates it, and adds the tokens to target,
+// as parseAndAddTokens does for the server's manager
+func addTokens(target *tokens.Manager, data []byte, filePath, fileURI string, opts *TokenFileOptions) (int, error) {
 	if opts == nil {
 		opts = &TokenFileOptions{}
 	}
 
-	// Parse tokens using asimonim (handles both JSON and YAML)
-	parser := asimonimParser.NewJSONParser()
-	parsedTokens, err := parser.Parse(data, asimonimParser.Options{
-		Prefix:       opts.Prefix,
-		GroupMarkers: opts.GroupMarkers,
-	})
+	parsedTokens, detected, cached, err := parseTokens(data, opts)
 	if err != nil {
 		return 0, err
 	}
+	if isYAMLPath(filePath) {
+		applyYAMLPositions(parsedTokens, data, opts)
+	}
 
-	// Validate schema consistency
-	version := detectSchemaVersion(parsedTokens)
-	if filePath != "" {
-		if validationErrors := validator.ValidateConsistencyWithPath(data, version, filePath); len(validationErrors) > 0 {
-			logValidationErrors(validationErrors)
-		}
-	} else {
-		if validationErrors := validator.ValidateConsistency(data, version); len(validationErrors) > 0 {
-			logValidationErrors(validationErrors)
+	// Validate schema consistency. Cached content was validated when it was
+	// first parsed, so its warnings are already in the log.
+	if !cached {
+		version := detected.Version
+		if filePath != "" {
+			if validationErrors := validator.ValidateConsistencyWithPath(data, version, filePath); len(validationErrors) > 0 {
+				logValidationErrors(validationErrors)
+			}
+		} else {
+			if validationErrors := validator.ValidateConsistency(data, version); len(validationErrors) > 0 {
+				logValidationErrors(validationErrors)
+			}
 		}
 	}
 
@@ -141,15 +209,43 @@ func (s *Server) parseAndAddTokens(data []byte, filePath, fileURI string, opts *
 	if source == "" {
 		source = "<memory>"
 	}
-	for _, token := range parsedTokens {
-		token.FilePath = filePath
-		token.DefinitionURI = fileURI
-		if err := s.tokens.Add(token); err != nil {
-			errs = append(errs, fmt.Errorf("failed to add token %s: %w", token.Name, err))
-		} else {
-			successCount++
-		}
+	if !cached {
+		log.Info("Parsing %s as schema %s (from %s)", source, detected.Version, detected.Source)
 	}
+	// Replace the file's tokens in one step, so readers see all of them or none.
+	// In-memory content has no file to replace, and adds its tokens in a batch.
+	load := target.Batch
+	if filePath != "" {
+		load = func(fn func(batch *tokens.Manager)) { replaceFileTokens(target, filePath, fn) }
+	}
+	load(func(batch *tokens.Manager) {
+		if filePath != "" {
+			batch.SetSchemaVersionForFile(filePath, detected.Version)
+		}
+		policy := batch.NameFormat().Collisions
+		for _, token := range parsedTokens {
+			token.FilePath = filePath
+			token.DefinitionURI = fileURI
+			if existing := batch.Colliding(token); existing != nil {
+				name := batch.CSSVariableName(token)
+				switch policy {
+				case tokens.CollisionFirst:
+					log.Warn("Skipping token %s: %s is already defined by %s", token.Name, name, existing.Name)
+					continue
+				case tokens.CollisionError:
+					errs = append(errs, fmt.Errorf("token %s collides with %s: both are named %s", token.Name, existing.Name, name))
+					continue
+				default:
+					log.Warn("Tokens %s and %s are both named %s", existing.Name, token.Name, name)
+				}
+			}
+			if err := batch.Add(token); err != nil {
+				errs = append(errs, fmt.Errorf("failed to add token %s: %w", token.Name, err))
+			} else {
+				successCount++
+			}
+		}
+	})
 
 	if len(errs) > 0 {
 		// Report partial success if some tokens loaded
@@ -172,6 +268,37 @@ func (s *Server) parseAndAddTokens(data []byte, filePath, fileURI string, opts *
 	return successCount, nil
 }
 
+// isYAMLPath reports whether a token file path is a YAML file
+func isYAMLPath(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return true
+	}
+	return false
+}
+
+// applyYAMLPositions moves tokens parsed from a YAML token file to the
+// positions of their keys, as the YAML parser finds them. The token parser
+// tracks positions in JSON, which YAML's indented keys and comments don't
+// line up with.
+func applyYAMLPositions(parsed []*asimonimToken.Token, data []byte, opts *TokenFileOptions) {
+	found, err := yamlparser.Parse(string(data), opts.GroupMarkers)
+	if err != nil {
+		log.Debug("Failed to find token positions in YAML: %v", err)
+		return
+	}
+	positions := make(map[string]yamlparser.Token, len(found))
+	for _, token := range found {
+		positions[strings.Join(token.Path, ".")] = token
+	}
+	for _, token := range parsed {
+		if position, ok := positions[strings.Join(token.Path, ".")]; ok {
+			token.Line = position.Line
+			token.Character = position.Character
+		}
+	}
+}
+
 // LoadTokensFromJSON loads tokens from JSON data (for testing)
 // errors from this function should be presented to the user via window/logMessage
 // further up the call stack
@@ -201,7 +328,13 @@ func (s *Server) LoadTokensFromDocumentContent(uri, languageID, content string)
 	// Convert URI to file path for FilePath field
 	filePath := uriutil.URIToPath(uri)
 
-	successCount, err := s.parseAndAddTokens([]byte(content), filePath, uri, &TokenFileOptions{})
+	// A configured file keeps the options it was loaded with, since its
+	// tokens are replaced by the ones parsed here
+	s.loadedFilesMu.RLock()
+	opts := s.loadedFiles[filepath.Clean(filePath)]
+	s.loadedFilesMu.RUnlock()
+
+	successCount, err := s.parseAndAddTokens([]byte(content), filePath, uri, opts)
 	if successCount > 0 {
 		// Resolve all aliases after loading tokens
 		s.ResolveAllTokens()
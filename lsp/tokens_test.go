@@ -106,3 +106,118 @@ func TestLoadTokenFile_ExtensionHandling(t *testing.T) {
 		})
 	}
 }
+
+// TestLoadTokenFile_SameContent tests that files with identical content get
+// their own tokens, though the content is only parsed once
+func TestLoadTokenFile_SameContent(t *testing.T) {
+	content := `{"color": {"primary": {"$value": "#ff0000", "$type": "color"}}}`
+	dir := t.TempDir()
+	first := filepath.Join(dir, "first.json")
+	second := filepath.Join(dir, "second.json")
+	require.NoError(t, os.WriteFile(first, []byte(content), 0o644))
+	require.NoError(t, os.WriteFile(second, []byte(content), 0o644))
+
+	firstServer, err := lsp.NewServer()
+	require.NoError(t, err)
+	secondServer, err := lsp.NewServer()
+	require.NoError(t, err)
+	require.NoError(t, firstServer.LoadTokenFile(first, ""))
+	require.NoError(t, secondServer.LoadTokenFile(second, ""))
+
+	firstToken := firstServer.Token("color-primary")
+	secondToken := secondServer.Token("color-primary")
+	require.NotNil(t, firstToken)
+	require.NotNil(t, secondToken)
+	assert.NotSame(t, firstToken, secondToken)
+	assert.Equal(t, first, firstToken.FilePath)
+	assert.Equal(t, second, secondToken.FilePath)
+}
+
+// TestLoadTokenFile_Shrinks tests that loading a file again drops the tokens
+// removed from it, and leaves other files' tokens alone
+func TestLoadTokenFile_Shrinks(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "tokens.json")
+	other := filepath.Join(dir, "other.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{"color": {
+		"primary": {"$value": "#ff0000", "$type": "color"},
+		"secondary": {"$value": "#00ff00", "$type": "color"}
+	}}`), 0o644))
+	require.NoError(t, os.WriteFile(other, []byte(`{"space": {"small": {"$value": "4px", "$type": "dimension"}}}`), 0o644))
+
+	server, err := lsp.NewServer()
+	require.NoError(t, err)
+	require.NoError(t, server.LoadTokenFile(path, ""))
+	require.NoError(t, server.LoadTokenFile(other, ""))
+	require.NotNil(t, server.Token("color-secondary"))
+
+	require.NoError(t, os.WriteFile(path, []byte(`{"color": {"primary": {"$value": "#0000ff", "$type": "color"}}}`), 0o644))
+	require.NoError(t, server.LoadTokenFile(path, ""))
+
+	assert.Nil(t, server.Token("color-secondary"))
+	require.NotNil(t, server.Token("color-primary"))
+	assert.Equal(t, "#0000ff", server.Token("color-primary").Value)
+	assert.NotNil(t, server.Token("space-small"))
+}
+
+func TestLoadTokensFromJSON_Expressions(t *testing.T) {
+	server, err := lsp.NewServer()
+	require.NoError(t, err)
+	require.NoError(t, server.LoadTokensFromJSON([]byte(`{"spacing": {
+		"base": {"$value": "8px", "$type": "dimension"},
+		"double": {"$value": "{spacing.base} * 2", "$type": "dimension"}
+	}}`), ""))
+
+	double := server.Token("spacing-double")
+	require.NotNil(t, double)
+	assert.Equal(t, "16px", double.ResolvedValue)
+	assert.Equal(t, "calc(8px * 2)", double.Value)
+}
+
+func TestLoadTokensFromJSON_ArrayValues(t *testing.T) {
+	server, err := lsp.NewServer()
+	require.NoError(t, err)
+	require.NoError(t, server.LoadTokensFromJSON([]byte(`{
+		"font": {"body": {"$value": ["Helvetica Neue", "Arial", "sans-serif"], "$type": "fontFamily"}},
+		"ease": {"out": {"$value": [0, 0, 0.58, 1], "$type": "cubicBezier"}},
+		"shadow": {"raised": {"$type": "shadow", "$value": [
+			{"color": "#000", "offsetX": "0px", "offsetY": "1px", "blur": "2px", "spread": "0px"},
+			{"color": "#111", "offsetX": "0px", "offsetY": "4px", "blur": "8px", "spread": "0px", "inset": true}
+		]}}
+	}`), ""))
+
+	tests := map[string]string{
+		"font-body":     `"Helvetica Neue", Arial, sans-serif`,
+		"ease-out":      "cubic-bezier(0, 0, 0.58, 1)",
+		"shadow-raised": "0px 1px 2px 0px #000, inset 0px 4px 8px 0px #111",
+	}
+	for name, want := range tests {
+		token := server.Token(name)
+		require.NotNil(t, token, name)
+		assert.Equal(t, want, token.Value, name)
+		assert.IsType(t, []any{}, token.RawValue, "%s keeps its array", name)
+	}
+}
+
+// TestLoadTokenFile_YAMLPositions tests that tokens from YAML files are
+// defined at their keys, for go to definition
+func TestLoadTokenFile_YAMLPositions(t *testing.T) {
+	content := "# Brand colors\ncolor:\n  $type: color\n  brand:\n    _:\n      $value: \"#0066cc\"\n    dark: { $value: \"#003366\" }\n"
+	path := filepath.Join(t.TempDir(), "tokens.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+
+	server, err := lsp.NewServer()
+	require.NoError(t, err)
+	defer func() { _ = server.Close() }()
+	require.NoError(t, server.LoadTokenFileWithOptions(path, &lsp.TokenFileOptions{GroupMarkers: []string{"_"}}))
+
+	brand := server.Token("color.brand")
+	require.NotNil(t, brand)
+	assert.Equal(t, uint32(4), brand.Line)
+	assert.Equal(t, uint32(4), brand.Character)
+
+	dark := server.Token("color.brand.dark")
+	require.NotNil(t, dark)
+	assert.Equal(t, uint32(6), dark.Line)
+	assert.Equal(t, uint32(4), dark.Character)
+}
@@ -0,0 +1,26 @@
+package lsp
+
+import (
+	"fmt"
+	"time"
+
+	"bennypowers.dev/dtls/lsp/types"
+	"github.com/tliron/glsp"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+// traceRequest reports a handled request in a $/logTrace notification when
+// the client has set the trace level to verbose, so that slow editors can be
+// debugged from the client's logs alone
+func traceRequest(glspCtx *glsp.Context, methodName string, duration time.Duration, req *types.RequestContext) {
+	if glspCtx == nil || glspCtx.Notify == nil || protocol.GetTraceValue() != protocol.TraceValueVerbose {
+		return
+	}
+	params := &protocol.LogTraceParams{
+		Message: fmt.Sprintf("%s took %s", methodName, duration.Round(time.Microsecond)),
+	}
+	if details := req.TraceDetails(); details != "" {
+		params.Verbose = &details
+	}
+	go glspCtx.Notify(protocol.MethodLogTrace, params)
+}
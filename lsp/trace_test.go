@@ -0,0 +1,60 @@
+package lsp
+
+import (
+	"testing"
+	"time"
+
+	"bennypowers.dev/dtls/internal/documents"
+	"bennypowers.dev/dtls/lsp/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tliron/glsp"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+func TestMethod_VerboseTrace(t *testing.T) {
+	t.Cleanup(func() { protocol.SetTraceValue(protocol.TraceValueOff) })
+
+	docs := documents.NewManager()
+	require.NoError(t, docs.DidOpen("file:///a.css", "css", 1, ".a { color: var(--x); }"))
+	handler := func(req *types.RequestContext, params string) (string, error) {
+		req.TraceDocument(docs.Get("file:///a.css"))
+		req.TraceVarCalls(1)
+		return "ok", nil
+	}
+
+	traces := make(chan *protocol.LogTraceParams, 1)
+	glspCtx := &glsp.Context{Notify: func(method string, params any) {
+		if method == protocol.MethodLogTrace {
+			traces <- params.(*protocol.LogTraceParams)
+		}
+	}}
+	wrapped := method(&mockServerContext{}, "textDocument/hover", handler)
+
+	t.Run("off", func(t *testing.T) {
+		protocol.SetTraceValue(protocol.TraceValueOff)
+		_, err := wrapped(glspCtx, "params")
+		require.NoError(t, err)
+
+		select {
+		case trace := <-traces:
+			t.Fatalf("unexpected trace: %s", trace.Message)
+		case <-time.After(50 * time.Millisecond):
+		}
+	})
+
+	t.Run("verbose", func(t *testing.T) {
+		protocol.SetTraceValue(protocol.TraceValueVerbose)
+		_, err := wrapped(glspCtx, "params")
+		require.NoError(t, err)
+
+		select {
+		case trace := <-traces:
+			assert.Contains(t, trace.Message, "textDocument/hover took ")
+			require.NotNil(t, trace.Verbose)
+			assert.Equal(t, "document: file:///a.css (23 bytes), var calls: 1", *trace.Verbose)
+		case <-time.After(time.Second):
+			t.Fatal("no $/logTrace sent")
+		}
+	})
+}
@@ -0,0 +1,113 @@
+package lsp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"bennypowers.dev/dtls/internal/log"
+	"github.com/sourcegraph/jsonrpc2"
+	"github.com/tliron/glsp"
+)
+
+// RunStdio starts the LSP server using stdio transport. The server is the
+// process's only one, so the parser pools are closed when the client
+// disconnects.
+func (s *Server) RunStdio() error {
+	defer closeParserPools()
+	log.Info("Reading from stdin, writing to stdout")
+	s.serve(stdio{})
+	log.Info("stdin/stdout connection closed")
+	return nil
+}
+
+// serve speaks LSP over rwc until the client disconnects
+func (s *Server) serve(rwc io.ReadWriteCloser) {
+	stream := newMessageStream(rwc, maxMessageSize())
+	conn := jsonrpc2.NewConn(context.Background(), stream, jsonrpc2.HandlerWithError(s.handleRPC))
+	<-conn.DisconnectNotify()
+}
+
+// handleRPC dispatches a JSON-RPC message to the glsp handler chain.
+//
+// This mirrors glsp's own server dispatch (github.com/tliron/glsp@v0.2.2/server/handle.go),
+// which reports every handler error as CodeInvalidRequest. We own the dispatch so that
+// recovered panics (*InternalError) are reported as CodeInternalError instead.
+func (s *Server) handleRPC(ctx context.Context, conn *jsonrpc2.Conn, request *jsonrpc2.Request) (any, error) {
+	defer s.reportPanic()
+
+	glspContext := glsp.Context{
+		Method: request.Method,
+		Notify: func(method string, params any) {
+			if err := conn.Notify(ctx, method, params); err != nil {
+				log.Error("Failed to send %s notification: %v", method, err)
+			}
+		},
+		Call: func(method string, params any, result any) {
+			if err := conn.Call(ctx, method, params, result); err != nil {
+				log.Error("Failed to call %s: %v", method, err)
+			}
+		},
+	}
+
+	if request.Params != nil {
+		glspContext.Params = *request.Params
+	}
+
+	if request.Method == "exit" {
+		// Give the handler a chance to handle it first, but ignore any result
+		_, _, _, _ = s.handler.Handle(&glspContext)
+		return nil, conn.Close()
+	}
+
+	// jsonrpc2 does not call this function if request.Params is not valid JSON,
+	// so CodeParseError never needs to be handled here
+	result, validMethod, validParams, err := s.handler.Handle(&glspContext)
+	switch {
+	case !validMethod:
+		return nil, &jsonrpc2.Error{
+			Code:    jsonrpc2.CodeMethodNotFound,
+			Message: fmt.Sprintf("method not supported: %s", request.Method),
+		}
+	case !validParams:
+		rpcErr := &jsonrpc2.Error{Code: jsonrpc2.CodeInvalidParams}
+		if err != nil {
+			rpcErr.Message = err.Error()
+		}
+		return nil, rpcErr
+	case err != nil:
+		var internalErr *InternalError
+		if errors.As(err, &internalErr) {
+			return nil, &jsonrpc2.Error{
+				Code:    jsonrpc2.CodeInternalError,
+				Message: err.Error(),
+			}
+		}
+		return nil, &jsonrpc2.Error{
+			Code:    jsonrpc2.CodeInvalidRequest,
+			Message: err.Error(),
+		}
+	default:
+		return result, nil
+	}
+}
+
+// stdio adapts the process's stdin/stdout to an io.ReadWriteCloser
+type stdio struct{}
+
+func (stdio) Read(p []byte) (int, error) {
+	return os.Stdin.Read(p)
+}
+
+func (stdio) Write(p []byte) (int, error) {
+	return os.Stdout.Write(p)
+}
+
+func (stdio) Close() error {
+	if err := os.Stdin.Close(); err != nil {
+		return err
+	}
+	return os.Stdout.Close()
+}
@@ -0,0 +1,85 @@
+package lsp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/sourcegraph/jsonrpc2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tliron/glsp"
+)
+
+// stubHandler is a glsp.Handler returning fixed values
+type stubHandler struct {
+	result      any
+	validMethod bool
+	validParams bool
+	err         error
+}
+
+func (h stubHandler) Handle(*glsp.Context) (any, bool, bool, error) {
+	return h.result, h.validMethod, h.validParams, h.err
+}
+
+func TestHandleRPC(t *testing.T) {
+	params := json.RawMessage(`{}`)
+	request := &jsonrpc2.Request{Method: "textDocument/hover", Params: &params}
+
+	tests := []struct {
+		name     string
+		handler  stubHandler
+		wantCode int64
+		wantErr  bool
+	}{
+		{
+			name:    "success",
+			handler: stubHandler{result: "ok", validMethod: true, validParams: true},
+		},
+		{
+			name:     "unknown method",
+			handler:  stubHandler{validMethod: false},
+			wantCode: jsonrpc2.CodeMethodNotFound,
+			wantErr:  true,
+		},
+		{
+			name:     "invalid params",
+			handler:  stubHandler{validMethod: true, validParams: false, err: errors.New("bad params")},
+			wantCode: jsonrpc2.CodeInvalidParams,
+			wantErr:  true,
+		},
+		{
+			name:     "handler error",
+			handler:  stubHandler{validMethod: true, validParams: true, err: errors.New("failed")},
+			wantCode: jsonrpc2.CodeInvalidRequest,
+			wantErr:  true,
+		},
+		{
+			name:     "recovered panic",
+			handler:  stubHandler{validMethod: true, validParams: true, err: &InternalError{Method: "textDocument/hover"}},
+			wantCode: jsonrpc2.CodeInternalError,
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, err := NewServer()
+			require.NoError(t, err)
+			server.handler = tt.handler
+
+			result, err := server.handleRPC(context.Background(), nil, request)
+			if !tt.wantErr {
+				require.NoError(t, err)
+				assert.Equal(t, "ok", result)
+				return
+			}
+
+			var rpcErr *jsonrpc2.Error
+			require.ErrorAs(t, err, &rpcErr)
+			assert.Equal(t, tt.wantCode, rpcErr.Code)
+		})
+	}
+}
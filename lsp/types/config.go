@@ -1,5 +1,7 @@
 package types
 
+import "strings"
+
 // TokenFileSpec represents a token file specification
 type TokenFileSpec struct {
 	// Path to the token file (required)
@@ -51,6 +53,268 @@ type ServerConfig struct {
 	// Valid values: "unpkg", "esm.sh", "esm.run", "jspm", "jsdelivr".
 	// Defaults to "unpkg" if empty. Has no effect if NetworkFallback is false.
 	CDN string `json:"cdn,omitempty"`
+
+	// Features enables or disables individual LSP features, so users can turn off
+	// features that overlap with other extensions. All features are enabled by default.
+	Features FeatureToggles `json:"features,omitempty"`
+
+	// Naming controls how token paths become CSS variable names, so generated
+	// names match what the project's build pipeline emits.
+	Naming NamingConfig `json:"naming,omitempty"`
+
+	// ShowPrefix controls whether hover titles and completion labels include the
+	// token prefix. Inserted text always uses the fully prefixed variable.
+	// nil means "not configured", which shows the prefix.
+	ShowPrefix *bool `json:"showPrefix,omitempty"`
+
+	// CustomPropertiesFiles lists generated postcss-custom-properties files
+	// (CSS files declaring custom properties, e.g. under :root). Their properties
+	// are offered as tokens when no token file defines them, and values that no
+	// longer match the token files are reported as stale.
+	// Relative paths resolve against the workspace root.
+	CustomPropertiesFiles []string `json:"customPropertiesFiles,omitempty"`
+
+	// ThemeFile is a hand-maintained CSS file declaring the tokens as custom
+	// properties (e.g. under :root), which the syncTheme command keeps in step
+	// with the token files. Relative paths resolve against the workspace root.
+	ThemeFile string `json:"themeFile,omitempty"`
+
+	// QueriesDir is a directory of tree-sitter query overrides (e.g. css/var-call.scm),
+	// letting advanced users change which nodes are treated as var() calls or
+	// declarations without recompiling. Relative paths resolve against the workspace root.
+	QueriesDir string `json:"queriesDir,omitempty"`
+
+	// ValueHistory annotates hover and completion docs with the date and subject
+	// of the last commit to change each token's definition, using git blame.
+	// Off by default, since it runs git for each token shown.
+	ValueHistory bool `json:"valueHistory,omitempty"`
+
+	// CompositeFallbacks opts in to fallbacks for composite tokens (shadow,
+	// border, transition and typography) in the add and toggle fallback code
+	// actions, expanded to the equivalent CSS value. Off by default, since the
+	// expanded value is long and can't express every sub-value.
+	CompositeFallbacks bool `json:"compositeFallbacks,omitempty"`
+
+	// CompositeSerializers maps composite token types (e.g. "shadow",
+	// "typography") to Go text/templates which write their values as CSS,
+	// replacing the built-in shorthand expansion for composite fallbacks.
+	// See css.SerializeCompositeValue for the template data.
+	CompositeSerializers map[string]string `json:"compositeSerializers,omitempty"`
+
+	// HoverPreviews draws gradient and shadow tokens in markdown hovers, as
+	// inline SVG images. Off by default, since not every client renders
+	// data URI images in hovers.
+	HoverPreviews bool `json:"hoverPreviews,omitempty"`
+
+	// LanguageOverrides maps nonstandard document language IDs (e.g. "postcss",
+	// "sugarss") to a supported language ID (e.g. "css"), so documents opened
+	// with them get the same features instead of being ignored.
+	LanguageOverrides map[string]string `json:"languageOverrides,omitempty"`
+
+	// NonFileDocuments opts in to processing documents with non-file URIs, such
+	// as untitled: scratch buffers. They get completion, hover and other features
+	// against the loaded tokens, but never features derived from a file path.
+	NonFileDocuments bool `json:"nonFileDocuments,omitempty"`
+
+	// DimensionDisplay controls how dimension and number values render in hover:
+	// "raw" (default) shows the value as written, "normalized" shows it in px
+	// with trailing zeros trimmed, and "both" shows the px and rem forms side by side.
+	DimensionDisplay string `json:"dimensionDisplay,omitempty"`
+
+	// RootFontSize is the root font size in px for px↔rem conversions in hover.
+	// Non-positive values use the browser default of 16.
+	RootFontSize float64 `json:"rootFontSize,omitempty"`
+
+	// MaxCompletionItems bounds the items in one completion response. Longer
+	// lists keep the closest matches and are marked incomplete, so the client
+	// asks again as the user types. Non-positive values use the default of 1000.
+	MaxCompletionItems int `json:"maxCompletionItems,omitempty"`
+
+	// MaxDocumentColors bounds the color swatches in one documentColor
+	// response, which keeps huge generated stylesheets responsive. Colors past
+	// the limit, in document order, are left out. Non-positive values use the
+	// default of 5000.
+	MaxDocumentColors int `json:"maxDocumentColors,omitempty"`
+
+	// Scales declares the progression that the values of scale groups, such as
+	// spacing or type ramps, must follow. Tokens whose values fall off their
+	// scale are reported in the token file. No scales are checked by default.
+	Scales []ScaleRule `json:"scales,omitempty"`
+
+	// Strict reports $-prefixed properties in token files which are not DTCG
+	// keywords, such as a misspelled "$descripton", as errors. Off by default,
+	// since some tools write their own $-prefixed properties.
+	Strict bool `json:"strict,omitempty"`
+
+	// Figma imports the variables of a Figma file as read-only tokens,
+	// refreshed periodically. Experimental. nil means no import.
+	Figma *FigmaConfig `json:"figma,omitempty"`
+
+	// ReadOnly keeps the server from running or fetching anything on the
+	// workspace's behalf, and disables the commands that edit files, as in an
+	// untrusted workspace. See Restricted.
+	ReadOnly bool `json:"readOnly,omitempty"`
+}
+
+// FigmaConfig configures the import of a Figma file's variables. Each
+// variable collection becomes a top-level token group.
+type FigmaConfig struct {
+	// FileKey is the key in the Figma file's URL, e.g. "abc123" in
+	// https://www.figma.com/design/abc123/Tokens
+	FileKey string `json:"fileKey"`
+
+	// TokenEnv names the environment variable holding a Figma personal
+	// access token with the file_variables:read scope (default "FIGMA_TOKEN").
+	// The token itself is never read from configuration files, and TokenEnv
+	// is only read from the editor's settings, not package.json.
+	TokenEnv string `json:"tokenEnv,omitempty"`
+
+	// Endpoint is the base URL of the Figma REST API (default
+	// "https://api.figma.com"). Like TokenEnv, only the editor's settings set it.
+	Endpoint string `json:"endpoint,omitempty"`
+
+	// Modes maps collection names to the mode whose values are imported,
+	// e.g. {"Theme": "Dark"}. Other collections use their default mode.
+	Modes map[string]string `json:"modes,omitempty"`
+
+	// Prefix is the CSS variable prefix of the imported tokens
+	// (default: the global prefix)
+	Prefix string `json:"prefix,omitempty"`
+
+	// RefreshInterval is how often, in seconds, the variables are fetched
+	// again. Non-positive values use the default of 300.
+	RefreshInterval int `json:"refreshInterval,omitempty"`
+}
+
+// ScaleRule declares the progression of a scale group's values.
+// Set either Step, or Ratio and Base.
+type ScaleRule struct {
+	// Group is the dot-separated path of the group, e.g. "space" or "font.size".
+	// The group's direct child tokens are checked.
+	Group string `json:"group"`
+
+	// Step requires each value to be a multiple of a value, e.g. "4px"
+	Step string `json:"step,omitempty"`
+
+	// Ratio requires a modular scale: each value is Base times a whole power of Ratio
+	Ratio float64 `json:"ratio,omitempty"`
+
+	// Base is the first step of a modular scale, e.g. "16px"
+	Base string `json:"base,omitempty"`
+}
+
+// Values for ServerConfig.DimensionDisplay
+const (
+	DimensionDisplayRaw        = "raw"
+	DimensionDisplayNormalized = "normalized"
+	DimensionDisplayBoth       = "both"
+)
+
+// DefaultRootFontSize is the root font size in px when none is configured
+const DefaultRootFontSize = 16
+
+// RootFontSizePx returns the configured root font size, or the default
+func (c ServerConfig) RootFontSizePx() float64 {
+	if c.RootFontSize <= 0 {
+		return DefaultRootFontSize
+	}
+	return c.RootFontSize
+}
+
+// DefaultMaxCompletionItems is the completion response limit when none is configured
+const DefaultMaxCompletionItems = 1000
+
+// DefaultMaxDocumentColors is the documentColor response limit when none is configured
+const DefaultMaxDocumentColors = 5000
+
+// CompletionItemLimit returns the configured completion response limit, or the default
+func (c ServerConfig) CompletionItemLimit() int {
+	if c.MaxCompletionItems <= 0 {
+		return DefaultMaxCompletionItems
+	}
+	return c.MaxCompletionItems
+}
+
+// DocumentColorLimit returns the configured documentColor response limit, or the default
+func (c ServerConfig) DocumentColorLimit() int {
+	if c.MaxDocumentColors <= 0 {
+		return DefaultMaxDocumentColors
+	}
+	return c.MaxDocumentColors
+}
+
+// CompositeSerializer returns the configured serializer template for a token
+// type, matched case-insensitively, or "" if there is none
+func (c ServerConfig) CompositeSerializer(tokenType string) string {
+	for t, serializer := range c.CompositeSerializers {
+		if strings.EqualFold(t, tokenType) {
+			return serializer
+		}
+	}
+	return ""
+}
+
+// ShowPrefixEnabled reports whether UI strings include token prefixes
+func (c ServerConfig) ShowPrefixEnabled() bool { return enabled(c.ShowPrefix) }
+
+// Restricted returns the configuration in read-only mode: without network
+// fallback, Figma import, query overrides or git blame, all of which fetch or
+// run something that the workspace configures.
+func (c ServerConfig) Restricted() ServerConfig {
+	c.ReadOnly = true
+	c.NetworkFallback = false
+	c.Figma = nil
+	c.QueriesDir = ""
+	c.ValueHistory = false
+	return c
+}
+
+// NamingConfig controls CSS variable name formatting.
+// Empty fields keep the standard "--prefix-path-to-token" naming.
+type NamingConfig struct {
+	// Separator joins the prefix and path segments (default "-")
+	Separator string `json:"separator,omitempty"`
+
+	// Case controls segment casing: "kebab" or "camel".
+	// Empty preserves segments as written in the token file.
+	Case string `json:"case,omitempty"`
+
+	// Collisions controls what happens when two tokens format to the same name:
+	// "warn" (default), "first", or "error".
+	Collisions string `json:"collisions,omitempty"`
+}
+
+// FeatureToggles enables or disables individual LSP features.
+// A nil field means "not configured", which leaves the feature enabled.
+type FeatureToggles struct {
+	Hover          *bool `json:"hover,omitempty"`
+	Completion     *bool `json:"completion,omitempty"`
+	CodeActions    *bool `json:"codeActions,omitempty"`
+	DocumentColor  *bool `json:"documentColor,omitempty"`
+	SemanticTokens *bool `json:"semanticTokens,omitempty"`
+	Diagnostics    *bool `json:"diagnostics,omitempty"`
+}
+
+// HoverEnabled reports whether textDocument/hover is enabled
+func (f FeatureToggles) HoverEnabled() bool { return enabled(f.Hover) }
+
+// CompletionEnabled reports whether textDocument/completion is enabled
+func (f FeatureToggles) CompletionEnabled() bool { return enabled(f.Completion) }
+
+// CodeActionsEnabled reports whether textDocument/codeAction is enabled
+func (f FeatureToggles) CodeActionsEnabled() bool { return enabled(f.CodeActions) }
+
+// DocumentColorEnabled reports whether textDocument/documentColor is enabled
+func (f FeatureToggles) DocumentColorEnabled() bool { return enabled(f.DocumentColor) }
+
+// SemanticTokensEnabled reports whether textDocument/semanticTokens is enabled
+func (f FeatureToggles) SemanticTokensEnabled() bool { return enabled(f.SemanticTokens) }
+
+// DiagnosticsEnabled reports whether diagnostics (push and pull) are enabled
+func (f FeatureToggles) DiagnosticsEnabled() bool { return enabled(f.Diagnostics) }
+
+func enabled(toggle *bool) bool {
+	return toggle == nil || *toggle
 }
 
 // ServerState represents a snapshot of runtime state (NOT configuration)
@@ -13,3 +13,51 @@ func TestDefaultConfig(t *testing.T) {
 	assert.Empty(t, config.TokensFiles)
 	assert.Equal(t, []string{"_", "@", "DEFAULT"}, config.GroupMarkers)
 }
+
+func TestFeatureToggles(t *testing.T) {
+	off := false
+	on := true
+
+	t.Run("features are enabled by default", func(t *testing.T) {
+		features := DefaultConfig().Features
+		assert.True(t, features.HoverEnabled())
+		assert.True(t, features.CompletionEnabled())
+		assert.True(t, features.CodeActionsEnabled())
+		assert.True(t, features.DocumentColorEnabled())
+		assert.True(t, features.SemanticTokensEnabled())
+		assert.True(t, features.DiagnosticsEnabled())
+	})
+
+	t.Run("explicit toggles are respected", func(t *testing.T) {
+		features := FeatureToggles{Hover: &off, Completion: &on, Diagnostics: &off}
+		assert.False(t, features.HoverEnabled())
+		assert.True(t, features.CompletionEnabled())
+		assert.False(t, features.DiagnosticsEnabled())
+		assert.True(t, features.DocumentColorEnabled())
+	})
+}
+
+func TestRestricted(t *testing.T) {
+	config := DefaultConfig()
+	config.Prefix = "ds"
+	config.NetworkFallback = true
+	config.QueriesDir = "queries"
+	config.ValueHistory = true
+	config.Figma = &FigmaConfig{FileKey: "abc123"}
+
+	restricted := config.Restricted()
+	assert.True(t, restricted.ReadOnly)
+	assert.False(t, restricted.NetworkFallback)
+	assert.Empty(t, restricted.QueriesDir)
+	assert.False(t, restricted.ValueHistory)
+	assert.Nil(t, restricted.Figma)
+	assert.Equal(t, "ds", restricted.Prefix, "settings that only read the workspace are kept")
+	assert.True(t, config.NetworkFallback, "the receiver is unchanged")
+}
+
+func TestCompositeSerializer(t *testing.T) {
+	config := ServerConfig{CompositeSerializers: map[string]string{"Shadow": "{{.color}}"}}
+	assert.Equal(t, "{{.color}}", config.CompositeSerializer("shadow"))
+	assert.Empty(t, config.CompositeSerializer("border"))
+	assert.Empty(t, ServerConfig{}.CompositeSerializer("shadow"))
+}
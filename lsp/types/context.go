@@ -2,7 +2,9 @@ package types
 
 import (
 	"bennypowers.dev/dtls/internal/documents"
+	"bennypowers.dev/dtls/internal/gitblame"
 	"bennypowers.dev/dtls/internal/tokens"
+	"bennypowers.dev/dtls/internal/workspacefiles"
 	"github.com/tliron/glsp"
 	protocol "github.com/tliron/glsp/protocol_3_16"
 )
@@ -20,18 +22,53 @@ type ServerContext interface {
 	Token(name string) *tokens.Token
 	TokenManager() *tokens.Manager
 	TokenCount() int
+	// ValueHistory returns the commit that last changed a token's definition,
+	// or nil when the valueHistory setting of cfg is off or git can't tell
+	ValueHistory(cfg ServerConfig, token *tokens.Token) *gitblame.Annotation
+	// TokenSnapshot returns the snapshot saved with SetTokenSnapshot, or nil
+	TokenSnapshot() *tokens.Snapshot
+	SetTokenSnapshot(snapshot *tokens.Snapshot)
+	// VendorTokens returns the tokens of the node_modules package containing
+	// path, or nil when path is not in node_modules
+	VendorTokens(path string) *VendorTokens
 
 	// Workspace operations
 	RootURI() string
 	RootPath() string
 	SetRootURI(uri string)
 	SetRootPath(path string)
+	// Locale is the client's UI locale from initialize (e.g. "de-CH"), or empty
+	Locale() string
+	SetLocale(locale string)
+	// WorkspaceTrusted is false when the client reported an untrusted
+	// workspace in initialize, which restricts the configuration
+	WorkspaceTrusted() bool
+	SetWorkspaceTrusted(trusted bool)
+	// WorkspaceIndex returns the index of CSS variable references in the
+	// files under the workspace root, scanned in the background
+	WorkspaceIndex() *workspacefiles.Index
 
 	// Configuration
 	GetConfig() ServerConfig
 	SetConfig(config ServerConfig)
+	// SetTokensFiles replaces the configured tokensFiles, without touching
+	// the rest of the configuration
+	SetTokensFiles(files []any)
 	LoadPackageJsonConfig() error
 	IsTokenFile(path string) bool
+	// IsCustomPropertiesFile reports whether path is a configured generated
+	// postcss-custom-properties file
+	IsCustomPropertiesFile(path string) bool
+	// CustomPropertiesFiles returns the configured generated custom properties
+	// files, resolved against the workspace root
+	CustomPropertiesFiles() []string
+	// ReloadCustomPropertiesFile replaces the tokens of one custom properties
+	// file with the properties declared in content, and re-diagnoses the open
+	// documents which reference the properties that changed
+	ReloadCustomPropertiesFile(path, content string) error
+	// TokensFileMatches resolves a tokensFiles path against the workspace
+	// root and expands its glob, returning the existing files it matches
+	TokensFileMatches(path string) ([]string, error)
 
 	// Token file detection
 	// ShouldProcessAsTokenFile checks if a document should receive token file features.
@@ -41,12 +78,17 @@ type ServerContext interface {
 	// Workspace initialization (called by Initialize handler)
 	LoadTokensFromConfig() error
 	RegisterFileWatchers(ctx *glsp.Context) error
+	// WatchedFilePatterns returns the glob patterns currently registered
+	// with the client's file watcher, or nil when none are
+	WatchedFilePatterns() []string
 
 	// Load tokens from an open document (for files with Design Tokens schema)
 	LoadTokensFromDocumentContent(uri, languageID, content string) error
 
 	// File tracking (for managing loaded token files)
 	RemoveLoadedFile(path string)
+	// RenameTokenFile retargets loaded files and tokensFiles entries after a rename
+	RenameTokenFile(oldPath, newPath string) bool
 
 	// LSP context (for publishing diagnostics, etc.)
 	GLSPContext() *glsp.Context
@@ -66,6 +108,8 @@ type ServerContext interface {
 	SupportsDefinitionLinks() bool
 	SupportsDiagnosticRelatedInfo() bool
 	SupportsCodeActionLiterals() bool
+	// SupportsApplyEdit reports whether the client handles workspace/applyEdit
+	SupportsApplyEdit() bool
 
 	// Diagnostics mode (pull vs push)
 	UsePullDiagnostics() bool
@@ -73,9 +117,19 @@ type ServerContext interface {
 
 	// Diagnostics publishing
 	PublishDiagnostics(context *glsp.Context, uri string) error
+	// RefreshDiagnostics updates diagnostics for all open documents,
+	// via workspace/diagnostic/refresh for pull clients or publishDiagnostics for push clients
+	RefreshDiagnostics(context *glsp.Context) error
 
 	// Semantic tokens delta support
 	SemanticTokenCache() SemanticTokenCacher
+	// SemanticTokensLegend returns the legend declared in initialize, or the
+	// zero legend before then
+	SemanticTokensLegend() SemanticTokensLegend
+	SetSemanticTokensLegend(legend SemanticTokensLegend)
+
+	// Pull diagnostics result ID support
+	DiagnosticResultCache() DiagnosticResultCacher
 }
 
 // SemanticTokenCacheEntry holds cached semantic tokens for a document
@@ -94,4 +148,14 @@ type SemanticTokenCacher interface {
 	Invalidate(uri string)
 }
 
+// DiagnosticResultCacher is the interface for pull diagnostics result ID tracking
+type DiagnosticResultCacher interface {
+	Store(uri, fingerprint string) string
+	// StoreFile and File cache the diagnostics of files checked as they
+	// are on disk, by a stamp of their content and what else they depend on
+	StoreFile(uri, stamp, fingerprint string, diagnostics []protocol.Diagnostic) string
+	File(uri, stamp string) ([]protocol.Diagnostic, string, bool)
+	Invalidate(uri string)
+}
+
 // No need for ServerConfig interface - handlers can access fields directly
@@ -0,0 +1,27 @@
+package types
+
+import "slices"
+
+// SemanticTokensLegend is the semantic tokens legend declared to the client,
+// whose indices semantic tokens are encoded with
+type SemanticTokensLegend struct {
+	TokenTypes     []string `json:"tokenTypes"`
+	TokenModifiers []string `json:"tokenModifiers"`
+}
+
+// TypeIndex returns the index of a token type, or false when the legend
+// doesn't declare it
+func (l SemanticTokensLegend) TypeIndex(tokenType string) (int, bool) {
+	i := slices.Index(l.TokenTypes, tokenType)
+	return i, i >= 0
+}
+
+// ModifierMask returns the bit of a token modifier, or 0 when the legend
+// doesn't declare it
+func (l SemanticTokensLegend) ModifierMask(modifier string) int {
+	i := slices.Index(l.TokenModifiers, modifier)
+	if i < 0 {
+		return 0
+	}
+	return 1 << i
+}
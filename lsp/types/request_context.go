@@ -1,7 +1,13 @@
 package types
 
 import (
+	"fmt"
+	"strings"
+
+	"bennypowers.dev/dtls/internal/documents"
+	"bennypowers.dev/dtls/internal/i18n"
 	"github.com/tliron/glsp"
+	protocol "github.com/tliron/glsp/protocol_3_16"
 )
 
 // RequestContext contains all request-scoped data for an LSP method call.
@@ -10,7 +16,17 @@ import (
 type RequestContext struct {
 	Server   ServerContext // Server-wide context (documents, tokens, config)
 	GLSP     *glsp.Context // GLSP protocol context (Notify, Call methods)
+	config   *ServerConfig // Configuration snapshot, taken on first use
 	warnings []error       // Request-scoped warnings (collected during handler execution)
+	trace    requestTrace  // Details reported by $/logTrace when tracing is verbose
+}
+
+// requestTrace holds what a request worked on, for verbose tracing
+type requestTrace struct {
+	uri         string
+	size        int
+	varCalls    int
+	hasVarCalls bool
 }
 
 // NewRequestContext creates a new request context
@@ -21,6 +37,24 @@ func NewRequestContext(server ServerContext, glspContext *glsp.Context) *Request
 	}
 }
 
+// Config returns a snapshot of the server configuration, taken the first
+// time it is called. Handlers should prefer it to Server.GetConfig, so that a
+// configuration change arriving mid-request can't mix old and new settings
+// in one response.
+func (r *RequestContext) Config() ServerConfig {
+	if r.config == nil {
+		config := r.Server.GetConfig()
+		r.config = &config
+	}
+	return *r.config
+}
+
+// Localize formats a user-facing message in the client's UI language.
+// format is the English message; see package i18n.
+func (r *RequestContext) Localize(format string, args ...any) string {
+	return i18n.Sprintf(r.Server.Locale(), format, args...)
+}
+
 // AddWarning adds a non-fatal warning to this request.
 // Warnings are logged by middleware after successful handler completion.
 func (r *RequestContext) AddWarning(err error) {
@@ -29,6 +63,17 @@ func (r *RequestContext) AddWarning(err error) {
 	}
 }
 
+// ReportPartialResult sends part of the result with $/progress, when the
+// client asked for partial results by passing token. It reports whether it
+// did, in which case the part must be left out of the final response.
+func (r *RequestContext) ReportPartialResult(token *protocol.ProgressToken, value any) bool {
+	if token == nil || r.GLSP == nil || r.GLSP.Notify == nil {
+		return false
+	}
+	r.GLSP.Notify(protocol.MethodProgress, protocol.ProgressParams{Token: *token, Value: value})
+	return true
+}
+
 // Warnings returns all warnings collected during this request.
 // Returns nil if no warnings were added.
 func (r *RequestContext) Warnings() []error {
@@ -39,3 +84,31 @@ func (r *RequestContext) Warnings() []error {
 func (r *RequestContext) HasWarnings() bool {
 	return len(r.warnings) > 0
 }
+
+// TraceDocument records the document a request worked on, for verbose tracing
+func (r *RequestContext) TraceDocument(doc *documents.Document) {
+	if doc != nil {
+		r.trace.uri = doc.URI()
+		r.trace.size = len(doc.Content())
+	}
+}
+
+// TraceVarCalls records how many var() calls a request processed, for verbose tracing
+func (r *RequestContext) TraceVarCalls(n int) {
+	r.trace.varCalls = n
+	r.trace.hasVarCalls = true
+}
+
+// TraceDetails describes what the request worked on, e.g.
+// "document: file:///a.css (1024 bytes), var calls: 12".
+// Returns "" if nothing was recorded.
+func (r *RequestContext) TraceDetails() string {
+	var details []string
+	if r.trace.uri != "" {
+		details = append(details, fmt.Sprintf("document: %s (%d bytes)", r.trace.uri, r.trace.size))
+	}
+	if r.trace.hasVarCalls {
+		details = append(details, fmt.Sprintf("var calls: %d", r.trace.varCalls))
+	}
+	return strings.Join(details, ", ")
+}
@@ -5,7 +5,9 @@ import (
 	"testing"
 
 	"bennypowers.dev/dtls/internal/documents"
+	"bennypowers.dev/dtls/internal/gitblame"
 	"bennypowers.dev/dtls/internal/tokens"
+	"bennypowers.dev/dtls/internal/workspacefiles"
 	"github.com/stretchr/testify/assert"
 	"github.com/tliron/glsp"
 	protocol "github.com/tliron/glsp/protocol_3_16"
@@ -54,6 +56,49 @@ func TestRequestContext_ContextAccess(t *testing.T) {
 	assert.Equal(t, "testMethod", req.GLSP.Method)
 }
 
+func TestRequestContext_ConfigSnapshot(t *testing.T) {
+	mockServer := NewMockServerContextForTest()
+	mockServer.SetConfig(ServerConfig{Prefix: "before"})
+	req := NewRequestContext(mockServer, nil)
+
+	assert.Equal(t, "before", req.Config().Prefix)
+
+	// A configuration change mid-request doesn't affect the snapshot
+	mockServer.SetConfig(ServerConfig{Prefix: "after"})
+	assert.Equal(t, "before", req.Config().Prefix)
+
+	// The next request sees the new configuration
+	assert.Equal(t, "after", NewRequestContext(mockServer, nil).Config().Prefix)
+}
+
+func TestRequestContext_ReportPartialResult(t *testing.T) {
+	var sent []protocol.ProgressParams
+	req := NewRequestContext(&mockServerContextMinimal{}, &glsp.Context{
+		Notify: func(method string, params any) {
+			assert.Equal(t, protocol.MethodProgress, method)
+			sent = append(sent, params.(protocol.ProgressParams))
+		},
+	})
+	token := protocol.ProgressToken{Value: 7}
+
+	assert.False(t, req.ReportPartialResult(nil, []int{1}), "no token, no partial results")
+	assert.True(t, req.ReportPartialResult(&token, []int{2}))
+	assert.Equal(t, []protocol.ProgressParams{{Token: token, Value: []int{2}}}, sent)
+}
+
+func TestRequestContext_TraceDetails(t *testing.T) {
+	req := NewRequestContext(nil, nil)
+	assert.Empty(t, req.TraceDetails())
+
+	docs := documents.NewManager()
+	assert.NoError(t, docs.DidOpen("file:///a.css", "css", 1, "a { }"))
+	req.TraceDocument(docs.Get("file:///a.css"))
+	assert.Equal(t, "document: file:///a.css (5 bytes)", req.TraceDetails())
+
+	req.TraceVarCalls(0)
+	assert.Equal(t, "document: file:///a.css (5 bytes), var calls: 0", req.TraceDetails())
+}
+
 // Helper to create mock for these tests
 func NewMockServerContextForTest() *mockServerContextMinimal {
 	return &mockServerContextMinimal{}
@@ -65,6 +110,8 @@ type mockServerContextMinimal struct {
 	// cache holds a stable semantic token cache instance, lazily initialized on first access.
 	// This ensures consistent behavior across multiple SemanticTokenCache() calls.
 	cache SemanticTokenCacher
+
+	config ServerConfig
 }
 
 func (m *mockServerContextMinimal) Document(uri string) *documents.Document      { return nil }
@@ -77,13 +124,29 @@ func (m *mockServerContextMinimal) RootURI() string
 func (m *mockServerContextMinimal) RootPath() string                             { return "" }
 func (m *mockServerContextMinimal) SetRootURI(uri string)                        {}
 func (m *mockServerContextMinimal) SetRootPath(path string)                      {}
-func (m *mockServerContextMinimal) GetConfig() ServerConfig                      { return ServerConfig{} }
-func (m *mockServerContextMinimal) SetConfig(config ServerConfig)                {}
+func (m *mockServerContextMinimal) Locale() string                               { return "" }
+func (m *mockServerContextMinimal) SetLocale(locale string)                      {}
+func (m *mockServerContextMinimal) WorkspaceTrusted() bool                       { return true }
+func (m *mockServerContextMinimal) SetWorkspaceTrusted(trusted bool)             {}
+func (m *mockServerContextMinimal) GetConfig() ServerConfig                      { return m.config }
+func (m *mockServerContextMinimal) SetConfig(config ServerConfig)                { m.config = config }
+func (m *mockServerContextMinimal) SetTokensFiles(files []any)                  { m.config.TokensFiles = files }
 func (m *mockServerContextMinimal) LoadPackageJsonConfig() error                 { return nil }
 func (m *mockServerContextMinimal) IsTokenFile(path string) bool                 { return false }
+func (m *mockServerContextMinimal) IsCustomPropertiesFile(path string) bool      { return false }
+func (m *mockServerContextMinimal) CustomPropertiesFiles() []string                { return nil }
+func (m *mockServerContextMinimal) ReloadCustomPropertiesFile(path, content string) error { return nil }
+func (m *mockServerContextMinimal) TokensFileMatches(path string) ([]string, error) { return nil, nil }
+func (m *mockServerContextMinimal) WatchedFilePatterns() []string                  { return nil }
+func (m *mockServerContextMinimal) ValueHistory(cfg ServerConfig, token *tokens.Token) *gitblame.Annotation { return nil }
+func (m *mockServerContextMinimal) TokenSnapshot() *tokens.Snapshot                         { return nil }
+func (m *mockServerContextMinimal) SetTokenSnapshot(snapshot *tokens.Snapshot)              {}
+func (m *mockServerContextMinimal) VendorTokens(path string) *VendorTokens                  { return nil }
+func (m *mockServerContextMinimal) WorkspaceIndex() *workspacefiles.Index                     { return workspacefiles.NewIndex() }
 func (m *mockServerContextMinimal) LoadTokensFromConfig() error                  { return nil }
 func (m *mockServerContextMinimal) RegisterFileWatchers(ctx *glsp.Context) error { return nil }
 func (m *mockServerContextMinimal) RemoveLoadedFile(path string)                 {}
+func (m *mockServerContextMinimal) RenameTokenFile(oldPath, newPath string) bool { return false }
 func (m *mockServerContextMinimal) GLSPContext() *glsp.Context                   { return nil }
 func (m *mockServerContextMinimal) SetGLSPContext(ctx *glsp.Context)             {}
 func (m *mockServerContextMinimal) ClientDiagnosticCapability() *bool            { return nil }
@@ -95,9 +158,11 @@ func (m *mockServerContextMinimal) PreferredHoverFormat() protocol.MarkupKind {
 func (m *mockServerContextMinimal) SupportsDefinitionLinks() bool { return false }
 func (m *mockServerContextMinimal) SupportsDiagnosticRelatedInfo() bool { return false }
 func (m *mockServerContextMinimal) SupportsCodeActionLiterals() bool   { return true }
+func (m *mockServerContextMinimal) SupportsApplyEdit() bool { return false }
 func (m *mockServerContextMinimal) PublishDiagnostics(context *glsp.Context, uri string) error {
 	return nil
 }
+func (m *mockServerContextMinimal) RefreshDiagnostics(context *glsp.Context) error { return nil }
 func (m *mockServerContextMinimal) UsePullDiagnostics() bool         { return false }
 func (m *mockServerContextMinimal) SetUsePullDiagnostics(use bool)   {}
 func (m *mockServerContextMinimal) AddWarning(err error)             {}
@@ -106,6 +171,22 @@ func (m *mockServerContextMinimal) ShouldProcessAsTokenFile(uri string) bool { r
 func (m *mockServerContextMinimal) LoadTokensFromDocumentContent(uri, languageID, content string) error {
 	return nil
 }
+func (m *mockServerContextMinimal) DiagnosticResultCache() DiagnosticResultCacher {
+	return &mockDiagnosticResultCache{}
+}
+
+// mockDiagnosticResultCache is a minimal mock for DiagnosticResultCacher
+type mockDiagnosticResultCache struct{}
+
+func (m *mockDiagnosticResultCache) Store(uri, fingerprint string) string { return "" }
+func (m *mockDiagnosticResultCache) StoreFile(uri, stamp, fingerprint string, diagnostics []protocol.Diagnostic) string {
+	return ""
+}
+func (m *mockDiagnosticResultCache) File(uri, stamp string) ([]protocol.Diagnostic, string, bool) {
+	return nil, "", false
+}
+func (m *mockDiagnosticResultCache) Invalidate(uri string) {}
+
 func (m *mockServerContextMinimal) SemanticTokenCache() SemanticTokenCacher {
 	if m.cache == nil {
 		m.cache = &mockSemanticTokenCache{}
@@ -113,6 +194,11 @@ func (m *mockServerContextMinimal) SemanticTokenCache() SemanticTokenCacher {
 	return m.cache
 }
 
+func (m *mockServerContextMinimal) SemanticTokensLegend() SemanticTokensLegend {
+	return SemanticTokensLegend{}
+}
+func (m *mockServerContextMinimal) SetSemanticTokensLegend(legend SemanticTokensLegend) {}
+
 // mockSemanticTokenCache is a minimal mock for SemanticTokenCacher
 type mockSemanticTokenCache struct{}
 
@@ -0,0 +1,44 @@
+package types
+
+import (
+	"strings"
+
+	"bennypowers.dev/dtls/internal/tokens"
+)
+
+// VendorTokens describes the design tokens of a package in node_modules,
+// which stylesheets from that package use in place of the workspace's
+type VendorTokens struct {
+	// Package is the package name, e.g. "@rhds/elements"
+	Package string
+
+	// Prefixes are the CSS variable prefixes of the package's tokens, with
+	// their separator, e.g. "--rh-"
+	Prefixes []string
+
+	// Tokens holds the tokens loaded from the package's configured token
+	// files, or nil when it configures none
+	Tokens *tokens.Manager
+}
+
+// Token looks up a token of the package, returning nil when the package's
+// token files aren't known or don't define it
+func (v *VendorTokens) Token(name string) *tokens.Token {
+	if v == nil || v.Tokens == nil {
+		return nil
+	}
+	return v.Tokens.Get(name)
+}
+
+// Owns reports whether a CSS variable name carries one of the package's prefixes
+func (v *VendorTokens) Owns(name string) bool {
+	if v == nil {
+		return false
+	}
+	for _, prefix := range v.Prefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
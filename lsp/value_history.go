@@ -0,0 +1,75 @@
+package lsp
+
+import (
+	"os"
+	"strings"
+
+	"bennypowers.dev/dtls/internal/collections"
+	"bennypowers.dev/dtls/internal/gitblame"
+	"bennypowers.dev/dtls/internal/parser/tokenfile"
+	"bennypowers.dev/dtls/internal/tokens"
+	"bennypowers.dev/dtls/lsp/types"
+)
+
+// ValueHistory returns the commit that last changed a token's $value line.
+// It returns nil when the valueHistory setting of cfg is off, the token has
+// no source file, or git can't annotate the line (e.g. it is uncommitted).
+func (s *Server) ValueHistory(cfg types.ServerConfig, token *tokens.Token) *gitblame.Annotation {
+	if token == nil || token.FilePath == "" || !cfg.ValueHistory {
+		return nil
+	}
+	return s.blame.Annotate(token.FilePath, valueLine(token))
+}
+
+// valueLinesCacheSize is how many token $value lines are kept. Hovers and
+// completion items ask for the same tokens' history over and over.
+const valueLinesCacheSize = 512
+
+// valueLineKey identifies a token in a revision of its file on disk
+type valueLineKey struct {
+	path    string
+	modTime int64
+	size    int64
+	token   string
+}
+
+// valueLines holds the $value lines found by valueLine, so that token files
+// are only read and parsed again once they change
+var valueLines = collections.NewLRU[valueLineKey, uint32](valueLinesCacheSize)
+
+// valueLine returns the 0-based line of a token's $value in its file as it
+// is on disk, which is what git blames. Tokens whose $value can't be found,
+// such as tokens of files that no longer parse, fall back to their
+// definition line.
+func valueLine(token *tokens.Token) uint32 {
+	info, err := os.Stat(token.FilePath)
+	if err != nil {
+		return token.Line
+	}
+	key := valueLineKey{
+		path:    token.FilePath,
+		modTime: info.ModTime().UnixNano(),
+		size:    info.Size(),
+		token:   strings.Join(token.Path, "\x00"),
+	}
+	if line, ok := valueLines.Get(key); ok {
+		return line
+	}
+	line := readValueLine(token)
+	valueLines.Put(key, line)
+	return line
+}
+
+// readValueLine parses a token's file to find the line of its $value
+func readValueLine(token *tokens.Token) uint32 {
+	content, err := os.ReadFile(token.FilePath) //nolint:gosec // G304: Token file paths come from user configuration
+	if err != nil {
+		return token.Line
+	}
+	isJSON := !strings.HasSuffix(token.FilePath, ".yaml") && !strings.HasSuffix(token.FilePath, ".yml")
+	node := tokenfile.ValueNode(tokenfile.Outline(string(content), isJSON), token.Path)
+	if node == nil || node.Line < 1 {
+		return token.Line
+	}
+	return uint32(node.Line - 1) //nolint:gosec // G115: yaml line numbers are bounded by file size
+}
@@ -0,0 +1,89 @@
+package lsp
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"bennypowers.dev/dtls/internal/gitblame"
+	"bennypowers.dev/dtls/internal/tokens"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValueHistory(t *testing.T) {
+	s, err := NewServer()
+	require.NoError(t, err)
+
+	var blamed []string
+	s.blame = gitblame.NewCacheWithRunner(func(dir string, args ...string) ([]byte, error) {
+		blamed = append(blamed, args[len(args)-1])
+		return []byte("8c1b2f0d6e4a3b2c1d0e9f8a7b6c5d4e3f2a1b0c 3 3 1\ncommitter-time 1773480600\nsummary Darken primary\n\t\"#0000ff\"\n"), nil
+	})
+
+	token := &tokens.Token{Name: "color-primary", FilePath: "/project/tokens.json", Line: 2}
+
+	t.Run("off by default", func(t *testing.T) {
+		assert.Nil(t, s.ValueHistory(s.GetConfig(), token))
+		assert.Empty(t, blamed, "git is not run unless the setting is on")
+	})
+
+	cfg := s.GetConfig()
+	cfg.ValueHistory = true
+	s.SetConfig(cfg)
+
+	t.Run("annotates the definition line", func(t *testing.T) {
+		a := s.ValueHistory(s.GetConfig(), token)
+		require.NotNil(t, a)
+		assert.Equal(t, "Darken primary", a.Subject)
+		assert.Equal(t, []string{"tokens.json"}, blamed)
+	})
+
+	t.Run("annotates the $value line", func(t *testing.T) {
+		dir := t.TempDir()
+		path := filepath.Join(dir, "tokens.json")
+		require.NoError(t, os.WriteFile(path, []byte("{\n  \"color\": {\n    \"primary\": {\n      \"$type\": \"color\",\n      \"$value\": \"#00f\"\n    }\n  }\n}\n"), 0o644))
+
+		var lines []string
+		s.blame = gitblame.NewCacheWithRunner(func(dir string, args ...string) ([]byte, error) {
+			for i, arg := range args {
+				if arg == "-L" {
+					lines = append(lines, args[i+1])
+				}
+			}
+			return []byte("8c1b2f0d6e4a3b2c1d0e9f8a7b6c5d4e3f2a1b0c 5 5 1\ncommitter-time 1773480600\nsummary Darken primary\n\t\"#0000ff\"\n"), nil
+		})
+
+		token := &tokens.Token{Name: "color-primary", FilePath: path, Path: []string{"color", "primary"}, Line: 2}
+		require.NotNil(t, s.ValueHistory(s.GetConfig(), token))
+		assert.Equal(t, []string{"5,5"}, lines, "the $value line, not the token's key line")
+	})
+
+	t.Run("tokens without a source file", func(t *testing.T) {
+		assert.Nil(t, s.ValueHistory(s.GetConfig(), &tokens.Token{Name: "inline"}))
+		assert.Nil(t, s.ValueHistory(s.GetConfig(), nil))
+	})
+}
+
+func TestValueLine_Cache(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "tokens.json")
+	write := func(content string, modTime time.Time) {
+		t.Helper()
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+		require.NoError(t, os.Chtimes(path, modTime, modTime))
+	}
+	token := &tokens.Token{Name: "color-primary", FilePath: path, Path: []string{"color", "primary"}, Line: 2}
+	modTime := time.Date(2026, 3, 14, 9, 30, 0, 0, time.UTC)
+
+	write("{\n  \"color\": {\n    \"primary\": {\n      \"$type\": \"color\",\n      \"$value\": \"#00f\"\n    }\n  }\n}\n", modTime)
+	assert.Equal(t, uint32(4), valueLine(token))
+
+	// Same size and modification time: the file is not read again
+	write("{\n  \"color\": {\n    \"primary\": {\n      \"$value\": \"#00f\",\n      \"$type\": \"color\"\n    }\n  }\n}\n", modTime)
+	assert.Equal(t, uint32(4), valueLine(token), "the cached line is used for an unchanged file")
+
+	require.NoError(t, os.Chtimes(path, modTime.Add(time.Second), modTime.Add(time.Second)))
+	assert.Equal(t, uint32(3), valueLine(token), "a changed file is parsed again")
+}
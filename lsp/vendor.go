@@ -0,0 +1,116 @@
+package lsp
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"bennypowers.dev/dtls/internal/log"
+	"bennypowers.dev/dtls/internal/tokens"
+	"bennypowers.dev/dtls/internal/uriutil"
+	"bennypowers.dev/dtls/lsp/types"
+)
+
+// VendorTokens returns the tokens of the node_modules package containing
+// path, or nil when path is not in node_modules. Each package's
+// configuration and token files are read once.
+func (s *Server) VendorTokens(path string) *types.VendorTokens {
+	dir, name, ok := vendorPackage(path)
+	if !ok {
+		return nil
+	}
+
+	s.vendorMu.Lock()
+	defer s.vendorMu.Unlock()
+	if vendor, ok := s.vendorTokens[dir]; ok {
+		return vendor
+	}
+	vendor := loadVendorTokens(dir, name)
+	if s.vendorTokens == nil {
+		s.vendorTokens = make(map[string]*types.VendorTokens)
+	}
+	s.vendorTokens[dir] = vendor
+	return vendor
+}
+
+// vendorPackage returns the directory and name of the innermost node_modules
+// package containing path, e.g. "@rhds/elements"
+func vendorPackage(path string) (dir, name string, ok bool) {
+	parts := strings.Split(filepath.ToSlash(filepath.Clean(path)), "/")
+	for i := len(parts) - 2; i >= 0; i-- {
+		if parts[i] != "node_modules" {
+			continue
+		}
+		end := i + 2
+		if strings.HasPrefix(parts[i+1], "@") {
+			end++
+		}
+		// The package directory itself is not in the package
+		if end >= len(parts) {
+			return "", "", false
+		}
+		return filepath.FromSlash(strings.Join(parts[:end], "/")), strings.Join(parts[i+1:end], "/"), true
+	}
+	return "", "", false
+}
+
+// loadVendorTokens reads a package's design tokens configuration, from its
+// package.json or .config/design-tokens file, and loads its token files.
+// Packages without configuration have no prefixes or tokens.
+func loadVendorTokens(dir, name string) *types.VendorTokens {
+	vendor := &types.VendorTokens{Package: name}
+	cfg, err := ReadPackageJsonConfig(dir)
+	if err != nil {
+		log.Warn("Failed to read the design tokens configuration of %s: %v", name, err)
+		return vendor
+	}
+	if cfg == nil {
+		return vendor
+	}
+
+	separator := cfg.Naming.Separator
+	if separator == "" {
+		separator = tokens.DefaultSeparator
+	}
+	addPrefix := func(prefix string) {
+		if prefix == "" {
+			return
+		}
+		prefix = "--" + strings.ReplaceAll(prefix, ".", separator) + separator
+		if !slices.Contains(vendor.Prefixes, prefix) {
+			vendor.Prefixes = append(vendor.Prefixes, prefix)
+		}
+	}
+	addPrefix(cfg.Prefix)
+
+	manager := tokens.NewManager()
+	manager.SetNameFormat(tokens.NameFormat{Separator: cfg.Naming.Separator})
+	for _, item := range cfg.TokensFiles {
+		path, opts, err := parseTokenFileItem(item, cfg.Prefix, cfg.GroupMarkers)
+		if err != nil || path == "" {
+			continue
+		}
+		addPrefix(opts.Prefix)
+
+		file, err := normalizePath(path, dir)
+		if err != nil {
+			log.Warn("Failed to resolve token file %s of %s: %v", path, name, err)
+			continue
+		}
+		data, err := os.ReadFile(filepath.Clean(file))
+		if err != nil {
+			log.Warn("Failed to read token file %s of %s: %v", file, name, err)
+			continue
+		}
+		if _, err := addTokens(manager, data, file, uriutil.PathToURI(file), opts); err != nil {
+			log.Warn("Failed to load token file %s of %s: %v", file, name, err)
+		}
+	}
+
+	if manager.Count() > 0 {
+		resolveTokens(manager.GetAll())
+		vendor.Tokens = manager
+	}
+	return vendor
+}
@@ -0,0 +1,65 @@
+package lsp
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVendorPackage(t *testing.T) {
+	tests := []struct {
+		name string
+		path string
+		dir  string
+		pkg  string
+	}{
+		{name: "unscoped", path: "/ws/node_modules/tokens/css/a.css", dir: "/ws/node_modules/tokens", pkg: "tokens"},
+		{name: "scoped", path: "/ws/node_modules/@acme/elements/button.css", dir: "/ws/node_modules/@acme/elements", pkg: "@acme/elements"},
+		{name: "nested", path: "/ws/node_modules/a/node_modules/b/b.css", dir: "/ws/node_modules/a/node_modules/b", pkg: "b"},
+		{name: "workspace file", path: "/ws/src/a.css"},
+		{name: "package directory", path: "/ws/node_modules/@acme"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, pkg, ok := vendorPackage(filepath.FromSlash(tt.path))
+			assert.Equal(t, tt.pkg != "", ok)
+			assert.Equal(t, filepath.FromSlash(tt.dir), dir)
+			assert.Equal(t, tt.pkg, pkg)
+		})
+	}
+}
+
+func TestVendorTokens(t *testing.T) {
+	root := t.TempDir()
+	pkg := filepath.Join(root, "node_modules", "@acme", "elements")
+	require.NoError(t, os.MkdirAll(pkg, 0o750))
+	require.NoError(t, os.WriteFile(filepath.Join(pkg, "package.json"), []byte(`{
+		"name": "@acme/elements",
+		"designTokensLanguageServer": {"prefix": "acme", "tokensFiles": ["./tokens.json"]}
+	}`), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(pkg, "tokens.json"),
+		[]byte(`{"color": {"accent": {"$type": "color", "$value": "#ff0000"}}}`), 0o600))
+
+	server, err := NewServer()
+	require.NoError(t, err)
+	server.SetRootPath(root)
+
+	vendor := server.VendorTokens(filepath.Join(pkg, "button.css"))
+	require.NotNil(t, vendor)
+	assert.Equal(t, "@acme/elements", vendor.Package)
+	assert.Equal(t, []string{"--acme-"}, vendor.Prefixes)
+	assert.NotNil(t, vendor.Token("--acme-color-accent"))
+	assert.Nil(t, server.Token("--acme-color-accent"), "vendor tokens are not workspace tokens")
+	assert.Same(t, vendor, server.VendorTokens(filepath.Join(pkg, "css", "card.css")), "read once per package")
+
+	// Packages without configuration own no names
+	other := server.VendorTokens(filepath.Join(root, "node_modules", "other", "a.css"))
+	require.NotNil(t, other)
+	assert.Empty(t, other.Prefixes)
+	assert.False(t, other.Owns("--acme-color-accent"))
+
+	assert.Nil(t, server.VendorTokens(filepath.Join(root, "src", "a.css")))
+}
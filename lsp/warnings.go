@@ -0,0 +1,59 @@
+package lsp
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"bennypowers.dev/dtls/internal/collections"
+	"bennypowers.dev/dtls/lsp/methods/workspace"
+	"bennypowers.dev/dtls/lsp/types"
+	"github.com/tliron/glsp"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+// warningNoticeInterval limits how often window/showMessage is sent for
+// warnings from the same method
+const warningNoticeInterval = 30 * time.Second
+
+// lastWarningNotice tracks when a warning notice was last shown, per method
+var lastWarningNotice sync.Map // map[string]time.Time
+
+// userInitiatedMethods are the requests a user makes on purpose, such as
+// running a command or applying a code action. Their warnings explain why
+// part of what the user asked for was skipped, so they are shown, not just
+// logged. Requests the editor sends on its own, like codeAction on every
+// cursor move, only log theirs.
+var userInitiatedMethods = collections.NewSet(
+	"workspace/executeCommand",
+	"codeAction/resolve",
+	"textDocument/rename",
+)
+
+// reportWarnings logs the warnings a request collected with AddWarning via
+// window/logMessage. For user-initiated requests it also shows the first of
+// them (rate-limited) via window/showMessage.
+func reportWarnings(glspCtx *glsp.Context, methodName string, req *types.RequestContext) {
+	if !req.HasWarnings() {
+		return
+	}
+	warnings := req.Warnings()
+	for _, w := range warnings {
+		workspace.LogWarning(glspCtx, "%s warning: %v", methodName, w)
+	}
+
+	if !userInitiatedMethods.Has(methodName) {
+		return
+	}
+	now := time.Now()
+	if last, ok := lastWarningNotice.Load(methodName); ok && now.Sub(last.(time.Time)) < warningNoticeInterval {
+		return
+	}
+	lastWarningNotice.Store(methodName, now)
+
+	message := fmt.Sprintf("Design Tokens Language Server: %v", warnings[0])
+	if len(warnings) > 1 {
+		message += fmt.Sprintf(" (and %d more warnings; see the output log)", len(warnings)-1)
+	}
+	workspace.ShowMessage(glspCtx, protocol.MessageTypeWarning, message)
+}
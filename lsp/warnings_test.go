@@ -0,0 +1,78 @@
+package lsp
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"bennypowers.dev/dtls/lsp/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tliron/glsp"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+func TestMethod_Warnings(t *testing.T) {
+	handler := func(req *types.RequestContext, params string) (string, error) {
+		req.AddWarning(errors.New("cannot format token \"shadow\" for fallback"))
+		req.AddWarning(errors.New("skipped edit"))
+		return "ok", nil
+	}
+
+	shown := make(chan *protocol.ShowMessageParams, 4)
+	logged := make(chan *protocol.LogMessageParams, 8)
+	glspCtx := &glsp.Context{Notify: func(method string, params any) {
+		switch method {
+		case protocol.ServerWindowShowMessage:
+			shown <- params.(*protocol.ShowMessageParams)
+		case protocol.ServerWindowLogMessage:
+			logged <- params.(*protocol.LogMessageParams)
+		}
+	}}
+
+	expectNoShow := func(t *testing.T) {
+		t.Helper()
+		select {
+		case msg := <-shown:
+			t.Fatalf("unexpected showMessage: %s", msg.Message)
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+
+	t.Run("editor-initiated requests only log", func(t *testing.T) {
+		_, err := method(&mockServerContext{}, "textDocument/codeAction", handler)(glspCtx, "params")
+		require.NoError(t, err)
+
+		for range 2 {
+			select {
+			case msg := <-logged:
+				assert.Equal(t, protocol.MessageTypeWarning, msg.Type)
+			case <-time.After(time.Second):
+				t.Fatal("warning not logged")
+			}
+		}
+		expectNoShow(t)
+	})
+
+	t.Run("user-initiated requests show the first warning", func(t *testing.T) {
+		lastWarningNotice.Delete("workspace/executeCommand")
+		t.Cleanup(func() { lastWarningNotice.Delete("workspace/executeCommand") })
+		wrapped := method(&mockServerContext{}, "workspace/executeCommand", handler)
+
+		_, err := wrapped(glspCtx, "params")
+		require.NoError(t, err)
+		select {
+		case msg := <-shown:
+			assert.Equal(t, protocol.MessageTypeWarning, msg.Type)
+			assert.Contains(t, msg.Message, `cannot format token "shadow" for fallback`)
+			assert.Contains(t, msg.Message, "and 1 more")
+		case <-time.After(time.Second):
+			t.Fatal("no showMessage sent")
+		}
+
+		// Repeats within the interval are rate-limited
+		_, err = wrapped(glspCtx, "params")
+		require.NoError(t, err)
+		expectNoShow(t)
+	})
+}
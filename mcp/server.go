@@ -0,0 +1,153 @@
+// Package mcp serves design token queries over the Model Context Protocol,
+// so AI coding assistants can look up tokens and validate CSS while editing.
+//
+// The server speaks JSON-RPC 2.0 over stdio with newline-delimited messages,
+// and exposes its queries as MCP tools backed by an analyzer.Analyzer.
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+
+	"bennypowers.dev/dtls/internal/log"
+	"bennypowers.dev/dtls/internal/version"
+	"bennypowers.dev/dtls/pkg/analyzer"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// serverName identifies the server to MCP clients
+const serverName = "design-tokens-language-server"
+
+// supportedProtocolVersions lists the MCP revisions the server speaks, newest first
+var supportedProtocolVersions = []string{"2025-06-18", "2025-03-26", "2024-11-05"}
+
+// Server answers MCP requests using an analyzer's tokens
+type Server struct {
+	analyzer *analyzer.Analyzer
+}
+
+// NewServer creates an MCP server backed by a
+func NewServer(a *analyzer.Analyzer) *Server {
+	return &Server{analyzer: a}
+}
+
+// RunStdio serves MCP on stdin/stdout until the client disconnects
+func (s *Server) RunStdio() error {
+	log.Info("MCP server reading from stdin, writing to stdout")
+	s.Serve(context.Background(), stdio{})
+	log.Info("MCP stdin/stdout connection closed")
+	return nil
+}
+
+// Serve handles MCP messages on rwc until it is closed
+func (s *Server) Serve(ctx context.Context, rwc io.ReadWriteCloser) {
+	stream := jsonrpc2.NewBufferedStream(rwc, jsonrpc2.PlainObjectCodec{})
+	conn := jsonrpc2.NewConn(ctx, stream, jsonrpc2.HandlerWithError(s.handle))
+	<-conn.DisconnectNotify()
+}
+
+type initializeParams struct {
+	ProtocolVersion string `json:"protocolVersion"`
+}
+
+type initializeResult struct {
+	ProtocolVersion string         `json:"protocolVersion"`
+	Capabilities    map[string]any `json:"capabilities"`
+	ServerInfo      serverInfo     `json:"serverInfo"`
+}
+
+type serverInfo struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+type toolsListResult struct {
+	Tools []tool `json:"tools"`
+}
+
+type toolCallParams struct {
+	Name      string          `json:"name"`
+	Arguments json.RawMessage `json:"arguments"`
+}
+
+// handle dispatches an MCP request. Notifications need no reply, so only
+// requests reach the result or error paths.
+func (s *Server) handle(_ context.Context, _ *jsonrpc2.Conn, req *jsonrpc2.Request) (any, error) {
+	if req.Notif {
+		log.Debug("MCP notification: %s", req.Method)
+		return nil, nil
+	}
+
+	switch req.Method {
+	case "initialize":
+		var params initializeParams
+		if err := unmarshalParams(req, &params); err != nil {
+			return nil, err
+		}
+		return initializeResult{
+			ProtocolVersion: negotiateProtocolVersion(params.ProtocolVersion),
+			Capabilities:    map[string]any{"tools": map[string]any{}},
+			ServerInfo:      serverInfo{Name: serverName, Version: version.GetVersion()},
+		}, nil
+
+	case "ping":
+		return map[string]any{}, nil
+
+	case "tools/list":
+		return toolsListResult{Tools: tools}, nil
+
+	case "tools/call":
+		var params toolCallParams
+		if err := unmarshalParams(req, &params); err != nil {
+			return nil, err
+		}
+		return s.callTool(params.Name, params.Arguments)
+
+	default:
+		return nil, &jsonrpc2.Error{
+			Code:    jsonrpc2.CodeMethodNotFound,
+			Message: fmt.Sprintf("method not supported: %s", req.Method),
+		}
+	}
+}
+
+// negotiateProtocolVersion returns the client's requested version when
+// supported, else the newest version the server supports
+func negotiateProtocolVersion(requested string) string {
+	if slices.Contains(supportedProtocolVersions, requested) {
+		return requested
+	}
+	return supportedProtocolVersions[0]
+}
+
+func unmarshalParams(req *jsonrpc2.Request, v any) error {
+	if req.Params == nil {
+		return nil
+	}
+	if err := json.Unmarshal(*req.Params, v); err != nil {
+		return &jsonrpc2.Error{Code: jsonrpc2.CodeInvalidParams, Message: err.Error()}
+	}
+	return nil
+}
+
+// stdio adapts the process's stdin/stdout to an io.ReadWriteCloser
+type stdio struct{}
+
+func (stdio) Read(p []byte) (int, error) {
+	return os.Stdin.Read(p)
+}
+
+func (stdio) Write(p []byte) (int, error) {
+	return os.Stdout.Write(p)
+}
+
+func (stdio) Close() error {
+	if err := os.Stdin.Close(); err != nil {
+		return err
+	}
+	return os.Stdout.Close()
+}
@@ -0,0 +1,185 @@
+package mcp_test
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"path/filepath"
+	"testing"
+
+	"bennypowers.dev/dtls/mcp"
+	"bennypowers.dev/dtls/pkg/analyzer"
+	"github.com/sourcegraph/jsonrpc2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type toolResult struct {
+	Content []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	} `json:"content"`
+	IsError bool `json:"isError"`
+}
+
+// connect serves MCP over an in-memory pipe and returns a client connection
+func connect(t *testing.T) *jsonrpc2.Conn {
+	t.Helper()
+	a, err := analyzer.New(analyzer.Options{
+		Prefix:      "ds",
+		TokensFiles: []analyzer.TokensFile{{Path: filepath.Join("testdata", "tokens.json")}},
+	})
+	require.NoError(t, err)
+
+	serverSide, clientSide := net.Pipe()
+	ctx, cancel := context.WithCancel(context.Background())
+	go mcp.NewServer(a).Serve(ctx, serverSide)
+
+	conn := jsonrpc2.NewConn(ctx,
+		jsonrpc2.NewBufferedStream(clientSide, jsonrpc2.PlainObjectCodec{}),
+		jsonrpc2.HandlerWithError(func(context.Context, *jsonrpc2.Conn, *jsonrpc2.Request) (any, error) { return nil, nil }))
+	t.Cleanup(func() {
+		_ = conn.Close()
+		cancel()
+		_ = a.Close()
+	})
+	return conn
+}
+
+func callTool(t *testing.T, conn *jsonrpc2.Conn, name string, arguments map[string]any) toolResult {
+	t.Helper()
+	var result toolResult
+	require.NoError(t, conn.Call(context.Background(), "tools/call",
+		map[string]any{"name": name, "arguments": arguments}, &result))
+	require.Len(t, result.Content, 1)
+	assert.Equal(t, "text", result.Content[0].Type)
+	return result
+}
+
+func TestInitialize(t *testing.T) {
+	conn := connect(t)
+
+	var result struct {
+		ProtocolVersion string         `json:"protocolVersion"`
+		Capabilities    map[string]any `json:"capabilities"`
+		ServerInfo      struct {
+			Name string `json:"name"`
+		} `json:"serverInfo"`
+	}
+
+	t.Run("supported version", func(t *testing.T) {
+		require.NoError(t, conn.Call(context.Background(), "initialize", map[string]any{
+			"protocolVersion": "2024-11-05",
+			"capabilities":    map[string]any{},
+			"clientInfo":      map[string]any{"name": "test", "version": "1"},
+		}, &result))
+		assert.Equal(t, "2024-11-05", result.ProtocolVersion)
+		assert.Contains(t, result.Capabilities, "tools")
+		assert.Equal(t, "design-tokens-language-server", result.ServerInfo.Name)
+	})
+
+	t.Run("unsupported version", func(t *testing.T) {
+		require.NoError(t, conn.Call(context.Background(), "initialize", map[string]any{"protocolVersion": "1999-01-01"}, &result))
+		assert.Equal(t, "2025-06-18", result.ProtocolVersion)
+	})
+
+	require.NoError(t, conn.Notify(context.Background(), "notifications/initialized", nil))
+	var pong map[string]any
+	require.NoError(t, conn.Call(context.Background(), "ping", nil, &pong))
+}
+
+func TestToolsList(t *testing.T) {
+	conn := connect(t)
+
+	var result struct {
+		Tools []struct {
+			Name        string         `json:"name"`
+			InputSchema map[string]any `json:"inputSchema"`
+		} `json:"tools"`
+	}
+	require.NoError(t, conn.Call(context.Background(), "tools/list", nil, &result))
+
+	var names []string
+	for _, tool := range result.Tools {
+		names = append(names, tool.Name)
+		assert.Equal(t, "object", tool.InputSchema["type"])
+	}
+	assert.Equal(t, []string{"lookupToken", "searchTokens", "validateCss"}, names)
+}
+
+func TestLookupToken(t *testing.T) {
+	conn := connect(t)
+
+	for _, name := range []string{"color.primary", "--ds-color-primary"} {
+		result := callTool(t, conn, "lookupToken", map[string]any{"name": name})
+		require.False(t, result.IsError)
+
+		var token analyzer.Token
+		require.NoError(t, json.Unmarshal([]byte(result.Content[0].Text), &token))
+		assert.Equal(t, "--ds-color-primary", token.CSSVariable)
+		assert.Equal(t, "#0000ff", token.Value)
+	}
+
+	result := callTool(t, conn, "lookupToken", map[string]any{"name": "color.missing"})
+	assert.True(t, result.IsError)
+	assert.Contains(t, result.Content[0].Text, "no token named color.missing")
+}
+
+func TestSearchTokens(t *testing.T) {
+	conn := connect(t)
+
+	search := func(arguments map[string]any) []string {
+		result := callTool(t, conn, "searchTokens", arguments)
+		require.False(t, result.IsError)
+		var found []analyzer.Token
+		require.NoError(t, json.Unmarshal([]byte(result.Content[0].Text), &found))
+		names := []string{}
+		for _, token := range found {
+			names = append(names, token.CSSVariable)
+		}
+		return names
+	}
+
+	assert.Equal(t, []string{"--ds-color-danger"}, search(map[string]any{"query": "DESTRUCTIVE"}), "matches descriptions")
+	assert.Equal(t, []string{"--ds-color-primary"}, search(map[string]any{"query": "#0000FF"}), "matches values")
+	assert.Equal(t, []string{"--ds-space-small"}, search(map[string]any{"type": "dimension"}))
+	assert.Len(t, search(map[string]any{"query": "color", "limit": 2}), 2)
+	assert.Empty(t, search(map[string]any{"query": "nothing-matches"}))
+}
+
+func TestValidateCss(t *testing.T) {
+	conn := connect(t)
+
+	result := callTool(t, conn, "validateCss", map[string]any{
+		"content": ".a { color: var(--ds-color-legacy); background: var(--ds-color-primary, red); }",
+	})
+	require.False(t, result.IsError)
+	var diagnostics []analyzer.Diagnostic
+	require.NoError(t, json.Unmarshal([]byte(result.Content[0].Text), &diagnostics))
+	require.Len(t, diagnostics, 2)
+	assert.True(t, diagnostics[0].Deprecated)
+	assert.Equal(t, analyzer.SeverityError, diagnostics[1].Severity)
+
+	result = callTool(t, conn, "validateCss", map[string]any{
+		"content":    "const s = css`:host { color: var(--ds-color-primary); }`;",
+		"languageId": "typescript",
+	})
+	require.False(t, result.IsError)
+	assert.JSONEq(t, "[]", result.Content[0].Text)
+
+	result = callTool(t, conn, "validateCss", map[string]any{"content": "", "languageId": "markdown"})
+	assert.True(t, result.IsError)
+}
+
+func TestErrors(t *testing.T) {
+	conn := connect(t)
+
+	var rpcErr *jsonrpc2.Error
+	err := conn.Call(context.Background(), "tools/call", map[string]any{"name": "dropTables"}, nil)
+	require.ErrorAs(t, err, &rpcErr)
+	assert.Equal(t, int64(jsonrpc2.CodeInvalidParams), rpcErr.Code)
+
+	err = conn.Call(context.Background(), "resources/list", nil, nil)
+	require.ErrorAs(t, err, &rpcErr)
+	assert.Equal(t, int64(jsonrpc2.CodeMethodNotFound), rpcErr.Code)
+}
@@ -0,0 +1,187 @@
+package mcp
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"bennypowers.dev/dtls/pkg/analyzer"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// defaultSearchLimit caps searchTokens results when the client sets no limit
+const defaultSearchLimit = 50
+
+type tool struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description"`
+	InputSchema map[string]any `json:"inputSchema"`
+}
+
+type toolResult struct {
+	Content []textContent `json:"content"`
+	IsError bool          `json:"isError,omitempty"`
+}
+
+type textContent struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+var tools = []tool{
+	{
+		Name:        "lookupToken",
+		Description: "Look up a design token by name (e.g. color.primary) or CSS custom property (e.g. --ds-color-primary), returning its value, type, description, and deprecation status.",
+		InputSchema: objectSchema(map[string]any{
+			"name": stringSchema("Token name or CSS custom property"),
+		}, "name"),
+	},
+	{
+		Name:        "searchTokens",
+		Description: "Search design tokens by name, CSS custom property, value, or description. Use this to find the right token for a value or purpose.",
+		InputSchema: objectSchema(map[string]any{
+			"query": stringSchema("Case-insensitive text to search for; empty matches every token"),
+			"type":  stringSchema("Only return tokens of this $type, e.g. color or dimension"),
+			"limit": map[string]any{"type": "integer", "description": fmt.Sprintf("Maximum results (default %d)", defaultSearchLimit)},
+		}),
+	},
+	{
+		Name:        "validateCss",
+		Description: "Check CSS (or HTML, SCSS, JavaScript, TypeScript with embedded CSS) for design token problems, such as deprecated tokens or var() fallbacks that don't match the token's value.",
+		InputSchema: objectSchema(map[string]any{
+			"content":    stringSchema("Source to validate"),
+			"languageId": stringSchema("LSP language ID of the source, e.g. css, scss, html, typescript (default css)"),
+		}, "content"),
+	},
+}
+
+func objectSchema(properties map[string]any, required ...string) map[string]any {
+	schema := map[string]any{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+func stringSchema(description string) map[string]any {
+	return map[string]any{"type": "string", "description": description}
+}
+
+// callTool runs a tool. Unknown tools and malformed arguments are protocol
+// errors; failures of the tool itself are reported in the result.
+func (s *Server) callTool(name string, arguments json.RawMessage) (*toolResult, error) {
+	switch name {
+	case "lookupToken":
+		var args struct {
+			Name string `json:"name"`
+		}
+		if err := unmarshalArguments(arguments, &args); err != nil {
+			return nil, err
+		}
+		return s.lookupToken(args.Name), nil
+
+	case "searchTokens":
+		var args struct {
+			Query string `json:"query"`
+			Type  string `json:"type"`
+			Limit int    `json:"limit"`
+		}
+		if err := unmarshalArguments(arguments, &args); err != nil {
+			return nil, err
+		}
+		return s.searchTokens(args.Query, args.Type, args.Limit), nil
+
+	case "validateCss":
+		var args struct {
+			Content    string `json:"content"`
+			LanguageID string `json:"languageId"`
+		}
+		if err := unmarshalArguments(arguments, &args); err != nil {
+			return nil, err
+		}
+		return s.validateCSS(args.Content, args.LanguageID), nil
+
+	default:
+		return nil, &jsonrpc2.Error{
+			Code:    jsonrpc2.CodeInvalidParams,
+			Message: fmt.Sprintf("unknown tool: %s", name),
+		}
+	}
+}
+
+func (s *Server) lookupToken(name string) *toolResult {
+	if name == "" {
+		return errorResult("name is required")
+	}
+	token, ok := s.analyzer.Token(name)
+	if !ok {
+		return errorResult(fmt.Sprintf("no token named %s", name))
+	}
+	return jsonResult(token)
+}
+
+func (s *Server) searchTokens(query, tokenType string, limit int) *toolResult {
+	if limit <= 0 {
+		limit = defaultSearchLimit
+	}
+	query = strings.ToLower(query)
+
+	matches := []analyzer.Token{}
+	for _, token := range s.analyzer.Tokens() {
+		if tokenType != "" && token.Type != tokenType {
+			continue
+		}
+		if query != "" && !tokenMatches(token, query) {
+			continue
+		}
+		matches = append(matches, token)
+		if len(matches) == limit {
+			break
+		}
+	}
+	return jsonResult(matches)
+}
+
+// tokenMatches reports whether a lowercase query appears in any of the
+// token's searchable fields
+func tokenMatches(token analyzer.Token, query string) bool {
+	for _, field := range []string{token.Name, token.CSSVariable, token.Value, token.ResolvedValue, token.Description} {
+		if strings.Contains(strings.ToLower(field), query) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Server) validateCSS(content, languageID string) *toolResult {
+	if languageID == "" {
+		languageID = "css"
+	}
+	diagnostics, err := s.analyzer.Analyze("mcp-input", languageID, content)
+	if err != nil {
+		return errorResult(err.Error())
+	}
+	return jsonResult(diagnostics)
+}
+
+func unmarshalArguments(arguments json.RawMessage, v any) error {
+	if len(arguments) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(arguments, v); err != nil {
+		return &jsonrpc2.Error{Code: jsonrpc2.CodeInvalidParams, Message: fmt.Sprintf("invalid arguments: %v", err)}
+	}
+	return nil
+}
+
+func jsonResult(v any) *toolResult {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return errorResult(err.Error())
+	}
+	return &toolResult{Content: []textContent{{Type: "text", Text: string(data)}}}
+}
+
+func errorResult(message string) *toolResult {
+	return &toolResult{Content: []textContent{{Type: "text", Text: message}}, IsError: true}
+}
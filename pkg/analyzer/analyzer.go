@@ -0,0 +1,241 @@
+// Package analyzer embeds the design tokens language server's analysis engine
+// in other Go programs, such as CI linters and code generators.
+//
+// An Analyzer loads design tokens the way the language server does, from the
+// workspace's package.json settings or from explicitly listed files, then
+// scans CSS, SCSS, HTML, and JavaScript/TypeScript sources for token usage
+// and reports the same diagnostics an editor would show.
+//
+//	a, err := analyzer.New(analyzer.Options{Root: "."})
+//	if err != nil { ... }
+//	defer a.Close()
+//	diagnostics, err := a.AnalyzeFile("src/button.css")
+//
+// The types in this package are stable; they do not expose the language
+// server's internal or protocol types.
+package analyzer
+
+import (
+	"cmp"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"bennypowers.dev/dtls/internal/documents"
+	"bennypowers.dev/dtls/internal/parser"
+	"bennypowers.dev/dtls/internal/uriutil"
+	"bennypowers.dev/dtls/lsp"
+	"bennypowers.dev/dtls/lsp/methods/textDocument/diagnostic"
+)
+
+// Options configures a new Analyzer
+type Options struct {
+	// Root is the workspace root. Relative token file paths resolve against it,
+	// and its package.json "designTokensLanguageServer" settings are read.
+	Root string
+
+	// TokensFiles lists the token files to load. When empty, the tokensFiles
+	// setting from Root's package.json is used.
+	TokensFiles []TokensFile
+
+	// Prefix is the CSS variable prefix for token files which don't set their own
+	Prefix string
+}
+
+// TokensFile is a design tokens file to load
+type TokensFile struct {
+	// Path is a file path (absolute or relative to Options.Root),
+	// or an npm:/jsr: package specifier
+	Path string
+
+	// Prefix overrides Options.Prefix for this file
+	Prefix string
+
+	// GroupMarkers are token names which are also groups, e.g. "DEFAULT"
+	GroupMarkers []string
+}
+
+// Analyzer loads design tokens and analyzes documents which use them.
+// It is safe for concurrent use.
+//
+// Settings such as languageOverrides and queriesDir are process-wide, so
+// analyzers with different settings should not run in the same process.
+type Analyzer struct {
+	// server loads the tokens and configuration. Documents are analyzed
+	// against it as a diagnostic.Context; they are never opened in it.
+	server *lsp.Server
+}
+
+// New creates an Analyzer and loads its tokens.
+// A partially loaded token set is still usable: New returns the Analyzer
+// alongside an error describing the files which failed to load.
+func New(opts Options) (*Analyzer, error) {
+	server, err := lsp.NewServer()
+	if err != nil {
+		return nil, err
+	}
+	a := &Analyzer{server: server}
+
+	if opts.Root != "" {
+		root, err := filepath.Abs(opts.Root)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve root %s: %w", opts.Root, err)
+		}
+		server.SetRootPath(root)
+		server.SetRootURI(uriutil.PathToURI(root))
+	}
+
+	cfg := server.GetConfig()
+	cfg.Prefix = opts.Prefix
+	if len(opts.TokensFiles) > 0 {
+		cfg.TokensFiles = make([]any, len(opts.TokensFiles))
+		for i, file := range opts.TokensFiles {
+			item := map[string]any{"path": file.Path}
+			if file.Prefix != "" {
+				item["prefix"] = file.Prefix
+			}
+			if len(file.GroupMarkers) > 0 {
+				item["groupMarkers"] = file.GroupMarkers
+			}
+			cfg.TokensFiles[i] = item
+		}
+	}
+	server.SetConfig(cfg)
+
+	if err := server.LoadPackageJsonConfig(); err != nil {
+		return nil, fmt.Errorf("failed to read package.json config: %w", err)
+	}
+	if err := server.LoadTokensFromConfig(); err != nil {
+		return a, err
+	}
+	return a, nil
+}
+
+// Close stops the analyzer's background work, such as Figma imports.
+// The parser pools are shared by the process and stay open.
+func (a *Analyzer) Close() error {
+	return a.server.Close()
+}
+
+// Tokens returns every loaded token, sorted by name
+func (a *Analyzer) Tokens() []Token {
+	manager := a.server.TokenManager()
+	all := manager.GetAll()
+	result := make([]Token, len(all))
+	for i, t := range all {
+		result[i] = newToken(manager, t)
+	}
+	slices.SortFunc(result, func(a, b Token) int { return strings.Compare(a.Name, b.Name) })
+	return result
+}
+
+// Token looks up a token by path (e.g. "color.primary") or CSS variable
+// (e.g. "--color-primary")
+func (a *Analyzer) Token(name string) (Token, bool) {
+	t := a.server.Token(name)
+	if t == nil {
+		return Token{}, false
+	}
+	return newToken(a.server.TokenManager(), t), true
+}
+
+// References returns the CSS custom property uses in content, in document order.
+// languageID is an LSP language ID such as "css" or "typescript"; see LanguageID.
+func (a *Analyzer) References(languageID, content string) ([]Reference, error) {
+	if !parser.IsCSSSupportedLanguage(languageID) {
+		return nil, fmt.Errorf("unsupported language %q", languageID)
+	}
+	result, err := parser.ParseCSSFromDocument(content, languageID)
+	if err != nil {
+		return nil, err
+	}
+	if result == nil {
+		return nil, nil
+	}
+
+	manager := a.server.TokenManager()
+	references := make([]Reference, 0, len(result.VarCalls))
+	for _, call := range result.VarCalls {
+		ref := Reference{
+			Name:     call.TokenName,
+			Alias:    call.Alias,
+			Range:    newRange(call.Range),
+			Fallback: call.Fallback,
+		}
+		if t := a.server.Token(call.TokenName); t != nil {
+			token := newToken(manager, t)
+			ref.Token = &token
+		}
+		references = append(references, ref)
+	}
+	slices.SortStableFunc(references, func(a, b Reference) int {
+		return cmp.Or(
+			cmp.Compare(a.Range.Start.Line, b.Range.Start.Line),
+			cmp.Compare(a.Range.Start.Character, b.Range.Start.Character),
+		)
+	})
+	return references, nil
+}
+
+// Analyze returns the diagnostics for a document's content.
+// Relative paths resolve against Options.Root. path identifies the document, e.g. to recognize generated custom properties
+// files; the file itself is not read.
+func (a *Analyzer) Analyze(path, languageID, content string) ([]Diagnostic, error) {
+	if !parser.IsCSSSupportedLanguage(languageID) {
+		return nil, fmt.Errorf("unsupported language %q", languageID)
+	}
+	abs, err := a.absPath(path)
+	if err != nil {
+		return nil, err
+	}
+	doc := documents.NewDocument(uriutil.PathToURI(abs), languageID, 0, content)
+
+	found, err := diagnostic.DocumentDiagnostics(a.server, a.server.GetConfig(), doc)
+	if err != nil {
+		return nil, err
+	}
+	diagnostics := make([]Diagnostic, len(found))
+	for i, d := range found {
+		diagnostics[i] = newDiagnostic(d)
+	}
+	return diagnostics, nil
+}
+
+// AnalyzeFile reads a file, relative to Options.Root if set, and returns its diagnostics.
+// The language is inferred from the file extension; see LanguageID.
+func (a *Analyzer) AnalyzeFile(path string) ([]Diagnostic, error) {
+	languageID := LanguageID(path)
+	if languageID == "" {
+		return nil, fmt.Errorf("unsupported file type: %s", path)
+	}
+	abs, err := a.absPath(path)
+	if err != nil {
+		return nil, err
+	}
+	content, err := os.ReadFile(abs)
+	if err != nil {
+		return nil, err
+	}
+	return a.Analyze(abs, languageID, string(content))
+}
+
+// absPath resolves a relative path against the root, if any,
+// else the working directory
+func (a *Analyzer) absPath(path string) (string, error) {
+	if root := a.server.RootPath(); root != "" && !filepath.IsAbs(path) {
+		return filepath.Join(root, path), nil
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve %s: %w", path, err)
+	}
+	return abs, nil
+}
+
+// LanguageID returns the LSP language ID for a source file path,
+// or "" when the analyzer does not support the file type
+func LanguageID(path string) string {
+	return parser.LanguageIDForPath(path)
+}
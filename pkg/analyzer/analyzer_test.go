@@ -0,0 +1,153 @@
+package analyzer_test
+
+import (
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"bennypowers.dev/dtls/pkg/analyzer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newWorkspaceAnalyzer(t *testing.T) *analyzer.Analyzer {
+	t.Helper()
+	a, err := analyzer.New(analyzer.Options{Root: filepath.Join("testdata", "workspace")})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = a.Close() })
+	return a
+}
+
+func TestNew_PackageJSONConfig(t *testing.T) {
+	a := newWorkspaceAnalyzer(t)
+
+	all := a.Tokens()
+	require.Len(t, all, 3)
+	assert.Equal(t, "color-accent", all[0].Name, "tokens are sorted by name")
+
+	primary, ok := a.Token("color.primary")
+	require.True(t, ok)
+	assert.Equal(t, []string{"color", "primary"}, primary.Path)
+	assert.Equal(t, "--ds-color-primary", primary.CSSVariable)
+	assert.Equal(t, "#0000ff", primary.Value)
+	assert.Equal(t, "color", primary.Type)
+	assert.Equal(t, "Brand blue", primary.Description)
+	assert.Equal(t, "tokens.json", filepath.Base(primary.File))
+
+	byVariable, ok := a.Token("--ds-color-primary")
+	require.True(t, ok)
+	assert.Equal(t, primary, byVariable)
+
+	_, ok = a.Token("color.missing")
+	assert.False(t, ok)
+}
+
+func TestNew_ExplicitTokensFiles(t *testing.T) {
+	a, err := analyzer.New(analyzer.Options{
+		TokensFiles: []analyzer.TokensFile{{
+			Path:   filepath.Join("testdata", "workspace", "tokens", "tokens.json"),
+			Prefix: "brand",
+		}},
+	})
+	require.NoError(t, err)
+	defer func() { _ = a.Close() }()
+
+	primary, ok := a.Token("color.primary")
+	require.True(t, ok)
+	assert.Equal(t, "--brand-color-primary", primary.CSSVariable)
+}
+
+func TestNew_MissingTokensFile(t *testing.T) {
+	a, err := analyzer.New(analyzer.Options{
+		TokensFiles: []analyzer.TokensFile{{Path: filepath.Join("testdata", "missing.json")}},
+	})
+	assert.Error(t, err)
+	require.NotNil(t, a, "the analyzer is usable despite load errors")
+	defer func() { _ = a.Close() }()
+	assert.Empty(t, a.Tokens())
+}
+
+func TestAnalyzeFile(t *testing.T) {
+	a := newWorkspaceAnalyzer(t)
+
+	diagnostics, err := a.AnalyzeFile(filepath.Join("src", "button.css"))
+	require.NoError(t, err)
+	require.Len(t, diagnostics, 2)
+
+	assert.Equal(t, analyzer.SeverityError, diagnostics[0].Severity)
+	assert.Contains(t, diagnostics[0].Message, "fallback does not match")
+	assert.Equal(t, analyzer.Position{Line: 1, Character: 9}, diagnostics[0].Range.Start)
+
+	assert.Equal(t, analyzer.SeverityInformation, diagnostics[1].Severity)
+	assert.True(t, diagnostics[1].Deprecated)
+	assert.Contains(t, diagnostics[1].Message, "--ds-color-legacy is deprecated")
+	assert.Equal(t, uint32(2), diagnostics[1].Range.Start.Line)
+}
+
+func TestAnalyze(t *testing.T) {
+	a := newWorkspaceAnalyzer(t)
+
+	t.Run("embedded CSS", func(t *testing.T) {
+		diagnostics, err := a.Analyze("card.ts", "typescript",
+			"const styles = css`:host { color: var(--ds-color-legacy); }`;")
+		require.NoError(t, err)
+		require.Len(t, diagnostics, 1)
+		assert.True(t, diagnostics[0].Deprecated)
+	})
+
+	t.Run("clean document", func(t *testing.T) {
+		diagnostics, err := a.Analyze("clean.css", "css", ".a { color: var(--ds-color-primary); }")
+		require.NoError(t, err)
+		assert.Empty(t, diagnostics)
+	})
+
+	t.Run("unsupported language", func(t *testing.T) {
+		_, err := a.Analyze("notes.md", "markdown", "")
+		assert.Error(t, err)
+	})
+}
+
+func TestClose_OtherAnalyzersKeepWorking(t *testing.T) {
+	a := newWorkspaceAnalyzer(t)
+	b := newWorkspaceAnalyzer(t)
+
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Go(func() {
+			diagnostics, err := a.AnalyzeFile(filepath.Join("src", "button.css"))
+			assert.NoError(t, err)
+			assert.Len(t, diagnostics, 2)
+		})
+	}
+	require.NoError(t, b.Close())
+	wg.Wait()
+
+	diagnostics, err := a.AnalyzeFile(filepath.Join("src", "button.css"))
+	require.NoError(t, err)
+	assert.Len(t, diagnostics, 2, "closing an analyzer leaves the shared parsers to the others")
+}
+
+func TestReferences(t *testing.T) {
+	a := newWorkspaceAnalyzer(t)
+
+	refs, err := a.References("css", ".a { color: var(--ds-color-primary, blue); margin: var(--unknown); }")
+	require.NoError(t, err)
+	require.Len(t, refs, 2)
+
+	assert.Equal(t, "--ds-color-primary", refs[0].Name)
+	require.NotNil(t, refs[0].Token)
+	assert.Equal(t, "color-primary", refs[0].Token.Name)
+	require.NotNil(t, refs[0].Fallback)
+	assert.Equal(t, "blue", *refs[0].Fallback)
+
+	assert.Equal(t, "--unknown", refs[1].Name)
+	assert.Nil(t, refs[1].Token)
+	assert.Nil(t, refs[1].Fallback)
+}
+
+func TestLanguageID(t *testing.T) {
+	assert.Equal(t, "css", analyzer.LanguageID("a/b.CSS"))
+	assert.Equal(t, "scss", analyzer.LanguageID("b.scss"))
+	assert.Equal(t, "typescriptreact", analyzer.LanguageID("c.tsx"))
+	assert.Equal(t, "", analyzer.LanguageID("d.md"))
+}
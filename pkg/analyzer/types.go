@@ -0,0 +1,157 @@
+package analyzer
+
+import (
+	"fmt"
+
+	cssparser "bennypowers.dev/dtls/internal/parser/css"
+	"bennypowers.dev/dtls/internal/tokens"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+// Position is a zero-based position in a document.
+// Character counts UTF-16 code units, as in the Language Server Protocol.
+type Position struct {
+	Line      uint32 `json:"line"`
+	Character uint32 `json:"character"`
+}
+
+// Range is a span of a document, from Start up to but excluding End
+type Range struct {
+	Start Position `json:"start"`
+	End   Position `json:"end"`
+}
+
+// Severity ranks a diagnostic. Values match the Language Server Protocol.
+type Severity int
+
+const (
+	SeverityError       Severity = 1
+	SeverityWarning     Severity = 2
+	SeverityInformation Severity = 3
+	SeverityHint        Severity = 4
+)
+
+// String returns the lowercase name of the severity, e.g. "error"
+func (s Severity) String() string {
+	switch s {
+	case SeverityError:
+		return "error"
+	case SeverityWarning:
+		return "warning"
+	case SeverityInformation:
+		return "information"
+	case SeverityHint:
+		return "hint"
+	default:
+		return fmt.Sprintf("severity(%d)", int(s))
+	}
+}
+
+// Diagnostic is a problem found in a document, such as a deprecated token
+// or a var() fallback which doesn't match the token's value
+type Diagnostic struct {
+	Range    Range    `json:"range"`
+	Severity Severity `json:"severity"`
+	Message  string   `json:"message"`
+
+	// Deprecated reports whether the diagnostic is about a deprecated token
+	Deprecated bool `json:"deprecated,omitempty"`
+}
+
+// Token is a design token loaded by the analyzer
+type Token struct {
+	// Name is the token's name, e.g. "color-primary"
+	Name string `json:"name"`
+
+	// Path is the token's group path, e.g. ["color", "primary"]
+	Path []string `json:"path"`
+
+	// CSSVariable is the custom property the token is used through,
+	// e.g. "--ds-color-primary"
+	CSSVariable string `json:"cssVariable"`
+
+	Type        string `json:"type,omitempty"`
+	Description string `json:"description,omitempty"`
+
+	// Value is the token's value as written, which may be an alias
+	Value string `json:"value"`
+
+	// ResolvedValue is the value after following aliases, or Value when the
+	// token is not an alias or could not be resolved
+	ResolvedValue string `json:"resolvedValue"`
+
+	Deprecated         bool   `json:"deprecated,omitempty"`
+	DeprecationMessage string `json:"deprecationMessage,omitempty"`
+
+	// File is the path of the token file which defines the token, if any,
+	// and Line and Character its zero-based position there
+	File      string `json:"file,omitempty"`
+	Line      uint32 `json:"line"`
+	Character uint32 `json:"character"`
+}
+
+// Reference is a use of a CSS custom property in a document,
+// e.g. a var() call, or an SCSS variable aliasing one
+type Reference struct {
+	// Name is the custom property, e.g. "--ds-color-primary"
+	Name  string `json:"name"`
+	Range Range  `json:"range"`
+
+	// Alias is the SCSS variable (e.g. "$primary") through which the custom
+	// property is used, in which case Range covers the variable
+	Alias string `json:"alias,omitempty"`
+
+	// Fallback is the var() call's fallback as written, or nil when it has none
+	Fallback *string `json:"fallback,omitempty"`
+
+	// Token is the design token Name refers to, or nil when it is unknown
+	Token *Token `json:"token,omitempty"`
+}
+
+func newToken(manager *tokens.Manager, t *tokens.Token) Token {
+	resolved := t.Value
+	if t.IsResolved && t.ResolvedValue != nil {
+		resolved = fmt.Sprint(t.ResolvedValue)
+	}
+	return Token{
+		Name:               t.Name,
+		Path:               t.Path,
+		CSSVariable:        manager.CSSVariableName(t),
+		Type:               t.Type,
+		Description:        t.Description,
+		Value:              t.Value,
+		ResolvedValue:      resolved,
+		Deprecated:         t.Deprecated,
+		DeprecationMessage: t.DeprecationMessage,
+		File:               t.FilePath,
+		Line:               t.Line,
+		Character:          t.Character,
+	}
+}
+
+func newRange(r cssparser.Range) Range {
+	return Range{
+		Start: Position{Line: r.Start.Line, Character: r.Start.Character},
+		End:   Position{Line: r.End.Line, Character: r.End.Character},
+	}
+}
+
+func newDiagnostic(d protocol.Diagnostic) Diagnostic {
+	diagnostic := Diagnostic{
+		Range: Range{
+			Start: Position{Line: d.Range.Start.Line, Character: d.Range.Start.Character},
+			End:   Position{Line: d.Range.End.Line, Character: d.Range.End.Character},
+		},
+		Severity: SeverityError,
+		Message:  d.Message,
+	}
+	if d.Severity != nil {
+		diagnostic.Severity = Severity(*d.Severity)
+	}
+	for _, tag := range d.Tags {
+		if tag == protocol.DiagnosticTagDeprecated {
+			diagnostic.Deprecated = true
+		}
+	}
+	return diagnostic
+}
@@ -5,6 +5,7 @@ import (
 	"path/filepath"
 	"testing"
 
+	"bennypowers.dev/dtls/internal/uriutil"
 	"bennypowers.dev/dtls/lsp/methods/textDocument"
 	"bennypowers.dev/dtls/lsp/methods/textDocument/hover"
 	"bennypowers.dev/dtls/lsp/methods/workspace"
@@ -49,7 +50,7 @@ func TestFileWatching_TokenFileChange(t *testing.T) {
 	require.NoError(t, err)
 
 	// Open CSS document
-	cssURI := "file://" + cssPath
+	cssURI := uriutil.PathToURI(cssPath)
 	req := types.NewRequestContext(server, nil)
 	err = textDocument.DidOpen(req, &protocol.DidOpenTextDocumentParams{
 		TextDocument: protocol.TextDocumentItem{
@@ -91,7 +92,7 @@ func TestFileWatching_TokenFileChange(t *testing.T) {
 	require.NoError(t, err)
 
 	// Simulate file change notification
-	tokensURI := "file://" + tokensPath
+	tokensURI := uriutil.PathToURI(tokensPath)
 	req = types.NewRequestContext(server, nil)
 	err = workspace.DidChangeWatchedFiles(req, &protocol.DidChangeWatchedFilesParams{
 		Changes: []protocol.FileEvent{
@@ -149,7 +150,7 @@ func TestFileWatching_TokenFileDeleted(t *testing.T) {
 	err = server.LoadTokenFile(tokensPath, "")
 	require.NoError(t, err)
 
-	cssURI := "file://" + cssPath
+	cssURI := uriutil.PathToURI(cssPath)
 	req := types.NewRequestContext(server, nil)
 	err = textDocument.DidOpen(req, &protocol.DidOpenTextDocumentParams{
 		TextDocument: protocol.TextDocumentItem{
@@ -177,7 +178,7 @@ func TestFileWatching_TokenFileDeleted(t *testing.T) {
 	require.NoError(t, err)
 
 	// Simulate file deletion notification
-	tokensURI := "file://" + tokensPath
+	tokensURI := uriutil.PathToURI(tokensPath)
 	req = types.NewRequestContext(server, nil)
 	err = workspace.DidChangeWatchedFiles(req, &protocol.DidChangeWatchedFilesParams{
 		Changes: []protocol.FileEvent{
@@ -250,7 +251,7 @@ func TestFileWatching_MultipleTokenFiles(t *testing.T) {
 	err = server.LoadTokenFile(tokens2Path, "")
 	require.NoError(t, err)
 
-	cssURI := "file://" + cssPath
+	cssURI := uriutil.PathToURI(cssPath)
 	req := types.NewRequestContext(server, nil)
 	err = textDocument.DidOpen(req, &protocol.DidOpenTextDocumentParams{
 		TextDocument: protocol.TextDocumentItem{
@@ -275,7 +276,7 @@ func TestFileWatching_MultipleTokenFiles(t *testing.T) {
 	require.NoError(t, err)
 
 	// Simulate file change notification
-	tokens2URI := "file://" + tokens2Path
+	tokens2URI := uriutil.PathToURI(tokens2Path)
 	req = types.NewRequestContext(server, nil)
 	err = workspace.DidChangeWatchedFiles(req, &protocol.DidChangeWatchedFilesParams{
 		Changes: []protocol.FileEvent{
@@ -335,7 +336,7 @@ func TestFileWatching_NonTokenFileIgnored(t *testing.T) {
 	initialCount := server.TokenCount()
 
 	// Simulate change to non-token file
-	pkgURI := "file://" + pkgPath
+	pkgURI := uriutil.PathToURI(pkgPath)
 	req := types.NewRequestContext(server, nil)
 	err = workspace.DidChangeWatchedFiles(req, &protocol.DidChangeWatchedFilesParams{
 		Changes: []protocol.FileEvent{
@@ -384,7 +385,7 @@ func TestFileWatching_YmlExtension(t *testing.T) {
 			assert.Equal(t, 1, server.TokenCount(), "Should load token from .yml file")
 
 			// Simulate file change to .yml file
-			tokensURI := "file://" + tokensPath
+			tokensURI := uriutil.PathToURI(tokensPath)
 			req := types.NewRequestContext(server, nil)
 			err = workspace.DidChangeWatchedFiles(req, &protocol.DidChangeWatchedFilesParams{
 				Changes: []protocol.FileEvent{
@@ -5,6 +5,7 @@ import (
 	"path/filepath"
 	"testing"
 
+	"bennypowers.dev/dtls/internal/uriutil"
 	"bennypowers.dev/dtls/lsp"
 	"bennypowers.dev/dtls/lsp/methods/lifecycle"
 	"bennypowers.dev/dtls/lsp/types"
@@ -23,7 +24,7 @@ func TestServerInitialization(t *testing.T) {
 
 		// Create temp workspace
 		tmpDir := t.TempDir()
-		workspaceURI := "file://" + tmpDir
+		workspaceURI := uriutil.PathToURI(tmpDir)
 		workspacePath := tmpDir
 
 		// Initialize server
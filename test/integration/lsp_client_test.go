@@ -8,10 +8,12 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"runtime"
 	"sync"
 	"testing"
 	"time"
 
+	"bennypowers.dev/dtls/internal/uriutil"
 	"bennypowers.dev/dtls/lsp/methods/textDocument/diagnostic"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -20,40 +22,114 @@ import (
 
 // LSPClient is a test client that communicates with an LSP server via stdio
 type LSPClient struct {
-	stdin     io.WriteCloser
-	stdout    io.ReadCloser
-	cmd       *exec.Cmd
-	reader    *bufio.Reader
-	responses map[int]chan json.RawMessage
-	t         *testing.T
-	msgID     int
-	mu        sync.Mutex
+	stdin         io.WriteCloser
+	stdout        io.ReadCloser
+	cmd           *exec.Cmd
+	reader        *bufio.Reader
+	responses     map[int]chan json.RawMessage
+	notifications []serverNotification
+	t             *testing.T
+	msgID         int
+	exitTimeout   time.Duration
+	mu            sync.Mutex
 }
 
-// NewLSPClient creates a new LSP test client
-func NewLSPClient(t *testing.T) *LSPClient {
-	t.Helper()
+// serverNotification is a notification sent from the server to the client
+// (e.g. textDocument/publishDiagnostics or window/logMessage)
+type serverNotification struct {
+	Method string
+	Params json.RawMessage
+}
+
+// serverBinaryEnv names an environment variable holding the path of a
+// prebuilt server binary, which the client runs instead of building one
+const serverBinaryEnv = "DTLS_TEST_SERVER_BINARY"
+
+// LSPClientOptions configures how an LSPClient starts the server
+type LSPClientOptions struct {
+	// BinaryPath is the server binary to run. When empty, the binary named by
+	// DTLS_TEST_SERVER_BINARY is run, or else the server is built once per
+	// test process into the system temp directory.
+	BinaryPath string
+
+	// CoverDir receives coverage data from the server. When empty, it is
+	// coverage/integration in the project root.
+	CoverDir string
 
-	// Build the server binary with coverage instrumentation
-	// Get current directory and navigate to project root
+	// ExitTimeout is how long Close waits for the server to exit after the
+	// exit notification before killing it. When zero, it is 5 seconds.
+	ExitTimeout time.Duration
+}
+
+var (
+	buildOnce   sync.Once
+	builtBinary string
+	buildErr    error
+)
+
+// projectRoot returns the repository root, relative to this package
+func projectRoot(t *testing.T) string {
+	t.Helper()
 	cwd, err := os.Getwd()
 	require.NoError(t, err)
-	projectRoot := filepath.Join(cwd, "..", "..")
+	return filepath.Join(cwd, "..", "..")
+}
+
+// serverBinary returns the path of a server binary built with coverage
+// instrumentation. It is built once, since tests run clients concurrently
+// and Windows can't replace a binary that is running.
+func serverBinary(t *testing.T) string {
+	t.Helper()
+	buildOnce.Do(func() {
+		name := "design-tokens-lsp-test"
+		if runtime.GOOS == "windows" {
+			name += ".exe"
+		}
+		builtBinary = filepath.Join(os.TempDir(), name)
+
+		// Build with -cover flag to enable coverage for integration tests (Go 1.20+)
+		cmd := exec.Command("go", "build", "-cover", "-o", builtBinary, "./cmd/design-tokens-language-server")
+		cmd.Dir = projectRoot(t)
+		if output, err := cmd.CombinedOutput(); err != nil {
+			buildErr = fmt.Errorf("%w: %s", err, output)
+		}
+	})
+	require.NoError(t, buildErr, "Failed to build server")
+	return builtBinary
+}
+
+// NewLSPClient creates a new LSP test client, running a server built from
+// this repository
+func NewLSPClient(t *testing.T) *LSPClient {
+	t.Helper()
+	return NewLSPClientWithOptions(t, LSPClientOptions{})
+}
 
-	// Build with -cover flag to enable coverage for integration tests (Go 1.20+)
-	cmd := exec.Command("go", "build", "-cover", "-o", "/tmp/design-tokens-lsp-test", "./cmd/design-tokens-language-server")
-	cmd.Dir = projectRoot
-	output, buildErr := cmd.CombinedOutput()
-	require.NoError(t, buildErr, "Failed to build server: %s", string(output))
+// NewLSPClientWithOptions creates a new LSP test client with the given options
+func NewLSPClientWithOptions(t *testing.T, opts LSPClientOptions) *LSPClient {
+	t.Helper()
+
+	binary := opts.BinaryPath
+	if binary == "" {
+		binary = os.Getenv(serverBinaryEnv)
+	}
+	if binary == "" {
+		binary = serverBinary(t)
+	}
 
 	// Start the server process with coverage output
-	coverDir := filepath.Join(projectRoot, "coverage", "integration")
+	coverDir := opts.CoverDir
+	if coverDir == "" {
+		coverDir = filepath.Join(projectRoot(t), "coverage", "integration")
+	}
 	_ = os.MkdirAll(coverDir, 0o755)
 
-	serverCmd := exec.Command("/tmp/design-tokens-lsp-test")
+	serverCmd := exec.Command(binary)
 	serverCmd.Env = append(os.Environ(),
 		fmt.Sprintf("GOCOVERDIR=%s", coverDir),
 	)
+	// Don't let Wait block on pipes held open by a server that was killed
+	serverCmd.WaitDelay = time.Second
 	stdin, err := serverCmd.StdinPipe()
 	require.NoError(t, err)
 	stdout, err := serverCmd.StdoutPipe()
@@ -73,13 +149,19 @@ func NewLSPClient(t *testing.T) *LSPClient {
 		}
 	}()
 
+	exitTimeout := opts.ExitTimeout
+	if exitTimeout == 0 {
+		exitTimeout = 5 * time.Second
+	}
+
 	client := &LSPClient{
-		cmd:       serverCmd,
-		stdin:     stdin,
-		stdout:    stdout,
-		reader:    bufio.NewReader(stdout),
-		responses: make(map[int]chan json.RawMessage),
-		t:         t,
+		cmd:         serverCmd,
+		stdin:       stdin,
+		stdout:      stdout,
+		reader:      bufio.NewReader(stdout),
+		responses:   make(map[int]chan json.RawMessage),
+		exitTimeout: exitTimeout,
+		t:           t,
 	}
 
 	// Start reading responses in background
@@ -88,12 +170,23 @@ func NewLSPClient(t *testing.T) *LSPClient {
 	return client
 }
 
-// Close shuts down the LSP client
+// Close shuts down the LSP client. A server that doesn't exit after the
+// exit notification is killed, which works the same on every platform,
+// unlike signals.
 func (c *LSPClient) Close() {
 	c.Shutdown()
 	_ = c.stdin.Close()
 	_ = c.stdout.Close()
-	_ = c.cmd.Wait()
+
+	exited := make(chan error, 1)
+	go func() { exited <- c.cmd.Wait() }()
+	select {
+	case <-exited:
+	case <-time.After(c.exitTimeout):
+		c.t.Logf("Server did not exit within %s, killing it", c.exitTimeout)
+		_ = c.cmd.Process.Kill()
+		<-exited
+	}
 }
 
 // sendRequest sends a JSON-RPC request and returns the message ID
@@ -158,6 +251,28 @@ func (c *LSPClient) waitForResponse(id int, timeout time.Duration) (json.RawMess
 	}
 }
 
+// waitForNotification waits for a server notification with the given method
+// for which match returns true. Notifications received before the call are
+// also considered, so callers don't race the server.
+func (c *LSPClient) waitForNotification(method string, match func(json.RawMessage) bool, timeout time.Duration) (json.RawMessage, error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		c.mu.Lock()
+		for _, n := range c.notifications {
+			if n.Method == method && (match == nil || match(n.Params)) {
+				c.mu.Unlock()
+				return n.Params, nil
+			}
+		}
+		c.mu.Unlock()
+
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("timeout waiting for %s notification", method)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
 // readResponses reads responses from the server in a background goroutine
 func (c *LSPClient) readResponses() {
 	for {
@@ -192,6 +307,7 @@ func (c *LSPClient) readResponses() {
 		var message struct {
 			ID     *int            `json:"id"`
 			Method *string         `json:"method"`
+			Params json.RawMessage `json:"params"`
 			Result json.RawMessage `json:"result"`
 			Error  json.RawMessage `json:"error"`
 		}
@@ -201,6 +317,17 @@ func (c *LSPClient) readResponses() {
 			continue
 		}
 
+		// Record server notifications (no ID) for later assertions
+		if message.Method != nil && message.ID == nil {
+			c.mu.Lock()
+			c.notifications = append(c.notifications, serverNotification{
+				Method: *message.Method,
+				Params: message.Params,
+			})
+			c.mu.Unlock()
+			continue
+		}
+
 		// Handle server requests (like client/registerCapability)
 		if message.Method != nil {
 			c.t.Logf("Received server request: %s (id: %v)", *message.Method, message.ID)
@@ -493,7 +620,7 @@ func TestRealLSPConnection(t *testing.T) {
 		defer client.Close()
 
 		// Initialize with workspace
-		rootURI := "file://" + tmpDir
+		rootURI := uriutil.PathToURI(tmpDir)
 		err = client.Initialize(rootURI)
 		require.NoError(t, err, "Initialize should succeed")
 
@@ -508,7 +635,7 @@ func TestRealLSPConnection(t *testing.T) {
 		time.Sleep(300 * time.Millisecond)
 
 		// Open CSS document
-		cssURI := "file://" + cssPath
+		cssURI := uriutil.PathToURI(cssPath)
 		client.DidOpenTextDocument(cssURI, "css", cssContent)
 
 		// Wait for document to be processed and tokens to be loaded
@@ -573,7 +700,7 @@ func TestRealLSPConnection(t *testing.T) {
 		defer client.Close()
 
 		// Initialize with workspace
-		rootURI := "file://" + tmpDir
+		rootURI := uriutil.PathToURI(tmpDir)
 		err = client.Initialize(rootURI)
 		require.NoError(t, err)
 
@@ -588,7 +715,7 @@ func TestRealLSPConnection(t *testing.T) {
 		time.Sleep(200 * time.Millisecond)
 
 		// Open CSS document
-		cssURI := "file://" + cssPath
+		cssURI := uriutil.PathToURI(cssPath)
 		client.DidOpenTextDocument(cssURI, "css", cssContent)
 
 		// Wait for document processing
@@ -671,12 +798,12 @@ func TestRealLSPConnection(t *testing.T) {
 		defer client.Close()
 
 		// Initialize with workspace
-		rootURI := "file://" + tmpDir
+		rootURI := uriutil.PathToURI(tmpDir)
 		err = client.Initialize(rootURI)
 		require.NoError(t, err)
 
 		// Open CSS document
-		cssURI := "file://" + cssPath
+		cssURI := uriutil.PathToURI(cssPath)
 		client.DidOpenTextDocument(cssURI, "css", cssContent)
 
 		// Wait for document processing
@@ -727,7 +854,7 @@ func TestRealLSPConnection(t *testing.T) {
 		defer client.Close()
 
 		// Initialize with workspace
-		rootURI := "file://" + tmpDir
+		rootURI := uriutil.PathToURI(tmpDir)
 		err = client.Initialize(rootURI)
 		require.NoError(t, err)
 
@@ -742,7 +869,7 @@ func TestRealLSPConnection(t *testing.T) {
 		time.Sleep(200 * time.Millisecond)
 
 		// Open token document
-		tokensURI := "file://" + tokensPath
+		tokensURI := uriutil.PathToURI(tokensPath)
 		client.DidOpenTextDocument(tokensURI, "json", tokens)
 
 		// Wait for document processing
@@ -6,6 +6,7 @@ import (
 	"path/filepath"
 	"testing"
 
+	"bennypowers.dev/dtls/internal/uriutil"
 	"bennypowers.dev/dtls/lsp"
 	"bennypowers.dev/dtls/lsp/methods/lifecycle"
 	"bennypowers.dev/dtls/lsp/types"
@@ -62,7 +63,7 @@ func TestPackageJsonConfiguration(t *testing.T) {
 		require.NoError(t, err)
 		defer func() { _ = server.Close() }()
 
-		workspaceURI := "file://" + tmpDir
+		workspaceURI := uriutil.PathToURI(tmpDir)
 		workspacePath := tmpDir
 
 		ctx := &glsp.Context{}
@@ -128,7 +129,7 @@ func TestPackageJsonConfiguration(t *testing.T) {
 		require.NoError(t, err)
 		defer func() { _ = server.Close() }()
 
-		workspaceURI := "file://" + tmpDir
+		workspaceURI := uriutil.PathToURI(tmpDir)
 		workspacePath := tmpDir
 
 		ctx := &glsp.Context{}
@@ -160,7 +161,7 @@ func TestPackageJsonConfiguration(t *testing.T) {
 		require.NoError(t, err)
 		defer func() { _ = server.Close() }()
 
-		workspaceURI := "file://" + tmpDir
+		workspaceURI := uriutil.PathToURI(tmpDir)
 		workspacePath := tmpDir
 
 		ctx := &glsp.Context{}
@@ -210,7 +211,7 @@ func TestPackageJsonConfiguration(t *testing.T) {
 		}
 		server.SetConfig(clientConfig)
 
-		workspaceURI := "file://" + tmpDir
+		workspaceURI := uriutil.PathToURI(tmpDir)
 		workspacePath := tmpDir
 
 		ctx := &glsp.Context{}
@@ -278,7 +279,7 @@ func TestPackageJsonConfiguration(t *testing.T) {
 		require.NoError(t, err)
 		defer func() { _ = server.Close() }()
 
-		workspaceURI := "file://" + tmpDir
+		workspaceURI := uriutil.PathToURI(tmpDir)
 		workspacePath := tmpDir
 
 		ctx := &glsp.Context{}
@@ -334,7 +335,7 @@ func TestPackageJsonConfiguration(t *testing.T) {
 		require.NoError(t, err)
 		defer func() { _ = server.Close() }()
 
-		workspaceURI := "file://" + tmpDir
+		workspaceURI := uriutil.PathToURI(tmpDir)
 		workspacePath := tmpDir
 
 		ctx := &glsp.Context{}
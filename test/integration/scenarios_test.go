@@ -0,0 +1,13 @@
+package integration_test
+
+import (
+	"testing"
+
+	"bennypowers.dev/dtls/test/integration/testutil"
+)
+
+// TestScenarios checks each workspace scenario in testdata/scenarios against
+// its golden files. Run with -update to regenerate them.
+func TestScenarios(t *testing.T) {
+	testutil.RunScenarios(t)
+}
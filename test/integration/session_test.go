@@ -0,0 +1,251 @@
+package integration_test
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"bennypowers.dev/dtls/internal/uriutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// sessionsRoot is the directory containing recorded session fixtures.
+//
+// Each subdirectory is one scenario:
+//
+//	testdata/sessions/<name>/
+//	├── session.json   # Scripted JSON-RPC steps (see sessionScript)
+//	└── workspace/     # Files copied into a temp dir used as the workspace root
+const sessionsRoot = "testdata/sessions"
+
+// sessionScript is a recorded JSON-RPC session replayed against the server binary.
+// Sessions capture client-specific quirks (capabilities, ordering of
+// notifications) without hand-writing Go for each editor.
+type sessionScript struct {
+	// Description explains what the session covers
+	Description string `json:"description"`
+
+	// Client names the editor the session was recorded from (informational)
+	Client string `json:"client"`
+
+	// Steps are replayed in order
+	Steps []sessionStep `json:"steps"`
+}
+
+// sessionStep is a single step in a recorded session.
+// Exactly one of Request, Notify, or Await should be set;
+// SleepMs may be combined with any of them or used alone.
+type sessionStep struct {
+	// Request is the method of a JSON-RPC request to send and wait for
+	Request string `json:"request,omitempty"`
+
+	// Notify is the method of a JSON-RPC notification to send
+	Notify string `json:"notify,omitempty"`
+
+	// Await is the method of a server notification to wait for
+	Await string `json:"await,omitempty"`
+
+	// Params are sent verbatim after placeholder substitution
+	Params json.RawMessage `json:"params,omitempty"`
+
+	// Expect is matched as a subset against the response result
+	// (or the awaited notification's params)
+	Expect json.RawMessage `json:"expect,omitempty"`
+
+	// ExpectNull asserts that the response result is null
+	ExpectNull bool `json:"expectNull,omitempty"`
+
+	// ExpectContains lists substrings the raw response must contain
+	ExpectContains []string `json:"expectContains,omitempty"`
+
+	// TimeoutMs overrides the default response timeout (2s)
+	TimeoutMs int `json:"timeoutMs,omitempty"`
+
+	// SleepMs pauses after the step, giving the server time to settle
+	SleepMs int `json:"sleepMs,omitempty"`
+}
+
+// TestRecordedSessions replays every session under testdata/sessions
+func TestRecordedSessions(t *testing.T) {
+	entries, err := os.ReadDir(sessionsRoot)
+	require.NoError(t, err)
+
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		t.Run(entry.Name(), func(t *testing.T) {
+			runSession(t, filepath.Join(sessionsRoot, entry.Name()))
+		})
+	}
+}
+
+// runSession copies the session workspace to a temp dir and replays its steps
+func runSession(t *testing.T, dir string) {
+	t.Helper()
+
+	workspace := t.TempDir()
+	if src := filepath.Join(dir, "workspace"); dirExists(src) {
+		require.NoError(t, os.CopyFS(workspace, os.DirFS(src)))
+	}
+
+	raw, err := os.ReadFile(filepath.Join(dir, "session.json")) //nolint:gosec // G304: Test fixture path - test code only
+	require.NoError(t, err)
+
+	var script sessionScript
+	require.NoError(t, json.Unmarshal(expandSessionPlaceholders(raw, workspace), &script))
+	t.Logf("Session (%s): %s", script.Client, script.Description)
+
+	client := NewLSPClient(t)
+	defer client.Close()
+
+	for i, step := range script.Steps {
+		runSessionStep(t, client, i, step)
+		if step.SleepMs > 0 {
+			time.Sleep(time.Duration(step.SleepMs) * time.Millisecond)
+		}
+	}
+}
+
+// runSessionStep executes one scripted step and checks its expectations
+func runSessionStep(t *testing.T, client *LSPClient, i int, step sessionStep) {
+	t.Helper()
+
+	timeout := 2 * time.Second
+	if step.TimeoutMs > 0 {
+		timeout = time.Duration(step.TimeoutMs) * time.Millisecond
+	}
+
+	var params any
+	if len(step.Params) > 0 {
+		params = step.Params
+	}
+
+	switch {
+	case step.Notify != "":
+		client.sendNotification(step.Notify, params)
+
+	case step.Request != "":
+		id := client.sendRequest(step.Request, params)
+		result, err := client.waitForResponse(id, timeout)
+		require.NoError(t, err, "step %d (%s)", i, step.Request)
+		assertSessionResult(t, i, step.Request, step, result)
+
+	case step.Await != "":
+		var match func(json.RawMessage) bool
+		if len(step.Expect) > 0 {
+			match = func(p json.RawMessage) bool { return jsonSubsetMatches(step.Expect, p) }
+		}
+		result, err := client.waitForNotification(step.Await, match, timeout)
+		require.NoError(t, err, "step %d (%s)", i, step.Await)
+		assertSessionResult(t, i, step.Await, step, result)
+	}
+}
+
+// assertSessionResult checks a response or notification payload against a step's expectations
+func assertSessionResult(t *testing.T, i int, method string, step sessionStep, result json.RawMessage) {
+	t.Helper()
+
+	if step.ExpectNull {
+		assert.Equal(t, "null", strings.TrimSpace(string(result)), "step %d (%s): expected null result", i, method)
+	}
+	if len(step.Expect) > 0 {
+		assert.True(t, jsonSubsetMatches(step.Expect, result),
+			"step %d (%s): result does not match expectation\nexpected subset: %s\nactual: %s",
+			i, method, string(step.Expect), string(result))
+	}
+	for _, s := range step.ExpectContains {
+		assert.Contains(t, string(result), s, "step %d (%s)", i, method)
+	}
+}
+
+// expandSessionPlaceholders substitutes ${workspacePath} and ${workspaceUri}
+// in a session script. Values are JSON-escaped so Windows paths stay valid.
+func expandSessionPlaceholders(raw []byte, workspace string) []byte {
+	escape := func(s string) string {
+		b, _ := json.Marshal(s)
+		return string(b[1 : len(b)-1])
+	}
+	r := strings.NewReplacer(
+		"${workspacePath}", escape(workspace),
+		"${workspaceUri}", escape(uriutil.PathToURI(workspace)),
+	)
+	return []byte(r.Replace(string(raw)))
+}
+
+// jsonSubsetMatches reports whether every field in expected is present in actual
+// with an equal value. Objects match recursively; arrays match element-wise
+// by index and actual may contain additional trailing elements.
+func jsonSubsetMatches(expected, actual json.RawMessage) bool {
+	var e, a any
+	if json.Unmarshal(expected, &e) != nil || json.Unmarshal(actual, &a) != nil {
+		return false
+	}
+	return subsetMatches(e, a)
+}
+
+func subsetMatches(expected, actual any) bool {
+	switch e := expected.(type) {
+	case map[string]any:
+		a, ok := actual.(map[string]any)
+		if !ok {
+			return false
+		}
+		for k, ev := range e {
+			av, ok := a[k]
+			if !ok || !subsetMatches(ev, av) {
+				return false
+			}
+		}
+		return true
+	case []any:
+		a, ok := actual.([]any)
+		if !ok || len(a) < len(e) {
+			return false
+		}
+		for i := range e {
+			if !subsetMatches(e[i], a[i]) {
+				return false
+			}
+		}
+		return true
+	default:
+		return reflect.DeepEqual(expected, actual)
+	}
+}
+
+func dirExists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
+
+// TestJSONSubsetMatches tests the subset matcher used by session expectations
+func TestJSONSubsetMatches(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected string
+		actual   string
+		want     bool
+	}{
+		{"equal scalars", `1`, `1`, true},
+		{"different scalars", `1`, `2`, false},
+		{"object subset", `{"a":1}`, `{"a":1,"b":2}`, true},
+		{"missing key", `{"c":1}`, `{"a":1}`, false},
+		{"nested object", `{"a":{"b":"x"}}`, `{"a":{"b":"x","c":true}}`, true},
+		{"array prefix", `[{"a":1}]`, `[{"a":1,"b":2},{"a":3}]`, true},
+		{"array too short", `[1,2]`, `[1]`, false},
+		{"type mismatch", `{"a":[]}`, `{"a":{}}`, false},
+		{"null expected", `null`, `null`, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, jsonSubsetMatches(json.RawMessage(tt.expected), json.RawMessage(tt.actual)))
+		})
+	}
+}
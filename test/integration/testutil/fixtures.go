@@ -1,6 +1,7 @@
 package testutil
 
 import (
+	"encoding/json"
 	"os"
 	"path/filepath"
 	"testing"
@@ -123,10 +124,11 @@ func LoadNonTokenFixture(t *testing.T, name string) []byte {
 	return data
 }
 
-// SetCodeActionLiteralSupport sets the client capabilities to support CodeAction literals.
+// SetCodeActionLiteralSupport sets the client capabilities to support CodeAction literals and
+// workspace edits.
 // Call this before tests that use code actions.
 func SetCodeActionLiteralSupport(server *lsp.Server) {
-	server.SetClientCapabilities(protocol.ClientCapabilities{
+	caps := protocol.ClientCapabilities{
 		TextDocument: &protocol.TextDocumentClientCapabilities{
 			CodeAction: &protocol.CodeActionClientCapabilities{
 				CodeActionLiteralSupport: &struct {
@@ -145,7 +147,10 @@ func SetCodeActionLiteralSupport(server *lsp.Server) {
 				},
 			},
 		},
-	})
+	}
+	// Workspace capabilities are an anonymous struct, so decode them
+	_ = json.Unmarshal([]byte(`{"applyEdit": true}`), &caps.Workspace)
+	server.SetClientCapabilities(caps)
 }
 
 // OpenNonTokenFixture opens a non-token JSON/YAML fixture file as a document in the server
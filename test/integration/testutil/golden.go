@@ -0,0 +1,198 @@
+package testutil
+
+import (
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"bennypowers.dev/dtls/internal/uriutil"
+	"bennypowers.dev/dtls/lsp"
+	"bennypowers.dev/dtls/lsp/methods/textDocument"
+	codeaction "bennypowers.dev/dtls/lsp/methods/textDocument/codeAction"
+	"bennypowers.dev/dtls/lsp/methods/textDocument/diagnostic"
+	"bennypowers.dev/dtls/lsp/methods/textDocument/hover"
+	"bennypowers.dev/dtls/lsp/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+var update = flag.Bool("update", false, "update golden files")
+
+// ScenarioRoot is the directory holding golden scenarios, one per subdirectory
+const ScenarioRoot = "testdata/scenarios"
+
+// workspacePlaceholder replaces the scenario's workspace path in golden files,
+// so they don't depend on where the repository is checked out
+const workspacePlaceholder = "${workspacePath}"
+
+// Scenario describes a golden test: a workspace, the server configuration,
+// and the requests whose results are compared with golden files.
+//
+// A scenario directory contains:
+//
+//	scenario.json   the Scenario
+//	workspace/      the token files and documents; the workspace root
+//	golden/         one <request name>.json file per request
+type Scenario struct {
+	// Config is the server configuration, as a client would send it
+	Config json.RawMessage `json:"config"`
+
+	Requests []ScenarioRequest `json:"requests"`
+}
+
+// ScenarioRequest is a request made against a scenario's workspace
+type ScenarioRequest struct {
+	// Name names the golden file, e.g. "hover-primary"
+	Name string `json:"name"`
+
+	// Method is one of "hover", "diagnostics" or "codeAction"
+	Method string `json:"method"`
+
+	// File is the document's path relative to the workspace
+	File string `json:"file"`
+
+	// Line and Character are the 0-based position for hover and codeAction
+	Line      uint32 `json:"line"`
+	Character uint32 `json:"character"`
+}
+
+// RunScenarios runs every scenario under ScenarioRoot as a subtest.
+// Run the tests with -update to write the golden files instead.
+func RunScenarios(t *testing.T) {
+	t.Helper()
+	entries, err := os.ReadDir(ScenarioRoot)
+	require.NoError(t, err)
+	for _, entry := range entries {
+		if entry.IsDir() {
+			t.Run(entry.Name(), func(t *testing.T) {
+				RunScenario(t, filepath.Join(ScenarioRoot, entry.Name()))
+			})
+		}
+	}
+}
+
+// RunScenario loads the scenario in dir and checks each request against its golden file
+func RunScenario(t *testing.T, dir string) {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(dir, "scenario.json")) //nolint:gosec // G304: Test fixture path - test code only
+	require.NoError(t, err, "Failed to load scenario: %s", dir)
+	var scenario Scenario
+	require.NoError(t, json.Unmarshal(data, &scenario), "Failed to parse scenario: %s", dir)
+
+	workspace, err := filepath.Abs(filepath.Join(dir, "workspace"))
+	require.NoError(t, err)
+	server := newScenarioServer(t, workspace, scenario.Config)
+
+	opened := map[string]bool{}
+	for _, request := range scenario.Requests {
+		t.Run(request.Name, func(t *testing.T) {
+			path := filepath.Join(workspace, filepath.FromSlash(request.File))
+			uri := uriutil.PathToURI(path)
+			if !opened[uri] {
+				openScenarioFile(t, server, uri, path)
+				opened[uri] = true
+			}
+
+			result := scenarioResult(t, server, uri, request)
+			actual, err := json.MarshalIndent(result, "", "  ")
+			require.NoError(t, err)
+			actual = []byte(strings.ReplaceAll(string(actual), workspace, workspacePlaceholder) + "\n")
+
+			golden := filepath.Join(dir, "golden", request.Name+".json")
+			if *update {
+				require.NoError(t, os.MkdirAll(filepath.Dir(golden), 0o755))
+				require.NoError(t, os.WriteFile(golden, actual, 0o644)) //nolint:gosec // G306: Golden files are checked in
+				return
+			}
+
+			expected, err := os.ReadFile(golden) //nolint:gosec // G304: Test fixture path - test code only
+			require.NoError(t, err, "golden file %s not found; run with -update to create", golden)
+			assert.Equal(t, string(expected), string(actual))
+		})
+	}
+}
+
+// newScenarioServer creates a server rooted at workspace, with config applied over the defaults
+func newScenarioServer(t *testing.T, workspace string, config json.RawMessage) *lsp.Server {
+	t.Helper()
+	server := NewTestServer(t)
+	SetCodeActionLiteralSupport(server)
+	server.SetRootPath(workspace)
+
+	cfg := types.DefaultConfig()
+	if len(config) > 0 {
+		require.NoError(t, json.Unmarshal(config, &cfg), "Failed to parse scenario config")
+	}
+	server.SetConfig(cfg)
+	require.NoError(t, server.LoadTokensFromConfig(), "Failed to load scenario tokens")
+	return server
+}
+
+// openScenarioFile opens a workspace file as a document in the server
+func openScenarioFile(t *testing.T, server *lsp.Server, uri, path string) {
+	t.Helper()
+	content, err := os.ReadFile(path) //nolint:gosec // G304: Test fixture path - test code only
+	require.NoError(t, err, "Failed to load scenario file: %s", path)
+
+	languageID := strings.TrimPrefix(filepath.Ext(path), ".")
+	if languageID == "yml" {
+		languageID = "yaml"
+	}
+	req := types.NewRequestContext(server, nil)
+	err = textDocument.DidOpen(req, &protocol.DidOpenTextDocumentParams{
+		TextDocument: protocol.TextDocumentItem{
+			URI:        uri,
+			LanguageID: languageID,
+			Version:    1,
+			Text:       string(content),
+		},
+	})
+	require.NoError(t, err, "Failed to open scenario file: %s", path)
+}
+
+// scenarioResult makes a scenario request and returns its result
+func scenarioResult(t *testing.T, server *lsp.Server, uri string, request ScenarioRequest) any {
+	t.Helper()
+	req := types.NewRequestContext(server, nil)
+	position := protocol.Position{Line: request.Line, Character: request.Character}
+
+	switch request.Method {
+	case "hover":
+		result, err := hover.Hover(req, &protocol.HoverParams{
+			TextDocumentPositionParams: protocol.TextDocumentPositionParams{
+				TextDocument: protocol.TextDocumentIdentifier{URI: uri},
+				Position:     position,
+			},
+		})
+		require.NoError(t, err)
+		return result
+	case "diagnostics":
+		result, err := diagnostic.GetDiagnostics(server, uri)
+		require.NoError(t, err)
+		return result
+	case "codeAction":
+		diagnostics, err := diagnostic.GetDiagnostics(server, uri)
+		require.NoError(t, err)
+		// Send the diagnostics at the position, as a client would
+		var context []protocol.Diagnostic
+		for _, d := range diagnostics {
+			if d.Range.Start.Line <= request.Line && request.Line <= d.Range.End.Line {
+				context = append(context, d)
+			}
+		}
+		result, err := codeaction.CodeAction(req, &protocol.CodeActionParams{
+			TextDocument: protocol.TextDocumentIdentifier{URI: uri},
+			Range:        protocol.Range{Start: position, End: position},
+			Context:      protocol.CodeActionContext{Diagnostics: context},
+		})
+		require.NoError(t, err)
+		return result
+	default:
+		t.Fatalf("unknown scenario method %q in request %s", request.Method, request.Name)
+		return nil
+	}
+}